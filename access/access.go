@@ -0,0 +1,124 @@
+// Package access builds the role × table × privilege matrix of access
+// reviews from the table privileges read by dbinfo.GetTablePrivileges, or
+// recorded in a snapshot taken with dbinfo.WithPrivileges (see
+// DBInfo.TablePrivileges), and writes it as text, CSV, HTML or JSON.
+//
+// Each row of the matrix is a role holding privileges on a table, and each
+// column a privilege. Privileges inherited through role membership aren't
+// expanded, so a review lists the roles granted access directly.
+package access
+
+import (
+	"cmp"
+	"path"
+	"slices"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Level is the access a role has through a privilege
+type Level string
+
+// Levels of access. A role without a privilege has no cell in its row.
+const (
+	Granted   Level = "granted"
+	Grantable Level = "grantable" // Granted WITH GRANT OPTION, so the role can grant it too
+)
+
+// StandardPrivileges are the table privileges, always columns of the
+// matrix, in the order of the GRANT documentation
+var StandardPrivileges = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"}
+
+// Row is the access of a role to a table
+type Row struct {
+	Schema     string           `json:"schema"`
+	Table      string           `json:"table"`
+	Role       string           `json:"role"`
+	Privileges map[string]Level `json:"privileges"`
+}
+
+// Matrix is the access of every role to every table
+type Matrix struct {
+	Privileges []string `json:"privileges"` // The columns: StandardPrivileges, then others found such as MAINTAIN
+	Rows       []*Row   `json:"rows"`
+}
+
+// Options selects and orders the rows of a matrix
+type Options struct {
+	Roles  []string // path.Match patterns of the roles to keep, all when empty
+	ByRole bool     // Order rows by role first, to review the access of each role in turn
+}
+
+// Build returns the matrix of privileges
+func Build(privileges []*dbinfo.TablePrivilege, opts Options) *Matrix {
+	m := &Matrix{Privileges: slices.Clone(StandardPrivileges), Rows: []*Row{}}
+	type rowKey struct{ schema, table, role string }
+	rows := map[rowKey]*Row{}
+	var others []string
+	for _, p := range privileges {
+		if !matchRole(opts.Roles, p.Role) {
+			continue
+		}
+		key := rowKey{p.Schema, p.Table, p.Role}
+		row := rows[key]
+		if row == nil {
+			row = &Row{Schema: p.Schema, Table: p.Table, Role: p.Role, Privileges: map[string]Level{}}
+			rows[key] = row
+			m.Rows = append(m.Rows, row)
+		}
+		level := Granted
+		if p.Grantable {
+			level = Grantable
+		}
+		row.Privileges[p.Privilege] = level
+		if !slices.Contains(m.Privileges, p.Privilege) && !slices.Contains(others, p.Privilege) {
+			others = append(others, p.Privilege)
+		}
+	}
+	slices.Sort(others)
+	m.Privileges = append(m.Privileges, others...)
+
+	slices.SortFunc(m.Rows, func(a, b *Row) int {
+		byTable := cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Table, b.Table))
+		if opts.ByRole {
+			return cmp.Or(cmp.Compare(a.Role, b.Role), byTable)
+		}
+		return cmp.Or(byTable, cmp.Compare(a.Role, b.Role))
+	})
+	return m
+}
+
+// matchRole reports whether role matches one of the patterns, or whether
+// there are none
+func matchRole(patterns []string, role string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, role); match {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles returns the roles of the matrix, sorted
+func (m *Matrix) Roles() []string {
+	var roles []string
+	for _, row := range m.Rows {
+		if !slices.Contains(roles, row.Role) {
+			roles = append(roles, row.Role)
+		}
+	}
+	slices.Sort(roles)
+	return roles
+}
+
+// Tables returns the number of tables of the matrix
+func (m *Matrix) Tables() int {
+	tables := map[[2]string]bool{}
+	for _, row := range m.Rows {
+		tables[[2]string{row.Schema, row.Table}] = true
+	}
+	return len(tables)
+}
@@ -0,0 +1,104 @@
+package access
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testPrivileges() []*dbinfo.TablePrivilege {
+	return []*dbinfo.TablePrivilege{
+		{Schema: "public", Table: "orders", Role: "PUBLIC", Privilege: "SELECT"},
+		{Schema: "public", Table: "orders", Role: "admin", Privilege: "DELETE", Grantable: true},
+		{Schema: "public", Table: "orders", Role: "admin", Privilege: "MAINTAIN", Grantable: true},
+		{Schema: "public", Table: "orders", Role: "admin", Privilege: "SELECT", Grantable: true},
+		{Schema: "public", Table: "orders", Role: "app", Privilege: "INSERT"},
+		{Schema: "public", Table: "orders", Role: "app", Privilege: "SELECT"},
+		{Schema: "billing", Table: "invoices", Role: "app", Privilege: "SELECT"},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	m := Build(testPrivileges(), Options{})
+	if diff := cmp.Diff([]string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER", "MAINTAIN"}, m.Privileges); diff != "" {
+		t.Errorf("privileges (-want +got):\n%s", diff)
+	}
+	want := []*Row{
+		{Schema: "billing", Table: "invoices", Role: "app", Privileges: map[string]Level{"SELECT": Granted}},
+		{Schema: "public", Table: "orders", Role: "PUBLIC", Privileges: map[string]Level{"SELECT": Granted}},
+		{Schema: "public", Table: "orders", Role: "admin", Privileges: map[string]Level{"DELETE": Grantable, "MAINTAIN": Grantable, "SELECT": Grantable}},
+		{Schema: "public", Table: "orders", Role: "app", Privileges: map[string]Level{"INSERT": Granted, "SELECT": Granted}},
+	}
+	if diff := cmp.Diff(want, m.Rows); diff != "" {
+		t.Errorf("rows (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"PUBLIC", "admin", "app"}, m.Roles()); diff != "" {
+		t.Errorf("roles (-want +got):\n%s", diff)
+	}
+	if m.Tables() != 2 {
+		t.Errorf("Expected 2 tables, got %d", m.Tables())
+	}
+
+	// Rows of a role together, only for the roles selected
+	var got []string
+	for _, row := range Build(testPrivileges(), Options{Roles: []string{"a*"}, ByRole: true}).Rows {
+		got = append(got, row.Role+" "+row.Schema+"."+row.Table)
+	}
+	if diff := cmp.Diff([]string{"admin public.orders", "app billing.invoices", "app public.orders"}, got); diff != "" {
+		t.Errorf("rows by role (-want +got):\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	m := Build(testPrivileges(), Options{})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"TABLE             ROLE    SELECT  INSERT  UPDATE  DELETE  TRUNCATE  REFERENCES  TRIGGER  MAINTAIN\n",
+		"public.orders     admin   x*                      x*                                     x*\n",
+		"3 roles on 2 tables; x* marks privileges held with the grant option\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the text output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteCSV(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "schema,table,role,SELECT,INSERT,UPDATE,DELETE,TRUNCATE,REFERENCES,TRIGGER,MAINTAIN" ||
+		lines[4] != "public,orders,app,granted,granted,,,,,," {
+		t.Errorf("Unexpected CSV output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteHTML(&buf, "shop", m); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>Access review of shop</title>",
+		"<p>3 roles on 2 tables.",
+		`<tr><td>public.orders</td><td>app</td><td class="cell granted">✓</td><td class="cell granted">✓</td><td class="cell "></td>`,
+		`<td class="cell grantable">✓+</td>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the HTML output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, Build(nil, Options{})); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"rows": []`) {
+		t.Errorf("Expected an empty rows array, got %s", buf.String())
+	}
+}
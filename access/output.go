@@ -0,0 +1,126 @@
+package access
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// mark returns the text of a cell: x for a privilege, x* for one held with
+// the grant option
+func (l Level) mark() string {
+	switch l {
+	case Granted:
+		return "x"
+	case Grantable:
+		return "x*"
+	}
+	return ""
+}
+
+// table returns the schema qualified name of the table of the row
+func (r *Row) table() string {
+	return dbinfo.QualifiedName(r.Schema, r.Table)
+}
+
+// WriteText writes the matrix to w as aligned columns, followed by a legend
+func WriteText(w io.Writer, m *Matrix) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "TABLE\tROLE\t%s\n", strings.Join(m.Privileges, "\t"))
+	for _, row := range m.Rows {
+		cells := make([]string, len(m.Privileges))
+		for i, privilege := range m.Privileges {
+			cells[i] = row.Privileges[privilege].mark()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", row.table(), row.Role, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d roles on %d tables; x* marks privileges held with the grant option\n", len(m.Roles()), m.Tables())
+	return err
+}
+
+// WriteCSV writes the matrix to w as CSV, for spreadsheets: a row per role
+// and table, with a column per privilege holding granted, grantable or
+// nothing
+func WriteCSV(w io.Writer, m *Matrix) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"schema", "table", "role"}, m.Privileges...))
+	for _, row := range m.Rows {
+		record := []string{row.Schema, row.Table, row.Role}
+		for _, privilege := range m.Privileges {
+			record = append(record, string(row.Privileges[privilege]))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the matrix to w as JSON
+func WriteJSON(w io.Writer, m *Matrix) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// htmlTemplate is the standalone page of WriteHTML
+var htmlTemplate = template.Must(template.New("access").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Access review of {{.Name}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+th { background: #f3f3f3; text-align: left; }
+td.cell { text-align: center; }
+td.granted { background: #e6f4ea; }
+td.grantable { background: #fce8d5; }
+</style>
+</head>
+<body>
+<h1>Access review of {{.Name}}</h1>
+<p>{{len .Matrix.Roles}} roles on {{.Matrix.Tables}} tables. Privileges marked ✓+ are held with the grant option, so the role can grant them to others. Privileges inherited through role membership are not listed.</p>
+<table>
+<thead>
+<tr><th>Table</th><th>Role</th>{{range .Matrix.Privileges}}<th>{{.}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{- range .Rows}}
+<tr><td>{{.Table}}</td><td>{{.Role}}</td>{{range .Cells}}<td class="cell {{.}}">{{if eq . "granted"}}✓{{else if eq . "grantable"}}✓+{{end}}</td>{{end}}</tr>
+{{- end}}
+</tbody>
+</table>
+</body>
+</html>
+`))
+
+// WriteHTML writes the matrix to w as a standalone HTML page, the access
+// review of the database name
+func WriteHTML(w io.Writer, name string, m *Matrix) error {
+	type htmlRow struct {
+		Table, Role string
+		Cells       []Level
+	}
+	rows := make([]htmlRow, len(m.Rows))
+	for i, row := range m.Rows {
+		rows[i] = htmlRow{Table: row.table(), Role: row.Role}
+		for _, privilege := range m.Privileges {
+			rows[i].Cells = append(rows[i].Cells, row.Privileges[privilege])
+		}
+	}
+	return htmlTemplate.Execute(w, struct {
+		Name   string
+		Matrix *Matrix
+		Rows   []htmlRow
+	}{name, m, rows})
+}
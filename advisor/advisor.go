@@ -0,0 +1,238 @@
+// Package advisor recommends index changes from the structure of a schema:
+// indexes to create for foreign keys without one, and indexes to drop
+// because another index or the primary key already serves them.
+//
+// Recommendations are prioritized with table and index statistics when
+// they are available: an unindexed foreign key on a large table, or a large
+// redundant index, matters more than one on a small table.
+package advisor
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/indexes"
+)
+
+// Action is what a recommendation does to an index
+type Action string
+
+// Actions
+const (
+	Create Action = "create"
+	Drop   Action = "drop"
+)
+
+// Priority orders recommendations
+type Priority string
+
+// Priorities, from most to least important
+const (
+	High   Priority = "high"
+	Medium Priority = "medium"
+	Low    Priority = "low"
+)
+
+// rank orders priorities so they can be compared
+func (p Priority) rank() int {
+	switch p {
+	case High:
+		return 3
+	case Medium:
+		return 2
+	case Low:
+		return 1
+	}
+	return 0
+}
+
+// Thresholds of the priorities. Recommendations without statistics are
+// Medium.
+const (
+	highRows    = 100_000
+	mediumRows  = 1_000
+	highBytes   = 100 << 20
+	mediumBytes = 1 << 20
+)
+
+// Recommendation is an index to create or drop
+type Recommendation struct {
+	Action   Action   `json:"action"`
+	Priority Priority `json:"priority"`
+	Schema   string   `json:"schema"`
+	Table    string   `json:"table"`
+	Index    string   `json:"index"` // Index to drop, or name of the index to create
+	Columns  []string `json:"columns"`
+	Reason   string   `json:"reason"`
+	// Rows is the row estimate of the table, -1 without statistics
+	Rows int64 `json:"rows"`
+	// Bytes is the estimated size of the index to create or the size of the
+	// index to drop, -1 without statistics
+	Bytes     int64  `json:"bytes"`
+	Statement string `json:"statement"`
+}
+
+// Advise returns the recommendations for info, the most important first.
+// The statistics are optional: tables and indexes missing from them are
+// prioritized as Medium.
+func Advise(info *dbinfo.DBInfo, tables []*dbinfo.TableStats, indexStats []*dbinfo.IndexStats) []*Recommendation {
+	rows := map[dbinfo.ObjectKey]int64{}
+	for _, s := range tables {
+		rows[dbinfo.ObjectKey{Schema: s.Schema, Name: s.Name}] = s.RowEstimate
+	}
+	// Index names are unique within a schema
+	sizes := map[dbinfo.ObjectKey]int64{}
+	for _, s := range indexStats {
+		sizes[dbinfo.ObjectKey{Schema: s.Schema, Name: s.Name}] = s.Bytes
+	}
+
+	var recs []*Recommendation
+	for _, table := range info.Tables {
+		n, ok := rows[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}]
+		if !ok {
+			n = -1
+		}
+		for _, rec := range missingIndexes(table) {
+			rec.Rows, rec.Bytes = n, -1
+			if n >= 0 {
+				rec.Bytes = estimateSize(table, rec.Columns, n)
+			}
+			rec.Priority = priority(n, highRows, mediumRows)
+			recs = append(recs, rec)
+		}
+		for _, rec := range redundantIndexes(table) {
+			rec.Rows, rec.Bytes = n, -1
+			if size, ok := sizes[dbinfo.ObjectKey{Schema: table.Schema, Name: rec.Index}]; ok {
+				rec.Bytes = size
+			}
+			rec.Priority = priority(rec.Bytes, highBytes, mediumBytes)
+			recs = append(recs, rec)
+		}
+	}
+
+	slices.SortStableFunc(recs, func(a, b *Recommendation) int {
+		return cmp.Or(
+			cmp.Compare(b.Priority.rank(), a.Priority.rank()),
+			cmp.Compare(b.Bytes, a.Bytes),
+			cmp.Compare(a.Schema, b.Schema),
+			cmp.Compare(a.Table, b.Table),
+			cmp.Compare(a.Index, b.Index),
+		)
+	})
+	return recs
+}
+
+// priority ranks a statistic, -1 when unknown, against its thresholds
+func priority(value, high, medium int64) Priority {
+	switch {
+	case value < 0:
+		return Medium
+	case value >= high:
+		return High
+	case value >= medium:
+		return Medium
+	}
+	return Low
+}
+
+// missingIndexes recommends an index for each foreign key of table that no
+// index or primary key starts with
+func missingIndexes(table *dbinfo.Table) []*Recommendation {
+	keys := indexes.Keys(table)
+	var recs []*Recommendation
+	seen := map[string]bool{}
+	for _, fk := range table.ForeignKeys {
+		if slices.ContainsFunc(keys, func(key []string) bool { return indexes.Serves(key, fk.ColumnNames) }) {
+			continue
+		}
+		// Foreign keys on the same columns share the index
+		name := table.Name + "_" + strings.Join(fk.ColumnNames, "_") + "_idx"
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		recs = append(recs, &Recommendation{
+			Action:  Create,
+			Schema:  table.Schema,
+			Table:   table.Name,
+			Index:   name,
+			Columns: fk.ColumnNames,
+			Reason:  fmt.Sprintf("foreign key %s has no index, so joins and deletes on %s scan %s", fk.Name, fk.RefTableName, table.Name),
+			Statement: fmt.Sprintf("CREATE INDEX CONCURRENTLY %s ON %s (%s);",
+				dbinfo.QuoteIdent(name), table.QuotedName(), quoteIdents(fk.ColumnNames)),
+		})
+	}
+	return recs
+}
+
+// redundantIndexes recommends dropping the indexes of table duplicating the
+// primary key or another index, and the non unique indexes that are a prefix
+// of a wider index
+func redundantIndexes(table *dbinfo.Table) []*Recommendation {
+	var recs []*Recommendation
+	for _, r := range indexes.Redundant(table) {
+		reason := "index " + r.Reason()
+		if r.Index.Unique {
+			reason += "; drop the constraint instead if the index backs a UNIQUE constraint"
+		}
+		recs = append(recs, &Recommendation{
+			Action:    Drop,
+			Schema:    table.Schema,
+			Table:     table.Name,
+			Index:     r.Index.Name,
+			Columns:   r.Index.Columns,
+			Reason:    reason,
+			Statement: fmt.Sprintf("DROP INDEX CONCURRENTLY %s;", dbinfo.QualifiedName(table.Schema, r.Index.Name)),
+		})
+	}
+	return recs
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = dbinfo.QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// typeWidths are the sizes in bytes of fixed width key types. Other types
+// count as variableWidth.
+var typeWidths = map[string]int64{
+	"smallint":                    2,
+	"integer":                     4,
+	"bigint":                      8,
+	"real":                        4,
+	"double precision":            8,
+	"boolean":                     1,
+	"date":                        4,
+	"timestamp with time zone":    8,
+	"timestamp without time zone": 8,
+	"uuid":                        16,
+}
+
+// variableWidth is the assumed size of variable width keys, such as text
+const variableWidth = 32
+
+// estimateSize estimates the size of a B-tree index on columns of table
+// holding rows entries: each entry has an 8 byte header and a 4 byte line
+// pointer, keys are aligned to 8 bytes, and leaf pages are filled to 90%
+func estimateSize(table *dbinfo.Table, columns []string, rows int64) int64 {
+	var width int64
+	for _, name := range columns {
+		w := int64(variableWidth)
+		for _, column := range table.Columns {
+			if column.Name == name {
+				if fixed, ok := typeWidths[column.Type]; ok {
+					w = fixed
+				}
+			}
+		}
+		width += w
+	}
+	entry := 12 + (width+7)/8*8
+	return rows * entry * 10 / 9
+}
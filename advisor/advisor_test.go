@@ -0,0 +1,151 @@
+package advisor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema:  "public",
+				Name:    "customers",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}, {Name: "email", Type: "text"}},
+				Indexes: []*dbinfo.Index{
+					{Name: "customers_id_key", Unique: true, Columns: []string{"id"}},
+					{Name: "customers_email_idx", Columns: []string{"email"}},
+					{Name: "customers_email_idx2", Columns: []string{"email"}},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer"},
+					{Name: "status", Type: "text"},
+					{Name: "created_at", Type: "timestamp with time zone"},
+				},
+				Indexes: []*dbinfo.Index{
+					{Name: "orders_status_idx", Columns: []string{"status"}},
+					{Name: "orders_status_created_at_idx", Columns: []string{"status", "created_at"}},
+					// The same columns in another order serve other queries
+					{Name: "orders_created_at_status_idx", Columns: []string{"created_at", "status"}},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{{
+					Name:           "orders_customer_id_fkey",
+					ColumnNames:    []string{"customer_id"},
+					RefTableSchema: "public",
+					RefTableName:   "customers",
+					RefColumnNames: []string{"id"},
+				}},
+			},
+			{
+				Schema:  "public",
+				Name:    "order_items",
+				Columns: []*dbinfo.Column{{Name: "order_id", Type: "bigint", IsPrimaryKey: true}, {Name: "line", Type: "integer", IsPrimaryKey: true}},
+				ForeignKeys: []*dbinfo.ForeignKey{{
+					Name:           "order_items_order_id_fkey",
+					ColumnNames:    []string{"order_id"},
+					RefTableSchema: "public",
+					RefTableName:   "orders",
+					RefColumnNames: []string{"id"},
+				}},
+			},
+		},
+	}
+}
+
+// summary is the part of a recommendation the tests compare
+type summary struct {
+	Priority Priority
+	Action   Action
+	Index    string
+	Bytes    int64
+}
+
+func summarize(recs []*Recommendation) []summary {
+	var got []summary
+	for _, r := range recs {
+		got = append(got, summary{r.Priority, r.Action, r.Table + "." + r.Index, r.Bytes})
+	}
+	return got
+}
+
+func TestAdvise(t *testing.T) {
+	recs := Advise(testInfo(), nil, nil)
+	want := []summary{
+		{Medium, Drop, "customers.customers_email_idx2", -1},
+		{Medium, Drop, "customers.customers_id_key", -1},
+		{Medium, Create, "orders.orders_customer_id_idx", -1},
+		{Medium, Drop, "orders.orders_status_idx", -1},
+	}
+	if diff := cmp.Diff(want, summarize(recs)); diff != "" {
+		t.Errorf("recommendations mismatch (-want +got):\n%s", diff)
+	}
+	if got, want := recs[2].Statement, "CREATE INDEX CONCURRENTLY orders_customer_id_idx ON public.orders (customer_id);"; got != want {
+		t.Errorf("statement = %q, want %q", got, want)
+	}
+	if got, want := recs[1].Reason, "index duplicates the primary key; drop the constraint instead if the index backs a UNIQUE constraint"; got != want {
+		t.Errorf("reason = %q, want %q", got, want)
+	}
+}
+
+func TestAdviseWithStats(t *testing.T) {
+	tables := []*dbinfo.TableStats{
+		{Schema: "public", Name: "customers", RowEstimate: 500},
+		{Schema: "public", Name: "orders", RowEstimate: 2_000_000},
+	}
+	indexes := []*dbinfo.IndexStats{
+		{Schema: "public", Table: "customers", Name: "customers_email_idx2", Bytes: 16 << 10},
+		{Schema: "public", Table: "customers", Name: "customers_id_key", Bytes: 8 << 10},
+		{Schema: "public", Table: "orders", Name: "orders_status_idx", Bytes: 200 << 20},
+	}
+	want := []summary{
+		{High, Drop, "orders.orders_status_idx", 200 << 20},
+		// 2M entries of 12 bytes and an aligned 4 byte key, 90% full
+		{High, Create, "orders.orders_customer_id_idx", 2_000_000 * 20 * 10 / 9},
+		{Low, Drop, "customers.customers_email_idx2", 16 << 10},
+		{Low, Drop, "customers.customers_id_key", 8 << 10},
+	}
+	if diff := cmp.Diff(want, summarize(Advise(testInfo(), tables, indexes))); diff != "" {
+		t.Errorf("recommendations mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tables := []*dbinfo.TableStats{{Schema: "public", Name: "orders", RowEstimate: 2_000_000}}
+	recs := Advise(testInfo(), tables, nil)[:2]
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, recs); err != nil {
+		t.Fatal(err)
+	}
+	want := "high    create  public.orders.orders_customer_id_idx   +42.4 MiB  foreign key orders_customer_id_fkey has no index, so joins and deletes on customers scan orders\n" +
+		"medium  drop    public.customers.customers_email_idx2  ?          index duplicates customers_email_idx\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("text mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteSQL(&buf, recs[1:]); err != nil {
+		t.Fatal(err)
+	}
+	want = "-- medium priority: index duplicates customers_email_idx\nDROP INDEX CONCURRENTLY public.customers_email_idx2;\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("SQL mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("WriteJSON(nil) = %q, want an empty array", got)
+	}
+}
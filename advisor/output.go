@@ -0,0 +1,62 @@
+package advisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteText writes one aligned line per recommendation to w, with the
+// estimated size change
+func WriteText(w io.Writer, recs []*Recommendation) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range recs {
+		size := "?"
+		if r.Bytes >= 0 {
+			sign := "+"
+			if r.Action == Drop {
+				sign = "-"
+			}
+			size = sign + formatBytes(r.Bytes)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s.%s.%s\t%s\t%s\n", r.Priority, r.Action, r.Schema, r.Table, r.Index, size, r.Reason)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the recommendations to w as a JSON array
+func WriteJSON(w io.Writer, recs []*Recommendation) error {
+	if recs == nil {
+		recs = []*Recommendation{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(recs)
+}
+
+// WriteSQL writes the statements applying the recommendations to w, each
+// after a comment with its reason. The statements run CONCURRENTLY, so
+// they can't run in a transaction.
+func WriteSQL(w io.Writer, recs []*Recommendation) error {
+	for _, r := range recs {
+		if _, err := fmt.Fprintf(w, "-- %s priority: %s\n%s\n", r.Priority, r.Reason, r.Statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatBytes prints a size with a binary unit, like pg_size_pretty
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
@@ -0,0 +1,257 @@
+// Package anonymize produces shareable copies of dbinfo schemas where
+// business terms have been replaced by deterministic pseudonyms.
+//
+// Schema, table, column, index and constraint names are replaced with
+// names derived from an HMAC of the original name, so the same name maps to
+// the same pseudonym everywhere it appears (foreign keys, relationships and
+// index columns stay consistent). Comments, default values and business
+// term tags are dropped. Types, nullability, keys, relationships and other
+// tags are kept, except that the names of enums, domains and other types
+// that aren't built in are pseudonymized too.
+//
+// Only tables are kept. Views, materialized views and rules are defined by
+// SQL text that can't be rewritten reliably, so they are stripped along with
+// sequences, domains, foreign tables, event triggers, owners, grants, table
+// statistics and the database locale.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/glossary"
+)
+
+// Prefixes used for each kind of pseudonymized object
+const (
+	SchemaPrefix     = "s_"
+	TablePrefix      = "t_"
+	ColumnPrefix     = "c_"
+	IndexPrefix      = "i_"
+	ConstraintPrefix = "k_"
+	RelationPrefix   = "r_"
+	DatabasePrefix   = "db_"
+	TypePrefix       = "y_"
+)
+
+// identifierPattern matches the identifiers that can appear in an index expression
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_$]*`)
+
+// Anonymizer replaces names with deterministic pseudonyms
+type Anonymizer struct {
+	key []byte
+}
+
+// New returns an Anonymizer using key to derive pseudonyms. Without a key
+// pseudonyms are still stable, but common names can be recovered by hashing
+// a dictionary of candidates, so a secret key should be used for anything
+// leaving the organization.
+func New(key []byte) *Anonymizer {
+	return &Anonymizer{key: key}
+}
+
+// Name returns the pseudonym for name, prefixed with prefix.
+// The public schema is kept as is since it carries no business meaning.
+func (a *Anonymizer) Name(prefix, name string) string {
+	if name == "" || (prefix == SchemaPrefix && name == "public") {
+		return name
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(prefix))
+	mac.Write([]byte(name))
+	return prefix + hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+func (a *Anonymizer) names(prefix string, names []string) []string {
+	if names == nil {
+		return nil
+	}
+	result := make([]string, len(names))
+	for i, name := range names {
+		result[i] = a.Name(prefix, name)
+	}
+	return result
+}
+
+// DBInfo returns an anonymized copy of info. The original is not modified.
+func (a *Anonymizer) DBInfo(info *dbinfo.DBInfo) *dbinfo.DBInfo {
+	result := &dbinfo.DBInfo{
+		Name:         a.Name(DatabasePrefix, info.Name),
+		Generator:    info.Generator,
+		Tables:       make([]*dbinfo.Table, len(info.Tables)),
+		Capabilities: info.Capabilities.Clone(),
+	}
+	for i, table := range info.Tables {
+		result.Tables[i] = a.Table(table)
+	}
+	result.EnsureSlices()
+	return result
+}
+
+// Table returns an anonymized copy of table. The original is not modified.
+func (a *Anonymizer) Table(table *dbinfo.Table) *dbinfo.Table {
+	result := &dbinfo.Table{
+		Name:     a.Name(TablePrefix, table.Name),
+		Schema:   a.Name(SchemaPrefix, table.Schema),
+		Database: a.Name(DatabasePrefix, table.Database),
+		Tags:     tags(table.Tags),
+	}
+
+	if table.Columns != nil {
+		result.Columns = make([]*dbinfo.Column, len(table.Columns))
+		for i, column := range table.Columns {
+			result.Columns[i] = &dbinfo.Column{
+				Name:         a.Name(ColumnPrefix, column.Name),
+				Type:         column.Type,
+				FullType:     a.columnType(column),
+				IsNullable:   column.IsNullable,
+				IsPrimaryKey: column.IsPrimaryKey,
+				Kind:         column.Kind,
+				Identity:     column.Identity,
+				Tags:         tags(column.Tags),
+			}
+		}
+	}
+
+	if table.PrimaryKey != nil {
+		result.PrimaryKey = &dbinfo.PrimaryKey{
+			Name:    a.Name(ConstraintPrefix, table.PrimaryKey.Name),
+			Columns: a.names(ColumnPrefix, table.PrimaryKey.Columns),
+		}
+	}
+
+	if table.Indexes != nil {
+		result.Indexes = make([]*dbinfo.Index, len(table.Indexes))
+		for i, index := range table.Indexes {
+			result.Indexes[i] = &dbinfo.Index{
+				Name:             a.Name(IndexPrefix, index.Name),
+				Unique:           index.Unique,
+				NullsNotDistinct: index.NullsNotDistinct,
+				Columns:          a.names(ColumnPrefix, index.Columns),
+				Expression:       a.expression(table, index.Expression),
+			}
+			for _, key := range index.Keys {
+				result.Indexes[i].Keys = append(result.Indexes[i].Keys, &dbinfo.IndexKey{
+					Column:     a.Name(ColumnPrefix, key.Column),
+					Expression: a.expression(table, key.Expression),
+				})
+			}
+		}
+	}
+
+	if table.ForeignKeys != nil {
+		result.ForeignKeys = make([]*dbinfo.ForeignKey, len(table.ForeignKeys))
+		for i, fk := range table.ForeignKeys {
+			result.ForeignKeys[i] = &dbinfo.ForeignKey{
+				Name:           a.Name(ConstraintPrefix, fk.Name),
+				ColumnNames:    a.names(ColumnPrefix, fk.ColumnNames),
+				RefTableSchema: a.Name(SchemaPrefix, fk.RefTableSchema),
+				RefTableName:   a.Name(TablePrefix, fk.RefTableName),
+				RefColumnNames: a.names(ColumnPrefix, fk.RefColumnNames),
+				OnUpdate:       fk.OnUpdate,
+				OnDelete:       fk.OnDelete,
+			}
+		}
+	}
+
+	result.HasMany = a.relationships(table.HasMany)
+	result.BelongsTo = a.relationships(table.BelongsTo)
+	result.EnsureSlices()
+
+	return result
+}
+
+func (a *Anonymizer) relationships(relationships []*dbinfo.Relationship) []*dbinfo.Relationship {
+	if relationships == nil {
+		return nil
+	}
+	result := make([]*dbinfo.Relationship, len(relationships))
+	for i, rel := range relationships {
+		result[i] = &dbinfo.Relationship{
+			Name:       a.Name(RelationPrefix, rel.Name),
+			Table:      a.Name(TablePrefix, rel.Table),
+			Schema:     a.Name(SchemaPrefix, rel.Schema),
+			Database:   a.Name(DatabasePrefix, rel.Database),
+			ForeignKey: a.Name(ConstraintPrefix, rel.ForeignKey),
+			Columns:    a.names(ColumnPrefix, rel.Columns),
+			References: a.names(ColumnPrefix, rel.References),
+			OnUpdate:   rel.OnUpdate,
+			OnDelete:   rel.OnDelete,
+		}
+	}
+	return result
+}
+
+// builtinTypes are the names format_type prints for the built-in types,
+// without modifiers, used to tell arrays of them from arrays of user
+// defined types
+var builtinTypes = map[string]bool{
+	"smallint": true, "integer": true, "bigint": true, "numeric": true, "real": true, "double precision": true,
+	"boolean": true, "text": true, "character varying": true, "character": true, `"char"`: true, "bytea": true,
+	"uuid": true, "bit": true, "bit varying": true, "date": true, "interval": true, "money": true,
+	"timestamp without time zone": true, "timestamp with time zone": true,
+	"time without time zone": true, "time with time zone": true,
+	"json": true, "jsonb": true, "jsonpath": true, "xml": true,
+	"inet": true, "cidr": true, "macaddr": true, "macaddr8": true, "tsvector": true, "tsquery": true,
+	"point": true, "line": true, "lseg": true, "box": true, "path": true, "polygon": true, "circle": true,
+	"int4range": true, "int8range": true, "numrange": true, "tsrange": true, "tstzrange": true, "daterange": true,
+	"oid": true, "name": true, "regclass": true, "regtype": true, "regproc": true, "pg_lsn": true,
+}
+
+// modifiersPattern matches the type modifiers format_type prints, such as
+// (10) in character varying(10) or (3) in timestamp(3) with time zone
+var modifiersPattern = regexp.MustCompile(`\([^)]*\)`)
+
+// columnType returns the full type of column, pseudonymizing the name of
+// the type when it isn't built in: enums, composite and extension types,
+// domains and arrays of them
+func (a *Anonymizer) columnType(column *dbinfo.Column) string {
+	base, _, _ := strings.Cut(column.FullType, "[")
+	switch {
+	case column.FullType == "":
+		return ""
+	case column.Domain != nil, column.Type == "USER-DEFINED":
+	case column.Type == "ARRAY" && !builtinTypes[modifiersPattern.ReplaceAllString(base, "")]:
+	default:
+		return column.FullType
+	}
+	arrays := column.FullType[len(base):]
+	if schema, name, ok := strings.Cut(base, "."); ok {
+		return a.Name(SchemaPrefix, schema) + "." + a.Name(TypePrefix, name) + arrays
+	}
+	return a.Name(TypePrefix, base) + arrays
+}
+
+// expression replaces the table's column names found in an index expression.
+// Function names, keywords and casts are left untouched.
+func (a *Anonymizer) expression(table *dbinfo.Table, expression string) string {
+	if expression == "" {
+		return ""
+	}
+	columns := make(map[string]bool, len(table.Columns))
+	for _, column := range table.Columns {
+		columns[column.Name] = true
+	}
+	return identifierPattern.ReplaceAllStringFunc(expression, func(word string) string {
+		if columns[word] {
+			return a.Name(ColumnPrefix, word)
+		}
+		return word
+	})
+}
+
+// tags returns a copy of tags without the business terms, which name what
+// the schema is about
+func tags(tags []string) []string {
+	var kept []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, glossary.TagPrefix) {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,169 @@
+package anonymize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	customers := &dbinfo.Table{
+		Name:    "customers",
+		Schema:  "billing",
+		Comment: "Customer information",
+		Tags:    []string{"term:Customer"},
+		Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", IsPrimaryKey: true, DefaultValue: "nextval('customers_id_seq'::regclass)"},
+			{Name: "email", Type: "character varying", Comment: "Contact email", Tags: []string{"pii:email", "term:Contact email"}},
+		},
+		Indexes: []*dbinfo.Index{
+			{
+				Name:       "idx_customers_email",
+				Unique:     true,
+				Columns:    []string{"id"},
+				Expression: "lower((email)::text)",
+				Keys:       []*dbinfo.IndexKey{{Expression: "lower((email)::text)"}, {Column: "id"}},
+			},
+		},
+		HasMany: []*dbinfo.Relationship{
+			{Table: "orders", Schema: "public", ForeignKey: "orders_customer_id_fkey", Columns: []string{"id"}, References: []string{"customer_id"}},
+		},
+		BelongsTo: []*dbinfo.Relationship{},
+	}
+	orders := &dbinfo.Table{
+		Name:   "orders",
+		Schema: "public",
+		Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", IsPrimaryKey: true},
+			{Name: "customer_id", Type: "integer", IsNullable: true},
+		},
+		ForeignKeys: []*dbinfo.ForeignKey{
+			{
+				Name:           "orders_customer_id_fkey",
+				ColumnNames:    []string{"customer_id"},
+				RefTableSchema: "billing",
+				RefTableName:   "customers",
+				RefColumnNames: []string{"id"},
+				OnDelete:       "CASCADE",
+			},
+		},
+		HasMany: []*dbinfo.Relationship{},
+		BelongsTo: []*dbinfo.Relationship{
+			{Table: "customers", Schema: "billing", ForeignKey: "orders_customer_id_fkey", Columns: []string{"customer_id"}, References: []string{"id"}, OnDelete: "CASCADE"},
+		},
+	}
+	return &dbinfo.DBInfo{Name: "shop", Tables: []*dbinfo.Table{customers, orders}}
+}
+
+func TestAnonymize(t *testing.T) {
+	info := testInfo()
+	result := New([]byte("secret")).DBInfo(info)
+
+	// The original must be left untouched
+	if info.Tables[0].Name != "customers" || info.Tables[0].Comment == "" {
+		t.Fatal("Original DBInfo was modified")
+	}
+
+	customers, orders := result.Tables[0], result.Tables[1]
+
+	if !strings.HasPrefix(result.Name, DatabasePrefix) {
+		t.Errorf("Expected database name to be pseudonymized, got %q", result.Name)
+	}
+	if !strings.HasPrefix(customers.Name, TablePrefix) || !strings.HasPrefix(customers.Schema, SchemaPrefix) {
+		t.Errorf("Expected table and schema to be pseudonymized, got %s.%s", customers.Schema, customers.Name)
+	}
+	if orders.Schema != "public" {
+		t.Errorf("Expected public schema to be kept, got %q", orders.Schema)
+	}
+
+	// Comments, defaults and business terms are stripped, structure is kept
+	if customers.Comment != "" || customers.Columns[1].Comment != "" {
+		t.Error("Expected comments to be stripped")
+	}
+	if customers.Tags != nil || len(customers.Columns[1].Tags) != 1 || customers.Columns[1].Tags[0] != "pii:email" {
+		t.Errorf("Expected only the pii tag to be kept, got %v and %v", customers.Tags, customers.Columns[1].Tags)
+	}
+	if customers.Columns[0].DefaultValue != "" {
+		t.Error("Expected default values to be stripped")
+	}
+	if customers.Columns[0].Type != "integer" || !customers.Columns[0].IsPrimaryKey {
+		t.Error("Expected column type and primary key to be kept")
+	}
+	if !orders.Columns[1].IsNullable {
+		t.Error("Expected nullability to be kept")
+	}
+
+	// References must use the same pseudonyms as the objects they point to
+	fk := orders.ForeignKeys[0]
+	if fk.RefTableName != customers.Name || fk.RefTableSchema != customers.Schema {
+		t.Errorf("Foreign key points to %s.%s, expected %s.%s", fk.RefTableSchema, fk.RefTableName, customers.Schema, customers.Name)
+	}
+	if fk.RefColumnNames[0] != customers.Columns[0].Name || fk.ColumnNames[0] != orders.Columns[1].Name {
+		t.Error("Foreign key columns don't match the pseudonymized columns")
+	}
+	if fk.OnDelete != "CASCADE" {
+		t.Errorf("Expected ON DELETE action to be kept, got %q", fk.OnDelete)
+	}
+	if rel := customers.HasMany[0]; rel.Table != orders.Name || rel.ForeignKey != fk.Name || rel.References[0] != fk.ColumnNames[0] {
+		t.Errorf("HasMany relationship doesn't match the pseudonymized foreign key: %+v", rel)
+	}
+	if rel := orders.BelongsTo[0]; rel.Table != customers.Name || rel.Schema != customers.Schema {
+		t.Errorf("BelongsTo relationship doesn't match the pseudonymized table: %+v", rel)
+	}
+
+	// Column names inside expressions are replaced, functions are kept
+	expected := "lower((" + customers.Columns[1].Name + ")::text)"
+	if customers.Indexes[0].Expression != expected {
+		t.Errorf("Expected expression %q, got %q", expected, customers.Indexes[0].Expression)
+	}
+	keys := customers.Indexes[0].Keys
+	if len(keys) != 2 || keys[0].Expression != expected || keys[1].Column != customers.Columns[0].Name {
+		t.Errorf("Expected index keys to be pseudonymized like the columns, got %v and %v", keys[0], keys[1])
+	}
+}
+
+func TestNameIsDeterministic(t *testing.T) {
+	a := New([]byte("secret"))
+	if a.Name(TablePrefix, "orders") != a.Name(TablePrefix, "orders") {
+		t.Error("Expected the same name to produce the same pseudonym")
+	}
+	if a.Name(TablePrefix, "orders") == a.Name(TablePrefix, "customers") {
+		t.Error("Expected different names to produce different pseudonyms")
+	}
+	if a.Name(TablePrefix, "orders") == New([]byte("other")).Name(TablePrefix, "orders") {
+		t.Error("Expected the key to change the pseudonym")
+	}
+}
+
+func TestAnonymizeTypes(t *testing.T) {
+	a := New([]byte("secret"))
+	tests := []struct {
+		column   dbinfo.Column
+		expected string
+	}{
+		{dbinfo.Column{Type: "character varying", FullType: "character varying(100)"}, "character varying(100)"},
+		{dbinfo.Column{Type: "ARRAY", FullType: "timestamp(3) with time zone[]"}, "timestamp(3) with time zone[]"},
+		{dbinfo.Column{Type: "USER-DEFINED", FullType: "order_status"}, a.Name(TypePrefix, "order_status")},
+		{dbinfo.Column{Type: "USER-DEFINED", FullType: "sales.status"}, a.Name(SchemaPrefix, "sales") + "." + a.Name(TypePrefix, "status")},
+		{dbinfo.Column{Type: "ARRAY", FullType: "order_status[]"}, a.Name(TypePrefix, "order_status") + "[]"},
+		{dbinfo.Column{Type: "text", FullType: "email", Domain: &dbinfo.DomainRef{Schema: "public", Name: "email"}}, a.Name(TypePrefix, "email")},
+	}
+	for _, test := range tests {
+		table := a.Table(&dbinfo.Table{Name: "orders", Schema: "public", Columns: []*dbinfo.Column{&test.column}})
+		if got := table.Columns[0].FullType; got != test.expected {
+			t.Errorf("Expected %s to be anonymized as %q, got %q", test.column.FullType, test.expected, got)
+		}
+	}
+}
+
+func TestAnonymizeKeepsOnlyTables(t *testing.T) {
+	info := testInfo()
+	info.Views = []*dbinfo.View{{Schema: "public", Name: "customer_totals"}}
+	info.Sequences = []*dbinfo.Sequence{{Schema: "public", Name: "invoice_numbers"}}
+	result := New([]byte("secret")).DBInfo(info)
+	if len(result.Views) != 0 || len(result.Sequences) != 0 || len(result.Tables) != 2 {
+		t.Errorf("Expected only the tables to be kept, got %d views, %d sequences and %d tables",
+			len(result.Views), len(result.Sequences), len(result.Tables))
+	}
+}
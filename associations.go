@@ -0,0 +1,61 @@
+package dbinfo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// nameRelationships sets the Name of the relationships of table, unique
+// among its relationships and columns so code generators can use them as
+// association names. A relationship through a single foreign key column
+// named after the role of the related row takes that role: orders belongs
+// to billing_address through billing_address_id. Other relationships take
+// the name of the related table: customers has many orders. When a table
+// is referenced several times by the same table, as addresses is by orders
+// through billing_address_id and shipping_address_id, the roles tell its
+// relationships apart: billing_address_orders and shipping_address_orders.
+// Names still taken get a numeric suffix, in the order of the relationships.
+func nameRelationships(table *Table) {
+	taken := make(map[string]bool, len(table.Columns))
+	for _, column := range table.Columns {
+		taken[column.Name] = true
+	}
+	unique := func(base string) string {
+		name := base
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken[name] = true
+		return name
+	}
+
+	for _, r := range table.BelongsTo {
+		r.Name = unique(roleName(r.Columns, r.Table))
+	}
+
+	referencing := make(map[ObjectKey]int)
+	for _, r := range table.HasMany {
+		referencing[ObjectKey{r.Schema, r.Table}]++
+	}
+	for _, r := range table.HasMany {
+		base := r.Table
+		if referencing[ObjectKey{r.Schema, r.Table}] > 1 {
+			base = roleName(r.References, r.ForeignKey) + "_" + r.Table
+		}
+		r.Name = unique(base)
+	}
+}
+
+// roleName returns the role of a foreign key with columns, the name of its
+// single column without the _id or Id suffix, or fallback
+func roleName(columns []string, fallback string) string {
+	if len(columns) != 1 {
+		return fallback
+	}
+	for _, suffix := range []string{"_id", "Id"} {
+		if role, ok := strings.CutSuffix(columns[0], suffix); ok && role != "" {
+			return role
+		}
+	}
+	return fallback
+}
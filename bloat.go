@@ -0,0 +1,262 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BloatMethod selects how GetBloatStats measures bloat
+type BloatMethod string
+
+// Bloat methods
+const (
+	// BloatEstimate compares the size of each relation with the size its
+	// rows should take, from the planner statistics. It is cheap but rough,
+	// and skips relations never analyzed and expression indexes.
+	BloatEstimate BloatMethod = "estimate"
+	// BloatPgstattuple reads every page with the pgstattuple extension. It
+	// is exact but scans the tables and indexes, so it is slow on large
+	// databases.
+	BloatPgstattuple BloatMethod = "pgstattuple"
+)
+
+// ParseBloatMethod validates a bloat method name
+func ParseBloatMethod(name string) (BloatMethod, error) {
+	switch m := BloatMethod(name); m {
+	case BloatEstimate, BloatPgstattuple:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown bloat method %q, expected estimate or pgstattuple", name)
+}
+
+// BloatStats holds the bloat of a table or of one of its B-tree indexes
+type BloatStats struct {
+	Schema string `json:"schema" yaml:"schema"`
+	Table  string `json:"table" yaml:"table"`
+	Index  string `json:"index,omitempty" yaml:"index,omitempty"` // Empty for the table itself
+	Bytes  int64  `json:"bytes" yaml:"bytes"`
+	// BloatBytes is the space not taken by live rows or index entries,
+	// beyond what the fillfactor leaves free
+	BloatBytes int64   `json:"bloatbytes" yaml:"bloatbytes"`
+	BloatRatio float64 `json:"bloatratio" yaml:"bloatratio"` // BloatBytes over Bytes, from 0 to 1
+	// DeadTupleRatio is the share of the table taken by dead tuples, from 0
+	// to 1. Zero for indexes.
+	DeadTupleRatio float64     `json:"deadtupleratio" yaml:"deadtupleratio"`
+	Method         BloatMethod `json:"method" yaml:"method"`
+}
+
+// ErrNoPgstattuple is returned by GetBloatStats with BloatPgstattuple when
+// the extension is not installed in the database
+var ErrNoPgstattuple = errors.New("the pgstattuple extension is not installed, run CREATE EXTENSION pgstattuple or use the estimate method")
+
+// GetBloatStats returns the bloat of the tables selected by opts and of
+// their B-tree indexes, sorted by schema and table, each table before its
+// indexes
+func GetBloatStats(ctx context.Context, db DBQuerier, method BloatMethod, opts ...Option) ([]*BloatStats, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	q := o.querier(db)
+
+	var stats []*BloatStats
+	var err error
+	switch method {
+	case BloatEstimate:
+		stats, err = estimateBloat(ctx, q, o)
+	case BloatPgstattuple:
+		stats, err = measureBloat(ctx, q, o)
+	default:
+		_, err = ParseBloatMethod(string(method))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(stats, func(a, b *BloatStats) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Table, b.Table), cmp.Compare(a.Index, b.Index))
+	})
+	return stats, nil
+}
+
+// bloatFilter restricts the bloat queries to the selected schemas, for
+// relations t with namespace n
+const bloatFilter = `
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])`
+
+// estimateBloat compares the sizes of the relations with the size of their
+// rows, as estimated from reltuples and the average column widths of
+// pg_stats
+func estimateBloat(ctx context.Context, q DBQuerier, o *options) ([]*BloatStats, error) {
+	query := `
+	SELECT n.nspname, t.relname, ''::text AS index_name,
+	       pg_relation_size(t.oid) AS bytes,
+	       t.reltuples::bigint AS rows,
+	       (SELECT sum(s.avg_width) FROM pg_stats s
+	        WHERE s.schemaname = n.nspname AND s.tablename = t.relname)::bigint AS width,
+	       COALESCE((SELECT option_value::int FROM pg_options_to_table(t.reloptions)
+	                 WHERE option_name = 'fillfactor'), 100) AS fillfactor,
+	       COALESCE(st.n_dead_tup::float8 / NULLIF(st.n_live_tup + st.n_dead_tup, 0), 0) AS dead_ratio
+	FROM pg_class t
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	LEFT JOIN pg_stat_user_tables st ON st.relid = t.oid
+	WHERE t.relkind = 'r'` + bloatFilter + `
+	UNION ALL
+	SELECT n.nspname, t.relname, i.relname::text,
+	       pg_relation_size(i.oid),
+	       i.reltuples::bigint,
+	       (SELECT sum(s.avg_width) FROM pg_attribute a
+	        JOIN pg_stats s ON s.schemaname = n.nspname AND s.tablename = t.relname AND s.attname = a.attname
+	        WHERE a.attrelid = t.oid AND a.attnum = ANY(x.indkey::int2[]))::bigint,
+	       COALESCE((SELECT option_value::int FROM pg_options_to_table(i.reloptions)
+	                 WHERE option_name = 'fillfactor'), 90),
+	       0
+	FROM pg_index x
+	JOIN pg_class i ON i.oid = x.indexrelid
+	JOIN pg_am am ON am.oid = i.relam
+	JOIN pg_class t ON t.oid = x.indrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	WHERE t.relkind = 'r' AND am.amname = 'btree' AND x.indexprs IS NULL` + bloatFilter
+
+	rows, err := q.Query(ctx, query, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("failed to query bloat estimates: %w", err))
+	}
+	defer rows.Close()
+
+	var stats []*BloatStats
+	for rows.Next() {
+		s := &BloatStats{Method: BloatEstimate}
+		var tuples int64
+		var width *int64
+		var fillfactor int
+		if err := rows.Scan(&s.Schema, &s.Table, &s.Index, &s.Bytes, &tuples, &width, &fillfactor, &s.DeadTupleRatio); err != nil {
+			return nil, queryError(PhaseStats, "", fmt.Errorf("failed to scan bloat estimate row: %w", err))
+		}
+		// Relations never analyzed have no row count or widths to compare with
+		if !o.includeTable(s.Schema, s.Table) || tuples < 0 || width == nil {
+			continue
+		}
+		var expected int64
+		if s.Index == "" {
+			expected = expectedTableBytes(tuples, *width, fillfactor)
+		} else {
+			expected = expectedIndexBytes(tuples, *width, fillfactor)
+		}
+		s.setBloat(s.Bytes - expected)
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("error iterating bloat estimate rows: %w", err))
+	}
+	return stats, nil
+}
+
+// pageSize is the PostgreSQL block size, as built by default
+const pageSize = 8192
+
+// expectedTableBytes estimates the size of a heap of rows rows of width
+// bytes of data: each row has a 24 byte header and a 4 byte line pointer,
+// and pages are filled to fillfactor percent after their 24 byte header
+func expectedTableBytes(rows, width int64, fillfactor int) int64 {
+	tuple := 24 + (width+7)/8*8 + 4
+	perPage := max((pageSize-24)*int64(fillfactor)/100/tuple, 1)
+	return (rows + perPage - 1) / perPage * pageSize
+}
+
+// expectedIndexBytes estimates the size of a B-tree index of rows entries
+// with keys of width bytes: each entry has an 8 byte header and a 4 byte line
+// pointer, leaf pages are filled to fillfactor percent after their header
+// and special space, and a metapage comes first
+func expectedIndexBytes(rows, width int64, fillfactor int) int64 {
+	entry := 8 + (width+7)/8*8 + 4
+	perPage := max((pageSize-40)*int64(fillfactor)/100/entry, 1)
+	return (1 + (rows+perPage-1)/perPage) * pageSize
+}
+
+// setBloat records bloat bytes of bloat, ignoring negative estimates
+func (s *BloatStats) setBloat(bloat int64) {
+	s.BloatBytes = max(bloat, 0)
+	if s.Bytes > 0 {
+		s.BloatRatio = float64(s.BloatBytes) / float64(s.Bytes)
+	}
+}
+
+// measureBloat reads the tables with pgstattuple and the B-tree indexes
+// with pgstatindex
+func measureBloat(ctx context.Context, q DBQuerier, o *options) ([]*BloatStats, error) {
+	var schema string
+	err := q.QueryRow(ctx, `
+	SELECT n.nspname FROM pg_extension e
+	JOIN pg_namespace n ON n.oid = e.extnamespace
+	WHERE e.extname = 'pgstattuple'`).Scan(&schema)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoPgstattuple
+	}
+	if err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("failed to look up pgstattuple: %w", err))
+	}
+	ext := QuoteIdent(schema)
+
+	// A table keeps the free space of its fillfactor on purpose, so only
+	// the free space beyond it is bloat. Leaf pages of B-tree indexes are
+	// 90% full when packed.
+	query := fmt.Sprintf(`
+	SELECT n.nspname, t.relname, ''::text AS index_name,
+	       s.table_len,
+	       s.table_len - s.tuple_len - s.table_len * (100 - COALESCE((SELECT option_value::int
+	           FROM pg_options_to_table(t.reloptions) WHERE option_name = 'fillfactor'), 100)) / 100 AS bloat,
+	       s.dead_tuple_percent / 100 AS dead_ratio
+	FROM pg_class t
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	CROSS JOIN LATERAL %[1]s.pgstattuple(t.oid) s
+	WHERE t.relkind = 'r'`+bloatFilter+`
+	UNION ALL
+	SELECT n.nspname, t.relname, i.relname::text,
+	       s.index_size,
+	       CASE WHEN s.avg_leaf_density = 'NaN' THEN 0
+	            ELSE (s.index_size * (1 - s.avg_leaf_density / 90))::bigint END,
+	       0
+	FROM pg_index x
+	JOIN pg_class i ON i.oid = x.indexrelid
+	JOIN pg_am am ON am.oid = i.relam
+	JOIN pg_class t ON t.oid = x.indrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	CROSS JOIN LATERAL %[1]s.pgstatindex(i.oid) s
+	WHERE t.relkind = 'r' AND am.amname = 'btree'`+bloatFilter, ext)
+
+	rows, err := q.Query(ctx, query, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("failed to query pgstattuple: %w", err))
+	}
+	defer rows.Close()
+
+	var stats []*BloatStats
+	for rows.Next() {
+		s := &BloatStats{Method: BloatPgstattuple}
+		var bloat int64
+		if err := rows.Scan(&s.Schema, &s.Table, &s.Index, &s.Bytes, &bloat, &s.DeadTupleRatio); err != nil {
+			return nil, queryError(PhaseStats, "", fmt.Errorf("failed to scan pgstattuple row: %w", err))
+		}
+		if !o.includeTable(s.Schema, s.Table) {
+			continue
+		}
+		s.setBloat(bloat)
+		if math.IsNaN(s.DeadTupleRatio) {
+			s.DeadTupleRatio = 0
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("error iterating pgstattuple rows: %w", err))
+	}
+	return stats, nil
+}
@@ -0,0 +1,134 @@
+package dbinfo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Facet is a part of the model read from the catalog
+type Facet string
+
+// Facets. Those after FacetRelationships need a recent enough server, see
+// facetVersions.
+const (
+	FacetColumns          Facet = "columns"
+	FacetPrimaryKeys      Facet = "primarykeys"
+	FacetComments         Facet = "comments"
+	FacetIndexes          Facet = "indexes"
+	FacetForeignKeys      Facet = "foreignkeys"
+	FacetViews            Facet = "views" // And materialized views
+	FacetDomains          Facet = "domains"
+	FacetForeignTables    Facet = "foreigntables"
+	FacetRules            Facet = "rules"
+	FacetEventTriggers    Facet = "eventtriggers"
+	FacetRelationships    Facet = "relationships"
+	FacetPartitions       Facet = "partitions"       // PostgreSQL 10
+	FacetIdentity         Facet = "identity"         // PostgreSQL 10
+	FacetSequences        Facet = "sequences"        // PostgreSQL 10
+	FacetNullsNotDistinct Facet = "nullsnotdistinct" // PostgreSQL 15
+)
+
+// facets lists every facet, in the order of Capabilities lists
+var facets = []Facet{
+	FacetColumns, FacetPrimaryKeys, FacetComments, FacetIndexes, FacetForeignKeys,
+	FacetViews, FacetDomains, FacetForeignTables, FacetRules, FacetEventTriggers, FacetRelationships, FacetPartitions, FacetIdentity, FacetSequences,
+	FacetNullsNotDistinct,
+}
+
+// facetVersions are the server versions, as server_version_num, adding the
+// catalog columns each facet is read from
+var facetVersions = map[Facet]int{
+	FacetPartitions:       100000,
+	FacetIdentity:         100000,
+	FacetSequences:        100000,
+	FacetNullsNotDistinct: 150000,
+}
+
+// Capabilities tells which facets of the model were read from the server.
+// Facets the server is too old for are listed as unavailable, and keep
+// their zero value in the model. Facets skipped by options are in neither
+// list.
+type Capabilities struct {
+	ServerVersion int     `json:"serverversion" yaml:"serverversion"` // server_version_num, e.g. 160002 for 16.2
+	Collected     []Facet `json:"collected" yaml:"collected"`
+	Unavailable   []Facet `json:"unavailable" yaml:"unavailable"`
+}
+
+// Supports reports whether the server provides facet
+func (c *Capabilities) Supports(facet Facet) bool {
+	return c.ServerVersion >= facetVersions[facet]
+}
+
+// serverVersionQuery reads the version of the server as a number, such as
+// 160002 for 16.2
+const serverVersionQuery = "SELECT current_setting('server_version_num')::int"
+
+// getCapabilities reads the server version and lists the facets the options
+// select, collected or unavailable
+func getCapabilities(ctx context.Context, db DBQuerier, o *options) (*Capabilities, error) {
+	c := &Capabilities{Collected: []Facet{}, Unavailable: []Facet{}}
+	if err := db.QueryRow(ctx, serverVersionQuery).Scan(&c.ServerVersion); err != nil {
+		return nil, queryError(PhaseDatabase, "", fmt.Errorf("failed to get server version: %w", err))
+	}
+
+	skipped := map[Facet]bool{
+		FacetComments:         o.skipComments,
+		FacetIndexes:          o.skipIndexes,
+		FacetNullsNotDistinct: o.skipIndexes,
+		FacetForeignKeys:      o.skipForeignKeys,
+		FacetViews:            o.skipViews,
+		FacetSequences:        o.skipSequences,
+		FacetDomains:          o.skipDomains,
+		FacetForeignTables:    o.skipForeignTables,
+		FacetRules:            o.skipRules,
+		FacetEventTriggers:    o.skipEventTriggers,
+		FacetRelationships:    o.skipForeignKeys || o.skipRelationships,
+	}
+	for _, facet := range facets {
+		switch {
+		case skipped[facet]:
+		case c.Supports(facet):
+			c.Collected = append(c.Collected, facet)
+		default:
+			c.Unavailable = append(c.Unavailable, facet)
+		}
+	}
+	return c, nil
+}
+
+// catalogQueries are the versions of the catalog queries a server runs
+type catalogQueries struct {
+	tables, columns, indexes string
+}
+
+// queries returns the catalog queries valid on the server: the catalog
+// columns of unavailable facets are replaced with constants
+func (c *Capabilities) queries() catalogQueries {
+	q := catalogQueries{tables: tablesQuery, columns: columnsQuery, indexes: indexesQuery}
+	if !c.Supports(FacetPartitions) {
+		q.tables = strings.NewReplacer(
+			"CASE WHEN pg_class.relispartition THEN pg_get_expr(pg_class.relpartbound, pg_class.oid) END", "NULL::text",
+			"AND pg_class.relispartition", "AND FALSE",
+		).Replace(q.tables)
+	}
+	if !c.Supports(FacetIdentity) {
+		q.columns = strings.Replace(q.columns, "a.attidentity::text", "''::text", 1)
+	}
+	if !c.Supports(FacetNullsNotDistinct) {
+		q.indexes = strings.Replace(q.indexes, "ix.indnullsnotdistinct", "FALSE", 1)
+	}
+	return q
+}
+
+// Clone returns a copy of the capabilities
+func (c *Capabilities) Clone() *Capabilities {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.Collected = slices.Clone(c.Collected)
+	clone.Unavailable = slices.Clone(c.Unavailable)
+	return &clone
+}
@@ -0,0 +1,238 @@
+// Package changelog builds the history of a schema from a series of
+// snapshots: each release lists the changes since the previous snapshot,
+// for audits and onboarding documentation.
+//
+// Snapshots come from a directory of timestamped snapshot files, or from
+// the git history of a snapshot file.
+package changelog
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// Snapshot is the schema at a point in its history
+type Snapshot struct {
+	Name        string    // File name without extension, tag or abbreviated commit
+	Date        time.Time // From the file name, or the commit date
+	Description string    // Commit subject, empty for files
+	Tag         string    // Tag of the commit, empty for files and untagged commits
+	Info        *dbinfo.DBInfo
+}
+
+// Release is a snapshot and the changes since the previous one
+type Release struct {
+	Name        string         `json:"name"`
+	Date        time.Time      `json:"date"`
+	Description string         `json:"description,omitempty"`
+	Changes     []*diff.Change `json:"changes"`
+}
+
+// Unreleased names the latest snapshot when Tagged keeps it without a tag
+const Unreleased = "Unreleased"
+
+// Build compares each snapshot with the previous one, in date order, and
+// returns the releases that changed the schema, newest first. The first
+// snapshot is compared with an empty schema, so its release adds every
+// table.
+func Build(snapshots []*Snapshot) []*Release {
+	sorted := slices.Clone(snapshots)
+	slices.SortStableFunc(sorted, func(a, b *Snapshot) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	var releases []*Release
+	previous := &dbinfo.DBInfo{}
+	for _, s := range sorted {
+		changes := diff.Compare(previous, s.Info)
+		previous = s.Info
+		if len(changes) == 0 {
+			continue
+		}
+		releases = append(releases, &Release{Name: s.Name, Date: s.Date, Description: s.Description, Changes: changes})
+	}
+	slices.Reverse(releases)
+	return releases
+}
+
+// Tagged keeps the tagged snapshots, named after their tag, and the latest
+// snapshot, named Unreleased when it has no tag. Releases then group the
+// changes of every commit since the previous tag.
+func Tagged(snapshots []*Snapshot) []*Snapshot {
+	var latest *Snapshot
+	for _, s := range snapshots {
+		if latest == nil || !s.Date.Before(latest.Date) {
+			latest = s
+		}
+	}
+	var tagged []*Snapshot
+	for _, s := range snapshots {
+		switch {
+		case s.Tag != "":
+			t := *s
+			t.Name, t.Description = s.Tag, ""
+			tagged = append(tagged, &t)
+		case s == latest:
+			t := *s
+			t.Name, t.Description = Unreleased, ""
+			tagged = append(tagged, &t)
+		}
+	}
+	return tagged
+}
+
+// timestampPattern matches the date, and optionally the time, that
+// snapshot file names start with, such as 2024-03-01, 20240301T120000 or
+// 2024-03-01T12:00:00Z
+var timestampPattern = regexp.MustCompile(`^(\d{4})-?(\d{2})-?(\d{2})(?:[T_ -]?(\d{2}):?(\d{2}):?(\d{2}))?`)
+
+// parseTimestamp returns the time a file name starts with, in UTC
+func parseTimestamp(name string) (time.Time, bool) {
+	m := timestampPattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	value, layout := m[1]+m[2]+m[3], "20060102"
+	if m[4] != "" {
+		value, layout = value+m[4]+m[5]+m[6], layout+"150405"
+	}
+	t, err := time.Parse(layout, value)
+	return t, err == nil
+}
+
+// FromDir reads the snapshots in dir: YAML and JSON snapshot files and
+// snapshot directories. Each is dated by the timestamp its name starts
+// with, or by its modification time when it has none.
+func FromDir(dir string) ([]*Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	var snapshots []*Snapshot
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		name := entry.Name()
+		if entry.IsDir() {
+			if _, err := os.Stat(filepath.Join(path, snapshot.IndexFile)); err != nil {
+				continue
+			}
+		} else {
+			ext := filepath.Ext(name)
+			if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+				continue
+			}
+			name = strings.TrimSuffix(name, ext)
+		}
+
+		info, err := snapshot.LoadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		date, ok := parseTimestamp(name)
+		if !ok {
+			fi, err := entry.Info()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read snapshots: %w", err)
+			}
+			date = fi.ModTime().UTC()
+		}
+		snapshots = append(snapshots, &Snapshot{Name: name, Date: date, Info: info})
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots in %s", dir)
+	}
+	// Files dated the same day keep the order of their names
+	slices.SortStableFunc(snapshots, func(a, b *Snapshot) int {
+		return cmp.Or(a.Date.Compare(b.Date), cmp.Compare(a.Name, b.Name))
+	})
+	return snapshots, nil
+}
+
+// FromGit reads every committed version of the snapshot file at path from
+// the history of the git repository holding it. Snapshots are named after
+// the abbreviated commit and dated by the commit date. Commits deleting the
+// file are skipped.
+func FromGit(ctx context.Context, path string) ([]*Snapshot, error) {
+	dir, file := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	// Fields are separated by NUL bytes, commits by the record separator
+	out, err := git(ctx, dir, "log", "--diff-filter=AM", "--format=%H%x00%h%x00%cI%x00%D%x00%s%x1e", "--", file)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	for record := range strings.SplitSeq(string(out), "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", fields[1], err)
+		}
+		data, err := git(ctx, dir, "show", fields[0]+":./"+file)
+		if err != nil {
+			return nil, err
+		}
+		info, err := snapshot.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", fields[1], err)
+		}
+		snapshots = append(snapshots, &Snapshot{
+			Name:        fields[1],
+			Date:        date.UTC(),
+			Description: fields[4],
+			Tag:         tag(fields[3]),
+			Info:        info,
+		})
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no commits of %s", path)
+	}
+	slices.Reverse(snapshots)
+	return snapshots, nil
+}
+
+// tag returns the first tag in the ref names git log prints for a commit,
+// such as "HEAD -> main, tag: v1.2.0"
+func tag(refs string) string {
+	for ref := range strings.SplitSeq(refs, ", ") {
+		if name, ok := strings.CutPrefix(ref, "tag: "); ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// git runs a git command in dir and returns its output
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package changelog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// history returns four versions of a schema: customers, orders added, the
+// same again, and the email column removed
+func history() []*dbinfo.DBInfo {
+	customers := func(columns ...*dbinfo.Column) *dbinfo.Table {
+		return &dbinfo.Table{Schema: "public", Name: "customers", Columns: append([]*dbinfo.Column{{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true}}, columns...)}
+	}
+	email := &dbinfo.Column{Name: "email", Type: "text", FullType: "text"}
+	orders := &dbinfo.Table{Schema: "public", Name: "orders", Columns: []*dbinfo.Column{{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true}}}
+	return []*dbinfo.DBInfo{
+		{Name: "shop", Tables: []*dbinfo.Table{customers(email)}},
+		{Name: "shop", Tables: []*dbinfo.Table{customers(email), orders}},
+		{Name: "shop", Tables: []*dbinfo.Table{customers(email), orders}},
+		{Name: "shop", Tables: []*dbinfo.Table{customers(), orders}},
+	}
+}
+
+func writeSnapshot(t *testing.T, path string, info *dbinfo.DBInfo) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := snapshot.WriteYAML(f, info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	for name, want := range map[string]time.Time{
+		"2024-03-01":               time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"20240301T120530-orders":   time.Date(2024, 3, 1, 12, 5, 30, 0, time.UTC),
+		"2024-03-01T12:05:30Z":     time.Date(2024, 3, 1, 12, 5, 30, 0, time.UTC),
+		"2024-03-01_120530_orders": time.Date(2024, 3, 1, 12, 5, 30, 0, time.UTC),
+	} {
+		got, ok := parseTimestamp(name)
+		if !ok || !got.Equal(want) {
+			t.Errorf("parseTimestamp(%q) = %v, %v, want %v", name, got, ok, want)
+		}
+	}
+	if _, ok := parseTimestamp("schema"); ok {
+		t.Error("parseTimestamp(\"schema\") found a timestamp")
+	}
+}
+
+func TestFromDir(t *testing.T) {
+	dir := t.TempDir()
+	infos := history()
+	// Names sort differently from dates, and other files are ignored
+	writeSnapshot(t, filepath.Join(dir, "2024-03-01-removal.yaml"), infos[3])
+	writeSnapshot(t, filepath.Join(dir, "2024-01-15-initial.yaml"), infos[0])
+	writeSnapshot(t, filepath.Join(dir, "2024-02-01-orders.yml"), infos[1])
+	writeSnapshot(t, filepath.Join(dir, "2024-02-20-unchanged.yml"), infos[2])
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Snapshots\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := FromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releases := Build(snapshots)
+
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, "shop", releases); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Changelog of shop\n" +
+		"\n## 2024-03-01-removal - 2024-03-01\n" +
+		"\n**Contains breaking changes.**\n" +
+		"\n### Removed\n\n" +
+		"- column `public.customers.email` (breaking)\n" +
+		"\n## 2024-02-01-orders - 2024-02-01\n" +
+		"\n### Added\n\n" +
+		"- table `public.orders`\n" +
+		"\n## 2024-01-15-initial - 2024-01-15\n" +
+		"\n### Added\n\n" +
+		"- table `public.customers`\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("Markdown mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteText(&buf, releases[:2]); err != nil {
+		t.Fatal(err)
+	}
+	want = "2024-03-01-removal  2024-03-01\n" +
+		"  - column public.customers.email\n" +
+		"\n2024-02-01-orders  2024-02-01\n" +
+		"  + table public.orders\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("text mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("WriteJSON(nil) = %q, want an empty array", got)
+	}
+}
+
+func TestFromGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping test: git not installed")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.yaml")
+	run := func(date string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=dbinfo", "-c", "user.email=dbinfo@example.com"}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("2024-01-15T10:00:00Z", "init", "-q")
+	for i, info := range history() {
+		date := time.Date(2024, 1, 15+i, 10, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		writeSnapshot(t, path, info)
+		run(date, "add", "schema.yaml")
+		run(date, "commit", "-q", "--allow-empty", "-m", "Version "+string(rune('1'+i)))
+		if i == 1 {
+			run(date, "tag", "v1.0.0")
+		}
+	}
+
+	snapshots, err := FromGit(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The third commit leaves the file unchanged
+	if len(snapshots) != 3 {
+		t.Fatalf("Expected 3 snapshots, got %d", len(snapshots))
+	}
+
+	var names []string
+	for _, r := range Build(snapshots) {
+		names = append(names, r.Description+" "+r.Date.Format(dateLayout))
+	}
+	if diff := cmp.Diff([]string{"Version 4 2024-01-18", "Version 2 2024-01-16", "Version 1 2024-01-15"}, names); diff != "" {
+		t.Errorf("releases mismatch (-want +got):\n%s", diff)
+	}
+
+	names = nil
+	for _, r := range Build(Tagged(snapshots)) {
+		names = append(names, r.Name)
+	}
+	if diff := cmp.Diff([]string{Unreleased, "v1.0.0"}, names); diff != "" {
+		t.Errorf("tagged releases mismatch (-want +got):\n%s", diff)
+	}
+}
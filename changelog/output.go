@@ -0,0 +1,108 @@
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo/diff"
+)
+
+// dateLayout formats release dates
+const dateLayout = "2006-01-02"
+
+// WriteText writes each release to w as a heading line followed by its
+// changes, one per line
+func WriteText(w io.Writer, releases []*Release) error {
+	for i, r := range releases {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s  %s", r.Name, r.Date.Format(dateLayout))
+		if r.Description != "" {
+			fmt.Fprintf(w, "  %s", r.Description)
+		}
+		fmt.Fprintln(w)
+		for _, change := range r.Changes {
+			if _, err := fmt.Fprintf(w, "  %s\n", change); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the releases to w as a JSON array
+func WriteJSON(w io.Writer, releases []*Release) error {
+	if releases == nil {
+		releases = []*Release{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(releases)
+}
+
+// sections are the headings of the changes of a release in Markdown, in
+// the order of Keep a Changelog
+var sections = []struct {
+	heading string
+	typ     diff.ChangeType
+}{
+	{"Added", diff.Added},
+	{"Changed", diff.Modified},
+	{"Removed", diff.Removed},
+}
+
+// WriteMarkdown writes the changelog of the schema name to w, a section per
+// release with its changes grouped as added, changed and removed
+func WriteMarkdown(w io.Writer, name string, releases []*Release) error {
+	fmt.Fprintf(w, "# Changelog of %s\n", name)
+	if len(releases) == 0 {
+		_, err := fmt.Fprintln(w, "\nThe schema has no history.")
+		return err
+	}
+	for _, r := range releases {
+		fmt.Fprintf(w, "\n## %s - %s\n", r.Name, r.Date.Format(dateLayout))
+		if r.Description != "" {
+			fmt.Fprintf(w, "\n%s\n", r.Description)
+		}
+		if diff.HasBreaking(r.Changes) {
+			fmt.Fprintln(w, "\n**Contains breaking changes.**")
+		}
+		for _, section := range sections {
+			var lines []string
+			for _, c := range r.Changes {
+				if c.Type == section.typ {
+					lines = append(lines, markdownChange(c))
+				}
+			}
+			if len(lines) == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "\n### %s\n\n", section.heading)
+			for _, line := range lines {
+				fmt.Fprintf(w, "- %s\n", line)
+			}
+		}
+	}
+	return nil
+}
+
+// markdownChange describes a change in a list item of its section
+func markdownChange(c *diff.Change) string {
+	line := fmt.Sprintf("%s `%s`", c.Kind, c.Path())
+	if c.Type == diff.Modified {
+		line += fmt.Sprintf(": %s `%s` → `%s`", c.Field, display(c.From), display(c.To))
+	}
+	if c.Breaking {
+		line += " (breaking)"
+	}
+	return line
+}
+
+func display(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
@@ -0,0 +1,218 @@
+package dbinfo
+
+import (
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the model. GetDBInfo builds a new model on
+// every call, which the caller is free to modify; services sharing one model
+// across goroutines should treat it as read only and post-process a clone.
+func (d *DBInfo) Clone() *DBInfo {
+	if d == nil {
+		return nil
+	}
+	clone := *d
+	clone.Tables = cloneAll(d.Tables, (*Table).Clone)
+	clone.Views = cloneAll(d.Views, (*View).Clone)
+	clone.MaterializedViews = cloneAll(d.MaterializedViews, (*MaterializedView).Clone)
+	clone.Sequences = cloneAll(d.Sequences, (*Sequence).Clone)
+	clone.Domains = cloneAll(d.Domains, (*Domain).Clone)
+	clone.ForeignTables = cloneAll(d.ForeignTables, (*ForeignTable).Clone)
+	clone.EventTriggers = cloneAll(d.EventTriggers, (*EventTrigger).Clone)
+	clone.Warnings = cloneAll(d.Warnings, func(w *Warning) *Warning {
+		c := *w
+		return &c
+	})
+	clone.Capabilities = d.Capabilities.Clone()
+	if d.Signature != nil {
+		signature := *d.Signature
+		clone.Signature = &signature
+	}
+	return &clone
+}
+
+// Clone returns a deep copy of the table
+func (t *Table) Clone() *Table {
+	if t == nil {
+		return nil
+	}
+	clone := *t
+	clone.Tags = slices.Clone(t.Tags)
+	clone.Columns = cloneAll(t.Columns, (*Column).Clone)
+	if t.PrimaryKey != nil {
+		clone.PrimaryKey = &PrimaryKey{Name: t.PrimaryKey.Name, Columns: slices.Clone(t.PrimaryKey.Columns)}
+	}
+	clone.Indexes = cloneAll(t.Indexes, (*Index).Clone)
+	clone.ForeignKeys = cloneAll(t.ForeignKeys, (*ForeignKey).Clone)
+	clone.Partitions = cloneAll(t.Partitions, (*Partition).Clone)
+	clone.HasMany = cloneAll(t.HasMany, (*Relationship).Clone)
+	clone.BelongsTo = cloneAll(t.BelongsTo, (*Relationship).Clone)
+	if t.Workload != nil {
+		workload := *t.Workload
+		clone.Workload = &workload
+	}
+	if t.Statistics != nil {
+		statistics := *t.Statistics
+		clone.Statistics = &statistics
+	}
+	clone.Grants = cloneGrants(t.Grants)
+	clone.Rules = cloneRules(t.Rules)
+	return &clone
+}
+
+// Clone returns a deep copy of the view
+func (v *View) Clone() *View {
+	if v == nil {
+		return nil
+	}
+	clone := *v
+	clone.Columns = cloneAll(v.Columns, (*Column).Clone)
+	clone.Sources = cloneSources(v.Sources)
+	clone.Grants = cloneGrants(v.Grants)
+	clone.Rules = cloneRules(v.Rules)
+	return &clone
+}
+
+// Clone returns a deep copy of the materialized view
+func (v *MaterializedView) Clone() *MaterializedView {
+	if v == nil {
+		return nil
+	}
+	clone := *v
+	clone.Columns = cloneAll(v.Columns, (*Column).Clone)
+	clone.Indexes = cloneAll(v.Indexes, (*Index).Clone)
+	clone.Sources = cloneSources(v.Sources)
+	clone.Grants = cloneGrants(v.Grants)
+	return &clone
+}
+
+// Clone returns a deep copy of the column
+func (c *Column) Clone() *Column {
+	clone := *c
+	clone.Tags = slices.Clone(c.Tags)
+	clone.Grants = cloneGrants(c.Grants)
+	if c.Domain != nil {
+		domain := *c.Domain
+		clone.Domain = &domain
+	}
+	return &clone
+}
+
+// Clone returns a deep copy of the sequence
+func (s *Sequence) Clone() *Sequence {
+	clone := *s
+	if s.LastValue != nil {
+		lastValue := *s.LastValue
+		clone.LastValue = &lastValue
+	}
+	if s.OwnedBy != nil {
+		owner := *s.OwnedBy
+		clone.OwnedBy = &owner
+	}
+	return &clone
+}
+
+// Clone returns a deep copy of the domain
+func (d *Domain) Clone() *Domain {
+	clone := *d
+	clone.Checks = cloneAll(d.Checks, func(c *DomainCheck) *DomainCheck {
+		check := *c
+		return &check
+	})
+	return &clone
+}
+
+// Clone returns a deep copy of the foreign table
+func (t *ForeignTable) Clone() *ForeignTable {
+	clone := *t
+	clone.Options = maps.Clone(t.Options)
+	clone.Columns = cloneAll(t.Columns, (*Column).Clone)
+	if t.ColumnOptions != nil {
+		clone.ColumnOptions = make(map[string]map[string]string, len(t.ColumnOptions))
+		for column, options := range t.ColumnOptions {
+			clone.ColumnOptions[column] = maps.Clone(options)
+		}
+	}
+	clone.Grants = cloneGrants(t.Grants)
+	return &clone
+}
+
+// Clone returns a deep copy of the event trigger
+func (t *EventTrigger) Clone() *EventTrigger {
+	clone := *t
+	clone.Tags = slices.Clone(t.Tags)
+	return &clone
+}
+
+// cloneRules clones the rules of a table or view
+func cloneRules(rules []*Rule) []*Rule {
+	return cloneAll(rules, func(r *Rule) *Rule {
+		c := *r
+		return &c
+	})
+}
+
+// cloneGrants clones the grants of a table, view or column
+func cloneGrants(grants []*Grant) []*Grant {
+	return cloneAll(grants, func(g *Grant) *Grant {
+		c := *g
+		return &c
+	})
+}
+
+// cloneSources clones the sources of a view
+func cloneSources(sources []*ViewSource) []*ViewSource {
+	return cloneAll(sources, func(s *ViewSource) *ViewSource {
+		c := *s
+		c.Columns = slices.Clone(s.Columns)
+		return &c
+	})
+}
+
+// Clone returns a deep copy of the index
+func (i *Index) Clone() *Index {
+	clone := *i
+	clone.Columns = slices.Clone(i.Columns)
+	clone.Keys = cloneAll(i.Keys, func(k *IndexKey) *IndexKey {
+		key := *k
+		return &key
+	})
+	return &clone
+}
+
+// Clone returns a deep copy of the foreign key
+func (fk *ForeignKey) Clone() *ForeignKey {
+	clone := *fk
+	clone.ColumnNames = slices.Clone(fk.ColumnNames)
+	clone.RefColumnNames = slices.Clone(fk.RefColumnNames)
+	return &clone
+}
+
+// Clone returns a deep copy of the relationship
+func (r *Relationship) Clone() *Relationship {
+	clone := *r
+	clone.Columns = slices.Clone(r.Columns)
+	clone.References = slices.Clone(r.References)
+	return &clone
+}
+
+// Clone returns a deep copy of the partition and its own partitions
+func (p *Partition) Clone() *Partition {
+	clone := *p
+	clone.Partitions = cloneAll(p.Partitions, (*Partition).Clone)
+	return &clone
+}
+
+// cloneAll clones every element of values, keeping nil and empty slices
+// apart as they encode differently
+func cloneAll[T any](values []*T, clone func(*T) *T) []*T {
+	if values == nil {
+		return nil
+	}
+	result := make([]*T, len(values))
+	for i, value := range values {
+		result[i] = clone(value)
+	}
+	return result
+}
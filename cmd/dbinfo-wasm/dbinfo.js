@@ -0,0 +1,46 @@
+// ES module loading dbinfo.wasm, built by 'make wasm'. wasm_exec.js, shipped
+// with Go, must be served next to it.
+//
+//   import { init } from "./dbinfo.js";
+//   const dbinfo = await init();
+//   const changes = JSON.parse(dbinfo.diff(before, after));
+//   const svg = dbinfo.render(snapshot, "svg", { cluster: "schema" });
+//
+// Functions take snapshots as YAML or JSON text and throw an Error on failure.
+
+import "./wasm_exec.js";
+
+let ready;
+
+// init loads the WebAssembly module once, from url, which defaults to
+// dbinfo.wasm next to this file, and returns the dbinfo functions
+export function init(url = new URL("dbinfo.wasm", import.meta.url)) {
+  ready ??= (async () => {
+    const go = new Go();
+    const source = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+    // run only settles when the program exits, which it doesn't
+    go.run(source.instance);
+    return wrap(globalThis.dbinfo);
+  })();
+  return ready;
+}
+
+function wrap(exported) {
+  const call = (name, ...args) => {
+    const result = exported[name](...args);
+    if (result.error !== undefined) {
+      throw new Error(result.error);
+    }
+    return result.value;
+  };
+  return {
+    // load parses a snapshot and returns it as JSON text
+    load: (snapshot) => call("load", snapshot),
+    // diff compares two snapshots and returns the changes as JSON text
+    diff: (from, to) => call("diff", from, to),
+    // render draws a snapshot as "mermaid" (the default), "dot" or "svg".
+    // options are {cluster, direction, collapseLookups}, as in 'dbinfo erd'.
+    render: (snapshot, format = "mermaid", options = {}) =>
+      call("render", snapshot, format, JSON.stringify(options)),
+  };
+}
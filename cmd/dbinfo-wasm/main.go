@@ -0,0 +1,53 @@
+//go:build js && wasm
+
+// Command dbinfo-wasm exports the offline functions of dbinfo to
+// JavaScript when built to WebAssembly:
+//
+//	GOOS=js GOARCH=wasm go build -o dbinfo.wasm ./cmd/dbinfo-wasm
+//
+// It defines a global dbinfo object whose load, diff and render functions
+// return {value} on success and {error} on failure. dbinfo.js wraps them in
+// an ES module throwing errors instead.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/guillermo/dbinfo/internal/wasmapi"
+)
+
+func main() {
+	js.Global().Set("dbinfo", js.ValueOf(map[string]any{
+		"load": export(func(args []js.Value) (string, error) {
+			return wasmapi.Load(arg(args, 0))
+		}),
+		"diff": export(func(args []js.Value) (string, error) {
+			return wasmapi.Diff(arg(args, 0), arg(args, 1))
+		}),
+		"render": export(func(args []js.Value) (string, error) {
+			return wasmapi.Render(arg(args, 0), arg(args, 1), arg(args, 2))
+		}),
+	}))
+	// Keep the functions available to JavaScript
+	select {}
+}
+
+// export wraps fn as a JavaScript function returning {value} or {error}
+func export(fn func(args []js.Value) (string, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) any {
+		value, err := fn(args)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return map[string]any{"value": value}
+	})
+}
+
+// arg returns the string argument at index i, or "" when it is missing or
+// not a string
+func arg(args []js.Value, i int) string {
+	if i >= len(args) || args[i].Type() != js.TypeString {
+		return ""
+	}
+	return args[i].String()
+}
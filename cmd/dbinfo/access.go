@@ -0,0 +1,77 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/access"
+)
+
+// runAccess implements 'dbinfo access', printing which roles hold which
+// privileges on every table of a database or of a snapshot taken with
+// -privileges, for access reviews
+func runAccess(ctx context.Context, args []string) error {
+	fs := newFlagSet("access", "[flags] [connection_string]\n       dbinfo access -snapshot <snapshot> [flags]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, csv, html (a standalone access review page) or json")
+	var roles stringList
+	fs.Var(&roles, "role", "only report roles matching this glob, PUBLIC for the grants to every role (repeatable)")
+	byRole := fs.Bool("by-role", false, "group the rows by role instead of by table")
+	snapshotPath := fs.String("snapshot", "", "review this YAML or JSON snapshot, or snapshot directory, taken with -privileges, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, string, *access.Matrix) error
+	switch *formatName {
+	case "text":
+		write = func(w io.Writer, _ string, m *access.Matrix) error { return access.WriteText(w, m) }
+	case "csv":
+		write = func(w io.Writer, _ string, m *access.Matrix) error { return access.WriteCSV(w, m) }
+	case "html":
+		write = access.WriteHTML
+	case "json":
+		write = func(w io.Writer, _ string, m *access.Matrix) error { return access.WriteJSON(w, m) }
+	default:
+		return fmt.Errorf("unknown access format %q", *formatName)
+	}
+
+	var name string
+	var privileges []*dbinfo.TablePrivilege
+	if *snapshotPath != "" {
+		info, err := loadSnapshot(ctx, *snapshotPath)
+		if err != nil {
+			return err
+		}
+		privileges = info.TablePrivileges()
+		if len(privileges) == 0 {
+			return fmt.Errorf("snapshot %s records no privileges, take it with -privileges", *snapshotPath)
+		}
+		name = info.Name
+	} else {
+		pool, err := conn.connect(ctx, fs)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+
+		ctx, cancel := conn.withTimeout(ctx)
+		defer cancel()
+		if privileges, err = dbinfo.GetTablePrivileges(ctx, pool, conn.options()...); err != nil {
+			return conn.introspectionError(err)
+		}
+		config := pool.Config()
+		name = cmp.Or(config.ConnConfig.Database, config.ConnConfig.User)
+	}
+
+	m := access.Build(privileges, access.Options{Roles: roles, ByRole: *byRole})
+	return out.write(func(w io.Writer) error {
+		return write(w, name, m)
+	})
+}
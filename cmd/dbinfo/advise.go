@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/advisor"
+)
+
+// runAdvise implements 'dbinfo advise', recommending indexes to create and
+// to drop, prioritized by the table and index statistics
+func runAdvise(ctx context.Context, args []string) error {
+	fs := newFlagSet("advise", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or sql (statements applying the recommendations)")
+	snapshotPath := fs.String("snapshot", "", "advise on this YAML or JSON snapshot, or snapshot directory, instead of a database; sizes are unknown (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, []*advisor.Recommendation) error
+	switch *formatName {
+	case "text":
+		write = advisor.WriteText
+	case "json":
+		write = advisor.WriteJSON
+	case "sql":
+		write = advisor.WriteSQL
+	default:
+		return fmt.Errorf("unknown advise format %q", *formatName)
+	}
+
+	var info *dbinfo.DBInfo
+	var tables []*dbinfo.TableStats
+	var indexes []*dbinfo.IndexStats
+	if *snapshotPath != "" {
+		var err error
+		if info, err = loadSnapshot(ctx, *snapshotPath); err != nil {
+			return err
+		}
+	} else {
+		pool, err := conn.connect(ctx, fs)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		if info, err = conn.introspect(ctx, pool); err != nil {
+			return err
+		}
+
+		ctx, cancel := conn.withTimeout(ctx)
+		defer cancel()
+		if tables, err = dbinfo.GetTableStats(ctx, pool, conn.options()...); err != nil {
+			return conn.introspectionError(err)
+		}
+		if indexes, err = dbinfo.GetIndexStats(ctx, pool, conn.options()...); err != nil {
+			return conn.introspectionError(err)
+		}
+	}
+
+	recs := advisor.Advise(info, tables, indexes)
+	return out.write(func(w io.Writer) error {
+		return write(w, recs)
+	})
+}
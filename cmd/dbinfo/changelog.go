@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo/changelog"
+)
+
+// runChangelog implements 'dbinfo changelog', printing the history of a
+// schema from a directory of snapshots or the git history of a snapshot
+func runChangelog(ctx context.Context, args []string) error {
+	fs := newFlagSet("changelog", "[flags] <snapshot_directory>\n       dbinfo changelog -git [flags] <snapshot_file>")
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "markdown", "output format: markdown, text or json")
+	fromGit := fs.Bool("git", false, "read the versions of a snapshot file from its git history instead of a directory of snapshots")
+	releases := fs.String("releases", "all", "with -git, a release per commit (all) or per tag (tags), with the changes since the last tag as Unreleased")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		if *fromGit {
+			return errors.New("changelog -git needs a snapshot file")
+		}
+		return errors.New("changelog needs a snapshot directory")
+	}
+	if *releases != "all" && *releases != "tags" {
+		return fmt.Errorf("unknown -releases value %q, expected all or tags", *releases)
+	}
+	if *releases == "tags" && !*fromGit {
+		return errors.New("-releases tags needs -git")
+	}
+
+	var write func(io.Writer, string, []*changelog.Release) error
+	switch *formatName {
+	case "markdown":
+		write = changelog.WriteMarkdown
+	case "text":
+		write = func(w io.Writer, name string, releases []*changelog.Release) error {
+			return changelog.WriteText(w, releases)
+		}
+	case "json":
+		write = func(w io.Writer, name string, releases []*changelog.Release) error {
+			return changelog.WriteJSON(w, releases)
+		}
+	default:
+		return fmt.Errorf("unknown changelog format %q", *formatName)
+	}
+
+	var snapshots []*changelog.Snapshot
+	var err error
+	if *fromGit {
+		snapshots, err = changelog.FromGit(ctx, fs.Arg(0))
+	} else {
+		snapshots, err = changelog.FromDir(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	name := snapshots[len(snapshots)-1].Info.Name
+	if *releases == "tags" {
+		snapshots = changelog.Tagged(snapshots)
+	}
+
+	history := changelog.Build(snapshots)
+	return out.write(func(w io.Writer) error {
+		return write(w, name, history)
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/compliance"
+)
+
+// runCompliance implements 'dbinfo compliance', verifying a database or a
+// snapshot against a manifest of required tables and columns. Like 'dbinfo
+// lint', it exits with status 1 when a requirement fails and 2 when the
+// check itself fails.
+func runCompliance(ctx context.Context, args []string) error {
+	failed, err := checkCompliance(ctx, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: lintError, err: err}
+	}
+	if failed {
+		return &exitError{code: lintFindings}
+	}
+	return nil
+}
+
+// checkCompliance prints the results of the manifest requirements and
+// reports whether any failed
+func checkCompliance(ctx context.Context, args []string) (bool, error) {
+	fs := newFlagSet("compliance", "-manifest file [flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	manifestPath := fs.String("manifest", "", "YAML, JSON or CSV file listing the required tables and columns (required)")
+	formatName := fs.String("format", "text", "output format: text, json or markdown (an audit report)")
+	snapshotPath := fs.String("snapshot", "", "check this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	var write func(io.Writer, *dbinfo.DBInfo, []*compliance.Result) error
+	switch *formatName {
+	case "text":
+		write = func(w io.Writer, info *dbinfo.DBInfo, results []*compliance.Result) error {
+			return compliance.WriteText(w, results)
+		}
+	case "json":
+		write = func(w io.Writer, info *dbinfo.DBInfo, results []*compliance.Result) error {
+			return compliance.WriteJSON(w, results)
+		}
+	case "markdown":
+		write = func(w io.Writer, info *dbinfo.DBInfo, results []*compliance.Result) error {
+			return compliance.WriteMarkdown(w, info.Name, results)
+		}
+	default:
+		return false, fmt.Errorf("unknown compliance format %q", *formatName)
+	}
+
+	if *manifestPath == "" {
+		return false, errors.New("-manifest is required")
+	}
+	manifest, err := compliance.LoadFile(*manifestPath)
+	if err != nil {
+		return false, err
+	}
+
+	var info *dbinfo.DBInfo
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	results := compliance.Check(manifest, info)
+	if err := out.write(func(w io.Writer) error {
+		return write(w, info, results)
+	}); err != nil {
+		return false, err
+	}
+	return !compliance.Passed(results), nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/lint"
+	"github.com/guillermo/dbinfo/pii"
+	"gopkg.in/yaml.v3"
+)
+
+// projectConfigFile is the project configuration read from the working
+// directory, unless DBINFO_CONFIG points somewhere else
+const projectConfigFile = ".dbinfo.yaml"
+
+// projectConfig holds settings shared by a team. Flags always take
+// precedence over it.
+type projectConfig struct {
+	// Connections maps aliases to connection strings. Aliases can be used
+	// anywhere a connection string is expected.
+	Connections map[string]string `yaml:"connections"`
+	// Connection is the alias or connection string used when none is given
+	Connection string `yaml:"connection"`
+	// PasswordCmd prints the database password, see -password-cmd
+	PasswordCmd    string       `yaml:"password-cmd"`
+	Schemas        []string     `yaml:"schemas"`
+	ExcludeSchemas []string     `yaml:"exclude-schemas"`
+	Tables         []string     `yaml:"tables"`
+	ExcludeTables  []string     `yaml:"exclude-tables"`
+	Format         string       `yaml:"format"` // Default format of 'dbinfo dump'
+	Lint           *lint.Config `yaml:"lint"`
+	// Types renames column types, matched on the type or the formatted type
+	Types map[string]string `yaml:"types"`
+	// RollUpPartitions lists partitions under their parent, see -roll-up-partitions
+	RollUpPartitions bool `yaml:"roll-up-partitions"`
+	// Tags group tables in diagrams, see 'dbinfo erd -cluster tag'
+	Tags tableTags `yaml:"tags"`
+	// PII configures the detection of 'dbinfo pii' and 'dbinfo dump -tag-pii'
+	PII *pii.Config `yaml:"pii"`
+	// Glossary is the business glossary file of 'dbinfo dump', see -glossary
+	Glossary string `yaml:"glossary"`
+}
+
+// tableTags maps tag names to table patterns, keeping the order of the file
+// since tables go to their first matching tag
+type tableTags []tableTag
+
+type tableTag struct {
+	name     string
+	patterns []string
+}
+
+func (t *tableTags) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: tags must map tag names to table patterns", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		tag := tableTag{name: node.Content[i].Value}
+		if err := node.Content[i+1].Decode(&tag.patterns); err != nil {
+			return fmt.Errorf("tag %s: %w", tag.name, err)
+		}
+		*t = append(*t, tag)
+	}
+	return nil
+}
+
+// project is the loaded project configuration, empty without a file
+var project = &projectConfig{}
+
+// loadProjectConfig reads the project configuration file, if there is one
+func loadProjectConfig() (*projectConfig, error) {
+	path := os.Getenv("DBINFO_CONFIG")
+	explicit := path != ""
+	if !explicit {
+		path = projectConfigFile
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &projectConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	cfg := &projectConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cfg.Lint != nil {
+		if err := cfg.Lint.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if cfg.PII != nil {
+		if _, err := pii.New(cfg.PII); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// resolve returns the connection string of an alias, or dsn itself when it
+// is not an alias
+func (p *projectConfig) resolve(dsn string) string {
+	if resolved, ok := p.Connections[dsn]; ok {
+		return resolved
+	}
+	return dsn
+}
+
+// format returns the configured dump format, or def
+func (p *projectConfig) format(def string) string {
+	if p.Format != "" {
+		return p.Format
+	}
+	return def
+}
+
+// mapTypes applies the type overrides to the columns of table
+func (p *projectConfig) mapTypes(table *dbinfo.Table) {
+	if len(p.Types) == 0 {
+		return
+	}
+	for _, column := range table.Columns {
+		if mapped, ok := p.Types[column.FullType]; ok && column.FullType != "" {
+			column.Type, column.FullType = mapped, mapped
+		} else if mapped, ok := p.Types[column.Type]; ok {
+			column.Type, column.FullType = mapped, mapped
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// connectionFlags holds the flags shared by every command that connects to a
+// database, including the ones selecting what to introspect
+type connectionFlags struct {
+	dsn              string
+	schemas          stringList
+	excludeSchemas   stringList
+	tables           stringList
+	excludeTables    stringList
+	timeout          time.Duration
+	statementTimeout time.Duration
+	rollUpPartitions bool
+	workload         bool
+	privileges       bool
+	statistics       bool
+	passwordCmd      string
+	password         string // Output of passwordCmd, once it has run
+	log              logFlags
+
+	// Projection flags, only registered by registerProjection
+	noRelationships bool
+	noIndexes       bool
+	noComments      bool
+	noViews         bool
+	noSequences     bool
+	noDomains       bool
+	noForeignTables bool
+	noEventTriggers bool
+	noRules         bool
+	columnsOnly     bool
+}
+
+// register adds the connection flags to fs
+func (c *connectionFlags) register(fs *flag.FlagSet) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		dsn = project.Connection
+	}
+	fs.StringVar(&c.dsn, "dsn", dsn, "connection string or alias (defaults to DATABASE_URL, then to the connection in "+projectConfigFile+")")
+	fs.Var(&c.schemas, "schema", "only introspect this schema (repeatable)")
+	fs.Var(&c.excludeSchemas, "exclude-schema", "skip this schema (repeatable)")
+	fs.Var(&c.tables, "table", "only introspect tables matching this glob, as table or schema.table (repeatable)")
+	fs.Var(&c.excludeTables, "exclude-table", "skip tables matching this glob, as table or schema.table (repeatable)")
+	fs.BoolVar(&c.rollUpPartitions, "roll-up-partitions", project.RollUpPartitions, "list partitions under their parent table instead of as tables")
+	fs.BoolVar(&c.workload, "workload", false, "annotate tables with their reads and writes from the statistics collector and pg_stat_statements, flagging hot tables and likely missing indexes")
+	fs.BoolVar(&c.statistics, "statistics", false, "record the estimated row count and the table, index, TOAST and total sizes of every table")
+	fs.BoolVar(&c.privileges, "privileges", false, "record the owner of every table and view and the privileges granted on them and their columns")
+	fs.StringVar(&c.passwordCmd, "password-cmd", project.PasswordCmd, "run this shell command and use its output as the password")
+	fs.DurationVar(&c.timeout, "timeout", 0, "give up introspecting a database after this long (0 for no limit)")
+	fs.DurationVar(&c.statementTimeout, "statement-timeout", 0, "cancel any catalog query running longer than this (0 for no limit)")
+	c.log.register(fs)
+}
+
+// registerProjection adds the flags leaving parts of the schema out, for
+// commands where a partial schema is still meaningful
+func (c *connectionFlags) registerProjection(fs *flag.FlagSet) {
+	fs.BoolVar(&c.noRelationships, "no-relationships", false, "don't build the hasmany and belongsto relationships")
+	fs.BoolVar(&c.noIndexes, "no-indexes", false, "don't introspect indexes")
+	fs.BoolVar(&c.noComments, "no-comments", false, "don't read table and column comments")
+	fs.BoolVar(&c.noViews, "no-views", false, "don't introspect views and materialized views")
+	fs.BoolVar(&c.noSequences, "no-sequences", false, "don't introspect sequences")
+	fs.BoolVar(&c.noDomains, "no-domains", false, "don't introspect domains")
+	fs.BoolVar(&c.noForeignTables, "no-foreign-tables", false, "don't introspect foreign tables")
+	fs.BoolVar(&c.noRules, "no-rules", false, "don't introspect rewrite rules")
+	fs.BoolVar(&c.noEventTriggers, "no-event-triggers", false, "don't introspect event triggers")
+	fs.BoolVar(&c.columnsOnly, "columns-only", false, "only introspect tables and their columns")
+}
+
+// options returns the introspection options selected by the flags, falling
+// back to the project configuration for the flags that are not set
+func (c *connectionFlags) options() []dbinfo.Option {
+	var opts []dbinfo.Option
+	if schemas := orDefault(c.schemas, project.Schemas); len(schemas) > 0 {
+		opts = append(opts, dbinfo.WithSchemas(schemas...))
+	}
+	if schemas := orDefault(c.excludeSchemas, project.ExcludeSchemas); len(schemas) > 0 {
+		opts = append(opts, dbinfo.WithExcludedSchemas(schemas...))
+	}
+	if tables := orDefault(c.tables, project.Tables); len(tables) > 0 {
+		opts = append(opts, dbinfo.WithTables(tables...))
+	}
+	if tables := orDefault(c.excludeTables, project.ExcludeTables); len(tables) > 0 {
+		opts = append(opts, dbinfo.WithExcludedTables(tables...))
+	}
+	if c.statementTimeout > 0 {
+		opts = append(opts, dbinfo.WithStatementTimeout(c.statementTimeout))
+	}
+	if c.rollUpPartitions {
+		opts = append(opts, dbinfo.WithRolledUpPartitions())
+	}
+	if c.workload {
+		opts = append(opts, dbinfo.WithWorkload())
+	}
+	if c.privileges {
+		opts = append(opts, dbinfo.WithPrivileges())
+	}
+	if c.statistics {
+		opts = append(opts, dbinfo.WithStatistics())
+	}
+	if c.noRelationships {
+		opts = append(opts, dbinfo.WithoutRelationships())
+	}
+	if c.noIndexes {
+		opts = append(opts, dbinfo.WithoutIndexes())
+	}
+	if c.noComments {
+		opts = append(opts, dbinfo.WithoutComments())
+	}
+	if c.noViews {
+		opts = append(opts, dbinfo.WithoutViews())
+	}
+	if c.noSequences {
+		opts = append(opts, dbinfo.WithoutSequences())
+	}
+	if c.noDomains {
+		opts = append(opts, dbinfo.WithoutDomains())
+	}
+	if c.noForeignTables {
+		opts = append(opts, dbinfo.WithoutForeignTables())
+	}
+	if c.noRules {
+		opts = append(opts, dbinfo.WithoutRules())
+	}
+	if c.noEventTriggers {
+		opts = append(opts, dbinfo.WithoutEventTriggers())
+	}
+	if c.columnsOnly {
+		opts = append(opts, dbinfo.WithColumnsOnly())
+	}
+	opts = append(opts, dbinfo.WithLogger(c.log.logger()))
+	return opts
+}
+
+// withTimeout bounds an introspection by the -timeout flag. The caller must
+// call the returned cancel function.
+func (c *connectionFlags) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// introspectionError describes err, explaining which limit was hit when the
+// introspection timed out
+func (c *connectionFlags) introspectionError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		switch {
+		case c.timeout > 0 && c.statementTimeout > 0:
+			return fmt.Errorf("getting database info: timed out (-timeout %s, -statement-timeout %s): %w", c.timeout, c.statementTimeout, err)
+		case c.timeout > 0:
+			return fmt.Errorf("getting database info: timed out after %s: %w", c.timeout, err)
+		case c.statementTimeout > 0:
+			return fmt.Errorf("getting database info: a query ran longer than %s: %w", c.statementTimeout, err)
+		}
+	}
+	return fmt.Errorf("getting database info: %w", err)
+}
+
+// orDefault returns values, or def when the flag was not set
+func orDefault(values stringList, def []string) []string {
+	if len(values) > 0 {
+		return values
+	}
+	return def
+}
+
+// connectionString returns the connection string from the first positional
+// argument, the -dsn flag or the DATABASE_URL environment variable
+func (c *connectionFlags) connectionString(fs *flag.FlagSet) (string, error) {
+	dsn := c.dsn
+	if fs.NArg() > 0 {
+		dsn = fs.Arg(0)
+	}
+	if dsn == "" {
+		return "", errors.New("no database connection string provided, pass it as an argument or set DATABASE_URL")
+	}
+	return dsn, nil
+}
+
+// connect opens a connection pool to the database selected by the flags.
+// The caller is responsible for closing the pool.
+func (c *connectionFlags) connect(ctx context.Context, fs *flag.FlagSet) (*pgxpool.Pool, error) {
+	dsn, err := c.connectionString(fs)
+	if err != nil {
+		return nil, err
+	}
+	return c.open(ctx, dsn)
+}
+
+// open opens a connection pool to dsn, which can be an alias from the
+// project configuration. The caller is responsible for closing the pool.
+func (c *connectionFlags) open(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	return c.openDatabase(ctx, dsn, "")
+}
+
+// openDatabase opens a connection pool to dsn, switching to database when it
+// is set. The caller is responsible for closing the pool.
+func (c *connectionFlags) openDatabase(ctx context.Context, dsn, database string) (*pgxpool.Pool, error) {
+	config, err := c.poolConfig(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if database != "" {
+		config.ConnConfig.Database = database
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		if database != "" {
+			return nil, fmt.Errorf("connecting to database %s: %w", database, err)
+		}
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return pool, nil
+}
+
+// poolConfig parses dsn after resolving project aliases and expanding
+// ${VAR} references, and sets the password printed by -password-cmd
+func (c *connectionFlags) poolConfig(ctx context.Context, dsn string) (*pgxpool.Config, error) {
+	connString, err := expandEnv(project.resolve(dsn))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	if c.passwordCmd != "" {
+		if c.password == "" {
+			if c.password, err = runPasswordCmd(ctx, c.passwordCmd); err != nil {
+				return nil, err
+			}
+		}
+		config.ConnConfig.Password = c.password
+	}
+	return config, nil
+}
+
+// serverAddress returns the host:port of the server config connects to,
+// naming databases in webhook payloads. Unix sockets are named by their
+// directory.
+func serverAddress(config *pgxpool.Config) string {
+	host := config.ConnConfig.Host
+	if strings.HasPrefix(host, "/") {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(config.ConnConfig.Port)))
+}
+
+// databaseTarget is a database to introspect: a connection string and,
+// when set, the database to use instead of the one it names
+type databaseTarget struct {
+	dsn      string
+	database string
+}
+
+// targets returns the databases selected by the positional arguments. With
+// all, it lists every database on the server of the connection string.
+func (c *connectionFlags) targets(ctx context.Context, fs *flag.FlagSet, all bool) ([]databaseTarget, error) {
+	if !all {
+		var targets []databaseTarget
+		for _, dsn := range fs.Args() {
+			targets = append(targets, databaseTarget{dsn: dsn})
+		}
+		return targets, nil
+	}
+
+	if fs.NArg() > 1 {
+		return nil, errors.New("-all-databases needs a single connection string")
+	}
+	dsn, err := c.connectionString(fs)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := c.open(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	names, err := dbinfo.ListDatabases(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]databaseTarget, len(names))
+	for i, name := range names {
+		targets[i] = databaseTarget{dsn: dsn, database: name}
+	}
+	return targets, nil
+}
+
+// loadTarget connects to a target database and introspects it
+func (c *connectionFlags) loadTarget(ctx context.Context, target databaseTarget) (*dbinfo.DBInfo, error) {
+	if target.database == "" {
+		return c.loadFrom(ctx, target.dsn)
+	}
+
+	pool, err := c.openDatabase(ctx, target.dsn, target.database)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+	return c.introspect(ctx, pool)
+}
+
+// load connects to the database selected by the flags and introspects it
+func (c *connectionFlags) load(ctx context.Context, fs *flag.FlagSet) (*dbinfo.DBInfo, error) {
+	dsn, err := c.connectionString(fs)
+	if err != nil {
+		return nil, err
+	}
+	return c.loadFrom(ctx, dsn)
+}
+
+// loadFrom connects to dsn and introspects it using the selection flags
+func (c *connectionFlags) loadFrom(ctx context.Context, dsn string) (*dbinfo.DBInfo, error) {
+	pool, err := c.open(ctx, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Close()
+
+	return c.introspect(ctx, pool)
+}
+
+// introspect reads the schema of db using the selection flags and applies
+// the type overrides of the project configuration
+func (c *connectionFlags) introspect(ctx context.Context, db dbinfo.DBQuerier) (*dbinfo.DBInfo, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	info, err := dbinfo.GetDBInfo(ctx, db, c.options()...)
+	if err != nil {
+		return nil, c.introspectionError(err)
+	}
+	for _, table := range info.Tables {
+		project.mapTypes(table)
+	}
+	return info, nil
+}
+
+// newFlagSet returns a flag set for a subcommand with a usage line
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: dbinfo %s %s\n\nFlags:\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// stringList is a flag that can be repeated, collecting every value
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/contract"
+)
+
+// runContract implements 'dbinfo contract', checking the schemas of an
+// OpenAPI document against a database or a snapshot. Like 'dbinfo lint', it
+// exits with status 1 on mismatches and 2 when the check fails.
+func runContract(ctx context.Context, args []string) error {
+	mismatched, err := checkContract(ctx, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: lintError, err: err}
+	}
+	if mismatched {
+		return &exitError{code: lintFindings}
+	}
+	return nil
+}
+
+// checkContract prints the mismatches between the OpenAPI document and the
+// schema and reports whether there are any
+func checkContract(ctx context.Context, args []string) (bool, error) {
+	fs := newFlagSet("contract", "-spec openapi.yaml [flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	specPath := fs.String("spec", "", "YAML or JSON OpenAPI document to check (required)")
+	strict := fs.Bool("strict", false, "also report the schemas of the document no table matches")
+	formatName := fs.String("format", "text", "output format: text, json or markdown")
+	snapshotPath := fs.String("snapshot", "", "check against this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	var write func(io.Writer, *dbinfo.DBInfo, []*contract.Mismatch) error
+	switch *formatName {
+	case "text":
+		write = func(w io.Writer, info *dbinfo.DBInfo, mismatches []*contract.Mismatch) error {
+			return contract.WriteText(w, mismatches)
+		}
+	case "json":
+		write = func(w io.Writer, info *dbinfo.DBInfo, mismatches []*contract.Mismatch) error {
+			return contract.WriteJSON(w, mismatches)
+		}
+	case "markdown":
+		write = func(w io.Writer, info *dbinfo.DBInfo, mismatches []*contract.Mismatch) error {
+			return contract.WriteMarkdown(w, info.Name, mismatches)
+		}
+	default:
+		return false, fmt.Errorf("unknown contract format %q", *formatName)
+	}
+
+	if *specPath == "" {
+		return false, errors.New("-spec is required")
+	}
+	spec, err := contract.LoadFile(*specPath)
+	if err != nil {
+		return false, err
+	}
+
+	var info *dbinfo.DBInfo
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	mismatches := contract.Check(spec, info, contract.Options{Strict: *strict})
+	if err := out.write(func(w io.Writer) error {
+		return write(w, info, mismatches)
+	}); err != nil {
+		return false, err
+	}
+	return len(mismatches) > 0, nil
+}
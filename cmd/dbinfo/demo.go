@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/guillermo/dbinfo/sample"
+)
+
+// runDemo implements 'dbinfo demo', installing or removing the sample
+// schema used by the tests
+func runDemo(ctx context.Context, args []string) error {
+	fs := newFlagSet("demo", "-create|-drop [flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	create := fs.Bool("create", false, "create the sample tables ("+strings.Join(sample.Tables, ", ")+") and fill them")
+	drop := fs.Bool("drop", false, "drop the sample tables and their data; with -create, recreate them from scratch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if !*create && !*drop {
+		fs.Usage()
+		return errors.New("demo needs -create or -drop")
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	ctx, cancel := conn.withTimeout(ctx)
+	defer cancel()
+	if *drop {
+		if err := sample.Drop(ctx, pool); err != nil {
+			return err
+		}
+		conn.log.notice("Dropped the sample schema")
+	}
+	if *create {
+		if err := sample.Create(ctx, pool); err != nil {
+			return err
+		}
+		conn.log.notice("Created the sample schema, try 'dbinfo dump' on it")
+	}
+	return nil
+}
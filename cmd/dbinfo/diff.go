@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of 'dbinfo diff'
+const (
+	diffIdentical   = 0
+	diffDifferences = 1
+	diffError       = 2
+)
+
+// runDiff implements 'dbinfo diff', comparing the schemas of two databases
+// or of a snapshot and a database. It exits with diffDifferences when the
+// schemas differ (or only on breaking changes with -fail-on breaking) and
+// with diffError when the comparison fails.
+func runDiff(ctx context.Context, args []string) error {
+	failed, err := diffSchemas(ctx, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: diffError, err: err}
+	}
+	if failed {
+		return &exitError{code: diffDifferences}
+	}
+	return nil
+}
+
+// diffSchemas prints the changes between the schemas and reports whether
+// they should fail the command
+func diffSchemas(ctx context.Context, args []string) (bool, error) {
+	fs := newFlagSet("diff", "[flags] <from_connection_string> <to_connection_string>\n       dbinfo diff -against <snapshot> [flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json, or patch for an RFC 6902 JSON Patch of the snapshot")
+	noColor := fs.Bool("no-color", false, "disable colors in the text output, which are only used on terminals")
+	failOn := fs.String("fail-on", "any", "exit with status 1 on: any change, breaking changes only, or never")
+	detectRenames := fs.Bool("detect-renames", false, "report tables and columns that look renamed as renames instead of a removal and an addition")
+	renamesPath := fs.String("renames", "", "YAML or JSON file mapping old table and column names to new ones, confirming renames")
+	against := fs.String("against", "", "compare the database with this YAML or JSON snapshot, snapshot directory, snapshot URL (s3://, gs://, http(s)://) or SQL file or directory of migrations, instead of a second database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	if *failOn != "any" && *failOn != "breaking" && *failOn != "never" {
+		return false, fmt.Errorf("unknown -fail-on value %q, expected any, breaking or never", *failOn)
+	}
+
+	opts := diff.Options{DetectRenames: *detectRenames}
+	if *renamesPath != "" {
+		renames, err := loadRenames(*renamesPath)
+		if err != nil {
+			return false, err
+		}
+		opts.Renames = renames
+	}
+
+	var from, to *dbinfo.DBInfo
+	var write func(io.Writer, []*diff.Change) error
+	switch *formatName {
+	case "text":
+		write = diff.WriteText
+		if out.colorEnabled(*noColor) {
+			write = diff.WriteColorText
+		}
+	case "json":
+		write = diff.WriteJSON
+	case "patch":
+		write = func(w io.Writer, _ []*diff.Change) error {
+			patch, err := diff.PatchWith(from, to, opts)
+			if err != nil {
+				return err
+			}
+			return diff.WritePatch(w, patch)
+		}
+	default:
+		return false, fmt.Errorf("unknown diff format %q", *formatName)
+	}
+
+	var err error
+	if *against != "" {
+		if fs.NArg() > 1 {
+			fs.Usage()
+			return false, errors.New("diff -against needs a single connection string")
+		}
+		if from, err = loadSnapshot(ctx, *against); err != nil {
+			return false, err
+		}
+		if to, err = conn.load(ctx, fs); err != nil {
+			return false, err
+		}
+		if *against != "-" && !storage.IsURL(*against) && isDDL(*against) {
+			to = tablesOnly(to)
+		}
+	} else {
+		if fs.NArg() != 2 {
+			fs.Usage()
+			return false, errors.New("diff needs two connection strings")
+		}
+		if from, err = conn.loadFrom(ctx, fs.Arg(0)); err != nil {
+			return false, fmt.Errorf("from: %w", err)
+		}
+		if to, err = conn.loadFrom(ctx, fs.Arg(1)); err != nil {
+			return false, fmt.Errorf("to: %w", err)
+		}
+	}
+
+	changes := diff.CompareWith(from, to, opts)
+	if err := out.write(func(w io.Writer) error {
+		return write(w, changes)
+	}); err != nil {
+		return false, err
+	}
+
+	switch *failOn {
+	case "breaking":
+		return diff.HasBreaking(changes), nil
+	case "never":
+		return false, nil
+	}
+	return len(changes) > 0, nil
+}
+
+// tablesOnly returns info without the objects the SQL parser skips, such as
+// views and sequences, which would otherwise all be reported as added when
+// comparing with migrations
+func tablesOnly(info *dbinfo.DBInfo) *dbinfo.DBInfo {
+	tables := *info
+	tables.Views, tables.MaterializedViews, tables.Sequences = nil, nil, nil
+	tables.Domains, tables.ForeignTables, tables.EventTriggers = nil, nil, nil
+	return &tables
+}
+
+// loadRenames reads a rename map of 'dbinfo diff -renames', such as
+//
+//	public.clients: customers
+//	public.customers.mail: email
+func loadRenames(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading renames: %w", err)
+	}
+	var renames map[string]string
+	// YAML is a superset of JSON
+	if err := yaml.Unmarshal(data, &renames); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return renames, nil
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/anonymize"
+	"github.com/guillermo/dbinfo/glossary"
+	"github.com/guillermo/dbinfo/internal/format"
+	"github.com/guillermo/dbinfo/pii"
+	"github.com/guillermo/dbinfo/snapshot"
+	"github.com/guillermo/dbinfo/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// formatUsage describes the registered formats for the -format flag
+func formatUsage() string {
+	var b strings.Builder
+	b.WriteString("output format:")
+	for _, name := range format.Names() {
+		fmt.Fprintf(&b, "\n  %s: %s", name, format.Description(name))
+	}
+	return b.String()
+}
+
+// runDump implements 'dbinfo dump', printing the schema in one of the registered formats
+func runDump(ctx context.Context, args []string) error {
+	fs := newFlagSet("dump", "[flags] [connection_string...]")
+	var conn connectionFlags
+	conn.register(fs)
+	conn.registerProjection(fs)
+	var out outputFlags
+	out.register(fs)
+	splitDir := fs.String("split-dir", "", "write one file per table to this directory")
+	snapshotDir := fs.String("snapshot-dir", "", "write a snapshot directory for git: an index and a YAML file per table, readable by diff, gen and lint")
+	formatName := fs.String("format", project.format("yaml"), formatUsage())
+	defaultSchema := fs.String("default-schema", "public", "schema analyzed by SchemaSpy; other schemas are emitted as remote")
+	namespace := fs.String("lineage-namespace", "", "OpenLineage namespace of the server (defaults to postgres://host:port of the connection string)")
+	tagPII := fs.Bool("tag-pii", false, "tag the columns that likely hold personal data, see 'dbinfo pii'")
+	glossaryPath := fs.String("glossary", project.Glossary, "YAML, JSON or CSV business glossary filling missing comments and tagging business terms")
+	anonymized := fs.Bool("anonymize", false, "replace names with pseudonyms and strip comments and defaults (key read from DBINFO_ANONYMIZE_KEY)")
+	allDatabases := fs.Bool("all-databases", false, "dump every database on the server of the connection string")
+	databaseDir := fs.String("database-dir", "", "when dumping several databases, write one file per database to this directory")
+	federate := fs.String("federate", "", "when dumping several databases, compose them into one model with this name, linking the tables @references annotations relate")
+	push := fs.String("push", "", "upload the output to this s3://, gs://, http(s):// or file:// URL instead of printing it; a URL ending in / gets <database>.<extension> appended")
+	sign := fs.Bool("sign", false, "embed in the snapshot an HMAC signature keyed with "+snapshot.KeyEnv+", see 'dbinfo verify'")
+	signKey := fs.String("sign-key", "", "write an Ed25519 signature of the snapshot, signed with this PEM private key, next to the -o or -push output as <output>.sig")
+	explain := fs.Bool("explain", false, "print the catalog queries the dump would run, without connecting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *explain {
+		queries, err := dbinfo.Explain(conn.options()...)
+		if err != nil {
+			return err
+		}
+		return out.write(func(w io.Writer) error {
+			return writeExplain(w, queries)
+		})
+	}
+
+	if *splitDir != "" && out.path != "" {
+		return errors.New("-split-dir and -output can't be used together")
+	}
+	if *snapshotDir != "" && (out.path != "" || *splitDir != "") {
+		return errors.New("-snapshot-dir can't be used with -output or -split-dir")
+	}
+	if *push != "" && (*splitDir != "" || *snapshotDir != "") {
+		return errors.New("-push can't be used with -split-dir or -snapshot-dir")
+	}
+	signed := *sign || *signKey != ""
+	if signed {
+		if *formatName != "yaml" && *formatName != "json" {
+			return errors.New("-sign and -sign-key need the yaml or json format")
+		}
+		if *splitDir != "" || *snapshotDir != "" || *allDatabases || fs.NArg() > 1 {
+			return errors.New("-sign and -sign-key need a single snapshot file, not -split-dir, -snapshot-dir or several databases")
+		}
+		if *signKey != "" && (out.path == "-" || (out.path == "" && *push == "")) {
+			return errors.New("-sign-key needs -o or -push to name the signature file")
+		}
+	}
+
+	if *namespace == "" {
+		if dsn, err := conn.connectionString(fs); err == nil {
+			*namespace = lineageNamespace(dsn)
+		}
+	}
+	encoder, err := format.New(*formatName, format.Options{DefaultSchema: *defaultSchema, Namespace: *namespace})
+	if err != nil {
+		return err
+	}
+	extension := format.Extension(*formatName)
+
+	var detector *pii.Detector
+	if *tagPII {
+		if detector, err = piiDetector(""); err != nil {
+			return err
+		}
+	}
+
+	var terms *glossary.Glossary
+	if *glossaryPath != "" {
+		if terms, err = glossary.LoadFile(*glossaryPath); err != nil {
+			return err
+		}
+	}
+
+	var hmacKey []byte
+	if *sign {
+		if hmacKey = []byte(os.Getenv(snapshot.KeyEnv)); len(hmacKey) == 0 {
+			return fmt.Errorf("-sign needs the signing key in %s", snapshot.KeyEnv)
+		}
+	}
+	var signingKey ed25519.PrivateKey
+	if *signKey != "" {
+		if signingKey, err = snapshot.LoadPrivateKey(*signKey); err != nil {
+			return err
+		}
+	}
+
+	var anonymizer *anonymize.Anonymizer
+	if *anonymized {
+		anonymizer = anonymize.New([]byte(os.Getenv("DBINFO_ANONYMIZE_KEY")))
+	}
+
+	if *allDatabases || fs.NArg() > 1 {
+		if *splitDir != "" || *snapshotDir != "" {
+			return errors.New("-split-dir and -snapshot-dir can't be used with several databases, use -database-dir")
+		}
+		if *push != "" {
+			return errors.New("-push can't be used with several databases")
+		}
+		targets, err := conn.targets(ctx, fs, *allDatabases)
+		if err != nil {
+			return err
+		}
+		if *federate != "" {
+			return dumpFederation(ctx, &conn, targets, *federate, encoder, *formatName, *databaseDir, detector, terms, anonymizer, &out)
+		}
+		return dumpDatabases(ctx, &conn, targets, encoder, *formatName, *databaseDir, detector, terms, anonymizer, &out)
+	}
+	if *databaseDir != "" {
+		return errors.New("-database-dir needs several connection strings or -all-databases")
+	}
+	if *federate != "" {
+		return errors.New("-federate needs several connection strings or -all-databases")
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	// Stream tables as soon as they are introspected when the format allows
+	// it; the workload, statistics and privileges are only read for the
+	// whole database
+	if tableEncoder, ok := encoder.(format.TableEncoder); ok && *snapshotDir == "" && *push == "" && !signed && !conn.workload && !conn.statistics && !conn.privileges {
+		ctx, cancel := conn.withTimeout(ctx)
+		defer cancel()
+		return out.write(func(w io.Writer) error {
+			err := dbinfo.StreamTables(ctx, pool, func(table *dbinfo.Table) error {
+				project.mapTypes(table)
+				if detector != nil {
+					pii.TagTable(table, detector.Table(table))
+				}
+				if terms != nil {
+					terms.ApplyTable(table)
+				}
+				if anonymizer != nil {
+					table = anonymizer.Table(table)
+				}
+				if *splitDir != "" {
+					return writeTableFile(*splitDir, table.Schema, table.Name, extension, func(w io.Writer) error {
+						return tableEncoder.EncodeTable(w, table)
+					})
+				}
+				return tableEncoder.EncodeTable(w, table)
+			}, conn.options()...)
+			if err != nil {
+				return conn.introspectionError(err)
+			}
+			return nil
+		})
+	}
+
+	// Get database schema information
+	info, err := conn.introspect(ctx, pool)
+	if err != nil {
+		return err
+	}
+
+	if detector != nil {
+		pii.Tag(info, detector.Detect(info))
+	}
+	if terms != nil {
+		applyGlossary(&conn, terms, info)
+	}
+	if anonymizer != nil {
+		info = anonymizer.DBInfo(info)
+	}
+
+	if hmacKey != nil {
+		if err := snapshot.SignHMAC(info, hmacKey); err != nil {
+			return err
+		}
+	}
+	var signature []byte
+	if signingKey != nil {
+		if signature, err = snapshot.SignDetached(info, signingKey); err != nil {
+			return err
+		}
+	}
+
+	if *snapshotDir != "" {
+		return snapshot.WriteDir(*snapshotDir, info)
+	}
+	if *push != "" {
+		return pushSnapshot(ctx, &conn, *push, info, signature, encoder, *formatName, &out)
+	}
+
+	// Write each table as a document of its own, keeping its relationships
+	if *splitDir != "" {
+		for _, table := range info.Tables {
+			single := &dbinfo.DBInfo{Name: info.Name, Generator: info.Generator, Tables: []*dbinfo.Table{table}}
+			err := writeTableFile(*splitDir, table.Schema, table.Name, extension, func(w io.Writer) error {
+				return encoder.Encode(w, single)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = out.write(func(w io.Writer) error {
+		if err := encoder.Encode(w, info); err != nil {
+			return fmt.Errorf("writing %s output: %w", *formatName, err)
+		}
+		return nil
+	})
+	if err != nil || signature == nil {
+		return err
+	}
+	return writeSignature(out.path, signature)
+}
+
+// writeSignature writes the detached signature of the snapshot written to
+// path, as path.sig
+func writeSignature(path string, signature []byte) error {
+	f, err := createFile(path + ".sig")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(signature); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pushSnapshot uploads info in the selected format to location, named after
+// the database when location ends in a slash, and also writes it to -output
+// when set. A detached signature goes next to both, with a .sig suffix.
+func pushSnapshot(ctx context.Context, conn *connectionFlags, location string, info *dbinfo.DBInfo, signature []byte, encoder format.Encoder, formatName string, out *outputFlags) error {
+	var buf bytes.Buffer
+	if err := encoder.Encode(&buf, info); err != nil {
+		return fmt.Errorf("writing %s output: %w", formatName, err)
+	}
+	if strings.HasSuffix(location, "/") {
+		location += safeFileName(info.Name) + "." + format.Extension(formatName)
+	}
+	if err := storage.Put(ctx, location, buf.Bytes()); err != nil {
+		return err
+	}
+	conn.log.logger().Info("Pushed snapshot", "location", location, "bytes", buf.Len())
+	if signature != nil {
+		if err := storage.Put(ctx, location+".sig", signature); err != nil {
+			return err
+		}
+	}
+
+	if out.path == "" {
+		return nil
+	}
+	err := out.write(func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+	if err != nil || signature == nil {
+		return err
+	}
+	return writeSignature(out.path, signature)
+}
+
+// dumpFederation introspects several databases and writes them as one
+// federated model named name, see dbinfo.Federate
+func dumpFederation(ctx context.Context, conn *connectionFlags, targets []databaseTarget, name string, encoder format.Encoder, formatName, databaseDir string, detector *pii.Detector, terms *glossary.Glossary, anonymizer *anonymize.Anonymizer, out *outputFlags) error {
+	if databaseDir != "" {
+		return errors.New("-federate and -database-dir can't be used together")
+	}
+
+	var infos []*dbinfo.DBInfo
+	for _, target := range targets {
+		info, err := conn.loadTarget(ctx, target)
+		if err != nil {
+			return err
+		}
+		if detector != nil {
+			pii.Tag(info, detector.Detect(info))
+		}
+		infos = append(infos, info)
+	}
+	info, err := dbinfo.Federate(name, infos)
+	if err != nil {
+		return err
+	}
+	// Annotations are read before the glossary fills the missing comments
+	unresolved, err := info.LinkReferences()
+	if err != nil {
+		return err
+	}
+	for _, ref := range unresolved {
+		conn.log.logger().Warn("Annotated reference to a missing table or column", "reference", ref.String())
+	}
+	if terms != nil {
+		applyGlossary(conn, terms, info)
+	}
+	if anonymizer != nil {
+		info = anonymizer.DBInfo(info)
+	}
+
+	return out.write(func(w io.Writer) error {
+		if err := encoder.Encode(w, info); err != nil {
+			return fmt.Errorf("writing %s output: %w", formatName, err)
+		}
+		return nil
+	})
+}
+
+// applyGlossary enriches info with the glossary, warning about the entries
+// that match nothing, usually left behind by renamed tables and columns
+func applyGlossary(conn *connectionFlags, terms *glossary.Glossary, info *dbinfo.DBInfo) {
+	terms.Apply(info)
+	if unused := terms.Unused(info); len(unused) > 0 {
+		conn.log.logger().Warn("Glossary entries match nothing", "database", info.Name, "entries", strings.Join(unused, ", "))
+	}
+}
+
+// lineageNamespace returns the OpenLineage namespace of the server dsn
+// connects to, postgres://host:port, or "" when dsn can't be parsed or
+// connects through a Unix socket
+func lineageNamespace(dsn string) string {
+	connString, err := expandEnv(project.resolve(dsn))
+	if err != nil {
+		return ""
+	}
+	config, err := pgconn.ParseConfig(connString)
+	if err != nil || strings.HasPrefix(config.Host, "/") {
+		return ""
+	}
+	return "postgres://" + net.JoinHostPort(config.Host, strconv.Itoa(int(config.Port)))
+}
+
+// writeTableFile creates the file for a table in dir and calls fn to fill it
+func writeTableFile(dir, schema, table, extension string, fn func(w io.Writer) error) error {
+	f, err := createFile(filepath.Join(dir, tableFileName(schema, table, extension)))
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s.%s: %w", schema, table, err)
+	}
+	return f.Close()
+}
+
+// dumpDatabases introspects several databases and writes them either to a
+// combined document or to one file per database in databaseDir
+func dumpDatabases(ctx context.Context, conn *connectionFlags, targets []databaseTarget, encoder format.Encoder, formatName, databaseDir string, detector *pii.Detector, terms *glossary.Glossary, anonymizer *anonymize.Anonymizer, out *outputFlags) error {
+	multi, combinable := encoder.(format.MultiEncoder)
+	if databaseDir == "" && !combinable {
+		return fmt.Errorf("the %s format can't combine several databases in one document, use -database-dir", formatName)
+	}
+	if databaseDir != "" && out.path != "" {
+		return errors.New("-database-dir and -output can't be used together")
+	}
+
+	var infos []*dbinfo.DBInfo
+	for _, target := range targets {
+		info, err := conn.loadTarget(ctx, target)
+		if err != nil {
+			return err
+		}
+		if detector != nil {
+			pii.Tag(info, detector.Detect(info))
+		}
+		if terms != nil {
+			applyGlossary(conn, terms, info)
+		}
+		if anonymizer != nil {
+			info = anonymizer.DBInfo(info)
+		}
+
+		if databaseDir == "" {
+			infos = append(infos, info)
+			continue
+		}
+		f, err := createFile(filepath.Join(databaseDir, safeFileName(info.Name)+"."+format.Extension(formatName)))
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(f, info); err != nil {
+			f.Close()
+			return fmt.Errorf("writing %s: %w", info.Name, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if databaseDir != "" {
+		return nil
+	}
+	return out.write(func(w io.Writer) error {
+		if err := multi.EncodeAll(w, infos); err != nil {
+			return fmt.Errorf("writing %s output: %w", formatName, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/erd"
+)
+
+// runERD implements 'dbinfo erd', printing an entity relationship diagram
+func runERD(ctx context.Context, args []string) error {
+	fs := newFlagSet("erd", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	format := fs.String("format", "mermaid", "diagram format: mermaid, dot or svg (default from the -o extension, else mermaid)")
+	cluster := fs.String("cluster", "none", "group tables by schema, tag (see -tag) or component (tables linked by foreign keys), or none")
+	var tags stringList
+	fs.Var(&tags, "tag", "tag tables for -cluster tag, as name=pattern[,pattern...] (repeatable, added to the tags of the project configuration)")
+	collapseLookups := fs.Bool("collapse-lookups", false, "leave small lookup tables out, naming them on the referencing columns")
+	direction := fs.String("direction", "LR", "layout direction of DOT and SVG diagrams: LR, RL, TB or BT")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	formatSet := false
+	fs.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	if !formatSet {
+		switch strings.ToLower(filepath.Ext(out.path)) {
+		case ".svg":
+			*format = "svg"
+		case ".dot", ".gv":
+			*format = "dot"
+		case ".png":
+			return fmt.Errorf("PNG output needs a rasterizer, write %s instead and convert it, for example with rsvg-convert", strings.TrimSuffix(out.path, filepath.Ext(out.path))+".svg")
+		}
+	}
+
+	var render func(io.Writer, *dbinfo.DBInfo, ...erd.Option) error
+	switch *format {
+	case "mermaid":
+		render = erd.Mermaid
+	case "dot":
+		render = erd.DOT
+	case "svg":
+		render = erd.SVG
+	default:
+		return fmt.Errorf("unknown diagram format %q", *format)
+	}
+
+	switch *direction {
+	case "LR", "RL", "TB", "BT":
+	default:
+		return fmt.Errorf("unknown direction %q, expected LR, RL, TB or BT", *direction)
+	}
+	opts := []erd.Option{erd.WithDirection(*direction)}
+	switch *cluster {
+	case "none":
+	case "schema":
+		opts = append(opts, erd.WithClusters(erd.BySchema))
+	case "tag":
+		opts = append(opts, erd.WithClusters(erd.ByTag))
+	case "component":
+		opts = append(opts, erd.WithClusters(erd.ByComponent))
+	default:
+		return fmt.Errorf("unknown clustering %q, expected none, schema, tag or component", *cluster)
+	}
+	for _, tag := range project.Tags {
+		opts = append(opts, erd.WithTag(tag.name, tag.patterns...))
+	}
+	for _, value := range tags {
+		name, patterns, ok := strings.Cut(value, "=")
+		if !ok || name == "" || patterns == "" {
+			return fmt.Errorf("invalid -tag %q, expected name=pattern[,pattern...]", value)
+		}
+		for _, pattern := range strings.Split(patterns, ",") {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid -tag pattern %q: %w", pattern, err)
+			}
+		}
+		opts = append(opts, erd.WithTag(name, strings.Split(patterns, ",")...))
+	}
+	if *cluster == "tag" && len(project.Tags) == 0 && len(tags) == 0 {
+		return fmt.Errorf("-cluster tag needs tags, from -tag or the tags of %s", projectConfigFile)
+	}
+	if *collapseLookups {
+		opts = append(opts, erd.WithCollapsedLookups())
+	}
+
+	info, err := conn.load(ctx, fs)
+	if err != nil {
+		return err
+	}
+	return out.write(func(w io.Writer) error {
+		return render(w, info, opts...)
+	})
+}
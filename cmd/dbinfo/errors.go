@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error codes reported with -format json, so orchestration systems can tell
+// failures apart
+const (
+	errorAuth       = "auth"       // The server rejected the credentials
+	errorPermission = "permission" // The role can't read a catalog object
+	errorTimeout    = "timeout"    // A -timeout, -statement-timeout or server side timeout expired
+	errorConnection = "connection" // The server could not be reached
+	errorQuery      = "query"      // Any other failed catalog query
+	errorGeneric    = "error"      // Everything else, such as invalid flags or files
+)
+
+// errorReport is the JSON form of an error written to stderr
+type errorReport struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Phase    string `json:"phase,omitempty"`  // Introspection phase of a failed query
+	Object   string `json:"object,omitempty"` // schema.table being introspected
+	SQLState string `json:"sqlstate,omitempty"`
+	ExitCode int    `json:"exitcode"`
+}
+
+// newErrorReport classifies err
+func newErrorReport(err error, exitCode int) *errorReport {
+	report := &errorReport{Code: errorGeneric, Message: err.Error(), ExitCode: exitCode}
+
+	var queryErr *dbinfo.QueryError
+	if errors.As(err, &queryErr) {
+		report.Code = errorQuery
+		report.Phase = string(queryErr.Phase)
+		report.Object = queryErr.Object
+	}
+
+	var pgErr *pgconn.PgError
+	var connectErr *pgconn.ConnectError
+	switch {
+	case errors.As(err, &pgErr):
+		report.SQLState = pgErr.Code
+		switch {
+		case strings.HasPrefix(pgErr.Code, "28"): // invalid_authorization_specification, invalid_password
+			report.Code = errorAuth
+		case pgErr.Code == "42501": // insufficient_privilege
+			report.Code = errorPermission
+		case pgErr.Code == "57014": // query_canceled, raised by statement_timeout
+			report.Code = errorTimeout
+		case errors.As(err, &connectErr): // Such as a missing database
+			report.Code = errorConnection
+		default:
+			report.Code = errorQuery
+		}
+	case errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err):
+		report.Code = errorTimeout
+	case errors.As(err, &connectErr):
+		report.Code = errorConnection
+	}
+	return report
+}
+
+// jsonErrors reports whether the command line asks for JSON output, with
+// -format json or jsonl, or -log-format json, in which case errors are
+// written as JSON too
+func jsonErrors(args []string) bool {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || (name != "format" && name != "log-format") {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			value = args[i+1]
+		}
+		if value == "json" || value == "jsonl" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONError writes err to stderr as a one line JSON object
+func writeJSONError(err error, exitCode int) {
+	json.NewEncoder(os.Stderr).Encode(struct {
+		Error *errorReport `json:"error"`
+	}{newErrorReport(err, exitCode)})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// writeExplain prints the catalog queries of an introspection as a SQL
+// script, each preceded by a comment with its phase and arguments
+func writeExplain(w io.Writer, queries []*dbinfo.PlannedQuery) error {
+	for _, query := range queries {
+		header := "-- " + string(query.Phase)
+		if query.PerTable {
+			header += ", once per table"
+		}
+		for i, arg := range query.Args {
+			if i == 0 {
+				header += ":"
+			} else {
+				header += ","
+			}
+			header += fmt.Sprintf(" $%d = %s", i+1, explainArg(arg))
+		}
+		if _, err := fmt.Fprintf(w, "%s\n%s;\n\n", header, query.SQL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explainArg prints a query argument as a SQL literal
+func explainArg(arg any) string {
+	switch arg := arg.(type) {
+	case []string:
+		quoted := make([]string, len(arg))
+		for i, value := range arg {
+			quoted[i] = `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+		}
+		return "'{" + strings.ReplaceAll(strings.Join(quoted, ","), "'", "''") + "}'"
+	case string:
+		if strings.HasPrefix(arg, "<") {
+			return arg // Placeholder of a per table argument
+		}
+		return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+	default:
+		return fmt.Sprint(arg)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/infer"
+)
+
+// runFKs implements 'dbinfo fks', listing the declared foreign keys next to
+// the ones inferred from column names
+func runFKs(ctx context.Context, args []string) error {
+	fs := newFlagSet("fks", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or sql (statements declaring the inferred foreign keys)")
+	inferredOnly := fs.Bool("inferred", false, "only list the inferred foreign keys")
+	verify := fs.Bool("verify", false, "sample the referencing columns and count the values found in the referenced tables; reads table data")
+	sampleSize := fs.Int("sample-size", 1000, "values sampled per inferred foreign key by -verify")
+	minOverlap := fs.Float64("min-overlap", 0.9, "with -verify, drop inferred foreign keys whose share of matching values is lower")
+	snapshotPath := fs.String("snapshot", "", "read this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, []*infer.Reference) error
+	switch *formatName {
+	case "text":
+		write = infer.WriteText
+	case "json":
+		write = infer.WriteJSON
+	case "sql":
+		write = infer.WriteSQL
+	default:
+		return fmt.Errorf("unknown fks format %q", *formatName)
+	}
+	if *verify && *snapshotPath != "" {
+		return errors.New("-verify needs a database and can't be used with -snapshot")
+	}
+	if *sampleSize <= 0 {
+		return errors.New("-sample-size must be positive")
+	}
+
+	var info *dbinfo.DBInfo
+	var inferred []*infer.Reference
+	if *snapshotPath != "" {
+		var err error
+		if info, err = loadSnapshot(ctx, *snapshotPath); err != nil {
+			return err
+		}
+		inferred = infer.Infer(info)
+	} else {
+		pool, err := conn.connect(ctx, fs)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		if info, err = conn.introspect(ctx, pool); err != nil {
+			return err
+		}
+		inferred = infer.Infer(info)
+
+		if *verify {
+			ctx, cancel := conn.withTimeout(ctx)
+			defer cancel()
+			if err := infer.Verify(ctx, pool, inferred, *sampleSize); err != nil {
+				return err
+			}
+			kept := inferred[:0]
+			for _, ref := range inferred {
+				if ref.Overlap.Ratio() >= *minOverlap {
+					kept = append(kept, ref)
+				}
+			}
+			inferred = kept
+		}
+	}
+
+	refs := inferred
+	if !*inferredOnly {
+		refs = append(infer.Declared(info), inferred...)
+	}
+	return out.write(func(w io.Writer) error {
+		return write(w, refs)
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/ddl"
+	"github.com/guillermo/dbinfo/loadorder"
+	"github.com/guillermo/dbinfo/terraform"
+)
+
+// runGen implements 'dbinfo gen', printing the DDL that recreates the schema,
+// the Terraform configuration managing it, or scripts handling its data in
+// foreign key order
+func runGen(ctx context.Context, args []string) error {
+	fs := newFlagSet("gen", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	snapshotPath := fs.String("snapshot", "", "generate from this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	format := fs.String("format", "sql", "output format: sql, terraform, or a script in foreign key order: truncate, delete, dump (psql \\copy to CSV files), load (psql \\copy from them) or seed (INSERT skeletons)")
+	dataDir := fs.String("data-dir", ".", "directory of the CSV files of the dump and load scripts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var generate func(io.Writer, *dbinfo.DBInfo) error
+	switch *format {
+	case "sql":
+		generate = ddl.Generate
+	case "terraform":
+		generate = terraform.Generate
+	case "truncate":
+		generate = loadorder.WriteTruncate
+	case "delete":
+		generate = loadorder.WriteDelete
+	case "dump":
+		generate = func(w io.Writer, info *dbinfo.DBInfo) error {
+			return loadorder.WriteDump(w, info, *dataDir)
+		}
+	case "load":
+		generate = func(w io.Writer, info *dbinfo.DBInfo) error {
+			return loadorder.WriteLoad(w, info, *dataDir)
+		}
+	case "seed":
+		generate = loadorder.WriteSeed
+	default:
+		return fmt.Errorf("unknown output format %q", *format)
+	}
+
+	var info *dbinfo.DBInfo
+	var err error
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return err
+	}
+	return out.write(func(w io.Writer) error {
+		return generate(w, info)
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/grpc/service"
+)
+
+// runGRPCServe implements 'dbinfo grpc-serve', serving snapshots and diffs
+// over gRPC until the process is interrupted
+func runGRPCServe(ctx context.Context, args []string) error {
+	fs := newFlagSet("grpc-serve", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	addr := fs.String("addr", "localhost:9090", "address to listen on")
+	cacheTTL := fs.Duration("cache-ttl", time.Minute, "how long introspection results are reused")
+	token := fs.String("token", os.Getenv("DBINFO_SERVE_TOKEN"), "require this bearer token (defaults to DBINFO_SERVE_TOKEN)")
+	var snapshots stringList
+	fs.Var(&snapshots, "snapshot", "make a snapshot available to Diff calls, as name=path (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := []service.Option{service.WithCacheTTL(*cacheTTL), service.WithToken(*token)}
+	references, err := loadSnapshots(ctx, snapshots)
+	if err != nil {
+		return err
+	}
+	for name, info := range references {
+		opts = append(opts, service.WithSnapshot(name, info))
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	handler := service.New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return conn.introspect(ctx, pool)
+	}, opts...)
+
+	// gRPC needs HTTP/2; without TLS, clients connect with prior knowledge
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	conn.log.notice("Serving the gRPC schema service on %s", *addr)
+	return runServer(ctx, &http.Server{Addr: *addr, Handler: handler, Protocols: protocols, ReadHeaderTimeout: 10 * time.Second})
+}
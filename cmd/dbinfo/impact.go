@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/impact"
+)
+
+// impactUsage is the statement 'dbinfo impact' reports on
+const impactUsage = "drop table [schema.]table"
+
+// runImpact implements 'dbinfo impact drop table X', reporting what dropping
+// a table would affect before running the DDL
+func runImpact(ctx context.Context, args []string) error {
+	fs := newFlagSet("impact", "[flags] "+impactUsage+" [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or markdown")
+	snapshotPath := fs.String("snapshot", "", "analyze this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin); triggers and policies aren't checked")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 3 || fs.Arg(0) != "drop" || fs.Arg(1) != "table" {
+		return errors.New("usage: dbinfo impact " + impactUsage)
+	}
+	name := fs.Arg(2)
+	// Flags may also follow the statement
+	if err := fs.Parse(fs.Args()[3:]); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, *impact.Report) error
+	switch *formatName {
+	case "text":
+		write = impact.WriteText
+	case "json":
+		write = impact.WriteJSON
+	case "markdown":
+		write = impact.WriteMarkdown
+	default:
+		return fmt.Errorf("unknown impact format %q", *formatName)
+	}
+
+	var info *dbinfo.DBInfo
+	var dependents []*dbinfo.TableDependent
+	if *snapshotPath != "" {
+		var err error
+		info, err = loadSnapshot(ctx, *snapshotPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		pool, err := conn.connect(ctx, fs)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		if info, err = conn.introspect(ctx, pool); err != nil {
+			return err
+		}
+		table, err := impact.FindTable(info, name)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := conn.withTimeout(ctx)
+		defer cancel()
+		if dependents, err = dbinfo.GetTableDependents(ctx, pool, table.Schema, table.Name, conn.options()...); err != nil {
+			return conn.introspectionError(err)
+		}
+		// An empty list tells the report the dependents were checked
+		if dependents == nil {
+			dependents = []*dbinfo.TableDependent{}
+		}
+	}
+
+	table, err := impact.FindTable(info, name)
+	if err != nil {
+		return err
+	}
+	report := impact.DropTable(info, table, dependents)
+	return out.write(func(w io.Writer) error {
+		return write(w, report)
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/lint"
+)
+
+// Exit codes of 'dbinfo lint'
+const (
+	lintClean    = 0
+	lintFindings = 1
+	lintError    = 2
+)
+
+// runLint implements 'dbinfo lint', checking a database or a snapshot
+// against the registered rules. It exits with lintFindings when a finding is
+// at least as severe as -fail-on and with lintError when linting fails.
+func runLint(ctx context.Context, args []string) error {
+	failed, err := lintSchema(ctx, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: lintError, err: err}
+	}
+	if failed {
+		return &exitError{code: lintFindings}
+	}
+	return nil
+}
+
+// lintSchema prints the findings of the rules and reports whether they
+// should fail the command
+func lintSchema(ctx context.Context, args []string) (bool, error) {
+	fs := newFlagSet("lint", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or sarif")
+	noColor := fs.Bool("no-color", false, "disable colors in the text output, which are only used on terminals")
+	failOn := fs.String("fail-on", "error", "exit with status 1 on findings of this severity or higher: error, warning, info or never")
+	configPath := fs.String("config", "", "YAML file enabling, disabling and configuring rules (defaults to the lint section of "+projectConfigFile+")")
+	snapshotPath := fs.String("snapshot", "", "lint this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	listRules := fs.Bool("rules", false, "list the available rules and exit")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	rules := lint.Rules()
+	if *listRules {
+		return false, out.write(func(w io.Writer) error {
+			for _, rule := range rules {
+				description := rule.Description
+				if rule.Optional {
+					description += " (optional)"
+				}
+				if _, err := fmt.Fprintf(w, "%-26s %-8s %s\n", rule.Name, rule.Severity, description); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	var threshold lint.Severity
+	if *failOn != "never" {
+		var err error
+		if threshold, err = lint.ParseSeverity(*failOn); err != nil {
+			return false, fmt.Errorf("invalid -fail-on value: %w", err)
+		}
+	}
+
+	var write func(io.Writer, []*lint.Finding) error
+	switch *formatName {
+	case "text":
+		write = lint.WriteText
+		if out.colorEnabled(*noColor) {
+			write = lint.WriteColorText
+		}
+	case "json":
+		write = lint.WriteJSON
+	case "sarif":
+		write = func(w io.Writer, findings []*lint.Finding) error {
+			return lint.WriteSARIF(w, rules, findings)
+		}
+	default:
+		return false, fmt.Errorf("unknown lint format %q", *formatName)
+	}
+
+	cfg := project.Lint
+	if *configPath != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(*configPath); err != nil {
+			return false, err
+		}
+	}
+
+	var info *dbinfo.DBInfo
+	var err error
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	findings := lint.Run(info, rules, cfg)
+	if err := out.write(func(w io.Writer) error {
+		return write(w, findings)
+	}); err != nil {
+		return false, err
+	}
+
+	if threshold == "" {
+		return false, nil
+	}
+	return lint.HasSeverity(findings, threshold), nil
+}
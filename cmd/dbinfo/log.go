@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// logFlags holds the flags controlling diagnostics written to stderr
+type logFlags struct {
+	verbosity verbosity
+	quiet     bool
+	format    string
+}
+
+// register adds the -v, -q and -log-format flags to fs
+func (l *logFlags) register(fs *flag.FlagSet) {
+	fs.Var(&l.verbosity, "v", "log progress; repeat (-v -v) to also log every catalog query")
+	fs.BoolVar(&l.quiet, "q", false, "only report errors")
+	l.format = "text"
+	fs.Func("log-format", "log format: text (default) or json", func(value string) error {
+		if value != "text" && value != "json" {
+			return fmt.Errorf("unknown log format %q, expected text or json", value)
+		}
+		l.format = value
+		return nil
+	})
+}
+
+// level returns the minimum level of the messages to log
+func (l *logFlags) level() slog.Level {
+	switch {
+	case l.quiet:
+		return slog.LevelError
+	case l.verbosity >= 2:
+		return slog.LevelDebug
+	case l.verbosity == 1:
+		return slog.LevelInfo
+	}
+	return slog.LevelWarn
+}
+
+// logger returns a logger writing to stderr in the selected format
+func (l *logFlags) logger() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: l.level()}
+	if l.format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+// notice prints a status message to stderr unless -q is set
+func (l *logFlags) notice(format string, args ...any) {
+	if !l.quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// verbosity is a boolean flag counting how many times it is set
+type verbosity int
+
+func (v *verbosity) String() string {
+	if v == nil {
+		return "0"
+	}
+	return strconv.Itoa(int(*v))
+}
+
+func (v *verbosity) Set(value string) error {
+	if value == "true" {
+		*v++
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("expected a number of verbosity levels: %w", err)
+	}
+	*v = verbosity(n)
+	return nil
+}
+
+func (v *verbosity) IsBoolFlag() bool { return true }
@@ -1,137 +1,143 @@
+// Command dbinfo inspects PostgreSQL databases and prints their schema.
 package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 
-	"github.com/guillermo/dbinfo"
-	"gopkg.in/yaml.v3"
+	"github.com/guillermo/dbinfo/plugins"
 )
 
-// Define structs that match the dbinfo package structs
-// but with yaml tags for better YAML output
-
-type DBInfoYAML struct {
-	Name   string       `yaml:"name"`
-	Tables []*TableYAML `yaml:"tables"`
+// command is a dbinfo subcommand
+type command struct {
+	name  string
+	short string
+	run   func(ctx context.Context, args []string) error
 }
 
-type TableYAML struct {
-	Name        string               `yaml:"name"`
-	Schema      string               `yaml:"schema"`
-	Columns     []*dbinfo.Column     `yaml:"columns,omitempty"`
-	Indexes     []*dbinfo.Index      `yaml:"indexes,omitempty"`
-	ForeignKeys []*dbinfo.ForeignKey `yaml:"foreignkeys,omitempty"`
-	HasMany     []*RelationshipYAML  `yaml:"hasmany,omitempty"`
-	BelongsTo   []*RelationshipYAML  `yaml:"belongsto,omitempty"`
-	Comment     string               `yaml:"comment,omitempty"`
+// commands lists the available subcommands in the order they are shown in the usage
+var commands = []*command{
+	{name: "dump", short: "print the database schema", run: runDump},
+	{name: "diff", short: "compare the schemas of two databases", run: runDiff},
+	{name: "tenants", short: "compare tenant schemas with a reference schema and report per-tenant drift", run: runTenants},
+	{name: "changelog", short: "print the history of a schema from snapshots or their git history", run: runChangelog},
+	{name: "erd", short: "render an entity relationship diagram", run: runERD},
+	{name: "gen", short: "generate the DDL or Terraform configuration of the schema, or data scripts in foreign key order", run: runGen},
+	{name: "lint", short: "check the schema against design rules", run: runLint},
+	{name: "fks", short: "list declared foreign keys and infer undeclared ones from column names", run: runFKs},
+	{name: "contract", short: "check an OpenAPI document against the schema", run: runContract},
+	{name: "compliance", short: "verify the schema has the tables and columns a manifest requires", run: runCompliance},
+	{name: "verify", short: "check the signature of a snapshot", run: runVerify},
+	{name: "pii", short: "flag columns that likely hold personal data", run: runPII},
+	{name: "access", short: "print the roles × tables × privileges matrix for access reviews", run: runAccess},
+	{name: "advise", short: "recommend indexes to create and drop", run: runAdvise},
+	{name: "stats", short: "print table sizes, row estimates and bloat", run: runStats},
+	{name: "shape", short: "summarize column types, widest tables, foreign key chains and most referenced tables", run: runShape},
+	{name: "impact", short: "report what dropping a table would affect: foreign keys, views, triggers, indexes and sequences", run: runImpact},
+	{name: "serve", short: "serve the schema over an HTTP JSON API", run: runServe},
+	{name: "grpc-serve", short: "serve snapshots and diffs over gRPC", run: runGRPCServe},
+	{name: "mcp", short: "serve the schema to coding assistants over the Model Context Protocol", run: runMCP},
+	{name: "metrics", short: "export schema metrics and drift to Prometheus", run: runMetrics},
+	{name: "watch", short: "print schema changes as they happen", run: runWatch},
+	{name: "monitor", short: "notify webhooks and Slack of schema changes", run: runMonitor},
+	{name: "demo", short: "create or drop the sample e-commerce schema", run: runDemo},
+	{name: "version", short: "print the version and build information", run: runVersion},
 }
 
-type RelationshipYAML struct {
-	Table      string   `yaml:"table"`
-	Schema     string   `yaml:"schema"`
-	ForeignKey string   `yaml:"foreignkey"`
-	Columns    []string `yaml:"columns"`
-	References []string `yaml:"references"`
-	OnUpdate   string   `yaml:"onupdate,omitempty"`
-	OnDelete   string   `yaml:"ondelete,omitempty"`
+// exitError makes main exit with a specific status code. A nil err exits
+// without printing anything.
+type exitError struct {
+	code int
+	err  error
 }
 
-func convertToYAML(info *dbinfo.DBInfo) *DBInfoYAML {
-	yamlInfo := &DBInfoYAML{
-		Name:   info.Name,
-		Tables: make([]*TableYAML, len(info.Tables)),
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
 	}
+	return e.err.Error()
+}
 
-	for i, table := range info.Tables {
-		yamlTable := &TableYAML{
-			Name:        table.Name,
-			Schema:      table.Schema,
-			Columns:     table.Columns,
-			Indexes:     table.Indexes,
-			ForeignKeys: table.ForeignKeys,
-			Comment:     table.Comment,
-		}
-
-		// Convert HasMany relationships
-		if len(table.HasMany) > 0 {
-			yamlTable.HasMany = make([]*RelationshipYAML, len(table.HasMany))
-			for j, rel := range table.HasMany {
-				yamlTable.HasMany[j] = &RelationshipYAML{
-					Table:      rel.Table,
-					Schema:     rel.Schema,
-					ForeignKey: rel.ForeignKey,
-					Columns:    rel.Columns,
-					References: rel.References,
-					OnUpdate:   rel.OnUpdate,
-					OnDelete:   rel.OnDelete,
-				}
-			}
-		}
+func (e *exitError) Unwrap() error {
+	return e.err
+}
 
-		// Convert BelongsTo relationships
-		if len(table.BelongsTo) > 0 {
-			yamlTable.BelongsTo = make([]*RelationshipYAML, len(table.BelongsTo))
-			for j, rel := range table.BelongsTo {
-				yamlTable.BelongsTo[j] = &RelationshipYAML{
-					Table:      rel.Table,
-					Schema:     rel.Schema,
-					ForeignKey: rel.ForeignKey,
-					Columns:    rel.Columns,
-					References: rel.References,
-					OnUpdate:   rel.OnUpdate,
-					OnDelete:   rel.OnDelete,
-				}
-			}
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
 		}
-
-		yamlInfo.Tables[i] = yamlTable
 	}
+	return nil
+}
 
-	return yamlInfo
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: dbinfo <command> [flags] [connection_string]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.short)
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "The connection string can also be set with the DATABASE_URL environment variable.")
+	fmt.Fprintln(os.Stderr, "Run 'dbinfo <command> -h' for the flags of each command.")
 }
 
 func main() {
-	// Get connection string from environment or command line
-	dsn := os.Getenv("DATABASE_URL")
-	if len(os.Args) > 1 {
-		dsn = os.Args[1]
+	args := os.Args[1:]
+
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		usage()
+		return
 	}
 
-	if dsn == "" {
-		fmt.Println("Error: No database connection string provided")
-		fmt.Println("Usage: dbinfo [connection_string]")
-		fmt.Println("  or set the DATABASE_URL environment variable")
-		os.Exit(1)
+	// Without a known command, behave like 'dbinfo dump' so existing
+	// invocations such as 'dbinfo $DATABASE_URL' keep working
+	cmd := commands[0]
+	if len(args) > 0 {
+		if found := findCommand(args[0]); found != nil {
+			cmd = found
+			args = args[1:]
+		}
 	}
 
-	ctx := context.Background()
+	// Plugins register before the configuration is read, so it can
+	// configure their lint rules
+	plugins.Register(os.Getenv("PATH"))
 
-	// Create connection pool
-	pool, err := dbinfo.FromString(ctx, dsn)
+	cfg, err := loadProjectConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error connecting to database: %v\n", err)
+		reportError(args, err, 1)
 		os.Exit(1)
 	}
-	defer pool.Close()
+	project = cfg
 
-	// Get database schema information
-	info, err := dbinfo.GetDBInfo(ctx, pool)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error getting database info: %v\n", err)
-		os.Exit(1)
+	if err := cmd.run(context.Background(), args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		code := 1
+		var exit *exitError
+		if errors.As(err, &exit) {
+			code = exit.code
+			err = exit.err
+		}
+		if err != nil {
+			reportError(args, err, code)
+		}
+		os.Exit(code)
 	}
+}
 
-	// Convert to our YAML-friendly structs
-	yamlInfo := convertToYAML(info)
-
-	// Convert to YAML and print to stdout
-	yamlData, err := yaml.Marshal(yamlInfo)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error converting to YAML: %v\n", err)
-		os.Exit(1)
+// reportError prints err to stderr, as JSON when the command line asks for
+// JSON output
+func reportError(args []string, err error, code int) {
+	if jsonErrors(args) {
+		writeJSONError(err, code)
+		return
 	}
-
-	fmt.Println(string(yamlData))
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 }
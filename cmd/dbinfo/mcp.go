@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/mcp"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runMCP implements 'dbinfo mcp', a Model Context Protocol server on stdin
+// and stdout letting coding assistants look up the schema
+func runMCP(ctx context.Context, args []string) error {
+	fs := newFlagSet("mcp", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	cacheTTL := fs.Duration("cache-ttl", time.Minute, "how long introspection results are reused")
+	snapshotPath := fs.String("snapshot", "", "serve this YAML or JSON snapshot, or snapshot directory, instead of a database")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var load dbinfo.Loader
+	if *snapshotPath != "" {
+		info, err := loadSnapshot(ctx, *snapshotPath)
+		if err != nil {
+			return err
+		}
+		load = func(ctx context.Context) (*dbinfo.DBInfo, error) {
+			return info, nil
+		}
+	} else {
+		dsn, err := conn.connectionString(fs)
+		if err != nil {
+			return err
+		}
+		config, err := conn.poolConfig(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		// Introspection only reads the catalog; read-only sessions make
+		// sure nothing else can happen on this connection
+		config.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+		pool, err := pgxpool.NewWithConfig(ctx, config)
+		if err != nil {
+			return fmt.Errorf("connecting to database: %w", err)
+		}
+		defer pool.Close()
+		load = func(ctx context.Context) (*dbinfo.DBInfo, error) {
+			return conn.introspect(ctx, pool)
+		}
+	}
+
+	// Stdout carries the protocol, diagnostics go to stderr
+	return mcp.New(load, mcp.WithCacheTTL(*cacheTTL)).Serve(ctx, os.Stdin, os.Stdout)
+}
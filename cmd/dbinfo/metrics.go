@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/metrics"
+)
+
+// runMetrics implements 'dbinfo metrics', serving schema metrics to
+// Prometheus, or printing them once for the node_exporter textfile collector
+func runMetrics(ctx context.Context, args []string) error {
+	fs := newFlagSet("metrics", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	addr := fs.String("addr", "localhost:9188", "address to serve /metrics on")
+	once := fs.Bool("once", false, "print the metrics once instead of serving them")
+	var snapshots stringList
+	fs.Var(&snapshots, "snapshot", "report the drift from a snapshot, as name=path (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	references, err := loadSnapshots(ctx, snapshots)
+	if err != nil {
+		return err
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	if *once {
+		start := time.Now()
+		info, err := conn.introspect(ctx, pool)
+		if err != nil {
+			return err
+		}
+		return out.write(func(w io.Writer) error {
+			return metrics.Write(w, info, time.Since(start), references)
+		})
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return conn.introspect(ctx, pool)
+	}, references))
+	conn.log.notice("Serving metrics on http://%s/metrics", *addr)
+	return listenAndServe(ctx, *addr, mux)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/watch"
+)
+
+// runMonitor implements 'dbinfo monitor', a daemon polling one or more
+// databases and notifying webhooks of their schema changes
+func runMonitor(ctx context.Context, args []string) error {
+	fs := newFlagSet("monitor", "[flags] [connection_string...]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	interval := fs.Duration("interval", 5*time.Minute, "time between schema polls")
+	stateDir := fs.String("state-dir", "dbinfo-state", "directory keeping the last known schema of each database")
+	allDatabases := fs.Bool("all-databases", false, "monitor every database on the server of the connection string")
+	var webhooks, slackHooks stringList
+	fs.Var(&webhooks, "webhook", "post every change event as JSON to this URL, signed with $"+watch.SecretEnv+" when set (repeatable)")
+	fs.Var(&slackHooks, "slack", "post every change event to this Slack incoming webhook URL (repeatable)")
+	once := fs.Bool("once", false, "poll once and exit, for running from cron")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *interval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+
+	targets, err := conn.targets(ctx, fs, *allDatabases)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		dsn, err := conn.connectionString(fs)
+		if err != nil {
+			return err
+		}
+		targets = []databaseTarget{{dsn: dsn}}
+	}
+
+	// Sources are named after their database, which names their state file
+	var sources []watch.Source
+	var names []string
+	for _, target := range targets {
+		config, err := conn.poolConfig(ctx, target.dsn)
+		if err != nil {
+			return err
+		}
+		// PostgreSQL defaults the database to the user name
+		name := cmp.Or(target.database, config.ConnConfig.Database, config.ConnConfig.User)
+		if slices.Contains(names, name) {
+			return fmt.Errorf("several connection strings name database %q, their state would be mixed up", name)
+		}
+		names = append(names, name)
+		sources = append(sources, watch.Source{Name: name, Server: serverAddress(config), Load: func(ctx context.Context) (*dbinfo.DBInfo, error) {
+			return conn.loadTarget(ctx, target)
+		}})
+	}
+
+	state, err := watch.NewState(*stateDir)
+	if err != nil {
+		return err
+	}
+
+	w, err := out.create()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	notifiers := []watch.Notifier{watch.NotifierFunc(func(ctx context.Context, event *watch.Event) error {
+		return writeMonitorEvent(w, event)
+	})}
+	secret := []byte(os.Getenv(watch.SecretEnv))
+	for _, url := range webhooks {
+		notifiers = append(notifiers, watch.Webhook(url, secret))
+	}
+	for _, url := range slackHooks {
+		notifiers = append(notifiers, watch.Slack(url))
+	}
+
+	logger := conn.log.logger()
+	var failed bool
+	m := &watch.Monitor{
+		Sources:   sources,
+		State:     state,
+		Notifiers: notifiers,
+		OnError: func(source string, err error) {
+			failed = true
+			logger.Error("Monitoring failed", "database", source, "error", err)
+		},
+	}
+
+	if *once {
+		m.Poll(ctx)
+		if failed {
+			return &exitError{code: 1}
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conn.log.notice("Monitoring %s every %s", strings.Join(names, ", "), *interval)
+	if err := m.Run(ctx, *interval); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// writeMonitorEvent writes the changes of an event prefixed with its time
+// and database
+func writeMonitorEvent(w io.Writer, event *watch.Event) error {
+	for _, change := range event.Changes {
+		if _, err := fmt.Fprintf(w, "%s %s %s\n", event.Time.Format(time.RFC3339), event.Database, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/ddl"
+	"github.com/guillermo/dbinfo/snapshot"
+	"github.com/guillermo/dbinfo/storage"
+)
+
+// outputFlags holds the flags selecting where a command writes its output
+type outputFlags struct {
+	path string
+}
+
+// register adds the -o and -output flags to fs
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.path, "o", "", "write the output to this file instead of stdout")
+	fs.StringVar(&o.path, "output", "", "write the output to this file instead of stdout")
+}
+
+// create opens the selected output. Closing the returned writer closes the
+// file, or does nothing when writing to stdout.
+func (o *outputFlags) create() (io.WriteCloser, error) {
+	if o.path == "" || o.path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return createFile(o.path)
+}
+
+// write calls fn with the selected output and closes it afterwards
+func (o *outputFlags) write(fn func(w io.Writer) error) error {
+	w, err := o.create()
+	if err != nil {
+		return err
+	}
+	if err := fn(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// colorEnabled reports whether the output can be colored: it goes to a
+// terminal, and neither -no-color nor the NO_COLOR environment variable is set
+func (o *outputFlags) colorEnabled(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" || (o.path != "" && o.path != "-") {
+		return false
+	}
+	stat, err := os.Stdout.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// loadSnapshot reads a YAML or JSON snapshot, or a snapshot directory, from
+// path, a snapshot from stdin when path is "-", or a snapshot stored at a
+// URL such as s3://bucket/key. SQL files, and directories of them such as
+// migrations, are parsed into the schema they create.
+func loadSnapshot(ctx context.Context, path string) (*dbinfo.DBInfo, error) {
+	switch {
+	case path == "-":
+		return snapshot.Load(os.Stdin)
+	case storage.IsURL(path):
+		data, err := storage.Get(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return snapshot.Parse(data)
+	case isDDL(path):
+		return ddl.ParseFiles(path)
+	}
+	return snapshot.LoadFile(path)
+}
+
+// isDDL reports whether path is a .sql file or a directory of them, rather
+// than a snapshot directory
+func isDDL(path string) bool {
+	if strings.EqualFold(filepath.Ext(path), ".sql") {
+		return true
+	}
+	if _, err := os.Stat(filepath.Join(path, snapshot.IndexFile)); err == nil {
+		return false
+	}
+	matches, _ := filepath.Glob(filepath.Join(path, "*.sql"))
+	return len(matches) > 0
+}
+
+// createFile creates a file and its parent directories
+func createFile(path string) (*os.File, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating output directory: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("creating output file: %w", err)
+	}
+	return f, nil
+}
+
+// tableFileName returns the name of the file holding a table in split
+// directory mode. Path separators are replaced so every table maps to a file
+// directly inside the directory.
+func tableFileName(schema, table, extension string) string {
+	return safeFileName(schema) + "." + safeFileName(table) + "." + extension
+}
+
+// safeFileName replaces path separators in a name used as part of a file name
+func safeFileName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
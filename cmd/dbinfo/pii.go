@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/pii"
+)
+
+// runPII implements 'dbinfo pii', reporting the columns that likely hold
+// personal data
+func runPII(ctx context.Context, args []string) error {
+	fs := newFlagSet("pii", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or markdown (a privacy report)")
+	configPath := fs.String("config", "", "YAML file with detection rules and ignored columns (defaults to the pii section of "+projectConfigFile+")")
+	snapshotPath := fs.String("snapshot", "", "check this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var write func(io.Writer, *dbinfo.DBInfo, []*pii.Finding) error
+	switch *formatName {
+	case "text":
+		write = func(w io.Writer, info *dbinfo.DBInfo, findings []*pii.Finding) error {
+			return pii.WriteText(w, findings)
+		}
+	case "json":
+		write = func(w io.Writer, info *dbinfo.DBInfo, findings []*pii.Finding) error {
+			return pii.WriteJSON(w, findings)
+		}
+	case "markdown":
+		write = func(w io.Writer, info *dbinfo.DBInfo, findings []*pii.Finding) error {
+			return pii.WriteMarkdown(w, info.Name, findings)
+		}
+	default:
+		return fmt.Errorf("unknown pii format %q", *formatName)
+	}
+
+	detector, err := piiDetector(*configPath)
+	if err != nil {
+		return err
+	}
+
+	var info *dbinfo.DBInfo
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return err
+	}
+
+	findings := detector.Detect(info)
+	return out.write(func(w io.Writer) error {
+		return write(w, info, findings)
+	})
+}
+
+// piiDetector returns a detector configured by the file at path, or by the
+// project configuration when path is empty
+func piiDetector(path string) (*pii.Detector, error) {
+	cfg := project.PII
+	if path != "" {
+		var err error
+		if cfg, err = pii.LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+	return pii.New(cfg)
+}
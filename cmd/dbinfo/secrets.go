@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// envReference matches the ${VAR} references expanded in connection strings.
+// The bare $VAR form is left alone, as passwords often contain a '$'.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${VAR} references in s with the values of the
+// environment variables. Referencing an unset variable is an error, so a
+// missing secret doesn't turn into an empty password.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// runPasswordCmd runs command with the system shell and returns its output
+// without the trailing newline. Its standard error is shown to the user, so
+// the command can prompt for a passphrase.
+func runPasswordCmd(ctx context.Context, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running -password-cmd: %w", err)
+	}
+	password := string(bytes.TrimRight(out, "\r\n"))
+	if password == "" {
+		return "", fmt.Errorf("-password-cmd printed an empty password")
+	}
+	return password, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/server"
+)
+
+// runServe implements 'dbinfo serve', exposing the schema over HTTP until
+// the process is interrupted
+func runServe(ctx context.Context, args []string) error {
+	fs := newFlagSet("serve", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	cacheTTL := fs.Duration("cache-ttl", time.Minute, "how long introspection results are reused")
+	token := fs.String("token", os.Getenv("DBINFO_SERVE_TOKEN"), "require this bearer token (defaults to DBINFO_SERVE_TOKEN)")
+	withUI := fs.Bool("ui", true, "serve the web UI on /")
+	withMetrics := fs.Bool("metrics", false, "serve Prometheus metrics on /metrics, with the drift from every -snapshot")
+	withGraphQL := fs.Bool("graphql", false, "serve a read-only GraphQL API over the schema on /graphql")
+	var snapshots stringList
+	fs.Var(&snapshots, "snapshot", "make a snapshot available to /diff?against=<name>, as name=path (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := []server.Option{server.WithCacheTTL(*cacheTTL), server.WithToken(*token), server.WithUI(*withUI), server.WithMetrics(*withMetrics), server.WithGraphQL(*withGraphQL)}
+	references, err := loadSnapshots(ctx, snapshots)
+	if err != nil {
+		return err
+	}
+	for name, info := range references {
+		opts = append(opts, server.WithSnapshot(name, info))
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	handler := server.New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return conn.introspect(ctx, pool)
+	}, opts...)
+
+	conn.log.notice("Serving schema on http://%s", *addr)
+	return listenAndServe(ctx, *addr, handler)
+}
+
+// loadSnapshots loads the snapshots of -snapshot flags, given as name=path
+func loadSnapshots(ctx context.Context, values stringList) (map[string]*dbinfo.DBInfo, error) {
+	snapshots := make(map[string]*dbinfo.DBInfo, len(values))
+	for _, value := range values {
+		name, path, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid -snapshot %q, expected name=path", value)
+		}
+		info, err := loadSnapshot(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[name] = info
+	}
+	return snapshots, nil
+}
+
+// listenAndServe serves handler on addr until the process is interrupted,
+// then shuts the server down gracefully
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	return runServer(ctx, &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second})
+}
+
+// runServer runs srv until the process is interrupted, then shuts it down
+// gracefully
+func runServer(ctx context.Context, srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
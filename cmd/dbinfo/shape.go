@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/shape"
+)
+
+// runShape implements 'dbinfo shape', summarizing the column types and the
+// structure of a schema
+func runShape(ctx context.Context, args []string) error {
+	fs := newFlagSet("shape", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or markdown")
+	top := fs.Int("top", shape.DefaultTop, "number of tables listed in each ranking")
+	snapshotPath := fs.String("snapshot", "", "summarize this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *top < 1 {
+		return fmt.Errorf("-top must be at least 1, got %d", *top)
+	}
+
+	var write func(io.Writer, *dbinfo.DBInfo, *shape.Report) error
+	switch *formatName {
+	case "text":
+		write = func(w io.Writer, info *dbinfo.DBInfo, r *shape.Report) error {
+			return shape.WriteText(w, r)
+		}
+	case "json":
+		write = func(w io.Writer, info *dbinfo.DBInfo, r *shape.Report) error {
+			return shape.WriteJSON(w, r)
+		}
+	case "markdown":
+		write = func(w io.Writer, info *dbinfo.DBInfo, r *shape.Report) error {
+			return shape.WriteMarkdown(w, info.Name, r)
+		}
+	default:
+		return fmt.Errorf("unknown shape format %q", *formatName)
+	}
+
+	var info *dbinfo.DBInfo
+	var err error
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return err
+	}
+
+	report := shape.Analyze(info, shape.Options{Top: *top})
+	return out.write(func(w io.Writer) error {
+		return write(w, info, report)
+	})
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// statsSorts orders tables for each -sort value. Sizes and counts sort from
+// the largest down.
+var statsSorts = map[string]func(a, b *dbinfo.TableStats) bool{
+	"name":       func(a, b *dbinfo.TableStats) bool { return false },
+	"rows":       func(a, b *dbinfo.TableStats) bool { return a.RowEstimate > b.RowEstimate },
+	"size":       func(a, b *dbinfo.TableStats) bool { return a.TotalBytes > b.TotalBytes },
+	"table-size": func(a, b *dbinfo.TableStats) bool { return a.TableBytes > b.TableBytes },
+	"index-size": func(a, b *dbinfo.TableStats) bool { return a.IndexBytes > b.IndexBytes },
+	"bloat":      func(a, b *dbinfo.TableStats) bool { return a.BloatBytes > b.BloatBytes },
+}
+
+// statsReport is the JSON form of 'dbinfo stats'
+type statsReport struct {
+	Tables  []*dbinfo.TableStats  `json:"tables"`
+	Schemas []*dbinfo.SchemaStats `json:"schemas"`
+}
+
+// runStats implements 'dbinfo stats', printing row estimates and sizes per
+// table and schema
+func runStats(ctx context.Context, args []string) error {
+	fs := newFlagSet("stats", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	formatName := fs.String("format", "text", "output format: text, json or csv")
+	sortBy := fs.String("sort", "name", "sort tables by name, rows, size, table-size, index-size or bloat")
+	limit := fs.Int("limit", 0, "only print the first n tables, 0 prints all")
+	snapshotPath := fs.String("snapshot", "", "report the sizes recorded in this YAML or JSON snapshot, or snapshot directory, taken with -statistics, instead of a database (- for stdin)")
+	bloat := fs.String("bloat", "", "print a bloat report of the tables and their B-tree indexes instead, measured with estimate (from the planner statistics) or pgstattuple (exact, scans every page)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *bloat != "" {
+		if *snapshotPath != "" {
+			return errors.New("-bloat needs a database, not a -snapshot")
+		}
+		return statsBloat(ctx, fs, &conn, &out, *bloat, *formatName, *sortBy, *limit)
+	}
+
+	less, ok := statsSorts[*sortBy]
+	if !ok {
+		return fmt.Errorf("unknown sort %q", *sortBy)
+	}
+	var write func(io.Writer, []*dbinfo.TableStats, []*dbinfo.SchemaStats) error
+	switch *formatName {
+	case "text":
+		write = writeStatsText
+	case "json":
+		write = writeStatsJSON
+	case "csv":
+		write = writeStatsCSV
+	default:
+		return fmt.Errorf("unknown stats format %q", *formatName)
+	}
+
+	var tables []*dbinfo.TableStats
+	if *snapshotPath != "" {
+		// Snapshots record sizes without tuple counts, so without bloat
+		info, err := loadSnapshot(ctx, *snapshotPath)
+		if err != nil {
+			return err
+		}
+		tables = info.TableStats()
+		if len(tables) == 0 {
+			return fmt.Errorf("snapshot %s records no statistics, take it with -statistics", *snapshotPath)
+		}
+	} else {
+		pool, err := conn.connect(ctx, fs)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+
+		ctx, cancel := conn.withTimeout(ctx)
+		defer cancel()
+		if tables, err = dbinfo.GetTableStats(ctx, pool, conn.options()...); err != nil {
+			return conn.introspectionError(err)
+		}
+	}
+
+	// Totals cover every table, even those cut by -limit
+	schemas := dbinfo.SummarizeStats(tables)
+	sort.SliceStable(tables, func(i, j int) bool { return less(tables[i], tables[j]) })
+	if *limit > 0 && len(tables) > *limit {
+		tables = tables[:*limit]
+	}
+
+	w, err := out.create()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return write(w, tables, schemas)
+}
+
+// writeStatsText writes the tables and the schema totals as aligned columns
+func writeStatsText(w io.Writer, tables []*dbinfo.TableStats, schemas []*dbinfo.SchemaStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "SCHEMA\tTABLE\tROWS\tTABLE SIZE\tINDEXES\tTOAST\tTOTAL\tBLOAT\t")
+	for _, t := range tables {
+		name := t.Name
+		if t.Partitions > 0 {
+			name += fmt.Sprintf(" (%d partitions)", t.Partitions)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s (%.0f%%)\t\n", t.Schema, name, t.RowEstimate,
+			formatBytes(t.TableBytes), formatBytes(t.IndexBytes), formatBytes(t.ToastBytes),
+			formatBytes(t.TotalBytes), formatBytes(t.BloatBytes), t.BloatRatio*100)
+	}
+	fmt.Fprintln(tw, "\t\t\t\t\t\t\t\t")
+	fmt.Fprintln(tw, "SCHEMA\tTABLES\tROWS\tTABLE SIZE\tINDEXES\tTOAST\tTOTAL\tBLOAT\t")
+	for _, s := range schemas {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t\n", s.Schema, s.Tables, s.RowEstimate,
+			formatBytes(s.TableBytes), formatBytes(s.IndexBytes), formatBytes(s.ToastBytes),
+			formatBytes(s.TotalBytes), formatBytes(s.BloatBytes))
+	}
+	return tw.Flush()
+}
+
+// writeStatsJSON writes the tables and the schema totals as one JSON document
+func writeStatsJSON(w io.Writer, tables []*dbinfo.TableStats, schemas []*dbinfo.SchemaStats) error {
+	report := statsReport{Tables: tables, Schemas: schemas}
+	if report.Tables == nil {
+		report.Tables = []*dbinfo.TableStats{}
+	}
+	if report.Schemas == nil {
+		report.Schemas = []*dbinfo.SchemaStats{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// writeStatsCSV writes one row per table, sizes in bytes, for spreadsheets
+func writeStatsCSV(w io.Writer, tables []*dbinfo.TableStats, _ []*dbinfo.SchemaStats) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"schema", "table", "row_estimate", "table_bytes", "index_bytes", "toast_bytes", "total_bytes", "dead_tuples", "bloat_ratio", "bloat_bytes", "partitions"})
+	for _, t := range tables {
+		cw.Write([]string{
+			t.Schema, t.Name,
+			strconv.FormatInt(t.RowEstimate, 10),
+			strconv.FormatInt(t.TableBytes, 10),
+			strconv.FormatInt(t.IndexBytes, 10),
+			strconv.FormatInt(t.ToastBytes, 10),
+			strconv.FormatInt(t.TotalBytes, 10),
+			strconv.FormatInt(t.DeadTuples, 10),
+			strconv.FormatFloat(t.BloatRatio, 'f', 4, 64),
+			strconv.FormatInt(t.BloatBytes, 10),
+			strconv.Itoa(t.Partitions),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formatBytes prints a size with a binary unit, like pg_size_pretty
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// bloatSorts orders the relations of the bloat report for each -sort value
+var bloatSorts = map[string]func(a, b *dbinfo.BloatStats) bool{
+	"name":  func(a, b *dbinfo.BloatStats) bool { return false },
+	"size":  func(a, b *dbinfo.BloatStats) bool { return a.Bytes > b.Bytes },
+	"bloat": func(a, b *dbinfo.BloatStats) bool { return a.BloatBytes > b.BloatBytes },
+}
+
+// statsBloat implements 'dbinfo stats -bloat', printing the bloat of the
+// tables and their indexes
+func statsBloat(ctx context.Context, fs *flag.FlagSet, conn *connectionFlags, out *outputFlags, methodName, formatName, sortBy string, limit int) error {
+	method, err := dbinfo.ParseBloatMethod(methodName)
+	if err != nil {
+		return err
+	}
+	less, ok := bloatSorts[sortBy]
+	if !ok {
+		return fmt.Errorf("unknown sort %q for the bloat report, expected name, size or bloat", sortBy)
+	}
+	var write func(io.Writer, []*dbinfo.BloatStats) error
+	switch formatName {
+	case "text":
+		write = writeBloatText
+	case "json":
+		write = func(w io.Writer, stats []*dbinfo.BloatStats) error {
+			if stats == nil {
+				stats = []*dbinfo.BloatStats{}
+			}
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(stats)
+		}
+	case "csv":
+		write = writeBloatCSV
+	default:
+		return fmt.Errorf("unknown stats format %q", formatName)
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	ctx, cancel := conn.withTimeout(ctx)
+	defer cancel()
+	stats, err := dbinfo.GetBloatStats(ctx, pool, method, conn.options()...)
+	if err != nil {
+		return conn.introspectionError(err)
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return less(stats[i], stats[j]) })
+	if limit > 0 && len(stats) > limit {
+		stats = stats[:limit]
+	}
+
+	w, err := out.create()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	return write(w, stats)
+}
+
+// writeBloatText writes one aligned line per table and index
+func writeBloatText(w io.Writer, stats []*dbinfo.BloatStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "SCHEMA\tTABLE\tINDEX\tSIZE\tBLOAT\tDEAD TUPLES\t")
+	for _, s := range stats {
+		dead := "-"
+		if s.Index == "" {
+			dead = fmt.Sprintf("%.0f%%", s.DeadTupleRatio*100)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s (%.0f%%)\t%s\t\n", s.Schema, s.Table, s.Index,
+			formatBytes(s.Bytes), formatBytes(s.BloatBytes), s.BloatRatio*100, dead)
+	}
+	return tw.Flush()
+}
+
+// writeBloatCSV writes one row per table and index, sizes in bytes
+func writeBloatCSV(w io.Writer, stats []*dbinfo.BloatStats) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"schema", "table", "index", "bytes", "bloat_bytes", "bloat_ratio", "dead_tuple_ratio", "method"})
+	for _, s := range stats {
+		cw.Write([]string{
+			s.Schema, s.Table, s.Index,
+			strconv.FormatInt(s.Bytes, 10),
+			strconv.FormatInt(s.BloatBytes, 10),
+			strconv.FormatFloat(s.BloatRatio, 'f', 4, 64),
+			strconv.FormatFloat(s.DeadTupleRatio, 'f', 4, 64),
+			string(s.Method),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
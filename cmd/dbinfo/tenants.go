@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/tenants"
+)
+
+// runTenants implements 'dbinfo tenants', comparing the tenant schemas of a
+// database with a reference schema. Like 'dbinfo diff', it exits with
+// diffDifferences when a tenant drifted (or only on breaking changes with
+// -fail-on breaking) and with diffError when the comparison fails.
+func runTenants(ctx context.Context, args []string) error {
+	failed, err := compareTenants(ctx, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return &exitError{code: diffError, err: err}
+	}
+	if failed {
+		return &exitError{code: diffDifferences}
+	}
+	return nil
+}
+
+// compareTenants prints the deviations of the tenants and reports whether
+// they should fail the command
+func compareTenants(ctx context.Context, args []string) (bool, error) {
+	fs := newFlagSet("tenants", "-reference <schema> [flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	reference := fs.String("reference", "", "schema the tenants should look like, such as the template new tenants are copied from")
+	var patterns stringList
+	fs.Var(&patterns, "tenant", "tenant schemas to compare, as a glob such as tenant_*; can be repeated, every other schema by default")
+	formatName := fs.String("format", "text", "output format: text, json or markdown")
+	failOn := fs.String("fail-on", "any", "exit with status 1 on: any drift, breaking changes only, or never")
+	snapshotPath := fs.String("snapshot", "", "compare the schemas of this YAML or JSON snapshot, or snapshot directory, instead of a database (- for stdin)")
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+
+	if *failOn != "any" && *failOn != "breaking" && *failOn != "never" {
+		return false, fmt.Errorf("unknown -fail-on value %q, expected any, breaking or never", *failOn)
+	}
+	opts := tenants.Options{Reference: *reference, Tenants: patterns}
+	if err := opts.Validate(); err != nil {
+		return false, err
+	}
+
+	var write func(io.Writer, *dbinfo.DBInfo, *tenants.Report) error
+	switch *formatName {
+	case "text":
+		write = func(w io.Writer, _ *dbinfo.DBInfo, r *tenants.Report) error { return tenants.WriteText(w, r) }
+	case "json":
+		write = func(w io.Writer, _ *dbinfo.DBInfo, r *tenants.Report) error { return tenants.WriteJSON(w, r) }
+	case "markdown":
+		write = func(w io.Writer, info *dbinfo.DBInfo, r *tenants.Report) error {
+			return tenants.WriteMarkdown(w, info.Name, r)
+		}
+	default:
+		return false, fmt.Errorf("unknown tenants format %q", *formatName)
+	}
+
+	var info *dbinfo.DBInfo
+	var err error
+	if *snapshotPath != "" {
+		info, err = loadSnapshot(ctx, *snapshotPath)
+	} else {
+		info, err = conn.load(ctx, fs)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	report, err := tenants.Compare(info, opts)
+	if err != nil {
+		return false, err
+	}
+	if err := out.write(func(w io.Writer) error {
+		return write(w, info, report)
+	}); err != nil {
+		return false, err
+	}
+
+	switch *failOn {
+	case "breaking":
+		for _, t := range report.Tenants {
+			if diff.HasBreaking(t.Changes) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "never":
+		return false, nil
+	}
+	return len(report.Drifted()) > 0, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/guillermo/dbinfo/snapshot"
+	"github.com/guillermo/dbinfo/storage"
+)
+
+// runVerify implements 'dbinfo verify', checking the signature of a
+// snapshot written by 'dbinfo dump -sign' or '-sign-key'. Like 'dbinfo
+// lint', it exits with status 1 when the signature doesn't match and 2 when
+// the check itself fails.
+func runVerify(ctx context.Context, args []string) error {
+	err := verifySnapshot(ctx, args)
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	if errors.Is(err, snapshot.ErrInvalidSignature) || errors.Is(err, snapshot.ErrUnsigned) {
+		return &exitError{code: lintFindings, err: err}
+	}
+	return &exitError{code: lintError, err: err}
+}
+
+// verifySnapshot checks the embedded signature of the snapshot named in
+// args, or its detached signature with -public-key, and prints the outcome
+func verifySnapshot(ctx context.Context, args []string) error {
+	fs := newFlagSet("verify", "[flags] snapshot")
+	publicKey := fs.String("public-key", "", "check the detached signature with this PEM Ed25519 public key, instead of the embedded HMAC keyed with "+snapshot.KeyEnv)
+	signaturePath := fs.String("signature", "", "detached signature file or URL (defaults to the snapshot path with a .sig suffix)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("verify needs a snapshot file, directory or URL, or - for stdin")
+	}
+	path := fs.Arg(0)
+
+	info, err := loadSnapshot(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if *publicKey == "" {
+		key := []byte(os.Getenv(snapshot.KeyEnv))
+		if len(key) == 0 {
+			return fmt.Errorf("verifying an embedded signature needs its key in %s, or use -public-key", snapshot.KeyEnv)
+		}
+		if err := snapshot.VerifyHMAC(info, key); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("%s: valid %s signature\n", path, info.Signature.Algorithm)
+		return nil
+	}
+
+	key, err := snapshot.LoadPublicKey(*publicKey)
+	if err != nil {
+		return err
+	}
+	if *signaturePath == "" {
+		if path == "-" {
+			return errors.New("-signature is required when reading the snapshot from stdin")
+		}
+		*signaturePath = strings.TrimSuffix(path, string(filepath.Separator)) + ".sig"
+	}
+	var signature []byte
+	if storage.IsURL(*signaturePath) {
+		signature, err = storage.Get(ctx, *signaturePath)
+	} else {
+		signature, err = os.ReadFile(*signaturePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+	if err := snapshot.VerifyDetached(info, key, signature); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Printf("%s: valid ed25519 signature\n", path)
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// runVersion implements 'dbinfo version', printing build information
+func runVersion(ctx context.Context, args []string) error {
+	fs := newFlagSet("version", "[flags]")
+	var out outputFlags
+	out.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	return out.write(func(w io.Writer) error {
+		commit := dbinfo.Commit()
+		if commit == "" {
+			commit = "unknown"
+		}
+		_, err := fmt.Fprintf(w, "dbinfo %s\ncommit: %s\ngo: %s\npostgresql: %d to %d\n",
+			dbinfo.Version(), commit, runtime.Version(), dbinfo.MinPostgresVersion, dbinfo.MaxPostgresVersion)
+		return err
+	})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/watch"
+)
+
+// runWatch implements 'dbinfo watch', printing schema changes as they
+// happen until the process is interrupted
+func runWatch(ctx context.Context, args []string) error {
+	fs := newFlagSet("watch", "[flags] [connection_string]")
+	var conn connectionFlags
+	conn.register(fs)
+	var out outputFlags
+	out.register(fs)
+	interval := fs.Duration("interval", 30*time.Second, "time between schema polls")
+	formatName := fs.String("format", "text", "output format: text, or jsonl for one JSON event per line")
+	var webhooks stringList
+	fs.Var(&webhooks, "webhook", "also post every change event as JSON to this URL, signed with $"+watch.SecretEnv+" when set (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *interval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+
+	var write func(io.Writer, *watch.Event) error
+	switch *formatName {
+	case "text":
+		write = writeEventText
+	case "jsonl":
+		write = func(w io.Writer, event *watch.Event) error {
+			return json.NewEncoder(w).Encode(event)
+		}
+	default:
+		return fmt.Errorf("unknown watch format %q", *formatName)
+	}
+
+	pool, err := conn.connect(ctx, fs)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w, err := out.create()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	var notifiers []watch.Notifier
+	secret := []byte(os.Getenv(watch.SecretEnv))
+	for _, url := range webhooks {
+		notifiers = append(notifiers, watch.Webhook(url, secret))
+	}
+
+	config := pool.Config()
+	// PostgreSQL defaults the database to the user name
+	database, server := cmp.Or(config.ConnConfig.Database, config.ConnConfig.User), serverAddress(config)
+	conn.log.notice("Watching schema every %s", *interval)
+	err = watch.Watch(ctx, func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return conn.introspect(ctx, pool)
+	}, *interval, func(event *watch.Event) error {
+		event.Database, event.Server = database, server
+		if err := write(w, event); err != nil {
+			return err
+		}
+		// A failed notification is reported without stopping the watch
+		for _, notifier := range notifiers {
+			if err := notifier.Notify(ctx, event); err != nil {
+				conn.log.logger().Error("Notification failed", "error", err)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// writeEventText writes the changes of an event prefixed with its time
+func writeEventText(w io.Writer, event *watch.Event) error {
+	for _, change := range event.Changes {
+		if _, err := fmt.Fprintf(w, "%s %s\n", event.Time.Format(time.RFC3339), change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
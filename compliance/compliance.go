@@ -0,0 +1,335 @@
+// Package compliance verifies that a database has the tables and columns a
+// manifest requires, such as the fields regulations make it keep, and
+// reports each requirement as passed or failed for audits.
+//
+// A manifest is a YAML (or JSON) list of requirements, or a CSV file with a
+// header naming the same fields:
+//
+//   - table: public.customers
+//     column: erased_at
+//     type: timestamptz
+//     nullable: true
+//     description: GDPR erasure date
+//
+// Table is schema.table, or a table name matching tables in any schema, and
+// may hold path.Match patterns: public.* requires the column in every table
+// of the public schema. Without a column, a requirement only needs the table
+// to exist. Type and nullable are optional.
+package compliance
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// Requirement is an entry of a manifest
+type Requirement struct {
+	Table       string `yaml:"table" json:"table"`
+	Column      string `yaml:"column,omitempty" json:"column,omitempty"`
+	Type        string `yaml:"type,omitempty" json:"type,omitempty"`         // As in SQL, aliases such as timestamptz allowed
+	Nullable    *bool  `yaml:"nullable,omitempty" json:"nullable,omitempty"` // Unset accepts either
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// String describes the requirement, such as "public.customers.erased_at
+// timestamptz not null"
+func (r *Requirement) String() string {
+	s := r.Table
+	if r.Column != "" {
+		s += "." + r.Column
+	}
+	if r.Type != "" {
+		s += " " + r.Type
+	}
+	if r.Nullable != nil {
+		if *r.Nullable {
+			s += " null"
+		} else {
+			s += " not null"
+		}
+	}
+	return s
+}
+
+// validate checks the fields of the requirement
+func (r *Requirement) validate() error {
+	if r.Table == "" {
+		return errors.New("the table is required")
+	}
+	if _, err := path.Match(r.Table, ""); err != nil {
+		return fmt.Errorf("invalid table pattern %q", r.Table)
+	}
+	if r.Column == "" && (r.Type != "" || r.Nullable != nil) {
+		return fmt.Errorf("%s: type and nullable need a column", r.Table)
+	}
+	return nil
+}
+
+// Parse reads a YAML or JSON manifest
+func Parse(data []byte) ([]*Requirement, error) {
+	var manifest []*Requirement
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	return manifest, validate(manifest)
+}
+
+// csvFields are the columns a CSV manifest can have
+var csvFields = []string{"table", "column", "type", "nullable", "description"}
+
+// ParseCSV reads a CSV manifest. Its header names the fields of each
+// requirement, in any order; table is required. Lines starting with # are
+// comments.
+func ParseCSV(r io.Reader) ([]*Requirement, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	index := map[string]int{}
+	for i, field := range header {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf("unknown manifest column %q, expected %s", field, strings.Join(csvFields, ", "))
+		}
+		index[field] = i
+	}
+	if _, ok := index["table"]; !ok {
+		return nil, errors.New("the manifest has no table column")
+	}
+
+	var manifest []*Requirement
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		req := &Requirement{Table: field("table"), Column: field("column"), Type: field("type"), Description: field("description")}
+		if value := field("nullable"); value != "" {
+			nullable, err := strconv.ParseBool(value)
+			if err != nil {
+				line, _ := reader.FieldPos(index["nullable"])
+				return nil, fmt.Errorf("line %d: invalid nullable value %q", line, value)
+			}
+			req.Nullable = &nullable
+		}
+		manifest = append(manifest, req)
+	}
+	return manifest, validate(manifest)
+}
+
+// validate checks that manifest has valid requirements
+func validate(manifest []*Requirement) error {
+	if len(manifest) == 0 {
+		return errors.New("the manifest has no requirements")
+	}
+	for i, req := range manifest {
+		if err := req.validate(); err != nil {
+			return fmt.Errorf("requirement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// LoadFile reads the manifest at path, as CSV when its extension is .csv
+func LoadFile(path string) ([]*Requirement, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseCSV(f)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return Parse(data)
+}
+
+// Result is the outcome of a requirement on a table. A requirement with a
+// table pattern has one result per matching table.
+type Result struct {
+	Requirement *Requirement `json:"requirement"`
+	Table       string       `json:"table,omitempty"` // schema.table, empty when no table matches
+	Passed      bool         `json:"passed"`
+	Message     string       `json:"message,omitempty"` // Why the requirement failed
+}
+
+// Check verifies each requirement of manifest against info, in order
+func Check(manifest []*Requirement, info *dbinfo.DBInfo) []*Result {
+	var results []*Result
+	for _, req := range manifest {
+		var matched bool
+		for _, table := range info.Tables {
+			if !matchTable(req.Table, table) {
+				continue
+			}
+			matched = true
+			result := &Result{Requirement: req, Table: dbinfo.QualifiedName(table.Schema, table.Name)}
+			result.Message = checkTable(req, table)
+			result.Passed = result.Message == ""
+			results = append(results, result)
+		}
+		if !matched {
+			message := "table does not exist"
+			if strings.ContainsAny(req.Table, `*?[\`) {
+				message = "no table matches"
+			}
+			results = append(results, &Result{Requirement: req, Message: message})
+		}
+	}
+	return results
+}
+
+// Passed reports whether every requirement passed
+func Passed(results []*Result) bool {
+	for _, result := range results {
+		if !result.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// matchTable reports whether the pattern of a requirement matches table
+func matchTable(pattern string, table *dbinfo.Table) bool {
+	if schema, name, ok := strings.Cut(pattern, "."); ok {
+		schemaMatch, _ := path.Match(schema, table.Schema)
+		nameMatch, _ := path.Match(name, table.Name)
+		return schemaMatch && nameMatch
+	}
+	match, _ := path.Match(pattern, table.Name)
+	return match
+}
+
+// checkTable explains why table fails the requirement, or returns ""
+func checkTable(req *Requirement, table *dbinfo.Table) string {
+	if req.Column == "" {
+		return ""
+	}
+	var column *dbinfo.Column
+	for _, c := range table.Columns {
+		if c.Name == req.Column {
+			column = c
+		}
+	}
+	if column == nil {
+		return fmt.Sprintf("column %s does not exist", req.Column)
+	}
+	var problems []string
+	if req.Type != "" && !typeMatches(req.Type, column) {
+		problems = append(problems, fmt.Sprintf("type is %s, not %s", columnType(column), req.Type))
+	}
+	if req.Nullable != nil && column.IsNullable != *req.Nullable {
+		if column.IsNullable {
+			problems = append(problems, "column is nullable")
+		} else {
+			problems = append(problems, "column is not nullable")
+		}
+	}
+	return strings.Join(problems, "; ")
+}
+
+// columnType returns the formatted type of column, falling back to its
+// type name in snapshots without one
+func columnType(column *dbinfo.Column) string {
+	if column.FullType != "" {
+		return column.FullType
+	}
+	return column.Type
+}
+
+// typeAliases maps the short type names PostgreSQL accepts to the names it
+// prints
+var typeAliases = map[string]string{
+	"int":         "integer",
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"serial":      "integer",
+	"bigserial":   "bigint",
+	"smallserial": "smallint",
+	"float4":      "real",
+	"float8":      "double precision",
+	"float":       "double precision",
+	"decimal":     "numeric",
+	"bool":        "boolean",
+	"varchar":     "character varying",
+	"char":        "character",
+	"bpchar":      "character",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+	"time":        "time without time zone",
+	"timetz":      "time with time zone",
+	"varbit":      "bit varying",
+}
+
+// normalizeType lowercases typ and replaces an alias with the name
+// PostgreSQL prints, keeping modifiers and array brackets
+func normalizeType(typ string) string {
+	typ = strings.Join(strings.Fields(strings.ToLower(typ)), " ")
+	base, rest := typ, ""
+	if i := strings.IndexAny(typ, "(["); i >= 0 {
+		base, rest = strings.TrimSpace(typ[:i]), typ[i:]
+	}
+	name, ok := typeAliases[base]
+	// timestamp(3) with time zone is a full name, not the timestamp alias
+	if !ok || strings.Contains(rest, " time zone") {
+		return typ
+	}
+	// PostgreSQL prints the precision of times before the time zone, as in
+	// timestamp(3) with time zone
+	if prefix, zone, found := strings.Cut(name, " with"); found && strings.HasPrefix(rest, "(") {
+		if end := strings.IndexByte(rest, ')'); end >= 0 {
+			return prefix + rest[:end+1] + " with" + zone + rest[end+1:]
+		}
+	}
+	return name + rest
+}
+
+// stripModifiers removes the modifiers of a formatted type: character
+// varying(20)[] becomes character varying[]
+func stripModifiers(typ string) string {
+	if i := strings.IndexByte(typ, '('); i >= 0 {
+		if j := strings.IndexByte(typ[i:], ')'); j >= 0 {
+			return typ[:i] + typ[i+j+1:]
+		}
+	}
+	return typ
+}
+
+// typeMatches reports whether column has the type required. A type with
+// modifiers, such as varchar(255), must match them; a type without accepts
+// any, so varchar matches character varying(255).
+func typeMatches(required string, column *dbinfo.Column) bool {
+	required = normalizeType(required)
+	full := normalizeType(columnType(column))
+	if required == full || required == strings.ToLower(column.Type) {
+		return true
+	}
+	return !strings.Contains(required, "(") && required == stripModifiers(full)
+}
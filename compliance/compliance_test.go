@@ -0,0 +1,220 @@
+package compliance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema: "public",
+				Name:   "customers",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer"},
+					{Name: "email", Type: "character varying", FullType: "character varying(255)"},
+					{Name: "erased_at", Type: "timestamp with time zone", FullType: "timestamp with time zone", IsNullable: true},
+					{Name: "created_at", Type: "timestamp without time zone", FullType: "timestamp(3) without time zone"},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", FullType: "bigint"},
+					{Name: "tags", Type: "ARRAY", FullType: "text[]"},
+				},
+			},
+			{
+				Schema: "audit",
+				Name:   "events",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", FullType: "bigint"},
+				},
+			},
+		},
+	}
+}
+
+const testManifest = `
+- table: public.customers
+  column: erased_at
+  type: timestamptz
+  nullable: true
+  description: GDPR erasure date
+- table: customers
+  column: email
+  type: varchar(100)
+  nullable: true
+- table: public.*
+  column: id
+- table: public.*
+  column: created_at
+  type: timestamp(3)
+- table: audit.retention_log
+  description: SOX retention log
+- table: archive.*
+`
+
+func TestCheck(t *testing.T) {
+	manifest, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := Check(manifest, testInfo())
+	var got []string
+	for _, r := range results {
+		got = append(got, r.status()+" "+r.object()+" "+r.Message)
+	}
+	want := []string{
+		"PASS public.customers.erased_at ",
+		"FAIL public.customers.email type is character varying(255), not varchar(100); column is not nullable",
+		"PASS public.customers.id ",
+		"PASS public.orders.id ",
+		"PASS public.customers.created_at ",
+		"FAIL public.orders.created_at column created_at does not exist",
+		"FAIL audit.retention_log table does not exist",
+		"FAIL archive.* no table matches",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("results (-want +got):\n%s", diff)
+	}
+	if Passed(results) {
+		t.Error("Expected the check to fail")
+	}
+	if !Passed(results[:1]) {
+		t.Error("Expected the first requirement to pass")
+	}
+}
+
+func TestTypeMatches(t *testing.T) {
+	tests := []struct {
+		required, typ, fullType string
+		want                    bool
+	}{
+		{"integer", "integer", "integer", true},
+		{"INT4", "integer", "integer", true},
+		{"int8", "integer", "integer", false},
+		{"varchar", "character varying", "character varying(20)", true},
+		{"character varying(20)", "character varying", "character varying(20)", true},
+		{"varchar(30)", "character varying", "character varying(20)", false},
+		{"timestamptz", "timestamp with time zone", "timestamp with time zone", true},
+		{"timestamp", "timestamp with time zone", "timestamp with time zone", false},
+		{"timestamptz(3)", "timestamp with time zone", "timestamp(3) with time zone", true},
+		{"text[]", "ARRAY", "text[]", true},
+		{"varchar[]", "ARRAY", "character varying(20)[]", true},
+		{"order_status", "USER-DEFINED", "order_status", true},
+		{"numeric", "numeric", "", true},
+	}
+	for _, tt := range tests {
+		column := &dbinfo.Column{Type: tt.typ, FullType: tt.fullType}
+		if got := typeMatches(tt.required, column); got != tt.want {
+			t.Errorf("typeMatches(%q, %q) = %v, want %v", tt.required, tt.fullType, got, tt.want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	manifest, err := ParseCSV(strings.NewReader(`# Retention fields
+column,table,nullable,type,description
+erased_at, public.customers, true, timestamptz, "GDPR erasure, right to be forgotten"
+,audit.retention_log,,,
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nullable := true
+	want := []*Requirement{
+		{Table: "public.customers", Column: "erased_at", Type: "timestamptz", Nullable: &nullable, Description: "GDPR erasure, right to be forgotten"},
+		{Table: "audit.retention_log"},
+	}
+	if diff := cmp.Diff(want, manifest); diff != "" {
+		t.Errorf("manifest (-want +got):\n%s", diff)
+	}
+
+	for input, want := range map[string]string{
+		"table,owner\nx,y\n":                 `unknown manifest column "owner", expected table, column, type, nullable, description`,
+		"column\nid\n":                       "the manifest has no table column",
+		"table,column,nullable\nt,c,maybe\n": `line 2: invalid nullable value "maybe"`,
+		"table,type\nt,integer\n":            "requirement 1: t: type and nullable need a column",
+		"table\n":                            "the manifest has no requirements",
+		"table,column\npublic.[,id\n":        `requirement 1: invalid table pattern "public.["`,
+	} {
+		if _, err := ParseCSV(strings.NewReader(input)); err == nil || err.Error() != want {
+			t.Errorf("ParseCSV(%q) error = %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "manifest.CSV")
+	if err := os.WriteFile(csvPath, []byte("table\npublic.orders\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	yamlPath := filepath.Join(dir, "manifest.yaml")
+	if err := os.WriteFile(yamlPath, []byte("- table: public.orders\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{csvPath, yamlPath} {
+		manifest, err := LoadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(manifest) != 1 || manifest[0].Table != "public.orders" {
+			t.Errorf("LoadFile(%s) = %v", filepath.Base(path), manifest)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	manifest, err := Parse([]byte(testManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := Check(manifest, testInfo())
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"PASS  public.customers.erased_at   GDPR erasure date\n",
+		"FAIL  audit.retention_log          table does not exist\n",
+		"8 checks, 4 failed\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the text output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, "shop", results); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Schema compliance of shop\n",
+		"**Failed**: 4 of the 8 checks of the manifest failed.\n",
+		"| PASS | public.customers.erased_at | public.customers.erased_at timestamptz null | GDPR erasure date | ok |\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the report:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected an empty JSON array, got %q", buf.String())
+	}
+}
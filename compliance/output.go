@@ -0,0 +1,95 @@
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// status returns PASS or FAIL
+func (r *Result) status() string {
+	if r.Passed {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// object returns the table or column the result is about
+func (r *Result) object() string {
+	object := r.Table
+	if object == "" {
+		object = r.Requirement.Table
+	}
+	if r.Requirement.Column != "" {
+		object += "." + r.Requirement.Column
+	}
+	return object
+}
+
+// detail returns why the result failed, or the description of its
+// requirement when it passed
+func (r *Result) detail() string {
+	if !r.Passed {
+		return r.Message
+	}
+	return r.Requirement.Description
+}
+
+// WriteText writes one aligned line per result to w, followed by a summary
+func WriteText(w io.Writer, results []*Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.status(), r.object(), r.detail())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d checks, %d failed\n", len(results), failed)
+	return err
+}
+
+// WriteJSON writes the results to w as a JSON array
+func WriteJSON(w io.Writer, results []*Result) error {
+	if results == nil {
+		results = []*Result{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// WriteMarkdown writes an audit report of the results for the database
+// called name to w: the outcome followed by every check
+func WriteMarkdown(w io.Writer, name string, results []*Result) error {
+	fmt.Fprintf(w, "# Schema compliance of %s\n\n", name)
+	failed := 0
+	for _, r := range results {
+		if !r.Passed {
+			failed++
+		}
+	}
+	if failed == 0 {
+		fmt.Fprintf(w, "**Passed**: the %d checks of the manifest passed.\n\n", len(results))
+	} else {
+		fmt.Fprintf(w, "**Failed**: %d of the %d checks of the manifest failed.\n\n", failed, len(results))
+	}
+
+	escape := strings.NewReplacer("|", `\|`).Replace
+	fmt.Fprintln(w, "| Status | Object | Requirement | Description | Result |")
+	fmt.Fprintln(w, "|---|---|---|---|---|")
+	for _, r := range results {
+		result := "ok"
+		if !r.Passed {
+			result = r.Message
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", r.status(), escape(r.object()), escape(r.Requirement.String()), escape(r.Requirement.Description), escape(result))
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,394 @@
+// Package contract checks an OpenAPI document against the database it is
+// served from, catching API and database divergence early: properties with
+// no column, types that can't hold each other's values, nullable columns
+// behind required properties and strings longer than their column.
+//
+// The schemas of the document (components.schemas in OpenAPI 3, definitions
+// in Swagger 2) are matched to tables by name: Order or order_item match the
+// tables orders and order_items, in singular or plural form. Properties
+// match columns by name, camelCase names matching snake_case columns.
+// Extensions override the matching:
+//
+//	x-dbinfo-table: billing.invoices  # on a schema, the table it describes
+//	x-dbinfo-column: created          # on a property, the column it reads
+//	x-dbinfo-ignore: true             # on a schema or property, skip it
+//
+// Properties referencing other schemas are relationships, not columns, and
+// are skipped.
+package contract
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// Kind classifies mismatches
+type Kind string
+
+// Mismatch kinds
+const (
+	MissingTable  Kind = "missing-table"  // The schema names a table that doesn't exist
+	MissingColumn Kind = "missing-column" // A property has no column
+	TypeConflict  Kind = "type"           // The property and column types don't hold the same values
+	Nullable      Kind = "nullable"       // A required, non-nullable property reads a nullable column
+	Length        Kind = "length"         // The property allows longer strings than the column
+	Unmatched     Kind = "unmatched"      // No table matches the schema, reported with Options.Strict
+)
+
+// Mismatch is a divergence between a schema of the document and the
+// database
+type Mismatch struct {
+	Schema   string `json:"schema"`             // Name of the OpenAPI schema
+	Property string `json:"property,omitempty"` // Empty for mismatches of the whole schema
+	Table    string `json:"table,omitempty"`    // schema.table
+	Column   string `json:"column,omitempty"`
+	Kind     Kind   `json:"kind"`
+	Message  string `json:"message"`
+}
+
+// String formats the mismatch as Schema.property: message
+func (m *Mismatch) String() string {
+	location := m.Schema
+	if m.Property != "" {
+		location += "." + m.Property
+	}
+	return location + ": " + m.Message
+}
+
+// Spec holds the schemas of an OpenAPI document
+type Spec struct {
+	Schemas map[string]*Schema
+}
+
+// Schema is the subset of an OpenAPI schema object the checks use
+type Schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       types              `yaml:"type"`
+	Format     string             `yaml:"format"`
+	Nullable   bool               `yaml:"nullable"`
+	MaxLength  *int               `yaml:"maxLength"`
+	Properties map[string]*Schema `yaml:"properties"`
+	Required   []string           `yaml:"required"`
+	Items      *Schema            `yaml:"items"`
+	AllOf      []*Schema          `yaml:"allOf"`
+	Table      string             `yaml:"x-dbinfo-table"`
+	Column     string             `yaml:"x-dbinfo-column"`
+	Ignore     bool               `yaml:"x-dbinfo-ignore"`
+}
+
+// types holds the type of a schema, a single name in OpenAPI 3.0 and
+// Swagger 2, or a list that may include "null" in OpenAPI 3.1
+type types []string
+
+func (t *types) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = types{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// typeName returns the type of the schema other than "null", or "" when it
+// has none or several
+func (s *Schema) typeName() string {
+	var name string
+	for _, t := range s.Type {
+		if t == "null" {
+			continue
+		}
+		if name != "" {
+			return ""
+		}
+		name = t
+	}
+	return name
+}
+
+// nullable reports whether the schema allows null
+func (s *Schema) nullable() bool {
+	return s.Nullable || slices.Contains(s.Type, "null")
+}
+
+// Parse reads a YAML or JSON OpenAPI document
+func Parse(data []byte) (*Spec, error) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]*Schema `yaml:"schemas"`
+		} `yaml:"components"`
+		Definitions map[string]*Schema `yaml:"definitions"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	spec := &Spec{Schemas: doc.Components.Schemas}
+	if spec.Schemas == nil {
+		spec.Schemas = doc.Definitions
+	}
+	if len(spec.Schemas) == 0 {
+		return nil, fmt.Errorf("the OpenAPI document defines no schemas")
+	}
+	return spec, nil
+}
+
+// LoadFile reads the OpenAPI document at path
+func LoadFile(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+	}
+	return Parse(data)
+}
+
+// resolve follows a local reference to a schema of the document, returning
+// nil when it points elsewhere
+func (spec *Spec) resolve(s *Schema) *Schema {
+	for seen := 0; s != nil && s.Ref != "" && seen < len(spec.Schemas); seen++ {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		if !ok {
+			name, ok = strings.CutPrefix(s.Ref, "#/definitions/")
+		}
+		if !ok {
+			return nil
+		}
+		s = spec.Schemas[name]
+	}
+	return s
+}
+
+// properties collects the properties of a schema, and whether each is
+// required, merging those of its allOf members
+func (spec *Spec) properties(s *Schema, props map[string]*Schema, required map[string]bool, depth int) {
+	s = spec.resolve(s)
+	if s == nil || depth > len(spec.Schemas) {
+		return
+	}
+	for name, prop := range s.Properties {
+		props[name] = prop
+	}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	for _, member := range s.AllOf {
+		spec.properties(member, props, required, depth+1)
+	}
+}
+
+// Options configure Check
+type Options struct {
+	// Strict reports the schemas no table matches. They are skipped
+	// otherwise, as documents usually have schemas for errors, pages and
+	// requests alongside those of tables.
+	Strict bool
+}
+
+// Check compares the schemas of spec with the tables of info and returns the
+// mismatches, sorted by schema and property
+func Check(spec *Spec, info *dbinfo.DBInfo, opts Options) []*Mismatch {
+	names := make([]string, 0, len(spec.Schemas))
+	for name := range spec.Schemas {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var mismatches []*Mismatch
+	for _, name := range names {
+		schema := spec.Schemas[name]
+		// Schemas of scalars, such as enums, don't describe tables
+		if schema == nil || schema.Ignore || schema.Table == "" && len(schema.Properties) == 0 && len(schema.AllOf) == 0 {
+			continue
+		}
+		table, mismatch := findTable(info, name, schema.Table)
+		if mismatch != nil {
+			if mismatch.Kind == MissingTable || opts.Strict {
+				mismatches = append(mismatches, mismatch)
+			}
+			continue
+		}
+		mismatches = append(mismatches, spec.checkTable(name, schema, table)...)
+	}
+	return mismatches
+}
+
+// findTable returns the table described by the schema called name, named by
+// its x-dbinfo-table extension when set
+func findTable(info *dbinfo.DBInfo, name, explicit string) (*dbinfo.Table, *Mismatch) {
+	if explicit != "" {
+		schemaName, tableName, qualified := strings.Cut(explicit, ".")
+		if !qualified {
+			schemaName, tableName = "", explicit
+		}
+		var found []*dbinfo.Table
+		for _, table := range info.Tables {
+			if table.Name == tableName && (!qualified || table.Schema == schemaName) {
+				found = append(found, table)
+			}
+		}
+		switch len(found) {
+		case 1:
+			return found[0], nil
+		case 0:
+			return nil, &Mismatch{Schema: name, Table: explicit, Kind: MissingTable, Message: fmt.Sprintf("table %s does not exist", explicit)}
+		default:
+			return nil, &Mismatch{Schema: name, Table: explicit, Kind: MissingTable, Message: fmt.Sprintf("several schemas have a table %s, qualify it with its schema", explicit)}
+		}
+	}
+
+	snake := snakeCase(name)
+	var found []*dbinfo.Table
+	for _, table := range info.Tables {
+		if slices.Contains(singulars(table.Name), snake) {
+			found = append(found, table)
+		}
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	message := "no table matches the schema, name it with x-dbinfo-table"
+	if len(found) > 1 {
+		message = "several tables match the schema, name one with x-dbinfo-table"
+	}
+	return nil, &Mismatch{Schema: name, Kind: Unmatched, Message: message}
+}
+
+// checkTable compares the properties of a schema with the columns of its
+// table
+func (spec *Spec) checkTable(name string, schema *Schema, table *dbinfo.Table) []*Mismatch {
+	props := map[string]*Schema{}
+	required := map[string]bool{}
+	spec.properties(schema, props, required, 0)
+	propNames := make([]string, 0, len(props))
+	for propName := range props {
+		propNames = append(propNames, propName)
+	}
+	slices.Sort(propNames)
+
+	tableName := dbinfo.QualifiedName(table.Schema, table.Name)
+	var mismatches []*Mismatch
+	for _, propName := range propNames {
+		prop := props[propName]
+		if prop == nil || prop.Ignore || isRelationship(spec, prop) {
+			continue
+		}
+		report := func(column string, kind Kind, format string, args ...any) {
+			mismatches = append(mismatches, &Mismatch{
+				Schema:   name,
+				Property: propName,
+				Table:    tableName,
+				Column:   column,
+				Kind:     kind,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		column := findColumn(table, propName, prop.Column)
+		if column == nil {
+			if prop.Column != "" {
+				report(prop.Column, MissingColumn, "column %s.%s does not exist", tableName, prop.Column)
+			} else {
+				report("", MissingColumn, "no column of %s matches the property", tableName)
+			}
+			continue
+		}
+
+		// The property may be a reference to a schema such as an enum
+		resolved := spec.resolve(prop)
+		if resolved == nil {
+			continue
+		}
+		if problem := typeConflict(spec, resolved, column.Type, column.FullType); problem != "" {
+			report(column.Name, TypeConflict, "%s", problem)
+		}
+		if column.IsNullable && required[propName] && !resolved.nullable() && !prop.nullable() {
+			report(column.Name, Nullable, "column %s is nullable but the property is required and not nullable", column.Name)
+		}
+		if limit, ok := characterLength(column.FullType); ok && resolved.MaxLength != nil && *resolved.MaxLength > limit {
+			report(column.Name, Length, "maxLength %d exceeds the %d characters of column %s", *resolved.MaxLength, limit, column.Name)
+		}
+	}
+	return mismatches
+}
+
+// isRelationship reports whether a property embeds another object of the
+// document, or a list of them, rather than reading a column
+func isRelationship(spec *Spec, prop *Schema) bool {
+	if prop.Items != nil {
+		prop = prop.Items
+	}
+	if prop.Ref == "" && len(prop.AllOf) == 0 {
+		return false
+	}
+	if len(prop.AllOf) > 0 {
+		return true
+	}
+	target := spec.resolve(prop)
+	return target != nil && (target.typeName() == "object" || len(target.Properties) > 0 || len(target.AllOf) > 0)
+}
+
+// findColumn returns the column a property reads: the one named by its
+// x-dbinfo-column extension, or the one named like the property
+func findColumn(table *dbinfo.Table, name, explicit string) *dbinfo.Column {
+	candidates := []string{explicit}
+	if explicit == "" {
+		candidates = []string{name, snakeCase(name)}
+	}
+	for _, candidate := range candidates {
+		for _, column := range table.Columns {
+			if column.Name == candidate {
+				return column
+			}
+		}
+	}
+	return nil
+}
+
+// snakeCase converts a camelCase or PascalCase name to snake_case, keeping
+// acronyms together: HTTPRequest becomes http_request
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 && runes[i-1] != '_' && runes[i-1] != '-' {
+			previousLower := runes[i-1] >= 'a' && runes[i-1] <= 'z' || runes[i-1] >= '0' && runes[i-1] <= '9'
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			if previousLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		switch {
+		case upper:
+			b.WriteRune(r + 'a' - 'A')
+		case r == '-':
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// singulars returns the forms a table name can take as a schema name: the
+// name itself and the singulars it could be the regular plural of
+func singulars(table string) []string {
+	forms := []string{table}
+	if strings.HasSuffix(table, "ies") {
+		forms = append(forms, strings.TrimSuffix(table, "ies")+"y")
+	}
+	if strings.HasSuffix(table, "es") {
+		forms = append(forms, strings.TrimSuffix(table, "es"))
+	}
+	if strings.HasSuffix(table, "s") && !strings.HasSuffix(table, "ss") {
+		forms = append(forms, strings.TrimSuffix(table, "s"))
+	}
+	return forms
+}
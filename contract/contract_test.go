@@ -0,0 +1,248 @@
+package contract
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema: "public",
+				Name:   "customers",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer"},
+					{Name: "email", Type: "character varying", FullType: "character varying(100)"},
+					{Name: "nickname", Type: "text", FullType: "text", IsNullable: true},
+					{Name: "created_at", Type: "timestamp with time zone", FullType: "timestamp with time zone"},
+					{Name: "born_on", Type: "date", FullType: "date", IsNullable: true},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", FullType: "bigint"},
+					{Name: "customer_id", Type: "integer", FullType: "integer"},
+					{Name: "total", Type: "numeric", FullType: "numeric(10,2)"},
+					{Name: "status", Type: "USER-DEFINED", FullType: "order_status"},
+					{Name: "tags", Type: "ARRAY", FullType: "character varying(20)[]"},
+					{Name: "metadata", Type: "jsonb", FullType: "jsonb", IsNullable: true},
+				},
+			},
+			{
+				Schema: "billing",
+				Name:   "invoices",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "uuid", FullType: "uuid"},
+				},
+			},
+		},
+	}
+}
+
+const testSpec = `
+openapi: 3.1.0
+components:
+  schemas:
+    Customer:
+      type: object
+      required: [id, email, nickname]
+      properties:
+        id: {type: integer}
+        email: {type: string, maxLength: 255}
+        nickname: {type: string}
+        createdAt: {type: string, format: date-time}
+        bornOn: {type: string, format: date-time}
+        phone: {type: string}
+        orders:
+          type: array
+          items: {$ref: '#/components/schemas/Order'}
+    Order:
+      allOf:
+        - $ref: '#/components/schemas/Resource'
+        - type: object
+          required: [customerId, metadata]
+          properties:
+            customerId: {type: [integer, "null"]}
+            total: {type: string, format: decimal}
+            status: {$ref: '#/components/schemas/OrderStatus'}
+            tags:
+              type: array
+              items: {type: integer}
+            metadata: {type: object}
+            customer: {$ref: '#/components/schemas/Customer'}
+            internalNotes: {type: string, x-dbinfo-ignore: true}
+            placed: {type: string, x-dbinfo-column: placed_at}
+    Resource:
+      type: object
+      properties:
+        id: {type: string}
+    OrderStatus:
+      type: string
+      enum: [pending, shipped]
+    Invoice:
+      x-dbinfo-table: billing.invoices
+      properties:
+        id: {type: string, format: uuid}
+    Refund:
+      x-dbinfo-table: refunds
+      properties:
+        id: {type: integer}
+    Error:
+      type: object
+      properties:
+        message: {type: string}
+`
+
+func TestCheck(t *testing.T) {
+	spec, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range Check(spec, testInfo(), Options{}) {
+		got = append(got, string(m.Kind)+" "+m.String())
+	}
+	want := []string{
+		"type Customer.bornOn: property type string (date-time) doesn't match column type date",
+		"length Customer.email: maxLength 255 exceeds the 100 characters of column email",
+		"nullable Customer.nickname: column nickname is nullable but the property is required and not nullable",
+		"missing-column Customer.phone: no column of public.customers matches the property",
+		"nullable Order.metadata: column metadata is nullable but the property is required and not nullable",
+		"missing-column Order.placed: column public.orders.placed_at does not exist",
+		"type Order.tags: items: property type integer doesn't match column type character varying(20)",
+		"missing-table Refund: table refunds does not exist",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatches (-want +got):\n%s", diff)
+	}
+
+	// Strict mode reports the schemas no table matches
+	var unmatched []string
+	for _, m := range Check(spec, testInfo(), Options{Strict: true}) {
+		if m.Kind == Unmatched {
+			unmatched = append(unmatched, m.Schema)
+		}
+	}
+	if diff := cmp.Diff([]string{"Error", "Resource"}, unmatched); diff != "" {
+		t.Errorf("unmatched schemas (-want +got):\n%s", diff)
+	}
+}
+
+func TestTypeConflict(t *testing.T) {
+	tests := []struct {
+		prop, typ, fullType string
+		ok                  bool
+	}{
+		{"integer", "smallint", "smallint", true},
+		{"integer", "numeric", "numeric(10,2)", false},
+		{"number", "integer", "integer", true},
+		{"number", "text", "text", false},
+		{"string", "bigint", "bigint", true},
+		{"string", "integer", "integer", false},
+		{"string", "uuid", "uuid", true},
+		{"boolean", "boolean", "boolean", true},
+		{"boolean", "integer", "integer", false},
+		{"object", "jsonb", "jsonb", true},
+		{"object", "text", "text", false},
+		{"array", "jsonb", "jsonb", true},
+		{"array", "ARRAY", "integer[]", true},
+		{"array", "text", "text", false},
+		{"string", "USER-DEFINED", "mood", true},
+	}
+	for _, tt := range tests {
+		problem := typeConflict(&Spec{}, &Schema{Type: types{tt.prop}}, tt.typ, tt.fullType)
+		if ok := problem == ""; ok != tt.ok {
+			t.Errorf("typeConflict(%s, %s) = %q, want ok %v", tt.prop, tt.fullType, problem, tt.ok)
+		}
+	}
+
+	formats := []struct {
+		format, typ string
+		ok          bool
+	}{
+		{"date-time", "timestamp without time zone", true},
+		{"date-time", "text", true},
+		{"date", "timestamp with time zone", false},
+		{"uuid", "uuid", true},
+		{"uuid", "integer", false},
+		{"email", "text", true},
+	}
+	for _, tt := range formats {
+		problem := typeConflict(&Spec{}, &Schema{Type: types{"string"}, Format: tt.format}, tt.typ, tt.typ)
+		if ok := problem == ""; ok != tt.ok {
+			t.Errorf("typeConflict(string %s, %s) = %q, want ok %v", tt.format, tt.typ, problem, tt.ok)
+		}
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"Order":        "order",
+		"OrderItem":    "order_item",
+		"order_item":   "order_item",
+		"createdAt":    "created_at",
+		"HTTPRequest":  "http_request",
+		"addressLine2": "address_line2",
+		"line-items":   "line_items",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	spec, err := Parse([]byte(`{"swagger": "2.0", "definitions": {"Customer": {"type": "object", "properties": {"id": {"type": "integer"}}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Schemas) != 1 || spec.Schemas["Customer"].Properties["id"].typeName() != "integer" {
+		t.Errorf("Unexpected Swagger 2 schemas %+v", spec.Schemas)
+	}
+
+	if _, err := Parse([]byte("openapi: 3.0.0\npaths: {}\n")); err == nil {
+		t.Error("Expected an error for a document without schemas")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	spec, err := Parse([]byte(testSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatches := Check(spec, testInfo(), Options{})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, mismatches); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Customer.phone     missing-column  no column of public.customers matches the property\n") {
+		t.Errorf("Unexpected text output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "[]\n" {
+		t.Errorf("Expected an empty JSON array, got %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, "shop", mismatches); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# API contract of shop\n", "8 mismatches in 3 schemas.\n", "## Customer\n\nTable `public.customers`.\n", "| phone |  | no column of public.customers matches the property |\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the report:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteText writes one aligned line per mismatch to w
+func WriteText(w io.Writer, mismatches []*Mismatch) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, m := range mismatches {
+		location := m.Schema
+		if m.Property != "" {
+			location += "." + m.Property
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", location, m.Kind, m.Message)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the mismatches to w as a JSON array
+func WriteJSON(w io.Writer, mismatches []*Mismatch) error {
+	if mismatches == nil {
+		mismatches = []*Mismatch{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(mismatches)
+}
+
+// WriteMarkdown writes a report of the mismatches between the OpenAPI
+// document and the database called name to w, grouped by schema
+func WriteMarkdown(w io.Writer, name string, mismatches []*Mismatch) error {
+	fmt.Fprintf(w, "# API contract of %s\n\n", name)
+	if len(mismatches) == 0 {
+		_, err := fmt.Fprintln(w, "The OpenAPI schemas match the database.")
+		return err
+	}
+
+	var schemas []string
+	bySchema := map[string][]*Mismatch{}
+	for _, m := range mismatches {
+		if _, ok := bySchema[m.Schema]; !ok {
+			schemas = append(schemas, m.Schema)
+		}
+		bySchema[m.Schema] = append(bySchema[m.Schema], m)
+	}
+	fmt.Fprintf(w, "%d mismatches in %d schemas.\n", len(mismatches), len(schemas))
+
+	for _, schema := range schemas {
+		fmt.Fprintf(w, "\n## %s\n\n", schema)
+		if table := bySchema[schema][0].Table; table != "" {
+			fmt.Fprintf(w, "Table `%s`.\n\n", table)
+		}
+		fmt.Fprintln(w, "| Property | Column | Mismatch |")
+		fmt.Fprintln(w, "|---|---|---|")
+		for _, m := range bySchema[schema] {
+			fmt.Fprintf(w, "| %s | %s | %s |\n", m.Property, m.Column, strings.ReplaceAll(m.Message, "|", `\|`))
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,148 @@
+package contract
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column kinds, named after the OpenAPI type or string format holding their
+// values
+const (
+	kindInteger  = "integer"
+	kindNumber   = "number"
+	kindBoolean  = "boolean"
+	kindString   = "string"
+	kindDate     = "date"
+	kindDateTime = "date-time"
+	kindTime     = "time"
+	kindUUID     = "uuid"
+	kindJSON     = "json"
+	kindArray    = "array"
+)
+
+// columnKinds maps column types, as information_schema names them, to their
+// kind. Types missing from it, such as enums and domains, aren't checked.
+var columnKinds = map[string]string{
+	"smallint":                    kindInteger,
+	"integer":                     kindInteger,
+	"bigint":                      kindInteger,
+	"numeric":                     kindNumber,
+	"real":                        kindNumber,
+	"double precision":            kindNumber,
+	"money":                       kindNumber,
+	"boolean":                     kindBoolean,
+	"text":                        kindString,
+	"character varying":           kindString,
+	"character":                   kindString,
+	"name":                        kindString,
+	"bytea":                       kindString,
+	"inet":                        kindString,
+	"cidr":                        kindString,
+	"macaddr":                     kindString,
+	"macaddr8":                    kindString,
+	"interval":                    kindString,
+	"xml":                         kindString,
+	"bit":                         kindString,
+	"bit varying":                 kindString,
+	"date":                        kindDate,
+	"timestamp without time zone": kindDateTime,
+	"timestamp with time zone":    kindDateTime,
+	"time without time zone":      kindTime,
+	"time with time zone":         kindTime,
+	"uuid":                        kindUUID,
+	"json":                        kindJSON,
+	"jsonb":                       kindJSON,
+	"ARRAY":                       kindArray,
+}
+
+// temporalFormats are the string formats that only fit columns of their own
+// kind or plain strings
+var temporalFormats = map[string]bool{kindDate: true, kindDateTime: true, kindTime: true, kindUUID: true}
+
+// typeConflict explains why a property of type prop can't hold the values
+// of a column, or returns "" when it can. typ is the information_schema type
+// of the column and fullType its formatted type, used for arrays.
+func typeConflict(spec *Spec, prop *Schema, typ, fullType string) string {
+	kind, known := columnKinds[typ]
+	if !known && strings.HasSuffix(fullType, "[]") {
+		kind, known = kindArray, true
+	}
+	propType := prop.typeName()
+	if !known || propType == "" || kind == kindJSON {
+		return ""
+	}
+
+	ok := true
+	switch propType {
+	case "integer":
+		ok = kind == kindInteger
+	case "number":
+		ok = kind == kindInteger || kind == kindNumber
+	case "boolean":
+		ok = kind == kindBoolean
+	case "string":
+		// Large integers and decimals are often sent as strings, keeping
+		// their precision
+		switch {
+		case kind == kindNumber, typ == "bigint":
+		case kind == kindInteger, kind == kindBoolean, kind == kindArray:
+			ok = false
+		case temporalFormats[prop.Format]:
+			ok = kind == prop.Format || kind == kindString
+		}
+	case "array":
+		if kind != kindArray {
+			ok = false
+			break
+		}
+		if items := spec.resolve(prop.Items); items != nil && fullType != "" {
+			element := strings.TrimSuffix(fullType, "[]")
+			if problem := typeConflict(spec, items, elementType(element), element); problem != "" {
+				return "items: " + problem
+			}
+		}
+	case "object":
+		ok = false
+	}
+	if ok {
+		return ""
+	}
+
+	described := propType
+	if prop.Format != "" {
+		described += " (" + prop.Format + ")"
+	}
+	columnType := fullType
+	if columnType == "" {
+		columnType = typ
+	}
+	return fmt.Sprintf("property type %s doesn't match column type %s", described, columnType)
+}
+
+// elementType returns the type of array elements as information_schema
+// would name it, from their formatted type: character varying(20) becomes
+// character varying
+func elementType(fullType string) string {
+	if i := strings.IndexByte(fullType, '('); i >= 0 {
+		rest := fullType[i:]
+		fullType = fullType[:i]
+		// Keep words following the modifiers, as in timestamp(3) with time zone
+		if j := strings.IndexByte(rest, ')'); j >= 0 {
+			fullType += rest[j+1:]
+		}
+	}
+	return strings.TrimSpace(fullType)
+}
+
+// characterLength returns the maximum length of a character varying(n) or
+// character(n) column
+func characterLength(fullType string) (int, bool) {
+	for _, prefix := range []string{"character varying(", "character("} {
+		if rest, ok := strings.CutPrefix(fullType, prefix); ok {
+			n, err := strconv.Atoi(strings.TrimSuffix(rest, ")"))
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
@@ -1,12 +1,25 @@
 package dbinfo
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/guillermo/dbinfo/internal/fakedb"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 func TestGetDBInfo(t *testing.T) {
@@ -624,31 +637,14 @@ func TestGetDBInfoStructure(t *testing.T) {
 		},
 	}
 
-	// Ensure all expected tables have initialized slices
-	for _, table := range expected.Tables {
-		if table.HasMany == nil {
-			table.HasMany = []*Relationship{}
-		}
-		if table.BelongsTo == nil {
-			table.BelongsTo = []*Relationship{}
-		}
-	}
-
-	// Ensure all actual tables have initialized slices (not nil)
-	for _, table := range actual.Tables {
-		if table.HasMany == nil {
-			table.HasMany = []*Relationship{}
-		}
-		if table.BelongsTo == nil {
-			table.BelongsTo = []*Relationship{}
-		}
-	}
+	// GetDBInfo returns empty slices, not nil
+	expected.EnsureSlices()
 
 	// Options for comparison
 	opts := []cmp.Option{
 		// Ignore fields that can vary or aren't relevant for structure comparison
-		cmpopts.IgnoreFields(DBInfo{}, "Name"),
-		cmpopts.IgnoreFields(Table{}, "Columns", "Indexes", "ForeignKeys", "Comment"),
+		cmpopts.IgnoreFields(DBInfo{}, "Name", "Generator", "Capabilities"),
+		cmpopts.IgnoreFields(Table{}, "Columns", "PrimaryKey", "Indexes", "ForeignKeys", "Comment"),
 		cmpopts.IgnoreFields(Relationship{}, "ForeignKey", "OnUpdate"),
 
 		// Only compare the tables we've defined in our expected structure
@@ -681,3 +677,2107 @@ func TestGetDBInfoStructure(t *testing.T) {
 		t.Errorf("Unexpected database structure (-expected +actual):\n%s", diff)
 	}
 }
+
+func TestStreamTables(t *testing.T) {
+	// Get connection string from environment variable
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+
+	// Create connection pool
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	var names []string
+	err = StreamTables(ctx, pool, func(table *Table) error {
+		if len(table.Columns) == 0 {
+			t.Errorf("Expected streamed table %s to have columns", table.Name)
+		}
+		if len(table.HasMany) != 0 || len(table.BelongsTo) != 0 {
+			t.Errorf("Expected streamed table %s to have no relationships", table.Name)
+		}
+		names = append(names, table.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream tables: %v", err)
+	}
+
+	expected := []string{"categories", "customers", "order_items", "orders", "products"}
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Errorf("Unexpected streamed tables (-expected +actual):\n%s", diff)
+	}
+
+	// Errors returned by the callback stop the scan
+	stop := errors.New("stop")
+	count := 0
+	err = StreamTables(ctx, pool, func(table *Table) error {
+		count++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected callback error to be returned, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected scan to stop after 1 table, got %d", count)
+	}
+}
+
+// testSchema creates a schema of its own for a test, runs ddl in it and
+// drops it when the test ends. It skips the test without a database.
+func testSchema(t *testing.T, schema, ddl string) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	if _, err := pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+schema+" CASCADE; CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("Failed to create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+	})
+	if _, err := pool.Exec(ctx, ddl); err != nil {
+		t.Fatalf("Failed to create test tables: %v", err)
+	}
+	return pool
+}
+
+func TestCompositeForeignKeys(t *testing.T) {
+	pool := testSchema(t, "dbinfo_fk_test", `
+	CREATE TABLE dbinfo_fk_test.accounts (
+	    tenant_id integer,
+	    region text,
+	    id integer,
+	    PRIMARY KEY (tenant_id, region, id),
+	    UNIQUE (id, tenant_id)
+	);
+	CREATE TABLE dbinfo_fk_test.payments (
+	    id integer PRIMARY KEY,
+	    account integer,
+	    tenant integer,
+	    region_code text,
+	    CONSTRAINT payments_account_fkey FOREIGN KEY (tenant, region_code, account)
+	        REFERENCES dbinfo_fk_test.accounts (tenant_id, region, id) ON DELETE CASCADE,
+	    CONSTRAINT payments_account_id_fkey FOREIGN KEY (account, tenant)
+	        REFERENCES dbinfo_fk_test.accounts (id, tenant_id) ON UPDATE SET NULL
+	);`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_fk_test"), WithTables("payments"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 1 {
+		t.Fatalf("Expected only the payments table, got %d tables", len(info.Tables))
+	}
+
+	expected := []*ForeignKey{
+		{
+			Name:           "payments_account_fkey",
+			ColumnNames:    []string{"tenant", "region_code", "account"},
+			RefTableSchema: "dbinfo_fk_test",
+			RefTableName:   "accounts",
+			RefColumnNames: []string{"tenant_id", "region", "id"},
+			OnUpdate:       "NO ACTION",
+			OnDelete:       "CASCADE",
+		},
+		{
+			Name:           "payments_account_id_fkey",
+			ColumnNames:    []string{"account", "tenant"},
+			RefTableSchema: "dbinfo_fk_test",
+			RefTableName:   "accounts",
+			RefColumnNames: []string{"id", "tenant_id"},
+			OnUpdate:       "SET NULL",
+			OnDelete:       "NO ACTION",
+		},
+	}
+	if diff := cmp.Diff(expected, info.Tables[0].ForeignKeys); diff != "" {
+		t.Errorf("Unexpected foreign keys (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestNullsNotDistinct(t *testing.T) {
+	pool := testSchema(t, "dbinfo_nulls_test", `
+	CREATE TABLE dbinfo_nulls_test.users (id integer PRIMARY KEY, email text, phone text);
+	CREATE UNIQUE INDEX users_phone_key ON dbinfo_nulls_test.users (phone);`)
+
+	var version int
+	if err := pool.QueryRow(context.Background(), "SELECT current_setting('server_version_num')::int").Scan(&version); err != nil {
+		t.Fatalf("Failed to get server version: %v", err)
+	}
+	if version >= 150000 {
+		if _, err := pool.Exec(context.Background(), "CREATE UNIQUE INDEX users_email_key ON dbinfo_nulls_test.users (email) NULLS NOT DISTINCT"); err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+	}
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_nulls_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	expected := map[string]bool{"users_phone_key": false}
+	if version >= 150000 {
+		expected["users_email_key"] = true
+	}
+	actual := make(map[string]bool)
+	for _, index := range info.Tables[0].Indexes {
+		actual[index.Name] = index.NullsNotDistinct
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Unexpected NULLS NOT DISTINCT flags (-expected +actual):\n%s", diff)
+	}
+	if info.Capabilities.ServerVersion != version || slices.Contains(info.Capabilities.Collected, FacetNullsNotDistinct) != (version >= 150000) {
+		t.Errorf("Unexpected capabilities %+v on server version %d", info.Capabilities, version)
+	}
+}
+
+func TestMultilineComments(t *testing.T) {
+	pool := testSchema(t, "dbinfo_comments_test", `
+	CREATE TABLE dbinfo_comments_test.notes (body text);
+	COMMENT ON TABLE dbinfo_comments_test.notes IS E'Line one\nline two: ''quoted'' # not YAML\n';
+	COMMENT ON COLUMN dbinfo_comments_test.notes.body IS E'\tÜnïcödé 表 🎉\r\n- "item"';`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_comments_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	table := info.Tables[0]
+	if expected := "Line one\nline two: 'quoted' # not YAML\n"; table.Comment != expected {
+		t.Errorf("Expected table comment %q, got %q", expected, table.Comment)
+	}
+	if expected := "\tÜnïcödé 表 🎉\r\n- \"item\""; table.Columns[0].Comment != expected {
+		t.Errorf("Expected column comment %q, got %q", expected, table.Columns[0].Comment)
+	}
+}
+
+func TestPrimaryKeyOrder(t *testing.T) {
+	pool := testSchema(t, "dbinfo_pk_test", `
+	CREATE TABLE dbinfo_pk_test.memberships (
+	    user_id integer,
+	    team_id integer,
+	    role text,
+	    CONSTRAINT memberships_team_user PRIMARY KEY (team_id, user_id)
+	);
+	CREATE TABLE dbinfo_pk_test.notes (body text);`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_pk_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 2 {
+		t.Fatalf("Expected 2 tables, got %d", len(info.Tables))
+	}
+
+	expected := &PrimaryKey{Name: "memberships_team_user", Columns: []string{"team_id", "user_id"}}
+	if diff := cmp.Diff(expected, info.Tables[0].PrimaryKey); diff != "" {
+		t.Errorf("Unexpected primary key (-expected +actual):\n%s", diff)
+	}
+	if info.Tables[1].PrimaryKey != nil {
+		t.Errorf("Expected notes to have no primary key, got %v", info.Tables[1].PrimaryKey)
+	}
+}
+
+func TestColumnKinds(t *testing.T) {
+	pool := testSchema(t, "dbinfo_kind_test", `
+	CREATE SEQUENCE dbinfo_kind_test.shared_seq;
+	CREATE TABLE dbinfo_kind_test.events (
+	    id serial,
+	    seq bigint GENERATED ALWAYS AS IDENTITY,
+	    version integer GENERATED BY DEFAULT AS IDENTITY,
+	    ticket integer DEFAULT nextval('dbinfo_kind_test.shared_seq'),
+	    status text DEFAULT 'new',
+	    body text
+	);`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_kind_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+
+	type kind struct {
+		Kind          ColumnKind
+		Identity      string
+		AutoIncrement bool
+	}
+	expected := map[string]kind{
+		"id":      {ColumnSerial, "", true},
+		"seq":     {ColumnIdentity, "ALWAYS", true},
+		"version": {ColumnIdentity, "BY DEFAULT", true},
+		// A sequence the column doesn't own is just a default
+		"ticket": {ColumnDefault, "", false},
+		"status": {ColumnDefault, "", false},
+		"body":   {ColumnPlain, "", false},
+	}
+	actual := make(map[string]kind)
+	for _, column := range info.Tables[0].Columns {
+		actual[column.Name] = kind{column.Kind, column.Identity, column.AutoIncrement()}
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Unexpected column kinds (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestDuplicateConstraintNames(t *testing.T) {
+	// Both schemas use the same table and constraint names, referencing
+	// different tables
+	pool := testSchema(t, "dbinfo_dup_a", `
+	CREATE SCHEMA dbinfo_dup_b;
+	CREATE TABLE dbinfo_dup_a.users (id integer CONSTRAINT users_pkey PRIMARY KEY);
+	CREATE TABLE dbinfo_dup_b.teams (code text CONSTRAINT teams_pkey PRIMARY KEY);
+	CREATE TABLE dbinfo_dup_a.items (
+	    id integer CONSTRAINT items_pkey PRIMARY KEY,
+	    owner integer CONSTRAINT items_owner_fkey REFERENCES dbinfo_dup_a.users (id)
+	);
+	CREATE TABLE dbinfo_dup_b.items (
+	    id integer,
+	    owner text CONSTRAINT items_owner_fkey REFERENCES dbinfo_dup_b.teams (code) ON DELETE CASCADE,
+	    CONSTRAINT items_pkey PRIMARY KEY (owner, id)
+	);`)
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS dbinfo_dup_b CASCADE")
+	})
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_dup_a", "dbinfo_dup_b"), WithTables("items"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 2 {
+		t.Fatalf("Expected both items tables, got %d tables", len(info.Tables))
+	}
+
+	for _, table := range info.Tables {
+		if len(table.ForeignKeys) != 1 {
+			t.Errorf("Expected one foreign key in %s.items, got %v", table.Schema, table.ForeignKeys)
+			continue
+		}
+		fk := table.ForeignKeys[0]
+		expectedRef := map[string]string{"dbinfo_dup_a": "users", "dbinfo_dup_b": "teams"}[table.Schema]
+		if fk.RefTableSchema != table.Schema || fk.RefTableName != expectedRef {
+			t.Errorf("Expected %s.items to reference %s.%s, got %s.%s", table.Schema, table.Schema, expectedRef, fk.RefTableSchema, fk.RefTableName)
+		}
+
+		var pk []string
+		for _, column := range table.Columns {
+			if column.IsPrimaryKey {
+				pk = append(pk, column.Name)
+			}
+		}
+		expectedPK := map[string][]string{"dbinfo_dup_a": {"id"}, "dbinfo_dup_b": {"id", "owner"}}[table.Schema]
+		if diff := cmp.Diff(expectedPK, pk); diff != "" {
+			t.Errorf("Unexpected primary key of %s.items (-expected +actual):\n%s", table.Schema, diff)
+		}
+	}
+}
+
+func TestIndexColumnOrder(t *testing.T) {
+	// Columns are created in the opposite order of the index keys, so
+	// sorting keys by column position would be caught
+	pool := testSchema(t, "dbinfo_index_test", `
+	CREATE TABLE dbinfo_index_test.events (
+	    id integer PRIMARY KEY,
+	    created_at timestamptz,
+	    kind text,
+	    tenant_id integer
+	);
+	CREATE INDEX events_tenant_created ON dbinfo_index_test.events (tenant_id, created_at);
+	CREATE UNIQUE INDEX events_tenant_kind_created ON dbinfo_index_test.events (tenant_id, kind, created_at);
+	CREATE INDEX events_created_tenant ON dbinfo_index_test.events (created_at, tenant_id);`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_index_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 1 {
+		t.Fatalf("Expected only the events table, got %d tables", len(info.Tables))
+	}
+
+	expected := []*Index{
+		{Name: "events_created_tenant", Columns: []string{"created_at", "tenant_id"}},
+		{Name: "events_tenant_created", Columns: []string{"tenant_id", "created_at"}},
+		{Name: "events_tenant_kind_created", Unique: true, Columns: []string{"tenant_id", "kind", "created_at"}},
+	}
+	if diff := cmp.Diff(expected, info.Tables[0].Indexes, cmpopts.IgnoreFields(Index{}, "Keys")); diff != "" {
+		t.Errorf("Unexpected indexes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestExpressionIndexKeys(t *testing.T) {
+	pool := testSchema(t, "dbinfo_expression_test", `
+	CREATE TABLE dbinfo_expression_test.users (
+	    id integer PRIMARY KEY,
+	    email text,
+	    tenant_id integer
+	);
+	CREATE INDEX users_tenant_email ON dbinfo_expression_test.users (tenant_id, lower(email), id);`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_expression_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 1 {
+		t.Fatalf("Expected only the users table, got %d tables", len(info.Tables))
+	}
+
+	expected := []*Index{{
+		Name:       "users_tenant_email",
+		Columns:    []string{"tenant_id", "id"},
+		Expression: "lower(email)",
+		Keys:       []*IndexKey{{Column: "tenant_id"}, {Expression: "lower(email)"}, {Column: "id"}},
+	}}
+	if diff := cmp.Diff(expected, info.Tables[0].Indexes); diff != "" {
+		t.Errorf("Unexpected indexes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGetDBInfoSchemaFilters(t *testing.T) {
+	// Get connection string from environment variable
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+
+	// Create connection pool
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	included, err := GetDBInfo(ctx, pool, WithSchemas("public"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(included.Tables) < 5 {
+		t.Errorf("Expected at least 5 tables in public, got %d", len(included.Tables))
+	}
+	for _, table := range included.Tables {
+		if table.Schema != "public" {
+			t.Errorf("Expected only public tables, got %s.%s", table.Schema, table.Name)
+		}
+	}
+
+	excluded, err := GetDBInfo(ctx, pool, WithSchemas("public"), WithExcludedSchemas("public"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(excluded.Tables) != 0 {
+		t.Errorf("Expected excluded schemas to win over included ones, got %d tables", len(excluded.Tables))
+	}
+}
+
+func TestProjectionOptions(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	info, err := GetDBInfo(ctx, pool, WithSchemas("public"), WithoutIndexes(), WithoutRelationships(), WithoutComments())
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	foreignKeys := 0
+	for _, table := range info.Tables {
+		foreignKeys += len(table.ForeignKeys)
+		if len(table.Indexes) > 0 || table.HasMany != nil || table.BelongsTo != nil || table.Comment != "" {
+			t.Errorf("Expected %s to have no indexes, relationships or comment", table.Name)
+		}
+		for _, column := range table.Columns {
+			if column.Comment != "" {
+				t.Errorf("Expected %s.%s to have no comment", table.Name, column.Name)
+			}
+		}
+	}
+	if foreignKeys == 0 {
+		t.Error("Expected foreign keys to be kept without relationships")
+	}
+
+	info, err = GetDBInfo(ctx, pool, WithSchemas("public"), WithColumnsOnly())
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	for _, table := range info.Tables {
+		if len(table.Columns) == 0 || len(table.Indexes) > 0 || len(table.ForeignKeys) > 0 || table.BelongsTo != nil {
+			t.Errorf("Expected %s to have only columns", table.Name)
+		}
+	}
+}
+
+func TestTableFilters(t *testing.T) {
+	o := newOptions([]Option{
+		WithTables("billing.*", "users"),
+		WithExcludedTables("*_audit"),
+	})
+	if err := o.validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+
+	tests := []struct {
+		schema, name string
+		expected     bool
+	}{
+		{"billing", "invoices", true},
+		{"billing", "invoices_audit", false},
+		{"public", "users", true},
+		{"auth", "users", true},
+		{"public", "orders", false},
+	}
+	for _, test := range tests {
+		if got := o.includeTable(test.schema, test.name); got != test.expected {
+			t.Errorf("includeTable(%s.%s) = %v, expected %v", test.schema, test.name, got, test.expected)
+		}
+	}
+
+	if err := newOptions([]Option{WithTables("[")}).validate(); err == nil {
+		t.Error("Expected malformed patterns to be rejected")
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	version = "v1.2.3"
+	commit = "abc123"
+	defer func() { version, commit = "", "" }()
+
+	if generator := Generator(); generator != "dbinfo v1.2.3 (abc123)" {
+		t.Errorf("Unexpected generator %q", generator)
+	}
+}
+
+// deadlineQuerier records the context of the last query
+type deadlineQuerier struct {
+	ctx context.Context
+}
+
+func (q *deadlineQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	q.ctx = ctx
+	return &closedRows{}, nil
+}
+
+func (q *deadlineQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	q.ctx = ctx
+	return nil
+}
+
+// closedRows is an empty result set
+type closedRows struct {
+	pgx.Rows
+}
+
+func (*closedRows) Close() {}
+
+func (*closedRows) Err() error { return nil }
+
+func TestQueryError(t *testing.T) {
+	cause := errors.New("permission denied")
+	db := fakedb.New(serverVersion(170000), &fakedb.Result{SQL: tablesQuery, Err: cause})
+	err := StreamTables(context.Background(), db, func(*Table) error { return nil })
+
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) {
+		t.Fatalf("Expected a *QueryError, got %v", err)
+	}
+	if queryErr.Phase != PhaseTables || queryErr.Object != "" {
+		t.Errorf("Unexpected phase %q and object %q", queryErr.Phase, queryErr.Object)
+	}
+	if !errors.Is(err, cause) || err.Error() != "failed to query tables: permission denied" {
+		t.Errorf("Expected the query error to wrap its cause, got %v", err)
+	}
+}
+
+// tableRow is a row of tablesQuery for a table without a comment, a primary
+// key or a parent
+func tableRow(schema, name string) []any {
+	return []any{schema, name, nil, nil, []string{}, nil, nil, nil}
+}
+
+// serverVersion answers the server version query with version, in the
+// format of server_version_num
+func serverVersion(version int) *fakedb.Result {
+	return &fakedb.Result{SQL: serverVersionQuery, Rows: [][]any{{version}}}
+}
+
+// emptyTables answers the per table queries, and the view queries, with
+// no rows
+func emptyTables() []*fakedb.Result {
+	return []*fakedb.Result{
+		{SQL: columnsQuery},
+		{SQL: indexesQuery},
+		{SQL: foreignKeysQuery},
+		{SQL: viewsQuery},
+		{SQL: viewColumnsQuery},
+		{SQL: viewSourcesQuery},
+		{SQL: sequencesQuery},
+		{SQL: domainsQuery},
+		{SQL: foreignTablesQuery},
+		{SQL: foreignColumnOptionsQuery},
+		{SQL: rulesQuery},
+		{SQL: eventTriggersQuery},
+	}
+}
+
+func TestCatalogRows(t *testing.T) {
+	db := fakedb.New(
+		&fakedb.Result{SQL: databaseQuery, Rows: [][]any{{"shop", "UTF8", "en_US.UTF-8", "en_US.UTF-8"}}},
+		serverVersion(170000),
+		&fakedb.Result{SQL: tablesQuery, Rows: [][]any{
+			{"public", "orders", "Customer orders", "orders_pkey", []string{"id"}, nil, nil, nil},
+			{"public", "users", nil, "users_pkey", []string{"id"}, nil, nil, nil},
+		}},
+		&fakedb.Result{SQL: columnsQuery, Args: []any{"public", "orders"}, Rows: [][]any{
+			{"id", "integer", "integer", false, "nextval('orders_id_seq'::regclass)", nil, true, "", true, nil, nil, nil},
+			{"user_id", "integer", "integer", false, nil, nil, false, "", false, nil, nil, nil},
+			{"status", "text", "text", true, "'new'::text", "Order status", false, "", false, nil, nil, `"C"`},
+		}},
+		&fakedb.Result{SQL: columnsQuery, Args: []any{"public", "users"}, Rows: [][]any{
+			{"id", "bigint", "bigint", false, nil, nil, true, "a", false, nil, nil, nil},
+			{"email", "character varying", "email_address", false, nil, nil, false, "", false, "public", "email_address", nil},
+		}},
+		&fakedb.Result{SQL: indexesQuery, Args: []any{"public", "orders"}, Rows: [][]any{
+			{"orders_user_status", false, []string{"user_id", ""}, []string{"", "lower(status)"}, "lower(status)", false},
+		}},
+		&fakedb.Result{SQL: indexesQuery, Args: []any{"public", "users"}, Rows: [][]any{
+			{"users_email_key", true, []string{"email"}, []string{""}, nil, true},
+		}},
+		&fakedb.Result{SQL: foreignKeysQuery, Args: []any{"public", "orders"}, Rows: [][]any{
+			{"orders_user_id_fkey", []string{"user_id"}, "public", "users", []string{"id"}, "NO ACTION", "CASCADE"},
+		}},
+		&fakedb.Result{SQL: foreignKeysQuery, Args: []any{"public", "users"}},
+		&fakedb.Result{SQL: viewsQuery, Rows: [][]any{
+			{"public", "user_totals", true, false, " SELECT users.id,\n    count(orders.id) AS orders\n   FROM users JOIN orders ON orders.user_id = users.id\n  GROUP BY users.id;", "Orders per user", false},
+			{"public", "active_users", false, true, " SELECT users.id,\n    users.email\n   FROM users;", nil, true},
+		}},
+		&fakedb.Result{SQL: indexesQuery, Args: []any{"public", "user_totals"}, Rows: [][]any{
+			{"user_totals_id", true, []string{"id"}, []string{""}, nil, false},
+		}},
+		&fakedb.Result{SQL: viewColumnsQuery, Rows: [][]any{
+			{"public", "active_users", "id", "bigint", "bigint", nil},
+			{"public", "active_users", "email", "character varying", "character varying(255)", "Login"},
+			{"public", "user_totals", "id", "bigint", "bigint", nil},
+			{"public", "user_totals", "orders", "bigint", "bigint", nil},
+		}},
+		&fakedb.Result{SQL: viewSourcesQuery, Rows: [][]any{
+			{"public", "active_users", "public", "users", []string{"email", "id"}},
+			{"public", "user_totals", "public", "users", []string{"id"}},
+			{"public", "user_totals", "public", "orders", []string{"id", "user_id"}},
+		}},
+		&fakedb.Result{SQL: sequencesQuery, Rows: [][]any{
+			{"public", "invoice_numbers", "bigint", int64(1000), int64(1), int64(1), int64(math.MaxInt64), int64(20), true, nil, nil, nil, nil, nil},
+			{"public", "orders_id_seq", "integer", int64(1), int64(1), int64(1), int64(math.MaxInt32), int64(1), false, int64(4012), "public", "orders", "id", false},
+			{"public", "users_id_seq", "bigint", int64(1), int64(1), int64(1), int64(math.MaxInt64), int64(1), false, int64(12), "public", "users", "id", true},
+		}},
+		&fakedb.Result{SQL: domainsQuery, Rows: [][]any{
+			{"public", "email_address", "character varying(255)", true, nil, "A mailbox", []string{"email_address_check"}, []string{"CHECK (((VALUE)::text ~~ '%@%'::text))"}},
+			{"public", "positive", "integer", false, "1", nil, []string{}, []string{}},
+		}},
+		&fakedb.Result{SQL: foreignTablesQuery, Rows: [][]any{
+			{"analytics", "events", "warehouse", "postgres_fdw", []string{"schema_name=public", "table_name=events"}, "Raw events"},
+		}},
+		&fakedb.Result{SQL: foreignColumnOptionsQuery, Rows: [][]any{
+			{"analytics", "events", "kind", []string{"column_name=event_type"}},
+		}},
+		&fakedb.Result{SQL: columnsQuery, Args: []any{"analytics", "events"}, Rows: [][]any{
+			{"id", "bigint", "bigint", false, nil, nil, false, "", false, nil, nil, nil},
+			{"kind", "text", "text", true, nil, nil, false, "", false, nil, nil, nil},
+		}},
+		&fakedb.Result{SQL: rulesQuery, Rows: [][]any{
+			{"public", "active_users", "active_users_insert", "INSERT", true, "O", "CREATE RULE active_users_insert AS\n    ON INSERT TO public.active_users DO INSTEAD  INSERT INTO users (email)\n  VALUES (new.email);", nil},
+			{"public", "users", "users_keep", "DELETE", true, "D", "CREATE RULE users_keep AS\n    ON DELETE TO public.users DO INSTEAD NOTHING;", "Soft deletes"},
+			{"public", "user_totals", "_ignored", "UPDATE", true, "O", "CREATE RULE _ignored AS\n    ON UPDATE TO public.user_totals DO INSTEAD NOTHING;", nil},
+		}},
+		&fakedb.Result{SQL: eventTriggersQuery, Rows: [][]any{
+			{"audit_ddl", "ddl_command_end", []string{"CREATE TABLE", "ALTER TABLE"}, "audit.log_ddl", "O", "Logs schema changes"},
+			{"block_drops", "sql_drop", []string{}, "public.block_drops", "D", nil},
+		}},
+	)
+
+	info, err := GetDBInfo(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+
+	expected := &DBInfo{
+		Name:      "shop",
+		Encoding:  "UTF8",
+		Collation: "en_US.UTF-8",
+		CType:     "en_US.UTF-8",
+		Tables: []*Table{
+			{
+				Name:   "orders",
+				Schema: "public",
+				Columns: []*Column{
+					{Name: "id", Type: "integer", FullType: "integer", DefaultValue: "nextval('orders_id_seq'::regclass)", NormalizedDefault: "nextval('orders_id_seq')", IsPrimaryKey: true, Kind: ColumnSerial},
+					{Name: "user_id", Type: "integer", FullType: "integer", Kind: ColumnPlain},
+					{Name: "status", Type: "text", FullType: "text", IsNullable: true, DefaultValue: "'new'::text", NormalizedDefault: "'new'", Comment: "Order status", Kind: ColumnDefault, Collation: `"C"`},
+				},
+				PrimaryKey: &PrimaryKey{Name: "orders_pkey", Columns: []string{"id"}},
+				Indexes: []*Index{{
+					Name:       "orders_user_status",
+					Columns:    []string{"user_id"},
+					Expression: "lower(status)",
+					Keys:       []*IndexKey{{Column: "user_id"}, {Expression: "lower(status)"}},
+				}},
+				ForeignKeys: []*ForeignKey{{
+					Name:           "orders_user_id_fkey",
+					ColumnNames:    []string{"user_id"},
+					RefTableSchema: "public",
+					RefTableName:   "users",
+					RefColumnNames: []string{"id"},
+					OnUpdate:       "NO ACTION",
+					OnDelete:       "CASCADE",
+				}},
+				Partitions: []*Partition{},
+				HasMany:    []*Relationship{},
+				BelongsTo:  []*Relationship{{Name: "user", Table: "users", Schema: "public", ForeignKey: "orders_user_id_fkey", Columns: []string{"user_id"}, References: []string{"id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE"}},
+				Comment:    "Customer orders",
+			},
+			{
+				Name:   "users",
+				Schema: "public",
+				Columns: []*Column{
+					{Name: "id", Type: "bigint", FullType: "bigint", IsPrimaryKey: true, Kind: ColumnIdentity, Identity: "ALWAYS"},
+					{Name: "email", Type: "character varying", FullType: "email_address", Kind: ColumnPlain, Domain: &DomainRef{Schema: "public", Name: "email_address"}},
+				},
+				PrimaryKey:  &PrimaryKey{Name: "users_pkey", Columns: []string{"id"}},
+				Indexes:     []*Index{{Name: "users_email_key", Unique: true, Columns: []string{"email"}, Keys: []*IndexKey{{Column: "email"}}, NullsNotDistinct: true}},
+				ForeignKeys: []*ForeignKey{},
+				Partitions:  []*Partition{},
+				HasMany:     []*Relationship{{Name: "orders", Table: "orders", Schema: "public", ForeignKey: "orders_user_id_fkey", Columns: []string{"id"}, References: []string{"user_id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE"}},
+				BelongsTo:   []*Relationship{},
+				Rules: []*Rule{{Name: "users_keep", Event: "DELETE", Instead: true, Enabled: "disabled",
+					Definition: "CREATE RULE users_keep AS\n    ON DELETE TO public.users DO INSTEAD NOTHING", Comment: "Soft deletes"}},
+			},
+		},
+		Views: []*View{
+			{
+				Name:       "active_users",
+				Schema:     "public",
+				Definition: "SELECT users.id,\n    users.email\n   FROM users;",
+				Columns: []*Column{
+					{Name: "id", Type: "bigint", FullType: "bigint", IsNullable: true, Kind: ColumnPlain},
+					{Name: "email", Type: "character varying", FullType: "character varying(255)", IsNullable: true, Comment: "Login", Kind: ColumnPlain},
+				},
+				Updatable: true,
+				Sources:   []*ViewSource{{Schema: "public", Name: "users", Columns: []string{"email", "id"}}},
+				Rules: []*Rule{{Name: "active_users_insert", Event: "INSERT", Instead: true, Enabled: "origin",
+					Definition: "CREATE RULE active_users_insert AS\n    ON INSERT TO public.active_users DO INSTEAD  INSERT INTO users (email)\n  VALUES (new.email)"}},
+			},
+		},
+		MaterializedViews: []*MaterializedView{
+			{
+				Name:       "user_totals",
+				Schema:     "public",
+				Definition: "SELECT users.id,\n    count(orders.id) AS orders\n   FROM users JOIN orders ON orders.user_id = users.id\n  GROUP BY users.id;",
+				Columns: []*Column{
+					{Name: "id", Type: "bigint", FullType: "bigint", IsNullable: true, Kind: ColumnPlain},
+					{Name: "orders", Type: "bigint", FullType: "bigint", IsNullable: true, Kind: ColumnPlain},
+				},
+				Indexes: []*Index{{Name: "user_totals_id", Unique: true, Columns: []string{"id"}, Keys: []*IndexKey{{Column: "id"}}}},
+				Comment: "Orders per user",
+				Sources: []*ViewSource{
+					{Schema: "public", Name: "orders", Columns: []string{"id", "user_id"}},
+					{Schema: "public", Name: "users", Columns: []string{"id"}},
+				},
+			},
+		},
+		Sequences: []*Sequence{
+			{Name: "invoice_numbers", Schema: "public", Type: "bigint", Start: 1000, Increment: 1, Min: 1, Max: math.MaxInt64, Cache: 20, Cycle: true},
+			{Name: "orders_id_seq", Schema: "public", Type: "integer", Start: 1, Increment: 1, Min: 1, Max: math.MaxInt32, Cache: 1, LastValue: ptr(int64(4012)), OwnedBy: &SequenceOwner{Schema: "public", Table: "orders", Column: "id"}},
+			{Name: "users_id_seq", Schema: "public", Type: "bigint", Start: 1, Increment: 1, Min: 1, Max: math.MaxInt64, Cache: 1, LastValue: ptr(int64(12)), OwnedBy: &SequenceOwner{Schema: "public", Table: "users", Column: "id", Identity: true}},
+		},
+		Domains: []*Domain{
+			{Name: "email_address", Schema: "public", Type: "character varying(255)", NotNull: true, Comment: "A mailbox",
+				Checks: []*DomainCheck{{Name: "email_address_check", Definition: "CHECK (((VALUE)::text ~~ '%@%'::text))"}}},
+			{Name: "positive", Schema: "public", Type: "integer", Default: "1", Checks: []*DomainCheck{}},
+		},
+		ForeignTables: []*ForeignTable{{
+			Name:    "events",
+			Schema:  "analytics",
+			Server:  "warehouse",
+			Wrapper: "postgres_fdw",
+			Options: map[string]string{"schema_name": "public", "table_name": "events"},
+			Columns: []*Column{
+				{Name: "id", Type: "bigint", FullType: "bigint", Kind: ColumnPlain},
+				{Name: "kind", Type: "text", FullType: "text", IsNullable: true, Kind: ColumnPlain},
+			},
+			ColumnOptions: map[string]map[string]string{"kind": {"column_name": "event_type"}},
+			Comment:       "Raw events",
+		}},
+		EventTriggers: []*EventTrigger{
+			{Name: "audit_ddl", Event: "ddl_command_end", Tags: []string{"CREATE TABLE", "ALTER TABLE"}, Function: "audit.log_ddl", Enabled: "origin", Comment: "Logs schema changes"},
+			{Name: "block_drops", Event: "sql_drop", Tags: []string{}, Function: "public.block_drops", Enabled: "disabled"},
+		},
+		Warnings: []*Warning{},
+		Capabilities: &Capabilities{
+			ServerVersion: 170000,
+			Collected:     []Facet{FacetColumns, FacetPrimaryKeys, FacetComments, FacetIndexes, FacetForeignKeys, FacetViews, FacetDomains, FacetForeignTables, FacetRules, FacetEventTriggers, FacetRelationships, FacetPartitions, FacetIdentity, FacetSequences, FacetNullsNotDistinct},
+			Unavailable:   []Facet{},
+		},
+	}
+	if diff := cmp.Diff(expected, info, cmpopts.IgnoreFields(DBInfo{}, "Generator")); diff != "" {
+		t.Errorf("Unexpected database info (-expected +actual):\n%s", diff)
+	}
+
+	// The projection options skip the queries of the parts left out
+	db.Queries = nil
+	if _, err := GetDBInfo(context.Background(), db, WithColumnsOnly(), WithoutComments()); err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	var queries []string
+	for _, query := range db.Queries {
+		switch query.SQL {
+		case columnsQuery:
+			queries = append(queries, fmt.Sprint("columns ", query.Args))
+		case tablesQuery:
+			queries = append(queries, fmt.Sprint("tables ", query.Args))
+		case databaseQuery:
+			queries = append(queries, "database")
+		case serverVersionQuery:
+			queries = append(queries, "version")
+		default:
+			queries = append(queries, "unexpected")
+		}
+	}
+	expectedQueries := []string{"database", "version", "tables [[] [] false]", "columns [public orders false]", "columns [public users false]"}
+	if diff := cmp.Diff(expectedQueries, queries); diff != "" {
+		t.Errorf("Unexpected queries (-expected +actual):\n%s", diff)
+	}
+}
+
+// ptr returns a pointer to value
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func TestSequenceUsed(t *testing.T) {
+	tests := []struct {
+		sequence *Sequence
+		used     float64
+	}{
+		{&Sequence{Min: 1, Max: 101, Increment: 1}, 0},
+		{&Sequence{Min: 1, Max: 101, Increment: 1, LastValue: ptr(int64(76))}, 0.75},
+		{&Sequence{Min: -100, Max: -1, Increment: -1, LastValue: ptr(int64(-100))}, 1},
+		{&Sequence{Min: math.MinInt64, Max: math.MaxInt64, Increment: 1, LastValue: ptr(int64(0))}, 0.5},
+	}
+	for _, test := range tests {
+		if used := test.sequence.Used(); math.Abs(used-test.used) > 1e-9 {
+			t.Errorf("Used() of %+v = %v, want %v", test.sequence, used, test.used)
+		}
+	}
+}
+
+func TestColumnDomain(t *testing.T) {
+	domain := &Domain{Schema: "public", Name: "price", Type: "numeric(10,2)", NotNull: true, Default: "0",
+		Checks: []*DomainCheck{{Name: "price_check", Definition: "CHECK ((VALUE >= (0)::numeric))"}}}
+	info := &DBInfo{Domains: []*Domain{domain}}
+
+	if got := info.ColumnDomain(&Column{Name: "total", Domain: &DomainRef{Schema: "public", Name: "price"}}); got != domain {
+		t.Errorf("Expected the price domain, got %+v", got)
+	}
+	if got := info.ColumnDomain(&Column{Name: "total"}); got != nil {
+		t.Errorf("Expected no domain for a plain column, got %+v", got)
+	}
+	if got := info.ColumnDomain(&Column{Name: "total", Domain: &DomainRef{Schema: "billing", Name: "price"}}); got != nil {
+		t.Errorf("Expected no domain outside of the snapshot, got %+v", got)
+	}
+	expected := []string{"DEFAULT 0", "NOT NULL", "CHECK ((VALUE >= (0)::numeric))"}
+	if diff := cmp.Diff(expected, domain.Constraints()); diff != "" {
+		t.Errorf("Unexpected constraints (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		version     int
+		opts        []Option
+		unavailable []Facet
+	}{
+		{170000, nil, []Facet{}},
+		{140000, nil, []Facet{FacetNullsNotDistinct}},
+		{140000, []Option{WithoutIndexes()}, []Facet{}},
+		{90600, nil, []Facet{FacetPartitions, FacetIdentity, FacetSequences, FacetNullsNotDistinct}},
+	}
+	for _, test := range tests {
+		capabilities := &Capabilities{ServerVersion: test.version}
+		queries := capabilities.queries()
+		for facet, column := range map[Facet]string{
+			FacetPartitions:       "relispartition",
+			FacetIdentity:         "attidentity",
+			FacetNullsNotDistinct: "indnullsnotdistinct",
+		} {
+			read := strings.Contains(queries.tables+queries.columns+queries.indexes, column)
+			if read != capabilities.Supports(facet) {
+				t.Errorf("Version %d: expected %s to be read only when supported", test.version, column)
+			}
+		}
+
+		// Older servers are sent the queries without the columns they lack
+		db := fakedb.New(
+			&fakedb.Result{SQL: databaseQuery, Rows: [][]any{{"legacy", "UTF8", "en_US.UTF-8", "en_US.UTF-8"}}},
+			serverVersion(test.version),
+			&fakedb.Result{SQL: queries.tables, Rows: [][]any{tableRow("public", "users")}},
+			&fakedb.Result{SQL: queries.columns},
+			&fakedb.Result{SQL: queries.indexes},
+			&fakedb.Result{SQL: foreignKeysQuery},
+			&fakedb.Result{SQL: viewsQuery},
+			&fakedb.Result{SQL: viewColumnsQuery},
+			&fakedb.Result{SQL: viewSourcesQuery},
+			&fakedb.Result{SQL: sequencesQuery},
+			&fakedb.Result{SQL: domainsQuery},
+			&fakedb.Result{SQL: foreignTablesQuery},
+			&fakedb.Result{SQL: foreignColumnOptionsQuery},
+			&fakedb.Result{SQL: rulesQuery},
+			&fakedb.Result{SQL: eventTriggersQuery},
+		)
+		info, err := GetDBInfo(context.Background(), db, test.opts...)
+		if err != nil {
+			t.Fatalf("Version %d: failed to get database info: %v", test.version, err)
+		}
+		if diff := cmp.Diff(test.unavailable, info.Capabilities.Unavailable); diff != "" {
+			t.Errorf("Version %d: unexpected unavailable facets (-expected +actual):\n%s", test.version, diff)
+		}
+		for _, facet := range info.Capabilities.Collected {
+			if slices.Contains(test.unavailable, facet) {
+				t.Errorf("Version %d: %s is both collected and unavailable", test.version, facet)
+			}
+		}
+	}
+}
+
+func TestSkipUnreadableObjects(t *testing.T) {
+	denied := &pgconn.PgError{Severity: "ERROR", Code: "42501", Message: "permission denied for table secrets"}
+	db := fakedb.New(
+		&fakedb.Result{SQL: databaseQuery, Rows: [][]any{{"restricted", "UTF8", "en_US.UTF-8", "en_US.UTF-8"}}},
+		serverVersion(170000),
+		&fakedb.Result{SQL: tablesQuery, Rows: [][]any{tableRow("public", "orders"), tableRow("public", "secrets"), tableRow("public", "users")}},
+		&fakedb.Result{SQL: columnsQuery, Args: []any{"public", "secrets"}, Err: denied},
+		&fakedb.Result{SQL: foreignKeysQuery, Args: []any{"public", "users"}, Err: fmt.Errorf("failed to scan foreign key row: %w", denied)},
+		&fakedb.Result{SQL: viewSourcesQuery, Err: denied},
+	)
+	db.Add(emptyTables()...)
+
+	info, err := GetDBInfo(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Expected unreadable objects to be skipped, got %v", err)
+	}
+	var names []string
+	for _, table := range info.Tables {
+		names = append(names, table.Name)
+	}
+	if diff := cmp.Diff([]string{"orders", "users"}, names); diff != "" {
+		t.Errorf("Unexpected tables (-expected +actual):\n%s", diff)
+	}
+
+	expected := []*Warning{
+		{Phase: PhaseColumns, Object: "public.secrets", Message: "failed to query columns for public.secrets: ERROR: permission denied for table secrets (SQLSTATE 42501)"},
+		{Phase: PhaseForeignKeys, Object: "public.users", Message: "failed to query foreign keys for public.users: failed to scan foreign key row: ERROR: permission denied for table secrets (SQLSTATE 42501)"},
+		{Phase: PhaseViews, Message: "failed to query view sources: ERROR: permission denied for table secrets (SQLSTATE 42501)"},
+	}
+	if diff := cmp.Diff(expected, info.Warnings); diff != "" {
+		t.Errorf("Unexpected warnings (-expected +actual):\n%s", diff)
+	}
+
+	// Other errors still stop the introspection
+	db.Results = append([]*fakedb.Result{{SQL: indexesQuery, Args: []any{"public", "orders"}, Err: context.DeadlineExceeded}}, db.Results...)
+	_, err = GetDBInfo(context.Background(), db)
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Phase != PhaseIndexes || queryErr.Object != "public.orders" {
+		t.Errorf("Expected the index query error of public.orders, got %v", err)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	db := &deadlineQuerier{}
+	if q := newOptions(nil).querier(db); q != db {
+		t.Error("Expected queries not to be wrapped without a statement timeout")
+	}
+
+	q := newOptions([]Option{WithStatementTimeout(time.Minute)}).querier(db)
+	rows, err := q.Query(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline, ok := db.ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Expected a deadline within a minute, got %v", deadline)
+	}
+	rows.Close()
+	if db.ctx.Err() == nil {
+		t.Error("Expected closing the rows to release the query context")
+	}
+
+	if err := newOptions([]Option{WithStatementTimeout(-time.Second)}).validate(); err == nil {
+		t.Error("Expected a negative statement timeout to be rejected")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	q := newOptions([]Option{WithLogger(logger)}).querier(&deadlineQuerier{})
+	rows, err := q.Query(context.Background(), "SELECT relname\n\tFROM pg_class\n\tWHERE relname = $1", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Expected queries to be logged once their rows are closed, got %q", buf.String())
+	}
+	rows.Close()
+	rows.Close()
+
+	output := buf.String()
+	if strings.Count(output, "msg=query") != 1 {
+		t.Errorf("Expected the query to be logged once, got %q", output)
+	}
+	if !strings.Contains(output, `sql="SELECT relname FROM pg_class WHERE relname = $1"`) || !strings.Contains(output, "args=[orders]") {
+		t.Errorf("Expected the compacted SQL and its arguments, got %q", output)
+	}
+}
+
+func TestSummarizeStats(t *testing.T) {
+	tables := []*TableStats{
+		{Schema: "public", Name: "orders", RowEstimate: 1000, TableBytes: 8192, IndexBytes: 4096, TotalBytes: 12288, LiveTuples: 900, DeadTuples: 100},
+		{Schema: "billing", Name: "invoices", RowEstimate: 10, TableBytes: 1024, TotalBytes: 1024},
+		{Schema: "public", Name: "customers", RowEstimate: 50, TableBytes: 2048, ToastBytes: 512, TotalBytes: 2560},
+	}
+	for _, table := range tables {
+		table.estimateBloat()
+	}
+	if tables[0].BloatRatio != 0.1 || tables[0].BloatBytes != 819 {
+		t.Errorf("Unexpected bloat estimate %v, %d bytes", tables[0].BloatRatio, tables[0].BloatBytes)
+	}
+
+	expected := []*SchemaStats{
+		{Schema: "billing", Tables: 1, RowEstimate: 10, TableBytes: 1024, TotalBytes: 1024},
+		{Schema: "public", Tables: 2, RowEstimate: 1050, TableBytes: 10240, IndexBytes: 4096, ToastBytes: 512, TotalBytes: 14848, BloatBytes: 819},
+	}
+	if diff := cmp.Diff(expected, SummarizeStats(tables)); diff != "" {
+		t.Errorf("Unexpected schema totals (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGetTableStats(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	stats, err := GetTableStats(ctx, pool, WithTables("public.orders"))
+	if err != nil {
+		t.Fatalf("Failed to get table statistics: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Name != "orders" {
+		t.Fatalf("Expected statistics for public.orders only, got %v", stats)
+	}
+	if stats[0].TotalBytes < stats[0].TableBytes+stats[0].IndexBytes {
+		t.Errorf("Expected the total size to include the table and its indexes, got %+v", stats[0])
+	}
+}
+
+func TestGetIndexStats(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	stats, err := GetIndexStats(ctx, pool, WithTables("public.orders"))
+	if err != nil {
+		t.Fatalf("Failed to get index statistics: %v", err)
+	}
+	if len(stats) == 0 {
+		t.Fatal("Expected statistics for the indexes of public.orders")
+	}
+	for _, s := range stats {
+		if s.Schema != "public" || s.Table != "orders" || s.Bytes <= 0 {
+			t.Errorf("Unexpected index statistics %+v", s)
+		}
+	}
+}
+
+func TestExpectedBloatSizes(t *testing.T) {
+	// 1000 rows of 20 bytes take 52 bytes each: 157 fit in a full page
+	if got, want := expectedTableBytes(1000, 20, 100), int64(7*8192); got != want {
+		t.Errorf("expectedTableBytes() = %d, want %d", got, want)
+	}
+	// Half full pages hold 78 rows
+	if got, want := expectedTableBytes(1000, 20, 50), int64(13*8192); got != want {
+		t.Errorf("expectedTableBytes() with fillfactor 50 = %d, want %d", got, want)
+	}
+	// 1000 entries of 20 bytes with 4 byte keys: 366 per 90% full page,
+	// after the metapage
+	if got, want := expectedIndexBytes(1000, 4, 90), int64(4*8192); got != want {
+		t.Errorf("expectedIndexBytes() = %d, want %d", got, want)
+	}
+
+	s := &BloatStats{Bytes: 1000}
+	s.setBloat(-10)
+	if s.BloatBytes != 0 || s.BloatRatio != 0 {
+		t.Errorf("Expected negative estimates to count as no bloat, got %+v", s)
+	}
+	s.setBloat(250)
+	if s.BloatBytes != 250 || s.BloatRatio != 0.25 {
+		t.Errorf("Expected 250 bytes of bloat, got %+v", s)
+	}
+
+	if _, err := ParseBloatMethod("vacuum"); err == nil {
+		t.Error("Expected an unknown bloat method to fail")
+	}
+}
+
+func TestGetBloatStats(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	if _, err := pool.Exec(ctx, "ANALYZE public.orders"); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := GetBloatStats(ctx, pool, BloatEstimate, WithTables("public.orders"))
+	if err != nil {
+		t.Fatalf("Failed to estimate bloat: %v", err)
+	}
+	if len(stats) == 0 || stats[0].Table != "orders" || stats[0].Index != "" {
+		t.Fatalf("Expected the estimate of public.orders first, got %v", stats)
+	}
+	for _, s := range stats {
+		if s.BloatBytes < 0 || s.BloatBytes > s.Bytes || s.Method != BloatEstimate {
+			t.Errorf("Unexpected bloat estimate %+v", s)
+		}
+	}
+
+	stats, err = GetBloatStats(ctx, pool, BloatPgstattuple, WithTables("public.orders"))
+	if errors.Is(err, ErrNoPgstattuple) {
+		return
+	}
+	if err != nil {
+		t.Fatalf("Failed to measure bloat: %v", err)
+	}
+	if len(stats) == 0 || stats[0].Method != BloatPgstattuple {
+		t.Errorf("Expected pgstattuple measures of public.orders, got %v", stats)
+	}
+}
+
+func TestGetTablePrivileges(t *testing.T) {
+	db := fakedb.New(&fakedb.Result{SQL: privilegesQuery, Rows: [][]any{
+		{"public", "users", "app", "SELECT", false},
+		{"public", "orders", "PUBLIC", "SELECT", false},
+		{"public", "orders", "admin", "UPDATE", true},
+		{"public", "orders", "admin", "DELETE", true},
+		{"public", "orders_audit", "auditor", "SELECT", false},
+	}})
+	privileges, err := GetTablePrivileges(context.Background(), db, WithExcludedTables("*_audit"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*TablePrivilege{
+		{Schema: "public", Table: "orders", Role: "PUBLIC", Privilege: "SELECT"},
+		{Schema: "public", Table: "orders", Role: "admin", Privilege: "DELETE", Grantable: true},
+		{Schema: "public", Table: "orders", Role: "admin", Privilege: "UPDATE", Grantable: true},
+		{Schema: "public", Table: "users", Role: "app", Privilege: "SELECT"},
+	}
+	if diff := cmp.Diff(expected, privileges); diff != "" {
+		t.Errorf("Unexpected privileges (-expected +actual):\n%s", diff)
+	}
+
+	cause := errors.New("permission denied")
+	_, err = GetTablePrivileges(context.Background(), fakedb.New(&fakedb.Result{SQL: privilegesQuery, Err: cause}))
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Phase != PhasePrivileges || !errors.Is(err, cause) {
+		t.Errorf("Expected a privileges QueryError, got %v", err)
+	}
+}
+
+func TestPrivileges(t *testing.T) {
+	db := fakedb.New(
+		&fakedb.Result{SQL: databaseQuery, Rows: [][]any{{"shop", "UTF8", "en_US.UTF-8", "en_US.UTF-8"}}},
+		serverVersion(170000),
+		&fakedb.Result{SQL: tablesQuery, Rows: [][]any{tableRow("public", "locked"), tableRow("public", "users")}},
+		&fakedb.Result{SQL: columnsQuery, Args: []any{"public", "users"}, Rows: [][]any{
+			{"id", "bigint", "bigint", false, nil, nil, true, "", false, nil, nil, nil},
+			{"email", "text", "text", false, nil, nil, false, "", false, nil, nil, nil},
+		}},
+		&fakedb.Result{SQL: viewsQuery, Rows: [][]any{
+			{"public", "active_users", false, true, "SELECT users.id FROM users;", nil, true},
+		}},
+		&fakedb.Result{SQL: ownersQuery, Rows: [][]any{
+			{"public", "users", "owner", "owner", "SELECT", false},
+			{"public", "users", "owner", "app", "UPDATE", false},
+			{"public", "users", "owner", "app", "SELECT", true},
+			{"public", "locked", "admin", nil, nil, nil},
+			{"public", "active_users", "reporting", "PUBLIC", "SELECT", false},
+			{"public", "orders_audit", "auditor", "auditor", "SELECT", false},
+		}},
+		&fakedb.Result{SQL: columnPrivilegesQuery, Rows: [][]any{
+			{"public", "users", "email", "support", "SELECT", false},
+			{"public", "users", "email", nil, "SELECT", false},
+		}},
+	)
+	db.Add(emptyTables()...)
+
+	info, err := GetDBInfo(context.Background(), db, WithPrivileges())
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	locked, users, view := info.Tables[0], info.Tables[1], info.Views[0]
+	if locked.Owner != "admin" || len(locked.Grants) != 0 {
+		t.Errorf("Expected locked to be owned by admin without grants, got %q %v", locked.Owner, locked.Grants)
+	}
+	expected := []*Grant{
+		{Role: "app", Privilege: "SELECT", Grantable: true},
+		{Role: "app", Privilege: "UPDATE"},
+		{Role: "owner", Privilege: "SELECT"},
+	}
+	if diff := cmp.Diff(expected, users.Grants); diff != "" || users.Owner != "owner" {
+		t.Errorf("Unexpected grants of users owned by %q (-expected +actual):\n%s", users.Owner, diff)
+	}
+	if diff := cmp.Diff([]*Grant{{Role: "support", Privilege: "SELECT"}}, users.Columns[1].Grants); diff != "" {
+		t.Errorf("Unexpected column grants (-expected +actual):\n%s", diff)
+	}
+	if view.Owner != "reporting" || len(view.Grants) != 1 || view.Grants[0].Role != PublicRole {
+		t.Errorf("Expected the view grants to PUBLIC, got %q %v", view.Owner, view.Grants)
+	}
+
+	// The model lists the privileges as GetTablePrivileges does
+	var privileges []string
+	for _, p := range info.TablePrivileges() {
+		privileges = append(privileges, p.Table+" "+p.Role+" "+p.Privilege)
+	}
+	expectedPrivileges := []string{"active_users PUBLIC SELECT", "users app SELECT", "users app UPDATE", "users owner SELECT"}
+	if diff := cmp.Diff(expectedPrivileges, privileges); diff != "" {
+		t.Errorf("Unexpected privileges (-expected +actual):\n%s", diff)
+	}
+
+	// Privileges are only read with the option, and unreadable ones are
+	// a warning
+	if info, err = GetDBInfo(context.Background(), db); err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if info.Tables[1].Owner != "" || info.Tables[1].Grants != nil {
+		t.Errorf("Expected no privileges without WithPrivileges, got %+v", info.Tables[1])
+	}
+	denied := &pgconn.PgError{Severity: "ERROR", Code: "42501", Message: "permission denied for function pg_get_userbyid"}
+	db.Results = append([]*fakedb.Result{{SQL: ownersQuery, Err: denied}}, db.Results...)
+	if info, err = GetDBInfo(context.Background(), db, WithPrivileges()); err != nil {
+		t.Fatalf("Expected unreadable privileges to be skipped, got %v", err)
+	}
+	if len(info.Warnings) != 1 || info.Warnings[0].Phase != PhasePrivileges {
+		t.Errorf("Expected a privileges warning, got %v", info.Warnings)
+	}
+}
+
+func TestGetTableDependents(t *testing.T) {
+	db := fakedb.New(&fakedb.Result{SQL: dependentsQuery, Args: []any{"public", "customers"}, Rows: [][]any{
+		{"trigger", "public", "customers_audit", "public.customers"},
+		{"view", "reports", "vip_customers", "public.active_customers"},
+		{"view", "public", "active_customers", "public.customers"},
+	}})
+	dependents, err := GetTableDependents(context.Background(), db, "public", "customers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*TableDependent{
+		{Kind: DependentView, Schema: "public", Name: "active_customers", On: "public.customers"},
+		{Kind: DependentView, Schema: "reports", Name: "vip_customers", On: "public.active_customers"},
+		{Kind: DependentTrigger, Schema: "public", Name: "customers_audit", On: "public.customers"},
+	}
+	if diff := cmp.Diff(expected, dependents); diff != "" {
+		t.Errorf("Unexpected dependents (-expected +actual):\n%s", diff)
+	}
+
+	cause := errors.New("canceling statement due to statement timeout")
+	_, err = GetTableDependents(context.Background(), fakedb.New(&fakedb.Result{SQL: dependentsQuery, Err: cause}), "public", "customers")
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Phase != PhaseDependents || queryErr.Object != "public.customers" || !errors.Is(err, cause) {
+		t.Errorf("Expected a dependents QueryError, got %v", err)
+	}
+}
+
+func TestWorkload(t *testing.T) {
+	db := fakedb.New(
+		&fakedb.Result{SQL: databaseQuery, Rows: [][]any{{"shop", "UTF8", "en_US.UTF-8", "en_US.UTF-8"}}},
+		serverVersion(170000),
+		&fakedb.Result{SQL: tablesQuery, Rows: [][]any{tableRow("public", "events"), tableRow("public", "orders"), tableRow("public", "users")}},
+		&fakedb.Result{SQL: tableWorkloadQuery, Rows: [][]any{
+			{"public", "events", int64(900), int64(45_000_000), int64(100), int64(5000), int64(0), int64(0)},
+			{"public", "orders", int64(10), int64(1000), int64(20000), int64(3000), int64(1000), int64(0)},
+			{"public", "users", int64(100), int64(100), int64(0), int64(10), int64(0), int64(0)},
+			{"audit", "log", int64(1), int64(1), int64(0), int64(0), int64(0), int64(0)},
+		}},
+		&fakedb.Result{SQL: statementsExtensionQuery, Rows: [][]any{{true}}},
+		&fakedb.Result{SQL: statementsQuery, Rows: [][]any{
+			{"SELECT * FROM orders JOIN users ON users.id = orders.user_id WHERE orders.id = $1", int64(700)},
+			{`INSERT INTO "orders" (id) VALUES ($1) -- events`, int64(50)},
+			{"SELECT 'events' FROM users", int64(3)},
+		}},
+	)
+	db.Add(emptyTables()...)
+
+	info, err := GetDBInfo(context.Background(), db, WithWorkload())
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	expected := []*Workload{
+		{SeqScans: 900, SeqRowsRead: 45_000_000, IndexScans: 100, RowsInserted: 5000, SeqScanRatio: 0.9, Hot: true, MissingIndex: true},
+		{SeqScans: 10, SeqRowsRead: 1000, IndexScans: 20000, RowsInserted: 3000, RowsUpdated: 1000, SeqScanRatio: 10.0 / 20010, Calls: 750, Hot: true},
+		{SeqScans: 100, SeqRowsRead: 100, RowsInserted: 10, SeqScanRatio: 1, Calls: 703},
+	}
+	var workloads []*Workload
+	for _, table := range info.Tables {
+		workloads = append(workloads, table.Workload)
+	}
+	if diff := cmp.Diff(expected, workloads); diff != "" {
+		t.Errorf("Unexpected workloads (-expected +actual):\n%s", diff)
+	}
+
+	// Without pg_stat_statements preloaded, only the statement calls are missing
+	notLoaded := &pgconn.PgError{Severity: "ERROR", Code: "55000", Message: "pg_stat_statements must be loaded via \"shared_preload_libraries\""}
+	db.Results = append([]*fakedb.Result{{SQL: statementsQuery, Err: notLoaded}}, db.Results...)
+	info, err = GetDBInfo(context.Background(), db, WithWorkload())
+	if err != nil {
+		t.Fatalf("Expected pg_stat_statements to be optional, got %v", err)
+	}
+	if w := info.Tables[1].Workload; w.Calls != 0 || !w.Hot {
+		t.Errorf("Expected the orders table to be hot without calls, got %+v", w)
+	}
+	if len(info.Warnings) != 1 || info.Warnings[0].Phase != PhaseWorkload {
+		t.Errorf("Expected a workload warning, got %v", info.Warnings)
+	}
+
+	// The workload is only read with the option
+	info, err = GetDBInfo(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if info.Tables[0].Workload != nil {
+		t.Errorf("Expected no workload without WithWorkload, got %+v", info.Tables[0].Workload)
+	}
+}
+
+func TestPartitionedWorkload(t *testing.T) {
+	tables := []*Table{{Schema: "public", Name: "events", Partitions: []*Partition{
+		{Schema: "public", Name: "events_2024", Partitions: []*Partition{{Schema: "public", Name: "events_2024_01"}}},
+		{Schema: "public", Name: "events_2025"},
+	}}}
+	db := fakedb.New(
+		&fakedb.Result{SQL: tableWorkloadQuery, Rows: [][]any{
+			{"public", "events_2024_01", int64(10), int64(100), int64(30), int64(5), int64(0), int64(0)},
+			{"public", "events_2025", int64(10), int64(200), int64(50), int64(7), int64(1), int64(0)},
+		}},
+		&fakedb.Result{SQL: statementsExtensionQuery, Rows: [][]any{{true}}},
+		&fakedb.Result{SQL: statementsQuery, Rows: [][]any{
+			{"SELECT * FROM events WHERE id = $1", int64(40)},
+			{"INSERT INTO events_2025 VALUES ($1)", int64(2)},
+		}},
+	)
+	if err := getWorkload(context.Background(), db, newOptions(nil), tables); err != nil {
+		t.Fatal(err)
+	}
+	expected := &Workload{SeqScans: 20, SeqRowsRead: 300, IndexScans: 80, RowsInserted: 12, RowsUpdated: 1, SeqScanRatio: 0.2, Calls: 42, Hot: true}
+	if diff := cmp.Diff(expected, tables[0].Workload); diff != "" {
+		t.Errorf("Unexpected workload of the partitioned table (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	db := fakedb.New(
+		&fakedb.Result{SQL: databaseQuery, Rows: [][]any{{"shop", "UTF8", "en_US.UTF-8", "en_US.UTF-8"}}},
+		serverVersion(170000),
+		&fakedb.Result{SQL: tablesQuery, Rows: [][]any{tableRow("public", "orders"), tableRow("public", "users")}},
+		&fakedb.Result{SQL: tableStatsQuery, Rows: [][]any{
+			{"public", "orders", int64(1200), int64(98304), int64(32768), int64(8192), int64(139264), int64(1190), int64(10), nil, nil},
+			{"public", "users", int64(0), int64(0), int64(8192), int64(0), int64(8192), int64(0), int64(0), nil, nil},
+			{"audit", "log", int64(5), int64(8192), int64(0), int64(0), int64(8192), int64(5), int64(0), nil, nil},
+		}},
+	)
+	db.Add(emptyTables()...)
+
+	info, err := GetDBInfo(context.Background(), db, WithStatistics())
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	expected := []*Statistics{
+		{RowEstimate: 1200, TotalBytes: 139264, TableBytes: 98304, IndexBytes: 32768, ToastBytes: 8192},
+		{TotalBytes: 8192, IndexBytes: 8192},
+	}
+	var statistics []*Statistics
+	for _, table := range info.Tables {
+		statistics = append(statistics, table.Statistics)
+	}
+	if diff := cmp.Diff(expected, statistics); diff != "" {
+		t.Errorf("Unexpected statistics (-expected +actual):\n%s", diff)
+	}
+	if stats := info.TableStats(); len(stats) != 2 || stats[0].Name != "orders" || stats[0].TotalBytes != 139264 {
+		t.Errorf("Unexpected table stats of the model %+v", stats)
+	}
+
+	// Rolled up partitions add up in their table
+	tables := []*Table{{Schema: "public", Name: "events", Partitions: []*Partition{
+		{Schema: "public", Name: "events_2024", Partitions: []*Partition{{Schema: "public", Name: "events_2024_01"}}},
+	}}}
+	db = fakedb.New(&fakedb.Result{SQL: tableStatsQuery, Rows: [][]any{
+		{"public", "events", int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), nil, nil},
+		{"public", "events_2024", int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), "public", "events"},
+		{"public", "events_2024_01", int64(100), int64(8192), int64(4096), int64(0), int64(12288), int64(90), int64(10), "public", "events_2024"},
+	}})
+	if err := getStatistics(context.Background(), db, newOptions(nil), tables); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&Statistics{RowEstimate: 100, TotalBytes: 12288, TableBytes: 8192, IndexBytes: 4096}, tables[0].Statistics); diff != "" {
+		t.Errorf("Unexpected statistics of the partitioned table (-expected +actual):\n%s", diff)
+	}
+	if n := tables[0].Clone(); n.Statistics == tables[0].Statistics || *n.Statistics != *tables[0].Statistics {
+		t.Errorf("Expected a deep copy of the statistics, got %+v", n.Statistics)
+	}
+}
+
+func TestRolledUpStats(t *testing.T) {
+	partition := func(name, parent string) []any {
+		return []any{"public", name, int64(100), int64(8192), int64(4096), int64(0), int64(12288), int64(90), int64(10), "public", parent}
+	}
+	db := fakedb.New(
+		&fakedb.Result{SQL: tableStatsQuery, Rows: [][]any{
+			{"public", "events", int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), int64(0), nil, nil},
+			partition("events_2024", "events"),
+			partition("events_2024_01", "events_2024"),
+			partition("events_2025", "events"),
+			{"public", "orders", int64(5), int64(8192), int64(0), int64(0), int64(8192), int64(5), int64(0), nil, nil},
+		}},
+		&fakedb.Result{SQL: indexStatsQuery, Rows: [][]any{
+			{"public", "events", "events_pkey", int64(0), int64(0), nil, nil, nil},
+			{"public", "events_2024_01", "events_2024_01_pkey", int64(1000), int64(3), "public", "events_2024", "events_2024_pkey"},
+			{"public", "events_2024", "events_2024_pkey", int64(0), int64(0), "public", "events", "events_pkey"},
+			{"public", "events_2025", "events_2025_pkey", int64(2000), int64(4), "public", "events", "events_pkey"},
+			{"public", "events_2025", "events_2025_note_idx", int64(500), int64(1), "public", "events", nil},
+		}},
+	)
+
+	// Partitions count in their table even when the filters leave them out
+	tables, err := GetTableStats(context.Background(), db, WithRolledUpPartitions(), WithTables("public.events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTables := []*TableStats{{Schema: "public", Name: "events", Partitions: 3, RowEstimate: 300, TableBytes: 3 * 8192, IndexBytes: 3 * 4096,
+		TotalBytes: 3 * 12288, LiveTuples: 270, DeadTuples: 30, BloatRatio: 0.1, BloatBytes: 3 * 819}}
+	if diff := cmp.Diff(expectedTables, tables); diff != "" {
+		t.Errorf("Unexpected rolled up tables (-expected +actual):\n%s", diff)
+	}
+	if tables, err = GetTableStats(context.Background(), db); err != nil || len(tables) != 5 {
+		t.Errorf("Expected every partition without WithRolledUpPartitions, got %v, %v", tables, err)
+	}
+
+	indexes, err := GetIndexStats(context.Background(), db, WithRolledUpPartitions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedIndexes := []*IndexStats{
+		{Schema: "public", Table: "events", Name: "events_2025_note_idx", Bytes: 500, Scans: 1},
+		{Schema: "public", Table: "events", Name: "events_pkey", Bytes: 3000, Scans: 7},
+	}
+	if diff := cmp.Diff(expectedIndexes, indexes); diff != "" {
+		t.Errorf("Unexpected rolled up indexes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestStatementNames(t *testing.T) {
+	names := statementNames(`SELECT o.id FROM "Order Items" o, public.users /* audit */ WHERE note = 'from carts' AND id > $1 -- skip
+	AND "say ""hi""" IS NULL`)
+	var got []string
+	for name := range names {
+		got = append(got, name)
+	}
+	slices.Sort(got)
+	expected := []string{"Order Items", "and", "from", "id", "is", "note", "null", "o", "public", "say \"hi\"", "select", "users", "where"}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("Unexpected names (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	queries, err := Explain(WithSchemas("public"), WithoutIndexes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var phases []Phase
+	for _, query := range queries {
+		phases = append(phases, query.Phase)
+		if strings.HasPrefix(query.SQL, "\n") || strings.Contains(query.SQL, "\n\tFROM") {
+			t.Errorf("Expected the %s query to be dedented, got %q", query.Phase, query.SQL)
+		}
+	}
+	expected := []Phase{PhaseDatabase, PhaseDatabase, PhaseTables, PhaseColumns, PhaseForeignKeys, PhaseViews, PhaseViews, PhaseViews, PhaseSequences, PhaseDomains, PhaseForeignTables, PhaseForeignTables, PhaseForeignTables, PhaseRules, PhaseEventTriggers}
+	if diff := cmp.Diff(expected, phases); diff != "" {
+		t.Errorf("Unexpected phases (-expected +actual):\n%s", diff)
+	}
+	if diff := cmp.Diff([]any{[]string{"public"}, []string{}, true}, queries[2].Args); diff != "" {
+		t.Errorf("Unexpected tables query arguments (-expected +actual):\n%s", diff)
+	}
+	if queries[2].PerTable || !queries[3].PerTable {
+		t.Error("Expected only the column, index and foreign key queries to run per table")
+	}
+}
+
+func TestOrdering(t *testing.T) {
+	customers := &Table{Schema: "public", Name: "customers"}
+	orders := &Table{
+		Schema: "public",
+		Name:   "orders",
+		Indexes: []*Index{
+			{Name: "orders_status"},
+			{Name: "orders_Date"},
+			{Name: "orders_customer"},
+		},
+		ForeignKeys: []*ForeignKey{
+			{Name: "orders_shipping_fkey", RefTableSchema: "public", RefTableName: "customers"},
+			{Name: "orders_billing_fkey", RefTableSchema: "public", RefTableName: "customers"},
+		},
+	}
+	invoices := &Table{
+		Schema:      "billing",
+		Name:        "invoices",
+		ForeignKeys: []*ForeignKey{{Name: "orders_billing_fkey", RefTableSchema: "public", RefTableName: "customers"}},
+	}
+	tables := []*Table{orders, customers, invoices, {Schema: "Public", Name: "audit"}}
+
+	sortTables(tables)
+	for _, table := range tables {
+		sortTable(table)
+	}
+	buildRelationships(tables)
+
+	var names []string
+	for _, table := range tables {
+		names = append(names, table.Schema+"."+table.Name)
+	}
+	// Byte order puts upper case first, whatever the database collation
+	if diff := cmp.Diff([]string{"Public.audit", "billing.invoices", "public.customers", "public.orders"}, names); diff != "" {
+		t.Errorf("Unexpected table order (-expected +actual):\n%s", diff)
+	}
+
+	var indexes []string
+	for _, index := range orders.Indexes {
+		indexes = append(indexes, index.Name)
+	}
+	if diff := cmp.Diff([]string{"orders_Date", "orders_customer", "orders_status"}, indexes); diff != "" {
+		t.Errorf("Unexpected index order (-expected +actual):\n%s", diff)
+	}
+	if orders.ForeignKeys[0].Name != "orders_billing_fkey" {
+		t.Errorf("Expected foreign keys sorted by name, got %s first", orders.ForeignKeys[0].Name)
+	}
+
+	var hasMany []string
+	for _, rel := range customers.HasMany {
+		hasMany = append(hasMany, rel.ForeignKey+" "+rel.Schema+"."+rel.Table)
+	}
+	expected := []string{"orders_billing_fkey billing.invoices", "orders_billing_fkey public.orders", "orders_shipping_fkey public.orders"}
+	if diff := cmp.Diff(expected, hasMany); diff != "" {
+		t.Errorf("Unexpected relationship order (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestRelationshipNames(t *testing.T) {
+	addresses := &Table{Schema: "public", Name: "addresses"}
+	orders := &Table{
+		Schema: "public",
+		Name:   "orders",
+		Columns: []*Column{
+			{Name: "billing_address_id"}, {Name: "shipping_address_id"}, {Name: "customer"}, {Name: "parentId"}, {Name: "addresses"},
+		},
+		ForeignKeys: []*ForeignKey{
+			{Name: "orders_billing_fkey", ColumnNames: []string{"billing_address_id"}, RefTableSchema: "public", RefTableName: "addresses", RefColumnNames: []string{"id"}},
+			{Name: "orders_shipping_fkey", ColumnNames: []string{"shipping_address_id"}, RefTableSchema: "public", RefTableName: "addresses", RefColumnNames: []string{"id"}},
+			{Name: "orders_customer_fkey", ColumnNames: []string{"customer"}, RefTableSchema: "public", RefTableName: "addresses", RefColumnNames: []string{"id"}},
+			{Name: "orders_parent_fkey", ColumnNames: []string{"parentId"}, RefTableSchema: "public", RefTableName: "orders", RefColumnNames: []string{"id"}},
+		},
+	}
+	buildRelationships([]*Table{addresses, orders})
+
+	names := func(relationships []*Relationship) []string {
+		var names []string
+		for _, r := range relationships {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+	// customer has no _id suffix, so it takes the table name, which is
+	// already a column of orders
+	if diff := cmp.Diff([]string{"billing_address", "addresses_2", "parent", "shipping_address"}, names(orders.BelongsTo)); diff != "" {
+		t.Errorf("Unexpected belongs to names (-expected +actual):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"billing_address_orders", "orders_customer_fkey_orders", "shipping_address_orders"}, names(addresses.HasMany)); diff != "" {
+		t.Errorf("Unexpected has many names (-expected +actual):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"orders"}, names(orders.HasMany)); diff != "" {
+		t.Errorf("Unexpected self reference names (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCrossSchemaRelationships(t *testing.T) {
+	// Every tenant schema has its own users table, and orders in each one
+	// reference either their own users or the shared ones
+	sharedUsers := &Table{Schema: "shared", Name: "users"}
+	acmeUsers := &Table{Schema: "acme", Name: "users"}
+	acmeOrders := &Table{
+		Schema: "acme",
+		Name:   "orders",
+		ForeignKeys: []*ForeignKey{
+			{Name: "orders_user_fkey", ColumnNames: []string{"user_id"}, RefTableSchema: "acme", RefTableName: "users", RefColumnNames: []string{"id"}},
+			{Name: "orders_owner_fkey", ColumnNames: []string{"owner_id"}, RefTableSchema: "shared", RefTableName: "users", RefColumnNames: []string{"id"}},
+		},
+	}
+	globexOrders := &Table{
+		Schema:      "globex",
+		Name:        "orders",
+		ForeignKeys: []*ForeignKey{{Name: "orders_user_fkey", ColumnNames: []string{"user_id"}, RefTableSchema: "globex", RefTableName: "users", RefColumnNames: []string{"id"}}},
+	}
+	// Names containing dots must not be confused with schema.table
+	dotted := &Table{Schema: "a.b", Name: "c"}
+	dottedRef := &Table{
+		Schema:      "a",
+		Name:        "b.d",
+		ForeignKeys: []*ForeignKey{{Name: "d_fkey", RefTableSchema: "a", RefTableName: "b.c"}},
+	}
+	tables := []*Table{sharedUsers, acmeUsers, acmeOrders, globexOrders, dotted, dottedRef}
+	buildRelationships(tables)
+
+	relationships := func(rels []*Relationship) []string {
+		names := []string{}
+		for _, rel := range rels {
+			names = append(names, rel.ForeignKey+" "+rel.Schema+"."+rel.Table)
+		}
+		return names
+	}
+	tests := []struct {
+		table     *Table
+		hasMany   []string
+		belongsTo []string
+	}{
+		{sharedUsers, []string{"orders_owner_fkey acme.orders"}, []string{}},
+		{acmeUsers, []string{"orders_user_fkey acme.orders"}, []string{}},
+		{acmeOrders, []string{}, []string{"orders_owner_fkey shared.users", "orders_user_fkey acme.users"}},
+		// globex.users is not in the model, so only the BelongsTo side exists
+		{globexOrders, []string{}, []string{"orders_user_fkey globex.users"}},
+		{dotted, []string{}, []string{}},
+		{dottedRef, []string{}, []string{"d_fkey a.b.c"}},
+	}
+	for _, tt := range tests {
+		name := tt.table.Schema + "." + tt.table.Name
+		if diff := cmp.Diff(tt.hasMany, relationships(tt.table.HasMany)); diff != "" {
+			t.Errorf("Unexpected HasMany of %s (-expected +actual):\n%s", name, diff)
+		}
+		if diff := cmp.Diff(tt.belongsTo, relationships(tt.table.BelongsTo)); diff != "" {
+			t.Errorf("Unexpected BelongsTo of %s (-expected +actual):\n%s", name, diff)
+		}
+	}
+}
+
+func TestCrossSchemaForeignKeys(t *testing.T) {
+	pool := testSchema(t, "dbinfo_tenant_a", `
+	CREATE SCHEMA dbinfo_tenant_b;
+	CREATE TABLE dbinfo_tenant_a.users (id integer PRIMARY KEY);
+	CREATE TABLE dbinfo_tenant_b.users (id integer PRIMARY KEY);
+	CREATE TABLE dbinfo_tenant_b.orders (
+	    id integer PRIMARY KEY,
+	    user_id integer REFERENCES dbinfo_tenant_a.users (id)
+	);`)
+	t.Cleanup(func() {
+		pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS dbinfo_tenant_b CASCADE")
+	})
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_tenant_a", "dbinfo_tenant_b"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+
+	tables := make(map[string]*Table)
+	for _, table := range info.Tables {
+		tables[table.Schema+"."+table.Name] = table
+	}
+	orders := tables["dbinfo_tenant_b.orders"]
+	if orders == nil || len(orders.BelongsTo) != 1 {
+		t.Fatalf("Expected dbinfo_tenant_b.orders to belong to one table, got %v", orders)
+	}
+	if rel := orders.BelongsTo[0]; rel.Schema != "dbinfo_tenant_a" || rel.Table != "users" {
+		t.Errorf("Expected orders to belong to dbinfo_tenant_a.users, got %s.%s", rel.Schema, rel.Table)
+	}
+	if len(tables["dbinfo_tenant_a.users"].HasMany) != 1 {
+		t.Errorf("Expected dbinfo_tenant_a.users to have many orders, got %v", tables["dbinfo_tenant_a.users"].HasMany)
+	}
+	if len(tables["dbinfo_tenant_b.users"].HasMany) != 0 {
+		t.Errorf("Expected dbinfo_tenant_b.users to have no relationships, got %v", tables["dbinfo_tenant_b.users"].HasMany)
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	tests := []struct {
+		expr, columnType, expected string
+	}{
+		{"'new'::text", "text", "'new'"},
+		{"'it''s'::character varying", "character varying(20)", "'it''s'"},
+		{"'{}'::text[]", "text[]", "'{}'"},
+		{"(-1)::integer", "integer", "-1"},
+		{"(0)::numeric", "numeric(10,2)", "0"},
+		{"'2020-01-01 00:00:00'::timestamp without time zone", "timestamp(3) without time zone", "'2020-01-01 00:00:00'"},
+		{"nextval('orders_id_seq'::regclass)", "integer", "nextval('orders_id_seq')"},
+		{`nextval('"Order_id_seq"'::regclass)`, "bigint", `nextval('"Order_id_seq"')`},
+		{"now()", "timestamp with time zone", "now()"},
+		{"42", "integer", "42"},
+		// Casts to another type change the value
+		{"'1.5'::numeric", "integer", "'1.5'::numeric"},
+		{"'a'::character", "character varying", "'a'::character"},
+		{"'2020-01-01'::timestamp without time zone", "timestamp with time zone", "'2020-01-01'::timestamp without time zone"},
+		{"('x'::text || 'y'::text)", "text", "('x' || 'y')"},
+		{`lower("it's"::text)`, "text", `lower("it's"::text)`},
+		{"", "text", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeDefault(tt.expr, tt.columnType); got != tt.expected {
+			t.Errorf("NormalizeDefault(%q, %q) = %q, expected %q", tt.expr, tt.columnType, got, tt.expected)
+		}
+	}
+}
+
+func TestRollUpPartitions(t *testing.T) {
+	events := &Table{Schema: "public", Name: "events"}
+	events2024 := &Table{Schema: "public", Name: "events_2024"}
+	events2024q1 := &Table{Schema: "public", Name: "events_2024_q1"}
+	events2025 := &Table{Schema: "public", Name: "events_2025"}
+	orphan := &Table{Schema: "public", Name: "logs_2024"}
+	users := &Table{Schema: "public", Name: "users"}
+	partitions := map[*Table]partitionOf{
+		events2024:   {parent: ObjectKey{"public", "events"}, bound: "FOR VALUES FROM (2024) TO (2025)"},
+		events2024q1: {parent: ObjectKey{"public", "events_2024"}, bound: "FOR VALUES FROM (1) TO (4)"},
+		events2025:   {parent: ObjectKey{"public", "events"}, bound: "FOR VALUES FROM (2025) TO (2026)"},
+		// The parent was filtered out
+		orphan: {parent: ObjectKey{"public", "logs"}, bound: "DEFAULT"},
+	}
+
+	tables := rollUpPartitions([]*Table{events, events2024, events2024q1, events2025, orphan, users}, partitions)
+	if diff := cmp.Diff([]*Table{events, orphan, users}, tables, cmpopts.IgnoreFields(Table{}, "Partitions")); diff != "" {
+		t.Errorf("Unexpected tables (-expected +actual):\n%s", diff)
+	}
+
+	expected := []*Partition{
+		{
+			Schema:     "public",
+			Name:       "events_2024",
+			Bound:      "FOR VALUES FROM (2024) TO (2025)",
+			Partitions: []*Partition{{Schema: "public", Name: "events_2024_q1", Bound: "FOR VALUES FROM (1) TO (4)"}},
+		},
+		{Schema: "public", Name: "events_2025", Bound: "FOR VALUES FROM (2025) TO (2026)"},
+	}
+	if diff := cmp.Diff(expected, events.Partitions); diff != "" {
+		t.Errorf("Unexpected partitions (-expected +actual):\n%s", diff)
+	}
+	if orphan.Partitions != nil || users.Partitions != nil {
+		t.Error("Expected tables without partitions to have none")
+	}
+}
+
+func TestPartitions(t *testing.T) {
+	pool := testSchema(t, "dbinfo_part_test", `
+	CREATE TABLE dbinfo_part_test.events (id integer, day date) PARTITION BY RANGE (day);
+	CREATE TABLE dbinfo_part_test.events_2024 PARTITION OF dbinfo_part_test.events
+	    FOR VALUES FROM ('2024-01-01') TO ('2025-01-01');
+	CREATE TABLE dbinfo_part_test.events_2025 PARTITION OF dbinfo_part_test.events
+	    FOR VALUES FROM ('2025-01-01') TO ('2026-01-01');`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_part_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 3 {
+		t.Errorf("Expected partitions to be listed as tables by default, got %d tables", len(info.Tables))
+	}
+
+	info, err = GetDBInfo(context.Background(), pool, WithSchemas("dbinfo_part_test"), WithRolledUpPartitions())
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 1 || info.Tables[0].Name != "events" {
+		t.Fatalf("Expected only the events table, got %d tables", len(info.Tables))
+	}
+	expected := []*Partition{
+		{Schema: "dbinfo_part_test", Name: "events_2024", Bound: "FOR VALUES FROM ('2024-01-01') TO ('2025-01-01')"},
+		{Schema: "dbinfo_part_test", Name: "events_2025", Bound: "FOR VALUES FROM ('2025-01-01') TO ('2026-01-01')"},
+	}
+	if diff := cmp.Diff(expected, info.Tables[0].Partitions); diff != "" {
+		t.Errorf("Unexpected partitions (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestEnsureSlices(t *testing.T) {
+	info := &DBInfo{Name: "shop"}
+	info.EnsureSlices()
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if string(data) != `{"name":"shop","tables":[]}` {
+		t.Errorf("Unexpected JSON for an empty database %s", data)
+	}
+
+	info.Tables = []*Table{{
+		Name:        "orders",
+		Schema:      "public",
+		PrimaryKey:  &PrimaryKey{Name: "orders_pkey"},
+		Indexes:     []*Index{{Name: "orders_lower_idx", Expression: "lower(status)"}},
+		ForeignKeys: []*ForeignKey{{Name: "orders_user_id_fkey"}},
+		BelongsTo:   []*Relationship{{Table: "users"}},
+		Partitions:  []*Partition{{Name: "orders_2024"}},
+	}}
+	info.EnsureSlices()
+	data, err = json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if strings.Contains(string(data), "null") {
+		t.Errorf("Expected no null lists, got %s", data)
+	}
+	table := info.Tables[0]
+	if table.Columns == nil || table.HasMany == nil || table.Partitions[0].Partitions == nil || table.Indexes[0].Keys == nil {
+		t.Errorf("Expected empty slices in the table, got %+v", table)
+	}
+}
+
+func TestClone(t *testing.T) {
+	users := &Table{
+		Schema:     "public",
+		Name:       "users",
+		Columns:    []*Column{{Name: "id", Type: "integer", IsPrimaryKey: true, Kind: ColumnIdentity, Identity: "ALWAYS", Tags: []string{"pii:id"}}},
+		PrimaryKey: &PrimaryKey{Name: "users_pkey", Columns: []string{"id"}},
+		Indexes:    []*Index{},
+		Tags:       []string{"term:User"},
+		Workload:   &Workload{SeqScans: 10, Hot: true},
+	}
+	orders := &Table{
+		Schema:      "public",
+		Name:        "orders",
+		Columns:     []*Column{{Name: "id", Type: "integer"}, {Name: "user_id", Type: "integer"}},
+		Indexes:     []*Index{{Name: "orders_user", Columns: []string{"user_id"}, Keys: []*IndexKey{{Column: "user_id"}}}},
+		ForeignKeys: []*ForeignKey{{Name: "orders_user_fkey", ColumnNames: []string{"user_id"}, RefTableSchema: "public", RefTableName: "users", RefColumnNames: []string{"id"}}},
+		Partitions:  []*Partition{{Schema: "public", Name: "orders_2024", Bound: "DEFAULT", Partitions: []*Partition{{Name: "orders_2024_q1"}}}},
+	}
+	info := &DBInfo{Name: "shop", Tables: []*Table{orders, users}, Warnings: []*Warning{{Phase: PhaseColumns, Object: "public.secrets"}}, Signature: &Signature{Algorithm: "hmac-sha256", Value: "00"}}
+	buildRelationships(info.Tables)
+
+	clone := info.Clone()
+	if diff := cmp.Diff(info, clone); diff != "" {
+		t.Fatalf("Expected an identical clone (-original +clone):\n%s", diff)
+	}
+	if clone.Tables[1].Indexes == nil {
+		t.Error("Expected empty slices to stay empty rather than nil")
+	}
+
+	// Change every level of the clone; the original must not move
+	before := info.Clone()
+	c := clone.Tables[0]
+	c.Name = "purchases"
+	c.Columns[0].Name = "purchase_id"
+	c.Indexes[0].Columns[0] = "buyer_id"
+	c.Indexes[0].Keys[0].Column = "buyer_id"
+	c.ForeignKeys[0].ColumnNames[0] = "buyer_id"
+	c.ForeignKeys[0].RefColumnNames[0] = "uid"
+	c.Partitions[0].Partitions[0].Name = "purchases_2024_q1"
+	c.BelongsTo[0].Columns[0] = "buyer_id"
+	clone.Tables[1].PrimaryKey.Columns[0] = "uid"
+	clone.Tables[1].Columns[0].Tags[0] = "public"
+	clone.Tables[1].Tags[0] = "term:Buyer"
+	clone.Tables[1].Workload.SeqScans = 20
+	clone.Tables[1].HasMany[0].References[0] = "buyer_id"
+	clone.Warnings[0].Object = "public.other"
+	clone.Signature.Value = "ff"
+	if diff := cmp.Diff(before, info); diff != "" {
+		t.Errorf("Changing the clone changed the original (-before +after):\n%s", diff)
+	}
+
+	// Relationships don't share column lists with the foreign keys
+	orders.BelongsTo[0].Columns[0] = "buyer_id"
+	if orders.ForeignKeys[0].ColumnNames[0] != "user_id" {
+		t.Error("Changing a relationship changed its foreign key")
+	}
+
+	if (*DBInfo)(nil).Clone() != nil {
+		t.Error("Expected the clone of nil to be nil")
+	}
+}
+
+// parseIdents splits a possibly qualified SQL name into its identifiers,
+// undoing the quoting. Unquoted identifiers are folded to lower case as
+// PostgreSQL does.
+func parseIdents(s string) ([]string, bool) {
+	var idents []string
+	for {
+		var ident string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s); i++ {
+				if s[i] != '"' {
+					b.WriteByte(s[i])
+				} else if i+1 < len(s) && s[i+1] == '"' {
+					b.WriteByte('"')
+					i++
+				} else {
+					break
+				}
+			}
+			if i >= len(s) {
+				return nil, false // Unterminated
+			}
+			ident, s = b.String(), s[i+1:]
+		} else {
+			end := strings.IndexByte(s, '.')
+			if end < 0 {
+				end = len(s)
+			}
+			ident, s = strings.ToLower(s[:end]), s[end:]
+			if ident == "" || strings.ContainsAny(ident, ` "`) {
+				return nil, false
+			}
+		}
+		idents = append(idents, ident)
+		if s == "" {
+			return idents, true
+		}
+		if s[0] != '.' {
+			return nil, false
+		}
+		s = s[1:]
+	}
+}
+
+func FuzzQuoteIdent(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"public", "orders"},
+		{"Sales", "Order"},
+		{"select", "from"},
+		{"a.b", `c"d`},
+		{`""`, "with space"},
+		{"ünïcödé", "表"},
+		{"2fa", "_x1"},
+		{"", "userId"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+	f.Fuzz(func(t *testing.T, schema, name string) {
+		quoted := QuoteIdent(name)
+		if quoted == name && (!isSimpleIdent(name) || strings.ToLower(name) != name) {
+			t.Errorf("QuoteIdent(%q) left a name unquoted that PostgreSQL would change", name)
+		}
+
+		idents, ok := parseIdents(QualifiedName(schema, name))
+		expected := []string{schema, name}
+		if schema == "" {
+			expected = []string{name}
+		}
+		if !ok || !slices.Equal(idents, expected) {
+			t.Errorf("QualifiedName(%q, %q) = %s, which reads back as %q", schema, name, QualifiedName(schema, name), idents)
+		}
+	})
+}
+
+func TestQuotedName(t *testing.T) {
+	table := &Table{Schema: "Sales", Name: "Order"}
+	if got := table.QuotedName(); got != `"Sales"."Order"` {
+		t.Errorf("Unexpected table name %s", got)
+	}
+	column := &Column{Name: "userId"}
+	if got := column.QuotedName(); got != `"userId"` {
+		t.Errorf("Unexpected column name %s", got)
+	}
+	index := &Index{Name: "idx_orders_user"}
+	if got := index.QuotedName(); got != "idx_orders_user" {
+		t.Errorf("Expected simple names to stay unquoted, got %s", got)
+	}
+	fk := &ForeignKey{Name: `a "quoted" fk`, RefTableSchema: "public", RefTableName: "user"}
+	if got := fk.QuotedName(); got != `"a ""quoted"" fk"` {
+		t.Errorf("Expected embedded quotes to be doubled, got %s", got)
+	}
+	if got := fk.QuotedRefTable(); got != `public."user"` {
+		t.Errorf("Expected reserved words to be quoted, got %s", got)
+	}
+}
+
+func TestMixedCaseIdentifiers(t *testing.T) {
+	pool := testSchema(t, `"DbinfoCase"`, `
+	CREATE TABLE "DbinfoCase"."Order" (
+	    "Id" integer PRIMARY KEY,
+	    "userId" integer NOT NULL,
+	    "select" text
+	);
+	COMMENT ON TABLE "DbinfoCase"."Order" IS 'Mixed case table';
+	COMMENT ON COLUMN "DbinfoCase"."Order"."userId" IS 'Mixed case column';
+	CREATE TABLE "DbinfoCase"."OrderLine" (
+	    id integer PRIMARY KEY,
+	    "orderId" integer REFERENCES "DbinfoCase"."Order" ("Id")
+	);
+	CREATE INDEX "Order_userId" ON "DbinfoCase"."Order" ("userId", lower("select"));`)
+
+	info, err := GetDBInfo(context.Background(), pool, WithSchemas("DbinfoCase"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != 2 {
+		t.Fatalf("Expected 2 tables, got %d", len(info.Tables))
+	}
+
+	order, line := info.Tables[0], info.Tables[1]
+	if order.Name != "Order" || order.Comment != "Mixed case table" {
+		t.Errorf("Expected the table name and comment to keep their case, got %q, %q", order.Name, order.Comment)
+	}
+	if len(order.Columns) != 3 || order.Columns[1].Name != "userId" || order.Columns[1].Comment != "Mixed case column" || !order.Columns[0].IsPrimaryKey {
+		t.Errorf("Unexpected columns %v", order.Columns)
+	}
+	if len(order.Indexes) != 1 || order.Indexes[0].Name != "Order_userId" || order.Indexes[0].Keys[1].Expression != `lower("select")` {
+		t.Errorf("Unexpected indexes %v", order.Indexes)
+	}
+	if len(line.ForeignKeys) != 1 || line.ForeignKeys[0].QuotedRefTable() != `"DbinfoCase"."Order"` || line.ForeignKeys[0].RefColumnNames[0] != "Id" {
+		t.Errorf("Unexpected foreign keys %v", line.ForeignKeys)
+	}
+	if len(order.HasMany) != 1 || order.HasMany[0].Table != "OrderLine" {
+		t.Errorf("Expected OrderLine to reference Order, got %v", order.HasMany)
+	}
+}
+
+func TestFederate(t *testing.T) {
+	shopUsers := &Table{Schema: "public", Name: "users", PrimaryKey: &PrimaryKey{Name: "users_pkey", Columns: []string{"id"}}, Columns: []*Column{{Name: "id"}}}
+	orders := &Table{
+		Schema: "public",
+		Name:   "orders",
+		Columns: []*Column{
+			{Name: "id"},
+			{Name: "user_id"},
+			{Name: "customer_id", Comment: "Buyer. @references crm.public.customers"},
+			{Name: "invoice_id", Comment: "@references billing.public.invoices(id)"},
+			{Name: "rep_id", Comment: "@references crm.public.reps( code )"},
+		},
+		ForeignKeys: []*ForeignKey{{Name: "orders_user_fkey", ColumnNames: []string{"user_id"}, RefTableSchema: "public", RefTableName: "users", RefColumnNames: []string{"id"}}},
+	}
+	buildRelationships([]*Table{shopUsers, orders})
+	shop := &DBInfo{Name: "shop", Tables: []*Table{orders, shopUsers}, Warnings: []*Warning{{Phase: PhaseColumns, Object: "public.secrets", Message: "permission denied"}}}
+	crm := &DBInfo{
+		Name: "crm",
+		Tables: []*Table{
+			{Schema: "public", Name: "customers", PrimaryKey: &PrimaryKey{Name: "customers_pkey", Columns: []string{"id"}}, Columns: []*Column{{Name: "id"}}},
+			{Schema: "public", Name: "reps", Columns: []*Column{{Name: "id"}}},
+			{Schema: "public", Name: "users", Columns: []*Column{{Name: "id"}}},
+		},
+	}
+
+	info, err := Federate("landscape", []*DBInfo{shop, crm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orders.Database != "" || len(orders.BelongsTo) != 1 {
+		t.Error("Federate modified the databases")
+	}
+	var tables []string
+	for _, table := range info.Tables {
+		tables = append(tables, table.Database+"."+table.Schema+"."+table.Name)
+	}
+	if diff := cmp.Diff([]string{"crm.public.customers", "crm.public.reps", "crm.public.users", "shop.public.orders", "shop.public.users"}, tables); diff != "" {
+		t.Errorf("Unexpected tables (-expected +actual):\n%s", diff)
+	}
+	if info.Warnings[0].Object != "shop.public.secrets" {
+		t.Errorf("Expected the warning object to name the database, got %s", info.Warnings[0].Object)
+	}
+
+	unresolved, err := info.LinkReferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var missing []string
+	for _, ref := range unresolved {
+		missing = append(missing, ref.String())
+	}
+	expected := []string{
+		"shop.public.orders.invoice_id -> billing.public.invoices.id",
+		"shop.public.orders.rep_id -> crm.public.reps.code",
+	}
+	if diff := cmp.Diff(expected, missing); diff != "" {
+		t.Errorf("Unexpected unresolved references (-expected +actual):\n%s", diff)
+	}
+
+	relationships := func(rels []*Relationship) []string {
+		names := []string{}
+		for _, rel := range rels {
+			names = append(names, rel.Name+" "+rel.Database+"."+rel.Schema+"."+rel.Table+" "+strings.Join(rel.Columns, ","))
+		}
+		return names
+	}
+	federatedOrders, customers := info.Tables[3], info.Tables[0]
+	if diff := cmp.Diff([]string{"customer crm.public.customers customer_id", "user shop.public.users user_id"}, relationships(federatedOrders.BelongsTo)); diff != "" {
+		t.Errorf("Unexpected BelongsTo of orders (-expected +actual):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"orders shop.public.orders id"}, relationships(customers.HasMany)); diff != "" {
+		t.Errorf("Unexpected HasMany of customers (-expected +actual):\n%s", diff)
+	}
+	// crm.public.users has the same schema and name as shop.public.users but
+	// is a table of its own
+	if len(info.Tables[2].HasMany) != 0 {
+		t.Errorf("Unexpected HasMany of crm.public.users: %v", relationships(info.Tables[2].HasMany))
+	}
+
+	if _, err := Federate("landscape", []*DBInfo{shop, shop}); err == nil {
+		t.Error("Expected an error for a database given twice")
+	}
+	for comment, expected := range map[string]string{
+		"@references customers(id)":      `public.orders.customer_id: invalid annotation "@references customers(id)", expected @references database.schema.table(column)`,
+		"@references crm.public.reps":    "shop.public.orders.customer_id -> crm.public.reps: the referenced table has no single column primary key, name the column",
+		"@references crm..customers(id)": `public.orders.customer_id: invalid annotation "@references crm..customers(id)", expected @references database.schema.table(column)`,
+	} {
+		info, err := Federate("landscape", []*DBInfo{shop, crm})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info.Tables[3].Columns[2].Comment = comment
+		if _, err := info.LinkReferences(); err == nil || err.Error() != expected {
+			t.Errorf("LinkReferences with %q: error = %v, expected %q", comment, err, expected)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+// Package ddl generates PostgreSQL DDL statements from a dbinfo schema, and
+// parses DDL, such as a schema.sql file or migrations, into one.
+package ddl
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Generate writes the DDL needed to recreate the tables in info to w.
+// Domains and sequences are created first, so columns and serial defaults
+// can use them, then tables, comments and indexes, and foreign tables,
+// whose servers are assumed to exist. Sequences are tied to their columns
+// and foreign keys are added at the end with ALTER TABLE, so the output
+// does not depend on the order of the tables and works for circular
+// references. The sequences of identity columns are left to the columns.
+// The rules of the tables follow, as they may write to any table. Event
+// triggers come last, so they don't fire on the statements before
+// them, and their functions are assumed to exist.
+func Generate(w io.Writer, info *dbinfo.DBInfo) error {
+	var b strings.Builder
+
+	for _, domain := range info.Domains {
+		b.WriteString(CreateDomain(domain))
+		b.WriteString(";\n")
+		if domain.Comment != "" {
+			fmt.Fprintf(&b, "COMMENT ON DOMAIN %s IS %s;\n", domain.QualifiedName(), QuoteLiteral(domain.Comment))
+		}
+	}
+	if len(info.Domains) > 0 {
+		b.WriteString("\n")
+	}
+
+	var owned []*dbinfo.Sequence
+	for _, sequence := range info.Sequences {
+		if sequence.OwnedBy != nil && sequence.OwnedBy.Identity {
+			continue
+		}
+		b.WriteString(CreateSequence(sequence))
+		b.WriteString(";\n")
+		if sequence.OwnedBy != nil {
+			owned = append(owned, sequence)
+		}
+	}
+	if len(info.Sequences) > 0 {
+		b.WriteString("\n")
+	}
+
+	for _, table := range info.Tables {
+		writeTable(&b, table)
+	}
+
+	for _, table := range info.ForeignTables {
+		b.WriteString(CreateForeignTable(table))
+		b.WriteString(";\n")
+		if table.Comment != "" {
+			fmt.Fprintf(&b, "COMMENT ON FOREIGN TABLE %s IS %s;\n", table.QualifiedName(), QuoteLiteral(table.Comment))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, sequence := range owned {
+		b.WriteString(SequenceOwnedBy(sequence))
+		b.WriteString(";\n")
+	}
+
+	for _, table := range info.Tables {
+		for _, fk := range table.ForeignKeys {
+			b.WriteString(ForeignKey(table, fk))
+			b.WriteString(";\n")
+		}
+	}
+
+	for _, table := range info.Tables {
+		for _, rule := range table.Rules {
+			separate(&b)
+			b.WriteString(rule.Definition)
+			b.WriteString(";\n")
+			if alter := EnableRule(table, rule); alter != "" {
+				b.WriteString(alter)
+				b.WriteString(";\n")
+			}
+			if rule.Comment != "" {
+				fmt.Fprintf(&b, "COMMENT ON RULE %s ON %s IS %s;\n", QuoteIdent(rule.Name), table.QuotedName(), QuoteLiteral(rule.Comment))
+			}
+		}
+	}
+
+	for _, trigger := range info.EventTriggers {
+		separate(&b)
+		b.WriteString(CreateEventTrigger(trigger))
+		b.WriteString(";\n")
+		if alter := EnableEventTrigger(trigger); alter != "" {
+			b.WriteString(alter)
+			b.WriteString(";\n")
+		}
+		if trigger.Comment != "" {
+			fmt.Fprintf(&b, "COMMENT ON EVENT TRIGGER %s IS %s;\n", QuoteIdent(trigger.Name), QuoteLiteral(trigger.Comment))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// separate starts a new block of statements with a blank line, unless b
+// is empty or already ends with one
+func separate(b *strings.Builder) {
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+		b.WriteString("\n")
+	}
+}
+
+func writeTable(b *strings.Builder, table *dbinfo.Table) {
+	b.WriteString(Table(table))
+	b.WriteString("\n")
+}
+
+// Table returns the statements creating table, its comments and its
+// indexes, but not its foreign keys, each on its own line
+func Table(table *dbinfo.Table) string {
+	var b strings.Builder
+	b.WriteString(CreateTable(table))
+	b.WriteString(";\n")
+
+	if table.Comment != "" {
+		fmt.Fprintf(&b, "COMMENT ON TABLE %s IS %s;\n", table.QuotedName(), QuoteLiteral(table.Comment))
+	}
+	for _, column := range table.Columns {
+		if column.Comment != "" {
+			fmt.Fprintf(&b, "COMMENT ON COLUMN %s.%s IS %s;\n",
+				table.QuotedName(), column.QuotedName(), QuoteLiteral(column.Comment))
+		}
+	}
+	for _, index := range table.Indexes {
+		b.WriteString(CreateIndex(table, index))
+		b.WriteString(";\n")
+	}
+	return b.String()
+}
+
+// CreateTable returns the CREATE TABLE statement for table, including its
+// primary key but not its foreign keys. The primary key keeps its name and
+// key order when the snapshot records them, and otherwise lists the primary
+// key columns in column order.
+func CreateTable(table *dbinfo.Table) string {
+	var lines []string
+	var primaryKey []string
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+ColumnDefinition(column))
+		if column.IsPrimaryKey {
+			primaryKey = append(primaryKey, column.QuotedName())
+		}
+	}
+	switch {
+	case table.PrimaryKey != nil:
+		lines = append(lines, "    CONSTRAINT "+table.PrimaryKey.QuotedName()+" PRIMARY KEY ("+quoteIdents(table.PrimaryKey.Columns)+")")
+	case len(primaryKey) > 0:
+		lines = append(lines, "    PRIMARY KEY ("+strings.Join(primaryKey, ", ")+")")
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", table.QuotedName(), strings.Join(lines, ",\n"))
+}
+
+// ColumnDefinition returns the column definition used inside CREATE TABLE
+func ColumnDefinition(column *dbinfo.Column) string {
+	definition := column.QuotedName() + " " + columnType(column)
+	if column.Collation != "" {
+		definition += " COLLATE " + column.Collation
+	}
+	if !column.IsNullable {
+		definition += " NOT NULL"
+	}
+	if column.DefaultValue != "" {
+		definition += " DEFAULT " + column.DefaultValue
+	}
+	if column.Kind == dbinfo.ColumnIdentity {
+		definition += " GENERATED " + column.Identity + " AS IDENTITY"
+	}
+	return definition
+}
+
+// columnType prefers the formatted type since it keeps modifiers like
+// lengths and precisions
+func columnType(column *dbinfo.Column) string {
+	if column.FullType != "" {
+		return column.FullType
+	}
+	return column.Type
+}
+
+// CreateIndex returns the CREATE INDEX statement for index
+func CreateIndex(table *dbinfo.Table, index *dbinfo.Index) string {
+	statement := "CREATE INDEX "
+	if index.Unique {
+		statement = "CREATE UNIQUE INDEX "
+	}
+
+	var keys string
+	switch {
+	case len(index.Keys) > 0:
+		parts := make([]string, len(index.Keys))
+		for i, key := range index.Keys {
+			if key.Column != "" {
+				parts[i] = QuoteIdent(key.Column)
+			} else {
+				parts[i] = "(" + key.Expression + ")"
+			}
+		}
+		keys = strings.Join(parts, ", ")
+	case index.Expression != "":
+		// Snapshots without keys can't tell where the expression goes
+		keys = "(" + index.Expression + ")"
+	default:
+		keys = quoteIdents(index.Columns)
+	}
+
+	statement += index.QuotedName() + " ON " + table.QuotedName() + " (" + keys + ")"
+	if index.Unique && index.NullsNotDistinct {
+		statement += " NULLS NOT DISTINCT"
+	}
+	return statement
+}
+
+// ForeignKey returns the ALTER TABLE statement adding fk to table
+func ForeignKey(table *dbinfo.Table, fk *dbinfo.ForeignKey) string {
+	statement := fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+		table.QuotedName(),
+		fk.QuotedName(),
+		quoteIdents(fk.ColumnNames),
+		fk.QuotedRefTable(),
+		quoteIdents(fk.RefColumnNames),
+	)
+	if fk.OnUpdate != "" && fk.OnUpdate != "NO ACTION" {
+		statement += " ON UPDATE " + fk.OnUpdate
+	}
+	if fk.OnDelete != "" && fk.OnDelete != "NO ACTION" {
+		statement += " ON DELETE " + fk.OnDelete
+	}
+	return statement
+}
+
+// CreateDomain returns the CREATE DOMAIN statement for domain, with its
+// default, NOT NULL and CHECK constraints
+func CreateDomain(domain *dbinfo.Domain) string {
+	statement := fmt.Sprintf("CREATE DOMAIN %s AS %s", domain.QualifiedName(), domain.Type)
+	if domain.Default != "" {
+		statement += " DEFAULT " + domain.Default
+	}
+	if domain.NotNull {
+		statement += " NOT NULL"
+	}
+	for _, check := range domain.Checks {
+		statement += fmt.Sprintf(" CONSTRAINT %s %s", QuoteIdent(check.Name), check.Definition)
+	}
+	return statement
+}
+
+// CreateForeignTable returns the CREATE FOREIGN TABLE statement for table,
+// with the options of the table and of its columns
+func CreateForeignTable(table *dbinfo.ForeignTable) string {
+	lines := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		line := "    " + ColumnDefinition(column)
+		if options := table.ColumnOptions[column.Name]; len(options) > 0 {
+			line += " OPTIONS (" + fdwOptions(options) + ")"
+		}
+		lines = append(lines, line)
+	}
+	statement := fmt.Sprintf("CREATE FOREIGN TABLE %s (\n%s\n) SERVER %s", table.QualifiedName(), strings.Join(lines, ",\n"), QuoteIdent(table.Server))
+	if len(table.Options) > 0 {
+		statement += " OPTIONS (" + fdwOptions(table.Options) + ")"
+	}
+	return statement
+}
+
+// fdwOptions lists foreign data wrapper options as OPTIONS takes them,
+// sorted by name
+func fdwOptions(options map[string]string) string {
+	list := make([]string, 0, len(options))
+	for _, name := range slices.Sorted(maps.Keys(options)) {
+		list = append(list, QuoteIdent(name)+" "+QuoteLiteral(options[name]))
+	}
+	return strings.Join(list, ", ")
+}
+
+// EnableRule returns the ALTER TABLE statement setting when rule applies,
+// empty for the origin default CREATE RULE leaves
+func EnableRule(table *dbinfo.Table, rule *dbinfo.Rule) string {
+	state := enableState(rule.Enabled)
+	if state == "" {
+		return ""
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s RULE %s", table.QuotedName(), state, QuoteIdent(rule.Name))
+}
+
+// CreateEventTrigger returns the CREATE EVENT TRIGGER statement for
+// trigger, limited to its command tags when it has any
+func CreateEventTrigger(trigger *dbinfo.EventTrigger) string {
+	statement := fmt.Sprintf("CREATE EVENT TRIGGER %s ON %s", QuoteIdent(trigger.Name), trigger.Event)
+	if len(trigger.Tags) > 0 {
+		tags := make([]string, len(trigger.Tags))
+		for i, tag := range trigger.Tags {
+			tags[i] = QuoteLiteral(tag)
+		}
+		statement += " WHEN TAG IN (" + strings.Join(tags, ", ") + ")"
+	}
+	return statement + " EXECUTE FUNCTION " + trigger.Function + "()"
+}
+
+// EnableEventTrigger returns the ALTER EVENT TRIGGER statement setting
+// when trigger fires, empty for the origin default CREATE EVENT TRIGGER
+// leaves
+func EnableEventTrigger(trigger *dbinfo.EventTrigger) string {
+	state := enableState(trigger.Enabled)
+	if state == "" {
+		return ""
+	}
+	return fmt.Sprintf("ALTER EVENT TRIGGER %s %s", QuoteIdent(trigger.Name), state)
+}
+
+// enableState returns the ENABLE or DISABLE clause of ALTER for an enabled
+// state, empty for origin
+func enableState(enabled string) string {
+	switch enabled {
+	case "replica":
+		return "ENABLE REPLICA"
+	case "always":
+		return "ENABLE ALWAYS"
+	case "disabled":
+		return "DISABLE"
+	}
+	return ""
+}
+
+// CreateSequence returns the CREATE SEQUENCE statement for sequence, with
+// every option spelled out
+func CreateSequence(sequence *dbinfo.Sequence) string {
+	statement := fmt.Sprintf("CREATE SEQUENCE %s", sequence.QualifiedName())
+	if sequence.Type != "" {
+		statement += " AS " + sequence.Type
+	}
+	statement += fmt.Sprintf(" INCREMENT BY %d MINVALUE %d MAXVALUE %d START WITH %d CACHE %d",
+		sequence.Increment, sequence.Min, sequence.Max, sequence.Start, sequence.Cache)
+	if sequence.Cycle {
+		statement += " CYCLE"
+	}
+	return statement
+}
+
+// SequenceOwnedBy returns the ALTER SEQUENCE statement tying sequence to
+// the column owning it, so it is dropped with the column
+func SequenceOwnedBy(sequence *dbinfo.Sequence) string {
+	owner := sequence.OwnedBy
+	return fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s.%s", sequence.QualifiedName(), TableName(owner.Schema, owner.Table), QuoteIdent(owner.Column))
+}
+
+// TableName returns the schema qualified and quoted name of a table
+func TableName(schema, name string) string {
+	return dbinfo.QualifiedName(schema, name)
+}
+
+// QuoteIdent quotes an identifier when PostgreSQL requires it
+func QuoteIdent(name string) string {
+	return dbinfo.QuoteIdent(name)
+}
+
+// QuoteLiteral quotes a string literal
+func QuoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = QuoteIdent(name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,404 @@
+package ddl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:    "categories",
+				Schema:  "public",
+				Comment: "Product categories",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true, DefaultValue: "nextval('categories_id_seq'::regclass)"},
+					{Name: "name", Type: "character varying", FullType: "character varying(100)", Comment: "Category's name"},
+				},
+			},
+			{
+				Name:   "products",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "category_id", Type: "integer"},
+					{Name: "Order", Type: "text", IsNullable: true},
+				},
+				Indexes: []*dbinfo.Index{
+					{Name: "idx_products_category", Columns: []string{"category_id"}},
+					{Name: "idx_products_order", Unique: true, Expression: `lower("Order")`},
+					{
+						Name:       "idx_products_category_order",
+						Columns:    []string{"category_id"},
+						Expression: `lower("Order")`,
+						Keys:       []*dbinfo.IndexKey{{Expression: `lower("Order")`}, {Column: "category_id"}},
+					},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{
+						Name:           "products_category_id_fkey",
+						ColumnNames:    []string{"category_id"},
+						RefTableSchema: "public",
+						RefTableName:   "categories",
+						RefColumnNames: []string{"id"},
+						OnUpdate:       "NO ACTION",
+						OnDelete:       "CASCADE",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Generate(&buf, testInfo()); err != nil {
+		t.Fatalf("Failed to generate DDL: %v", err)
+	}
+
+	expected := `CREATE TABLE public.categories (
+    id integer NOT NULL DEFAULT nextval('categories_id_seq'::regclass),
+    name character varying(100) NOT NULL,
+    PRIMARY KEY (id)
+);
+COMMENT ON TABLE public.categories IS 'Product categories';
+COMMENT ON COLUMN public.categories.name IS 'Category''s name';
+
+CREATE TABLE public.products (
+    id integer NOT NULL,
+    category_id integer NOT NULL,
+    "Order" text,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_products_category ON public.products (category_id);
+CREATE UNIQUE INDEX idx_products_order ON public.products ((lower("Order")));
+CREATE INDEX idx_products_category_order ON public.products ((lower("Order")), category_id);
+
+ALTER TABLE public.products ADD CONSTRAINT products_category_id_fkey FOREIGN KEY (category_id) REFERENCES public.categories (id) ON DELETE CASCADE;
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected DDL (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGenerateSequences(t *testing.T) {
+	info := &dbinfo.DBInfo{
+		Tables: []*dbinfo.Table{{Schema: "public", Name: "orders", Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", DefaultValue: "nextval('orders_id_seq'::regclass)", Kind: dbinfo.ColumnSerial},
+			{Name: "number", Type: "bigint", Kind: dbinfo.ColumnIdentity, Identity: "ALWAYS"},
+		}}},
+		Sequences: []*dbinfo.Sequence{
+			{Schema: "billing", Name: "Invoice numbers", Type: "bigint", Start: 1000, Increment: 10, Min: 1, Max: 9223372036854775807, Cache: 20, Cycle: true},
+			{Schema: "public", Name: "orders_id_seq", Type: "integer", Start: 1, Increment: 1, Min: 1, Max: 2147483647, Cache: 1,
+				OwnedBy: &dbinfo.SequenceOwner{Schema: "public", Table: "orders", Column: "id"}},
+			{Schema: "public", Name: "orders_number_seq", Type: "bigint", Start: 1, Increment: 1, Min: 1, Max: 9223372036854775807, Cache: 1,
+				OwnedBy: &dbinfo.SequenceOwner{Schema: "public", Table: "orders", Column: "number", Identity: true}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE SEQUENCE billing."Invoice numbers" AS bigint INCREMENT BY 10 MINVALUE 1 MAXVALUE 9223372036854775807 START WITH 1000 CACHE 20 CYCLE;
+CREATE SEQUENCE public.orders_id_seq AS integer INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START WITH 1 CACHE 1;
+
+CREATE TABLE public.orders (
+    id integer NOT NULL DEFAULT nextval('orders_id_seq'::regclass),
+    number bigint NOT NULL GENERATED ALWAYS AS IDENTITY
+);
+
+ALTER SEQUENCE public.orders_id_seq OWNED BY public.orders.id;
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected DDL (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGenerateDomains(t *testing.T) {
+	info := &dbinfo.DBInfo{
+		Tables: []*dbinfo.Table{{Schema: "public", Name: "users", Columns: []*dbinfo.Column{
+			{Name: "email", Type: "character varying", FullType: "email_address", Domain: &dbinfo.DomainRef{Schema: "public", Name: "email_address"}},
+		}}},
+		Domains: []*dbinfo.Domain{
+			{Schema: "public", Name: "email_address", Type: "character varying(254)", NotNull: true, Comment: "A mailbox",
+				Checks: []*dbinfo.DomainCheck{{Name: "email_address_check", Definition: "CHECK (((VALUE)::text ~~ '%@%'::text))"}}},
+			{Schema: "public", Name: "Positive", Type: "integer", Default: "1"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE DOMAIN public.email_address AS character varying(254) NOT NULL CONSTRAINT email_address_check CHECK (((VALUE)::text ~~ '%@%'::text));
+COMMENT ON DOMAIN public.email_address IS 'A mailbox';
+CREATE DOMAIN public."Positive" AS integer DEFAULT 1;
+
+CREATE TABLE public.users (
+    email email_address NOT NULL
+);
+
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected DDL (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGenerateForeignTables(t *testing.T) {
+	info := &dbinfo.DBInfo{
+		ForeignTables: []*dbinfo.ForeignTable{{
+			Schema:  "analytics",
+			Name:    "events",
+			Server:  "warehouse",
+			Wrapper: "postgres_fdw",
+			Options: map[string]string{"table_name": "events", "schema_name": "public"},
+			Columns: []*dbinfo.Column{
+				{Name: "id", Type: "bigint", FullType: "bigint"},
+				{Name: "kind", Type: "text", FullType: "text", IsNullable: true},
+			},
+			ColumnOptions: map[string]map[string]string{"kind": {"column_name": "event_type"}},
+			Comment:       "Raw events",
+		}},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE FOREIGN TABLE analytics.events (
+    id bigint NOT NULL,
+    kind text OPTIONS (column_name 'event_type')
+) SERVER warehouse OPTIONS (schema_name 'public', table_name 'events');
+COMMENT ON FOREIGN TABLE analytics.events IS 'Raw events';
+
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected DDL (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGenerateRules(t *testing.T) {
+	info := &dbinfo.DBInfo{
+		Tables: []*dbinfo.Table{{
+			Schema:  "public",
+			Name:    "users",
+			Columns: []*dbinfo.Column{{Name: "id", Type: "bigint", FullType: "bigint"}},
+			Rules: []*dbinfo.Rule{{Name: "users_keep", Event: "DELETE", Instead: true, Enabled: "replica",
+				Definition: "CREATE RULE users_keep AS\n    ON DELETE TO public.users DO INSTEAD NOTHING", Comment: "Soft deletes"}},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE TABLE public.users (
+    id bigint NOT NULL
+);
+
+CREATE RULE users_keep AS
+    ON DELETE TO public.users DO INSTEAD NOTHING;
+ALTER TABLE public.users ENABLE REPLICA RULE users_keep;
+COMMENT ON RULE users_keep ON public.users IS 'Soft deletes';
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected DDL (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestGenerateEventTriggers(t *testing.T) {
+	info := &dbinfo.DBInfo{
+		EventTriggers: []*dbinfo.EventTrigger{
+			{Name: "audit_ddl", Event: "ddl_command_end", Tags: []string{"CREATE TABLE", "ALTER TABLE"}, Function: "audit.log_ddl", Enabled: "origin", Comment: "Logs schema changes"},
+			{Name: "block_drops", Event: "sql_drop", Function: "public.block_drops", Enabled: "disabled"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	expected := `CREATE EVENT TRIGGER audit_ddl ON ddl_command_end WHEN TAG IN ('CREATE TABLE', 'ALTER TABLE') EXECUTE FUNCTION audit.log_ddl();
+COMMENT ON EVENT TRIGGER audit_ddl IS 'Logs schema changes';
+
+CREATE EVENT TRIGGER block_drops ON sql_drop EXECUTE FUNCTION public.block_drops();
+ALTER EVENT TRIGGER block_drops DISABLE;
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected DDL (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := map[string]string{
+		"orders":     "orders",
+		"order":      `"order"`,
+		"Order":      `"Order"`,
+		"userId":     `"userId"`,
+		"2fa":        `"2fa"`,
+		"with space": `"with space"`,
+		`a"b`:        `"a""b"`,
+		"":           `""`,
+	}
+	for name, expected := range tests {
+		if got := QuoteIdent(name); got != expected {
+			t.Errorf("QuoteIdent(%q) = %s, expected %s", name, got, expected)
+		}
+	}
+
+	if got := TableName("Billing", "invoices"); got != `"Billing".invoices` {
+		t.Errorf("Unexpected table name %s", got)
+	}
+	if !strings.Contains(QuoteLiteral("it's"), "it''s") {
+		t.Error("Expected quotes in literals to be doubled")
+	}
+}
+
+func TestCreateIndexNullsNotDistinct(t *testing.T) {
+	table := &dbinfo.Table{Schema: "public", Name: "users"}
+	index := &dbinfo.Index{Name: "users_email_key", Unique: true, NullsNotDistinct: true, Keys: []*dbinfo.IndexKey{{Column: "email"}}}
+
+	expected := "CREATE UNIQUE INDEX users_email_key ON public.users (email) NULLS NOT DISTINCT"
+	if got := CreateIndex(table, index); got != expected {
+		t.Errorf("Unexpected statement %s", got)
+	}
+}
+
+func TestCreateTablePrimaryKey(t *testing.T) {
+	table := &dbinfo.Table{
+		Schema: "public",
+		Name:   "memberships",
+		Columns: []*dbinfo.Column{
+			{Name: "user_id", Type: "integer", IsPrimaryKey: true},
+			{Name: "Team", Type: "integer", IsPrimaryKey: true},
+		},
+	}
+	if got := CreateTable(table); !strings.Contains(got, "    PRIMARY KEY (user_id, \"Team\")\n") {
+		t.Errorf("Expected the primary key in column order without a recorded key, got\n%s", got)
+	}
+
+	table.PrimaryKey = &dbinfo.PrimaryKey{Name: "memberships_pk", Columns: []string{"Team", "user_id"}}
+	if got := CreateTable(table); !strings.Contains(got, "    CONSTRAINT memberships_pk PRIMARY KEY (\"Team\", user_id)\n") {
+		t.Errorf("Expected the named primary key in key order, got\n%s", got)
+	}
+}
+
+func TestColumnDefinitionCollation(t *testing.T) {
+	column := &dbinfo.Column{Name: "code", Type: "text", FullType: "text", Collation: `"C"`}
+	if got := ColumnDefinition(column); got != `code text COLLATE "C" NOT NULL` {
+		t.Errorf("Unexpected column definition %s", got)
+	}
+}
+
+func TestColumnDefinitionIdentity(t *testing.T) {
+	column := &dbinfo.Column{Name: "id", Type: "bigint", Kind: dbinfo.ColumnIdentity, Identity: "BY DEFAULT"}
+	if got := ColumnDefinition(column); got != "id bigint NOT NULL GENERATED BY DEFAULT AS IDENTITY" {
+		t.Errorf("Unexpected column definition %s", got)
+	}
+}
+
+// FuzzRoundTrip creates a table with the fuzzed names from the generated
+// DDL and checks that introspection reads the same names back. It needs a
+// database, see TEST_POSTGRES_DSN.
+func FuzzRoundTrip(f *testing.F) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		f.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+	ctx := context.Background()
+	pool, err := dbinfo.FromString(ctx, dsn)
+	if err != nil {
+		f.Fatalf("Failed to connect to database: %v", err)
+	}
+	f.Cleanup(pool.Close)
+
+	const schema = `ddl "Round Trip"`
+	f.Cleanup(func() {
+		pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+dbinfo.QuoteIdent(schema)+" CASCADE")
+	})
+
+	for _, seed := range [][2]string{
+		{"Order", "userId"},
+		{"select", "from"},
+		{`a "quoted" table`, `it's`},
+		{"with space", "a.b"},
+		{"ünïcödé 表", "çolumn"},
+		{strings.Repeat("t", 63), strings.Repeat("é", 31)},
+		{"$1", "2fa"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, table, column string) {
+		// PostgreSQL truncates names over 63 bytes and rejects NUL. The
+		// column shares its name with the index, which can't clash with
+		// the table or its primary key, nor with the other columns.
+		for _, name := range []string{table, column} {
+			if name == "" || len(name) > 63 || !utf8.ValidString(name) || strings.ContainsRune(name, 0) {
+				t.Skip()
+			}
+		}
+		if column == "id" || column == "parent" || column == table || strings.HasSuffix(column, "_pkey") {
+			t.Skip()
+		}
+
+		info := &dbinfo.DBInfo{Tables: []*dbinfo.Table{{
+			Schema:  schema,
+			Name:    table,
+			Comment: table,
+			Columns: []*dbinfo.Column{
+				{Name: "id", Type: "integer", IsPrimaryKey: true},
+				{Name: column, Type: "text", IsNullable: true, Comment: column},
+				{Name: "parent", Type: "integer", IsNullable: true},
+			},
+			Indexes: []*dbinfo.Index{{Name: column, Columns: []string{column}, Keys: []*dbinfo.IndexKey{{Column: column}}}},
+			ForeignKeys: []*dbinfo.ForeignKey{{
+				Name:           column,
+				ColumnNames:    []string{"parent"},
+				RefTableSchema: schema,
+				RefTableName:   table,
+				RefColumnNames: []string{"id"},
+			}},
+		}}}
+		var buf bytes.Buffer
+		if err := Generate(&buf, info); err != nil {
+			t.Fatal(err)
+		}
+
+		quotedSchema := dbinfo.QuoteIdent(schema)
+		if _, err := pool.Exec(ctx, "DROP SCHEMA IF EXISTS "+quotedSchema+" CASCADE; CREATE SCHEMA "+quotedSchema); err != nil {
+			t.Fatalf("Failed to create schema: %v", err)
+		}
+		if _, err := pool.Exec(ctx, buf.String()); err != nil {
+			t.Fatalf("Generated DDL failed: %v\n%s", err, buf.String())
+		}
+
+		actual, err := dbinfo.GetDBInfo(ctx, pool, dbinfo.WithSchemas(schema))
+		if err != nil {
+			t.Fatalf("Failed to get database info: %v", err)
+		}
+		if len(actual.Tables) != 1 {
+			t.Fatalf("Expected 1 table, got %d", len(actual.Tables))
+		}
+		got := actual.Tables[0]
+		var columns []string
+		for _, c := range got.Columns {
+			columns = append(columns, c.Name)
+		}
+		if got.Name != table || got.Comment != table || !slices.Equal(columns, []string{"id", column, "parent"}) || got.Columns[1].Comment != column {
+			t.Errorf("Table %q with column %q read back as %q with columns %q", table, column, got.Name, columns)
+		}
+		if len(got.Indexes) != 1 || got.Indexes[0].Name != column || !slices.Equal(got.Indexes[0].Columns, []string{column}) {
+			t.Errorf("Unexpected indexes %v", got.Indexes)
+		}
+		if len(got.ForeignKeys) != 1 || got.ForeignKeys[0].Name != column || got.ForeignKeys[0].RefTableName != table {
+			t.Errorf("Unexpected foreign keys %v", got.ForeignKeys)
+		}
+	})
+}
@@ -0,0 +1,527 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenKind classifies the tokens of a statement
+type tokenKind int
+
+const (
+	endToken    tokenKind = iota // Past the last token of a statement
+	identToken                   // Unquoted identifier or keyword
+	quotedToken                  // Quoted identifier
+	stringToken                  // String constant, including dollar quoted ones
+	numberToken                  // Numeric constant
+	opToken                      // Punctuation and operators
+)
+
+// token is a lexical token of an SQL statement
+type token struct {
+	kind tokenKind
+	// text is lowercased for identifiers, as PostgreSQL folds them,
+	// unquoted for quoted identifiers and as written otherwise
+	text  string
+	line  int
+	space bool // White space or a comment precedes the token
+}
+
+// downMarkers are the comments after which migration files undo their
+// changes, as goose and sql-migrate write them
+var downMarkers = []string{"+goose Down", "+migrate Down"}
+
+// lex splits sql into statements of tokens, dropping comments, empty
+// statements and psql meta-commands such as \connect. It stops at the
+// comment starting the down section of a migration.
+func lex(sql string) ([][]token, error) {
+	var statements [][]token
+	var current []token
+	line, lineStart, space := 1, true, false
+	emit := func(kind tokenKind, text string, tokenLine int) {
+		current = append(current, token{kind: kind, text: text, line: tokenLine, space: space})
+		lineStart, space = false, false
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start, startLine := i, line
+		switch {
+		case c == '\n':
+			line++
+			lineStart, space = true, true
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			space = true
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			comment := strings.TrimSpace(sql[i+2 : i+end])
+			for _, marker := range downMarkers {
+				if strings.HasPrefix(comment, marker) {
+					return append(statements, nonEmpty(current)...), nil
+				}
+			}
+			space = true
+			i += end
+		case strings.HasPrefix(sql[i:], "/*"):
+			// Block comments nest
+			depth := 0
+			for i < len(sql) {
+				switch {
+				case strings.HasPrefix(sql[i:], "/*"):
+					depth++
+					i += 2
+				case strings.HasPrefix(sql[i:], "*/"):
+					depth--
+					i += 2
+				default:
+					if sql[i] == '\n' {
+						line++
+					}
+					i++
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if depth > 0 {
+				return nil, &lineError{line: startLine, msg: "unterminated comment"}
+			}
+			space = true
+		case c == '\\' && lineStart:
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			i += end
+		case c == ';':
+			statements = append(statements, nonEmpty(current)...)
+			current = nil
+			lineStart, space = false, false
+			i++
+		case c == '\'' || (strings.ContainsRune("eEbBxXnN", rune(c)) && i+1 < len(sql) && sql[i+1] == '\''):
+			n, err := scanString(sql[i:])
+			if err != nil {
+				return nil, &lineError{line: startLine, msg: err.Error()}
+			}
+			line += strings.Count(sql[i:i+n], "\n")
+			i += n
+			emit(stringToken, sql[start:i], startLine)
+		case c == '"':
+			var b strings.Builder
+			closed := false
+			for i++; i < len(sql); i++ {
+				if sql[i] == '"' {
+					if i+1 < len(sql) && sql[i+1] == '"' {
+						b.WriteByte('"')
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				if sql[i] == '\n' {
+					line++
+				}
+				b.WriteByte(sql[i])
+			}
+			if !closed {
+				return nil, &lineError{line: startLine, msg: "unterminated quoted identifier"}
+			}
+			emit(quotedToken, b.String(), startLine)
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			// A parameter such as $1
+			for i++; i < len(sql) && isDigit(sql[i]); i++ {
+			}
+			emit(opToken, sql[start:i], startLine)
+		case c == '$':
+			tag := dollarTag(sql[i:])
+			if tag == "" {
+				i++
+				emit(opToken, "$", startLine)
+				break
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				return nil, &lineError{line: startLine, msg: "unterminated dollar-quoted string"}
+			}
+			i += len(tag) + end + len(tag)
+			line += strings.Count(sql[start:i], "\n")
+			emit(stringToken, sql[start:i], startLine)
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.' && !strings.HasPrefix(sql[i:], "..")) {
+				i++
+			}
+			if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+				j := i + 1
+				if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+					j++
+				}
+				if j < len(sql) && isDigit(sql[j]) {
+					for i = j; i < len(sql) && isDigit(sql[i]); i++ {
+					}
+				}
+			}
+			emit(numberToken, sql[start:i], startLine)
+		case isIdentStart(c):
+			for i < len(sql) && isIdentPart(sql[i]) {
+				i++
+			}
+			emit(identToken, strings.ToLower(sql[start:i]), startLine)
+		case strings.HasPrefix(sql[i:], "::"):
+			i += 2
+			emit(opToken, "::", startLine)
+		case strings.ContainsRune("+-*/<>=~!@#%^&|`?", rune(c)):
+			for i < len(sql) && strings.ContainsRune("+-*/<>=~!@#%^&|`?", rune(sql[i])) && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+				i++
+			}
+			emit(opToken, sql[start:i], startLine)
+		default:
+			i++
+			emit(opToken, sql[start:i], startLine)
+		}
+	}
+	return append(statements, nonEmpty(current)...), nil
+}
+
+// nonEmpty returns statement as a list of statements, empty when it has no
+// tokens
+func nonEmpty(statement []token) [][]token {
+	if len(statement) == 0 {
+		return nil
+	}
+	return [][]token{statement}
+}
+
+// scanString returns the length of the string constant at the start of s,
+// with its prefix: E strings take backslash escapes, others double quotes
+func scanString(s string) (int, error) {
+	i := 0
+	escapes := false
+	if s[0] != '\'' {
+		escapes = s[0] == 'e' || s[0] == 'E'
+		i++
+	}
+	for i++; i < len(s); i++ {
+		switch {
+		case escapes && s[i] == '\\':
+			i++
+		case s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == '\'':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string")
+}
+
+// dollarTag returns the tag opening a dollar-quoted string at the start of
+// s, such as $$ or $body$, or "" when s doesn't start with one
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case !isIdentPart(s[i]) || s[i] == '$' || (i == 1 && isDigit(s[i])):
+			return ""
+		}
+	}
+	return ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
+
+// stmt reads the tokens of a statement, or of a part of one
+type stmt struct {
+	tokens []token
+	pos    int
+}
+
+// peek returns the next token without consuming it
+func (s *stmt) peek() token {
+	return s.at(s.pos)
+}
+
+// at returns the token at position i, an endToken past the last one
+func (s *stmt) at(i int) token {
+	if i >= len(s.tokens) {
+		line := 0
+		if len(s.tokens) > 0 {
+			line = s.tokens[len(s.tokens)-1].line
+		}
+		return token{kind: endToken, line: line}
+	}
+	return s.tokens[i]
+}
+
+// next consumes the next token
+func (s *stmt) next() token {
+	t := s.peek()
+	if s.pos < len(s.tokens) {
+		s.pos++
+	}
+	return t
+}
+
+// done reports whether every token was consumed
+func (s *stmt) done() bool {
+	return s.pos >= len(s.tokens)
+}
+
+// is reports whether the next tokens are the keywords words
+func (s *stmt) is(words ...string) bool {
+	for i, word := range words {
+		if t := s.at(s.pos + i); t.kind != identToken || t.text != word {
+			return false
+		}
+	}
+	return true
+}
+
+// isOp reports whether the next token is the operator or punctuation op
+func (s *stmt) isOp(op string) bool {
+	t := s.peek()
+	return t.kind == opToken && t.text == op
+}
+
+// accept consumes the keywords words when they come next
+func (s *stmt) accept(words ...string) bool {
+	if !s.is(words...) {
+		return false
+	}
+	s.pos += len(words)
+	return true
+}
+
+// expect consumes the keywords words, which must come next
+func (s *stmt) expect(words ...string) error {
+	if !s.accept(words...) {
+		return s.unexpected(strings.ToUpper(strings.Join(words, " ")))
+	}
+	return nil
+}
+
+// expectOp consumes the operator or punctuation op, which must come next
+func (s *stmt) expectOp(op string) error {
+	if !s.isOp(op) {
+		return s.unexpected(op)
+	}
+	s.pos++
+	return nil
+}
+
+// unexpected returns the error of finding the next token instead of what
+// was expected
+func (s *stmt) unexpected(expected string) error {
+	t := s.peek()
+	found := t.text
+	switch t.kind {
+	case endToken:
+		found = "end of statement"
+	case quotedToken:
+		found = `"` + t.text + `"`
+	}
+	return s.errorf("expected %s, found %s", expected, found)
+}
+
+// errorf returns an error at the line of the next token
+func (s *stmt) errorf(format string, args ...any) error {
+	return &lineError{line: s.peek().line, msg: fmt.Sprintf(format, args...)}
+}
+
+// ident consumes an identifier, quoted or not
+func (s *stmt) ident() (string, error) {
+	t := s.peek()
+	if t.kind != identToken && t.kind != quotedToken {
+		return "", s.unexpected("a name")
+	}
+	s.pos++
+	return t.text, nil
+}
+
+// name consumes a possibly qualified name, returning its parts
+func (s *stmt) name() ([]string, error) {
+	part, err := s.ident()
+	if err != nil {
+		return nil, err
+	}
+	parts := []string{part}
+	for s.isOp(".") {
+		s.pos++
+		if part, err = s.ident(); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// identList consumes a parenthesized list of names
+func (s *stmt) identList() ([]string, error) {
+	group, err := s.group()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, item := range splitCommas(group) {
+		item := &stmt{tokens: item}
+		name, err := item.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// group consumes a parenthesized group, returning the tokens inside it
+func (s *stmt) group() ([]token, error) {
+	if !s.isOp("(") {
+		return nil, s.unexpected("(")
+	}
+	depth := 0
+	for i := s.pos; i < len(s.tokens); i++ {
+		if s.tokens[i].kind != opToken {
+			continue
+		}
+		switch s.tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				group := s.tokens[s.pos+1 : i]
+				s.pos = i + 1
+				return group, nil
+			}
+		}
+	}
+	return nil, s.errorf("unbalanced parentheses")
+}
+
+// skipGroup consumes a parenthesized group when one comes next
+func (s *stmt) skipGroup() error {
+	if s.isOp("(") {
+		_, err := s.group()
+		return err
+	}
+	return nil
+}
+
+// until consumes the tokens up to the first one outside parentheses for
+// which stop returns true, or to the end
+func (s *stmt) until(stop func(s *stmt) bool) []token {
+	start, depth := s.pos, 0
+	for !s.done() {
+		if depth == 0 && s.pos > start && stop(s) {
+			break
+		}
+		if t := s.peek(); t.kind == opToken {
+			switch t.text {
+			case "(", "[":
+				depth++
+			case ")", "]":
+				depth--
+			}
+		}
+		s.pos++
+	}
+	return s.tokens[start:s.pos]
+}
+
+// rest consumes the remaining tokens
+func (s *stmt) rest() []token {
+	rest := s.tokens[s.pos:]
+	s.pos = len(s.tokens)
+	return rest
+}
+
+// splitCommas splits tokens at the commas outside parentheses
+func splitCommas(tokens []token) [][]token {
+	var parts [][]token
+	start, depth := 0, 0
+	for i, t := range tokens {
+		if t.kind != opToken {
+			continue
+		}
+		switch t.text {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		case ",":
+			if depth == 0 {
+				parts = append(parts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(tokens) {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+// lineError is a syntax error at a line of a file
+type lineError struct {
+	line int
+	msg  string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// stringValue returns the value of a string constant token
+func stringValue(t token) string {
+	text := t.text
+	if strings.HasPrefix(text, "$") {
+		tag := dollarTag(text)
+		return text[len(tag) : len(text)-len(tag)]
+	}
+	escapes := text[0] == 'e' || text[0] == 'E'
+	if text[0] != '\'' {
+		text = text[1:]
+	}
+	text = text[1 : len(text)-1]
+	if !escapes {
+		return strings.ReplaceAll(text, "''", "'")
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case c == '\\' && i+1 < len(text):
+			i++
+			switch text[i] {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'r':
+				c = '\r'
+			default:
+				c = text[i]
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,1474 @@
+package ddl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// maxNameLength is the length PostgreSQL truncates names to, NAMEDATALEN - 1
+const maxNameLength = 63
+
+// Parser builds a schema from DDL statements without a database, applying
+// them in order as PostgreSQL would: a schema.sql file, the output of
+// pg_dump --schema-only, or the migrations of an application. It models
+// CREATE, ALTER and DROP TABLE, CREATE and DROP INDEX, COMMENT ON and the
+// sequences owned by serial columns; other statements, such as functions,
+// views, grants and data, are skipped.
+//
+// Names PostgreSQL generates, such as orders_pkey or
+// orders_customer_id_fkey, are generated the same way, so the schema
+// compares equal to the one introspected from a database the statements
+// ran on. Index expressions and defaults keep the text they are written
+// with, which the server may print differently.
+type Parser struct {
+	schema   string // Schema of unqualified names, the first of search_path
+	tables   []*dbinfo.Table
+	domains  map[string]sqlType
+	owned    map[dbinfo.ObjectKey]*dbinfo.Column // Sequences and the columns owning them
+	warnings []*dbinfo.Warning
+	file     string // File being parsed, for warnings and errors
+}
+
+// NewParser returns a parser of an empty database, whose unqualified names
+// are in the public schema
+func NewParser() *Parser {
+	return &Parser{
+		schema:  "public",
+		domains: make(map[string]sqlType),
+		owned:   make(map[dbinfo.ObjectKey]*dbinfo.Column),
+	}
+}
+
+// Parse applies the statements of sql, read from file, which names the file
+// in errors and warnings. It fails on the syntax errors of the statements
+// it models. Statements on tables that don't exist are skipped with a
+// warning, recorded in the Warnings of the schema.
+func (p *Parser) Parse(file, sql string) error {
+	p.file = file
+	statements, err := lex(sql)
+	if err != nil {
+		return p.fileError(err)
+	}
+	for _, tokens := range statements {
+		if err := p.statement(&stmt{tokens: tokens}); err != nil {
+			return p.fileError(err)
+		}
+	}
+	return nil
+}
+
+// fileError prefixes the line of a syntax error with the file
+func (p *Parser) fileError(err error) error {
+	var lineErr *lineError
+	if p.file != "" && errors.As(err, &lineErr) {
+		return fmt.Errorf("%s:%d: %s", p.file, lineErr.line, lineErr.msg)
+	}
+	return err
+}
+
+// warn records that a statement at line was skipped because object
+// doesn't exist
+func (p *Parser) warn(line int, object, format string, args ...any) {
+	position := fmt.Sprintf("line %d", line)
+	if p.file != "" {
+		position = fmt.Sprintf("%s:%d", p.file, line)
+	}
+	p.warnings = append(p.warnings, &dbinfo.Warning{
+		Phase:   dbinfo.PhaseDDL,
+		Object:  object,
+		Message: position + ": " + fmt.Sprintf(format, args...),
+	})
+}
+
+// DBInfo returns the schema built so far, named name. The parser keeps its
+// state, so more statements can be parsed afterwards.
+func (p *Parser) DBInfo(name string) *dbinfo.DBInfo {
+	for _, table := range p.tables {
+		p.finishTable(table)
+	}
+	info := (&dbinfo.DBInfo{Name: name, Generator: dbinfo.Generator(), Tables: p.tables, Warnings: p.warnings}).Clone()
+
+	for _, table := range info.Tables {
+		// A reference without columns is to the primary key
+		for _, fk := range table.ForeignKeys {
+			if len(fk.RefColumnNames) > 0 {
+				continue
+			}
+			if ref := findTable(info.Tables, fk.RefTableSchema, fk.RefTableName); ref != nil && ref.PrimaryKey != nil {
+				fk.RefColumnNames = slices.Clone(ref.PrimaryKey.Columns)
+			}
+		}
+		slices.SortStableFunc(table.Indexes, func(a, b *dbinfo.Index) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+		slices.SortStableFunc(table.ForeignKeys, func(a, b *dbinfo.ForeignKey) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+	}
+	slices.SortStableFunc(info.Tables, func(a, b *dbinfo.Table) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	info.BuildRelationships()
+	info.EnsureSlices()
+	return info
+}
+
+// nextvalPattern matches the default of a column taking its values from a
+// sequence, capturing the name of the sequence
+var nextvalPattern = regexp.MustCompile(`^nextval\('((?:[^']|'')+)'(?:::regclass)?\)$`)
+
+// finishTable sets what introspection derives from the statements: the
+// primary key flags, and the kinds and normalized defaults of the columns.
+// A column is serial when its default uses a sequence it owns.
+func (p *Parser) finishTable(table *dbinfo.Table) {
+	for _, column := range table.Columns {
+		column.IsPrimaryKey = table.PrimaryKey != nil && slices.Contains(table.PrimaryKey.Columns, column.Name)
+		if column.IsPrimaryKey {
+			column.IsNullable = false
+		}
+
+		serial := false
+		if match := nextvalPattern.FindStringSubmatch(column.DefaultValue); match != nil {
+			schema, name := table.Schema, strings.ReplaceAll(match[1], "''", "'")
+			if before, after, ok := strings.Cut(name, "."); ok {
+				schema, name = before, after
+			}
+			if p.owned[dbinfo.ObjectKey{Schema: schema, Name: name}] == column {
+				serial = true
+				column.DefaultValue = sequenceDefault(schema, name)
+			}
+		}
+		if literalPattern.MatchString(column.DefaultValue) {
+			column.DefaultValue += castSuffix(column)
+		}
+		switch {
+		case column.Identity != "":
+			column.Kind = dbinfo.ColumnIdentity
+			column.DefaultValue = ""
+		case serial:
+			column.Kind = dbinfo.ColumnSerial
+		case column.DefaultValue != "":
+			column.Kind = dbinfo.ColumnDefault
+		default:
+			column.Kind = dbinfo.ColumnPlain
+		}
+		column.NormalizedDefault = dbinfo.NormalizeDefault(column.DefaultValue, column.FullType)
+	}
+}
+
+// literalPattern matches a default that is a string constant
+var literalPattern = regexp.MustCompile(`^'(?:[^']|'')*'$`)
+
+// castedTypes are the types whose string constant defaults PostgreSQL
+// prints with a cast, such as 'pending'::character varying. Constants of
+// numeric and boolean types are printed as values.
+var castedTypes = []string{
+	"text", "character varying", "character", "json", "jsonb", "uuid", "bytea", "inet", "cidr", "date", "interval",
+	"timestamp without time zone", "timestamp with time zone", "time without time zone", "time with time zone",
+}
+
+// castSuffix returns the cast PostgreSQL adds to a string constant default
+// of column, which names the type without its modifiers
+func castSuffix(column *dbinfo.Column) string {
+	switch {
+	case column.Type == "character":
+		return "::bpchar"
+	case column.Type == "USER-DEFINED":
+		return "::" + column.FullType
+	case slices.Contains(castedTypes, column.Type):
+		return "::" + column.Type
+	}
+	return ""
+}
+
+// sequenceDefault returns the default of a column using a sequence, as
+// PostgreSQL prints it with public in the search path
+func sequenceDefault(schema, name string) string {
+	if schema != "public" {
+		name = schema + "." + name
+	}
+	return "nextval(" + QuoteLiteral(name) + "::regclass)"
+}
+
+// statement applies a statement, skipping the ones that don't change tables
+func (p *Parser) statement(s *stmt) error {
+	switch {
+	case s.accept("create"):
+		return p.create(s)
+	case s.accept("alter", "table"):
+		return p.alterTable(s)
+	case s.accept("alter", "index"):
+		return p.alterIndex(s)
+	case s.accept("alter", "sequence"):
+		return p.ownSequence(s)
+	case s.accept("drop"):
+		return p.drop(s)
+	case s.accept("comment", "on"):
+		return p.comment(s)
+	case s.accept("set"):
+		p.setSearchPath(s)
+	case s.accept("select"):
+		p.setConfig(s)
+	}
+	return nil
+}
+
+// create applies a CREATE statement
+func (p *Parser) create(s *stmt) error {
+	s.accept("or", "replace")
+	_ = s.accept("global") || s.accept("local")
+	temporary := s.accept("temporary") || s.accept("temp")
+	s.accept("unlogged")
+	switch {
+	case s.accept("table"):
+		if temporary {
+			return nil
+		}
+		return p.createTable(s)
+	case s.accept("unique", "index"):
+		return p.createIndex(s, true)
+	case s.accept("index"):
+		return p.createIndex(s, false)
+	case s.accept("sequence"):
+		return p.ownSequence(s)
+	case s.accept("domain"):
+		return p.createDomain(s)
+	}
+	return nil
+}
+
+// createTable applies CREATE TABLE, with its columns and constraints, a
+// copy of the columns of the tables it inherits from, or of the parent
+// table of a partition
+func (p *Parser) createTable(s *stmt) error {
+	line := s.peek().line
+	ifNotExists := s.accept("if", "not", "exists")
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	schema, name := p.qualify(parts)
+	if existing := p.table(schema, name); existing != nil {
+		if ifNotExists {
+			return nil
+		}
+		p.warn(line, dbinfo.QualifiedName(schema, name), "table already exists, replaced")
+		p.removeTable(existing)
+	}
+
+	table := &dbinfo.Table{Schema: schema, Name: name}
+	switch {
+	case s.accept("partition", "of"):
+		parts, err := s.name()
+		if err != nil {
+			return err
+		}
+		parent := p.lookup(parts)
+		if parent == nil {
+			p.warn(line, dbinfo.QualifiedName(p.qualify(parts)), "partitioned table does not exist, partition %s skipped", name)
+			return nil
+		}
+		p.tables = append(p.tables, table)
+		p.inherit(table, parent, true)
+		return nil
+	case s.isOp("("):
+	default:
+		p.warn(line, dbinfo.QualifiedName(schema, name), "CREATE TABLE without a column list is not parsed, table skipped")
+		return nil
+	}
+
+	p.tables = append(p.tables, table)
+	elements, err := s.group()
+	if err != nil {
+		return err
+	}
+	for _, element := range splitCommas(elements) {
+		e := &stmt{tokens: element}
+		switch {
+		case isConstraint(e):
+			err = p.tableConstraint(e, table)
+		case e.accept("like"):
+			err = p.like(e, table)
+		default:
+			err = p.columnDefinition(e, table)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.accept("inherits") {
+		group, err := s.group()
+		if err != nil {
+			return err
+		}
+		for _, item := range splitCommas(group) {
+			parts, err := (&stmt{tokens: item}).name()
+			if err != nil {
+				return err
+			}
+			if parent := p.lookup(parts); parent != nil {
+				p.inherit(table, parent, false)
+			} else {
+				p.warn(line, dbinfo.QualifiedName(p.qualify(parts)), "inherited table does not exist")
+			}
+		}
+	}
+	return nil
+}
+
+// inherit copies the columns of parent to table, before its own columns. A
+// partition also gets the primary key and foreign keys of its parent.
+func (p *Parser) inherit(table, parent *dbinfo.Table, partition bool) {
+	var columns []*dbinfo.Column
+	for _, column := range parent.Columns {
+		if !slices.ContainsFunc(table.Columns, func(c *dbinfo.Column) bool { return c.Name == column.Name }) {
+			c := *column
+			columns = append(columns, &c)
+		}
+	}
+	table.Columns = append(columns, table.Columns...)
+	if !partition {
+		return
+	}
+	if parent.PrimaryKey != nil {
+		p.setPrimaryKey(table, "", slices.Clone(parent.PrimaryKey.Columns))
+	}
+	for _, fk := range parent.ForeignKeys {
+		table.ForeignKeys = append(table.ForeignKeys, fk.Clone())
+	}
+}
+
+// like applies a LIKE clause of CREATE TABLE, copying the columns of
+// another table, with their defaults, identities and comments when the
+// clause includes them
+func (p *Parser) like(e *stmt, table *dbinfo.Table) error {
+	line := e.peek().line
+	parts, err := e.name()
+	if err != nil {
+		return err
+	}
+	source := p.lookup(parts)
+	if source == nil {
+		p.warn(line, dbinfo.QualifiedName(p.qualify(parts)), "table does not exist, LIKE skipped")
+		return nil
+	}
+	included := make(map[string]bool)
+	for !e.done() {
+		include := e.accept("including")
+		if !include && !e.accept("excluding") {
+			return e.unexpected("INCLUDING or EXCLUDING")
+		}
+		option, err := e.ident()
+		if err != nil {
+			return err
+		}
+		included[option] = include
+	}
+	for _, column := range source.Columns {
+		c := &dbinfo.Column{Name: column.Name, Type: column.Type, FullType: column.FullType, IsNullable: column.IsNullable}
+		if included["defaults"] || included["all"] {
+			c.DefaultValue = column.DefaultValue
+		}
+		if included["identity"] || included["all"] {
+			c.Identity = column.Identity
+		}
+		if included["comments"] || included["all"] {
+			c.Comment = column.Comment
+		}
+		table.Columns = append(table.Columns, c)
+	}
+	return nil
+}
+
+// isConstraint reports whether a table constraint comes next, rather than
+// a column
+func isConstraint(s *stmt) bool {
+	return s.is("constraint") || s.is("primary", "key") || s.is("unique") || s.is("foreign", "key") || s.is("check") ||
+		(s.is("exclude") && (s.at(s.pos+1).text == "(" || s.at(s.pos+1).text == "using"))
+}
+
+// tableConstraint applies a table constraint: primary keys, unique
+// constraints and foreign keys. Check and exclusion constraints aren't
+// modeled.
+func (p *Parser) tableConstraint(e *stmt, table *dbinfo.Table) error {
+	name := ""
+	if e.accept("constraint") {
+		var err error
+		if name, err = e.ident(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case e.accept("primary", "key"):
+		columns, err := e.identList()
+		if err != nil {
+			return err
+		}
+		p.setPrimaryKey(table, name, columns)
+	case e.accept("unique"):
+		nullsNotDistinct := acceptNullsDistinct(e)
+		columns, err := e.identList()
+		if err != nil {
+			return err
+		}
+		p.addUnique(table, name, columns, nullsNotDistinct)
+	case e.accept("foreign", "key"):
+		columns, err := e.identList()
+		if err != nil {
+			return err
+		}
+		if err := e.expect("references"); err != nil {
+			return err
+		}
+		return p.references(e, table, name, columns)
+	case e.accept("check"), e.accept("exclude"):
+	default:
+		return e.unexpected("a table constraint")
+	}
+	return nil
+}
+
+// acceptNullsDistinct consumes the NULLS [NOT] DISTINCT clause of a unique
+// constraint or index, reporting whether NULLs are not distinct
+func acceptNullsDistinct(s *stmt) bool {
+	if s.accept("nulls", "not", "distinct") {
+		return true
+	}
+	s.accept("nulls", "distinct")
+	return false
+}
+
+// columnDefinition applies the definition of a column: its name, type and
+// constraints
+func (p *Parser) columnDefinition(e *stmt, table *dbinfo.Table) error {
+	name, err := e.ident()
+	if err != nil {
+		return err
+	}
+	typ, err := p.parseType(e)
+	if err != nil {
+		return err
+	}
+	column := &dbinfo.Column{Name: name, Type: typ.Type, FullType: typ.FullType, IsNullable: true}
+	if typ.Serial {
+		sequence := chooseName(table.Name, name, "seq", p.relationTaken(table.Schema))
+		p.owned[dbinfo.ObjectKey{Schema: table.Schema, Name: sequence}] = column
+		column.DefaultValue = sequenceDefault(table.Schema, sequence)
+		column.IsNullable = false
+	}
+	table.Columns = append(table.Columns, column)
+
+	for !e.done() {
+		name := ""
+		if e.accept("constraint") {
+			if name, err = e.ident(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case e.accept("not", "null"):
+			column.IsNullable = false
+		case e.accept("null"):
+			column.IsNullable = true
+		case e.accept("default"):
+			column.DefaultValue = p.expression(e)
+		case e.accept("primary", "key"):
+			p.setPrimaryKey(table, name, []string{column.Name})
+		case e.accept("unique"):
+			p.addUnique(table, name, []string{column.Name}, acceptNullsDistinct(e))
+		case e.accept("references"):
+			err = p.references(e, table, name, []string{column.Name})
+		case e.accept("check"):
+			_, err = e.group()
+			e.accept("no", "inherit")
+		case e.accept("generated"):
+			err = acceptIdentity(e, column)
+		case e.accept("collate"):
+			err = acceptCollation(e, column)
+		case e.accept("compression"), e.accept("storage"), e.accept("initially"), e.accept("using", "index", "tablespace"):
+			e.next()
+		case e.accept("include"), e.accept("with"):
+			_, err = e.group()
+		case e.accept("deferrable"), e.accept("not", "deferrable"), e.accept("not", "valid"), e.accept("enforced"), e.accept("not", "enforced"):
+		default:
+			return e.unexpected("a column constraint")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acceptCollation consumes the name of a COLLATE clause, written as
+// introspection reads it: unqualified for the collations of pg_catalog and
+// empty for the default one
+func acceptCollation(e *stmt, column *dbinfo.Column) error {
+	name, err := e.name()
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(name) == 2 && name[0] != "pg_catalog":
+		column.Collation = TableName(name[0], name[1])
+	case name[len(name)-1] == "default":
+		column.Collation = ""
+	default:
+		column.Collation = QuoteIdent(name[len(name)-1])
+	}
+	return nil
+}
+
+// acceptIdentity consumes the rest of a GENERATED clause: an identity, or
+// the expression of a generated column, which has no default
+func acceptIdentity(e *stmt, column *dbinfo.Column) error {
+	switch {
+	case e.accept("always", "as", "identity"):
+		column.Identity = "ALWAYS"
+	case e.accept("by", "default", "as", "identity"):
+		column.Identity = "BY DEFAULT"
+	case e.accept("always", "as"):
+		if _, err := e.group(); err != nil {
+			return err
+		}
+		_ = e.accept("stored") || e.accept("virtual")
+		return nil
+	default:
+		return e.unexpected("AS IDENTITY")
+	}
+	column.IsNullable = false
+	// Sequence options
+	return e.skipGroup()
+}
+
+// columnKeywords start the constraints following a default in a column
+// definition
+var columnKeywords = []string{"constraint", "not", "null", "primary", "unique", "references", "check", "default", "generated", "collate", "deferrable", "initially"}
+
+// expression consumes an expression up to the next column constraint and
+// returns its text
+func (p *Parser) expression(e *stmt) string {
+	tokens := e.until(func(s *stmt) bool {
+		return slices.ContainsFunc(columnKeywords, func(word string) bool { return s.is(word) })
+	})
+	return p.render(tokens)
+}
+
+// references applies a REFERENCES clause, adding a foreign key from columns
+// of table
+func (p *Parser) references(e *stmt, table *dbinfo.Table, name string, columns []string) error {
+	parts, err := e.name()
+	if err != nil {
+		return err
+	}
+	refSchema, refName := p.qualify(parts)
+	fk := &dbinfo.ForeignKey{
+		Name:           name,
+		ColumnNames:    columns,
+		RefTableSchema: refSchema,
+		RefTableName:   refName,
+		OnUpdate:       "NO ACTION",
+		OnDelete:       "NO ACTION",
+	}
+	if e.isOp("(") {
+		if fk.RefColumnNames, err = e.identList(); err != nil {
+			return err
+		}
+	}
+	for {
+		switch {
+		case e.accept("match"):
+			e.next()
+			continue
+		case e.accept("on", "delete"):
+			if fk.OnDelete, err = referentialAction(e); err != nil {
+				return err
+			}
+			continue
+		case e.accept("on", "update"):
+			if fk.OnUpdate, err = referentialAction(e); err != nil {
+				return err
+			}
+			continue
+		}
+		break
+	}
+	if fk.Name == "" {
+		fk.Name = chooseName(table.Name, strings.Join(columns, "_"), "fkey", p.constraintTaken(table.Schema))
+	}
+	table.ForeignKeys = append(table.ForeignKeys, fk)
+	return nil
+}
+
+// referentialAction consumes the action of ON DELETE or ON UPDATE
+func referentialAction(e *stmt) (string, error) {
+	for _, action := range []string{"cascade", "restrict", "no action", "set null", "set default"} {
+		if e.accept(strings.Fields(action)...) {
+			// SET NULL and SET DEFAULT may list columns
+			if err := e.skipGroup(); err != nil {
+				return "", err
+			}
+			return strings.ToUpper(action), nil
+		}
+	}
+	return "", e.unexpected("a referential action")
+}
+
+// setPrimaryKey sets the primary key of table, named as PostgreSQL would
+// when name is empty
+func (p *Parser) setPrimaryKey(table *dbinfo.Table, name string, columns []string) {
+	if name == "" {
+		name = chooseName(table.Name, "", "pkey", p.relationTaken(table.Schema))
+	}
+	table.PrimaryKey = &dbinfo.PrimaryKey{Name: name, Columns: columns}
+	for _, column := range table.Columns {
+		if slices.Contains(columns, column.Name) {
+			column.IsNullable = false
+		}
+	}
+}
+
+// addUnique adds the index of a unique constraint to table
+func (p *Parser) addUnique(table *dbinfo.Table, name string, columns []string, nullsNotDistinct bool) {
+	if name == "" {
+		name = chooseName(table.Name, strings.Join(columns, "_"), "key", p.relationTaken(table.Schema))
+	}
+	index := &dbinfo.Index{Name: name, Unique: true, Columns: columns, NullsNotDistinct: nullsNotDistinct}
+	for _, column := range columns {
+		index.Keys = append(index.Keys, &dbinfo.IndexKey{Column: column})
+	}
+	table.Indexes = append(table.Indexes, index)
+}
+
+// createIndex applies CREATE INDEX
+func (p *Parser) createIndex(s *stmt, unique bool) error {
+	line := s.peek().line
+	s.accept("concurrently")
+	ifNotExists := s.accept("if", "not", "exists")
+	name := ""
+	if !s.is("on") {
+		var err error
+		if name, err = s.ident(); err != nil {
+			return err
+		}
+	}
+	if err := s.expect("on"); err != nil {
+		return err
+	}
+	s.accept("only")
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	table := p.lookup(parts)
+	if table == nil {
+		p.warn(line, dbinfo.QualifiedName(p.qualify(parts)), "table does not exist, index %s skipped", name)
+		return nil
+	}
+	if s.accept("using") {
+		s.next()
+	}
+	group, err := s.group()
+	if err != nil {
+		return err
+	}
+
+	index := &dbinfo.Index{Unique: unique, Columns: []string{}}
+	var keyNames, expressions []string
+	for _, item := range splitCommas(group) {
+		key := p.indexKey(&stmt{tokens: item})
+		index.Keys = append(index.Keys, key)
+		if key.Column != "" {
+			index.Columns = append(index.Columns, key.Column)
+			keyNames = append(keyNames, key.Column)
+		} else {
+			expressions = append(expressions, key.Expression)
+			keyNames = append(keyNames, expressionName(item))
+		}
+	}
+	index.Expression = strings.Join(expressions, ", ")
+	if s.accept("include") {
+		if _, err := s.group(); err != nil {
+			return err
+		}
+	}
+	index.NullsNotDistinct = acceptNullsDistinct(s)
+
+	if name == "" {
+		name = chooseName(table.Name, strings.Join(keyNames, "_"), "idx", p.relationTaken(table.Schema))
+	} else if slices.ContainsFunc(table.Indexes, func(i *dbinfo.Index) bool { return i.Name == name }) {
+		if !ifNotExists {
+			p.warn(line, dbinfo.QualifiedName(table.Schema, name), "index already exists")
+		}
+		return nil
+	}
+	index.Name = name
+	table.Indexes = append(table.Indexes, index)
+	return nil
+}
+
+// indexKey reads a key of an index: a column, or an expression, followed by
+// an optional collation, operator class and ordering
+func (p *Parser) indexKey(k *stmt) *dbinfo.IndexKey {
+	first, second := k.peek(), k.at(k.pos+1)
+	switch {
+	case first.kind == opToken && first.text == "(":
+		group, _ := k.group()
+		return &dbinfo.IndexKey{Expression: p.render(group)}
+	case (first.kind == identToken || first.kind == quotedToken) && (second.kind == endToken || second.kind == identToken || second.kind == quotedToken):
+		return &dbinfo.IndexKey{Column: first.text}
+	case first.kind == identToken && second.text == "(":
+		// A function call
+		k.next()
+		k.group()
+		return &dbinfo.IndexKey{Expression: p.render(k.tokens[:k.pos])}
+	}
+	return &dbinfo.IndexKey{Expression: p.render(k.rest())}
+}
+
+// expressionName returns the name PostgreSQL gives an index expression in
+// the index name: the name of the function it calls, or expr
+func expressionName(tokens []token) string {
+	for len(tokens) > 2 && tokens[0].text == "(" && tokens[len(tokens)-1].text == ")" {
+		tokens = tokens[1 : len(tokens)-1]
+	}
+	i := 0
+	for i+2 < len(tokens) && tokens[i+1].text == "." {
+		i += 2
+	}
+	if i+1 < len(tokens) && (tokens[i].kind == identToken || tokens[i].kind == quotedToken) && tokens[i+1].text == "(" {
+		return tokens[i].text
+	}
+	return "expr"
+}
+
+// alterTable applies the actions of ALTER TABLE
+func (p *Parser) alterTable(s *stmt) error {
+	line := s.peek().line
+	ifExists := s.accept("if", "exists")
+	s.accept("only")
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	table := p.lookup(parts)
+	if table == nil {
+		if !ifExists {
+			p.warn(line, dbinfo.QualifiedName(p.qualify(parts)), "table does not exist, ALTER TABLE skipped")
+		}
+		return nil
+	}
+	s.accept("*")
+	for _, action := range splitCommas(s.rest()) {
+		if err := p.alterAction(&stmt{tokens: action}, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alterAction applies an action of ALTER TABLE
+func (p *Parser) alterAction(a *stmt, table *dbinfo.Table) error {
+	line := a.peek().line
+	switch {
+	case a.accept("add"):
+		if isConstraint(a) {
+			return p.tableConstraint(a, table)
+		}
+		a.accept("column")
+		if a.accept("if", "not", "exists") && column(table, a.peek().text) != nil {
+			return nil
+		}
+		return p.columnDefinition(a, table)
+
+	case a.accept("drop", "constraint"):
+		// Check constraints aren't modeled, so a constraint that isn't
+		// found is no reason for a warning
+		a.accept("if", "exists")
+		name, err := a.ident()
+		if err != nil {
+			return err
+		}
+		p.dropConstraint(table, name)
+
+	case a.accept("drop"):
+		a.accept("column")
+		ifExists := a.accept("if", "exists")
+		name, err := a.ident()
+		if err != nil {
+			return err
+		}
+		if column(table, name) == nil {
+			if !ifExists {
+				p.warn(line, dbinfo.QualifiedName(table.Schema, table.Name)+"."+name, "column does not exist, DROP COLUMN skipped")
+			}
+			return nil
+		}
+		p.dropColumn(table, name)
+
+	case a.accept("alter"):
+		a.accept("column")
+		name, err := a.ident()
+		if err != nil {
+			return err
+		}
+		c := column(table, name)
+		if c == nil {
+			p.warn(line, dbinfo.QualifiedName(table.Schema, table.Name)+"."+name, "column does not exist, ALTER COLUMN skipped")
+			return nil
+		}
+		return p.alterColumn(a, c)
+
+	case a.accept("rename", "to"):
+		name, err := a.ident()
+		if err != nil {
+			return err
+		}
+		p.moveTable(table, table.Schema, name)
+
+	case a.accept("set", "schema"):
+		schema, err := a.ident()
+		if err != nil {
+			return err
+		}
+		p.moveTable(table, schema, table.Name)
+
+	case a.accept("rename", "constraint"):
+		from, to, err := renaming(a)
+		if err != nil {
+			return err
+		}
+		renameConstraint(table, from, to)
+
+	case a.accept("rename"):
+		a.accept("column")
+		from, to, err := renaming(a)
+		if err != nil {
+			return err
+		}
+		p.renameColumn(table, from, to)
+	}
+	// Other actions, such as OWNER TO or ENABLE TRIGGER, aren't modeled
+	return nil
+}
+
+// renaming consumes the "old TO new" of a RENAME
+func renaming(a *stmt) (string, string, error) {
+	from, err := a.ident()
+	if err != nil {
+		return "", "", err
+	}
+	if err := a.expect("to"); err != nil {
+		return "", "", err
+	}
+	to, err := a.ident()
+	return from, to, err
+}
+
+// alterColumn applies ALTER COLUMN
+func (p *Parser) alterColumn(a *stmt, column *dbinfo.Column) error {
+	switch {
+	case a.accept("type"), a.accept("set", "data", "type"):
+		typ, err := p.parseType(a)
+		if err != nil {
+			return err
+		}
+		column.Type, column.FullType = typ.Type, typ.FullType
+	case a.accept("set", "default"):
+		column.DefaultValue = p.render(a.rest())
+	case a.accept("drop", "default"):
+		column.DefaultValue = ""
+	case a.accept("set", "not", "null"):
+		column.IsNullable = false
+	case a.accept("drop", "not", "null"):
+		column.IsNullable = true
+	case a.accept("add", "generated"):
+		return acceptIdentity(a, column)
+	case a.accept("set", "generated", "always"):
+		column.Identity = "ALWAYS"
+	case a.accept("set", "generated", "by", "default"):
+		column.Identity = "BY DEFAULT"
+	case a.accept("drop", "identity"):
+		column.Identity = ""
+	}
+	return nil
+}
+
+// dropColumn removes a column from table, with the primary key, indexes
+// and foreign keys using it
+func (p *Parser) dropColumn(table *dbinfo.Table, name string) {
+	table.Columns = slices.DeleteFunc(table.Columns, func(c *dbinfo.Column) bool { return c.Name == name })
+	if table.PrimaryKey != nil && slices.Contains(table.PrimaryKey.Columns, name) {
+		table.PrimaryKey = nil
+	}
+	table.Indexes = slices.DeleteFunc(table.Indexes, func(i *dbinfo.Index) bool { return slices.Contains(i.Columns, name) })
+	table.ForeignKeys = slices.DeleteFunc(table.ForeignKeys, func(fk *dbinfo.ForeignKey) bool { return slices.Contains(fk.ColumnNames, name) })
+	for _, other := range p.tables {
+		other.ForeignKeys = slices.DeleteFunc(other.ForeignKeys, func(fk *dbinfo.ForeignKey) bool {
+			return references(fk, table) && slices.Contains(fk.RefColumnNames, name)
+		})
+	}
+}
+
+// renameColumn renames a column of table, and its uses by keys and indexes
+func (p *Parser) renameColumn(table *dbinfo.Table, from, to string) {
+	rename := func(names []string) {
+		for i, name := range names {
+			if name == from {
+				names[i] = to
+			}
+		}
+	}
+	if c := column(table, from); c != nil {
+		c.Name = to
+	}
+	if table.PrimaryKey != nil {
+		rename(table.PrimaryKey.Columns)
+	}
+	for _, index := range table.Indexes {
+		rename(index.Columns)
+		for _, key := range index.Keys {
+			if key.Column == from {
+				key.Column = to
+			}
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		rename(fk.ColumnNames)
+	}
+	for _, other := range p.tables {
+		for _, fk := range other.ForeignKeys {
+			if references(fk, table) {
+				rename(fk.RefColumnNames)
+			}
+		}
+	}
+}
+
+// moveTable renames table or moves it to another schema, updating the
+// foreign keys referencing it
+func (p *Parser) moveTable(table *dbinfo.Table, schema, name string) {
+	for _, other := range p.tables {
+		for _, fk := range other.ForeignKeys {
+			if references(fk, table) {
+				fk.RefTableSchema, fk.RefTableName = schema, name
+			}
+		}
+	}
+	table.Schema, table.Name = schema, name
+}
+
+// dropConstraint removes the primary key, unique constraint or foreign key
+// name from table
+func (p *Parser) dropConstraint(table *dbinfo.Table, name string) {
+	if table.PrimaryKey != nil && table.PrimaryKey.Name == name {
+		table.PrimaryKey = nil
+	}
+	table.Indexes = slices.DeleteFunc(table.Indexes, func(i *dbinfo.Index) bool { return i.Unique && i.Name == name })
+	table.ForeignKeys = slices.DeleteFunc(table.ForeignKeys, func(fk *dbinfo.ForeignKey) bool { return fk.Name == name })
+}
+
+// renameConstraint renames the primary key, unique constraint or foreign
+// key of table
+func renameConstraint(table *dbinfo.Table, from, to string) {
+	if table.PrimaryKey != nil && table.PrimaryKey.Name == from {
+		table.PrimaryKey.Name = to
+	}
+	for _, index := range table.Indexes {
+		if index.Name == from {
+			index.Name = to
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		if fk.Name == from {
+			fk.Name = to
+		}
+	}
+}
+
+// alterIndex applies ALTER INDEX ... RENAME TO, which also renames the
+// constraint of the index
+func (p *Parser) alterIndex(s *stmt) error {
+	s.accept("if", "exists")
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	if !s.accept("rename", "to") {
+		return nil
+	}
+	to, err := s.ident()
+	if err != nil {
+		return err
+	}
+	schema, from := p.qualify(parts)
+	for _, table := range p.tables {
+		if table.Schema == schema {
+			renameConstraint(table, from, to)
+		}
+	}
+	return nil
+}
+
+// ownSequence records the column a sequence is owned by, from the OWNED BY
+// option of CREATE or ALTER SEQUENCE
+func (p *Parser) ownSequence(s *stmt) error {
+	_ = s.accept("if", "not", "exists") || s.accept("if", "exists")
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	schema, name := p.qualify(parts)
+	s.until(func(s *stmt) bool { return s.is("owned", "by") })
+	if !s.accept("owned", "by") {
+		return nil
+	}
+	if s.accept("none") {
+		delete(p.owned, dbinfo.ObjectKey{Schema: schema, Name: name})
+		return nil
+	}
+	owner, err := s.name()
+	if err != nil {
+		return err
+	}
+	if len(owner) < 2 {
+		return s.errorf("expected a table column after OWNED BY")
+	}
+	if table := p.lookup(owner[:len(owner)-1]); table != nil {
+		if c := column(table, owner[len(owner)-1]); c != nil {
+			p.owned[dbinfo.ObjectKey{Schema: schema, Name: name}] = c
+		}
+	}
+	return nil
+}
+
+// createDomain records the type of a domain, which columns of the domain
+// report as their type
+func (p *Parser) createDomain(s *stmt) error {
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	s.accept("as")
+	typ, err := p.parseType(s)
+	if err != nil {
+		return err
+	}
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && parts[len(parts)-2] != "public" {
+		name = parts[len(parts)-2] + "." + name
+	}
+	p.domains[name] = typ
+	return nil
+}
+
+// drop applies DROP TABLE, DROP INDEX and DROP SCHEMA
+func (p *Parser) drop(s *stmt) error {
+	line := s.peek().line
+	var kind string
+	switch {
+	case s.accept("table"):
+		kind = "table"
+	case s.accept("index"):
+		kind = "index"
+		s.accept("concurrently")
+	case s.accept("schema"):
+		kind = "schema"
+	default:
+		return nil
+	}
+	ifExists := s.accept("if", "exists")
+	for _, item := range splitCommas(s.until(func(s *stmt) bool { return s.is("cascade") || s.is("restrict") })) {
+		parts, err := (&stmt{tokens: item}).name()
+		if err != nil {
+			return err
+		}
+		schema, name := p.qualify(parts)
+		switch kind {
+		case "table":
+			if table := p.table(schema, name); table != nil {
+				p.removeTable(table)
+			} else if !ifExists {
+				p.warn(line, dbinfo.QualifiedName(schema, name), "table does not exist, DROP TABLE skipped")
+			}
+		case "index":
+			for _, table := range p.tables {
+				if table.Schema == schema {
+					table.Indexes = slices.DeleteFunc(table.Indexes, func(i *dbinfo.Index) bool { return i.Name == name })
+				}
+			}
+		case "schema":
+			for _, table := range slices.Clone(p.tables) {
+				if table.Schema == parts[len(parts)-1] {
+					p.removeTable(table)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// removeTable removes table and the foreign keys referencing it
+func (p *Parser) removeTable(table *dbinfo.Table) {
+	p.tables = slices.DeleteFunc(p.tables, func(t *dbinfo.Table) bool { return t == table })
+	for _, other := range p.tables {
+		other.ForeignKeys = slices.DeleteFunc(other.ForeignKeys, func(fk *dbinfo.ForeignKey) bool { return references(fk, table) })
+	}
+}
+
+// comment applies COMMENT ON TABLE and COMMENT ON COLUMN
+func (p *Parser) comment(s *stmt) error {
+	line := s.peek().line
+	isColumn := s.accept("column")
+	if !isColumn && !s.accept("table") {
+		return nil
+	}
+	parts, err := s.name()
+	if err != nil {
+		return err
+	}
+	if err := s.expect("is"); err != nil {
+		return err
+	}
+	var text string
+	switch t := s.next(); {
+	case t.kind == stringToken:
+		text = stringValue(t)
+	case t.kind == identToken && t.text == "null":
+	default:
+		s.pos--
+		return s.unexpected("a string or NULL")
+	}
+
+	tableParts := parts
+	if isColumn {
+		if len(parts) < 2 {
+			return s.errorf("expected a table column to comment on")
+		}
+		tableParts = parts[:len(parts)-1]
+	}
+	table := p.lookup(tableParts)
+	if table == nil {
+		p.warn(line, dbinfo.QualifiedName(p.qualify(tableParts)), "table does not exist, COMMENT skipped")
+		return nil
+	}
+	if !isColumn {
+		table.Comment = text
+		return nil
+	}
+	c := column(table, parts[len(parts)-1])
+	if c == nil {
+		p.warn(line, dbinfo.QualifiedName(table.Schema, table.Name)+"."+parts[len(parts)-1], "column does not exist, COMMENT skipped")
+		return nil
+	}
+	c.Comment = text
+	return nil
+}
+
+// setSearchPath applies SET search_path, whose first schema unqualified
+// names are created in
+func (p *Parser) setSearchPath(s *stmt) {
+	_ = s.accept("session") || s.accept("local")
+	if !s.accept("search_path") {
+		return
+	}
+	if !s.accept("to") && !s.isOp("=") {
+		return
+	}
+	if s.isOp("=") {
+		s.next()
+	}
+	var schemas []string
+	for _, item := range splitCommas(s.rest()) {
+		t := item[0]
+		if t.kind == stringToken {
+			t.text = stringValue(t)
+		}
+		schemas = append(schemas, t.text)
+	}
+	p.useSearchPath(schemas)
+}
+
+// setConfig applies the search_path set by set_config, as pg_dump does
+func (p *Parser) setConfig(s *stmt) {
+	var values []string
+	for _, t := range s.rest() {
+		if t.kind == stringToken {
+			values = append(values, stringValue(t))
+		}
+	}
+	if len(values) >= 2 && values[0] == "search_path" {
+		var schemas []string
+		for _, schema := range strings.Split(values[1], ",") {
+			schemas = append(schemas, strings.Trim(strings.TrimSpace(schema), `"`))
+		}
+		p.useSearchPath(schemas)
+	}
+}
+
+// useSearchPath makes the first schema of a search path, other than
+// $user, the schema of unqualified names. An empty search path leaves it
+// unchanged: pg_dump clears it and qualifies every name.
+func (p *Parser) useSearchPath(schemas []string) {
+	for _, schema := range schemas {
+		if schema != "" && schema != "$user" {
+			p.schema = schema
+			return
+		}
+	}
+}
+
+// qualify returns the schema and name of a possibly qualified name
+func (p *Parser) qualify(parts []string) (string, string) {
+	if len(parts) == 1 {
+		return p.schema, parts[0]
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// lookup returns the table with a possibly qualified name, nil if there is
+// none
+func (p *Parser) lookup(parts []string) *dbinfo.Table {
+	return p.table(p.qualify(parts))
+}
+
+// table returns the table schema.name, nil if there is none
+func (p *Parser) table(schema, name string) *dbinfo.Table {
+	return findTable(p.tables, schema, name)
+}
+
+func findTable(tables []*dbinfo.Table, schema, name string) *dbinfo.Table {
+	for _, table := range tables {
+		if table.Schema == schema && table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// column returns the column name of table, nil if there is none
+func column(table *dbinfo.Table, name string) *dbinfo.Column {
+	for _, c := range table.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// references reports whether fk references table
+func references(fk *dbinfo.ForeignKey, table *dbinfo.Table) bool {
+	return fk.RefTableSchema == table.Schema && fk.RefTableName == table.Name
+}
+
+// relationTaken returns whether a name is used by a table, index or
+// sequence of schema, which share a namespace
+func (p *Parser) relationTaken(schema string) func(string) bool {
+	return func(name string) bool {
+		if _, ok := p.owned[dbinfo.ObjectKey{Schema: schema, Name: name}]; ok {
+			return true
+		}
+		for _, table := range p.tables {
+			if table.Schema != schema {
+				continue
+			}
+			if table.Name == name || (table.PrimaryKey != nil && table.PrimaryKey.Name == name) ||
+				slices.ContainsFunc(table.Indexes, func(i *dbinfo.Index) bool { return i.Name == name }) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// constraintTaken returns whether a name is used by a constraint of schema
+func (p *Parser) constraintTaken(schema string) func(string) bool {
+	return func(name string) bool {
+		for _, table := range p.tables {
+			if table.Schema == schema && ((table.PrimaryKey != nil && table.PrimaryKey.Name == name) ||
+				slices.ContainsFunc(table.Indexes, func(i *dbinfo.Index) bool { return i.Unique && i.Name == name }) ||
+				slices.ContainsFunc(table.ForeignKeys, func(fk *dbinfo.ForeignKey) bool { return fk.Name == name })) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// chooseName returns the name PostgreSQL gives an object it names, such as
+// orders_customer_id_fkey: see objectName. Names taken get a number after
+// the label, as in orders_customer_id_fkey1.
+func chooseName(name1, name2, label string, taken func(string) bool) string {
+	name := objectName(name1, name2, label)
+	for i := 1; taken(name); i++ {
+		name = objectName(name1, name2, label+strconv.Itoa(i))
+	}
+	return name
+}
+
+// objectName joins a table name, the columns of an object and a label with
+// underscores, shortening the longest of the first two until the name fits
+// in maxNameLength bytes, as PostgreSQL's makeObjectName does
+func objectName(name1, name2, label string) string {
+	overhead := len(label) + 1
+	if name2 != "" {
+		overhead++
+	}
+	length1, length2 := len(name1), len(name2)
+	for length1+length2 > maxNameLength-overhead {
+		if length1 > length2 {
+			length1--
+		} else {
+			length2--
+		}
+	}
+	name := clip(name1, length1)
+	if name2 != "" {
+		name += "_" + clip(name2, length2)
+	}
+	return name + "_" + label
+}
+
+// clip shortens s to at most n bytes without splitting a character
+func clip(s string, n int) string {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// sqlValueFunctions are the functions PostgreSQL prints in upper case
+var sqlValueFunctions = map[string]bool{
+	"current_date": true, "current_time": true, "current_timestamp": true, "localtime": true, "localtimestamp": true,
+	"current_role": true, "current_user": true, "session_user": true, "current_catalog": true, "current_schema": true,
+}
+
+// render returns the text of an expression, with the spacing it was written
+// with, keywords in lower case and the types of casts as PostgreSQL names
+// them
+func (p *Parser) render(tokens []token) string {
+	return renderTokens(tokens, p)
+}
+
+// renderTokens returns the text of tokens, normalizing the types of casts
+// with p when it is not nil
+func renderTokens(tokens []token, p *Parser) string {
+	var b strings.Builder
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if i > 0 && t.space {
+			b.WriteByte(' ')
+		}
+		switch {
+		case t.kind == identToken && sqlValueFunctions[t.text]:
+			b.WriteString(strings.ToUpper(t.text))
+		case t.kind == quotedToken:
+			b.WriteString(QuoteIdent(t.text))
+		case t.kind == opToken && t.text == "::" && p != nil:
+			b.WriteString(t.text)
+			s := &stmt{tokens: tokens[i+1:]}
+			if typ, err := p.parseType(s); err == nil {
+				b.WriteString(typ.FullType)
+				i += s.pos
+			}
+		default:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// Parse builds the schema of the DDL statements of sql, naming it name
+func Parse(name, sql string) (*dbinfo.DBInfo, error) {
+	p := NewParser()
+	if err := p.Parse("", sql); err != nil {
+		return nil, err
+	}
+	return p.DBInfo(name), nil
+}
+
+// ParseFiles builds a schema from SQL files, applying them in order. A
+// directory stands for its .sql files, such as the migrations of an
+// application, sorted by the number they start with and then by name;
+// migrations reverting others, named *.down.sql, are left out, as are the
+// down sections of goose and sql-migrate files. The schema is named after
+// the first path.
+func ParseFiles(paths ...string) (*dbinfo.DBInfo, error) {
+	p := NewParser()
+	for _, path := range paths {
+		files, err := sqlFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("reading DDL: %w", err)
+			}
+			if err := p.Parse(file, string(data)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	name := ""
+	if len(paths) > 0 {
+		name = strings.TrimSuffix(filepath.Base(paths[0]), ".sql")
+	}
+	return p.DBInfo(name), nil
+}
+
+// sqlFiles returns the files of path: path itself, or the migrations of a
+// directory in order
+func sqlFiles(path string) ([]string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading DDL: %w", err)
+	}
+	if !fi.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading DDL: %w", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasSuffix(name, ".sql") && !strings.HasSuffix(name, ".down.sql") {
+			names = append(names, name)
+		}
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(migrationNumber(a), migrationNumber(b)), cmp.Compare(a, b))
+	})
+	files := make([]string, len(names))
+	for i, name := range names {
+		files[i] = filepath.Join(path, name)
+	}
+	return files, nil
+}
+
+// migrationNumber returns the number a migration file name starts with,
+// such as 12 for 12_add_orders.up.sql, or -1
+func migrationNumber(name string) int64 {
+	end := 0
+	for end < len(name) && isDigit(name[end]) {
+		end++
+	}
+	n, err := strconv.ParseInt(name[:end], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,270 @@
+package ddl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/sample"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// TestParseSample parses the sample schema, which testdata/demo.yaml was
+// introspected from
+func TestParseSample(t *testing.T) {
+	want, err := snapshot.LoadFile("../testdata/demo.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse("demo", sample.Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Generator = ""
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parsed sample schema differs from the snapshot (-want +got):\n%s", diff)
+	}
+}
+
+// TestParseDump parses statements as pg_dump writes them
+func TestParseDump(t *testing.T) {
+	sql := `
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+\restrict abc123
+
+CREATE FUNCTION sales.touch() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    NEW.updated_at := now(); -- a semicolon inside the body
+    RETURN NEW;
+END;
+$$;
+
+CREATE TYPE sales.status AS ENUM ('open', 'paid');
+
+CREATE TABLE sales."Customers" (
+    id bigint NOT NULL,
+    email text COLLATE pg_catalog."C",
+    tags text[] DEFAULT '{}'::text[]
+);
+
+CREATE SEQUENCE sales."Customers_id_seq"
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE sales."Customers_id_seq" OWNED BY sales."Customers".id;
+
+CREATE TABLE sales.invoices (
+    id integer NOT NULL,
+    customer_id bigint,
+    status sales.status DEFAULT 'open'::sales.status NOT NULL,
+    total numeric(12,2),
+    issued_at timestamp(3) with time zone DEFAULT now()
+);
+
+ALTER TABLE sales.invoices ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
+    SEQUENCE NAME sales.invoices_id_seq
+    START WITH 1
+    CACHE 1
+);
+
+ALTER TABLE ONLY sales."Customers" ALTER COLUMN id SET DEFAULT nextval('sales."Customers_id_seq"'::regclass);
+ALTER TABLE ONLY sales."Customers" ADD CONSTRAINT "Customers_pkey" PRIMARY KEY (id);
+ALTER TABLE ONLY sales.invoices ADD CONSTRAINT invoices_pkey PRIMARY KEY (id);
+CREATE UNIQUE INDEX customers_email ON sales."Customers" USING btree (lower(email)) NULLS NOT DISTINCT;
+CREATE INDEX invoices_customer_status ON sales.invoices USING btree (customer_id, status DESC);
+ALTER TABLE ONLY sales.invoices
+    ADD CONSTRAINT invoices_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES sales."Customers"(id) ON DELETE SET NULL;
+COMMENT ON TABLE sales.invoices IS 'Issued invoices, as sent to the customer''s address';
+COMMENT ON COLUMN sales.invoices.total IS E'Total\nwith taxes';
+ALTER TABLE sales.invoices OWNER TO app;
+GRANT SELECT ON TABLE sales.invoices TO reporting;
+`
+	info, err := Parse("billing", sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Tables) != 2 || len(info.Warnings) != 0 {
+		t.Fatalf("Expected 2 tables and no warnings, got %d tables and %v", len(info.Tables), info.Warnings)
+	}
+
+	customers, invoices := info.Tables[0], info.Tables[1]
+	want := []*dbinfo.Column{
+		{Name: "id", Type: "bigint", FullType: "bigint", DefaultValue: `nextval('sales."Customers_id_seq"'::regclass)`,
+			NormalizedDefault: `nextval('sales."Customers_id_seq"')`, IsPrimaryKey: true, Kind: dbinfo.ColumnDefault},
+		{Name: "email", Type: "text", FullType: "text", IsNullable: true, Kind: dbinfo.ColumnPlain, Collation: `"C"`},
+		{Name: "tags", Type: "ARRAY", FullType: "text[]", IsNullable: true, DefaultValue: "'{}'::text[]", NormalizedDefault: "'{}'", Kind: dbinfo.ColumnDefault},
+	}
+	if diff := cmp.Diff(want, customers.Columns); diff != "" {
+		t.Errorf("Unexpected columns of %s (-want +got):\n%s", customers.Name, diff)
+	}
+	want = []*dbinfo.Column{
+		{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true, Kind: dbinfo.ColumnIdentity, Identity: "ALWAYS"},
+		{Name: "customer_id", Type: "bigint", FullType: "bigint", IsNullable: true, Kind: dbinfo.ColumnPlain},
+		{Name: "status", Type: "USER-DEFINED", FullType: "sales.status", DefaultValue: "'open'::sales.status", NormalizedDefault: "'open'", Kind: dbinfo.ColumnDefault},
+		{Name: "total", Type: "numeric", FullType: "numeric(12,2)", IsNullable: true, Kind: dbinfo.ColumnPlain, Comment: "Total\nwith taxes"},
+		{Name: "issued_at", Type: "timestamp with time zone", FullType: "timestamp(3) with time zone", IsNullable: true,
+			DefaultValue: "now()", NormalizedDefault: "now()", Kind: dbinfo.ColumnDefault},
+	}
+	if diff := cmp.Diff(want, invoices.Columns); diff != "" {
+		t.Errorf("Unexpected columns of %s (-want +got):\n%s", invoices.Name, diff)
+	}
+	if invoices.Comment != "Issued invoices, as sent to the customer's address" {
+		t.Errorf("Unexpected table comment %q", invoices.Comment)
+	}
+
+	wantIndexes := []*dbinfo.Index{
+		{Name: "customers_email", Unique: true, Columns: []string{}, Expression: "lower(email)", Keys: []*dbinfo.IndexKey{{Expression: "lower(email)"}}, NullsNotDistinct: true},
+	}
+	if diff := cmp.Diff(wantIndexes, customers.Indexes); diff != "" {
+		t.Errorf("Unexpected indexes (-want +got):\n%s", diff)
+	}
+	if got := invoices.Indexes[0].Columns; !cmp.Equal(got, []string{"customer_id", "status"}) {
+		t.Errorf("Unexpected index columns %v", got)
+	}
+	fk := invoices.ForeignKeys[0]
+	if fk.RefTableSchema != "sales" || fk.RefTableName != "Customers" || fk.OnDelete != "SET NULL" || fk.OnUpdate != "NO ACTION" {
+		t.Errorf("Unexpected foreign key %+v", fk)
+	}
+	if len(customers.HasMany) != 1 || customers.HasMany[0].Table != "invoices" {
+		t.Errorf("Expected customers to have many invoices, got %+v", customers.HasMany)
+	}
+}
+
+func TestParseMigrations(t *testing.T) {
+	dir := t.TempDir()
+	migrations := map[string]string{
+		"1_init.up.sql": `
+			CREATE TABLE accounts (id serial PRIMARY KEY, name varchar(50) NOT NULL UNIQUE);
+			CREATE TABLE users (
+				id bigint GENERATED BY DEFAULT AS IDENTITY,
+				account_id integer REFERENCES accounts,
+				email text NOT NULL,
+				CONSTRAINT users_pk PRIMARY KEY (id),
+				UNIQUE NULLS NOT DISTINCT (account_id, email)
+			);
+			CREATE INDEX ON users (lower(email));`,
+		"1_init.down.sql": `DROP TABLE users; DROP TABLE accounts;`,
+		"2_rename.up.sql": `
+			ALTER TABLE accounts RENAME TO organizations;
+			ALTER TABLE users RENAME COLUMN account_id TO organization_id;
+			ALTER TABLE users ADD COLUMN IF NOT EXISTS email text, ADD COLUMN nickname text DEFAULT 'none';`,
+		"10_cleanup.up.sql": `
+			-- +goose Up
+			ALTER TABLE users DROP COLUMN nickname, ALTER COLUMN email TYPE citext, ALTER COLUMN email DROP NOT NULL;
+			ALTER TABLE missing ADD COLUMN x integer;
+			ALTER TABLE IF EXISTS also_missing ADD COLUMN x integer;
+			-- +goose Down
+			DROP TABLE users;`,
+	}
+	for name, sql := range migrations {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := ParseFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, table := range info.Tables {
+		names = append(names, table.Name)
+	}
+	if !cmp.Equal(names, []string{"organizations", "users"}) {
+		t.Fatalf("Unexpected tables %v", names)
+	}
+
+	organizations, users := info.Tables[0], info.Tables[1]
+	if c := organizations.Columns[0]; c.Kind != dbinfo.ColumnSerial || c.DefaultValue != "nextval('accounts_id_seq'::regclass)" {
+		t.Errorf("Expected a serial column on the sequence of accounts, got %+v", c)
+	}
+	if organizations.PrimaryKey.Name != "accounts_pkey" || organizations.Indexes[0].Name != "accounts_name_key" {
+		t.Errorf("Expected constraints to keep their names, got %+v %+v", organizations.PrimaryKey, organizations.Indexes[0])
+	}
+
+	var columns []string
+	for _, c := range users.Columns {
+		columns = append(columns, c.Name+" "+c.FullType+" "+string(c.Kind))
+	}
+	want := []string{"id bigint identity", "organization_id integer plain", "email citext plain"}
+	if diff := cmp.Diff(want, columns); diff != "" {
+		t.Errorf("Unexpected columns (-want +got):\n%s", diff)
+	}
+	if !users.Columns[2].IsNullable {
+		t.Error("Expected email to be nullable")
+	}
+	var indexes []string
+	for _, index := range users.Indexes {
+		indexes = append(indexes, index.Name)
+	}
+	if diff := cmp.Diff([]string{"users_account_id_email_key", "users_lower_idx"}, indexes); diff != "" {
+		t.Errorf("Unexpected indexes (-want +got):\n%s", diff)
+	}
+	if got := users.Indexes[0].Columns; !cmp.Equal(got, []string{"organization_id", "email"}) {
+		t.Errorf("Expected the renamed column in the index, got %v", got)
+	}
+	fk := users.ForeignKeys[0]
+	if fk.Name != "users_account_id_fkey" || fk.RefTableName != "organizations" || !cmp.Equal(fk.RefColumnNames, []string{"id"}) {
+		t.Errorf("Expected the foreign key to follow the renamed table to its primary key, got %+v", fk)
+	}
+
+	if len(info.Warnings) != 1 {
+		t.Fatalf("Expected a warning for the missing table, got %d", len(info.Warnings))
+	}
+	if !strings.Contains(info.Warnings[0].Message, "10_cleanup.up.sql:4: table does not exist") {
+		t.Errorf("Unexpected warning %q", info.Warnings[0].Message)
+	}
+	if info.Name != filepath.Base(dir) {
+		t.Errorf("Expected the schema to be named after the directory, got %s", info.Name)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"CREATE TABLE t (id integer,\n  name text CHECK);", "schema.sql:2: expected (, found end of statement"},
+		{"CREATE TABLE t (id integer REFERENCES);", "schema.sql:1: expected a name, found end of statement"},
+		{"CREATE TABLE t (\n  id integer DEFAULT 'open);", "schema.sql:2: unterminated string"},
+		{"COMMENT ON TABLE t IS 42;", "schema.sql:1: expected a string or NULL, found 42"},
+	}
+	for _, tt := range tests {
+		err := NewParser().Parse("schema.sql", tt.sql)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) = %v, want %s", tt.sql, err, tt.want)
+		}
+	}
+}
+
+func TestObjectName(t *testing.T) {
+	long := strings.Repeat("a", 40)
+	tests := []struct {
+		name1, name2, label string
+		want                string
+	}{
+		{"orders", "", "pkey", "orders_pkey"},
+		{"orders", "customer_id", "fkey", "orders_customer_id_fkey"},
+		{long, strings.Repeat("b", 30), "key", strings.Repeat("a", 29) + "_" + strings.Repeat("b", 29) + "_key"},
+		{long + long, "", "pkey", strings.Repeat("a", 58) + "_pkey"},
+	}
+	for _, tt := range tests {
+		if got := objectName(tt.name1, tt.name2, tt.label); got != tt.want {
+			t.Errorf("objectName(%q, %q, %q) = %q, want %q", tt.name1, tt.name2, tt.label, got, tt.want)
+		}
+	}
+
+	taken := func(name string) bool { return name == "orders_pkey" || name == "orders_pkey1" }
+	if got := chooseName("orders", "", "pkey", taken); got != "orders_pkey2" {
+		t.Errorf("Expected a numbered name, got %s", got)
+	}
+}
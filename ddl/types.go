@@ -0,0 +1,201 @@
+package ddl
+
+import (
+	"strconv"
+	"strings"
+)
+
+// sqlType is a type as introspection reports it: Type is the data type
+// of information_schema.columns and FullType the type with its modifiers,
+// as pg_catalog.format_type prints it
+type sqlType struct {
+	Type     string
+	FullType string
+	Serial   bool // A serial pseudo-type, an integer column with a sequence
+}
+
+// typeNames maps the names and aliases of the built-in types to the names
+// PostgreSQL prints
+var typeNames = map[string]string{
+	"int": "integer", "int4": "integer", "integer": "integer",
+	"int2": "smallint", "smallint": "smallint",
+	"int8": "bigint", "bigint": "bigint",
+	"decimal": "numeric", "numeric": "numeric",
+	"float4": "real", "real": "real",
+	"float8": "double precision",
+	"bool":   "boolean", "boolean": "boolean",
+	"varchar": "character varying",
+	"char":    "character", "character": "character", "bpchar": "character",
+	"varbit":      "bit varying",
+	"bit":         "bit",
+	"timestamptz": "timestamp with time zone",
+	"timetz":      "time with time zone",
+	"text":        "text", "date": "date", "bytea": "bytea", "uuid": "uuid",
+	"json": "json", "jsonb": "jsonb", "jsonpath": "jsonpath", "xml": "xml",
+	"inet": "inet", "cidr": "cidr", "macaddr": "macaddr", "macaddr8": "macaddr8",
+	"money": "money", "tsvector": "tsvector", "tsquery": "tsquery",
+	"point": "point", "line": "line", "lseg": "lseg", "box": "box", "path": "path", "polygon": "polygon", "circle": "circle",
+	"int4range": "int4range", "int8range": "int8range", "numrange": "numrange",
+	"tsrange": "tsrange", "tstzrange": "tstzrange", "daterange": "daterange",
+	"oid": "oid", "regclass": "regclass", "regtype": "regtype", "regproc": "regproc",
+	"name": "name", "pg_lsn": "pg_lsn",
+}
+
+// serialTypes maps the serial pseudo-types to the integer types of their
+// columns
+var serialTypes = map[string]string{
+	"serial": "integer", "serial4": "integer",
+	"bigserial": "bigint", "serial8": "bigint",
+	"smallserial": "smallint", "serial2": "smallint",
+}
+
+// intervalFields are the fields that can restrict an interval type
+var intervalFields = []string{"year", "month", "day", "hour", "minute", "second", "to"}
+
+// parseType consumes a type name, such as varchar(20), timestamptz or
+// public.mood[], and returns it as PostgreSQL reports it. Types that aren't
+// built in, such as enums and extension types, are USER-DEFINED; domains
+// report the type they are based on.
+func (p *Parser) parseType(s *stmt) (sqlType, error) {
+	parts, err := s.name()
+	if err != nil {
+		return sqlType{}, err
+	}
+	name := parts[len(parts)-1]
+	quoted := s.at(s.pos-1).kind == quotedToken
+	qualified := len(parts) > 1 && parts[0] != "pg_catalog"
+
+	var typ sqlType
+	switch {
+	case quoted || qualified && parts[0] != "public":
+		typ = p.userType(strings.Join(parts, "."))
+	case name == "double" && s.accept("precision"):
+		typ = sqlType{Type: "double precision"}
+	case (name == "character" || name == "char") && s.accept("varying"):
+		typ = sqlType{Type: "character varying"}
+	case name == "bit" && s.accept("varying"):
+		typ = sqlType{Type: "bit varying"}
+	case name == "national" && (s.accept("character", "varying") || s.accept("char", "varying")):
+		typ = sqlType{Type: "character varying"}
+	case name == "national" && (s.accept("character") || s.accept("char")):
+		typ = sqlType{Type: "character"}
+	case name == "float":
+		// float(p) is real up to 24 bits of precision
+		typ = sqlType{Type: "double precision", FullType: "double precision"}
+		if s.isOp("(") {
+			group, err := s.group()
+			if err != nil {
+				return sqlType{}, err
+			}
+			if bits, err := strconv.Atoi(renderTokens(group, nil)); err == nil && bits <= 24 {
+				typ = sqlType{Type: "real", FullType: "real"}
+			}
+		}
+		return p.arrayType(s, typ), nil
+	case serialTypes[name] != "" && !qualified:
+		typ = sqlType{Type: serialTypes[name], Serial: true}
+	case typeNames[name] != "":
+		typ = sqlType{Type: typeNames[name]}
+	case name == "timestamp" || name == "time":
+		return p.timeType(s, name)
+	case name == "interval":
+		var fields []string
+		for _, field := range intervalFields {
+			if s.accept(field) {
+				fields = append(fields, field)
+			}
+		}
+		typ = sqlType{Type: "interval", FullType: strings.Join(append([]string{"interval"}, fields...), " ")}
+		if s.isOp("(") {
+			modifiers, err := typeModifiers(s)
+			if err != nil {
+				return sqlType{}, err
+			}
+			typ.FullType += modifiers
+		}
+		return p.arrayType(s, typ), nil
+	default:
+		typ = p.userType(name)
+	}
+
+	if typ.FullType == "" {
+		typ.FullType = typ.Type
+		if s.isOp("(") {
+			modifiers, err := typeModifiers(s)
+			if err != nil {
+				return sqlType{}, err
+			}
+			typ.FullType += modifiers
+		} else if typ.Type == "character" || typ.Type == "bit" {
+			typ.FullType += "(1)"
+		}
+	}
+	return p.arrayType(s, typ), nil
+}
+
+// userType returns a type that isn't built in: a domain reports the type it
+// is based on
+func (p *Parser) userType(name string) sqlType {
+	if domain, ok := p.domains[name]; ok {
+		return sqlType{Type: domain.Type, FullType: name}
+	}
+	return sqlType{Type: "USER-DEFINED", FullType: name}
+}
+
+// timeType consumes the rest of a timestamp or time type, with its
+// precision and time zone
+func (p *Parser) timeType(s *stmt, name string) (sqlType, error) {
+	modifiers := ""
+	if s.isOp("(") {
+		var err error
+		if modifiers, err = typeModifiers(s); err != nil {
+			return sqlType{}, err
+		}
+	}
+	zone := " without time zone"
+	switch {
+	case s.accept("with", "time", "zone"):
+		zone = " with time zone"
+	case s.accept("without", "time", "zone"):
+	}
+	typ := sqlType{Type: name + zone, FullType: name + modifiers + zone}
+	return p.arrayType(s, typ), nil
+}
+
+// arrayType consumes the array bounds after a type, such as [] or [3],
+// making typ an array
+func (p *Parser) arrayType(s *stmt, typ sqlType) sqlType {
+	array := false
+	for {
+		switch {
+		case s.accept("array"):
+			if s.isOp("[") {
+				continue
+			}
+			array = true
+		case s.isOp("["):
+			s.until(func(s *stmt) bool { return s.at(s.pos-1).text == "]" })
+			array = true
+			continue
+		}
+		break
+	}
+	if array {
+		return sqlType{Type: "ARRAY", FullType: typ.FullType + "[]"}
+	}
+	return typ
+}
+
+// typeModifiers consumes the modifiers of a type, such as (10, 2), and
+// returns them as format_type prints them, (10,2)
+func typeModifiers(s *stmt) (string, error) {
+	group, err := s.group()
+	if err != nil {
+		return "", err
+	}
+	var modifiers []string
+	for _, item := range splitCommas(group) {
+		modifiers = append(modifiers, renderTokens(item, nil))
+	}
+	return "(" + strings.Join(modifiers, ",") + ")", nil
+}
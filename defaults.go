@@ -0,0 +1,132 @@
+package dbinfo
+
+import "strings"
+
+// NormalizeDefault returns a default expression without the casts that don't
+// change its value: casts of literals to the type of the column, which
+// PostgreSQL adds when it stores a default, and the regclass cast of the
+// sequence name in nextval. Servers of different versions don't always print
+// these casts the same way, so normalized defaults of equal expressions
+// compare equal. columnType is the formatted type of the column, such as
+// character varying(20); type modifiers are ignored.
+func NormalizeDefault(expr, columnType string) string {
+	if expr == "" {
+		return ""
+	}
+	base := baseType(columnType)
+
+	var b strings.Builder
+	for i := 0; i < len(expr); {
+		if expr[i] == '"' {
+			// Quoted identifiers are copied as they are
+			end := strings.IndexByte(expr[i+1:], '"')
+			if end < 0 {
+				end = len(expr) - i - 2
+			}
+			b.WriteString(expr[i : i+end+2])
+			i += end + 2
+			continue
+		}
+
+		literal, n := scanLiteral(expr[i:])
+		if n == 0 {
+			b.WriteByte(expr[i])
+			i++
+			continue
+		}
+		b.WriteString(literal)
+		i += n
+
+		rest := expr[i:]
+		switch {
+		case base != "" && isCast(rest, base):
+			i += len("::") + len(base)
+		case strings.HasSuffix(b.String(), "nextval("+literal) && isCast(rest, "regclass"):
+			i += len("::regclass")
+		}
+	}
+	return b.String()
+}
+
+// scanLiteral reads the literal at the start of s: a quoted string, or a
+// number between parentheses as PostgreSQL prints negative and numeric
+// constants. It returns the literal, without parentheses, and the length
+// read, 0 when s doesn't start with a literal.
+func scanLiteral(s string) (string, int) {
+	switch {
+	case strings.HasPrefix(s, "'"):
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++ // Escaped quote
+				continue
+			}
+			return s[:i+1], i + 1
+		}
+	case strings.HasPrefix(s, "("):
+		end := strings.IndexByte(s, ')')
+		if end > 1 && isNumber(s[1:end]) {
+			return s[1:end], end + 1
+		}
+	}
+	return "", 0
+}
+
+// isNumber reports whether s is a decimal number, optionally negative
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	digits := 0
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '.' && i > 0:
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}
+
+// isCast reports whether s starts with a cast to typ, and not to a longer
+// type name starting the same way
+func isCast(s, typ string) bool {
+	if !strings.HasPrefix(s, "::"+typ) {
+		return false
+	}
+	rest := s[len("::")+len(typ):]
+	if rest == "" {
+		return true
+	}
+	for _, suffix := range typeNameSuffixes {
+		if strings.HasPrefix(rest, suffix) {
+			return false
+		}
+	}
+	c := rest[0]
+	return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '[' || c == '(')
+}
+
+// typeNameSuffixes are the words that continue multi-word type names, such
+// as character varying or double precision
+var typeNameSuffixes = []string{" varying", " precision", " with time zone", " without time zone"}
+
+// baseType returns a formatted type without its modifiers, as types are
+// printed in casts: character varying(20)[] becomes character varying[]
+func baseType(typ string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range typ {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
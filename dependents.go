@@ -0,0 +1,101 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Kinds of the objects depending on a table
+const (
+	DependentView             = "view"
+	DependentMaterializedView = "materialized view"
+	DependentTrigger          = "trigger"
+	DependentPolicy           = "policy"
+)
+
+// TableDependent is an object depending on a table, read from the catalog
+// rather than the model: a view reading it, directly or through other
+// views, or a trigger or row security policy on it. Dropping the table drops its triggers and
+// policies, and fails unless CASCADE also drops the views.
+type TableDependent struct {
+	Kind   string `json:"kind" yaml:"kind"` // DependentView, DependentMaterializedView, DependentTrigger or DependentPolicy
+	Schema string `json:"schema" yaml:"schema"`
+	Name   string `json:"name" yaml:"name"`
+	On     string `json:"on" yaml:"on"` // schema.name of the table or view it depends on
+}
+
+// dependentsQuery lists the objects depending on the table $1.$2. Views
+// depend on the relations they read through their rewrite rule, followed
+// recursively for views reading views.
+const dependentsQuery = `
+	WITH RECURSIVE views (oid, via) AS (
+		SELECT DISTINCT r.ev_class, c.oid
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_depend d ON d.refclassid = 'pg_class'::regclass AND d.refobjid = c.oid AND d.classid = 'pg_rewrite'::regclass
+		JOIN pg_rewrite r ON r.oid = d.objid
+		WHERE n.nspname = $1 AND c.relname = $2 AND r.ev_class <> c.oid
+		UNION
+		SELECT r.ev_class, v.oid
+		FROM views v
+		JOIN pg_depend d ON d.refclassid = 'pg_class'::regclass AND d.refobjid = v.oid AND d.classid = 'pg_rewrite'::regclass
+		JOIN pg_rewrite r ON r.oid = d.objid
+		WHERE r.ev_class <> v.oid
+	)
+	SELECT CASE c.relkind WHEN 'm' THEN 'materialized view' ELSE 'view' END, n.nspname, c.relname, pn.nspname || '.' || p.relname
+	FROM views v
+	JOIN pg_class c ON c.oid = v.oid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_class p ON p.oid = v.via
+	JOIN pg_namespace pn ON pn.oid = p.relnamespace
+	UNION ALL
+	SELECT 'trigger', n.nspname, t.tgname, n.nspname || '.' || c.relname
+	FROM pg_trigger t
+	JOIN pg_class c ON c.oid = t.tgrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = $2 AND NOT t.tgisinternal
+	UNION ALL
+	SELECT 'policy', n.nspname, p.polname, n.nspname || '.' || c.relname
+	FROM pg_policy p
+	JOIN pg_class c ON c.oid = p.polrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relname = $2`
+
+// GetTableDependents returns the views, triggers and policies depending on
+// the table schema.name, sorted by kind, schema and name. Functions using
+// the table in their body aren't tracked by PostgreSQL and aren't listed.
+// Of opts, only WithStatementTimeout and WithLogger apply: dependents in
+// excluded schemas are dropped all the same.
+func GetTableDependents(ctx context.Context, db DBQuerier, schema, name string, opts ...Option) ([]*TableDependent, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	object := schema + "." + name
+	rows, err := o.querier(db).Query(ctx, dependentsQuery, schema, name)
+	if err != nil {
+		return nil, queryError(PhaseDependents, object, fmt.Errorf("failed to query the dependents of %s: %w", object, err))
+	}
+	defer rows.Close()
+
+	var dependents []*TableDependent
+	for rows.Next() {
+		d := &TableDependent{}
+		if err := rows.Scan(&d.Kind, &d.Schema, &d.Name, &d.On); err != nil {
+			return nil, queryError(PhaseDependents, object, fmt.Errorf("failed to scan dependent row of %s: %w", object, err))
+		}
+		dependents = append(dependents, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(PhaseDependents, object, fmt.Errorf("error iterating dependent rows of %s: %w", object, err))
+	}
+	kinds := []string{DependentView, DependentMaterializedView, DependentTrigger, DependentPolicy}
+	slices.SortStableFunc(dependents, func(a, b *TableDependent) int {
+		return cmp.Or(cmp.Compare(slices.Index(kinds, a.Kind), slices.Index(kinds, b.Kind)),
+			cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	return dependents, nil
+}
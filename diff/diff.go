@@ -0,0 +1,575 @@
+// Package diff compares two dbinfo schemas and reports their structural
+// differences as a list of changes.
+package diff
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/color"
+)
+
+// ChangeType tells whether an object was added, removed or modified
+type ChangeType string
+
+// Change types
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+	// Renamed is a table or column renamed, see Options
+	Renamed ChangeType = "renamed"
+)
+
+// Kind is the kind of object a change refers to
+type Kind string
+
+// Object kinds
+const (
+	KindDatabase   Kind = "database"
+	KindTable      Kind = "table"
+	KindColumn     Kind = "column"
+	KindIndex      Kind = "index"
+	KindForeignKey Kind = "foreignkey"
+	KindRule       Kind = "rule"
+
+	KindView             Kind = "view"
+	KindMaterializedView Kind = "materializedview"
+	KindSequence         Kind = "sequence"
+	KindDomain           Kind = "domain"
+	KindForeignTable     Kind = "foreigntable"
+	KindEventTrigger     Kind = "eventtrigger"
+)
+
+// Change is a single difference between two schemas. Modifications produce
+// one change per modified attribute.
+type Change struct {
+	Type     ChangeType `json:"type"`
+	Kind     Kind       `json:"kind"`
+	Schema   string     `json:"schema"`
+	Table    string     `json:"table"`           // Table, view, materialized view, sequence, domain or foreign table name
+	Name     string     `json:"name,omitempty"`  // Column, index, foreign key or rule name, empty for the objects of Table, database or event trigger name
+	Field    string     `json:"field,omitempty"` // Modified attribute
+	From     string     `json:"from,omitempty"`  // Previous value of a modified attribute
+	To       string     `json:"to,omitempty"`    // New value of a modified attribute
+	Breaking bool       `json:"breaking"`        // Whether the change can break existing readers or writers
+}
+
+// Path returns the location of the changed object as schema.table[.name],
+// or the name of the database or event trigger for their changes
+func (c *Change) Path() string {
+	if c.Kind == KindDatabase || c.Kind == KindEventTrigger {
+		return c.Name
+	}
+	if c.Name == "" {
+		return c.Schema + "." + c.Table
+	}
+	return c.Schema + "." + c.Table + "." + c.Name
+}
+
+// String returns a one line, human readable description of the change
+func (c *Change) String() string {
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %s %s", c.Kind, c.Path())
+	case Removed:
+		return fmt.Sprintf("- %s %s", c.Kind, c.Path())
+	case Renamed:
+		return fmt.Sprintf("> %s %s: renamed from %s", c.Kind, c.Path(), c.From)
+	default:
+		return fmt.Sprintf("~ %s %s: %s %s -> %s", c.Kind, c.Path(), c.Field, display(c.From), display(c.To))
+	}
+}
+
+func display(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// Compare returns the changes needed to go from the from schema to the to
+// schema. Changes of the database itself, such as its collation, come
+// first, then changes sorted by table, then by object kind and name, then
+// those of views, materialized views, sequences, domains, foreign tables
+// and event triggers, so the result is stable across runs. Renamed tables
+// and columns are reported as removed and added, see CompareWith to detect
+// renames.
+func Compare(from, to *dbinfo.DBInfo) []*Change {
+	return CompareWith(from, to, Options{})
+}
+
+// CompareWith returns the changes needed to go from the from schema to the
+// to schema like Compare, reporting the renames of opts as such. A renamed
+// table or column is listed under its new name, followed by the changes of
+// its attributes.
+func CompareWith(from, to *dbinfo.DBInfo, opts Options) []*Change {
+	changes := compareDatabases(from, to)
+
+	fromTables := tablesByKey(from)
+	toTables := tablesByKey(to)
+	renamed := matchRenames(fromTables, toTables, opts.tableRenames(), opts.DetectRenames, sameTable, dbinfo.ObjectKey.Compare)
+	// New names by old name, so foreign keys following a renamed table
+	// aren't reported
+	newNames := make(map[dbinfo.ObjectKey]dbinfo.ObjectKey, len(renamed))
+	for new, old := range renamed {
+		newNames[old] = new
+	}
+
+	for _, key := range unionKeysFunc(fromTables, toTables, dbinfo.ObjectKey.Compare) {
+		oldTable, newTable := fromTables[key], toTables[key]
+		_, wasRenamed := newNames[key]
+		old, isRenamed := renamed[key]
+		switch {
+		case newTable == nil && wasRenamed:
+			// Reported under its new name
+		case newTable == nil:
+			changes = append(changes, &Change{Type: Removed, Kind: KindTable, Schema: oldTable.Schema, Table: oldTable.Name, Breaking: true})
+		case oldTable == nil && isRenamed:
+			oldTable = fromTables[old]
+			changes = append(changes, &Change{Type: Renamed, Kind: KindTable, Schema: newTable.Schema, Table: newTable.Name,
+				Field: "name", From: old.String(), To: key.String(), Breaking: true})
+			changes = append(changes, compareTables(oldTable, newTable, opts, newNames)...)
+		case oldTable == nil:
+			changes = append(changes, &Change{Type: Added, Kind: KindTable, Schema: newTable.Schema, Table: newTable.Name})
+		default:
+			changes = append(changes, compareTables(oldTable, newTable, opts, newNames)...)
+		}
+	}
+
+	return append(changes, compareObjects(from, to)...)
+}
+
+// compareDatabases compares the encoding and locales of the databases, when
+// both snapshots recorded them. Their names usually differ across
+// environments and aren't compared.
+func compareDatabases(from, to *dbinfo.DBInfo) []*Change {
+	var changes []*Change
+	field := func(field, old, new string) {
+		if old != "" && new != "" && old != new {
+			changes = append(changes, &Change{Type: Modified, Kind: KindDatabase, Name: to.Name, Field: field, From: old, To: new})
+		}
+	}
+	field("encoding", from.Encoding, to.Encoding)
+	field("collation", from.Collation, to.Collation)
+	field("ctype", from.CType, to.CType)
+	return changes
+}
+
+// HasBreaking reports whether any of the changes is breaking
+func HasBreaking(changes []*Change) bool {
+	for _, change := range changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+func compareTables(from, to *dbinfo.Table, opts Options, newNames map[dbinfo.ObjectKey]dbinfo.ObjectKey) []*Change {
+	c := &collector{schema: to.Schema, table: to.Name}
+
+	c.field(KindTable, "", "comment", from.Comment, to.Comment)
+	// Privileges are only compared when both snapshots recorded them
+	privileges := from.Owner != "" && to.Owner != ""
+	if privileges {
+		c.field(KindTable, "", "owner", from.Owner, to.Owner)
+		c.field(KindTable, "", "grants", grants(from.Grants), grants(to.Grants))
+	}
+	if from.PrimaryKey != nil && to.PrimaryKey != nil {
+		// Snapshots taken before primary keys were recorded only compare
+		// the IsPrimaryKey flags of the columns
+		c.field(KindTable, "", "primarykey", list(from.PrimaryKey.Columns), list(to.PrimaryKey.Columns))
+		c.field(KindTable, "", "primarykeyname", from.PrimaryKey.Name, to.PrimaryKey.Name)
+	}
+
+	fromColumns := make(map[string]*dbinfo.Column)
+	for _, column := range from.Columns {
+		fromColumns[column.Name] = column
+	}
+	toColumns := make(map[string]*dbinfo.Column)
+	for _, column := range to.Columns {
+		toColumns[column.Name] = column
+	}
+	renamed := matchRenames(fromColumns, toColumns, opts.columnRenames(from), opts.DetectRenames, sameColumn(from, to), strings.Compare)
+	renamedFrom := make(map[string]bool, len(renamed))
+	for _, old := range renamed {
+		renamedFrom[old] = true
+	}
+	for _, name := range unionKeys(fromColumns, toColumns) {
+		oldColumn, newColumn := fromColumns[name], toColumns[name]
+		if newColumn == nil && renamedFrom[name] {
+			// Reported under its new name
+			continue
+		}
+		if oldColumn == nil && renamed[name] != "" {
+			oldColumn = fromColumns[renamed[name]]
+			c.changes = append(c.changes, &Change{Type: Renamed, Kind: KindColumn, Schema: c.schema, Table: c.table, Name: name,
+				Field: "name", From: oldColumn.Name, To: name, Breaking: true})
+		} else if !c.presence(KindColumn, name, oldColumn != nil, newColumn != nil) {
+			// Removed columns break readers, new required columns without a
+			// default or a sequence break existing inserts
+			if change := c.last(); change.Type == Removed || (!newColumn.IsNullable && newColumn.DefaultValue == "" && !newColumn.AutoIncrement()) {
+				change.Breaking = true
+			}
+			continue
+		}
+		c.field(KindColumn, name, "type", columnType(oldColumn, newColumn), columnType(newColumn, oldColumn))
+		c.field(KindColumn, name, "nullable", strconv.FormatBool(oldColumn.IsNullable), strconv.FormatBool(newColumn.IsNullable))
+		// Defaults are compared without redundant casts, which servers of
+		// different versions print differently
+		if normalizedDefault(oldColumn) != normalizedDefault(newColumn) {
+			c.field(KindColumn, name, "default", oldColumn.DefaultValue, newColumn.DefaultValue)
+		}
+		if oldColumn.Kind != "" && newColumn.Kind != "" {
+			c.field(KindColumn, name, "kind", string(oldColumn.Kind), string(newColumn.Kind))
+			c.field(KindColumn, name, "identity", oldColumn.Identity, newColumn.Identity)
+		}
+		c.field(KindColumn, name, "primarykey", strconv.FormatBool(oldColumn.IsPrimaryKey), strconv.FormatBool(newColumn.IsPrimaryKey))
+		c.field(KindColumn, name, "collation", oldColumn.Collation, newColumn.Collation)
+		c.field(KindColumn, name, "comment", oldColumn.Comment, newColumn.Comment)
+		if privileges {
+			c.field(KindColumn, name, "grants", grants(oldColumn.Grants), grants(newColumn.Grants))
+		}
+	}
+
+	c.indexes(from.Indexes, to.Indexes)
+
+	fromFKs := make(map[string]*dbinfo.ForeignKey)
+	for _, fk := range from.ForeignKeys {
+		fromFKs[fk.Name] = fk
+	}
+	toFKs := make(map[string]*dbinfo.ForeignKey)
+	for _, fk := range to.ForeignKeys {
+		toFKs[fk.Name] = fk
+	}
+	for _, name := range unionKeys(fromFKs, toFKs) {
+		oldFK, newFK := fromFKs[name], toFKs[name]
+		if !c.presence(KindForeignKey, name, oldFK != nil, newFK != nil) {
+			// New foreign keys reject writes that used to succeed
+			if change := c.last(); change.Type == Added {
+				change.Breaking = true
+			}
+			continue
+		}
+		c.field(KindForeignKey, name, "columns", list(oldFK.ColumnNames), list(newFK.ColumnNames))
+		references := dbinfo.ObjectKey{Schema: oldFK.RefTableSchema, Name: oldFK.RefTableName}
+		if renamed, ok := newNames[references]; ok {
+			references = renamed
+		}
+		c.field(KindForeignKey, name, "references", references.String(), dbinfo.ObjectKey{Schema: newFK.RefTableSchema, Name: newFK.RefTableName}.String())
+		c.field(KindForeignKey, name, "refcolumns", list(oldFK.RefColumnNames), list(newFK.RefColumnNames))
+		c.field(KindForeignKey, name, "onupdate", oldFK.OnUpdate, newFK.OnUpdate)
+		c.field(KindForeignKey, name, "ondelete", oldFK.OnDelete, newFK.OnDelete)
+	}
+
+	c.rules(from.Rules, to.Rules)
+
+	return c.changes
+}
+
+// collector accumulates the changes of a table
+type collector struct {
+	schema  string
+	table   string
+	changes []*Change
+}
+
+// presence records additions and removals. It returns true when the object
+// exists on both sides and its attributes need to be compared.
+func (c *collector) presence(kind Kind, name string, before, after bool) bool {
+	switch {
+	case before && !after:
+		c.changes = append(c.changes, &Change{Type: Removed, Kind: kind, Schema: c.schema, Table: c.table, Name: name})
+		return false
+	case !before && after:
+		c.changes = append(c.changes, &Change{Type: Added, Kind: kind, Schema: c.schema, Table: c.table, Name: name})
+		return false
+	}
+	return true
+}
+
+// indexes records the changes of the indexes of a table or materialized
+// view
+func (c *collector) indexes(from, to []*dbinfo.Index) {
+	fromIndexes := make(map[string]*dbinfo.Index)
+	for _, index := range from {
+		fromIndexes[index.Name] = index
+	}
+	toIndexes := make(map[string]*dbinfo.Index)
+	for _, index := range to {
+		toIndexes[index.Name] = index
+	}
+	for _, name := range unionKeys(fromIndexes, toIndexes) {
+		oldIndex, newIndex := fromIndexes[name], toIndexes[name]
+		if !c.presence(KindIndex, name, oldIndex != nil, newIndex != nil) {
+			// New unique indexes reject writes that used to succeed
+			if change := c.last(); change.Type == Added && newIndex.Unique {
+				change.Breaking = true
+			}
+			continue
+		}
+		c.field(KindIndex, name, "unique", strconv.FormatBool(oldIndex.Unique), strconv.FormatBool(newIndex.Unique))
+		c.field(KindIndex, name, "nullsnotdistinct", strconv.FormatBool(oldIndex.NullsNotDistinct), strconv.FormatBool(newIndex.NullsNotDistinct))
+		if len(oldIndex.Keys) > 0 && len(newIndex.Keys) > 0 {
+			c.field(KindIndex, name, "keys", indexKeys(oldIndex), indexKeys(newIndex))
+		} else {
+			// Snapshots taken before keys were recorded
+			c.field(KindIndex, name, "columns", list(oldIndex.Columns), list(newIndex.Columns))
+			c.field(KindIndex, name, "expression", oldIndex.Expression, newIndex.Expression)
+		}
+	}
+}
+
+// rules records the changes of the rules of a table or view
+func (c *collector) rules(from, to []*dbinfo.Rule) {
+	fromRules := make(map[string]*dbinfo.Rule)
+	for _, rule := range from {
+		fromRules[rule.Name] = rule
+	}
+	toRules := make(map[string]*dbinfo.Rule)
+	for _, rule := range to {
+		toRules[rule.Name] = rule
+	}
+	for _, name := range unionKeys(fromRules, toRules) {
+		oldRule, newRule := fromRules[name], toRules[name]
+		if !c.presence(KindRule, name, oldRule != nil, newRule != nil) {
+			// New INSTEAD rules replace the writes that used to happen
+			if change := c.last(); change.Type == Added && newRule.Instead {
+				change.Breaking = true
+			}
+			continue
+		}
+		c.field(KindRule, name, "definition", oldRule.Definition, newRule.Definition)
+		c.field(KindRule, name, "enabled", oldRule.Enabled, newRule.Enabled)
+	}
+}
+
+// last returns the most recently recorded change
+func (c *collector) last() *Change {
+	return c.changes[len(c.changes)-1]
+}
+
+// field records a modification when the attribute values differ
+func (c *collector) field(kind Kind, name, field, from, to string) {
+	if from == to {
+		return
+	}
+	c.changes = append(c.changes, &Change{
+		Type:     Modified,
+		Kind:     kind,
+		Schema:   c.schema,
+		Table:    c.table,
+		Name:     name,
+		Field:    field,
+		From:     from,
+		To:       to,
+		Breaking: isBreaking(kind, field, from, to),
+	})
+}
+
+// isBreaking tells whether modifying an attribute can break existing readers
+// or writers: type, key and primary key changes, new NOT NULL and UNIQUE
+// constraints, unique indexes starting to treat NULLs as equal, foreign
+// keys pointing somewhere else and rules rewriting statements differently.
+// Views that stop being updatable or populated, sequences with a narrower
+// type or range or starting to cycle, domains on another type or becoming
+// NOT NULL and foreign tables moved to another server break too. Event
+// triggers only run on DDL, so none of their changes is breaking.
+func isBreaking(kind Kind, field, from, to string) bool {
+	switch kind {
+	case KindTable:
+		return field == "primarykey"
+	case KindColumn:
+		switch field {
+		case "type", "primarykey":
+			return true
+		case "nullable":
+			return to == "false"
+		}
+	case KindIndex:
+		switch field {
+		case "unique", "nullsnotdistinct":
+			return to == "true"
+		case "columns", "expression":
+			return true
+		}
+	case KindForeignKey:
+		switch field {
+		case "columns", "references", "refcolumns":
+			return true
+		}
+	case KindRule:
+		return field == "definition"
+	case KindView:
+		return field == "updatable" && to == "false"
+	case KindMaterializedView:
+		return field == "populated" && to == "false"
+	case KindSequence:
+		switch field {
+		case "type":
+			return sequenceTypes[to] < sequenceTypes[from]
+		case "min", "max":
+			old, _ := strconv.ParseInt(from, 10, 64)
+			new, _ := strconv.ParseInt(to, 10, 64)
+			return (field == "min" && new > old) || (field == "max" && new < old)
+		case "cycle":
+			return to == "true"
+		}
+	case KindDomain:
+		switch field {
+		case "type":
+			return true
+		case "notnull":
+			return to == "true"
+		}
+	case KindForeignTable:
+		return field == "server"
+	}
+	return false
+}
+
+// sequenceTypes ranks the types of sequences by the values they hold
+var sequenceTypes = map[string]int{"smallint": 1, "integer": 2, "bigint": 3}
+
+// columnType returns the most precise type available on both sides, so
+// snapshots without formatted types still compare equal
+func columnType(column, other *dbinfo.Column) string {
+	if column.FullType != "" && other.FullType != "" {
+		return column.FullType
+	}
+	return column.Type
+}
+
+// normalizedDefault returns the default of column without redundant casts,
+// also for snapshots taken before NormalizedDefault was recorded
+func normalizedDefault(column *dbinfo.Column) string {
+	typ := column.FullType
+	if typ == "" {
+		typ = column.Type
+	}
+	return dbinfo.NormalizeDefault(column.DefaultValue, typ)
+}
+
+// indexKeys lists the keys of index in order
+// grants lists grants as role:PRIVILEGE, with a * for those held with the
+// grant option
+func grants(grants []*dbinfo.Grant) string {
+	values := make([]string, len(grants))
+	for i, g := range grants {
+		values[i] = g.Role + ":" + g.Privilege
+		if g.Grantable {
+			values[i] += "*"
+		}
+	}
+	return list(values)
+}
+
+func indexKeys(index *dbinfo.Index) string {
+	keys := make([]string, len(index.Keys))
+	for i, key := range index.Keys {
+		keys[i] = key.String()
+	}
+	return list(keys)
+}
+
+func list(values []string) string {
+	return strings.Join(values, ", ")
+}
+
+func keyOf(table *dbinfo.Table) dbinfo.ObjectKey {
+	return dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}
+}
+
+func tablesByKey(info *dbinfo.DBInfo) map[dbinfo.ObjectKey]*dbinfo.Table {
+	tables := make(map[dbinfo.ObjectKey]*dbinfo.Table)
+	if info == nil {
+		return tables
+	}
+	for _, table := range info.Tables {
+		tables[keyOf(table)] = table
+	}
+	return tables
+}
+
+// unionKeys returns the sorted keys present in any of the maps
+func unionKeys[K cmp.Ordered, V any](a, b map[K]V) []K {
+	return unionKeysFunc(a, b, cmp.Compare[K])
+}
+
+// unionKeysFunc returns the keys present in any of the maps, sorted with
+// compare
+func unionKeysFunc[K comparable, V any](a, b map[K]V, compare func(K, K) int) []K {
+	seen := make(map[K]bool, len(a)+len(b))
+	var keys []K
+	for _, m := range []map[K]V{a, b} {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	slices.SortFunc(keys, compare)
+	return keys
+}
+
+// WriteText writes one line per change to w
+func WriteText(w io.Writer, changes []*Change) error {
+	for _, change := range changes {
+		if _, err := fmt.Fprintln(w, change.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteColorText writes the changes to w as aligned columns colored for a
+// terminal: additions in green, removals in red and modifications in
+// yellow, with breaking changes flagged
+func WriteColorText(w io.Writer, changes []*Change) error {
+	rows := make([][]color.Cell, 0, len(changes))
+	for _, change := range changes {
+		var row []color.Cell
+		switch change.Type {
+		case Added:
+			row = append(row, color.Cell{Text: "+", Color: color.Green})
+		case Removed:
+			row = append(row, color.Cell{Text: "-", Color: color.Red})
+		case Renamed:
+			row = append(row, color.Cell{Text: ">", Color: color.Cyan})
+		default:
+			row = append(row, color.Cell{Text: "~", Color: color.Yellow})
+		}
+		row = append(row, color.Cell{Text: string(change.Kind), Color: color.Gray}, color.Cell{Text: change.Path(), Color: color.Bold})
+		details := ""
+		switch change.Type {
+		case Modified:
+			details = fmt.Sprintf("%s %s -> %s", change.Field, display(change.From), display(change.To))
+		case Renamed:
+			details = "renamed from " + change.From
+		}
+		row = append(row, color.Cell{Text: details})
+		if change.Breaking {
+			row = append(row, color.Cell{Text: "breaking", Color: color.Red})
+		}
+		rows = append(rows, row)
+	}
+	return color.WriteTable(w, rows)
+}
+
+// WriteJSON writes the changes to w as a JSON array
+func WriteJSON(w io.Writer, changes []*Change) error {
+	if changes == nil {
+		changes = []*Change{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(changes)
+}
@@ -0,0 +1,558 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func baseInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:   "customers",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true},
+					{Name: "email", Type: "character varying", FullType: "character varying(100)"},
+				},
+			},
+			{
+				Name:   "orders",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer", FullType: "integer"},
+					{Name: "notes", Type: "text", FullType: "text", IsNullable: true},
+				},
+				Indexes: []*dbinfo.Index{
+					{Name: "idx_orders_customer_id", Columns: []string{"customer_id"}},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{
+						Name:           "orders_customer_id_fkey",
+						ColumnNames:    []string{"customer_id"},
+						RefTableSchema: "public",
+						RefTableName:   "customers",
+						RefColumnNames: []string{"id"},
+						OnUpdate:       "NO ACTION",
+						OnDelete:       "NO ACTION",
+					},
+				},
+			},
+			{
+				Name:   "legacy",
+				Schema: "public",
+			},
+		},
+	}
+}
+
+func TestCompareIdentical(t *testing.T) {
+	if changes := Compare(baseInfo(), baseInfo()); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	from := baseInfo()
+	to := baseInfo()
+
+	to.Tables = to.Tables[:2] // drop legacy
+	to.Tables = append(to.Tables, &dbinfo.Table{Name: "invoices", Schema: "billing"})
+
+	orders := to.Tables[1]
+	orders.Comment = "Customer orders"
+	orders.Columns[1].FullType = "bigint"
+	orders.Columns[1].Type = "bigint"
+	orders.Columns = orders.Columns[:2] // drop notes
+	orders.Columns = append(orders.Columns, &dbinfo.Column{Name: "status", Type: "text", FullType: "text"})
+	orders.Indexes[0].Unique = true
+	orders.ForeignKeys[0].OnDelete = "CASCADE"
+
+	expected := []*Change{
+		{Type: Added, Kind: KindTable, Schema: "billing", Table: "invoices"},
+		{Type: Removed, Kind: KindTable, Schema: "public", Table: "legacy", Breaking: true},
+		{Type: Modified, Kind: KindTable, Schema: "public", Table: "orders", Field: "comment", To: "Customer orders"},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "orders", Name: "customer_id", Field: "type", From: "integer", To: "bigint", Breaking: true},
+		{Type: Removed, Kind: KindColumn, Schema: "public", Table: "orders", Name: "notes", Breaking: true},
+		{Type: Added, Kind: KindColumn, Schema: "public", Table: "orders", Name: "status", Breaking: true},
+		{Type: Modified, Kind: KindIndex, Schema: "public", Table: "orders", Name: "idx_orders_customer_id", Field: "unique", From: "false", To: "true", Breaking: true},
+		{Type: Modified, Kind: KindForeignKey, Schema: "public", Table: "orders", Name: "orders_customer_id_fkey", Field: "ondelete", From: "NO ACTION", To: "CASCADE"},
+	}
+
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareDottedNames(t *testing.T) {
+	// Both tables would be a.b.c as schema.name strings
+	from := &dbinfo.DBInfo{Tables: []*dbinfo.Table{{Schema: "a.b", Name: "c"}}}
+	to := &dbinfo.DBInfo{Tables: []*dbinfo.Table{{Schema: "a", Name: "b.c"}}}
+	changes := Compare(from, to)
+	if len(changes) != 2 || changes[0].Type != Added || changes[1].Type != Removed {
+		t.Errorf("Expected the tables to be told apart, got %v", changes)
+	}
+}
+
+func TestCompareIndexKeys(t *testing.T) {
+	index := func(keys ...*dbinfo.IndexKey) *dbinfo.DBInfo {
+		info := baseInfo()
+		info.Tables[1].Indexes = []*dbinfo.Index{{Name: "idx_orders_lookup", Keys: keys}}
+		return info
+	}
+	from := index(&dbinfo.IndexKey{Column: "customer_id"}, &dbinfo.IndexKey{Expression: "lower(notes)"})
+	to := index(&dbinfo.IndexKey{Expression: "lower(notes)"}, &dbinfo.IndexKey{Column: "customer_id"})
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindIndex, Schema: "public", Table: "orders", Name: "idx_orders_lookup", Field: "keys", From: "customer_id, lower(notes)", To: "lower(notes), customer_id"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareNullsNotDistinct(t *testing.T) {
+	from := baseInfo()
+	from.Tables[1].Indexes[0].Unique = true
+	to := baseInfo()
+	to.Tables[1].Indexes[0].Unique = true
+	to.Tables[1].Indexes[0].NullsNotDistinct = true
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindIndex, Schema: "public", Table: "orders", Name: "idx_orders_customer_id", Field: "nullsnotdistinct", From: "false", To: "true", Breaking: true},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestComparePrimaryKey(t *testing.T) {
+	from := baseInfo()
+	from.Tables[1].PrimaryKey = &dbinfo.PrimaryKey{Name: "orders_pkey", Columns: []string{"id", "customer_id"}}
+	to := baseInfo()
+	to.Tables[1].PrimaryKey = &dbinfo.PrimaryKey{Name: "orders_pk", Columns: []string{"customer_id", "id"}}
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindTable, Schema: "public", Table: "orders", Field: "primarykey", From: "id, customer_id", To: "customer_id, id", Breaking: true},
+		{Type: Modified, Kind: KindTable, Schema: "public", Table: "orders", Field: "primarykeyname", From: "orders_pkey", To: "orders_pk"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+
+	// Snapshots without primary keys don't report them as changed
+	from.Tables[1].PrimaryKey = nil
+	if changes := Compare(from, to); len(changes) != 0 {
+		t.Errorf("Expected no changes against an older snapshot, got %v", changes)
+	}
+}
+
+func TestComparePrivileges(t *testing.T) {
+	from := baseInfo()
+	from.Tables[0].Owner = "app"
+	from.Tables[0].Grants = []*dbinfo.Grant{{Role: "app", Privilege: "SELECT"}, {Role: "reporting", Privilege: "SELECT"}}
+	from.Tables[0].Columns[1].Grants = []*dbinfo.Grant{{Role: "support", Privilege: "SELECT"}}
+	to := baseInfo()
+	to.Tables[0].Owner = "admin"
+	to.Tables[0].Grants = []*dbinfo.Grant{{Role: "admin", Privilege: "SELECT"}, {Role: "reporting", Privilege: "SELECT", Grantable: true}}
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindTable, Schema: "public", Table: "customers", Field: "owner", From: "app", To: "admin"},
+		{Type: Modified, Kind: KindTable, Schema: "public", Table: "customers", Field: "grants", From: "app:SELECT, reporting:SELECT", To: "admin:SELECT, reporting:SELECT*"},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "customers", Name: "email", Field: "grants", From: "support:SELECT"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+
+	// Snapshots taken without privileges don't report them as changed
+	to.Tables[0].Owner = ""
+	if changes := Compare(from, to); len(changes) != 0 {
+		t.Errorf("Expected no changes against a snapshot without privileges, got %v", changes)
+	}
+}
+
+func TestCompareCollations(t *testing.T) {
+	from := baseInfo()
+	from.Encoding, from.Collation, from.CType = "UTF8", "en_US.UTF-8", "en_US.UTF-8"
+	to := baseInfo()
+	to.Encoding, to.Collation, to.CType = "UTF8", "C", "C.UTF-8"
+	to.Tables[0].Columns[1].Collation = `"C"`
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindDatabase, Name: to.Name, Field: "collation", From: "en_US.UTF-8", To: "C"},
+		{Type: Modified, Kind: KindDatabase, Name: to.Name, Field: "ctype", From: "en_US.UTF-8", To: "C.UTF-8"},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "customers", Name: "email", Field: "collation", To: `"C"`},
+	}
+	changes := Compare(from, to)
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+	if got := changes[0].String(); got != "~ database "+to.Name+": collation en_US.UTF-8 -> C" {
+		t.Errorf("Unexpected database change %q", got)
+	}
+
+	// Snapshots taken without the locales don't report them as changed
+	from.Encoding, from.Collation, from.CType = "", "", ""
+	if changes := Compare(from, to); len(changes) != 1 {
+		t.Errorf("Expected only the column change against a snapshot without locales, got %v", changes)
+	}
+}
+
+func TestCompareRules(t *testing.T) {
+	from := baseInfo()
+	from.Tables[0].Rules = []*dbinfo.Rule{
+		{Name: "customers_log", Event: "UPDATE", Enabled: "origin", Definition: "CREATE RULE customers_log AS ON UPDATE TO public.customers DO INSERT INTO log VALUES (old.id)"},
+		{Name: "customers_old", Event: "INSERT", Enabled: "origin", Definition: "CREATE RULE customers_old AS ON INSERT TO public.customers DO NOTHING"},
+	}
+	to := baseInfo()
+	to.Tables[0].Rules = []*dbinfo.Rule{
+		{Name: "customers_keep", Event: "DELETE", Instead: true, Enabled: "origin", Definition: "CREATE RULE customers_keep AS ON DELETE TO public.customers DO INSTEAD NOTHING"},
+		{Name: "customers_log", Event: "UPDATE", Enabled: "disabled", Definition: "CREATE RULE customers_log AS ON UPDATE TO public.customers DO INSERT INTO log VALUES (new.id)"},
+	}
+
+	expected := []*Change{
+		{Type: Added, Kind: KindRule, Schema: "public", Table: "customers", Name: "customers_keep", Breaking: true},
+		{Type: Modified, Kind: KindRule, Schema: "public", Table: "customers", Name: "customers_log", Field: "definition",
+			From: "CREATE RULE customers_log AS ON UPDATE TO public.customers DO INSERT INTO log VALUES (old.id)",
+			To:   "CREATE RULE customers_log AS ON UPDATE TO public.customers DO INSERT INTO log VALUES (new.id)", Breaking: true},
+		{Type: Modified, Kind: KindRule, Schema: "public", Table: "customers", Name: "customers_log", Field: "enabled", From: "origin", To: "disabled"},
+		{Type: Removed, Kind: KindRule, Schema: "public", Table: "customers", Name: "customers_old"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareViews(t *testing.T) {
+	from := baseInfo()
+	from.Views = []*dbinfo.View{
+		{Schema: "public", Name: "active_customers", Definition: "SELECT id, email FROM customers", Updatable: true, Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", FullType: "integer", IsNullable: true},
+			{Name: "email", Type: "character varying", FullType: "character varying(100)", IsNullable: true},
+		}},
+		{Schema: "public", Name: "old_orders", Definition: "SELECT id FROM orders"},
+	}
+	from.MaterializedViews = []*dbinfo.MaterializedView{
+		{Schema: "public", Name: "order_totals", Definition: "SELECT customer_id FROM orders", Populated: true, Columns: []*dbinfo.Column{
+			{Name: "customer_id", Type: "integer", FullType: "integer", IsNullable: true},
+		}},
+	}
+	to := baseInfo()
+	to.Views = []*dbinfo.View{
+		{Schema: "public", Name: "active_customers", Definition: "SELECT id FROM customers WHERE active", Columns: []*dbinfo.Column{
+			{Name: "id", Type: "bigint", FullType: "bigint", IsNullable: true},
+		}},
+		{Schema: "public", Name: "recent_orders", Definition: "SELECT id FROM orders"},
+	}
+	to.MaterializedViews = []*dbinfo.MaterializedView{
+		{Schema: "public", Name: "order_totals", Definition: "SELECT customer_id FROM orders", Columns: []*dbinfo.Column{
+			{Name: "customer_id", Type: "integer", FullType: "integer", IsNullable: true},
+		}, Indexes: []*dbinfo.Index{{Name: "order_totals_customer_id_idx", Columns: []string{"customer_id"}, Unique: true}}},
+	}
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindView, Schema: "public", Table: "active_customers", Field: "definition",
+			From: "SELECT id, email FROM customers", To: "SELECT id FROM customers WHERE active"},
+		{Type: Modified, Kind: KindView, Schema: "public", Table: "active_customers", Field: "updatable", From: "true", To: "false", Breaking: true},
+		{Type: Removed, Kind: KindColumn, Schema: "public", Table: "active_customers", Name: "email", Breaking: true},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "active_customers", Name: "id", Field: "type", From: "integer", To: "bigint", Breaking: true},
+		{Type: Removed, Kind: KindView, Schema: "public", Table: "old_orders", Breaking: true},
+		{Type: Added, Kind: KindView, Schema: "public", Table: "recent_orders"},
+		{Type: Modified, Kind: KindMaterializedView, Schema: "public", Table: "order_totals", Field: "populated", From: "true", To: "false", Breaking: true},
+		{Type: Added, Kind: KindIndex, Schema: "public", Table: "order_totals", Name: "order_totals_customer_id_idx", Breaking: true},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareSequences(t *testing.T) {
+	lastValue, nextValue := int64(10), int64(20)
+	from := baseInfo()
+	from.Sequences = []*dbinfo.Sequence{
+		{Schema: "public", Name: "invoice_numbers", Type: "bigint", Start: 1, Increment: 1, Min: 1, Max: 9223372036854775807, Cache: 1, LastValue: &lastValue},
+		{Schema: "public", Name: "orders_id_seq", Type: "integer", Start: 1, Increment: 1, Min: 1, Max: 2147483647, Cache: 1,
+			OwnedBy: &dbinfo.SequenceOwner{Schema: "public", Table: "orders", Column: "id"}},
+	}
+	to := baseInfo()
+	to.Sequences = []*dbinfo.Sequence{
+		{Schema: "public", Name: "invoice_numbers", Type: "integer", Start: 1, Increment: 1, Min: 1, Max: 2147483647, Cache: 20, Cycle: true, LastValue: &nextValue},
+		{Schema: "public", Name: "tickets", Type: "bigint", Start: 1, Increment: 1, Min: 1, Max: 9223372036854775807, Cache: 1},
+	}
+
+	// The last values differ but aren't compared
+	expected := []*Change{
+		{Type: Modified, Kind: KindSequence, Schema: "public", Table: "invoice_numbers", Field: "type", From: "bigint", To: "integer", Breaking: true},
+		{Type: Modified, Kind: KindSequence, Schema: "public", Table: "invoice_numbers", Field: "max", From: "9223372036854775807", To: "2147483647", Breaking: true},
+		{Type: Modified, Kind: KindSequence, Schema: "public", Table: "invoice_numbers", Field: "cache", From: "1", To: "20"},
+		{Type: Modified, Kind: KindSequence, Schema: "public", Table: "invoice_numbers", Field: "cycle", From: "false", To: "true", Breaking: true},
+		{Type: Removed, Kind: KindSequence, Schema: "public", Table: "orders_id_seq", Breaking: true},
+		{Type: Added, Kind: KindSequence, Schema: "public", Table: "tickets"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareDomains(t *testing.T) {
+	from := baseInfo()
+	from.Domains = []*dbinfo.Domain{
+		{Schema: "public", Name: "email", Type: "character varying(254)", Checks: []*dbinfo.DomainCheck{
+			{Name: "email_check", Definition: "CHECK (VALUE ~~ '%@%')"},
+		}},
+		{Schema: "public", Name: "quantity", Type: "integer", Checks: []*dbinfo.DomainCheck{
+			{Name: "quantity_check", Definition: "CHECK ((VALUE > 0))"},
+		}},
+	}
+	to := baseInfo()
+	to.Domains = []*dbinfo.Domain{
+		{Schema: "public", Name: "email", Type: "character varying(254)", NotNull: true, Checks: []*dbinfo.DomainCheck{}},
+		{Schema: "public", Name: "quantity", Type: "integer", Checks: []*dbinfo.DomainCheck{
+			{Name: "quantity_check", Definition: "CHECK ((VALUE > 0))"},
+			{Name: "quantity_max", Definition: "CHECK ((VALUE < 1000))"},
+		}},
+	}
+
+	// Dropping a check accepts more values, adding one rejects some
+	expected := []*Change{
+		{Type: Modified, Kind: KindDomain, Schema: "public", Table: "email", Field: "notnull", From: "false", To: "true", Breaking: true},
+		{Type: Modified, Kind: KindDomain, Schema: "public", Table: "email", Field: "checks", From: "email_check: CHECK (VALUE ~~ '%@%')"},
+		{Type: Modified, Kind: KindDomain, Schema: "public", Table: "quantity", Field: "checks", From: "quantity_check: CHECK ((VALUE > 0))",
+			To: "quantity_check: CHECK ((VALUE > 0)), quantity_max: CHECK ((VALUE < 1000))", Breaking: true},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareForeignTables(t *testing.T) {
+	from := baseInfo()
+	from.ForeignTables = []*dbinfo.ForeignTable{{
+		Schema: "public", Name: "remote_orders", Server: "warehouse", Wrapper: "postgres_fdw",
+		Options: map[string]string{"schema_name": "public", "table_name": "orders"},
+		Columns: []*dbinfo.Column{{Name: "id", Type: "integer", FullType: "integer", IsNullable: true}},
+	}}
+	to := baseInfo()
+	to.ForeignTables = []*dbinfo.ForeignTable{{
+		Schema: "public", Name: "remote_orders", Server: "archive", Wrapper: "postgres_fdw",
+		Options:       map[string]string{"schema_name": "archive", "table_name": "orders"},
+		Columns:       []*dbinfo.Column{{Name: "id", Type: "integer", FullType: "integer", IsNullable: true}},
+		ColumnOptions: map[string]map[string]string{"id": {"column_name": "order_id"}},
+	}}
+	from.EventTriggers = []*dbinfo.EventTrigger{{Name: "audit_ddl", Event: "ddl_command_end", Function: "public.audit_ddl", Enabled: "origin"}}
+	to.EventTriggers = []*dbinfo.EventTrigger{{Name: "audit_ddl", Event: "ddl_command_end", Function: "public.audit_ddl", Enabled: "disabled"}}
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindForeignTable, Schema: "public", Table: "remote_orders", Field: "server", From: "warehouse", To: "archive", Breaking: true},
+		{Type: Modified, Kind: KindForeignTable, Schema: "public", Table: "remote_orders", Field: "options",
+			From: "schema_name=public, table_name=orders", To: "schema_name=archive, table_name=orders"},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "remote_orders", Name: "id", Field: "options", To: "column_name=order_id"},
+		{Type: Modified, Kind: KindEventTrigger, Name: "audit_ddl", Field: "enabled", From: "origin", To: "disabled"},
+	}
+	changes := Compare(from, to)
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+	if expected := "~ eventtrigger audit_ddl: enabled origin -> disabled"; len(changes) == 4 && changes[3].String() != expected {
+		t.Errorf("Expected %q, got %q", expected, changes[3].String())
+	}
+}
+
+func TestCompareColumnKind(t *testing.T) {
+	from := baseInfo()
+	from.Tables[1].Columns[0].Kind = dbinfo.ColumnSerial
+	to := baseInfo()
+	to.Tables[1].Columns[0].Kind = dbinfo.ColumnIdentity
+	to.Tables[1].Columns[0].Identity = "ALWAYS"
+	// Identity columns are filled in even without a default
+	to.Tables[1].Columns = append(to.Tables[1].Columns, &dbinfo.Column{Name: "seq", Type: "bigint", Kind: dbinfo.ColumnIdentity, Identity: "ALWAYS"})
+
+	expected := []*Change{
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "orders", Name: "id", Field: "kind", From: "serial", To: "identity"},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "orders", Name: "id", Field: "identity", To: "ALWAYS"},
+		{Type: Added, Kind: KindColumn, Schema: "public", Table: "orders", Name: "seq"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestCompareDefaults(t *testing.T) {
+	from := baseInfo()
+	from.Tables[1].Columns[2].DefaultValue = "'none'::text"
+	to := baseInfo()
+	to.Tables[1].Columns[2].DefaultValue = "'none'"
+	if changes := Compare(from, to); len(changes) != 0 {
+		t.Errorf("Expected defaults differing in redundant casts to compare equal, got %v", changes)
+	}
+
+	to.Tables[1].Columns[2].DefaultValue = "'empty'"
+	expected := []*Change{
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "orders", Name: "notes", Field: "default", From: "'none'::text", To: "'empty'"},
+	}
+	if diff := cmp.Diff(expected, Compare(from, to)); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestBreaking(t *testing.T) {
+	from := baseInfo()
+	to := baseInfo()
+
+	// Additive changes are not breaking
+	orders := to.Tables[1]
+	orders.Columns = append(orders.Columns,
+		&dbinfo.Column{Name: "shipped_at", Type: "timestamp", IsNullable: true},
+		&dbinfo.Column{Name: "status", Type: "text", DefaultValue: "'pending'::text"},
+	)
+	orders.Indexes = append(orders.Indexes, &dbinfo.Index{Name: "idx_orders_status", Columns: []string{"status"}})
+	orders.ForeignKeys[0].OnDelete = "CASCADE"
+	to.Tables = append(to.Tables, &dbinfo.Table{Name: "invoices", Schema: "public"})
+
+	changes := Compare(from, to)
+	if len(changes) != 5 {
+		t.Fatalf("Expected 5 changes, got %v", changes)
+	}
+	if HasBreaking(changes) {
+		t.Errorf("Expected additive changes not to be breaking, got %v", changes)
+	}
+
+	// Making a column required is breaking
+	orders.Columns[2].IsNullable = false
+	if !HasBreaking(Compare(from, to)) {
+		t.Error("Expected a new NOT NULL constraint to be breaking")
+	}
+}
+
+func TestCompareWithoutFullType(t *testing.T) {
+	from := baseInfo()
+	to := baseInfo()
+	for _, column := range to.Tables[0].Columns {
+		column.FullType = ""
+	}
+	if changes := Compare(from, to); len(changes) != 0 {
+		t.Errorf("Expected snapshots without formatted types to compare equal, got %v", changes)
+	}
+}
+
+func TestCompareRenames(t *testing.T) {
+	from := baseInfo()
+	to := baseInfo()
+	to.Tables[0].Name = "clients"
+	orders := to.Tables[1]
+	orders.ForeignKeys[0].RefTableName = "clients"
+	orders.Columns[2].Name = "remarks"
+	orders.Columns[2].Comment = "Free text"
+	from.Tables[1].Columns[2].Comment = "Free text"
+
+	// Without options renames are a removal and an addition
+	if changes := Compare(from, to); len(changes) != 5 {
+		t.Errorf("Expected 5 changes without rename detection, got %v", changes)
+	}
+
+	expected := []*Change{
+		{Type: Renamed, Kind: KindTable, Schema: "public", Table: "clients", Field: "name", From: "public.customers", To: "public.clients", Breaking: true},
+		{Type: Renamed, Kind: KindColumn, Schema: "public", Table: "orders", Name: "remarks", Field: "name", From: "notes", To: "remarks", Breaking: true},
+	}
+	if diff := cmp.Diff(expected, CompareWith(from, to, Options{DetectRenames: true})); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+	if got := expected[1].String(); got != "> column public.orders.remarks: renamed from notes" {
+		t.Errorf("Unexpected rename line %q", got)
+	}
+
+	// Two lookalike columns are ambiguous, unless the rename map tells
+	from = baseInfo()
+	to = baseInfo()
+	from.Tables[1].Columns = append(from.Tables[1].Columns, &dbinfo.Column{Name: "memo", Type: "text", FullType: "text", IsNullable: true})
+	to.Tables[1].Columns[2].Name = "summary"
+	to.Tables[1].Columns = append(to.Tables[1].Columns, &dbinfo.Column{Name: "details", Type: "text", FullType: "text", IsNullable: true})
+	to.Tables[1].Columns[2], to.Tables[1].Columns[3] = to.Tables[1].Columns[3], to.Tables[1].Columns[2]
+	from.Tables[1].Columns[2].Comment, to.Tables[1].Columns[3].Comment = "Same", "Same"
+	from.Tables[1].Columns[3].Comment, to.Tables[1].Columns[2].Comment = "Same", "Same"
+	var types []ChangeType
+	for _, change := range CompareWith(from, to, Options{DetectRenames: true}) {
+		types = append(types, change.Type)
+	}
+	if diff := cmp.Diff([]ChangeType{Added, Removed, Removed, Added}, types); diff != "" {
+		t.Errorf("Expected ambiguous columns to stay removed and added (-expected +actual):\n%s", diff)
+	}
+	changes := CompareWith(from, to, Options{Renames: map[string]string{"public.orders.notes": "details", "public.orders.memo": "summary"}})
+	var renames []string
+	for _, change := range changes {
+		renames = append(renames, change.String())
+	}
+	expectedRenames := []string{"> column public.orders.details: renamed from notes", "> column public.orders.summary: renamed from memo"}
+	if diff := cmp.Diff(expectedRenames, renames); diff != "" {
+		t.Errorf("Unexpected confirmed renames (-expected +actual):\n%s", diff)
+	}
+
+	// Table renames in the map may leave out the schema
+	from, to = baseInfo(), baseInfo()
+	to.Tables[2].Name = "archive"
+	changes = CompareWith(from, to, Options{Renames: map[string]string{"public.legacy": "archive"}})
+	if len(changes) != 1 || changes[0].Type != Renamed || changes[0].From != "public.legacy" {
+		t.Errorf("Expected legacy renamed to archive, got %v", changes)
+	}
+}
+
+func TestWriters(t *testing.T) {
+	changes := []*Change{
+		{Type: Added, Kind: KindTable, Schema: "billing", Table: "invoices"},
+		{Type: Removed, Kind: KindColumn, Schema: "public", Table: "orders", Name: "notes"},
+		{Type: Modified, Kind: KindColumn, Schema: "public", Table: "orders", Name: "status", Field: "default", To: "'pending'"},
+	}
+
+	var text bytes.Buffer
+	if err := WriteText(&text, changes); err != nil {
+		t.Fatal(err)
+	}
+	expected := "+ table billing.invoices\n- column public.orders.notes\n~ column public.orders.status: default (none) -> 'pending'\n"
+	if text.String() != expected {
+		t.Errorf("Unexpected text output:\n%s", text.String())
+	}
+
+	changes[1].Breaking = true
+	text.Reset()
+	if err := WriteColorText(&text, changes); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(text.String(), "\n")
+	if lines[0] != "\x1b[32m+\x1b[0m  \x1b[90mtable\x1b[0m   \x1b[1mbilling.invoices\x1b[0m" {
+		t.Errorf("Expected additions in green with aligned kinds, got %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "\x1b[31mbreaking\x1b[0m") {
+		t.Errorf("Expected breaking changes to be flagged, got %q", lines[1])
+	}
+	if !strings.HasSuffix(lines[2], "default (none) -> 'pending'") {
+		t.Errorf("Expected modifications to show both values, got %q", lines[2])
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("Expected an empty JSON array without changes, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := WriteJSON(&out, changes); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []*Change
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v", err)
+	}
+	if diff := cmp.Diff(changes, decoded); diff != "" {
+		t.Errorf("JSON round trip mismatch (-expected +actual):\n%s", diff)
+	}
+}
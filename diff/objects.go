@@ -0,0 +1,252 @@
+package diff
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// compareObjects compares the members of the schemas other than tables:
+// views, materialized views, sequences, domains, foreign tables and event
+// triggers, in that order and each sorted by schema and name. The changes
+// of an object name it in Table, as those of a table do, except event
+// triggers, which belong to no schema and are named by Name.
+func compareObjects(from, to *dbinfo.DBInfo) []*Change {
+	if from == nil {
+		from = &dbinfo.DBInfo{}
+	}
+	if to == nil {
+		to = &dbinfo.DBInfo{}
+	}
+	var changes []*Change
+
+	fromViews, toViews := byKey(from.Views, viewKey), byKey(to.Views, viewKey)
+	for _, key := range unionKeysFunc(fromViews, toViews, dbinfo.ObjectKey.Compare) {
+		changes = append(changes, compareViews(key, fromViews[key], toViews[key])...)
+	}
+	fromMatViews, toMatViews := byKey(from.MaterializedViews, matViewKey), byKey(to.MaterializedViews, matViewKey)
+	for _, key := range unionKeysFunc(fromMatViews, toMatViews, dbinfo.ObjectKey.Compare) {
+		changes = append(changes, compareMaterializedViews(key, fromMatViews[key], toMatViews[key])...)
+	}
+	fromSequences, toSequences := byKey(from.Sequences, sequenceKey), byKey(to.Sequences, sequenceKey)
+	for _, key := range unionKeysFunc(fromSequences, toSequences, dbinfo.ObjectKey.Compare) {
+		changes = append(changes, compareSequences(key, fromSequences[key], toSequences[key])...)
+	}
+	fromDomains, toDomains := byKey(from.Domains, domainKey), byKey(to.Domains, domainKey)
+	for _, key := range unionKeysFunc(fromDomains, toDomains, dbinfo.ObjectKey.Compare) {
+		changes = append(changes, compareDomains(key, fromDomains[key], toDomains[key])...)
+	}
+	fromForeign, toForeign := byKey(from.ForeignTables, foreignTableKey), byKey(to.ForeignTables, foreignTableKey)
+	for _, key := range unionKeysFunc(fromForeign, toForeign, dbinfo.ObjectKey.Compare) {
+		changes = append(changes, compareForeignTables(key, fromForeign[key], toForeign[key])...)
+	}
+
+	fromTriggers := make(map[string]*dbinfo.EventTrigger)
+	for _, trigger := range from.EventTriggers {
+		fromTriggers[trigger.Name] = trigger
+	}
+	toTriggers := make(map[string]*dbinfo.EventTrigger)
+	for _, trigger := range to.EventTriggers {
+		toTriggers[trigger.Name] = trigger
+	}
+	c := &collector{}
+	for _, name := range unionKeys(fromTriggers, toTriggers) {
+		oldTrigger, newTrigger := fromTriggers[name], toTriggers[name]
+		if !c.presence(KindEventTrigger, name, oldTrigger != nil, newTrigger != nil) {
+			continue
+		}
+		c.field(KindEventTrigger, name, "event", oldTrigger.Event, newTrigger.Event)
+		c.field(KindEventTrigger, name, "tags", list(oldTrigger.Tags), list(newTrigger.Tags))
+		c.field(KindEventTrigger, name, "function", oldTrigger.Function, newTrigger.Function)
+		c.field(KindEventTrigger, name, "enabled", oldTrigger.Enabled, newTrigger.Enabled)
+		c.field(KindEventTrigger, name, "comment", oldTrigger.Comment, newTrigger.Comment)
+	}
+	return append(changes, c.changes...)
+}
+
+// compareViews compares the view key of both schemas, either of which may
+// be missing. Removed views break their readers.
+func compareViews(key dbinfo.ObjectKey, from, to *dbinfo.View) []*Change {
+	c := &collector{schema: key.Schema, table: key.Name}
+	if !c.presence(KindView, "", from != nil, to != nil) {
+		c.last().Breaking = c.last().Type == Removed
+		return c.changes
+	}
+	c.field(KindView, "", "definition", from.Definition, to.Definition)
+	c.field(KindView, "", "updatable", strconv.FormatBool(from.Updatable), strconv.FormatBool(to.Updatable))
+	c.field(KindView, "", "comment", from.Comment, to.Comment)
+	if from.Owner != "" && to.Owner != "" {
+		c.field(KindView, "", "owner", from.Owner, to.Owner)
+		c.field(KindView, "", "grants", grants(from.Grants), grants(to.Grants))
+	}
+	c.outputColumns(from.Columns, to.Columns)
+	c.rules(from.Rules, to.Rules)
+	return c.changes
+}
+
+// compareMaterializedViews compares the materialized view key of both
+// schemas, either of which may be missing. Removed materialized views break
+// their readers.
+func compareMaterializedViews(key dbinfo.ObjectKey, from, to *dbinfo.MaterializedView) []*Change {
+	c := &collector{schema: key.Schema, table: key.Name}
+	if !c.presence(KindMaterializedView, "", from != nil, to != nil) {
+		c.last().Breaking = c.last().Type == Removed
+		return c.changes
+	}
+	c.field(KindMaterializedView, "", "definition", from.Definition, to.Definition)
+	c.field(KindMaterializedView, "", "populated", strconv.FormatBool(from.Populated), strconv.FormatBool(to.Populated))
+	c.field(KindMaterializedView, "", "comment", from.Comment, to.Comment)
+	if from.Owner != "" && to.Owner != "" {
+		c.field(KindMaterializedView, "", "owner", from.Owner, to.Owner)
+		c.field(KindMaterializedView, "", "grants", grants(from.Grants), grants(to.Grants))
+	}
+	c.outputColumns(from.Columns, to.Columns)
+	c.indexes(from.Indexes, to.Indexes)
+	return c.changes
+}
+
+// compareSequences compares the sequence key of both schemas, either of
+// which may be missing. The last value handed out changes with every
+// insert and isn't compared. Removed sequences break the defaults calling
+// nextval on them.
+func compareSequences(key dbinfo.ObjectKey, from, to *dbinfo.Sequence) []*Change {
+	c := &collector{schema: key.Schema, table: key.Name}
+	if !c.presence(KindSequence, "", from != nil, to != nil) {
+		c.last().Breaking = c.last().Type == Removed
+		return c.changes
+	}
+	c.field(KindSequence, "", "type", from.Type, to.Type)
+	c.field(KindSequence, "", "start", strconv.FormatInt(from.Start, 10), strconv.FormatInt(to.Start, 10))
+	c.field(KindSequence, "", "increment", strconv.FormatInt(from.Increment, 10), strconv.FormatInt(to.Increment, 10))
+	c.field(KindSequence, "", "min", strconv.FormatInt(from.Min, 10), strconv.FormatInt(to.Min, 10))
+	c.field(KindSequence, "", "max", strconv.FormatInt(from.Max, 10), strconv.FormatInt(to.Max, 10))
+	c.field(KindSequence, "", "cache", strconv.FormatInt(from.Cache, 10), strconv.FormatInt(to.Cache, 10))
+	c.field(KindSequence, "", "cycle", strconv.FormatBool(from.Cycle), strconv.FormatBool(to.Cycle))
+	c.field(KindSequence, "", "ownedby", sequenceOwner(from.OwnedBy), sequenceOwner(to.OwnedBy))
+	return c.changes
+}
+
+// compareDomains compares the domain key of both schemas, either of which
+// may be missing. Removed domains break the columns and casts using them,
+// new checks reject values that used to be accepted.
+func compareDomains(key dbinfo.ObjectKey, from, to *dbinfo.Domain) []*Change {
+	c := &collector{schema: key.Schema, table: key.Name}
+	if !c.presence(KindDomain, "", from != nil, to != nil) {
+		c.last().Breaking = c.last().Type == Removed
+		return c.changes
+	}
+	c.field(KindDomain, "", "type", from.Type, to.Type)
+	c.field(KindDomain, "", "notnull", strconv.FormatBool(from.NotNull), strconv.FormatBool(to.NotNull))
+	c.field(KindDomain, "", "default", from.Default, to.Default)
+	oldChecks, newChecks := domainChecks(from), domainChecks(to)
+	if old, new := list(oldChecks), list(newChecks); old != new {
+		c.field(KindDomain, "", "checks", old, new)
+		c.last().Breaking = slices.ContainsFunc(newChecks, func(check string) bool { return !slices.Contains(oldChecks, check) })
+	}
+	c.field(KindDomain, "", "comment", from.Comment, to.Comment)
+	return c.changes
+}
+
+// compareForeignTables compares the foreign table key of both schemas,
+// either of which may be missing. Removed foreign tables break their
+// readers, and moving one to another server reads other data.
+func compareForeignTables(key dbinfo.ObjectKey, from, to *dbinfo.ForeignTable) []*Change {
+	c := &collector{schema: key.Schema, table: key.Name}
+	if !c.presence(KindForeignTable, "", from != nil, to != nil) {
+		c.last().Breaking = c.last().Type == Removed
+		return c.changes
+	}
+	c.field(KindForeignTable, "", "server", from.Server, to.Server)
+	c.field(KindForeignTable, "", "wrapper", from.Wrapper, to.Wrapper)
+	c.field(KindForeignTable, "", "options", options(from.Options), options(to.Options))
+	c.field(KindForeignTable, "", "comment", from.Comment, to.Comment)
+	if from.Owner != "" && to.Owner != "" {
+		c.field(KindForeignTable, "", "owner", from.Owner, to.Owner)
+		c.field(KindForeignTable, "", "grants", grants(from.Grants), grants(to.Grants))
+	}
+	c.outputColumns(from.Columns, to.Columns)
+	for _, column := range to.Columns {
+		if slices.ContainsFunc(from.Columns, func(old *dbinfo.Column) bool { return old.Name == column.Name }) {
+			c.field(KindColumn, column.Name, "options", options(from.ColumnOptions[column.Name]), options(to.ColumnOptions[column.Name]))
+		}
+	}
+	return c.changes
+}
+
+// outputColumns records the changes of the columns of a view, materialized
+// view or foreign table. Their nullability and defaults aren't recorded, so
+// only their types are compared. Removed columns and type changes break
+// readers.
+func (c *collector) outputColumns(from, to []*dbinfo.Column) {
+	fromColumns := make(map[string]*dbinfo.Column)
+	for _, column := range from {
+		fromColumns[column.Name] = column
+	}
+	toColumns := make(map[string]*dbinfo.Column)
+	for _, column := range to {
+		toColumns[column.Name] = column
+	}
+	for _, name := range unionKeys(fromColumns, toColumns) {
+		oldColumn, newColumn := fromColumns[name], toColumns[name]
+		if !c.presence(KindColumn, name, oldColumn != nil, newColumn != nil) {
+			c.last().Breaking = c.last().Type == Removed
+			continue
+		}
+		c.field(KindColumn, name, "type", columnType(oldColumn, newColumn), columnType(newColumn, oldColumn))
+		c.field(KindColumn, name, "comment", oldColumn.Comment, newColumn.Comment)
+	}
+}
+
+// byKey indexes objects by schema and name
+func byKey[T any](objects []T, key func(T) dbinfo.ObjectKey) map[dbinfo.ObjectKey]T {
+	m := make(map[dbinfo.ObjectKey]T, len(objects))
+	for _, object := range objects {
+		m[key(object)] = object
+	}
+	return m
+}
+
+func viewKey(v *dbinfo.View) dbinfo.ObjectKey {
+	return dbinfo.ObjectKey{Schema: v.Schema, Name: v.Name}
+}
+func matViewKey(v *dbinfo.MaterializedView) dbinfo.ObjectKey {
+	return dbinfo.ObjectKey{Schema: v.Schema, Name: v.Name}
+}
+func sequenceKey(s *dbinfo.Sequence) dbinfo.ObjectKey {
+	return dbinfo.ObjectKey{Schema: s.Schema, Name: s.Name}
+}
+func domainKey(d *dbinfo.Domain) dbinfo.ObjectKey {
+	return dbinfo.ObjectKey{Schema: d.Schema, Name: d.Name}
+}
+func foreignTableKey(t *dbinfo.ForeignTable) dbinfo.ObjectKey {
+	return dbinfo.ObjectKey{Schema: t.Schema, Name: t.Name}
+}
+
+// sequenceOwner returns the column owning a sequence as schema.table.column,
+// or an empty string
+func sequenceOwner(owner *dbinfo.SequenceOwner) string {
+	if owner == nil {
+		return ""
+	}
+	return owner.Schema + "." + owner.Table + "." + owner.Column
+}
+
+// domainChecks lists the checks of a domain as name: definition
+func domainChecks(domain *dbinfo.Domain) []string {
+	checks := make([]string, len(domain.Checks))
+	for i, check := range domain.Checks {
+		checks[i] = check.Name + ": " + check.Definition
+	}
+	return checks
+}
+
+// options lists options as key=value, sorted by key
+func options(values map[string]string) string {
+	keys := slices.Sorted(maps.Keys(values))
+	for i, key := range keys {
+		keys[i] = key + "=" + values[key]
+	}
+	return list(keys)
+}
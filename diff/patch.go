@@ -0,0 +1,484 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Operation is an RFC 6902 JSON Patch operation on the JSON form of a
+// snapshot
+type Operation struct {
+	Op    string          `json:"op"` // add, remove, replace, move, copy or test
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`  // Source of move and copy
+	Value json.RawMessage `json:"value,omitempty"` // Value of add, replace and test
+}
+
+// Patch returns the JSON Patch turning the JSON form of the from schema
+// into the to schema, see PatchWith
+func Patch(from, to *dbinfo.DBInfo) ([]*Operation, error) {
+	return PatchWith(from, to, Options{})
+}
+
+// PatchWith returns the JSON Patch turning the tables of the from schema
+// into those of the to schema, as Apply replays it. Tables, columns,
+// indexes and foreign keys are matched by name, renames following opts as
+// in CompareWith, so modifying an attribute replaces that attribute only,
+// as in
+//
+//	{"op": "replace", "path": "/tables/3/columns/1/isnullable", "value": false}
+//
+// Removals come first, then modifications and additions, each path
+// pointing into the document as the previous operations left it. The
+// relationships derived from foreign keys and the workload of tables
+// aren't patched, and neither are the other members of the snapshot.
+func PatchWith(from, to *dbinfo.DBInfo, opts Options) ([]*Operation, error) {
+	fromTables, toTables := tablesByKey(from), tablesByKey(to)
+	renamed := matchRenames(fromTables, toTables, opts.tableRenames(), opts.DetectRenames, sameTable, dbinfo.ObjectKey.Compare)
+
+	p := &patcher{}
+	var before, after []*member[dbinfo.ObjectKey]
+	if from != nil {
+		before = members(from.Tables, keyOf)
+	}
+	if to != nil {
+		after = members(to.Tables, keyOf)
+	}
+	patchList(p, "/tables", before, after, renamed, func(path string, old, new any) {
+		p.table(path, old.(*dbinfo.Table), new.(*dbinfo.Table), opts)
+	})
+	return p.ops, p.err
+}
+
+// member is an element of a JSON array matched by key
+type member[K comparable] struct {
+	key   K
+	value any
+}
+
+// members returns the elements of a JSON array with their keys
+func members[K comparable, T any](elements []T, key func(T) K) []*member[K] {
+	list := make([]*member[K], len(elements))
+	for i, element := range elements {
+		list[i] = &member[K]{key: key(element), value: element}
+	}
+	return list
+}
+
+// patcher accumulates the operations of a patch
+type patcher struct {
+	ops []*Operation
+	err error
+}
+
+// add records an operation with value encoded as JSON
+func (p *patcher) add(op, path string, value any) {
+	operation := &Operation{Op: op, Path: path}
+	if op != "remove" {
+		data, err := json.Marshal(value)
+		if err != nil {
+			p.err = errors.Join(p.err, err)
+			return
+		}
+		operation.Value = data
+	}
+	p.ops = append(p.ops, operation)
+}
+
+// patchList records in p the operations turning the array at path, holding
+// before, into after. Members are matched by key, or by the old key of
+// renamed, which maps new keys to old ones; matched members are compared
+// with modify.
+func patchList[K comparable](p *patcher, path string, before, after []*member[K], renamed map[K]K, modify func(path string, old, new any)) {
+	if len(before) == 0 && len(after) > 0 {
+		// Empty arrays may be left out of the document or null, so they
+		// are set as a whole
+		values := make([]any, len(after))
+		for i, m := range after {
+			values[i] = m.value
+		}
+		p.add("add", path, values)
+		return
+	}
+
+	newKeys := make(map[K]bool, len(after))
+	for _, m := range after {
+		newKeys[m.key] = true
+	}
+	oldKeys := make(map[K]bool, len(renamed))
+	for _, old := range renamed {
+		oldKeys[old] = true
+	}
+
+	current := make([]K, 0, len(before))
+	values := make(map[K]any, len(before))
+	for _, m := range before {
+		current = append(current, m.key)
+		values[m.key] = m.value
+	}
+	for i := len(current) - 1; i >= 0; i-- {
+		if key := current[i]; !newKeys[key] && !oldKeys[key] {
+			p.add("remove", path+"/"+strconv.Itoa(i), nil)
+			current = slices.Delete(current, i, i+1)
+		}
+	}
+	for _, m := range after {
+		old, ok := renamed[m.key]
+		if !ok {
+			old = m.key
+		}
+		if i := slices.Index(current, old); i >= 0 {
+			modify(path+"/"+strconv.Itoa(i), values[old], m.value)
+		}
+	}
+	for i, m := range after {
+		old, ok := renamed[m.key]
+		if !ok {
+			old = m.key
+		}
+		if slices.Contains(current, old) {
+			continue
+		}
+		i = min(i, len(current))
+		p.add("add", path+"/"+strconv.Itoa(i), m.value)
+		current = slices.Insert(current, i, m.key)
+	}
+}
+
+// object records the operations turning the JSON object at path from old
+// into new, member by member, leaving out the members in skip
+func (p *patcher) object(path string, old, new any, skip ...string) {
+	oldMembers, err := objectMembers(old)
+	if err != nil {
+		p.err = errors.Join(p.err, err)
+		return
+	}
+	newMembers, err := objectMembers(new)
+	if err != nil {
+		p.err = errors.Join(p.err, err)
+		return
+	}
+	for _, key := range unionKeys(oldMembers, newMembers) {
+		if slices.Contains(skip, key) {
+			continue
+		}
+		before, inOld := oldMembers[key]
+		after, inNew := newMembers[key]
+		switch {
+		case !inNew:
+			p.add("remove", path+"/"+escapePointer(key), nil)
+		case !inOld:
+			p.ops = append(p.ops, &Operation{Op: "add", Path: path + "/" + escapePointer(key), Value: after})
+		case !bytes.Equal(before, after):
+			p.ops = append(p.ops, &Operation{Op: "replace", Path: path + "/" + escapePointer(key), Value: after})
+		}
+	}
+}
+
+// objectMembers returns the members of the JSON object encoding v
+func objectMembers(v any) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	err = json.Unmarshal(data, &m)
+	return m, err
+}
+
+// table records the operations turning the table at path from old into new
+func (p *patcher) table(path string, old, new *dbinfo.Table, opts Options) {
+	p.object(path, old, new, "columns", "indexes", "foreignkeys", "hasmany", "belongsto", "workload")
+
+	renamed := matchRenames(columnsByName(old), columnsByName(new), opts.columnRenames(old), opts.DetectRenames, sameColumn(old, new), strings.Compare)
+	modify := func(path string, old, new any) { p.object(path, old, new) }
+	columnKey := func(c *dbinfo.Column) string { return c.Name }
+	patchList(p, path+"/columns", members(old.Columns, columnKey), members(new.Columns, columnKey), renamed, modify)
+	indexKey := func(i *dbinfo.Index) string { return i.Name }
+	patchList(p, path+"/indexes", members(old.Indexes, indexKey), members(new.Indexes, indexKey), nil, modify)
+	foreignKeyKey := func(fk *dbinfo.ForeignKey) string { return fk.Name }
+	patchList(p, path+"/foreignkeys", members(old.ForeignKeys, foreignKeyKey), members(new.ForeignKeys, foreignKeyKey), nil, modify)
+}
+
+// columnsByName indexes the columns of a table
+func columnsByName(table *dbinfo.Table) map[string]*dbinfo.Column {
+	columns := make(map[string]*dbinfo.Column, len(table.Columns))
+	for _, column := range table.Columns {
+		columns[column.Name] = column
+	}
+	return columns
+}
+
+// Apply replays a JSON Patch, such as one written by PatchWith, on the
+// JSON form of info and returns the patched schema, leaving info unchanged.
+// Every RFC 6902 operation is supported. The relationships between tables
+// are rebuilt from the patched foreign keys.
+func Apply(info *dbinfo.DBInfo, patch []*Operation) (*dbinfo.DBInfo, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range patch {
+		if doc, err = applyOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	var patched dbinfo.DBInfo
+	if err := json.Unmarshal(data, &patched); err != nil {
+		return nil, fmt.Errorf("patched document is not a snapshot: %w", err)
+	}
+	patched.BuildRelationships()
+	return &patched, nil
+}
+
+// decodeJSON decodes a JSON value into maps, slices and json.Number values
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// applyOperation applies a single operation to doc and returns the new
+// document
+func applyOperation(doc any, op *Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, errors.New("missing value")
+		}
+		if value, err = decodeJSON(op.Value); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = lookupPointer(doc, from); err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+				return nil, errors.New("cannot move a value into itself")
+			}
+			if doc, err = updatePointer(doc, from, removeMember); err != nil {
+				return nil, err
+			}
+		} else {
+			value = copyJSON(value)
+		}
+	}
+
+	if len(path) == 0 && op.Op != "test" {
+		// The whole document
+		if op.Op == "remove" {
+			return nil, errors.New("cannot remove the whole document")
+		}
+		return value, nil
+	}
+	switch op.Op {
+	case "add", "move", "copy":
+		return updatePointer(doc, path, func(parent any, key string) (any, error) { return addMember(parent, key, value) })
+	case "remove":
+		return updatePointer(doc, path, removeMember)
+	case "replace":
+		return updatePointer(doc, path, func(parent any, key string) (any, error) {
+			parent, err := removeMember(parent, key)
+			if err != nil {
+				return nil, err
+			}
+			return addMember(parent, key, value)
+		})
+	case "test":
+		current, err := lookupPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// escapePointer escapes a member name as a JSON pointer token
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// lookupPointer returns the value at path
+func lookupPointer(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]any:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[i]
+		default:
+			return nil, fmt.Errorf("cannot look up %q in a scalar", token)
+		}
+	}
+	return doc, nil
+}
+
+// updatePointer applies change to the container holding the value at
+// path, a non-empty pointer, and the last token of path, returning the
+// updated document
+func updatePointer(doc any, path []string, change func(parent any, key string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	child, err := lookupPointer(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	child, err = updatePointer(child, path[1:], change)
+	if err != nil {
+		return nil, err
+	}
+	switch container := doc.(type) {
+	case map[string]any:
+		container[path[0]] = child
+	case []any:
+		i, _ := arrayIndex(path[0], len(container)-1)
+		container[i] = child
+	}
+	return doc, nil
+}
+
+// addMember sets a member of an object, or inserts an element into an
+// array, - appending it
+func addMember(parent any, key string, value any) (any, error) {
+	switch container := parent.(type) {
+	case map[string]any:
+		container[key] = value
+		return container, nil
+	case []any:
+		if key == "-" {
+			return append(container, value), nil
+		}
+		i, err := arrayIndex(key, len(container))
+		if err != nil {
+			return nil, err
+		}
+		return slices.Insert(container, i, value), nil
+	}
+	return nil, fmt.Errorf("cannot add %q to a scalar", key)
+}
+
+// removeMember removes a member of an object or an element of an array
+func removeMember(parent any, key string) (any, error) {
+	switch container := parent.(type) {
+	case map[string]any:
+		if _, ok := container[key]; !ok {
+			return nil, fmt.Errorf("member %q does not exist", key)
+		}
+		delete(container, key)
+		return container, nil
+	case []any:
+		i, err := arrayIndex(key, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+		return slices.Delete(container, i, i+1), nil
+	}
+	return nil, fmt.Errorf("cannot remove %q from a scalar", key)
+}
+
+// arrayIndex parses an array index token, which must be at most max
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || token != strconv.Itoa(i) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	return i, nil
+}
+
+// copyJSON returns a deep copy of a decoded JSON value
+func copyJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for key, member := range v {
+			c[key] = copyJSON(member)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, element := range v {
+			c[i] = copyJSON(element)
+		}
+		return c
+	}
+	return value
+}
+
+// WritePatch writes a JSON Patch to w as a JSON array
+func WritePatch(w io.Writer, patch []*Operation) error {
+	if patch == nil {
+		patch = []*Operation{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(patch)
+}
+
+// ReadPatch reads a JSON Patch written by WritePatch, or by any other RFC
+// 6902 implementation
+func ReadPatch(r io.Reader) ([]*Operation, error) {
+	var patch []*Operation
+	if err := json.NewDecoder(r).Decode(&patch); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON Patch: %w", err)
+	}
+	return patch, nil
+}
@@ -0,0 +1,177 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/guillermo/dbinfo"
+)
+
+func TestPatch(t *testing.T) {
+	from := baseInfo()
+	to := baseInfo()
+	to.Tables = to.Tables[:2]
+	customers, orders := to.Tables[0], to.Tables[1]
+	customers.Columns[1].FullType = "text"
+	customers.Columns[1].Type = "text"
+	customers.Comment = "People who order"
+	orders.Columns = []*dbinfo.Column{
+		orders.Columns[0],
+		{Name: "status", Type: "text", FullType: "text", DefaultValue: "'new'::text"},
+		orders.Columns[1],
+	}
+	orders.Indexes[0].Unique = true
+	to.Tables = append(to.Tables, &dbinfo.Table{
+		Name:    "invoices",
+		Schema:  "public",
+		Columns: []*dbinfo.Column{{Name: "id", Type: "bigint", FullType: "bigint"}},
+	})
+
+	patch, err := Patch(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, op := range patch {
+		got = append(got, op.Op+" "+op.Path+" "+string(op.Value))
+	}
+	want := []string{
+		"remove /tables/2 ",
+		`add /tables/0/comment "People who order"`,
+		`replace /tables/0/columns/1/fulltype "text"`,
+		`replace /tables/0/columns/1/type "text"`,
+		"remove /tables/1/columns/2 ",
+		`add /tables/1/columns/1 {"name":"status","type":"text","fulltype":"text","isnullable":false,"defaultvalue":"'new'::text","comment":"","isprimarykey":false}`,
+		"replace /tables/1/indexes/0/unique true",
+		`add /tables/2 {"name":"invoices","schema":"public","columns":[{"name":"id","type":"bigint","fulltype":"bigint","isnullable":false,"defaultvalue":"","comment":"","isprimarykey":false}]}`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected patch (-want +got):\n%s", diff)
+	}
+
+	patched, err := Apply(from, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to.BuildRelationships()
+	if diff := cmp.Diff(to, patched, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Patched schema differs (-want +got):\n%s", diff)
+	}
+	if changes := Compare(baseInfo(), from); len(changes) != 0 {
+		t.Errorf("Expected Apply to leave the schema unchanged, got %v", changes)
+	}
+}
+
+func TestPatchRenames(t *testing.T) {
+	from := baseInfo()
+	to := baseInfo()
+	to.Tables[0].Name = "clients"
+	to.Tables[1].Columns[2].Name = "remarks"
+	to.Tables[1].ForeignKeys[0].RefTableName = "clients"
+
+	patch, err := PatchWith(from, to, Options{Renames: map[string]string{
+		"public.customers":    "clients",
+		"public.orders.notes": "remarks",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, op := range patch {
+		got = append(got, op.Op+" "+op.Path+" "+string(op.Value))
+	}
+	want := []string{
+		`replace /tables/0/name "clients"`,
+		`replace /tables/1/columns/2/name "remarks"`,
+		`replace /tables/1/foreignkeys/0/reftablename "clients"`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Unexpected patch (-want +got):\n%s", diff)
+	}
+}
+
+func TestPatchEmpty(t *testing.T) {
+	to := baseInfo()
+	patch, err := Patch(&dbinfo.DBInfo{Name: "shop"}, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Op != "add" || patch[0].Path != "/tables" {
+		t.Fatalf("Expected the tables to be added as a whole, got %+v", patch)
+	}
+	patched, err := Apply(&dbinfo.DBInfo{Name: "shop"}, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := Compare(to, patched); len(changes) != 0 {
+		t.Errorf("Expected the patched schema to match, got %v", changes)
+	}
+}
+
+func TestApply(t *testing.T) {
+	patch, err := ReadPatch(strings.NewReader(`[
+		{"op": "test", "path": "/tables/1/name", "value": "orders"},
+		{"op": "copy", "from": "/tables/1/columns/2", "path": "/tables/1/columns/-"},
+		{"op": "replace", "path": "/tables/1/columns/3/name", "value": "internal_notes"},
+		{"op": "move", "from": "/tables/2", "path": "/tables/0"},
+		{"op": "remove", "path": "/tables/2/foreignkeys/0"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := Apply(baseInfo(), patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tables, columns []string
+	for _, table := range patched.Tables {
+		tables = append(tables, table.Name)
+	}
+	for _, column := range patched.Tables[2].Columns {
+		columns = append(columns, column.Name)
+	}
+	if diff := cmp.Diff([]string{"legacy", "customers", "orders"}, tables); diff != "" {
+		t.Errorf("Unexpected tables (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"id", "customer_id", "notes", "internal_notes"}, columns); diff != "" {
+		t.Errorf("Unexpected columns (-want +got):\n%s", diff)
+	}
+	if len(patched.Tables[1].HasMany) != 0 {
+		t.Errorf("Expected the relationships to follow the removed foreign key, got %+v", patched.Tables[1].HasMany)
+	}
+
+	errors := []struct {
+		op   string
+		want string
+	}{
+		{`{"op": "test", "path": "/name", "value": "other"}`, "patch operation 0 (test /name): test failed"},
+		{`{"op": "remove", "path": "/tables/3"}`, `patch operation 0 (remove /tables/3): invalid array index "3"`},
+		{`{"op": "replace", "path": "/tables/0/missing", "value": 1}`, `patch operation 0 (replace /tables/0/missing): member "missing" does not exist`},
+		{`{"op": "move", "from": "/tables", "path": "/tables/0/columns"}`, "patch operation 0 (move /tables/0/columns): cannot move a value into itself"},
+		{`{"op": "add", "path": "/tables/0/name/x", "value": 1}`, `patch operation 0 (add /tables/0/name/x): cannot add "x" to a scalar`},
+		{`{"op": "rename", "path": "/name"}`, `patch operation 0 (rename /name): unknown operation "rename"`},
+	}
+	for _, tt := range errors {
+		var op Operation
+		if err := json.Unmarshal([]byte(tt.op), &op); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Apply(baseInfo(), []*Operation{&op}); err == nil || err.Error() != tt.want {
+			t.Errorf("Apply(%s) = %v, want %s", tt.op, err, tt.want)
+		}
+	}
+}
+
+func TestWritePatch(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePatch(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("Expected an empty array, got %s", got)
+	}
+}
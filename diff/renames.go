@@ -0,0 +1,142 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Options configure CompareWith
+type Options struct {
+	// DetectRenames reports a removed table or column as renamed when a
+	// single added one looks like it under another name. Tables must have
+	// the same schema and the same columns. Columns must have the same
+	// type, nullability and default, and either the same position or the
+	// same comment.
+	DetectRenames bool
+	// Renames confirms renames the heuristics miss or can't tell apart,
+	// mapping old names to new ones: schema.table to schema.table, or to
+	// table in the same schema, and schema.table.column, named after the
+	// table in the from schema, to the new column name. Entries that don't
+	// map a removed table or column to an added one are ignored.
+	Renames map[string]string
+}
+
+// tableRenames returns the table entries of Renames
+func (o Options) tableRenames() map[dbinfo.ObjectKey]dbinfo.ObjectKey {
+	renames := make(map[dbinfo.ObjectKey]dbinfo.ObjectKey)
+	for old, new := range o.Renames {
+		schema, name, ok := strings.Cut(old, ".")
+		if !ok || strings.Contains(name, ".") {
+			continue
+		}
+		newSchema, newName, ok := strings.Cut(new, ".")
+		if !ok {
+			newSchema, newName = schema, new
+		}
+		renames[dbinfo.ObjectKey{Schema: schema, Name: name}] = dbinfo.ObjectKey{Schema: newSchema, Name: newName}
+	}
+	return renames
+}
+
+// columnRenames returns the column entries of Renames for table, by
+// column name
+func (o Options) columnRenames(table *dbinfo.Table) map[string]string {
+	renames := make(map[string]string)
+	prefix := table.Schema + "." + table.Name + "."
+	for old, new := range o.Renames {
+		if column, ok := strings.CutPrefix(old, prefix); ok && !strings.Contains(column, ".") {
+			renames[column] = new
+		}
+	}
+	return renames
+}
+
+// matchRenames pairs the keys of from missing in to with the keys of to
+// missing in from: first the explicit renames, then, when detect is set,
+// the ones same matches unambiguously, each having a single candidate on
+// the other side. Keys are visited in the order of compare. It returns the
+// old keys by new key.
+func matchRenames[K comparable, V any](from, to map[K]V, explicit map[K]K, detect bool, same func(old, new V) bool, compare func(K, K) int) map[K]K {
+	var removed, added []K
+	for _, key := range unionKeysFunc(from, to, compare) {
+		_, before := from[key]
+		_, after := to[key]
+		switch {
+		case before && !after:
+			removed = append(removed, key)
+		case after && !before:
+			added = append(added, key)
+		}
+	}
+
+	renamed := make(map[K]K)
+	paired := make(map[K]bool)
+	isRenamed := func(new K) bool {
+		_, ok := renamed[new]
+		return ok
+	}
+	for _, old := range removed {
+		if new, ok := explicit[old]; ok && slices.Contains(added, new) && !isRenamed(new) {
+			renamed[new] = old
+			paired[old] = true
+		}
+	}
+	if !detect {
+		return renamed
+	}
+
+	candidates := func(keys []K, match func(K) bool) []K {
+		var found []K
+		for _, other := range keys {
+			if !paired[other] && !isRenamed(other) && match(other) {
+				found = append(found, other)
+			}
+		}
+		return found
+	}
+	for _, old := range removed {
+		if paired[old] {
+			continue
+		}
+		news := candidates(added, func(new K) bool { return same(from[old], to[new]) })
+		if len(news) != 1 {
+			continue
+		}
+		olds := candidates(removed, func(other K) bool { return same(from[other], to[news[0]]) })
+		if len(olds) == 1 {
+			renamed[news[0]] = old
+			paired[old] = true
+		}
+	}
+	return renamed
+}
+
+// sameTable reports whether a table looks like the other renamed: same
+// schema and same columns, in the same order
+func sameTable(old, new *dbinfo.Table) bool {
+	if old.Schema != new.Schema || len(old.Columns) == 0 || len(old.Columns) != len(new.Columns) {
+		return false
+	}
+	for i, column := range old.Columns {
+		other := new.Columns[i]
+		if column.Name != other.Name || columnType(column, other) != columnType(other, column) || column.IsNullable != other.IsNullable {
+			return false
+		}
+	}
+	return true
+}
+
+// sameColumn returns whether a column of from looks like a column of to
+// renamed: same type, nullability and default, and either the same
+// position or the same comment
+func sameColumn(from, to *dbinfo.Table) func(old, new *dbinfo.Column) bool {
+	return func(old, new *dbinfo.Column) bool {
+		if columnType(old, new) != columnType(new, old) || old.IsNullable != new.IsNullable || normalizedDefault(old) != normalizedDefault(new) {
+			return false
+		}
+		samePosition := slices.Index(from.Columns, old) == slices.Index(to.Columns, new)
+		return samePosition || (old.Comment != "" && old.Comment == new.Comment)
+	}
+}
@@ -0,0 +1,137 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Domain is a domain: a base type narrowed by a NOT NULL, a default and
+// CHECK constraints, which columns declared with it inherit
+type Domain struct {
+	Name    string         `json:"name" yaml:"name"`
+	Schema  string         `json:"schema" yaml:"schema"`
+	Type    string         `json:"type" yaml:"type"` // Base type with its modifiers, e.g. character varying(254)
+	NotNull bool           `json:"notnull" yaml:"notnull"`
+	Default string         `json:"default,omitempty" yaml:"default,omitempty"` // As printed by the server
+	Checks  []*DomainCheck `json:"checks" yaml:"checks"`                       // Sorted by name
+	Comment string         `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// DomainCheck is a CHECK constraint of a domain
+type DomainCheck struct {
+	Name string `json:"name" yaml:"name"`
+	// Definition is the constraint as pg_get_constraintdef prints it, such
+	// as CHECK ((VALUE > 0))
+	Definition string `json:"definition" yaml:"definition"`
+}
+
+// DomainRef names the domain a column is declared with
+type DomainRef struct {
+	Schema string `json:"schema" yaml:"schema"`
+	Name   string `json:"name" yaml:"name"`
+}
+
+// QualifiedName returns the quoted schema.name of the domain
+func (d *Domain) QualifiedName() string {
+	return QualifiedName(d.Schema, d.Name)
+}
+
+// Constraints lists what the domain adds to its base type, in the order
+// CREATE DOMAIN takes them: DEFAULT, NOT NULL and the CHECK constraints
+func (d *Domain) Constraints() []string {
+	var constraints []string
+	if d.Default != "" {
+		constraints = append(constraints, "DEFAULT "+d.Default)
+	}
+	if d.NotNull {
+		constraints = append(constraints, "NOT NULL")
+	}
+	for _, check := range d.Checks {
+		constraints = append(constraints, check.Definition)
+	}
+	return constraints
+}
+
+// Domain returns the domain schema.name, nil when it isn't in the snapshot
+func (d *DBInfo) Domain(schema, name string) *Domain {
+	for _, domain := range d.Domains {
+		if domain.Schema == schema && domain.Name == name {
+			return domain
+		}
+	}
+	return nil
+}
+
+// ColumnDomain returns the domain column is declared with, nil for columns
+// of other types or domains outside of the snapshot
+func (d *DBInfo) ColumnDomain(column *Column) *Domain {
+	if column.Domain == nil {
+		return nil
+	}
+	return d.Domain(column.Domain.Schema, column.Domain.Name)
+}
+
+// domainsQuery lists the domains of the user schemas included by $1 and not
+// excluded by $2, leaving out those of extensions, with their CHECK
+// constraints and their comments when $3 is set. From PostgreSQL 17 NOT
+// NULL is also recorded as a constraint, which typnotnull already tells.
+const domainsQuery = `
+	SELECT n.nspname, t.typname, pg_catalog.format_type(t.typbasetype, t.typtypmod), t.typnotnull, t.typdefault,
+	       CASE WHEN $3::boolean THEN pg_catalog.obj_description(t.oid, 'pg_type') END,
+	       COALESCE(array_agg(c.conname::text ORDER BY c.conname) FILTER (WHERE c.oid IS NOT NULL), '{}'),
+	       COALESCE(array_agg(pg_catalog.pg_get_constraintdef(c.oid) ORDER BY c.conname) FILTER (WHERE c.oid IS NOT NULL), '{}')
+	FROM pg_catalog.pg_type t
+	JOIN pg_catalog.pg_namespace n ON n.oid = t.typnamespace
+	LEFT JOIN pg_catalog.pg_constraint c ON c.contypid = t.oid AND c.contype = 'c'
+	WHERE t.typtype = 'd'
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	AND NOT EXISTS (
+	    SELECT 1 FROM pg_catalog.pg_depend e
+	    WHERE e.classid = 'pg_catalog.pg_type'::regclass AND e.objid = t.oid AND e.deptype = 'e'
+	)
+	GROUP BY t.oid, n.nspname
+	ORDER BY n.nspname, t.typname`
+
+// getDomains reads the domains of the schemas the options select, sorted
+// by schema and name. The table filters don't apply to domains.
+func getDomains(ctx context.Context, db DBQuerier, o *options) ([]*Domain, error) {
+	rows, err := db.Query(ctx, domainsQuery, o.schemas, o.excludeSchemas, !o.skipComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domains: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []*Domain
+	for rows.Next() {
+		d := &Domain{}
+		var defaultValue, comment *string // NULL without a default or comment
+		var names, definitions []string
+		if err := rows.Scan(&d.Schema, &d.Name, &d.Type, &d.NotNull, &defaultValue, &comment, &names, &definitions); err != nil {
+			return nil, fmt.Errorf("failed to scan domain row: %w", err)
+		}
+		if defaultValue != nil {
+			d.Default = *defaultValue
+		}
+		if comment != nil {
+			d.Comment = *comment
+		}
+		d.Checks = make([]*DomainCheck, 0, len(names))
+		for i, name := range names {
+			if i < len(definitions) {
+				d.Checks = append(d.Checks, &DomainCheck{Name: name, Definition: definitions[i]})
+			}
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating domain rows: %w", err)
+	}
+	slices.SortStableFunc(domains, func(a, b *Domain) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	return domains, nil
+}
@@ -0,0 +1,105 @@
+package dbinfo
+
+// EnsureSlices replaces the nil slices of the model with empty ones, so
+// JSON encodes lists that may be empty as [] rather than null and code
+// ranging over the model can tell "none" from "not loaded" without nil
+// checks. GetDBInfo, StreamTables and snapshots loaded with the snapshot
+// package return models with no nil slices; models built by hand can call
+// EnsureSlices before they are encoded or compared.
+func (d *DBInfo) EnsureSlices() {
+	d.Tables = nonNil(d.Tables)
+	d.Warnings = nonNil(d.Warnings)
+	if d.Capabilities != nil {
+		d.Capabilities.Collected = nonNil(d.Capabilities.Collected)
+		d.Capabilities.Unavailable = nonNil(d.Capabilities.Unavailable)
+	}
+	for _, table := range d.Tables {
+		table.EnsureSlices()
+	}
+	for _, view := range d.Views {
+		view.EnsureSlices()
+	}
+	for _, view := range d.MaterializedViews {
+		view.EnsureSlices()
+	}
+	for _, domain := range d.Domains {
+		domain.Checks = nonNil(domain.Checks)
+	}
+	for _, table := range d.ForeignTables {
+		table.Columns = nonNil(table.Columns)
+	}
+	for _, trigger := range d.EventTriggers {
+		trigger.Tags = nonNil(trigger.Tags)
+	}
+}
+
+// EnsureSlices replaces the nil slices of the table, and of its indexes,
+// foreign keys, relationships and partitions, with empty ones
+func (t *Table) EnsureSlices() {
+	t.Columns = nonNil(t.Columns)
+	if t.PrimaryKey != nil {
+		t.PrimaryKey.Columns = nonNil(t.PrimaryKey.Columns)
+	}
+	t.Indexes = nonNil(t.Indexes)
+	for _, index := range t.Indexes {
+		index.Columns = nonNil(index.Columns)
+		index.Keys = nonNil(index.Keys)
+	}
+	t.ForeignKeys = nonNil(t.ForeignKeys)
+	for _, fk := range t.ForeignKeys {
+		fk.ColumnNames = nonNil(fk.ColumnNames)
+		fk.RefColumnNames = nonNil(fk.RefColumnNames)
+	}
+	t.Partitions = ensurePartitions(t.Partitions)
+	t.HasMany = ensureRelationships(t.HasMany)
+	t.BelongsTo = ensureRelationships(t.BelongsTo)
+}
+
+// EnsureSlices replaces the nil slices of the view, and of its sources,
+// with empty ones
+func (v *View) EnsureSlices() {
+	v.Columns = nonNil(v.Columns)
+	v.Sources = ensureSources(v.Sources)
+}
+
+// EnsureSlices replaces the nil slices of the materialized view, and of its
+// indexes and sources, with empty ones
+func (v *MaterializedView) EnsureSlices() {
+	v.Columns = nonNil(v.Columns)
+	v.Indexes = nonNil(v.Indexes)
+	for _, index := range v.Indexes {
+		index.Columns = nonNil(index.Columns)
+		index.Keys = nonNil(index.Keys)
+	}
+	v.Sources = ensureSources(v.Sources)
+}
+
+func ensureSources(sources []*ViewSource) []*ViewSource {
+	for _, s := range sources {
+		s.Columns = nonNil(s.Columns)
+	}
+	return nonNil(sources)
+}
+
+func ensureRelationships(relationships []*Relationship) []*Relationship {
+	for _, r := range relationships {
+		r.Columns = nonNil(r.Columns)
+		r.References = nonNil(r.References)
+	}
+	return nonNil(relationships)
+}
+
+func ensurePartitions(partitions []*Partition) []*Partition {
+	for _, p := range partitions {
+		p.Partitions = ensurePartitions(p.Partitions)
+	}
+	return nonNil(partitions)
+}
+
+// nonNil returns values, or an empty slice when values is nil
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
@@ -0,0 +1,254 @@
+// Package erd renders entity relationship diagrams from a dbinfo schema as
+// Mermaid or Graphviz DOT sources.
+package erd
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Colors highlighting the workload of tables, when GetDBInfo read it
+const (
+	hotColor          = "#f4a582"
+	missingIndexColor = "#b2182b"
+)
+
+// unsafeChars matches the characters Mermaid doesn't accept in entity names
+// and attribute types
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Mermaid writes a Mermaid erDiagram for the tables in info to w
+func Mermaid(w io.Writer, info *dbinfo.DBInfo, opts ...Option) error {
+	l := newLayout(info, opts)
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+
+	writeTable := func(table *dbinfo.Table) {
+		fmt.Fprintf(&b, "    %s {\n", mermaidName(table.Schema, table.Name))
+		fks := foreignKeyColumns(table)
+		references := l.collapsedReferences(table)
+		for _, column := range table.Columns {
+			var keys []string
+			if column.IsPrimaryKey {
+				keys = append(keys, "PK")
+			}
+			if fks[column.Name] {
+				keys = append(keys, "FK")
+			}
+			line := unsafeChars.ReplaceAllString(column.Type, "_") + " " + unsafeChars.ReplaceAllString(column.Name, "_")
+			if len(keys) > 0 {
+				line += " " + strings.Join(keys, ",")
+			}
+			comment := column.Comment
+			if lookup := references[column.Name]; lookup != "" {
+				comment = strings.TrimSpace(comment + " (references " + lookup + ")")
+			}
+			if comment != "" {
+				line += " " + mermaidString(comment)
+			}
+			fmt.Fprintf(&b, "        %s\n", line)
+		}
+		b.WriteString("    }\n")
+	}
+	for _, c := range l.clusters {
+		fmt.Fprintf(&b, "    %%%% %s\n", strings.ReplaceAll(c.Name, "\n", " "))
+		for _, table := range c.Tables {
+			writeTable(table)
+		}
+	}
+	if len(l.clusters) > 0 && len(l.unclustered) > 0 {
+		b.WriteString("    %% other tables\n")
+	}
+	for _, table := range l.unclustered {
+		writeTable(table)
+	}
+
+	for _, table := range l.tables() {
+		columns := nullableColumns(table)
+		for _, fk := range table.ForeignKeys {
+			if l.lookup(fk) != nil {
+				continue
+			}
+			// The parent side is optional when any of the local columns is nullable
+			parent := "||"
+			for _, name := range fk.ColumnNames {
+				if columns[name] {
+					parent = "|o"
+				}
+			}
+			fmt.Fprintf(&b, "    %s %s--o{ %s : %s\n",
+				mermaidName(fk.RefTableSchema, fk.RefTableName),
+				parent,
+				mermaidName(table.Schema, table.Name),
+				mermaidString(fk.Name),
+			)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DOT writes a Graphviz digraph for the tables in info to w. Each table is a
+// node with one row per column and each foreign key an edge from the
+// referencing table to the referenced one. Hot tables have an orange header,
+// and tables likely missing an index a red note under it (see
+// dbinfo.WithWorkload).
+func DOT(w io.Writer, info *dbinfo.DBInfo, opts ...Option) error {
+	l := newLayout(info, opts)
+	var b strings.Builder
+	b.WriteString("digraph dbinfo {\n")
+	fmt.Fprintf(&b, "    rankdir=%s;\n", l.direction)
+	b.WriteString("    node [shape=plaintext, fontname=\"Helvetica\"];\n")
+	b.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n")
+
+	writeTable := func(table *dbinfo.Table, indent string) {
+		fks := foreignKeyColumns(table)
+		references := l.collapsedReferences(table)
+		fmt.Fprintf(&b, "%s%s [label=<\n", indent, dotID(table.Schema, table.Name))
+		fmt.Fprintf(&b, "%s    <table border=\"0\" cellborder=\"1\" cellspacing=\"0\">\n", indent)
+		header := "lightgrey"
+		if table.Workload != nil && table.Workload.Hot {
+			header = hotColor
+		}
+		fmt.Fprintf(&b, "%s    <tr><td bgcolor=%q><b>%s</b></td></tr>\n", indent, header, html.EscapeString(tableTitle(table)))
+		if table.Workload != nil && table.Workload.MissingIndex {
+			fmt.Fprintf(&b, "%s    <tr><td align=\"left\"><font color=%q>%s</font></td></tr>\n", indent, missingIndexColor, html.EscapeString(missingIndexNote(table.Workload)))
+		}
+		for _, column := range table.Columns {
+			name := html.EscapeString(column.Name)
+			if column.IsPrimaryKey {
+				name = "<u>" + name + "</u>"
+			}
+			if fks[column.Name] {
+				name = "<i>" + name + "</i>"
+			}
+			typ := html.EscapeString(column.Type)
+			if lookup := references[column.Name]; lookup != "" {
+				typ += " → " + html.EscapeString(lookup)
+			}
+			fmt.Fprintf(&b, "%s    <tr><td align=\"left\" port=%q>%s: %s</td></tr>\n", indent, column.Name, name, typ)
+		}
+		fmt.Fprintf(&b, "%s    </table>\n", indent)
+		fmt.Fprintf(&b, "%s>];\n", indent)
+	}
+	for i, c := range l.clusters {
+		fmt.Fprintf(&b, "    subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&b, "        label=%q;\n", c.Name)
+		b.WriteString("        style=rounded;\n")
+		b.WriteString("        color=grey;\n")
+		for _, table := range c.Tables {
+			writeTable(table, "        ")
+		}
+		b.WriteString("    }\n")
+	}
+	for _, table := range l.unclustered {
+		writeTable(table, "    ")
+	}
+
+	for _, table := range l.tables() {
+		for _, fk := range table.ForeignKeys {
+			if l.lookup(fk) != nil {
+				continue
+			}
+			fmt.Fprintf(&b, "    %s -> %s [label=%q];\n",
+				dotID(table.Schema, table.Name),
+				dotID(fk.RefTableSchema, fk.RefTableName),
+				fk.Name,
+			)
+		}
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidName returns the entity name used for a table. Tables in the public
+// schema use their plain name.
+func mermaidName(schema, name string) string {
+	if schema != "public" && schema != "" {
+		name = schema + "__" + name
+	}
+	return unsafeChars.ReplaceAllString(name, "_")
+}
+
+func mermaidString(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return `"` + s + `"`
+}
+
+func dotID(schema, name string) string {
+	return fmt.Sprintf("%q", schema+"."+name)
+}
+
+// foreignKeyColumns returns the set of columns used by the table's foreign keys
+func foreignKeyColumns(table *dbinfo.Table) map[string]bool {
+	columns := make(map[string]bool)
+	for _, fk := range table.ForeignKeys {
+		for _, name := range fk.ColumnNames {
+			columns[name] = true
+		}
+	}
+	return columns
+}
+
+// nullableColumns returns the set of nullable columns of the table
+func nullableColumns(table *dbinfo.Table) map[string]bool {
+	columns := make(map[string]bool)
+	for _, column := range table.Columns {
+		if column.IsNullable {
+			columns[column.Name] = true
+		}
+	}
+	return columns
+}
+
+// tableTitle returns the header of the box of a table: its qualified name,
+// with the number of partitions rolled up into it
+func tableTitle(table *dbinfo.Table) string {
+	title := table.Schema + "." + table.Name
+	var count func([]*dbinfo.Partition) int
+	count = func(partitions []*dbinfo.Partition) int {
+		n := len(partitions)
+		for _, p := range partitions {
+			n += count(p.Partitions)
+		}
+		return n
+	}
+	switch n := count(table.Partitions); n {
+	case 0:
+	case 1:
+		title += " (1 partition)"
+	default:
+		title += fmt.Sprintf(" (%d partitions)", n)
+	}
+	return title
+}
+
+// workloadNote summarizes the workload of a table, empty when it wasn't read
+func workloadNote(w *dbinfo.Workload) string {
+	if w == nil {
+		return ""
+	}
+	note := fmt.Sprintf("%d reads, %d writes", w.Reads(), w.Writes())
+	if w.Hot {
+		note = "hot table: " + note
+	}
+	if w.MissingIndex {
+		note += "; " + missingIndexNote(w)
+	}
+	return note
+}
+
+// missingIndexNote describes a table likely missing an index
+func missingIndexNote(w *dbinfo.Workload) string {
+	return fmt.Sprintf("likely missing an index: %.0f%% sequential scans", w.SeqScanRatio*100)
+}
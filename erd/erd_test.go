@@ -0,0 +1,339 @@
+package erd
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:   "customers",
+				Schema: "billing",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "email", Type: "character varying", Comment: `The "main" email`},
+				},
+			},
+			{
+				Name:   "orders",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer", IsNullable: true},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{
+						Name:           "orders_customer_id_fkey",
+						ColumnNames:    []string{"customer_id"},
+						RefTableSchema: "billing",
+						RefTableName:   "customers",
+						RefColumnNames: []string{"id"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Mermaid(&buf, testInfo()); err != nil {
+		t.Fatalf("Failed to render Mermaid: %v", err)
+	}
+
+	expected := `erDiagram
+    billing__customers {
+        integer id PK
+        character_varying email "The 'main' email"
+    }
+    orders {
+        integer id PK
+        integer customer_id FK
+    }
+    billing__customers |o--o{ orders : "orders_customer_id_fkey"
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected Mermaid output (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := DOT(&buf, testInfo()); err != nil {
+		t.Fatalf("Failed to render DOT: %v", err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{
+		"digraph dbinfo {",
+		`"billing.customers" [label=<`,
+		`<tr><td align="left" port="id"><u>id</u>: integer</td></tr>`,
+		`<tr><td align="left" port="customer_id"><i>customer_id</i>: integer</td></tr>`,
+		`"public.orders" -> "billing.customers" [label="orders_customer_id_fkey"];`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+// landscapeInfo has two groups of tables sharing a lookup table, and a
+// table linked to nothing
+func landscapeInfo() *dbinfo.DBInfo {
+	fk := func(name, schema, table string) *dbinfo.ForeignKey {
+		return &dbinfo.ForeignKey{Name: name, ColumnNames: []string{table + "_id"}, RefTableSchema: schema, RefTableName: table, RefColumnNames: []string{"id"}}
+	}
+	id := &dbinfo.Column{Name: "id", Type: "integer", IsPrimaryKey: true}
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{Schema: "public", Name: "statuses", Columns: []*dbinfo.Column{id, {Name: "label", Type: "text"}}},
+			{Schema: "public", Name: "customers", Columns: []*dbinfo.Column{id, {Name: "email", Type: "text"}, {Name: "name", Type: "text"}, {Name: "phone", Type: "text"}, {Name: "created_at", Type: "timestamp"}}},
+			{Schema: "public", Name: "orders", Columns: []*dbinfo.Column{id, {Name: "customers_id", Type: "integer"}, {Name: "statuses_id", Type: "integer"}},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("orders_customer_fkey", "public", "customers"), fk("orders_status_fkey", "public", "statuses")}},
+			{Schema: "billing", Name: "invoices", Columns: []*dbinfo.Column{id, {Name: "orders_id", Type: "integer"}},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("invoices_order_fkey", "public", "orders")}},
+			{Schema: "inventory", Name: "products", Columns: []*dbinfo.Column{id, {Name: "statuses_id", Type: "integer"}},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("products_status_fkey", "public", "statuses")}},
+			{Schema: "inventory", Name: "stock", Columns: []*dbinfo.Column{id, {Name: "products_id", Type: "integer"}},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("stock_product_fkey", "inventory", "products")}},
+			{Schema: "public", Name: "settings", Columns: []*dbinfo.Column{{Name: "key", Type: "text"}, {Name: "value", Type: "text"}}},
+		},
+	}
+}
+
+// clusterNames returns the clusters of a layout as name: schema.table lists
+func clusterNames(l *layout) []string {
+	var names []string
+	for _, c := range l.clusters {
+		var tables []string
+		for _, table := range c.Tables {
+			tables = append(tables, table.Schema+"."+table.Name)
+		}
+		names = append(names, c.Name+": "+strings.Join(tables, " "))
+	}
+	var other []string
+	for _, table := range l.unclustered {
+		other = append(other, table.Schema+"."+table.Name)
+	}
+	return append(names, "other: "+strings.Join(other, " "))
+}
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []string
+	}{
+		{"no clusters", nil, []string{
+			"other: public.statuses public.customers public.orders billing.invoices inventory.products inventory.stock public.settings",
+		}},
+		{"schemas", []Option{WithClusters(BySchema)}, []string{
+			"public: public.statuses public.customers public.orders public.settings",
+			"billing: billing.invoices",
+			"inventory: inventory.products inventory.stock",
+			"other: ",
+		}},
+		{"tags", []Option{WithClusters(ByTag), WithTag("sales", "orders", "billing.*"), WithTag("catalog", "inventory.*", "orders")}, []string{
+			"sales: public.orders billing.invoices",
+			"catalog: inventory.products inventory.stock",
+			"other: public.statuses public.customers public.settings",
+		}},
+		{"components", []Option{WithClusters(ByComponent)}, []string{
+			"public.orders: public.statuses public.customers public.orders billing.invoices inventory.products inventory.stock",
+			"other: public.settings",
+		}},
+		{"components without lookups", []Option{WithClusters(ByComponent), WithCollapsedLookups()}, []string{
+			"public.orders: public.customers public.orders billing.invoices",
+			"inventory.products: inventory.products inventory.stock",
+			"other: public.settings",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, clusterNames(newLayout(landscapeInfo(), test.opts))); diff != "" {
+				t.Errorf("Unexpected clusters (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLayoutDottedNames(t *testing.T) {
+	// "a.b"."c" and "a"."b.c" would both be a.b.c as strings
+	lookup := func(schema, name string) *dbinfo.Table {
+		return &dbinfo.Table{Schema: schema, Name: name, Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}}}
+	}
+	info := &dbinfo.DBInfo{Tables: []*dbinfo.Table{
+		lookup("a.b", "c"),
+		lookup("a", "b.c"),
+		{Schema: "a", Name: "items", Columns: []*dbinfo.Column{{Name: "c_id", Type: "integer"}}, ForeignKeys: []*dbinfo.ForeignKey{
+			{Name: "items_c_id_fkey", ColumnNames: []string{"c_id"}, RefTableSchema: "a.b", RefTableName: "c", RefColumnNames: []string{"id"}},
+		}},
+	}}
+
+	// Only the referenced table is collapsed
+	l := newLayout(info, []Option{WithCollapsedLookups()})
+	if len(l.unclustered) != 2 || l.unclustered[0] != info.Tables[1] || len(l.lookups) != 1 {
+		t.Errorf("Expected a.\"b.c\" to be drawn and \"a.b\".c collapsed, got %v", clusterNames(l))
+	}
+}
+
+func TestClusteredDiagrams(t *testing.T) {
+	opts := []Option{WithClusters(BySchema), WithCollapsedLookups(), WithDirection("TB")}
+
+	var buf bytes.Buffer
+	if err := DOT(&buf, landscapeInfo(), opts...); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"rankdir=TB;",
+		"    subgraph cluster_1 {\n        label=\"billing\";\n        style=rounded;\n        color=grey;\n        \"billing.invoices\" [label=<\n",
+		`<tr><td align="left" port="statuses_id"><i>statuses_id</i>: integer → public.statuses</td></tr>`,
+		`"public.orders" -> "public.customers" [label="orders_customer_fkey"];`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, `"public.statuses"`) {
+		t.Errorf("Expected the lookup table to be collapsed, got:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := Mermaid(&buf, landscapeInfo(), opts...); err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	for _, expected := range []string{
+		"erDiagram\n    %% public\n    customers {\n",
+		"    %% billing\n    billing__invoices {\n",
+		`integer statuses_id FK "(references public.statuses)"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected Mermaid output to contain %q, got:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "statuses {") || strings.Contains(out, "orders_status_fkey") {
+		t.Errorf("Expected the lookup table to be collapsed, got:\n%s", out)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := SVG(&buf, testInfo()); err != nil {
+		t.Fatalf("Failed to render SVG: %v", err)
+	}
+
+	out := buf.String()
+	for _, expected := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="469" height="100" viewBox="0 0 469 100">`,
+		`<g class="table" id="public.orders">`,
+		`<rect x="20" y="20" width="160" height="60"/>`,
+		`<text x="28" y="57"><tspan class="pk">id</tspan><tspan class="type">: integer</tspan></text>`,
+		`<text x="28" y="75"><tspan class="fk">customer_id</tspan><tspan class="type">: integer</tspan></text>`,
+		"<title>orders_customer_id_fkey</title>\n    <path d=\"M 180 71 C 220 71, 220 53, 260 53\" marker-end=\"url(#arrow)\"/>",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected SVG output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestWorkloadHighlights(t *testing.T) {
+	info := testInfo()
+	info.Tables[0].Workload = &dbinfo.Workload{SeqScans: 900, IndexScans: 100, SeqScanRatio: 0.9, Hot: true, MissingIndex: true}
+	info.Tables[1].Workload = &dbinfo.Workload{IndexScans: 50, RowsInserted: 10}
+
+	var buf bytes.Buffer
+	if err := DOT(&buf, info); err != nil {
+		t.Fatalf("Failed to render DOT: %v", err)
+	}
+	for _, expected := range []string{
+		`<tr><td bgcolor="#f4a582"><b>billing.customers</b></td></tr>`,
+		`<tr><td align="left"><font color="#b2182b">likely missing an index: 90% sequential scans</font></td></tr>`,
+		`<tr><td bgcolor="lightgrey"><b>public.orders</b></td></tr>`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := SVG(&buf, info); err != nil {
+		t.Fatalf("Failed to render SVG: %v", err)
+	}
+	for _, expected := range []string{
+		"<g class=\"table hot missing-index\" id=\"billing.customers\">\n    <title>hot table: 1000 reads, 0 writes; likely missing an index: 90% sequential scans</title>",
+		"<g class=\"table\" id=\"public.orders\">\n    <title>50 reads, 10 writes</title>",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected SVG output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestPartitionCount(t *testing.T) {
+	info := testInfo()
+	info.Tables[1].Partitions = []*dbinfo.Partition{
+		{Schema: "public", Name: "orders_2024", Partitions: []*dbinfo.Partition{{Schema: "public", Name: "orders_2024_01"}}},
+	}
+
+	var buf bytes.Buffer
+	if err := DOT(&buf, info); err != nil {
+		t.Fatalf("Failed to render DOT: %v", err)
+	}
+	if expected := `<tr><td bgcolor="lightgrey"><b>public.orders (2 partitions)</b></td></tr>`; !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected DOT output to contain %q, got:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := SVG(&buf, info); err != nil {
+		t.Fatalf("Failed to render SVG: %v", err)
+	}
+	if expected := ">public.orders (2 partitions)</text>"; !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected SVG output to contain %q, got:\n%s", expected, buf.String())
+	}
+}
+
+func TestSVGLayout(t *testing.T) {
+	for _, direction := range []string{"LR", "RL", "TB", "BT"} {
+		t.Run(direction, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := SVG(&buf, landscapeInfo(), WithDirection(direction)); err != nil {
+				t.Fatal(err)
+			}
+			// Each table references the next one, so they follow the direction
+			var positions []float64
+			for _, id := range []string{"billing.invoices", "public.orders", "public.customers"} {
+				var x, y float64
+				_, rest, _ := strings.Cut(buf.String(), `<g class="table" id="`+id+"\">\n    <rect ")
+				if _, err := fmt.Sscanf(rest, `x="%g" y="%g"`, &x, &y); err != nil {
+					t.Fatalf("Failed to find %s: %v", id, err)
+				}
+				switch direction {
+				case "LR":
+					positions = append(positions, x)
+				case "RL":
+					positions = append(positions, -x)
+				case "TB":
+					positions = append(positions, y)
+				case "BT":
+					positions = append(positions, -y)
+				}
+			}
+			if !slices.IsSorted(positions) {
+				t.Errorf("Expected tables to follow the %s direction, got positions %v", direction, positions)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package erd
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Clustering selects how diagrams group tables
+type Clustering string
+
+// Clusterings
+const (
+	NoClusters  Clustering = ""
+	BySchema    Clustering = "schema"    // A cluster per schema
+	ByTag       Clustering = "tag"       // A cluster per tag given with WithTag
+	ByComponent Clustering = "component" // A cluster per group of tables linked by foreign keys
+)
+
+// maxLookupColumns is the largest number of columns of a lookup table
+const maxLookupColumns = 4
+
+// Option configures a diagram
+type Option func(*options)
+
+type options struct {
+	clustering      Clustering
+	tags            []tag
+	collapseLookups bool
+	direction       string
+}
+
+type tag struct {
+	name     string
+	patterns []string
+}
+
+// WithClusters groups the tables of the diagram. DOT draws each group as a
+// cluster. Mermaid ER diagrams have no clusters, so Mermaid only writes the
+// tables of a group together, under a comment naming it.
+func WithClusters(by Clustering) Option {
+	return func(o *options) {
+		o.clustering = by
+	}
+}
+
+// WithTag tags the tables matching any of the glob patterns, which follow
+// the syntax of dbinfo.WithTables. Clustering by tag puts each table in the
+// cluster of its first tag; tables without tags stay outside clusters.
+func WithTag(name string, patterns ...string) Option {
+	return func(o *options) {
+		o.tags = append(o.tags, tag{name: name, patterns: patterns})
+	}
+}
+
+// WithCollapsedLookups leaves lookup tables, such as statuses or countries,
+// out of the diagram. A lookup table has at most four columns, a single
+// column primary key and no foreign keys, and other tables reference it.
+// The referencing columns name the lookup table instead of an edge.
+func WithCollapsedLookups() Option {
+	return func(o *options) {
+		o.collapseLookups = true
+	}
+}
+
+// WithDirection sets the direction DOT and SVG lay the diagram out in: LR
+// (the default), RL, TB or BT. Mermaid ignores it.
+func WithDirection(direction string) Option {
+	return func(o *options) {
+		o.direction = direction
+	}
+}
+
+// Cluster is a group of tables drawn together
+type Cluster struct {
+	Name   string
+	Tables []*dbinfo.Table
+}
+
+// layout holds what a diagram draws once options are applied
+type layout struct {
+	direction   string
+	clusters    []*Cluster
+	unclustered []*dbinfo.Table                    // Tables drawn outside clusters
+	lookups     map[dbinfo.ObjectKey]*dbinfo.Table // Collapsed lookup tables
+}
+
+// newLayout applies opts to the tables of info
+func newLayout(info *dbinfo.DBInfo, opts []Option) *layout {
+	o := &options{direction: "LR"}
+	for _, opt := range opts {
+		opt(o)
+	}
+	l := &layout{direction: o.direction, lookups: make(map[dbinfo.ObjectKey]*dbinfo.Table)}
+
+	tables := info.Tables
+	if o.collapseLookups {
+		referenced := make(map[dbinfo.ObjectKey]bool)
+		for _, table := range info.Tables {
+			for _, fk := range table.ForeignKeys {
+				if fk.RefTableSchema != table.Schema || fk.RefTableName != table.Name {
+					referenced[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}] = true
+				}
+			}
+		}
+		tables = nil
+		for _, table := range info.Tables {
+			if referenced[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}] && isLookup(table) {
+				l.lookups[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}] = table
+			} else {
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	switch o.clustering {
+	case BySchema:
+		l.group(tables, func(table *dbinfo.Table) string { return table.Schema })
+	case ByTag:
+		l.group(tables, func(table *dbinfo.Table) string {
+			for _, t := range o.tags {
+				if matchTable(t.patterns, table.Schema, table.Name) {
+					return t.name
+				}
+			}
+			return ""
+		})
+		// Clusters follow the order of the tags
+		order := make(map[string]int, len(o.tags))
+		for i, t := range o.tags {
+			if _, ok := order[t.name]; !ok {
+				order[t.name] = i
+			}
+		}
+		sort.SliceStable(l.clusters, func(i, j int) bool {
+			return order[l.clusters[i].Name] < order[l.clusters[j].Name]
+		})
+	case ByComponent:
+		l.components(tables)
+	default:
+		l.unclustered = tables
+	}
+	return l
+}
+
+// group puts tables in the cluster named by name, in order of appearance.
+// Tables with an empty name stay outside clusters.
+func (l *layout) group(tables []*dbinfo.Table, name func(*dbinfo.Table) string) {
+	byName := make(map[string]*Cluster)
+	for _, table := range tables {
+		n := name(table)
+		if n == "" {
+			l.unclustered = append(l.unclustered, table)
+			continue
+		}
+		if byName[n] == nil {
+			byName[n] = &Cluster{Name: n}
+			l.clusters = append(l.clusters, byName[n])
+		}
+		byName[n].Tables = append(byName[n].Tables, table)
+	}
+}
+
+// components puts the tables linked by foreign keys, in either direction,
+// in a cluster named after its most connected table. Isolated tables stay
+// outside clusters. Larger clusters come first.
+func (l *layout) components(tables []*dbinfo.Table) {
+	index := make(map[dbinfo.ObjectKey]int, len(tables))
+	for i, table := range tables {
+		index[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}] = i
+	}
+	parent := make([]int, len(tables))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	degree := make([]int, len(tables))
+	for i, table := range tables {
+		for _, fk := range table.ForeignKeys {
+			j, ok := index[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}]
+			if !ok || i == j {
+				continue
+			}
+			degree[i]++
+			degree[j]++
+			parent[find(i)] = find(j)
+		}
+	}
+
+	byRoot := make(map[int]*Cluster)
+	hubs := make(map[*Cluster]int)
+	for i, table := range tables {
+		root := find(i)
+		if degree[i] == 0 {
+			l.unclustered = append(l.unclustered, table)
+			continue
+		}
+		c := byRoot[root]
+		if c == nil {
+			c = &Cluster{}
+			byRoot[root] = c
+			hubs[c] = i
+			l.clusters = append(l.clusters, c)
+		}
+		c.Tables = append(c.Tables, table)
+		if degree[i] > degree[hubs[c]] {
+			hubs[c] = i
+		}
+	}
+	for c, hub := range hubs {
+		c.Name = tables[hub].Schema + "." + tables[hub].Name
+	}
+	sort.SliceStable(l.clusters, func(i, j int) bool {
+		return len(l.clusters[i].Tables) > len(l.clusters[j].Tables)
+	})
+}
+
+// tables returns the tables drawn, clustered ones first
+func (l *layout) tables() []*dbinfo.Table {
+	var tables []*dbinfo.Table
+	for _, c := range l.clusters {
+		tables = append(tables, c.Tables...)
+	}
+	return append(tables, l.unclustered...)
+}
+
+// lookup returns the collapsed lookup table a foreign key references, or nil
+func (l *layout) lookup(fk *dbinfo.ForeignKey) *dbinfo.Table {
+	return l.lookups[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}]
+}
+
+// collapsedReferences returns the lookup table referenced by each column of
+// table, for foreign keys to collapsed lookup tables
+func (l *layout) collapsedReferences(table *dbinfo.Table) map[string]string {
+	references := make(map[string]string)
+	for _, fk := range table.ForeignKeys {
+		if lookup := l.lookup(fk); lookup != nil {
+			for _, name := range fk.ColumnNames {
+				references[name] = lookup.Schema + "." + lookup.Name
+			}
+		}
+	}
+	return references
+}
+
+// isLookup reports whether a table has the shape of a lookup table
+func isLookup(table *dbinfo.Table) bool {
+	if len(table.ForeignKeys) > 0 || len(table.Columns) > maxLookupColumns {
+		return false
+	}
+	keys := 0
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey {
+			keys++
+		}
+	}
+	return keys == 1
+}
+
+// matchTable reports whether any of the patterns matches the table, as
+// dbinfo.WithTables matches them
+func matchTable(patterns []string, schema, name string) bool {
+	for _, pattern := range patterns {
+		subject := name
+		if strings.Contains(pattern, ".") {
+			subject = schema + "." + name
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,470 @@
+package erd
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Sizes of SVG diagrams, in pixels. Text uses a monospace font so widths
+// can be computed from the number of characters.
+const (
+	svgFontSize     = 12
+	svgCharWidth    = 7.2 // Advance of a character at svgFontSize
+	svgRowHeight    = 18
+	svgHeaderHeight = 24
+	svgTextPadding  = 8  // Between the text and the sides of a table
+	svgLayerGap     = 80 // Between layers, along the direction of the diagram
+	svgNodeGap      = 24 // Between the tables of a layer
+	svgClusterPad   = 16 // Between a cluster frame and its tables
+	svgClusterLabel = 20 // Height of the cluster labels, above their tables
+	svgGroupGap     = 32 // Between clusters
+	svgMargin       = 20
+	svgLoopWidth    = 30 // Of the loops drawn for self references
+)
+
+// orderingSweeps is the number of passes ordering the tables of each layer
+// next to the tables they are linked to
+const orderingSweeps = 4
+
+// svgNode is a table placed in an SVG diagram
+type svgNode struct {
+	table      *dbinfo.Table
+	x, y, w, h float64
+	layer      int
+	position   float64 // In its layer, while ordering
+}
+
+// rowY returns the vertical center of the row of column, or of the header
+// when the table has no such column
+func (n *svgNode) rowY(column string) float64 {
+	for i, c := range n.table.Columns {
+		if c.Name == column {
+			return n.y + svgHeaderHeight + float64(i)*svgRowHeight + svgRowHeight/2
+		}
+	}
+	return n.y + svgHeaderHeight/2
+}
+
+// svgGroup is a cluster, or the tables drawn outside clusters
+type svgGroup struct {
+	name       string // Empty for tables outside clusters
+	nodes      []*svgNode
+	x, y, w, h float64
+}
+
+// SVG writes the diagram of the tables in info to w as a standalone SVG
+// image, laid out without Graphviz. Tables are arranged in layers so that
+// foreign keys point in the direction of WithDirection, and clusters are
+// framed and stacked across it.
+func SVG(w io.Writer, info *dbinfo.DBInfo, opts ...Option) error {
+	l := newLayout(info, opts)
+	horizontal := l.direction == "LR" || l.direction == "RL"
+
+	nodes := make(map[dbinfo.ObjectKey]*svgNode)
+	newGroup := func(name string, tables []*dbinfo.Table) *svgGroup {
+		g := &svgGroup{name: name}
+		for _, table := range tables {
+			n := &svgNode{table: table}
+			n.w, n.h = nodeSize(table, l.collapsedReferences(table))
+			nodes[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}] = n
+			g.nodes = append(g.nodes, n)
+		}
+		return g
+	}
+	var groups []*svgGroup
+	for _, c := range l.clusters {
+		groups = append(groups, newGroup(c.Name, c.Tables))
+	}
+	if len(l.unclustered) > 0 {
+		groups = append(groups, newGroup("", l.unclustered))
+	}
+
+	// Lay out each group on its own, then stack them across the direction
+	var width, height float64
+	for _, g := range groups {
+		g.w, g.h = arrange(g.nodes, l, nodes, horizontal)
+		inset := 0.0
+		if g.name != "" {
+			inset = svgClusterPad
+			g.w += 2 * svgClusterPad
+			g.h += 2*svgClusterPad + svgClusterLabel
+		}
+		if horizontal {
+			g.x, g.y = svgMargin, svgMargin+height
+			if height > 0 {
+				g.y += svgGroupGap
+			}
+			height = g.y - svgMargin + g.h
+			width = max(width, g.w)
+		} else {
+			g.x, g.y = svgMargin+width, svgMargin
+			if width > 0 {
+				g.x += svgGroupGap
+			}
+			width = g.x - svgMargin + g.w
+			height = max(height, g.h)
+		}
+		labelHeight := 0.0
+		if g.name != "" {
+			labelHeight = svgClusterLabel
+		}
+		for _, n := range g.nodes {
+			n.x += g.x + inset
+			n.y += g.y + inset + labelHeight
+		}
+	}
+	width, height = width+2*svgMargin, height+2*svgMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %[1]s %[2]s\">\n", num(width), num(height))
+	b.WriteString("  <style>\n")
+	fmt.Fprintf(&b, "    text { font-family: Menlo, Consolas, \"DejaVu Sans Mono\", monospace; font-size: %dpx; fill: #222; }\n", svgFontSize)
+	b.WriteString("    .table rect { fill: #fff; stroke: #555; }\n")
+	b.WriteString("    .table .header { fill: #ddd; }\n")
+	b.WriteString("    .table .name { font-weight: bold; }\n")
+	fmt.Fprintf(&b, "    .hot .header { fill: %s; }\n", hotColor)
+	fmt.Fprintf(&b, "    .missing-index > rect:first-of-type { stroke: %s; stroke-width: 2; }\n", missingIndexColor)
+	b.WriteString("    .pk { text-decoration: underline; }\n")
+	b.WriteString("    .fk { font-style: italic; }\n")
+	b.WriteString("    .type { fill: #666; }\n")
+	b.WriteString("    .cluster rect { fill: #f7f7f7; stroke: #aaa; }\n")
+	b.WriteString("    .edge path { fill: none; stroke: #777; }\n")
+	b.WriteString("  </style>\n")
+	b.WriteString("  <defs>\n")
+	b.WriteString("    <marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"8\" markerHeight=\"8\" orient=\"auto-start-reverse\">\n")
+	b.WriteString("      <path d=\"M 0 0 L 10 5 L 0 10 z\" fill=\"#777\"/>\n")
+	b.WriteString("    </marker>\n")
+	b.WriteString("  </defs>\n")
+
+	for _, g := range groups {
+		if g.name == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "  <g class=\"cluster\">\n")
+		fmt.Fprintf(&b, "    <rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" rx=\"6\"/>\n", num(g.x), num(g.y), num(g.w), num(g.h))
+		fmt.Fprintf(&b, "    <text x=\"%s\" y=\"%s\">%s</text>\n", num(g.x+svgClusterPad), num(g.y+svgClusterPad+svgFontSize), html.EscapeString(g.name))
+		b.WriteString("  </g>\n")
+	}
+
+	for _, table := range l.tables() {
+		from := nodes[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}]
+		for _, fk := range table.ForeignKeys {
+			to := nodes[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}]
+			if to == nil || l.lookup(fk) != nil {
+				continue
+			}
+			var refColumn string
+			if len(fk.RefColumnNames) > 0 {
+				refColumn = fk.RefColumnNames[0]
+			}
+			b.WriteString("  <g class=\"edge\">\n")
+			fmt.Fprintf(&b, "    <title>%s</title>\n", html.EscapeString(fk.Name))
+			fmt.Fprintf(&b, "    <path d=\"%s\" marker-end=\"url(#arrow)\"/>\n", edgePath(from, to, fk.ColumnNames[0], refColumn))
+			b.WriteString("  </g>\n")
+		}
+	}
+
+	for _, n := range sortedNodes(groups) {
+		writeSVGTable(&b, n, foreignKeyColumns(n.table), l.collapsedReferences(n.table))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedNodes returns the nodes of the groups in the order they are drawn
+func sortedNodes(groups []*svgGroup) []*svgNode {
+	var nodes []*svgNode
+	for _, g := range groups {
+		nodes = append(nodes, g.nodes...)
+	}
+	return nodes
+}
+
+// nodeSize returns the size of the box of a table
+func nodeSize(table *dbinfo.Table, references map[string]string) (float64, float64) {
+	chars := utf8.RuneCountInString(tableTitle(table))
+	for _, column := range table.Columns {
+		chars = max(chars, utf8.RuneCountInString(columnLabel(column, references)))
+	}
+	w := math.Ceil(float64(chars)*svgCharWidth) + 2*svgTextPadding
+	h := float64(svgHeaderHeight + len(table.Columns)*svgRowHeight)
+	return w, h
+}
+
+// columnLabel returns the text of the row of a column
+func columnLabel(column *dbinfo.Column, references map[string]string) string {
+	label := column.Name + ": " + column.Type
+	if lookup := references[column.Name]; lookup != "" {
+		label += " → " + lookup
+	}
+	return label
+}
+
+// arrange places nodes in layers, the tables a foreign key references in a
+// later layer than the referencing table, and returns the size they take.
+// Positions are relative to the top left corner of the group.
+func arrange(group []*svgNode, l *layout, nodes map[dbinfo.ObjectKey]*svgNode, horizontal bool) (float64, float64) {
+	if len(group) == 0 {
+		return 0, 0
+	}
+	in := make(map[*svgNode]bool, len(group))
+	for _, n := range group {
+		in[n] = true
+	}
+	// Edges of the group, from the referencing to the referenced table
+	targets := make(map[*svgNode][]*svgNode)
+	for _, n := range group {
+		for _, fk := range n.table.ForeignKeys {
+			to := nodes[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}]
+			if to != nil && to != n && in[to] && l.lookup(fk) == nil && !slices.Contains(targets[n], to) {
+				targets[n] = append(targets[n], to)
+			}
+		}
+	}
+
+	// Order the nodes topologically, ignoring the edges closing cycles
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*svgNode]int, len(group))
+	forward := make(map[*svgNode][]*svgNode)
+	var postorder []*svgNode
+	var visit func(n *svgNode)
+	visit = func(n *svgNode) {
+		state[n] = visiting
+		for _, to := range targets[n] {
+			switch state[to] {
+			case unvisited:
+				visit(to)
+				forward[n] = append(forward[n], to)
+			case done:
+				forward[n] = append(forward[n], to)
+			}
+		}
+		state[n] = done
+		postorder = append(postorder, n)
+	}
+	for _, n := range group {
+		if state[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	// Longest path layering
+	layers := 0
+	for i := len(postorder) - 1; i >= 0; i-- {
+		n := postorder[i]
+		for _, to := range forward[n] {
+			to.layer = max(to.layer, n.layer+1)
+		}
+		layers = max(layers, n.layer+1)
+	}
+	byLayer := make([][]*svgNode, layers)
+	for _, n := range group {
+		byLayer[n.layer] = append(byLayer[n.layer], n)
+	}
+
+	// Order the tables of each layer by the mean position of the tables
+	// they are linked to in the previous layers, then in the next ones
+	neighbors := make(map[*svgNode][]*svgNode)
+	for n, tos := range forward {
+		for _, to := range tos {
+			neighbors[n] = append(neighbors[n], to)
+			neighbors[to] = append(neighbors[to], n)
+		}
+	}
+	reindex := func(layer []*svgNode) {
+		for i, n := range layer {
+			n.position = float64(i)
+		}
+	}
+	for _, layer := range byLayer {
+		reindex(layer)
+	}
+	sweep := func(layer []*svgNode, before bool) {
+		barycenters := make(map[*svgNode]float64, len(layer))
+		for _, n := range layer {
+			sum, count := 0.0, 0
+			for _, m := range neighbors[n] {
+				if (m.layer < n.layer) == before {
+					sum += m.position
+					count++
+				}
+			}
+			barycenters[n] = n.position
+			if count > 0 {
+				barycenters[n] = sum / float64(count)
+			}
+		}
+		slices.SortStableFunc(layer, func(a, b *svgNode) int {
+			return cmpFloat(barycenters[a], barycenters[b])
+		})
+		reindex(layer)
+	}
+	for range orderingSweeps {
+		for i := 1; i < layers; i++ {
+			sweep(byLayer[i], true)
+		}
+		for i := layers - 2; i >= 0; i-- {
+			sweep(byLayer[i], false)
+		}
+	}
+
+	// Place the layers along the direction and center them across it
+	length := func(n *svgNode) float64 { return n.h }
+	thickness := func(n *svgNode) float64 { return n.w }
+	if !horizontal {
+		length, thickness = thickness, length
+	}
+	var cross float64
+	lengths := make([]float64, layers)
+	for i, layer := range byLayer {
+		for j, n := range layer {
+			if j > 0 {
+				lengths[i] += svgNodeGap
+			}
+			lengths[i] += length(n)
+		}
+		cross = max(cross, lengths[i])
+	}
+	var along float64
+	for i, layer := range byLayer {
+		if i > 0 {
+			along += svgLayerGap
+		}
+		offset := (cross - lengths[i]) / 2
+		var layerThickness float64
+		for _, n := range layer {
+			if horizontal {
+				n.x, n.y = along, offset
+			} else {
+				n.x, n.y = offset, along
+			}
+			offset += length(n) + svgNodeGap
+			layerThickness = max(layerThickness, thickness(n))
+		}
+		along += layerThickness
+	}
+
+	w, h := along, cross
+	if !horizontal {
+		w, h = cross, along
+	}
+	for _, n := range group {
+		switch l.direction {
+		case "RL":
+			n.x = w - n.x - n.w
+		case "BT":
+			n.y = h - n.y - n.h
+		}
+	}
+	return w, h
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// edgePath returns the SVG path of a foreign key from the row of column in
+// from to the row of refColumn in to. Edges leave and enter tables on the
+// sides facing each other, or on their top and bottom when the tables are
+// above one another.
+func edgePath(from, to *svgNode, column, refColumn string) string {
+	y1, y2 := from.rowY(column), to.rowY(refColumn)
+	if from == to {
+		x := from.x + from.w
+		return fmt.Sprintf("M %s %s C %s %s, %s %s, %s %s",
+			num(x), num(y1), num(x+svgLoopWidth), num(y1), num(x+svgLoopWidth), num(y2), num(x), num(y2))
+	}
+	horizontalCurve := func(x1, x2 float64) string {
+		bend := math.Max(math.Abs(x2-x1)/2, svgLoopWidth)
+		if x2 < x1 {
+			bend = -bend
+		}
+		return fmt.Sprintf("M %s %s C %s %s, %s %s, %s %s",
+			num(x1), num(y1), num(x1+bend), num(y1), num(x2-bend), num(y2), num(x2), num(y2))
+	}
+	switch {
+	case to.x >= from.x+from.w:
+		return horizontalCurve(from.x+from.w, to.x)
+	case to.x+to.w <= from.x:
+		return horizontalCurve(from.x, to.x+to.w)
+	}
+	x1, x2 := from.x+from.w/2, to.x+to.w/2
+	y1, y2 = from.y+from.h, to.y
+	if to.y+to.h <= from.y {
+		y1, y2 = from.y, to.y+to.h
+	}
+	bend := math.Max(math.Abs(y2-y1)/2, svgLoopWidth)
+	if y2 < y1 {
+		bend = -bend
+	}
+	return fmt.Sprintf("M %s %s C %s %s, %s %s, %s %s",
+		num(x1), num(y1), num(x1), num(y1+bend), num(x2), num(y2-bend), num(x2), num(y2))
+}
+
+// writeSVGTable writes the box of a table, a header with its name and a row
+// per column. Hot tables and tables likely missing an index get classes
+// highlighting them, and a tooltip with their workload.
+func writeSVGTable(b *strings.Builder, n *svgNode, fks map[string]bool, references map[string]string) {
+	table := n.table
+	class := "table"
+	if table.Workload != nil && table.Workload.Hot {
+		class += " hot"
+	}
+	if table.Workload != nil && table.Workload.MissingIndex {
+		class += " missing-index"
+	}
+	fmt.Fprintf(b, "  <g class=%q id=%q>\n", class, table.Schema+"."+table.Name)
+	if note := workloadNote(table.Workload); note != "" {
+		fmt.Fprintf(b, "    <title>%s</title>\n", html.EscapeString(note))
+	}
+	fmt.Fprintf(b, "    <rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\"/>\n", num(n.x), num(n.y), num(n.w), num(n.h))
+	fmt.Fprintf(b, "    <rect class=\"header\" x=\"%s\" y=\"%s\" width=\"%s\" height=\"%d\"/>\n", num(n.x), num(n.y), num(n.w), svgHeaderHeight)
+	fmt.Fprintf(b, "    <text class=\"name\" x=\"%s\" y=\"%s\">%s</text>\n",
+		num(n.x+svgTextPadding), num(n.y+svgHeaderHeight/2+svgFontSize/3), html.EscapeString(tableTitle(table)))
+	for i, column := range table.Columns {
+		var classes []string
+		if column.IsPrimaryKey {
+			classes = append(classes, "pk")
+		}
+		if fks[column.Name] {
+			classes = append(classes, "fk")
+		}
+		name := html.EscapeString(column.Name)
+		if len(classes) > 0 {
+			name = fmt.Sprintf("<tspan class=%q>%s</tspan>", strings.Join(classes, " "), name)
+		}
+		typ := ": " + column.Type
+		if lookup := references[column.Name]; lookup != "" {
+			typ += " → " + lookup
+		}
+		y := n.y + svgHeaderHeight + float64(i)*svgRowHeight + svgRowHeight/2 + svgFontSize/3
+		fmt.Fprintf(b, "    <text x=\"%s\" y=\"%s\">%s<tspan class=\"type\">%s</tspan></text>\n",
+			num(n.x+svgTextPadding), num(y), name, html.EscapeString(typ))
+	}
+	b.WriteString("  </g>\n")
+}
+
+// num formats a coordinate without exponent or trailing zeros
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+}
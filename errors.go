@@ -0,0 +1,56 @@
+package dbinfo
+
+// Phase is the part of the schema being read when a catalog query failed
+type Phase string
+
+// Introspection phases
+const (
+	PhaseDatabase    Phase = "database"
+	PhaseDatabases   Phase = "databases"
+	PhaseTables      Phase = "tables"
+	PhaseColumns     Phase = "columns"
+	PhaseIndexes     Phase = "indexes"
+	PhaseForeignKeys Phase = "foreignkeys"
+	PhaseViews       Phase = "views"
+	PhaseSequences   Phase = "sequences"
+	PhaseDomains     Phase = "domains"
+	// PhaseForeignTables covers the foreign tables and their columns
+	PhaseForeignTables Phase = "foreigntables"
+	PhaseRules         Phase = "rules"
+	PhaseEventTriggers Phase = "eventtriggers"
+	PhaseStats         Phase = "stats"
+	PhasePrivileges    Phase = "privileges"
+	PhaseWorkload      Phase = "workload"
+	PhaseDependents    Phase = "dependents"
+	// PhaseDDL is the parsing of DDL files without a database, see the ddl
+	// package
+	PhaseDDL Phase = "ddl"
+)
+
+// QueryError is returned when a catalog query fails, telling which phase of
+// the introspection and which object it was reading. Use errors.As to get
+// it and errors.Is or errors.As on it to inspect the driver error, such as a
+// *pgconn.PgError or context.DeadlineExceeded.
+type QueryError struct {
+	Phase  Phase
+	Object string // schema.table being introspected, empty for database wide queries
+	Err    error
+}
+
+// Error returns the message of the underlying error, which already names
+// the object
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// queryError wraps a non nil err in a *QueryError
+func queryError(phase Phase, object string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &QueryError{Phase: phase, Object: object, Err: err}
+}
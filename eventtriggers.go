@@ -0,0 +1,75 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// EventTrigger is an event trigger, which runs a function on DDL commands
+// anywhere in the database
+type EventTrigger struct {
+	Name string `json:"name" yaml:"name"`
+	// Event is ddl_command_start, ddl_command_end, sql_drop, table_rewrite
+	// or, from PostgreSQL 17, login
+	Event    string   `json:"event" yaml:"event"`
+	Tags     []string `json:"tags" yaml:"tags"`         // Command tags it is limited to, such as CREATE TABLE, empty for all
+	Function string   `json:"function" yaml:"function"` // Schema qualified name of the function it runs
+	// Enabled tells when it fires depending on session_replication_role:
+	// origin (the default, on origin and local sessions), replica, always
+	// or disabled
+	Enabled string `json:"enabled" yaml:"enabled"`
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// triggerStates names the evtenabled values of pg_event_trigger, which
+// the ev_enabled values of pg_rewrite share
+var triggerStates = map[string]string{"O": "origin", "R": "replica", "A": "always", "D": "disabled"}
+
+// eventTriggersQuery lists the event triggers of the database, leaving out
+// those of extensions, with their comments when $1 is set. Event triggers
+// belong to no schema, so the schema filters don't apply.
+const eventTriggersQuery = `
+	SELECT e.evtname, e.evtevent, COALESCE(e.evttags, '{}'),
+	       pg_catalog.quote_ident(n.nspname) || '.' || pg_catalog.quote_ident(p.proname), e.evtenabled::text,
+	       CASE WHEN $1::boolean THEN pg_catalog.obj_description(e.oid, 'pg_event_trigger') END
+	FROM pg_catalog.pg_event_trigger e
+	JOIN pg_catalog.pg_proc p ON p.oid = e.evtfoid
+	JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+	WHERE NOT EXISTS (
+	    SELECT 1 FROM pg_catalog.pg_depend d
+	    WHERE d.classid = 'pg_catalog.pg_event_trigger'::regclass AND d.objid = e.oid AND d.deptype = 'e'
+	)
+	ORDER BY e.evtname`
+
+// getEventTriggers reads the event triggers of the database, sorted by name
+func getEventTriggers(ctx context.Context, db DBQuerier, o *options) ([]*EventTrigger, error) {
+	rows, err := db.Query(ctx, eventTriggersQuery, !o.skipComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event triggers: %w", err)
+	}
+	defer rows.Close()
+
+	var triggers []*EventTrigger
+	for rows.Next() {
+		t := &EventTrigger{}
+		var enabled string
+		var comment *string // NULL without a comment
+		if err := rows.Scan(&t.Name, &t.Event, &t.Tags, &t.Function, &enabled, &comment); err != nil {
+			return nil, fmt.Errorf("failed to scan event trigger row: %w", err)
+		}
+		t.Enabled = cmp.Or(triggerStates[enabled], enabled)
+		if comment != nil {
+			t.Comment = *comment
+		}
+		triggers = append(triggers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event trigger rows: %w", err)
+	}
+	slices.SortStableFunc(triggers, func(a, b *EventTrigger) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return triggers, nil
+}
@@ -0,0 +1,91 @@
+package dbinfo
+
+import "strings"
+
+// PlannedQuery is a catalog query run by GetDBInfo
+type PlannedQuery struct {
+	Phase    Phase
+	SQL      string
+	Args     []any
+	PerTable bool // Run once per table, with "<schema>" and "<table>" standing for the first two arguments
+}
+
+// Explain returns the catalog queries GetDBInfo runs with opts, in order,
+// without connecting to the database, so the introspection workload can be
+// reviewed before granting access. Queries run once per table are listed
+// once. The queries are those of the latest servers; older ones run them
+// with constants in place of the catalog columns they lack (see
+// Capabilities).
+func Explain(opts ...Option) ([]*PlannedQuery, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	queries := []*PlannedQuery{
+		{Phase: PhaseDatabase, SQL: databaseQuery},
+		{Phase: PhaseDatabase, SQL: serverVersionQuery},
+		{Phase: PhaseTables, SQL: tablesQuery, Args: []any{o.schemas, o.excludeSchemas, !o.skipComments}},
+		{Phase: PhaseColumns, SQL: columnsQuery, Args: []any{"<schema>", "<table>", !o.skipComments}, PerTable: true},
+	}
+	if !o.skipIndexes {
+		queries = append(queries, &PlannedQuery{Phase: PhaseIndexes, SQL: indexesQuery, Args: []any{"<schema>", "<table>"}, PerTable: true})
+	}
+	if !o.skipForeignKeys {
+		queries = append(queries, &PlannedQuery{Phase: PhaseForeignKeys, SQL: foreignKeysQuery, Args: []any{"<schema>", "<table>"}, PerTable: true})
+	}
+	if !o.skipViews {
+		args := []any{o.schemas, o.excludeSchemas, !o.skipComments}
+		queries = append(queries,
+			&PlannedQuery{Phase: PhaseViews, SQL: viewsQuery, Args: args},
+			&PlannedQuery{Phase: PhaseViews, SQL: viewColumnsQuery, Args: args},
+			&PlannedQuery{Phase: PhaseViews, SQL: viewSourcesQuery, Args: args[:2]},
+		)
+	}
+	if !o.skipSequences {
+		queries = append(queries, &PlannedQuery{Phase: PhaseSequences, SQL: sequencesQuery, Args: []any{o.schemas, o.excludeSchemas}})
+	}
+	if !o.skipDomains {
+		queries = append(queries, &PlannedQuery{Phase: PhaseDomains, SQL: domainsQuery, Args: []any{o.schemas, o.excludeSchemas, !o.skipComments}})
+	}
+	if !o.skipForeignTables {
+		args := []any{o.schemas, o.excludeSchemas, !o.skipComments}
+		queries = append(queries,
+			&PlannedQuery{Phase: PhaseForeignTables, SQL: foreignTablesQuery, Args: args},
+			&PlannedQuery{Phase: PhaseForeignTables, SQL: foreignColumnOptionsQuery, Args: args[:2]},
+			&PlannedQuery{Phase: PhaseForeignTables, SQL: columnsQuery, Args: []any{"<schema>", "<foreign table>", !o.skipComments}, PerTable: true},
+		)
+	}
+	if !o.skipRules {
+		queries = append(queries, &PlannedQuery{Phase: PhaseRules, SQL: rulesQuery, Args: []any{o.schemas, o.excludeSchemas, !o.skipComments}})
+	}
+	if !o.skipEventTriggers {
+		queries = append(queries, &PlannedQuery{Phase: PhaseEventTriggers, SQL: eventTriggersQuery, Args: []any{!o.skipComments}})
+	}
+	if o.privileges {
+		queries = append(queries,
+			&PlannedQuery{Phase: PhasePrivileges, SQL: ownersQuery, Args: []any{o.schemas, o.excludeSchemas}},
+			&PlannedQuery{Phase: PhasePrivileges, SQL: columnPrivilegesQuery, Args: []any{o.schemas, o.excludeSchemas}},
+		)
+	}
+	if o.statistics {
+		queries = append(queries, &PlannedQuery{Phase: PhaseStats, SQL: tableStatsQuery, Args: []any{o.schemas, o.excludeSchemas}})
+	}
+	if o.workload {
+		queries = append(queries,
+			&PlannedQuery{Phase: PhaseWorkload, SQL: tableWorkloadQuery},
+			&PlannedQuery{Phase: PhaseWorkload, SQL: statementsExtensionQuery},
+			&PlannedQuery{Phase: PhaseWorkload, SQL: statementsQuery},
+		)
+	}
+	for _, query := range queries {
+		query.SQL = dedent(query.SQL)
+	}
+	return queries, nil
+}
+
+// dedent removes the blank lines around sql and the tab indenting its lines
+// in the Go source
+func dedent(sql string) string {
+	return strings.ReplaceAll(strings.TrimSpace(sql), "\n\t", "\n")
+}
@@ -0,0 +1,170 @@
+package dbinfo
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Federate composes several databases into one model, so the databases of
+// a microservice landscape can be documented as a whole. The model is named
+// name and has the tables of every database, each recording the database it
+// belongs to in Database, as do its relationships. Warnings have their
+// object prefixed with the database; capabilities, which differ from server
+// to server, are left out. The databases are cloned, not modified, and must
+// have different names.
+//
+// Relationships stay within their database: see LinkReferences for the
+// ones declared across databases.
+func Federate(name string, infos []*DBInfo) (*DBInfo, error) {
+	federated := &DBInfo{Name: name, Generator: Generator()}
+	seen := make(map[string]bool, len(infos))
+	for _, info := range infos {
+		if seen[info.Name] {
+			return nil, fmt.Errorf("database %s is given twice", info.Name)
+		}
+		seen[info.Name] = true
+
+		clone := info.Clone()
+		for _, table := range clone.Tables {
+			table.Database = info.Name
+			for _, r := range table.HasMany {
+				r.Database = info.Name
+			}
+			for _, r := range table.BelongsTo {
+				r.Database = info.Name
+			}
+		}
+		for _, w := range clone.Warnings {
+			w.Object = info.Name + "." + w.Object
+		}
+		federated.Tables = append(federated.Tables, clone.Tables...)
+		federated.Warnings = append(federated.Warnings, clone.Warnings...)
+	}
+	slices.SortStableFunc(federated.Tables, func(a, b *Table) int {
+		return cmp.Or(cmp.Compare(a.Database, b.Database), cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	federated.EnsureSlices()
+	return federated, nil
+}
+
+// referencesPattern matches the annotations declaring a logical reference
+// in a column comment, such as "@references crm.public.customers(id)". The
+// column may be left out to reference the primary key.
+var referencesPattern = regexp.MustCompile(`@references\s+([^\s(]+)(?:\(\s*([^\s)]*)\s*\))?`)
+
+// CrossReference is a logical reference from a column to a column of
+// another table, usually of another database, declared with an annotation
+type CrossReference struct {
+	Database    string `json:"database" yaml:"database"`
+	Schema      string `json:"schema" yaml:"schema"`
+	Table       string `json:"table" yaml:"table"`
+	Column      string `json:"column" yaml:"column"`
+	RefDatabase string `json:"refdatabase" yaml:"refdatabase"`
+	RefSchema   string `json:"refschema" yaml:"refschema"`
+	RefTable    string `json:"reftable" yaml:"reftable"`
+	RefColumn   string `json:"refcolumn,omitempty" yaml:"refcolumn,omitempty"` // Empty for the primary key
+}
+
+// String returns the reference as database.schema.table.column ->
+// database.schema.table.column
+func (r *CrossReference) String() string {
+	target := r.RefDatabase + "." + r.RefSchema + "." + r.RefTable
+	if r.RefColumn != "" {
+		target += "." + r.RefColumn
+	}
+	return r.Database + "." + r.Schema + "." + r.Table + "." + r.Column + " -> " + target
+}
+
+// databaseTableKey identifies a table of a federated model
+type databaseTableKey struct {
+	database, schema, name string
+}
+
+// LinkReferences adds the relationships declared by @references annotations
+// in column comments, which name the referenced table as
+// database.schema.table and optionally its column:
+//
+//	COMMENT ON COLUMN orders.customer_id IS 'Buyer. @references crm.public.customers(id)';
+//
+// The referencing table gets a BelongsTo relationship and the referenced
+// one a HasMany relationship, both with an empty ForeignKey and the
+// database of the related table. Tables with no Database, as in models that
+// aren't federated, belong to the database the model is named after.
+//
+// The references to tables or columns the model doesn't have, such as
+// databases left out of the federation, are returned. Malformed annotations
+// and references to tables without a single column primary key, when the
+// column is left out, are errors.
+func (d *DBInfo) LinkReferences() ([]*CrossReference, error) {
+	tableDatabase := func(table *Table) string {
+		if table.Database != "" {
+			return table.Database
+		}
+		return d.Name
+	}
+	tables := make(map[databaseTableKey]*Table, len(d.Tables))
+	for _, table := range d.Tables {
+		tables[databaseTableKey{tableDatabase(table), table.Schema, table.Name}] = table
+	}
+
+	var unresolved []*CrossReference
+	linked := make(map[*Table]bool)
+	for _, table := range d.Tables {
+		for _, column := range table.Columns {
+			for _, match := range referencesPattern.FindAllStringSubmatch(column.Comment, -1) {
+				parts := strings.Split(match[1], ".")
+				if len(parts) != 3 || slices.Contains(parts, "") {
+					return nil, fmt.Errorf("%s.%s.%s: invalid annotation %q, expected @references database.schema.table(column)",
+						table.Schema, table.Name, column.Name, match[0])
+				}
+				ref := &CrossReference{
+					Database: tableDatabase(table), Schema: table.Schema, Table: table.Name, Column: column.Name,
+					RefDatabase: parts[0], RefSchema: parts[1], RefTable: parts[2], RefColumn: match[2],
+				}
+				refTable, ok := tables[databaseTableKey{ref.RefDatabase, ref.RefSchema, ref.RefTable}]
+				if !ok {
+					unresolved = append(unresolved, ref)
+					continue
+				}
+				if ref.RefColumn == "" {
+					if refTable.PrimaryKey == nil || len(refTable.PrimaryKey.Columns) != 1 {
+						return nil, fmt.Errorf("%s: the referenced table has no single column primary key, name the column", ref)
+					}
+					ref.RefColumn = refTable.PrimaryKey.Columns[0]
+				}
+				if !slices.ContainsFunc(refTable.Columns, func(c *Column) bool { return c.Name == ref.RefColumn }) {
+					unresolved = append(unresolved, ref)
+					continue
+				}
+
+				table.BelongsTo = append(table.BelongsTo, &Relationship{
+					Table:      ref.RefTable,
+					Schema:     ref.RefSchema,
+					Database:   ref.RefDatabase,
+					Columns:    []string{ref.Column},
+					References: []string{ref.RefColumn},
+				})
+				refTable.HasMany = append(refTable.HasMany, &Relationship{
+					Table:      ref.Table,
+					Schema:     ref.Schema,
+					Database:   ref.Database,
+					Columns:    []string{ref.RefColumn},
+					References: []string{ref.Column},
+				})
+				linked[table], linked[refTable] = true, true
+			}
+		}
+	}
+
+	for _, table := range d.Tables {
+		if linked[table] {
+			sortRelationships(table.HasMany)
+			sortRelationships(table.BelongsTo)
+			nameRelationships(table)
+		}
+	}
+	return unresolved, nil
+}
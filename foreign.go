@@ -0,0 +1,166 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ForeignTable is a foreign table, whose rows a foreign data wrapper such as
+// postgres_fdw reads from a server outside of the database
+type ForeignTable struct {
+	Name    string `json:"name" yaml:"name"`
+	Schema  string `json:"schema" yaml:"schema"`
+	Server  string `json:"server" yaml:"server"`   // Foreign server, as in CREATE SERVER
+	Wrapper string `json:"wrapper" yaml:"wrapper"` // Foreign data wrapper of the server, e.g. postgres_fdw
+	// Options are the options of the table, such as the schema_name and
+	// table_name of the remote table for postgres_fdw
+	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+	Columns []*Column         `json:"columns" yaml:"columns"`
+	// ColumnOptions are the options of the columns having any, by column
+	// name, such as the column_name of the remote column for postgres_fdw
+	ColumnOptions map[string]map[string]string `json:"columnoptions,omitempty" yaml:"columnoptions,omitempty"`
+	Comment       string                       `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Owner         string                       `json:"owner,omitempty" yaml:"owner,omitempty"`   // As in Table
+	Grants        []*Grant                     `json:"grants,omitempty" yaml:"grants,omitempty"` // As in Table
+}
+
+// QualifiedName returns the quoted schema.name of the foreign table
+func (t *ForeignTable) QualifiedName() string {
+	return QualifiedName(t.Schema, t.Name)
+}
+
+// foreignFilter restricts the foreign table queries to the user schemas
+// included by $1 and not excluded by $2, and leaves out the foreign tables
+// of extensions
+const foreignFilter = `
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	AND NOT EXISTS (
+	    SELECT 1 FROM pg_catalog.pg_depend e
+	    WHERE e.classid = 'pg_catalog.pg_class'::regclass AND e.objid = c.oid AND e.deptype = 'e'
+	)`
+
+// foreignTablesQuery lists the foreign tables with their server, wrapper and
+// options, and their comments when $3 is set
+const foreignTablesQuery = `
+	SELECT n.nspname, c.relname, s.srvname, w.fdwname, COALESCE(ft.ftoptions, '{}'),
+	       CASE WHEN $3::boolean THEN pg_catalog.obj_description(c.oid, 'pg_class') END
+	FROM pg_catalog.pg_foreign_table ft
+	JOIN pg_catalog.pg_class c ON c.oid = ft.ftrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_catalog.pg_foreign_server s ON s.oid = ft.ftserver
+	JOIN pg_catalog.pg_foreign_data_wrapper w ON w.oid = s.srvfdw
+	WHERE c.relkind = 'f'` + foreignFilter + `
+	ORDER BY n.nspname, c.relname`
+
+// foreignColumnOptionsQuery lists the options of the foreign table columns
+// having any
+const foreignColumnOptionsQuery = `
+	SELECT n.nspname, c.relname, a.attname, a.attfdwoptions
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+	WHERE c.relkind = 'f' AND a.attfdwoptions IS NOT NULL` + foreignFilter + `
+	ORDER BY n.nspname, c.relname, a.attnum`
+
+// getForeignTables reads the foreign tables of the schemas and tables the
+// options select, sorted by schema and name, with their column options.
+// Their columns are read by getForeignTableColumns.
+func getForeignTables(ctx context.Context, db DBQuerier, o *options) ([]*ForeignTable, error) {
+	rows, err := db.Query(ctx, foreignTablesQuery, o.schemas, o.excludeSchemas, !o.skipComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign tables: %w", err)
+	}
+	var tables []*ForeignTable
+	byKey := make(map[ObjectKey]*ForeignTable)
+	for rows.Next() {
+		t := &ForeignTable{}
+		var options []string
+		var comment *string // NULL without a comment
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Server, &t.Wrapper, &options, &comment); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan foreign table row: %w", err)
+		}
+		if !o.includeTable(t.Schema, t.Name) {
+			continue
+		}
+		t.Options = parseOptions(options)
+		if comment != nil {
+			t.Comment = *comment
+		}
+		tables = append(tables, t)
+		byKey[ObjectKey{t.Schema, t.Name}] = t
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating foreign table rows: %w", err)
+	}
+
+	rows, err = db.Query(ctx, foreignColumnOptionsQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query foreign column options: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key ObjectKey
+		var column string
+		var options []string
+		if err := rows.Scan(&key.Schema, &key.Name, &column, &options); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign column options row: %w", err)
+		}
+		t := byKey[key]
+		if t == nil || len(options) == 0 {
+			continue
+		}
+		if t.ColumnOptions == nil {
+			t.ColumnOptions = make(map[string]map[string]string)
+		}
+		t.ColumnOptions[column] = parseOptions(options)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating foreign column options rows: %w", err)
+	}
+
+	slices.SortStableFunc(tables, func(a, b *ForeignTable) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	return tables, nil
+}
+
+// getForeignTableColumns reads the columns of the foreign tables, with the
+// version of columnsQuery the server runs. Unreadable foreign tables are
+// left without columns and a warning.
+func getForeignTableColumns(ctx context.Context, db DBQuerier, o *options, tables []*ForeignTable) error {
+	query := o.capabilities.queries().columns
+	for _, table := range tables {
+		object := table.Schema + "." + table.Name
+		columns, err := getColumns(ctx, db, query, table.Schema, table.Name, !o.skipComments)
+		if err != nil && skippable(err) {
+			o.warn(ctx, PhaseForeignTables, object, err)
+			continue
+		}
+		if err != nil {
+			return queryError(PhaseForeignTables, object, err)
+		}
+		table.Columns = columns
+	}
+	return nil
+}
+
+// parseOptions turns the key=value options of a catalog, such as ftoptions,
+// into a map, nil when there are none
+func parseOptions(options []string) map[string]string {
+	if len(options) == 0 {
+		return nil
+	}
+	parsed := make(map[string]string, len(options))
+	for _, option := range options {
+		key, value, _ := strings.Cut(option, "=")
+		parsed[key] = value
+	}
+	return parsed
+}
@@ -0,0 +1,270 @@
+// Package glossary enriches dbinfo schemas with a business glossary kept
+// outside the database: the business term and description of tables and
+// columns, for documentation and catalog exports of schemas with few
+// comments.
+//
+// A glossary is a YAML (or JSON) file:
+//
+//	tables:
+//	  public.customers:
+//	    term: Customer
+//	    description: A person or company that placed an order.
+//	    columns:
+//	      ltv: {term: Customer lifetime value, description: Revenue to date, in cents.}
+//	columns:
+//	  created_at: {description: When the row was inserted.}
+//
+// Tables are named schema.table, or by name alone to match them in every
+// schema. The top-level columns apply to columns of that name in any table
+// without an entry of its own. A glossary can also be a CSV file with
+// table, column, term and description columns, leaving table empty for the
+// top-level columns.
+//
+// Apply never replaces database comments: descriptions only fill the
+// missing ones. Terms are recorded as term:<term> tags.
+package glossary
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// TagPrefix starts the table and column tags recording business terms
+const TagPrefix = "term:"
+
+// Entry describes a table or column
+type Entry struct {
+	Term        string `yaml:"term" json:"term"`
+	Description string `yaml:"description" json:"description"`
+}
+
+// TableEntry describes a table and its columns
+type TableEntry struct {
+	Entry   `yaml:",inline"`
+	Columns map[string]*Entry `yaml:"columns" json:"columns"`
+}
+
+// Glossary maps table and column names to their entries
+type Glossary struct {
+	Tables  map[string]*TableEntry `yaml:"tables" json:"tables"`
+	Columns map[string]*Entry      `yaml:"columns" json:"columns"` // By column name, in any table
+}
+
+// Parse reads a YAML or JSON glossary
+func Parse(data []byte) (*Glossary, error) {
+	g := &Glossary{}
+	if err := yaml.Unmarshal(data, g); err != nil {
+		return nil, fmt.Errorf("failed to parse glossary: %w", err)
+	}
+	// Catches files whose top-level keys are misspelled
+	if len(g.Tables) == 0 && len(g.Columns) == 0 {
+		return nil, errors.New("the glossary has no tables or columns")
+	}
+	return g, nil
+}
+
+// csvFields are the columns a CSV glossary can have
+var csvFields = []string{"table", "column", "term", "description"}
+
+// ParseCSV reads a CSV glossary. Its header names the fields of each entry,
+// in any order. Lines starting with # are comments.
+func ParseCSV(r io.Reader) (*Glossary, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse glossary: %w", err)
+	}
+	index := map[string]int{}
+	for i, field := range header {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !slices.Contains(csvFields, field) {
+			return nil, fmt.Errorf("unknown glossary column %q, expected %s", field, strings.Join(csvFields, ", "))
+		}
+		index[field] = i
+	}
+
+	g := &Glossary{Tables: map[string]*TableEntry{}, Columns: map[string]*Entry{}}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse glossary: %w", err)
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		table, column := field("table"), field("column")
+		entry := Entry{Term: field("term"), Description: field("description")}
+		switch {
+		case table == "" && column == "":
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: an entry needs a table or a column", line)
+		case table == "":
+			g.Columns[column] = &entry
+		default:
+			t := g.Tables[table]
+			if t == nil {
+				t = &TableEntry{}
+				g.Tables[table] = t
+			}
+			if column == "" {
+				t.Entry = entry
+			} else {
+				if t.Columns == nil {
+					t.Columns = map[string]*Entry{}
+				}
+				t.Columns[column] = &entry
+			}
+		}
+	}
+	if len(g.Tables) == 0 && len(g.Columns) == 0 {
+		return nil, errors.New("the glossary has no tables or columns")
+	}
+	return g, nil
+}
+
+// LoadFile reads the glossary at path, as CSV when its extension is .csv
+func LoadFile(path string) (*Glossary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read glossary: %w", err)
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseCSV(f)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read glossary: %w", err)
+	}
+	return Parse(data)
+}
+
+// parseTableKey splits the schema.table name of an entry. The schema is
+// empty for the entries naming a table in every schema.
+func parseTableKey(key string) dbinfo.ObjectKey {
+	if schema, name, ok := strings.Cut(key, "."); ok {
+		return dbinfo.ObjectKey{Schema: schema, Name: name}
+	}
+	return dbinfo.ObjectKey{Name: key}
+}
+
+// table returns the entry of table and its name in g.Tables, preferring the
+// one naming its schema
+func (g *Glossary) table(table *dbinfo.Table) (string, *TableEntry) {
+	var key string
+	var entry *TableEntry
+	for k, e := range g.Tables {
+		switch parseTableKey(k) {
+		case dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}:
+			return k, e
+		case dbinfo.ObjectKey{Name: table.Name}:
+			key, entry = k, e
+		}
+	}
+	return key, entry
+}
+
+// column returns the entry of a column of a table with the given entry
+func (g *Glossary) column(tableEntry *TableEntry, name string) *Entry {
+	if tableEntry != nil {
+		if entry, ok := tableEntry.Columns[name]; ok {
+			return entry
+		}
+	}
+	return g.Columns[name]
+}
+
+// Apply enriches every table of info, see ApplyTable
+func (g *Glossary) Apply(info *dbinfo.DBInfo) {
+	for _, table := range info.Tables {
+		g.ApplyTable(table)
+	}
+}
+
+// ApplyTable fills the missing comments of table and its columns with their
+// glossary descriptions and tags them with their terms
+func (g *Glossary) ApplyTable(table *dbinfo.Table) {
+	_, tableEntry := g.table(table)
+	if tableEntry != nil {
+		table.Comment = merge(table.Comment, &tableEntry.Entry, &table.Tags)
+	}
+	for _, column := range table.Columns {
+		if entry := g.column(tableEntry, column.Name); entry != nil {
+			column.Comment = merge(column.Comment, entry, &column.Tags)
+		}
+	}
+}
+
+// merge adds the term tag of entry to tags and returns comment, or the
+// description of entry when comment is empty
+func merge(comment string, entry *Entry, tags *[]string) string {
+	if entry.Term != "" {
+		if tag := TagPrefix + entry.Term; !slices.Contains(*tags, tag) {
+			*tags = append(*tags, tag)
+		}
+	}
+	if comment == "" {
+		return entry.Description
+	}
+	return comment
+}
+
+// Unused returns the glossary entries matching nothing in info, as
+// table[.column] or *.column for the top-level columns, so stale entries
+// can be cleaned up
+func (g *Glossary) Unused(info *dbinfo.DBInfo) []string {
+	usedTables := map[string]bool{}
+	usedColumns := map[string]bool{}
+	for _, table := range info.Tables {
+		key, tableEntry := g.table(table)
+		if tableEntry != nil {
+			usedTables[key] = true
+		}
+		for _, column := range table.Columns {
+			if tableEntry != nil {
+				if _, ok := tableEntry.Columns[column.Name]; ok {
+					usedColumns[key+"."+column.Name] = true
+					continue
+				}
+			}
+			usedColumns["*."+column.Name] = true
+		}
+	}
+
+	var unused []string
+	for key, entry := range g.Tables {
+		if !usedTables[key] {
+			unused = append(unused, key)
+			continue
+		}
+		for name := range entry.Columns {
+			if !usedColumns[key+"."+name] {
+				unused = append(unused, key+"."+name)
+			}
+		}
+	}
+	for name := range g.Columns {
+		if !usedColumns["*."+name] {
+			unused = append(unused, "*."+name)
+		}
+	}
+	slices.Sort(unused)
+	return unused
+}
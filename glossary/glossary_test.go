@@ -0,0 +1,165 @@
+package glossary
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema:  "public",
+				Name:    "customers",
+				Comment: "Registered customers",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "email", Type: "text", Tags: []string{"pii:email"}},
+					{Name: "ltv", Type: "bigint", Comment: "Lifetime value"},
+					{Name: "created_at", Type: "timestamp with time zone"},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint"},
+					{Name: "created_at", Type: "timestamp with time zone"},
+				},
+			},
+			{
+				Schema: "archive",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint"},
+				},
+			},
+		},
+	}
+}
+
+const testGlossary = `
+tables:
+  public.customers:
+    term: Customer
+    description: A person or company that placed an order.
+    columns:
+      email: {term: Contact email, description: Where receipts are sent.}
+      ltv: {term: Customer lifetime value, description: Revenue to date, in cents.}
+      fax: {description: Removed in 2019.}
+  orders:
+    term: Order
+    description: A purchase.
+  public.invoices:
+    term: Invoice
+columns:
+  created_at: {description: When the row was inserted.}
+  deleted_at: {description: When the row was soft deleted.}
+`
+
+func TestApply(t *testing.T) {
+	g, err := Parse([]byte(testGlossary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := testInfo()
+	g.Apply(info)
+	g.Apply(info) // Applying twice adds no duplicate tags
+
+	type described struct {
+		Comment string
+		Tags    []string
+	}
+	got := map[string]described{}
+	for _, table := range info.Tables {
+		got[table.Schema+"."+table.Name] = described{table.Comment, table.Tags}
+		for _, column := range table.Columns {
+			got[table.Schema+"."+table.Name+"."+column.Name] = described{column.Comment, column.Tags}
+		}
+	}
+	want := map[string]described{
+		"public.customers":            {"Registered customers", []string{"term:Customer"}},
+		"public.customers.id":         {},
+		"public.customers.email":      {"Where receipts are sent.", []string{"pii:email", "term:Contact email"}},
+		"public.customers.ltv":        {"Lifetime value", []string{"term:Customer lifetime value"}},
+		"public.customers.created_at": {"When the row was inserted.", nil},
+		"public.orders":               {"A purchase.", []string{"term:Order"}},
+		"public.orders.id":            {},
+		"public.orders.created_at":    {"When the row was inserted.", nil},
+		"archive.orders":              {"A purchase.", []string{"term:Order"}},
+		"archive.orders.id":           {},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("enriched schema (-want +got):\n%s", diff)
+	}
+
+	unused := []string{"*.deleted_at", "public.customers.fax", "public.invoices"}
+	if diff := cmp.Diff(unused, g.Unused(info)); diff != "" {
+		t.Errorf("unused entries (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	g, err := ParseCSV(strings.NewReader(`# Business terms
+Table,column,term,description
+public.customers,,Customer,"A person or company, usually"
+public.customers, email, Contact email,
+,created_at,,When the row was inserted.
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Glossary{
+		Tables: map[string]*TableEntry{
+			"public.customers": {
+				Entry:   Entry{Term: "Customer", Description: "A person or company, usually"},
+				Columns: map[string]*Entry{"email": {Term: "Contact email"}},
+			},
+		},
+		Columns: map[string]*Entry{"created_at": {Description: "When the row was inserted."}},
+	}
+	if diff := cmp.Diff(want, g); diff != "" {
+		t.Errorf("glossary (-want +got):\n%s", diff)
+	}
+
+	for input, want := range map[string]string{
+		"table,owner\nx,y\n":           `unknown glossary column "owner", expected table, column, term, description`,
+		"table,column,term\n,,Order\n": "line 2: an entry needs a table or a column",
+		"table,term\n":                 "the glossary has no tables or columns",
+	} {
+		if _, err := ParseCSV(strings.NewReader(input)); err == nil || err.Error() != want {
+			t.Errorf("ParseCSV(%q) error = %v, want %q", input, err, want)
+		}
+	}
+
+	if _, err := Parse([]byte("table:\n  customers: {term: Customer}\n")); err == nil {
+		t.Error("Expected an error for a glossary without tables or columns")
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "glossary.CSV")
+	if err := os.WriteFile(csvPath, []byte("table,term\norders,Order\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "glossary.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"tables": {"orders": {"term": "Order"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{csvPath, jsonPath} {
+		g, err := LoadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry := g.Tables["orders"]; entry == nil || entry.Term != "Order" {
+			t.Errorf("LoadFile(%s) = %+v", filepath.Base(path), g.Tables)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package dbinfo_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/ddl"
+	"github.com/guillermo/dbinfo/erd"
+	"github.com/guillermo/dbinfo/internal/format"
+	"github.com/guillermo/dbinfo/sample"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestGoldenSnapshot introspects the sample schema and compares its
+// snapshots with testdata/demo.yaml and testdata/demo.json
+func TestGoldenSnapshot(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := dbinfo.FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	info, err := dbinfo.GetDBInfo(ctx, pool, dbinfo.WithSchemas("public"), dbinfo.WithTables(sample.Tables...))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	// The database name, the build and the server version vary between runs
+	info.Name, info.Generator, info.Capabilities = "demo", "", nil
+
+	writers := map[string]func(io.Writer, *dbinfo.DBInfo) error{
+		"demo.yaml": snapshot.WriteYAML,
+		"demo.json": snapshot.WriteJSON,
+	}
+	for name, write := range writers {
+		var buf bytes.Buffer
+		if err := write(&buf, info); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		golden(t, name, buf.Bytes())
+	}
+}
+
+// TestGoldenFormats writes testdata/demo.yaml in every output format, as DDL
+// and as diagrams, and compares the results with the files in
+// testdata/formats
+func TestGoldenFormats(t *testing.T) {
+	info, err := snapshot.LoadFile(filepath.Join("testdata", "demo.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range format.Names() {
+		encoder, err := format.New(name, format.Options{DefaultSchema: "public", EventTime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, info); err != nil {
+			t.Errorf("Failed to write %s: %v", name, err)
+			continue
+		}
+		golden(t, filepath.Join("formats", name+"."+format.Extension(name)), buf.Bytes())
+	}
+
+	writers := map[string]func(io.Writer, *dbinfo.DBInfo) error{
+		"ddl.sql":     ddl.Generate,
+		"mermaid.mmd": func(w io.Writer, info *dbinfo.DBInfo) error { return erd.Mermaid(w, info) },
+		"dot.gv":      func(w io.Writer, info *dbinfo.DBInfo) error { return erd.DOT(w, info) },
+		"erd.svg":     func(w io.Writer, info *dbinfo.DBInfo) error { return erd.SVG(w, info) },
+	}
+	for name, write := range writers {
+		var buf bytes.Buffer
+		if err := write(&buf, info); err != nil {
+			t.Errorf("Failed to write %s: %v", name, err)
+			continue
+		}
+		golden(t, filepath.Join("formats", name), buf.Bytes())
+	}
+}
+
+// golden compares actual with the golden file testdata/name, or rewrites
+// the file with -update
+func golden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file, run go test -update to create it: %v", err)
+	}
+	if diff := cmp.Diff(string(expected), string(actual)); diff != "" {
+		t.Errorf("%s is out of date, run go test -update if the change is expected (-golden +actual):\n%s", path, diff)
+	}
+}
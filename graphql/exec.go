@@ -0,0 +1,520 @@
+// Package graphql serves dbinfo schemas over a read-only GraphQL API: the
+// tables, columns, keys and relationships of a schema form a graph that
+// clients query without custom endpoints, such as every table referencing
+// customers:
+//
+//	{ table(name: "customers") { hasMany { table { schema name } columns } } }
+//
+// The package implements the query subset of GraphQL the schema needs:
+// queries with variables, aliases, fragments, inline fragments, the @skip
+// and @include directives, and __typename. Mutations and subscriptions are
+// rejected. Introspection queries (__schema, __type) aren't supported; SDL
+// returns the schema definition instead.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Request is a GraphQL request, as sent in the body of a POST
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is left out when the request
+// fails before running, such as on a syntax error.
+type Response struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"` // Response keys and list indexes leading to the failed field
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Location is a position in the query document, counted from 1
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Execute runs a query against the schema of info. Mutations and
+// subscriptions are rejected, since the schema is read-only.
+func Execute(info *dbinfo.DBInfo, req *Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{err.(*Error)}}
+	}
+
+	var op *operation
+	switch {
+	case req.OperationName != "":
+		for _, candidate := range doc.operations {
+			if candidate.name == req.OperationName {
+				op = candidate
+			}
+		}
+		if op == nil {
+			return &Response{Errors: []*Error{{Message: fmt.Sprintf("unknown operation %q", req.OperationName)}}}
+		}
+	case len(doc.operations) > 1:
+		return &Response{Errors: []*Error{{Message: "the document has several operations, operationName must name one"}}}
+	default:
+		op = doc.operations[0]
+	}
+
+	e := &executor{info: info, doc: doc, tables: make(map[dbinfo.ObjectKey]*dbinfo.Table, len(info.Tables))}
+	for _, table := range info.Tables {
+		e.tables[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}] = table
+	}
+	if errs := e.validate(op); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	if err := e.coerceVariables(op, req.Variables); err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+
+	data, _ := e.selectionSet(queryType, nil, op.selection, nil)
+	return &Response{Data: data, Errors: e.errors}
+}
+
+// executor runs an operation
+type executor struct {
+	info      *dbinfo.DBInfo
+	tables    map[dbinfo.ObjectKey]*dbinfo.Table
+	doc       *document
+	variables map[string]any
+	errors    []*Error
+}
+
+// validate checks that the fields, arguments, fragments and directives of
+// the operation exist in the schema, before anything runs
+func (e *executor) validate(op *operation) []*Error {
+	var errs []*Error
+	fail := func(f *field, format string, args ...any) {
+		err := &Error{Message: fmt.Sprintf(format, args...)}
+		if f != nil {
+			err.Locations = []Location{{f.line, f.column}}
+		}
+		errs = append(errs, err)
+	}
+	defined := make(map[string]bool, len(op.variables))
+	for _, v := range op.variables {
+		if defined[v.name] {
+			fail(nil, "variable $%s is defined more than once", v.name)
+		}
+		defined[v.name] = true
+	}
+	validateVariables := func(f *field, args map[string]value) {
+		for _, v := range args {
+			for _, name := range variables(v) {
+				if !defined[name] {
+					fail(f, "variable $%s is not defined by the operation", name)
+				}
+			}
+		}
+	}
+	validateDirectives := func(f *field, directives []*directive) {
+		for _, d := range directives {
+			validateVariables(f, d.arguments)
+			if d.name != "skip" && d.name != "include" {
+				fail(f, "unknown directive @%s", d.name)
+			} else if _, ok := d.arguments["if"]; !ok || len(d.arguments) != 1 {
+				fail(f, "directive @%s takes a single if argument", d.name)
+			}
+		}
+	}
+
+	var visit func(typ *objectType, selections []selection, spreading map[string]bool)
+	visit = func(typ *objectType, selections []selection, spreading map[string]bool) {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *field:
+				validateDirectives(sel, sel.directives)
+				if sel.name == "__typename" {
+					if sel.selection != nil || len(sel.arguments) > 0 {
+						fail(sel, "field __typename takes no arguments or selection")
+					}
+					continue
+				}
+				def := typ.field(sel.name)
+				if def == nil {
+					fail(sel, "cannot query field %q on type %s", sel.name, typ.name)
+					continue
+				}
+				validateVariables(sel, sel.arguments)
+				for name := range sel.arguments {
+					if def.arg(name) == nil {
+						fail(sel, "unknown argument %q on field %s.%s", name, typ.name, def.name)
+					}
+				}
+				for _, arg := range def.args {
+					if _, ok := sel.arguments[arg.name]; !ok && strings.HasSuffix(arg.typ, "!") {
+						fail(sel, "field %s.%s needs the argument %s", typ.name, def.name, arg.name)
+					}
+				}
+				named := types[namedType(def.typ)]
+				switch {
+				case named != nil && sel.selection == nil:
+					fail(sel, "field %s.%s of type %s needs a selection of subfields", typ.name, def.name, def.typ)
+				case named == nil && sel.selection != nil:
+					fail(sel, "field %s.%s of type %s has no subfields", typ.name, def.name, def.typ)
+				case named != nil:
+					visit(named, sel.selection, spreading)
+				}
+			case *fragmentSpread:
+				validateDirectives(nil, sel.directives)
+				f := e.doc.fragments[sel.name]
+				switch {
+				case f == nil:
+					fail(nil, "unknown fragment %s", sel.name)
+				case spreading[sel.name]:
+					fail(nil, "fragment %s spreads itself", sel.name)
+				case types[f.typeCondition] == nil:
+					fail(nil, "fragment %s is on unknown type %s", f.name, f.typeCondition)
+				default:
+					validateDirectives(nil, f.directives)
+					spreading[sel.name] = true
+					visit(types[f.typeCondition], f.selection, spreading)
+					delete(spreading, sel.name)
+				}
+			case *inlineFragment:
+				validateDirectives(nil, sel.directives)
+				target := typ
+				if sel.typeCondition != "" {
+					if target = types[sel.typeCondition]; target == nil {
+						fail(nil, "inline fragment on unknown type %s", sel.typeCondition)
+						continue
+					}
+				}
+				visit(target, sel.selection, spreading)
+			}
+		}
+	}
+	visit(queryType, op.selection, make(map[string]bool))
+	return errs
+}
+
+// variables returns the names of the variables a value uses
+func variables(v value) []string {
+	switch v := v.(type) {
+	case variable:
+		return []string{string(v)}
+	case []value:
+		var names []string
+		for _, item := range v {
+			names = append(names, variables(item)...)
+		}
+		return names
+	case map[string]value:
+		var names []string
+		for _, item := range v {
+			names = append(names, variables(item)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// coerceVariables checks the variables sent with the request against the
+// definitions of the operation, applying their defaults
+func (e *executor) coerceVariables(op *operation, values map[string]any) *Error {
+	e.variables = make(map[string]any, len(op.variables))
+	for _, def := range op.variables {
+		v, ok := values[def.name]
+		if !ok {
+			v, ok = e.resolve(def.defaultValue), def.defaultValue != nil
+		}
+		coerced, err := coerce(def.typ, v)
+		if err != nil {
+			return &Error{Message: fmt.Sprintf("variable $%s: %v", def.name, err)}
+		}
+		if ok {
+			e.variables[def.name] = coerced
+		}
+	}
+	return nil
+}
+
+// coerce checks that v, decoded from JSON or resolved from the query, is a
+// valid value of the type typ, converting whole JSON numbers to Int
+func coerce(typ string, v any) (any, error) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if v == nil {
+		if nonNull {
+			return nil, fmt.Errorf("expected a value of type %s!, got null", typ)
+		}
+		return nil, nil
+	}
+
+	if elem, ok := strings.CutPrefix(typ, "["); ok {
+		elem = strings.TrimSuffix(elem, "]")
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v} // A single value is a list of one
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			var err error
+			if list[i], err = coerce(elem, item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+
+	switch typ {
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown input type %s", typ)
+	}
+	return nil, fmt.Errorf("expected a value of type %s, got %v", typ, v)
+}
+
+// resolve replaces the variables in a literal value with their values
+func (e *executor) resolve(v value) any {
+	switch v := v.(type) {
+	case variable:
+		return e.variables[string(v)]
+	case enumValue:
+		return string(v)
+	case []value:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = e.resolve(item)
+		}
+		return list
+	case map[string]value:
+		object := make(map[string]any, len(v))
+		for name, item := range v {
+			object[name] = e.resolve(item)
+		}
+		return object
+	}
+	return v
+}
+
+// arguments returns the arguments of a field call, coerced to the types
+// the field declares
+func (e *executor) arguments(def *fieldDef, values map[string]value) (map[string]any, error) {
+	args := make(map[string]any, len(def.args))
+	for _, arg := range def.args {
+		literal, ok := values[arg.name]
+		if v, isVariable := literal.(variable); isVariable {
+			_, ok = e.variables[string(v)]
+		}
+		var v any
+		if ok {
+			v = e.resolve(literal)
+		}
+		coerced, err := coerce(arg.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %w", arg.name, err)
+		}
+		args[arg.name] = coerced
+	}
+	return args, nil
+}
+
+// included evaluates the @skip and @include directives
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		condition, _ := e.resolve(d.arguments["if"]).(bool)
+		if d.name == "skip" && condition || d.name == "include" && !condition {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldGroup holds the fields sharing a response key, whose selections are
+// merged
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collectFields flattens the fragments of a selection set that apply to
+// typ and groups the fields by response key, in query order
+func (e *executor) collectFields(typ *objectType, selections []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.responseKey()
+			var group *fieldGroup
+			for _, g := range groups {
+				if g.key == key {
+					group = g
+					break
+				}
+			}
+			if group == nil {
+				group = &fieldGroup{key: key}
+				groups = append(groups, group)
+			}
+			group.fields = append(group.fields, sel)
+		case *fragmentSpread:
+			f := e.doc.fragments[sel.name]
+			if visited[sel.name] || !e.included(sel.directives) || !e.included(f.directives) || f.typeCondition != typ.name {
+				continue
+			}
+			visited[sel.name] = true
+			groups = e.collectFields(typ, f.selection, groups, visited)
+		case *inlineFragment:
+			if !e.included(sel.directives) || sel.typeCondition != "" && sel.typeCondition != typ.name {
+				continue
+			}
+			groups = e.collectFields(typ, sel.selection, groups, visited)
+		}
+	}
+	return groups
+}
+
+// selectionSet resolves the fields selected on source, an object of type
+// typ. It returns false when a non-null field is null, so its parent
+// becomes null in turn.
+func (e *executor) selectionSet(typ *objectType, source any, selections []selection, path []any) (*object, bool) {
+	result := &object{}
+	for _, group := range e.collectFields(typ, selections, nil, make(map[string]bool)) {
+		f := group.fields[0]
+		fieldPath := append(path[:len(path):len(path)], group.key)
+		if f.name == "__typename" {
+			result.set(group.key, typ.name)
+			continue
+		}
+
+		def := typ.field(f.name)
+		var subselection []selection
+		for _, f := range group.fields {
+			subselection = append(subselection, f.selection...)
+		}
+		var v any
+		args, err := e.arguments(def, f.arguments)
+		if err == nil {
+			v, err = def.resolve(e, source, args)
+		}
+		if err != nil {
+			e.errors = append(e.errors, &Error{Message: err.Error(), Locations: []Location{{f.line, f.column}}, Path: fieldPath})
+			v = nil
+		}
+		completed, ok := e.complete(def.typ, v, subselection, fieldPath)
+		if !ok {
+			return nil, false
+		}
+		result.set(group.key, completed)
+	}
+	return result, true
+}
+
+// complete turns a resolved value into its response value according to its
+// type. It returns false when the value is null at a non-null position.
+func (e *executor) complete(typ string, v any, selections []selection, path []any) (any, bool) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if isNull(v) {
+		return nil, !nonNull
+	}
+
+	if elem, ok := strings.CutPrefix(typ, "["); ok {
+		elem = strings.TrimSuffix(elem, "]")
+		items := reflect.ValueOf(v)
+		list := make([]any, items.Len())
+		for i := range list {
+			item, ok := e.complete(elem, items.Index(i).Interface(), selections, append(path[:len(path):len(path)], i))
+			if !ok {
+				return nil, !nonNull
+			}
+			list[i] = item
+		}
+		return list, true
+	}
+
+	if objType := types[typ]; objType != nil {
+		result, ok := e.selectionSet(objType, v, selections, path)
+		if !ok {
+			return nil, !nonNull
+		}
+		return result, true
+	}
+	return v, true
+}
+
+// isNull reports whether v is nil or a nil pointer
+func isNull(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// object is a response object, keeping its fields in query order
+type object struct {
+	keys   []string
+	values []any
+}
+
+func (o *object) set(key string, v any) {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, v)
+}
+
+// MarshalJSON writes the fields in query order
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,185 @@
+package graphql
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name:         "shop",
+		Capabilities: &dbinfo.Capabilities{ServerVersion: 160002},
+		Tables: []*dbinfo.Table{
+			{
+				Schema:  "public",
+				Name:    "customers",
+				Comment: "People buying",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "email", Type: "text", IsNullable: true},
+				},
+				HasMany: []*dbinfo.Relationship{
+					{Name: "orders", Schema: "public", Table: "orders", ForeignKey: "orders_customer_id_fkey", Columns: []string{"customer_id"}, References: []string{"id"}},
+					{Name: "invoices", Schema: "billing", Table: "invoices", ForeignKey: "invoices_customer_id_fkey", Columns: []string{"customer_id"}, References: []string{"id"}},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer"},
+				},
+				PrimaryKey:  &dbinfo.PrimaryKey{Name: "orders_pkey", Columns: []string{"id"}},
+				ForeignKeys: []*dbinfo.ForeignKey{{Name: "orders_customer_id_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: "public", RefTableName: "customers", RefColumnNames: []string{"id"}, OnDelete: "CASCADE"}},
+			},
+			{Schema: "billing", Name: "invoices"},
+			{Schema: "archive", Name: "orders"},
+		},
+	}
+}
+
+// run executes query and returns the response as JSON
+func run(t *testing.T, query string, variables map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(Execute(testInfo(), &Request{Query: query, Variables: variables}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]any
+		expected  string
+	}{
+		{
+			"tables referencing customers",
+			`{ table(name: "customers") { hasMany { table { schema name } columns } } }`, nil,
+			`{"data":{"table":{"hasMany":[{"table":{"schema":"public","name":"orders"},"columns":["customer_id"]},{"table":{"schema":"billing","name":"invoices"},"columns":["customer_id"]}]}}}`,
+		},
+		{
+			"aliases, variables and filters",
+			`query Tables($schema: String = "public") {
+			  db: database { name serverVersion }
+			  tables(schema: $schema) { name }
+			}`, nil,
+			`{"data":{"db":{"name":"shop","serverVersion":160002},"tables":[{"name":"customers"},{"name":"orders"}]}}`,
+		},
+		{
+			"variables override defaults",
+			`query ($schema: String = "public", $name: String!) { tables(schema: $schema, name: $name) { schema } }`,
+			map[string]any{"schema": "archive", "name": "orders"},
+			`{"data":{"tables":[{"schema":"archive"}]}}`,
+		},
+		{
+			"fragments and directives",
+			`query ($details: Boolean!) {
+			  table(schema: "public", name: "orders") { ...Keys __typename comment @skip(if: true) columns @include(if: $details) { name } }
+			}
+			fragment Keys on Table {
+			  primaryKey { name columns }
+			  foreignKeys { name referencedTable { name } onDelete onUpdate }
+			  ... on Table { name }
+			}`,
+			map[string]any{"details": false},
+			`{"data":{"table":{"primaryKey":{"name":"orders_pkey","columns":["id"]},"foreignKeys":[{"name":"orders_customer_id_fkey","referencedTable":{"name":"customers"},"onDelete":"CASCADE","onUpdate":null}],"name":"orders","__typename":"Table"}}}`,
+		},
+		{
+			"merged selections",
+			`{ table(name: "customers") { column(name: "email") { name } column(name: "email") { nullable primaryKey } primaryKey { columns } } }`, nil,
+			`{"data":{"table":{"column":{"name":"email","nullable":true,"primaryKey":false},"primaryKey":{"columns":["id"]}}}}`,
+		},
+		{
+			"missing table",
+			`{ table(name: "payments") { name } }`, nil,
+			`{"data":{"table":null}}`,
+		},
+		{
+			"ambiguous table",
+			`{ table(name: "orders") { name } database { name } }`, nil,
+			`{"data":{"table":null,"database":{"name":"shop"}},"errors":[{"message":"table orders is in several schemas, pass one of: public, archive","locations":[{"line":1,"column":3}],"path":["table"]}]}`,
+		},
+		{
+			"undefined variables",
+			`{ tables(name: $missing) { name } }`, nil,
+			`{"errors":[{"message":"variable $missing is not defined by the operation","locations":[{"line":1,"column":3}]}]}`,
+		},
+		{
+			"invalid variables",
+			`query ($name: String!) { tables(name: $name) { name } }`, map[string]any{"name": 3.0},
+			`{"errors":[{"message":"variable $name: expected a value of type String, got 3"}]}`,
+		},
+		{
+			"unknown fields",
+			`{ tables { name size } database(verbose: true) { name } }`, nil,
+			`{"errors":[{"message":"cannot query field \"size\" on type Table","locations":[{"line":1,"column":17}]},{"message":"unknown argument \"verbose\" on field Query.database","locations":[{"line":1,"column":24}]}]}`,
+		},
+		{
+			"selection errors",
+			`{ database table { name } }`, nil,
+			`{"errors":[{"message":"field Query.database of type Database! needs a selection of subfields","locations":[{"line":1,"column":3}]},{"message":"field Query.table needs the argument name","locations":[{"line":1,"column":12}]}]}`,
+		},
+		{
+			"mutations",
+			`mutation { dropTable }`, nil,
+			`{"errors":[{"message":"syntax error: the schema is read-only, only queries are supported","locations":[{"line":1,"column":1}]}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.expected, run(t, test.query, test.variables)); diff != "" {
+				t.Errorf("Unexpected response (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOperationName(t *testing.T) {
+	query := `query A { database { name } } query B { tables { name } }`
+	if resp := Execute(testInfo(), &Request{Query: query}); len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0].Message, "operationName") {
+		t.Errorf("Expected an operationName error, got %v", resp.Errors)
+	}
+	resp := Execute(testInfo(), &Request{Query: query, OperationName: "A"})
+	if data, _ := json.Marshal(resp); string(data) != `{"data":{"database":{"name":"shop"}}}` {
+		t.Errorf("Unexpected response %s", data)
+	}
+	if resp := Execute(testInfo(), &Request{Query: query, OperationName: "C"}); len(resp.Errors) != 1 {
+		t.Errorf("Expected an unknown operation error, got %v", resp.Errors)
+	}
+}
+
+func TestFragmentCycle(t *testing.T) {
+	resp := Execute(testInfo(), &Request{Query: `{ tables { ...A } } fragment A on Table { name ...A }`})
+	if len(resp.Errors) != 1 || resp.Errors[0].Message != "fragment A spreads itself" || resp.Data != nil {
+		t.Errorf("Expected a fragment cycle error, got %v", resp.Errors)
+	}
+}
+
+func TestSDL(t *testing.T) {
+	sdl := SDL()
+	for _, expected := range []string{
+		"type Query {\n  database: Database!\n",
+		"  \"A table, by name alone when no other schema has a table with that name\"\n  table(schema: String, name: String!): Table\n",
+		"type Relationship {\n",
+	} {
+		if !strings.Contains(sdl, expected) {
+			t.Errorf("Expected %q in the SDL:\n%s", expected, sdl)
+		}
+	}
+	// Every field of the SDL can be queried
+	for _, typ := range objectTypes {
+		for _, f := range typ.fields {
+			if named := namedType(f.typ); types[named] == nil && named != "String" && named != "Int" && named != "Boolean" {
+				t.Errorf("Unknown type %s of %s.%s", named, typ.name, f.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,581 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed query document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+// operation is a query operation
+type operation struct {
+	name      string
+	variables []*variableDefinition
+	selection []selection
+}
+
+// variableDefinition declares a variable of an operation
+type variableDefinition struct {
+	name         string
+	typ          string
+	defaultValue value
+}
+
+// fragment is a named fragment definition
+type fragment struct {
+	name          string
+	typeCondition string
+	directives    []*directive
+	selection     []selection
+}
+
+// selection is a *field, a *fragmentSpread or an *inlineFragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	arguments  map[string]value
+	directives []*directive
+	selection  []selection
+	line       int
+	column     int
+}
+
+// responseKey returns the name of the field in the response
+func (f *field) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	typeCondition string
+	directives    []*directive
+	selection     []selection
+}
+
+type directive struct {
+	name      string
+	arguments map[string]value
+}
+
+// value is a literal or a variable: variable, string, int, float64, bool,
+// enumValue, []value, map[string]value, or nil for null
+type value any
+
+type variable string
+
+type enumValue string
+
+// tokenKind classifies the tokens of the lexer
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	line   int
+	column int
+}
+
+// parser is a recursive descent parser for query documents
+type parser struct {
+	src    string
+	pos    int
+	line   int
+	lineAt int // Offset of the start of the current line
+	tok    token
+}
+
+// parse parses a query document
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+
+	p := &parser{src: src, line: 1}
+	p.next()
+	doc = &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			doc.operations = append(doc.operations, &operation{selection: p.parseSelectionSet()})
+		case p.tok.kind == tokenName && p.tok.value == "query":
+			doc.operations = append(doc.operations, p.parseOperation())
+		case p.tok.kind == tokenName && (p.tok.value == "mutation" || p.tok.value == "subscription"):
+			p.fail("the schema is read-only, only queries are supported")
+		case p.tok.kind == tokenName && p.tok.value == "fragment":
+			f := p.parseFragment()
+			if doc.fragments[f.name] != nil {
+				p.fail(fmt.Sprintf("fragment %s is defined more than once", f.name))
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.fail("the document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) parseOperation() *operation {
+	p.expectName() // query
+	op := &operation{}
+	if p.tok.kind == tokenName {
+		op.name = p.expectName()
+	}
+	if p.skip("(") {
+		for !p.skip(")") {
+			p.expect("$")
+			v := &variableDefinition{name: p.expectName()}
+			p.expect(":")
+			v.typ = p.parseType()
+			if p.skip("=") {
+				v.defaultValue = p.parseValue(true)
+			}
+			op.variables = append(op.variables, v)
+		}
+	}
+	p.parseDirectives()
+	op.selection = p.parseSelectionSet()
+	return op
+}
+
+func (p *parser) parseFragment() *fragment {
+	p.expectName() // fragment
+	f := &fragment{name: p.expectName()}
+	if f.name == "on" {
+		p.fail("a fragment can't be named on")
+	}
+	p.expectKeyword("on")
+	f.typeCondition = p.expectName()
+	f.directives = p.parseDirectives()
+	f.selection = p.parseSelectionSet()
+	return f
+}
+
+// parseType parses a type reference, such as [String!]!, returning it as
+// written without spaces
+func (p *parser) parseType() string {
+	var typ string
+	if p.skip("[") {
+		typ = "[" + p.parseType() + "]"
+		p.expect("]")
+	} else {
+		typ = p.expectName()
+	}
+	if p.skip("!") {
+		typ += "!"
+	}
+	return typ
+}
+
+func (p *parser) parseSelectionSet() []selection {
+	open := p.tok
+	p.expect("{")
+	var selections []selection
+	for !p.skip("}") {
+		selections = append(selections, p.parseSelection())
+	}
+	if len(selections) == 0 {
+		p.failAt(open, "a selection set can't be empty")
+	}
+	return selections
+}
+
+func (p *parser) parseSelection() selection {
+	if p.skip("...") {
+		if p.tok.kind == tokenName && p.tok.value != "on" {
+			return &fragmentSpread{name: p.expectName(), directives: p.parseDirectives()}
+		}
+		f := &inlineFragment{}
+		if p.tok.kind == tokenName {
+			p.expectName() // on
+			f.typeCondition = p.expectName()
+		}
+		f.directives = p.parseDirectives()
+		f.selection = p.parseSelectionSet()
+		return f
+	}
+
+	f := &field{line: p.tok.line, column: p.tok.column}
+	f.name = p.expectName()
+	if p.skip(":") {
+		f.alias, f.name = f.name, p.expectName()
+	}
+	f.arguments = p.parseArguments(false)
+	f.directives = p.parseDirectives()
+	if p.peek("{") {
+		f.selection = p.parseSelectionSet()
+	}
+	return f
+}
+
+func (p *parser) parseArguments(constant bool) map[string]value {
+	if !p.skip("(") {
+		return nil
+	}
+	args := make(map[string]value)
+	for !p.skip(")") {
+		tok := p.tok
+		name := p.expectName()
+		if _, ok := args[name]; ok {
+			p.failAt(tok, fmt.Sprintf("argument %s is given more than once", name))
+		}
+		p.expect(":")
+		args[name] = p.parseValue(constant)
+	}
+	return args
+}
+
+func (p *parser) parseDirectives() []*directive {
+	var directives []*directive
+	for p.skip("@") {
+		d := &directive{name: p.expectName()}
+		d.arguments = p.parseArguments(false)
+		directives = append(directives, d)
+	}
+	return directives
+}
+
+// parseValue parses a value. Constant values, such as variable defaults,
+// can't hold variables.
+func (p *parser) parseValue(constant bool) value {
+	tok := p.tok
+	switch tok.kind {
+	case tokenPunctuator:
+		switch tok.value {
+		case "$":
+			if constant {
+				p.fail("a default value can't use variables")
+			}
+			p.next()
+			return variable(p.expectName())
+		case "[":
+			p.next()
+			list := []value{}
+			for !p.skip("]") {
+				list = append(list, p.parseValue(constant))
+			}
+			return list
+		case "{":
+			p.next()
+			object := make(map[string]value)
+			for !p.skip("}") {
+				name := p.expectName()
+				p.expect(":")
+				object[name] = p.parseValue(constant)
+			}
+			return object
+		}
+	case tokenInt:
+		p.next()
+		n, err := strconv.Atoi(tok.value)
+		if err != nil {
+			p.failAt(tok, fmt.Sprintf("integer %s is out of range", tok.value))
+		}
+		return n
+	case tokenFloat:
+		p.next()
+		f, _ := strconv.ParseFloat(tok.value, 64)
+		return f
+	case tokenString:
+		p.next()
+		return tok.value
+	case tokenName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enumValue(tok.value)
+	}
+	p.unexpected()
+	return nil
+}
+
+func (p *parser) peek(punctuator string) bool {
+	return p.tok.kind == tokenPunctuator && p.tok.value == punctuator
+}
+
+func (p *parser) skip(punctuator string) bool {
+	if p.peek(punctuator) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punctuator string) {
+	if !p.skip(punctuator) {
+		p.fail(fmt.Sprintf("expected %s, found %s", punctuator, p.describe()))
+	}
+}
+
+func (p *parser) expectName() string {
+	if p.tok.kind != tokenName {
+		p.fail("expected a name, found " + p.describe())
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+func (p *parser) expectKeyword(keyword string) {
+	if p.tok.kind != tokenName || p.tok.value != keyword {
+		p.fail(fmt.Sprintf("expected %s, found %s", keyword, p.describe()))
+	}
+	p.next()
+}
+
+func (p *parser) unexpected() {
+	p.fail("unexpected " + p.describe())
+}
+
+// describe names the current token in error messages
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokenEOF:
+		return "end of document"
+	case tokenString:
+		return strconv.Quote(p.tok.value)
+	}
+	return p.tok.value
+}
+
+func (p *parser) fail(message string) {
+	p.failAt(p.tok, message)
+}
+
+func (p *parser) failAt(tok token, message string) {
+	panic(&Error{Message: "syntax error: " + message, Locations: []Location{{tok.line, tok.column}}})
+}
+
+// next reads the next token, skipping white space, commas and comments
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			p.pos++
+			p.line++
+			p.lineAt = p.pos
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case strings.HasPrefix(p.src[p.pos:], "\ufeff"): // Byte order mark
+			p.pos += len("\ufeff")
+		default:
+			p.lex()
+			return
+		}
+	}
+	p.tok = token{kind: tokenEOF, line: p.line, column: p.pos - p.lineAt + 1}
+}
+
+// lex reads the token starting at the current position
+func (p *parser) lex() {
+	start := p.pos
+	p.tok = token{line: p.line, column: start - p.lineAt + 1}
+	c := p.src[start]
+	switch {
+	case strings.HasPrefix(p.src[start:], "..."):
+		p.pos += 3
+		p.tok.kind, p.tok.value = tokenPunctuator, "..."
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.tok.kind, p.tok.value = tokenPunctuator, string(c)
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.value = tokenName, p.src[start:p.pos]
+	case c == '-' || isDigit(c):
+		p.lexNumber()
+	case strings.HasPrefix(p.src[start:], `"""`):
+		p.lexBlockString()
+	case c == '"':
+		p.lexString()
+	default:
+		r, _ := utf8.DecodeRuneInString(p.src[start:])
+		p.tok.value = strconv.QuoteRune(r)
+		p.fail("unexpected character " + strconv.QuoteRune(r))
+	}
+}
+
+func (p *parser) lexNumber() {
+	start := p.pos
+	p.tok.kind = tokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		begin := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == begin {
+			p.fail("invalid number " + p.src[start:p.pos])
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.tok.kind = tokenFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.tok.kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok.value = p.src[start:p.pos]
+}
+
+func (p *parser) lexString() {
+	p.pos++ // Opening quote
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			p.tok.kind, p.tok.value = tokenString, b.String()
+			return
+		case '\\':
+			if p.pos+1 >= len(p.src) {
+				p.fail("unterminated string")
+			}
+			escape := p.src[p.pos+1]
+			p.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if p.pos+4 > len(p.src) {
+					p.fail("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+				if err != nil {
+					p.fail("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				p.pos += 4
+			default:
+				p.fail(fmt.Sprintf("invalid escape \\%c", escape))
+			}
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// lexBlockString reads a """ string, removing its common indentation and
+// its leading and trailing blank lines
+func (p *parser) lexBlockString() {
+	p.pos += 3
+	end := strings.Index(p.src[p.pos:], `"""`)
+	for end > 0 && p.src[p.pos+end-1] == '\\' {
+		next := strings.Index(p.src[p.pos+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		p.fail("unterminated block string")
+	}
+	raw := p.src[p.pos : p.pos+end]
+	p.pos += end + 3
+	p.line += strings.Count(raw, "\n")
+	if i := strings.LastIndexByte(raw, '\n'); i >= 0 {
+		p.lineAt = p.pos - end - 3 + i + 1
+	}
+
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(raw, `\"""`, `"""`), "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != "" && (indent < 0 || len(line)-len(trimmed) < indent) {
+			indent = len(line) - len(trimmed)
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	p.tok.kind, p.tok.value = tokenString, strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
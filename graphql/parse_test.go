@@ -0,0 +1,57 @@
+package graphql
+
+import (
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+		line     int
+		column   int
+	}{
+		{``, "syntax error: the document has no operation", 1, 1},
+		{`{ tables { name }`, "syntax error: expected a name, found end of document", 1, 18},
+		{"{\n  tables {}\n}", "syntax error: a selection set can't be empty", 2, 10},
+		{`{ table(name: "orders) { name } }`, "syntax error: unterminated string", 1, 15},
+		{`{ table(name: "a", name: "b") { name } }`, "syntax error: argument name is given more than once", 1, 20},
+		{`query ($a: String = $b) { tables { name } }`, "syntax error: a default value can't use variables", 1, 21},
+		{`{ tables { name } } ; `, "syntax error: unexpected character ';'", 1, 21},
+		{`fragment F on Table { name } fragment F on Table { name } { tables { ...F } }`, "syntax error: fragment F is defined more than once", 1, 59},
+	}
+	for _, test := range tests {
+		_, err := parse(test.query)
+		e, ok := err.(*Error)
+		if !ok || e.Message != test.expected || e.Locations[0] != (Location{test.line, test.column}) {
+			t.Errorf("parse(%q) = %v at %v, expected %q at %d:%d", test.query, err, e.Locations, test.expected, test.line, test.column)
+		}
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`
+		# Comments, commas and byte order marks are ignored
+		{ f(s: "a\"é\n", b: """
+		    Block
+		      string
+		""", i: -12, f: 1.5e3, t: true, n: null, e: ASC, l: [1, 2], o: {k: $v}) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := doc.operations[0].selection[0].(*field).arguments
+	expected := map[string]any{
+		"s": "a\"é\n", "b": "Block\n  string", "i": -12, "f": 1500.0, "t": true, "n": nil, "e": enumValue("ASC"),
+	}
+	for name, value := range expected {
+		if args[name] != value {
+			t.Errorf("Argument %s = %#v, expected %#v", name, args[name], value)
+		}
+	}
+	if l := args["l"].([]value); len(l) != 2 || l[1] != 2 {
+		t.Errorf("Unexpected list %#v", args["l"])
+	}
+	if o := args["o"].(map[string]value); o["k"] != variable("v") {
+		t.Errorf("Unexpected object %#v", args["o"])
+	}
+}
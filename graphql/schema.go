@@ -0,0 +1,275 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// objectType is an object type of the schema
+type objectType struct {
+	name        string
+	description string
+	fields      []*fieldDef
+}
+
+// field returns the field called name, or nil
+func (t *objectType) field(name string) *fieldDef {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// resolveFunc returns the value of a field of source
+type resolveFunc func(e *executor, source any, args map[string]any) (any, error)
+
+// fieldDef is a field of an object type
+type fieldDef struct {
+	name        string
+	description string
+	typ         string // Type reference, such as [Table!]!
+	args        []*argDef
+	resolve     resolveFunc
+}
+
+// arg returns the argument called name, or nil
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// argDef is an argument of a field
+type argDef struct {
+	name string
+	typ  string
+}
+
+// namedType returns the type a type reference wraps in lists and non-nulls
+func namedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+// get returns a resolver for a field read from a source of type T
+func get[T any](f func(T) any) resolveFunc {
+	return func(e *executor, source any, args map[string]any) (any, error) {
+		return f(source.(T)), nil
+	}
+}
+
+// optional returns s, or nil when it is empty
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+var (
+	queryType        = &objectType{name: "Query"}
+	databaseType     = &objectType{name: "Database", description: "The introspected database"}
+	tableType        = &objectType{name: "Table"}
+	columnType       = &objectType{name: "Column"}
+	primaryKeyType   = &objectType{name: "PrimaryKey"}
+	indexType        = &objectType{name: "Index"}
+	foreignKeyType   = &objectType{name: "ForeignKey"}
+	relationshipType = &objectType{name: "Relationship", description: "A foreign key seen from one of the tables it links"}
+)
+
+// objectTypes lists the object types of the schema, in the order of SDL
+var objectTypes = []*objectType{queryType, databaseType, tableType, columnType, primaryKeyType, indexType, foreignKeyType, relationshipType}
+
+// types are the object types of the schema, by name. Other named types are
+// scalars: String, Int and Boolean.
+var types = map[string]*objectType{}
+
+func init() {
+	queryType.fields = []*fieldDef{
+		{name: "database", typ: "Database!", resolve: func(e *executor, source any, args map[string]any) (any, error) {
+			return e.info, nil
+		}},
+		{
+			name: "tables", typ: "[Table!]!", description: "The tables, optionally of a schema or with a name",
+			args: []*argDef{{name: "schema", typ: "String"}, {name: "name", typ: "String"}},
+			resolve: func(e *executor, source any, args map[string]any) (any, error) {
+				tables := []*dbinfo.Table{}
+				for _, table := range e.info.Tables {
+					if (args["schema"] == nil || args["schema"] == table.Schema) && (args["name"] == nil || args["name"] == table.Name) {
+						tables = append(tables, table)
+					}
+				}
+				return tables, nil
+			},
+		},
+		{
+			name: "table", typ: "Table", description: "A table, by name alone when no other schema has a table with that name",
+			args: []*argDef{{name: "schema", typ: "String"}, {name: "name", typ: "String!"}},
+			resolve: func(e *executor, source any, args map[string]any) (any, error) {
+				name := args["name"].(string)
+				if schema, ok := args["schema"].(string); ok {
+					return e.tables[dbinfo.ObjectKey{Schema: schema, Name: name}], nil
+				}
+				var found []*dbinfo.Table
+				for _, table := range e.info.Tables {
+					if table.Name == name {
+						found = append(found, table)
+					}
+				}
+				switch len(found) {
+				case 0:
+					return nil, nil
+				case 1:
+					return found[0], nil
+				}
+				schemas := make([]string, len(found))
+				for i, table := range found {
+					schemas[i] = table.Schema
+				}
+				return nil, fmt.Errorf("table %s is in several schemas, pass one of: %s", name, strings.Join(schemas, ", "))
+			},
+		},
+	}
+
+	databaseType.fields = []*fieldDef{
+		{name: "name", typ: "String!", resolve: get(func(d *dbinfo.DBInfo) any { return d.Name })},
+		{name: "generator", typ: "String", description: "The dbinfo build that introspected the database", resolve: get(func(d *dbinfo.DBInfo) any { return optional(d.Generator) })},
+		{name: "serverVersion", typ: "Int", description: "The server version, as server_version_num", resolve: get(func(d *dbinfo.DBInfo) any {
+			if d.Capabilities == nil {
+				return nil
+			}
+			return d.Capabilities.ServerVersion
+		})},
+		{name: "tables", typ: "[Table!]!", resolve: get(func(d *dbinfo.DBInfo) any { return d.Tables })},
+	}
+
+	tableType.fields = []*fieldDef{
+		{name: "schema", typ: "String!", resolve: get(func(t *dbinfo.Table) any { return t.Schema })},
+		{name: "name", typ: "String!", resolve: get(func(t *dbinfo.Table) any { return t.Name })},
+		{name: "comment", typ: "String", resolve: get(func(t *dbinfo.Table) any { return optional(t.Comment) })},
+		{name: "columns", typ: "[Column!]!", resolve: get(func(t *dbinfo.Table) any { return t.Columns })},
+		{
+			name: "column", typ: "Column", args: []*argDef{{name: "name", typ: "String!"}},
+			resolve: func(e *executor, source any, args map[string]any) (any, error) {
+				for _, column := range source.(*dbinfo.Table).Columns {
+					if column.Name == args["name"] {
+						return column, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+		{name: "primaryKey", typ: "PrimaryKey", resolve: get(func(t *dbinfo.Table) any {
+			if t.PrimaryKey != nil {
+				return t.PrimaryKey
+			}
+			// Snapshots without the constraint still flag the columns
+			pk := &dbinfo.PrimaryKey{}
+			for _, column := range t.Columns {
+				if column.IsPrimaryKey {
+					pk.Columns = append(pk.Columns, column.Name)
+				}
+			}
+			if len(pk.Columns) == 0 {
+				return nil
+			}
+			return pk
+		})},
+		{name: "indexes", typ: "[Index!]!", resolve: get(func(t *dbinfo.Table) any { return t.Indexes })},
+		{name: "foreignKeys", typ: "[ForeignKey!]!", resolve: get(func(t *dbinfo.Table) any { return t.ForeignKeys })},
+		{name: "belongsTo", typ: "[Relationship!]!", description: "The tables this table references", resolve: get(func(t *dbinfo.Table) any { return t.BelongsTo })},
+		{name: "hasMany", typ: "[Relationship!]!", description: "The tables referencing this table", resolve: get(func(t *dbinfo.Table) any { return t.HasMany })},
+	}
+
+	columnType.fields = []*fieldDef{
+		{name: "name", typ: "String!", resolve: get(func(c *dbinfo.Column) any { return c.Name })},
+		{name: "type", typ: "String!", resolve: get(func(c *dbinfo.Column) any { return c.Type })},
+		{name: "fullType", typ: "String", description: "The type with its modifiers, such as character varying(100)", resolve: get(func(c *dbinfo.Column) any { return optional(c.FullType) })},
+		{name: "nullable", typ: "Boolean!", resolve: get(func(c *dbinfo.Column) any { return c.IsNullable })},
+		{name: "default", typ: "String", resolve: get(func(c *dbinfo.Column) any { return optional(c.DefaultValue) })},
+		{name: "kind", typ: "String", description: "plain, default, serial or identity", resolve: get(func(c *dbinfo.Column) any { return optional(string(c.Kind)) })},
+		{name: "primaryKey", typ: "Boolean!", resolve: get(func(c *dbinfo.Column) any { return c.IsPrimaryKey })},
+		{name: "comment", typ: "String", resolve: get(func(c *dbinfo.Column) any { return optional(c.Comment) })},
+	}
+
+	primaryKeyType.fields = []*fieldDef{
+		{name: "name", typ: "String", resolve: get(func(pk *dbinfo.PrimaryKey) any { return optional(pk.Name) })},
+		{name: "columns", typ: "[String!]!", resolve: get(func(pk *dbinfo.PrimaryKey) any { return pk.Columns })},
+	}
+
+	indexType.fields = []*fieldDef{
+		{name: "name", typ: "String!", resolve: get(func(i *dbinfo.Index) any { return i.Name })},
+		{name: "unique", typ: "Boolean!", resolve: get(func(i *dbinfo.Index) any { return i.Unique })},
+		{name: "columns", typ: "[String!]!", resolve: get(func(i *dbinfo.Index) any { return i.Columns })},
+		{name: "expression", typ: "String", resolve: get(func(i *dbinfo.Index) any { return optional(i.Expression) })},
+		{name: "nullsNotDistinct", typ: "Boolean!", resolve: get(func(i *dbinfo.Index) any { return i.NullsNotDistinct })},
+	}
+
+	foreignKeyType.fields = []*fieldDef{
+		{name: "name", typ: "String!", resolve: get(func(fk *dbinfo.ForeignKey) any { return fk.Name })},
+		{name: "columns", typ: "[String!]!", resolve: get(func(fk *dbinfo.ForeignKey) any { return fk.ColumnNames })},
+		{name: "referencedSchema", typ: "String!", resolve: get(func(fk *dbinfo.ForeignKey) any { return fk.RefTableSchema })},
+		{name: "referencedTableName", typ: "String!", resolve: get(func(fk *dbinfo.ForeignKey) any { return fk.RefTableName })},
+		{name: "referencedTable", typ: "Table", description: "Null when the referenced table wasn't introspected", resolve: func(e *executor, source any, args map[string]any) (any, error) {
+			fk := source.(*dbinfo.ForeignKey)
+			return e.tables[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}], nil
+		}},
+		{name: "referencedColumns", typ: "[String!]!", resolve: get(func(fk *dbinfo.ForeignKey) any { return fk.RefColumnNames })},
+		{name: "onUpdate", typ: "String", resolve: get(func(fk *dbinfo.ForeignKey) any { return optional(fk.OnUpdate) })},
+		{name: "onDelete", typ: "String", resolve: get(func(fk *dbinfo.ForeignKey) any { return optional(fk.OnDelete) })},
+	}
+
+	relationshipType.fields = []*fieldDef{
+		{name: "name", typ: "String!", description: "The association name, unique in the table", resolve: get(func(r *dbinfo.Relationship) any { return r.Name })},
+		{name: "schema", typ: "String!", description: "The schema of the related table", resolve: get(func(r *dbinfo.Relationship) any { return r.Schema })},
+		{name: "tableName", typ: "String!", description: "The name of the related table", resolve: get(func(r *dbinfo.Relationship) any { return r.Table })},
+		{name: "table", typ: "Table", description: "The related table", resolve: func(e *executor, source any, args map[string]any) (any, error) {
+			r := source.(*dbinfo.Relationship)
+			return e.tables[dbinfo.ObjectKey{Schema: r.Schema, Name: r.Table}], nil
+		}},
+		{name: "foreignKey", typ: "String!", resolve: get(func(r *dbinfo.Relationship) any { return r.ForeignKey })},
+		{name: "columns", typ: "[String!]!", description: "The columns of the referencing table", resolve: get(func(r *dbinfo.Relationship) any { return r.Columns })},
+		{name: "references", typ: "[String!]!", description: "The columns of the referenced table", resolve: get(func(r *dbinfo.Relationship) any { return r.References })},
+		{name: "onDelete", typ: "String", resolve: get(func(r *dbinfo.Relationship) any { return optional(r.OnDelete) })},
+	}
+
+	for _, t := range objectTypes {
+		types[t.name] = t
+	}
+}
+
+// SDL returns the schema in the GraphQL schema definition language
+func SDL() string {
+	var b strings.Builder
+	for i, t := range objectTypes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if t.description != "" {
+			fmt.Fprintf(&b, "%q\n", t.description)
+		}
+		fmt.Fprintf(&b, "type %s {\n", t.name)
+		for _, f := range t.fields {
+			if f.description != "" {
+				fmt.Fprintf(&b, "  %q\n", f.description)
+			}
+			b.WriteString("  " + f.name)
+			if len(f.args) > 0 {
+				args := make([]string, len(f.args))
+				for i, a := range f.args {
+					args[i] = a.name + ": " + a.typ
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.typ + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
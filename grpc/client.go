@@ -0,0 +1,181 @@
+// Package grpc is the Go client of the dbinfo schema service, defined in
+// dbinfo.proto and served by 'dbinfo grpc-serve'. The service returns
+// snapshots of the schema and their drift from reference snapshots.
+//
+// The package only depends on the standard library, so services consuming
+// schema metadata don't link the PostgreSQL driver: snapshots come back as
+// documents, which snapshot.Parse decodes into a dbinfo.DBInfo when needed.
+// Messages are encoded by hand and calls go over HTTP/2, without TLS for
+// host:port and http:// targets.
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceName is the full name of the service in dbinfo.proto
+const ServiceName = "dbinfo.v1.Schema"
+
+// ContentType is the content type of gRPC requests and responses
+const ContentType = "application/grpc+proto"
+
+// MaxMessageSize limits the size of the messages read by the client and the
+// server
+const MaxMessageSize = 64 << 20
+
+// ClientOption configures a Client
+type ClientOption func(*Client)
+
+// WithToken sends "authorization: Bearer <token>" with every call
+func WithToken(token string) ClientOption {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient makes calls with client, which must speak HTTP/2, instead
+// of the default one
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of https:// targets
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// Client calls the schema service
+type Client struct {
+	baseURL    string
+	token      string
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+}
+
+// NewClient returns a client of the service at target: host:port, or an
+// http:// or https:// URL
+func NewClient(target string, opts ...ClientOption) (*Client, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return nil, fmt.Errorf("invalid target %q, expected host:port or an http:// or https:// URL", target)
+	}
+	c := &Client{baseURL: strings.TrimSuffix(target, "/")}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.httpClient == nil {
+		protocols := new(http.Protocols)
+		if strings.HasPrefix(c.baseURL, "https://") {
+			protocols.SetHTTP2(true)
+		} else {
+			protocols.SetUnencryptedHTTP2(true)
+		}
+		c.httpClient = &http.Client{Transport: &http.Transport{Protocols: protocols, TLSClientConfig: c.tlsConfig}}
+	}
+	return c, nil
+}
+
+// GetSnapshot returns the schema
+func (c *Client) GetSnapshot(ctx context.Context, req *GetSnapshotRequest) (*Snapshot, error) {
+	resp := &Snapshot{}
+	if err := c.invoke(ctx, "GetSnapshot", req.Marshal(), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Diff returns the changes from a snapshot to the schema
+func (c *Client) Diff(ctx context.Context, req *DiffRequest) (*DiffResponse, error) {
+	resp := &DiffResponse{}
+	if err := c.invoke(ctx, "Diff", req.Marshal(), resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// invoke makes a unary call, decoding the response message into resp.
+// Failures are returned as *Status.
+func (c *Client) invoke(ctx context.Context, method string, req []byte, resp interface{ Unmarshal([]byte) error }) error {
+	var body bytes.Buffer
+	WriteMessage(&body, req)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+ServiceName+"/"+method, &body)
+	if err != nil {
+		return Errorf(Internal, "%v", err)
+	}
+	httpReq.Header.Set("Content-Type", ContentType)
+	httpReq.Header.Set("TE", "trailers")
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := max(time.Until(deadline).Milliseconds(), 1)
+		httpReq.Header.Set("Grpc-Timeout", strconv.FormatInt(timeout, 10)+"m")
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return Errorf(DeadlineExceeded, "%v", err)
+		} else if ctx.Err() != nil {
+			return Errorf(Canceled, "%v", err)
+		}
+		return Errorf(Unavailable, "%v", err)
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return Errorf(Unavailable, "unexpected HTTP status %s", httpResp.Status)
+	}
+	// Trailers-only responses carry the status in the headers
+	if st := statusFromHeader(httpResp.Header); st != nil {
+		if st.Code == OK {
+			return Errorf(Internal, "response without a message")
+		}
+		return st
+	}
+
+	m, err := ReadMessage(httpResp.Body, MaxMessageSize)
+	if err != nil {
+		if err == io.EOF {
+			err = nil
+		}
+		if st := readTrailer(httpResp); st != nil && st.Code != OK {
+			return st
+		}
+		if err == nil {
+			return Errorf(Internal, "response without a message")
+		}
+		return Errorf(Internal, "reading response: %v", err)
+	}
+	st := readTrailer(httpResp)
+	if st == nil {
+		return Errorf(Internal, "response without a status")
+	}
+	if st.Code != OK {
+		return st
+	}
+	if err := resp.Unmarshal(m); err != nil {
+		return Errorf(Internal, "decoding response: %v", err)
+	}
+	return nil
+}
+
+// readTrailer reads the rest of the response and returns the status in
+// its trailers
+func readTrailer(resp *http.Response) *Status {
+	io.Copy(io.Discard, resp.Body)
+	return statusFromHeader(resp.Trailer)
+}
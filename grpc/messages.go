@@ -0,0 +1,209 @@
+package grpc
+
+import "fmt"
+
+// The messages of dbinfo.proto. They are encoded by hand, so the package
+// has no dependencies; keep them in sync with the proto file.
+
+// Format is the encoding of a snapshot document
+type Format int32
+
+// Snapshot formats
+const (
+	FormatJSON Format = 0
+	FormatYAML Format = 1
+)
+
+// String returns the name of the format, as dbinfo -format takes it
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	}
+	return fmt.Sprintf("Format(%d)", int32(f))
+}
+
+// GetSnapshotRequest asks for the schema
+type GetSnapshotRequest struct {
+	Format  Format
+	Refresh bool // Introspect the database again instead of using the cached schema
+}
+
+// Marshal encodes the request
+func (m *GetSnapshotRequest) Marshal() []byte {
+	var e encoder
+	e.varint(1, uint64(m.Format))
+	e.bool(2, m.Refresh)
+	return e
+}
+
+// Unmarshal decodes the request
+func (m *GetSnapshotRequest) Unmarshal(data []byte) error {
+	*m = GetSnapshotRequest{}
+	return decode(data, func(field, wireType int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			m.Format = Format(v)
+			return checkWireType(field, wireType, wireVarint)
+		case 2:
+			m.Refresh = v != 0
+			return checkWireType(field, wireType, wireVarint)
+		}
+		return nil
+	})
+}
+
+// Snapshot is the schema of a database, as a snapshot document readable by
+// dbinfo diff, lint and gen, and by snapshot.Parse
+type Snapshot struct {
+	Database string
+	Format   Format
+	Data     []byte
+}
+
+// Marshal encodes the snapshot
+func (m *Snapshot) Marshal() []byte {
+	var e encoder
+	e.string(1, m.Database)
+	e.varint(2, uint64(m.Format))
+	e.bytes(3, m.Data)
+	return e
+}
+
+// Unmarshal decodes the snapshot
+func (m *Snapshot) Unmarshal(data []byte) error {
+	*m = Snapshot{}
+	return decode(data, func(field, wireType int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			m.Database = string(b)
+			return checkWireType(field, wireType, wireBytes)
+		case 2:
+			m.Format = Format(v)
+			return checkWireType(field, wireType, wireVarint)
+		case 3:
+			m.Data = append([]byte(nil), b...)
+			return checkWireType(field, wireType, wireBytes)
+		}
+		return nil
+	})
+}
+
+// DiffRequest asks for the changes from a snapshot to the schema. Set
+// either Against, naming a snapshot loaded by the server, or Snapshot, a
+// YAML or JSON snapshot document.
+type DiffRequest struct {
+	Against  string
+	Snapshot []byte
+	Refresh  bool
+}
+
+// Marshal encodes the request
+func (m *DiffRequest) Marshal() []byte {
+	var e encoder
+	e.string(1, m.Against)
+	e.bytes(2, m.Snapshot)
+	e.bool(3, m.Refresh)
+	return e
+}
+
+// Unmarshal decodes the request. As with any oneof, the last of against
+// and snapshot wins.
+func (m *DiffRequest) Unmarshal(data []byte) error {
+	*m = DiffRequest{}
+	return decode(data, func(field, wireType int, v uint64, b []byte) error {
+		switch field {
+		case 1:
+			m.Against, m.Snapshot = string(b), nil
+			return checkWireType(field, wireType, wireBytes)
+		case 2:
+			m.Against, m.Snapshot = "", append([]byte(nil), b...)
+			return checkWireType(field, wireType, wireBytes)
+		case 3:
+			m.Refresh = v != 0
+			return checkWireType(field, wireType, wireVarint)
+		}
+		return nil
+	})
+}
+
+// DiffResponse lists the changes from a snapshot to the schema
+type DiffResponse struct {
+	Changes []*Change
+}
+
+// Marshal encodes the response
+func (m *DiffResponse) Marshal() []byte {
+	var e encoder
+	for _, change := range m.Changes {
+		e.message(1, change.Marshal())
+	}
+	return e
+}
+
+// Unmarshal decodes the response
+func (m *DiffResponse) Unmarshal(data []byte) error {
+	*m = DiffResponse{}
+	return decode(data, func(field, wireType int, v uint64, b []byte) error {
+		if field != 1 {
+			return nil
+		}
+		if err := checkWireType(field, wireType, wireBytes); err != nil {
+			return err
+		}
+		change := &Change{}
+		if err := change.Unmarshal(b); err != nil {
+			return fmt.Errorf("change %d: %w", len(m.Changes)+1, err)
+		}
+		m.Changes = append(m.Changes, change)
+		return nil
+	})
+}
+
+// Change is a single difference between two schemas, as diff.Change
+// describes it
+type Change struct {
+	Type     string
+	Kind     string
+	Schema   string
+	Table    string
+	Name     string
+	Field    string
+	From     string
+	To       string
+	Breaking bool
+}
+
+// Marshal encodes the change
+func (m *Change) Marshal() []byte {
+	var e encoder
+	e.string(1, m.Type)
+	e.string(2, m.Kind)
+	e.string(3, m.Schema)
+	e.string(4, m.Table)
+	e.string(5, m.Name)
+	e.string(6, m.Field)
+	e.string(7, m.From)
+	e.string(8, m.To)
+	e.bool(9, m.Breaking)
+	return e
+}
+
+// Unmarshal decodes the change
+func (m *Change) Unmarshal(data []byte) error {
+	*m = Change{}
+	strings := []*string{1: &m.Type, &m.Kind, &m.Schema, &m.Table, &m.Name, &m.Field, &m.From, &m.To}
+	return decode(data, func(field, wireType int, v uint64, b []byte) error {
+		switch {
+		case field >= 1 && field <= 8:
+			*strings[field] = string(b)
+			return checkWireType(field, wireType, wireBytes)
+		case field == 9:
+			m.Breaking = v != 0
+			return checkWireType(field, wireType, wireVarint)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,87 @@
+package grpc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMessages(t *testing.T) {
+	type message interface {
+		Marshal() []byte
+		Unmarshal([]byte) error
+	}
+	tests := []struct {
+		in, out message
+	}{
+		{&GetSnapshotRequest{Format: FormatYAML, Refresh: true}, &GetSnapshotRequest{}},
+		{&GetSnapshotRequest{}, &GetSnapshotRequest{}},
+		{&Snapshot{Database: "shop", Format: FormatYAML, Data: []byte("name: shop\n")}, &Snapshot{}},
+		{&DiffRequest{Against: "release", Refresh: true}, &DiffRequest{}},
+		{&DiffRequest{Snapshot: []byte("{}")}, &DiffRequest{}},
+		{&DiffResponse{Changes: []*Change{
+			{Type: "added", Kind: "column", Schema: "public", Table: "orders", Name: "note"},
+			{},
+			{Type: "modified", Kind: "column", Schema: "public", Table: "orders", Name: "total", Field: "type", From: "integer", To: "bigint", Breaking: true},
+		}}, &DiffResponse{}},
+	}
+	for _, test := range tests {
+		if err := test.out.Unmarshal(test.in.Marshal()); err != nil {
+			t.Errorf("Unmarshal(%+v): %v", test.in, err)
+			continue
+		}
+		if diff := cmp.Diff(test.in, test.out); diff != "" {
+			t.Errorf("Unexpected round trip (-expected +actual):\n%s", diff)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	// As protoc encodes Snapshot{database: "a", format: FORMAT_YAML, data: "b"}
+	expected := []byte{0x0a, 0x01, 'a', 0x10, 0x01, 0x1a, 0x01, 'b'}
+	if actual := (&Snapshot{Database: "a", Format: FormatYAML, Data: []byte("b")}).Marshal(); !bytes.Equal(actual, expected) {
+		t.Errorf("Marshal() = %x, expected %x", actual, expected)
+	}
+
+	// Unknown fields of any wire type are skipped
+	withUnknown := append([]byte{0x20, 0x96, 0x01, 0x29, 1, 2, 3, 4, 5, 6, 7, 8, 0x35, 1, 2, 3, 4, 0x7a, 0x00}, expected...)
+	var m Snapshot
+	if err := m.Unmarshal(withUnknown); err != nil || m.Database != "a" || string(m.Data) != "b" {
+		t.Errorf("Unmarshal() = %+v, %v", m, err)
+	}
+
+	for _, invalid := range [][]byte{{0x0a, 0x05, 'a'}, {0x08}, {0x0b}, {0x10, 0x80}, {0x00, 0x01}, {0x12, 0x01, 'x'}} {
+		if err := m.Unmarshal(invalid); err == nil {
+			t.Errorf("Expected an error decoding %x", invalid)
+		}
+	}
+}
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte{0, 0, 0, 0, 3, 'a', 'b', 'c'}) {
+		t.Errorf("Unexpected frame %x", buf.Bytes())
+	}
+	if m, err := ReadMessage(bytes.NewReader(buf.Bytes()), 3); err != nil || string(m) != "abc" {
+		t.Errorf("ReadMessage() = %q, %v", m, err)
+	}
+	if _, err := ReadMessage(bytes.NewReader(buf.Bytes()), 2); err == nil {
+		t.Error("Expected messages over the limit to fail")
+	}
+	if _, err := ReadMessage(bytes.NewReader([]byte{1, 0, 0, 0, 0}), 2); err == nil {
+		t.Error("Expected compressed messages to fail")
+	}
+}
+
+func TestStatusMessage(t *testing.T) {
+	message := "table \"ça\" not found: 100%\n"
+	if encoded := encodeMessage(message); encoded != `table "%C3%A7a" not found: 100%25%0A` {
+		t.Errorf("encodeMessage() = %s", encoded)
+	} else if decoded := decodeMessage(encoded); decoded != message {
+		t.Errorf("decodeMessage() = %q", decoded)
+	}
+}
@@ -0,0 +1,234 @@
+// Package service implements the dbinfo.v1.Schema gRPC service of
+// grpc/dbinfo.proto as an http.Handler. The handler speaks gRPC over
+// HTTP/2, so the http.Server serving it must enable HTTP/2: with TLS, or
+// without it through http.Protocols.SetUnencryptedHTTP2.
+//
+// Introspection results are cached for a configurable time; requests with
+// refresh set reload them immediately.
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/grpc"
+	"github.com/guillermo/dbinfo/internal/cache"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithCacheTTL sets how long introspection results are reused. Zero loads
+// the schema on every call. Defaults to one minute.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.ttl = ttl
+	}
+}
+
+// WithToken requires calls to send "authorization: Bearer <token>"
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithSnapshot makes a snapshot available to Diff calls against name
+func WithSnapshot(name string, info *dbinfo.DBInfo) Option {
+	return func(s *Server) {
+		s.snapshots[name] = info
+	}
+}
+
+// Server is an http.Handler serving the schema service
+type Server struct {
+	cache     *cache.Schema
+	ttl       time.Duration
+	token     string
+	snapshots map[string]*dbinfo.DBInfo
+}
+
+// New returns a server introspecting the schema with load
+func New(load dbinfo.Loader, opts ...Option) *Server {
+	s := &Server{
+		ttl:       time.Minute,
+		snapshots: make(map[string]*dbinfo.DBInfo),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cache = cache.New(load, s.ttl)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "dbinfo serves gRPC over HTTP/2 only", http.StatusUnsupportedMediaType)
+		return
+	}
+	service, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if service != grpc.ServiceName {
+		grpc.WriteStatus(w, false, grpc.Errorf(grpc.Unimplemented, "unknown service %s", service))
+		return
+	}
+	if s.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			grpc.WriteStatus(w, false, grpc.Errorf(grpc.Unauthenticated, "missing or invalid token"))
+			return
+		}
+	}
+
+	ctx := r.Context()
+	if timeout, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, err := grpc.ReadMessage(r.Body, grpc.MaxMessageSize)
+	if err != nil {
+		grpc.WriteStatus(w, false, grpc.Errorf(grpc.InvalidArgument, "reading request: %v", err))
+		return
+	}
+
+	var resp []byte
+	var st *grpc.Status
+	switch method {
+	case "GetSnapshot":
+		resp, st = s.getSnapshot(ctx, req)
+	case "Diff":
+		resp, st = s.diff(ctx, req)
+	default:
+		st = grpc.Errorf(grpc.Unimplemented, "unknown method %s", method)
+	}
+	if st != nil {
+		grpc.WriteStatus(w, false, st)
+		return
+	}
+	w.Header().Set("Content-Type", grpc.ContentType)
+	grpc.WriteMessage(w, resp)
+	grpc.WriteStatus(w, true, nil)
+}
+
+func (s *Server) getSnapshot(ctx context.Context, data []byte) ([]byte, *grpc.Status) {
+	var req grpc.GetSnapshotRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "decoding request: %v", err)
+	}
+	write := map[grpc.Format]func(io.Writer, *dbinfo.DBInfo) error{
+		grpc.FormatJSON: snapshot.WriteJSON,
+		grpc.FormatYAML: snapshot.WriteYAML,
+	}[req.Format]
+	if write == nil {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "unknown format %s", req.Format)
+	}
+	info, st := s.schema(ctx, req.Refresh)
+	if st != nil {
+		return nil, st
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, info); err != nil {
+		return nil, grpc.Errorf(grpc.Internal, "encoding snapshot: %v", err)
+	}
+	resp := &grpc.Snapshot{Database: info.Name, Format: req.Format, Data: buf.Bytes()}
+	return resp.Marshal(), nil
+}
+
+func (s *Server) diff(ctx context.Context, data []byte) ([]byte, *grpc.Status) {
+	var req grpc.DiffRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "decoding request: %v", err)
+	}
+	var from *dbinfo.DBInfo
+	switch {
+	case len(req.Snapshot) > 0:
+		var err error
+		if from, err = snapshot.Parse(req.Snapshot); err != nil {
+			return nil, grpc.Errorf(grpc.InvalidArgument, "%v", err)
+		}
+	case s.snapshots[req.Against] != nil:
+		from = s.snapshots[req.Against]
+	default:
+		return nil, grpc.Errorf(grpc.NotFound, "unknown snapshot %q, expected one of: %s", req.Against, strings.Join(s.snapshotNames(), ", "))
+	}
+
+	to, st := s.schema(ctx, req.Refresh)
+	if st != nil {
+		return nil, st
+	}
+	resp := &grpc.DiffResponse{}
+	for _, change := range diff.Compare(from, to) {
+		resp.Changes = append(resp.Changes, &grpc.Change{
+			Type:     string(change.Type),
+			Kind:     string(change.Kind),
+			Schema:   change.Schema,
+			Table:    change.Table,
+			Name:     change.Name,
+			Field:    change.Field,
+			From:     change.From,
+			To:       change.To,
+			Breaking: change.Breaking,
+		})
+	}
+	return resp.Marshal(), nil
+}
+
+// schema returns the cached schema, loading it when it is missing, expired
+// or a refresh is requested
+func (s *Server) schema(ctx context.Context, refresh bool) (*dbinfo.DBInfo, *grpc.Status) {
+	info, err := s.cache.Get(ctx, refresh)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, grpc.Errorf(grpc.DeadlineExceeded, "getting database info: %v", err)
+		}
+		return nil, grpc.Errorf(grpc.Unavailable, "getting database info: %v", err)
+	}
+	return info, nil
+}
+
+// snapshotNames returns the sorted names of the configured snapshots
+func (s *Server) snapshotNames() []string {
+	names := make([]string, 0, len(s.snapshots))
+	for name := range s.snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseTimeout parses a grpc-timeout header, such as 100m or 5S
+func parseTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	unit := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}[value[len(value)-1]]
+	if unit == 0 || n > math.MaxInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
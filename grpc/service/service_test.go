@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/grpc"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{{
+			Schema:  "public",
+			Name:    "orders",
+			Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}, {Name: "total", Type: "bigint"}},
+		}},
+	}
+}
+
+// start serves s over unencrypted HTTP/2 and returns a client of it
+func start(t *testing.T, s *Server, opts ...grpc.ClientOption) *grpc.Client {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(s)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	client, err := grpc.NewClient(ts.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestGetSnapshot(t *testing.T) {
+	var calls int
+	s := New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		calls++
+		return testInfo(), nil
+	})
+	client := start(t, s)
+
+	for _, format := range []grpc.Format{grpc.FormatJSON, grpc.FormatYAML} {
+		resp, err := client.GetSnapshot(context.Background(), &grpc.GetSnapshotRequest{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Database != "shop" || resp.Format != format {
+			t.Errorf("Unexpected snapshot %s in %s", resp.Database, resp.Format)
+		}
+		info, err := snapshot.Parse(resp.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(testInfo(), info, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("Unexpected %s snapshot (-expected +actual):\n%s", format, diff)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the schema to be cached, loaded %d times", calls)
+	}
+	if _, err := client.GetSnapshot(context.Background(), &grpc.GetSnapshotRequest{Refresh: true}); err != nil || calls != 2 {
+		t.Errorf("Expected refresh to reload the schema, loaded %d times: %v", calls, err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	release := testInfo()
+	release.Tables[0].Columns[1].Type = "integer"
+	client := start(t, New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return testInfo(), nil
+	}, WithSnapshot("release", release)))
+
+	expected := &grpc.DiffResponse{Changes: []*grpc.Change{{
+		Type: "modified", Kind: "column", Schema: "public", Table: "orders", Name: "total", Field: "type", From: "integer", To: "bigint", Breaking: true,
+	}}}
+	resp, err := client.Diff(context.Background(), &grpc.DiffRequest{Against: "release"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(expected, resp); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+
+	yaml := `{"name": "shop", "tables": [{"schema": "public", "name": "orders", "columns": [{"name": "id", "type": "integer", "isprimarykey": true}, {"name": "total", "type": "integer"}]}]}`
+	if resp, err := client.Diff(context.Background(), &grpc.DiffRequest{Snapshot: []byte(yaml)}); err != nil {
+		t.Error(err)
+	} else if diff := cmp.Diff(expected, resp); diff != "" {
+		t.Errorf("Unexpected changes from a posted snapshot (-expected +actual):\n%s", diff)
+	}
+
+	_, err = client.Diff(context.Background(), &grpc.DiffRequest{Against: "nightly"})
+	if st, ok := err.(*grpc.Status); !ok || st.Code != grpc.NotFound || !strings.Contains(st.Message, `unknown snapshot "nightly", expected one of: release`) {
+		t.Errorf("Expected a NotFound status, got %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return nil, errors.New("connection refused")
+	}, WithToken("secret"))
+
+	_, err := start(t, s).GetSnapshot(context.Background(), &grpc.GetSnapshotRequest{})
+	if st, ok := err.(*grpc.Status); !ok || st.Code != grpc.Unauthenticated {
+		t.Errorf("Expected an Unauthenticated status, got %v", err)
+	}
+
+	_, err = start(t, s, grpc.WithToken("secret")).GetSnapshot(context.Background(), &grpc.GetSnapshotRequest{})
+	if st, ok := err.(*grpc.Status); !ok || st.Code != grpc.Unavailable || st.Message != "getting database info: connection refused" {
+		t.Errorf("Expected an Unavailable status, got %v", err)
+	}
+
+	_, err = start(t, s, grpc.WithToken("secret")).GetSnapshot(context.Background(), &grpc.GetSnapshotRequest{Format: 7})
+	if st, ok := err.(*grpc.Status); !ok || st.Code != grpc.InvalidArgument {
+		t.Errorf("Expected an InvalidArgument status, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/dbinfo.v1.Schema/GetSnapshot", nil))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected plain HTTP requests to fail, got %d", rec.Code)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := map[string]time.Duration{"100m": 100 * time.Millisecond, "5S": 5 * time.Second, "1H": time.Hour, "7": 0, "1x": 0, "99999999H": 0}
+	for value, expected := range tests {
+		if actual, ok := parseTimeout(value); actual != expected || ok != (expected != 0) {
+			t.Errorf("parseTimeout(%q) = %v, %v, expected %v", value, actual, ok, expected)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package grpc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Code is a gRPC status code
+type Code uint32
+
+// The status codes the service uses
+const (
+	OK                Code = 0
+	Canceled          Code = 1
+	Unknown           Code = 2
+	InvalidArgument   Code = 3
+	DeadlineExceeded  Code = 4
+	NotFound          Code = 5
+	ResourceExhausted Code = 8
+	Unimplemented     Code = 12
+	Internal          Code = 13
+	Unavailable       Code = 14
+	Unauthenticated   Code = 16
+)
+
+var codeNames = map[Code]string{
+	OK:                "OK",
+	Canceled:          "Canceled",
+	Unknown:           "Unknown",
+	InvalidArgument:   "InvalidArgument",
+	DeadlineExceeded:  "DeadlineExceeded",
+	NotFound:          "NotFound",
+	ResourceExhausted: "ResourceExhausted",
+	Unimplemented:     "Unimplemented",
+	Internal:          "Internal",
+	Unavailable:       "Unavailable",
+	Unauthenticated:   "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// Status is the error of a failed call
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("rpc error: code = %s desc = %s", s.Code, s.Message)
+}
+
+// Errorf returns a status with a formatted message
+func Errorf(code Code, format string, args ...any) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WriteStatus ends a call with st, or OK when st is nil. Without a response
+// message, it sends a trailers-only response; after one, it sets the
+// trailers.
+func WriteStatus(w http.ResponseWriter, wroteMessage bool, st *Status) {
+	if st == nil {
+		st = &Status{Code: OK}
+	}
+	prefix := ""
+	if wroteMessage {
+		prefix = http.TrailerPrefix
+	} else {
+		w.Header().Set("Content-Type", ContentType)
+	}
+	w.Header().Set(prefix+"Grpc-Status", strconv.FormatUint(uint64(st.Code), 10))
+	if st.Message != "" {
+		w.Header().Set(prefix+"Grpc-Message", encodeMessage(st.Message))
+	}
+	if !wroteMessage {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// statusFromHeader returns the status in the Grpc-Status and Grpc-Message
+// fields of h, or nil when h has none
+func statusFromHeader(h http.Header) *Status {
+	value := h.Get("Grpc-Status")
+	if value == "" {
+		return nil
+	}
+	code, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return Errorf(Internal, "invalid grpc-status %q", value)
+	}
+	return &Status{Code: Code(code), Message: decodeMessage(h.Get("Grpc-Message"))}
+}
+
+// encodeMessage percent-encodes a status message, as the protocol requires
+// for bytes outside printable ASCII
+func encodeMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// decodeMessage reverses encodeMessage, keeping invalid escapes as they are
+func decodeMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if message[i] == '%' && i+2 < len(message) {
+			if c, err := strconv.ParseUint(message[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(message[i])
+	}
+	return b.String()
+}
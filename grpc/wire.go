@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Protocol buffers wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// encoder appends fields to a protocol buffers message. Fields holding
+// their zero value are left out, as proto3 does.
+type encoder []byte
+
+func (e *encoder) tag(field, wireType int) {
+	*e = binary.AppendUvarint(*e, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	*e = binary.AppendUvarint(*e, uint64(len(b)))
+	*e = append(*e, b...)
+}
+
+func (e *encoder) string(field int, s string) {
+	e.bytes(field, []byte(s))
+}
+
+func (e *encoder) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	*e = binary.AppendUvarint(*e, v)
+}
+
+func (e *encoder) bool(field int, b bool) {
+	if b {
+		e.varint(field, 1)
+	}
+}
+
+// message appends an embedded message, even when it is empty, so repeated
+// fields keep their length
+func (e *encoder) message(field int, m []byte) {
+	e.tag(field, wireBytes)
+	*e = binary.AppendUvarint(*e, uint64(len(m)))
+	*e = append(*e, m...)
+}
+
+// decode calls f for every field of a protocol buffers message, with its
+// value for varints and its contents for length-delimited fields. Fixed
+// size fields are skipped, since no message has any.
+func decode(data []byte, f func(field, wireType int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid field key")
+		}
+		data = data[n:]
+		field, wireType := int(key>>3), int(key&7)
+		if field == 0 {
+			return errors.New("invalid field number 0")
+		}
+
+		var v uint64
+		var b []byte
+		switch wireType {
+		case wireVarint:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("invalid varint in field %d", field)
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return fmt.Errorf("invalid length of field %d", field)
+			}
+			b, data = data[n:n+int(length)], data[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wireType == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return fmt.Errorf("truncated field %d", field)
+			}
+			data = data[size:]
+			continue
+		default:
+			return fmt.Errorf("unsupported wire type %d in field %d", wireType, field)
+		}
+		if err := f(field, wireType, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkWireType reports fields sent with an unexpected wire type
+func checkWireType(field, wireType, expected int) error {
+	if wireType != expected {
+		return fmt.Errorf("field %d has wire type %d, expected %d", field, wireType, expected)
+	}
+	return nil
+}
+
+// WriteMessage writes a length-prefixed gRPC message, uncompressed
+func WriteMessage(w io.Writer, m []byte) error {
+	frame := make([]byte, 5, 5+len(m))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(m)))
+	_, err := w.Write(append(frame, m...))
+	return err
+}
+
+// ReadMessage reads a length-prefixed gRPC message of at most maxSize
+// bytes. Compressed messages aren't supported, since neither side asks for
+// compression.
+func ReadMessage(r io.Reader, maxSize int) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated message")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, errors.New("compressed messages aren't supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if uint64(size) > uint64(maxSize) {
+		return nil, fmt.Errorf("message of %d bytes exceeds the limit of %d", size, maxSize)
+	}
+	m := make([]byte, size)
+	if _, err := io.ReadFull(r, m); err != nil {
+		return nil, errors.New("truncated message")
+	}
+	return m, nil
+}
@@ -0,0 +1,98 @@
+package dbinfo
+
+import "strings"
+
+// QuoteIdent quotes an identifier when PostgreSQL requires it: names with
+// upper case letters, such as "Order" or "userId", spaces or other special
+// characters, and reserved words. Names are stored in the model with their
+// original case, unquoted.
+func QuoteIdent(name string) string {
+	if isSimpleIdent(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// QualifiedName returns the quoted name of an object, qualified with its
+// schema when schema is not empty
+func QualifiedName(schema, name string) string {
+	if schema == "" {
+		return QuoteIdent(name)
+	}
+	return QuoteIdent(schema) + "." + QuoteIdent(name)
+}
+
+// QuotedName returns the schema qualified name of the table, quoted for SQL
+func (t *Table) QuotedName() string {
+	return QualifiedName(t.Schema, t.Name)
+}
+
+// QuotedName returns the name of the column, quoted for SQL
+func (c *Column) QuotedName() string {
+	return QuoteIdent(c.Name)
+}
+
+// QuotedName returns the name of the primary key constraint, quoted for SQL
+func (pk *PrimaryKey) QuotedName() string {
+	return QuoteIdent(pk.Name)
+}
+
+// QuotedName returns the name of the index, quoted for SQL. Indexes live in
+// the schema of their table.
+func (i *Index) QuotedName() string {
+	return QuoteIdent(i.Name)
+}
+
+// QuotedName returns the name of the foreign key constraint, quoted for SQL
+func (fk *ForeignKey) QuotedName() string {
+	return QuoteIdent(fk.Name)
+}
+
+// QuotedRefTable returns the schema qualified name of the referenced table,
+// quoted for SQL
+func (fk *ForeignKey) QuotedRefTable() string {
+	return QualifiedName(fk.RefTableSchema, fk.RefTableName)
+}
+
+// isSimpleIdent reports whether name can be written without quotes:
+// lower case letters, digits and underscores, not starting with a digit
+func isSimpleIdent(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return !reservedWords[name]
+}
+
+// reservedWords are the PostgreSQL keywords that can't be used as unquoted
+// column or table names
+var reservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true,
+	"array": true, "as": true, "asc": true, "asymmetric": true, "authorization": true,
+	"binary": true, "both": true, "case": true, "cast": true, "check": true,
+	"collate": true, "collation": true, "column": true, "concurrently": true, "constraint": true,
+	"create": true, "cross": true, "current_catalog": true, "current_date": true, "current_role": true,
+	"current_schema": true, "current_time": true, "current_timestamp": true, "current_user": true, "default": true,
+	"deferrable": true, "desc": true, "distinct": true, "do": true, "else": true,
+	"end": true, "except": true, "false": true, "fetch": true, "for": true,
+	"foreign": true, "freeze": true, "from": true, "full": true, "grant": true,
+	"group": true, "having": true, "ilike": true, "in": true, "initially": true,
+	"inner": true, "intersect": true, "into": true, "is": true, "isnull": true,
+	"join": true, "lateral": true, "leading": true, "left": true, "like": true,
+	"limit": true, "localtime": true, "localtimestamp": true, "natural": true, "not": true,
+	"notnull": true, "null": true, "offset": true, "on": true, "only": true,
+	"or": true, "order": true, "outer": true, "overlaps": true, "placing": true,
+	"primary": true, "references": true, "returning": true, "right": true, "select": true,
+	"session_user": true, "similar": true, "some": true, "symmetric": true, "system_user": true,
+	"table": true, "tablesample": true, "then": true, "to": true, "trailing": true,
+	"true": true, "union": true, "unique": true, "user": true, "using": true,
+	"variadic": true, "verbose": true, "when": true, "where": true, "window": true,
+	"with": true,
+}
@@ -0,0 +1,209 @@
+// Package impact reports what dropping a table would affect before anyone
+// runs the DDL: the foreign keys of other tables referencing it and the
+// views reading it, which make a plain DROP TABLE fail and are dropped by
+// DROP TABLE ... CASCADE, and the indexes, constraints, sequences,
+// partitions, triggers and policies dropped along with it.
+//
+// Foreign keys, indexes, sequences, partitions and views come from the
+// model. Triggers and policies aren't in it; they come from
+// dbinfo.GetTableDependents when the database is at hand, along with the
+// views.
+//
+// Dropping a table removes the foreign keys referencing it, not the rows of
+// the referencing tables: ON DELETE CASCADE only applies to deleted rows.
+package impact
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Effects of dropping a table on an object
+const (
+	// Blocks is an object making a plain DROP TABLE fail, dropped with CASCADE
+	Blocks = "blocks"
+	// Dropped is an object dropped along with the table
+	Dropped = "dropped"
+)
+
+// Kinds of the objects the model records, besides the kinds of
+// dbinfo.TableDependent
+const (
+	KindForeignKey = "foreign key"
+	KindPrimaryKey = "primary key"
+	KindIndex      = "index"
+	KindSequence   = "sequence"
+	KindPartition  = "partition"
+)
+
+// kinds orders the objects of a report
+var kinds = []string{KindForeignKey, dbinfo.DependentView, dbinfo.DependentMaterializedView, KindPrimaryKey, KindIndex,
+	KindSequence, KindPartition, dbinfo.DependentTrigger, dbinfo.DependentPolicy}
+
+// Object is an object affected by dropping a table
+type Object struct {
+	Effect string `json:"effect"` // Blocks or Dropped
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	On     string `json:"on"` // schema.name of the table or view it belongs to or depends on
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report is the impact of dropping a table
+type Report struct {
+	Table   string    `json:"table"` // schema.name
+	Objects []*Object `json:"objects"`
+	// DependentsChecked is set when the views, triggers and policies were
+	// read from the database. Without them the views come from the model,
+	// and the report misses those of snapshots taken without views.
+	DependentsChecked bool `json:"dependentschecked"`
+}
+
+// Blocked reports whether a plain DROP TABLE fails, other objects depending
+// on the table
+func (r *Report) Blocked() bool {
+	return slices.ContainsFunc(r.Objects, func(o *Object) bool { return o.Effect == Blocks })
+}
+
+// Statement returns the DROP TABLE statement the report is about, with
+// CASCADE when a plain one fails
+func (r *Report) Statement() string {
+	schema, name, _ := strings.Cut(r.Table, ".")
+	statement := "DROP TABLE " + dbinfo.QualifiedName(schema, name)
+	if r.Blocked() {
+		statement += " CASCADE"
+	}
+	return statement
+}
+
+// FindTable returns the table of info named schema.table, or table when a
+// single schema has a table with that name
+func FindTable(info *dbinfo.DBInfo, name string) (*dbinfo.Table, error) {
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		for _, t := range info.Tables {
+			if t.Schema == schema && t.Name == table {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("table %s not found", name)
+	}
+
+	var matches []string
+	var found *dbinfo.Table
+	for _, t := range info.Tables {
+		if t.Name == name {
+			matches = append(matches, t.Schema+"."+t.Name)
+			found = t
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("table %s not found", name)
+	case 1:
+		return found, nil
+	}
+	return nil, fmt.Errorf("table %s is ambiguous, use one of: %s", name, strings.Join(matches, ", "))
+}
+
+// DropTable returns the impact of dropping table, a table of info.
+// Dependents are the objects read with dbinfo.GetTableDependents, nil when
+// they weren't read, as for a snapshot, in which case the views reading the
+// table are found in info.Views.
+func DropTable(info *dbinfo.DBInfo, table *dbinfo.Table, dependents []*dbinfo.TableDependent) *Report {
+	key := table.Schema + "." + table.Name
+	r := &Report{Table: key, Objects: []*Object{}, DependentsChecked: dependents != nil}
+	add := func(effect, kind, name, on, detail string) {
+		r.Objects = append(r.Objects, &Object{Effect: effect, Kind: kind, Name: name, On: on, Detail: detail})
+	}
+
+	for _, other := range info.Tables {
+		if other == table {
+			continue
+		}
+		for _, fk := range other.ForeignKeys {
+			if fk.RefTableSchema != table.Schema || fk.RefTableName != table.Name {
+				continue
+			}
+			add(Blocks, KindForeignKey, fk.Name, other.Schema+"."+other.Name,
+				fmt.Sprintf("%s stops being checked, its rows are kept", strings.Join(fk.ColumnNames, ", ")))
+		}
+	}
+	if dependents == nil {
+		dependents = dependentViews(info, table)
+	}
+	for _, d := range dependents {
+		name := d.Schema + "." + d.Name
+		switch d.Kind {
+		case dbinfo.DependentView, dbinfo.DependentMaterializedView:
+			add(Blocks, d.Kind, name, d.On, "")
+		default:
+			add(Dropped, d.Kind, d.Name, d.On, "")
+		}
+	}
+
+	if table.PrimaryKey != nil {
+		add(Dropped, KindPrimaryKey, table.PrimaryKey.Name, key, strings.Join(table.PrimaryKey.Columns, ", "))
+	}
+	for _, index := range table.Indexes {
+		detail := cmp.Or(strings.Join(index.Columns, ", "), index.Expression)
+		if index.Unique {
+			detail = "unique " + detail
+		}
+		add(Dropped, KindIndex, index.Name, key, detail)
+	}
+	for _, fk := range table.ForeignKeys {
+		add(Dropped, KindForeignKey, fk.Name, key, "references "+fk.RefTableSchema+"."+fk.RefTableName)
+	}
+	for _, column := range table.Columns {
+		if column.AutoIncrement() {
+			add(Dropped, KindSequence, column.Name, key, fmt.Sprintf("of the %s column", column.Kind))
+		}
+	}
+	var partitions func(parent string, list []*dbinfo.Partition)
+	partitions = func(parent string, list []*dbinfo.Partition) {
+		for _, p := range list {
+			add(Dropped, KindPartition, p.Schema+"."+p.Name, parent, p.Bound)
+			partitions(p.Schema+"."+p.Name, p.Partitions)
+		}
+	}
+	partitions(key, table.Partitions)
+
+	slices.SortStableFunc(r.Objects, func(a, b *Object) int {
+		// Blocks sorts before Dropped
+		return cmp.Or(cmp.Compare(a.Effect, b.Effect), cmp.Compare(slices.Index(kinds, a.Kind), slices.Index(kinds, b.Kind)))
+	})
+	return r
+}
+
+// dependentViews returns the views and materialized views of info reading
+// table, directly or through other views, as GetTableDependents would
+func dependentViews(info *dbinfo.DBInfo, table *dbinfo.Table) []*dbinfo.TableDependent {
+	var dependents []*dbinfo.TableDependent
+	seen := make(map[dbinfo.ObjectKey]bool)
+	var visit func(schema, name string)
+	add := func(kind, schema, name, on string) {
+		if key := (dbinfo.ObjectKey{Schema: schema, Name: name}); !seen[key] {
+			seen[key] = true
+			dependents = append(dependents, &dbinfo.TableDependent{Kind: kind, Schema: schema, Name: name, On: on})
+			visit(schema, name)
+		}
+	}
+	visit = func(schema, name string) {
+		for _, view := range info.Views {
+			if view.Reads(schema, name) {
+				add(dbinfo.DependentView, view.Schema, view.Name, schema+"."+name)
+			}
+		}
+		for _, view := range info.MaterializedViews {
+			if view.Reads(schema, name) {
+				add(dbinfo.DependentMaterializedView, view.Schema, view.Name, schema+"."+name)
+			}
+		}
+	}
+	visit(table.Schema, table.Name)
+	return dependents
+}
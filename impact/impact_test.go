@@ -0,0 +1,169 @@
+package impact
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{Schema: "billing", Name: "invoices", ForeignKeys: []*dbinfo.ForeignKey{
+				{Name: "invoices_customer_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: "public", RefTableName: "customers", OnDelete: "CASCADE"},
+			}},
+			{Schema: "public", Name: "customers",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", Kind: dbinfo.ColumnSerial, IsPrimaryKey: true},
+					{Name: "email", Type: "text"},
+					{Name: "referrer_id", Type: "integer"},
+				},
+				PrimaryKey:  &dbinfo.PrimaryKey{Name: "customers_pkey", Columns: []string{"id"}},
+				Indexes:     []*dbinfo.Index{{Name: "customers_email_key", Unique: true, Columns: []string{"email"}}},
+				ForeignKeys: []*dbinfo.ForeignKey{{Name: "customers_referrer_fkey", ColumnNames: []string{"referrer_id"}, RefTableSchema: "public", RefTableName: "customers"}},
+			},
+			{Schema: "sales", Name: "customers"},
+		},
+	}
+}
+
+func TestFindTable(t *testing.T) {
+	info := testInfo()
+	if table, err := FindTable(info, "invoices"); err != nil || table != info.Tables[0] {
+		t.Errorf("Expected billing.invoices, got %v, %v", table, err)
+	}
+	if table, err := FindTable(info, "sales.customers"); err != nil || table != info.Tables[2] {
+		t.Errorf("Expected sales.customers, got %v, %v", table, err)
+	}
+	if _, err := FindTable(info, "customers"); err == nil || !strings.Contains(err.Error(), "public.customers, sales.customers") {
+		t.Errorf("Expected an ambiguous table error, got %v", err)
+	}
+	if _, err := FindTable(info, "public.orders"); err == nil {
+		t.Error("Expected an error for a missing table")
+	}
+}
+
+func TestDropTable(t *testing.T) {
+	info := testInfo()
+	dependents := []*dbinfo.TableDependent{
+		{Kind: dbinfo.DependentView, Schema: "public", Name: "active_customers", On: "public.customers"},
+		{Kind: dbinfo.DependentView, Schema: "public", Name: "vip_customers", On: "public.active_customers"},
+		{Kind: dbinfo.DependentTrigger, Schema: "public", Name: "customers_audit", On: "public.customers"},
+	}
+	r := DropTable(info, info.Tables[1], dependents)
+
+	want := &Report{
+		Table: "public.customers",
+		Objects: []*Object{
+			{Effect: Blocks, Kind: KindForeignKey, Name: "invoices_customer_fkey", On: "billing.invoices", Detail: "customer_id stops being checked, its rows are kept"},
+			{Effect: Blocks, Kind: dbinfo.DependentView, Name: "public.active_customers", On: "public.customers"},
+			{Effect: Blocks, Kind: dbinfo.DependentView, Name: "public.vip_customers", On: "public.active_customers"},
+			{Effect: Dropped, Kind: KindForeignKey, Name: "customers_referrer_fkey", On: "public.customers", Detail: "references public.customers"},
+			{Effect: Dropped, Kind: KindPrimaryKey, Name: "customers_pkey", On: "public.customers", Detail: "id"},
+			{Effect: Dropped, Kind: KindIndex, Name: "customers_email_key", On: "public.customers", Detail: "unique email"},
+			{Effect: Dropped, Kind: KindSequence, Name: "id", On: "public.customers", Detail: "of the serial column"},
+			{Effect: Dropped, Kind: dbinfo.DependentTrigger, Name: "customers_audit", On: "public.customers"},
+		},
+		DependentsChecked: true,
+	}
+	if diff := cmp.Diff(want, r); diff != "" {
+		t.Errorf("report (-want +got):\n%s", diff)
+	}
+	if want := `DROP TABLE public.customers CASCADE`; r.Statement() != want {
+		t.Errorf("Expected %q, got %q", want, r.Statement())
+	}
+
+	// Nothing references the invoices
+	r = DropTable(info, info.Tables[0], nil)
+	if r.Blocked() || r.DependentsChecked || len(r.Objects) != 1 {
+		t.Errorf("Expected invoices to drop without CASCADE, got %+v", r.Objects)
+	}
+}
+
+func TestDropTableViews(t *testing.T) {
+	info := testInfo()
+	info.Views = []*dbinfo.View{
+		{Schema: "public", Name: "active_customers", Sources: []*dbinfo.ViewSource{{Schema: "public", Name: "customers"}}},
+		{Schema: "public", Name: "open_invoices", Sources: []*dbinfo.ViewSource{{Schema: "billing", Name: "invoices"}}},
+	}
+	info.MaterializedViews = []*dbinfo.MaterializedView{
+		{Schema: "reports", Name: "customer_totals", Sources: []*dbinfo.ViewSource{{Schema: "public", Name: "active_customers"}}},
+	}
+	r := DropTable(info, info.Tables[1], nil)
+	var views []string
+	for _, o := range r.Objects {
+		if o.Kind == dbinfo.DependentView || o.Kind == dbinfo.DependentMaterializedView {
+			views = append(views, o.Effect+" "+o.Kind+" "+o.Name+" on "+o.On)
+		}
+	}
+	want := []string{
+		"blocks view public.active_customers on public.customers",
+		"blocks materialized view reports.customer_totals on public.active_customers",
+	}
+	if diff := cmp.Diff(want, views); diff != "" {
+		t.Errorf("views (-want +got):\n%s", diff)
+	}
+	if r.DependentsChecked {
+		t.Error("Expected the triggers and policies to be reported unchecked")
+	}
+}
+
+func TestDropTableDottedViews(t *testing.T) {
+	// "a.b"."c" and "a"."b.c" would both be a.b.c as strings
+	info := testInfo()
+	source := []*dbinfo.ViewSource{{Schema: "public", Name: "customers"}}
+	info.Views = []*dbinfo.View{
+		{Schema: "a.b", Name: "c", Sources: source},
+		{Schema: "a", Name: "b.c", Sources: source},
+	}
+	views := dependentViews(info, info.Tables[1])
+	if len(views) != 2 || views[0].Schema != "a.b" || views[1].Schema != "a" {
+		t.Errorf("Expected both views to be reported, got %v", views)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	info := testInfo()
+	r := DropTable(info, info.Tables[1], nil)
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"DROP TABLE public.customers fails unless CASCADE also drops the objects depending on it.\nTriggers and policies weren't checked",
+		"DROPPED BY CASCADE                  ON                DETAIL\nforeign key invoices_customer_fkey  billing.invoices  customer_id stops being checked, its rows are kept\n",
+		"\nDROPPED WITH THE TABLE",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the text output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Impact of dropping public.customers\n\n",
+		"## Dropped with the table\n\n| Kind | Name | On | Detail |\n|---|---|---|---|\n| foreign key | customers_referrer_fkey | public.customers | references public.customers |\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the Markdown output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"statement": "DROP TABLE public.customers CASCADE"`, `"blocked": true`, `"effect": "blocks"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %s in the JSON output:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package impact
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// objects returns the objects of the report with the given effect
+func (r *Report) objects(effect string) []*Object {
+	var objects []*Object
+	for _, o := range r.Objects {
+		if o.Effect == effect {
+			objects = append(objects, o)
+		}
+	}
+	return objects
+}
+
+// verdict tells in one sentence whether a plain DROP TABLE succeeds
+func (r *Report) verdict() string {
+	if r.Blocked() {
+		return fmt.Sprintf("DROP TABLE %s fails unless CASCADE also drops the objects depending on it", r.Table)
+	}
+	return fmt.Sprintf("DROP TABLE %s succeeds without CASCADE", r.Table)
+}
+
+// uncheckedNote is shown when the triggers and policies weren't read, and
+// views only from the model
+const uncheckedNote = "Triggers and policies weren't checked, and views only as far as the snapshot has them: connect to the database to list them"
+
+// WriteText writes the report to w as aligned sections
+func WriteText(w io.Writer, r *Report) error {
+	fmt.Fprintf(w, "%s.\n", r.verdict())
+	if !r.DependentsChecked {
+		fmt.Fprintf(w, "%s.\n", uncheckedNote)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, section := range []struct{ effect, title string }{
+		{Blocks, "DROPPED BY CASCADE"},
+		{Dropped, "DROPPED WITH THE TABLE"},
+	} {
+		objects := r.objects(section.effect)
+		if len(objects) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s\tON\tDETAIL\n", section.title)
+		for _, o := range objects {
+			fmt.Fprintf(tw, "%s %s\t%s\t%s\n", o.Kind, o.Name, o.On, o.Detail)
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the report to w as JSON
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		*Report
+		Statement string `json:"statement"`
+		Blocked   bool   `json:"blocked"`
+	}{r, r.Statement(), r.Blocked()})
+}
+
+// WriteMarkdown writes the report to w as a Markdown document, to paste in
+// the review of a migration
+func WriteMarkdown(w io.Writer, r *Report) error {
+	fmt.Fprintf(w, "# Impact of dropping %s\n\n", r.Table)
+	fmt.Fprintf(w, "%s.\n", r.verdict())
+	if !r.DependentsChecked {
+		fmt.Fprintf(w, "\n> %s.\n", uncheckedNote)
+	}
+	for _, section := range []struct{ effect, title string }{
+		{Blocks, "Dropped by CASCADE"},
+		{Dropped, "Dropped with the table"},
+	} {
+		objects := r.objects(section.effect)
+		if len(objects) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", section.title)
+		fmt.Fprintln(w, "| Kind | Name | On | Detail |")
+		fmt.Fprintln(w, "|---|---|---|---|")
+		for _, o := range objects {
+			fmt.Fprintf(w, "| %s | %s | %s | %s |\n", o.Kind, o.Name, o.On, o.Detail)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package infer suggests the foreign keys a schema relies on without
+// declaring them, as legacy schemas often do.
+//
+// A column is a candidate when its name is the name of another table, in
+// singular or plural form, followed by the name of that table's single
+// column primary key, such as customer_id for customers.id, and its type is
+// compatible with the key. Verify checks the candidates against the data by
+// sampling values and counting those the referenced table contains.
+package infer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Reference is a foreign key, declared or inferred
+type Reference struct {
+	Schema     string   `json:"schema"`
+	Table      string   `json:"table"`
+	Columns    []string `json:"columns"`
+	RefSchema  string   `json:"refschema"`
+	RefTable   string   `json:"reftable"`
+	RefColumns []string `json:"refcolumns"`
+	// Name is the constraint name of declared foreign keys, and the name
+	// PostgreSQL would give to inferred ones
+	Name     string   `json:"name"`
+	Declared bool     `json:"declared"`
+	Overlap  *Overlap `json:"overlap,omitempty"` // Set by Verify on inferred references
+}
+
+// ForeignKey returns the reference as a foreign key of the referencing table
+func (r *Reference) ForeignKey() *dbinfo.ForeignKey {
+	return &dbinfo.ForeignKey{
+		Name:           r.Name,
+		ColumnNames:    r.Columns,
+		RefTableSchema: r.RefSchema,
+		RefTableName:   r.RefTable,
+		RefColumnNames: r.RefColumns,
+	}
+}
+
+// Overlap counts the sampled values of the referencing columns found in the
+// referenced table
+type Overlap struct {
+	Sampled int64 `json:"sampled"`
+	Matched int64 `json:"matched"`
+}
+
+// Ratio returns the share of sampled values found, from 0 to 1. A column
+// without values has nothing contradicting the reference and returns 1.
+func (o *Overlap) Ratio() float64 {
+	if o.Sampled == 0 {
+		return 1
+	}
+	return float64(o.Matched) / float64(o.Sampled)
+}
+
+// Declared returns the foreign keys declared in info, in the order of the
+// tables
+func Declared(info *dbinfo.DBInfo) []*Reference {
+	var refs []*Reference
+	for _, table := range info.Tables {
+		for _, fk := range table.ForeignKeys {
+			refs = append(refs, &Reference{
+				Schema:     table.Schema,
+				Table:      table.Name,
+				Columns:    fk.ColumnNames,
+				RefSchema:  fk.RefTableSchema,
+				RefTable:   fk.RefTableName,
+				RefColumns: fk.RefColumnNames,
+				Name:       fk.Name,
+				Declared:   true,
+			})
+		}
+	}
+	return refs
+}
+
+// Infer returns the foreign keys info relies on without declaring them, in
+// the order of the tables and columns. Columns that are part of a declared
+// foreign key are left out.
+func Infer(info *dbinfo.DBInfo) []*Reference {
+	// Tables that can be referenced
+	var targets []*dbinfo.Table
+	for _, table := range info.Tables {
+		if len(primaryKey(table)) == 1 {
+			targets = append(targets, table)
+		}
+	}
+
+	var refs []*Reference
+	for _, table := range info.Tables {
+		declared := map[string]bool{}
+		for _, fk := range table.ForeignKeys {
+			for _, name := range fk.ColumnNames {
+				declared[name] = true
+			}
+		}
+		for _, column := range table.Columns {
+			if declared[column.Name] {
+				continue
+			}
+			target, key := referenced(table, column, targets)
+			if target == nil {
+				continue
+			}
+			refs = append(refs, &Reference{
+				Schema:     table.Schema,
+				Table:      table.Name,
+				Columns:    []string{column.Name},
+				RefSchema:  target.Schema,
+				RefTable:   target.Name,
+				RefColumns: []string{key.Name},
+				Name:       table.Name + "_" + column.Name + "_fkey",
+			})
+		}
+	}
+	return refs
+}
+
+// referenced returns the table and key column column likely references, or
+// nil. Tables of the schema of table win over tables of other schemas; a
+// name found in several other schemas is ambiguous and ignored.
+func referenced(table *dbinfo.Table, column *dbinfo.Column, targets []*dbinfo.Table) (*dbinfo.Table, *dbinfo.Column) {
+	name := strings.ToLower(column.Name)
+	var found []*dbinfo.Table
+	for _, target := range targets {
+		key := primaryKey(target)[0]
+		if target == table && column == key {
+			continue
+		}
+		if !compatible(column.Type, key.Type) || !matches(name, strings.ToLower(target.Name), strings.ToLower(key.Name)) {
+			continue
+		}
+		if target.Schema == table.Schema {
+			return target, key
+		}
+		found = append(found, target)
+	}
+	if len(found) != 1 {
+		return nil, nil
+	}
+	return found[0], primaryKey(found[0])[0]
+}
+
+// matches reports whether column is named after table and its key: the
+// table name in singular or plural form followed by the key, with or
+// without an underscore, or the key itself when it is more specific than id
+func matches(column, table, key string) bool {
+	if key != "id" && column == key {
+		return true
+	}
+	for _, form := range forms(table) {
+		if column == form+"_"+key || column == form+key {
+			return true
+		}
+	}
+	return false
+}
+
+// forms returns the singular and plural forms a table name can take in a
+// column name. It handles regular English plurals only, and tries every
+// singular a plural could come from: houses could be a plural of hous.
+func forms(table string) []string {
+	forms := []string{table}
+	if strings.HasSuffix(table, "ies") {
+		forms = append(forms, strings.TrimSuffix(table, "ies")+"y")
+	}
+	if strings.HasSuffix(table, "es") {
+		forms = append(forms, strings.TrimSuffix(table, "es"))
+	}
+	if strings.HasSuffix(table, "s") && !strings.HasSuffix(table, "ss") {
+		forms = append(forms, strings.TrimSuffix(table, "s"))
+	}
+	return forms
+}
+
+// typeFamilies groups the types whose values can be compared for equality
+var typeFamilies = map[string]string{
+	"smallint":          "integer",
+	"integer":           "integer",
+	"bigint":            "integer",
+	"int2":              "integer",
+	"int4":              "integer",
+	"int8":              "integer",
+	"text":              "text",
+	"character varying": "text",
+	"varchar":           "text",
+	"character":         "text",
+	"bpchar":            "text",
+}
+
+// compatible reports whether a column of type typ can reference a key of
+// type keyType
+func compatible(typ, keyType string) bool {
+	typ, keyType = strings.ToLower(typ), strings.ToLower(keyType)
+	if typ == keyType {
+		return true
+	}
+	family, ok := typeFamilies[typ]
+	return ok && family == typeFamilies[keyType]
+}
+
+// primaryKey returns the primary key columns of table
+func primaryKey(table *dbinfo.Table) []*dbinfo.Column {
+	var columns []*dbinfo.Column
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// Verify samples up to sampleSize non-null values of the columns of each
+// inferred reference and records how many the referenced table contains.
+// Declared references are left alone.
+func Verify(ctx context.Context, db dbinfo.DBQuerier, refs []*Reference, sampleSize int) error {
+	for _, ref := range refs {
+		if ref.Declared {
+			continue
+		}
+		column, key := dbinfo.QuoteIdent(ref.Columns[0]), dbinfo.QuoteIdent(ref.RefColumns[0])
+		query := fmt.Sprintf(`
+	SELECT count(*), count(r.%s)
+	FROM (SELECT %s AS value FROM %s WHERE %s IS NOT NULL LIMIT $1) s
+	LEFT JOIN %s r ON r.%s = s.value`,
+			key, column, dbinfo.QualifiedName(ref.Schema, ref.Table), column,
+			dbinfo.QualifiedName(ref.RefSchema, ref.RefTable), key)
+
+		overlap := &Overlap{}
+		if err := db.QueryRow(ctx, query, sampleSize).Scan(&overlap.Sampled, &overlap.Matched); err != nil {
+			return fmt.Errorf("verifying %s.%s.%s: %w", ref.Schema, ref.Table, ref.Columns[0], err)
+		}
+		ref.Overlap = overlap
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package infer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema:  "public",
+				Name:    "customers",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}, {Name: "country_code", Type: "text"}},
+			},
+			{
+				Schema:  "public",
+				Name:    "countries",
+				Columns: []*dbinfo.Column{{Name: "code", Type: "character", IsPrimaryKey: true}},
+			},
+			{
+				Schema:  "public",
+				Name:    "categories",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}, {Name: "parent_id", Type: "integer"}},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "bigint"},
+					{Name: "CategoryID", Type: "integer"},
+					{Name: "coupon_id", Type: "uuid"},
+					{Name: "warehouse_id", Type: "integer"},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{{
+					Name:           "orders_warehouse_fkey",
+					ColumnNames:    []string{"warehouse_id"},
+					RefTableSchema: "inventory",
+					RefTableName:   "warehouses",
+					RefColumnNames: []string{"id"},
+				}},
+			},
+			{
+				Schema:  "public",
+				Name:    "coupons",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}},
+			},
+			{
+				Schema:  "inventory",
+				Name:    "warehouses",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}},
+			},
+			{
+				Schema:  "inventory",
+				Name:    "stock",
+				Columns: []*dbinfo.Column{{Name: "warehouse_id", Type: "integer"}, {Name: "customer_id", Type: "integer"}},
+			},
+		},
+	}
+}
+
+func TestInfer(t *testing.T) {
+	var got []string
+	for _, r := range Infer(testInfo()) {
+		got = append(got, r.Schema+"."+r.Table+"."+r.Columns[0]+" -> "+r.RefSchema+"."+r.RefTable+"."+r.RefColumns[0])
+	}
+	want := []string{
+		"public.customers.country_code -> public.countries.code",
+		"public.orders.customer_id -> public.customers.id",
+		"public.orders.CategoryID -> public.categories.id",
+		"inventory.stock.warehouse_id -> inventory.warehouses.id",
+		"inventory.stock.customer_id -> public.customers.id",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("references mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestForms(t *testing.T) {
+	for table, want := range map[string][]string{
+		"users":      {"users", "user"},
+		"categories": {"categories", "category", "categori", "categorie"},
+		"boxes":      {"boxes", "box", "boxe"},
+		"warehouses": {"warehouses", "warehous", "warehouse"},
+		"addresses":  {"addresses", "address", "addresse"},
+		"address":    {"address"},
+		"person":     {"person"},
+	} {
+		if diff := cmp.Diff(want, forms(table)); diff != "" {
+			t.Errorf("forms(%q) mismatch (-want +got):\n%s", table, diff)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	info := testInfo()
+	refs := append(Declared(info), Infer(info)[1:3]...)
+	refs[2].Overlap = &Overlap{Sampled: 200, Matched: 190}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, refs); err != nil {
+		t.Fatal(err)
+	}
+	want := "public.orders (warehouse_id)  -> inventory.warehouses (id)  declared\n" +
+		"public.orders (customer_id)   -> public.customers (id)      inferred\n" +
+		"public.orders (CategoryID)    -> public.categories (id)     inferred, 95% of 200 sampled values match\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("text mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteSQL(&buf, refs); err != nil {
+		t.Fatal(err)
+	}
+	want = "ALTER TABLE public.orders ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES public.customers (id) NOT VALID;\n" +
+		`ALTER TABLE public.orders ADD CONSTRAINT "orders_CategoryID_fkey" FOREIGN KEY ("CategoryID") REFERENCES public.categories (id) NOT VALID;` + "\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("SQL mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("WriteJSON(nil) = %q, want an empty array", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := dbinfo.FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, `
+		CREATE SCHEMA infer_test;
+		CREATE TABLE infer_test.customers (id integer PRIMARY KEY);
+		CREATE TABLE infer_test.orders (id integer PRIMARY KEY, customer_id integer);
+		INSERT INTO infer_test.customers VALUES (1), (2);
+		INSERT INTO infer_test.orders VALUES (1, 1), (2, 2), (3, 3), (4, NULL);`)
+	if err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	defer conn.Exec(ctx, "DROP SCHEMA infer_test CASCADE")
+
+	info, err := dbinfo.GetDBInfo(ctx, conn, dbinfo.WithSchemas("infer_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	refs := Infer(info)
+	if len(refs) != 1 {
+		t.Fatalf("Expected one inferred reference, got %d", len(refs))
+	}
+	if err := Verify(ctx, conn, refs, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&Overlap{Sampled: 3, Matched: 2}, refs[0].Overlap); diff != "" {
+		t.Errorf("overlap mismatch (-want +got):\n%s", diff)
+	}
+}
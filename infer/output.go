@@ -0,0 +1,62 @@
+package infer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/ddl"
+)
+
+// Status describes how a reference is known: declared, inferred, or
+// inferred with the overlap found by Verify
+func (r *Reference) Status() string {
+	switch {
+	case r.Declared:
+		return "declared"
+	case r.Overlap == nil:
+		return "inferred"
+	}
+	return fmt.Sprintf("inferred, %.0f%% of %d sampled values match", r.Overlap.Ratio()*100, r.Overlap.Sampled)
+}
+
+// WriteText writes one aligned line per reference to w
+func WriteText(w io.Writer, refs []*Reference) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, r := range refs {
+		fmt.Fprintf(tw, "%s.%s (%s)\t-> %s.%s (%s)\t%s\n",
+			r.Schema, r.Table, strings.Join(r.Columns, ", "),
+			r.RefSchema, r.RefTable, strings.Join(r.RefColumns, ", "),
+			r.Status())
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the references to w as a JSON array
+func WriteJSON(w io.Writer, refs []*Reference) error {
+	if refs == nil {
+		refs = []*Reference{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(refs)
+}
+
+// WriteSQL writes the statements declaring the inferred references to w.
+// The constraints are NOT VALID, so adding them doesn't scan the tables;
+// VALIDATE CONSTRAINT checks the existing rows later.
+func WriteSQL(w io.Writer, refs []*Reference) error {
+	for _, r := range refs {
+		if r.Declared {
+			continue
+		}
+		table := &dbinfo.Table{Schema: r.Schema, Name: r.Table}
+		if _, err := fmt.Fprintf(w, "%s NOT VALID;\n", ddl.ForeignKey(table, r.ForeignKey())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+//go:build integration
+
+// Package integration runs the dbinfo test suite against every supported
+// PostgreSQL version. Each version is started in a container with
+// testcontainers, loaded with the sample schema, and the tests of the main
+// module run against it through TEST_POSTGRES_DSN. It is a module of its
+// own so that dbinfo doesn't depend on testcontainers and its Docker client.
+//
+//	cd integration && go test -tags integration -v .
+package integration
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/sample"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+var (
+	versions = flag.String("versions", "12,13,14,15,16,17", "comma separated PostgreSQL versions to test against")
+	suite    = flag.String("suite", "", "run only the tests of the suite matching this regular expression")
+)
+
+func TestMatrix(t *testing.T) {
+	requireDocker(t)
+	for _, version := range strings.Split(*versions, ",") {
+		version = strings.TrimSpace(version)
+		t.Run("postgres"+version, func(t *testing.T) {
+			t.Parallel()
+			dsn := startPostgres(t, version)
+
+			args := []string{"test", "-count=1", "./..."}
+			if *suite != "" {
+				args = append(args, "-run", *suite)
+			}
+			if testing.Verbose() {
+				args = append(args, "-v")
+			}
+			cmd := exec.Command("go", args...)
+			cmd.Dir = ".."
+			cmd.Env = append(os.Environ(), "TEST_POSTGRES_DSN="+dsn)
+			out, err := cmd.CombinedOutput()
+			t.Logf("go %s against PostgreSQL %s:\n%s", strings.Join(args, " "), version, out)
+			if err != nil {
+				t.Fatalf("Tests failed against PostgreSQL %s: %v", version, err)
+			}
+		})
+	}
+}
+
+// requireDocker fails the test when testcontainers can't reach Docker.
+// The harness only runs when asked for with the integration tag, so a
+// missing Docker is an error rather than a reason to skip.
+func requireDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		// testcontainers panics when it finds no Docker host
+		if r := recover(); r != nil {
+			t.Fatalf("Docker is not available: %v", r)
+		}
+	}()
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		t.Fatalf("Docker is not available: %v", err)
+	}
+	defer provider.Close()
+	if err := provider.Health(context.Background()); err != nil {
+		t.Fatalf("Docker is not available: %v", err)
+	}
+}
+
+// startPostgres runs a PostgreSQL container of the given version for the
+// length of the test, installs the sample schema on it and returns its DSN
+func startPostgres(t *testing.T, version string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := postgres.Run(ctx, "postgres:"+version+"-alpine",
+		postgres.WithDatabase("dbinfo_test"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute)),
+	)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL %s: %v", version, err)
+	}
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to get the PostgreSQL %s connection string: %v", version, err)
+	}
+
+	pool, err := dbinfo.FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to PostgreSQL %s: %v", version, err)
+	}
+	defer pool.Close()
+	if err := sample.Create(ctx, pool); err != nil {
+		t.Fatalf("Failed to create the sample schema on PostgreSQL %s: %v", version, err)
+	}
+	return dsn
+}
@@ -0,0 +1,58 @@
+// Package cache reuses an introspected schema for a while, so servers
+// answering many requests don't introspect the database for each of them.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Schema holds the schema returned by a loader until its time to live
+// expires. It is safe for concurrent use; concurrent requests for an
+// expired schema wait for a single load.
+type Schema struct {
+	load dbinfo.Loader
+	ttl  time.Duration
+	// Now returns the current time, replaced by tests
+	Now func() time.Time
+
+	mu       sync.Mutex
+	info     *dbinfo.DBInfo
+	loadedAt time.Time
+	loadTime time.Duration
+}
+
+// New returns a cache of the schema returned by load, reused for ttl. Zero
+// loads the schema on every call to Get.
+func New(load dbinfo.Loader, ttl time.Duration) *Schema {
+	return &Schema{load: load, ttl: ttl, Now: time.Now}
+}
+
+// Get returns the cached schema, loading it when it is missing, expired or
+// refresh is set. Failed loads are not cached.
+func (c *Schema) Get(ctx context.Context, refresh bool) (*dbinfo.DBInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.info != nil && !refresh && c.Now().Sub(c.loadedAt) < c.ttl {
+		return c.info, nil
+	}
+	start := c.Now()
+	info, err := c.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.info, c.loadedAt = info, c.Now()
+	c.loadTime = c.loadedAt.Sub(start)
+	return info, nil
+}
+
+// LoadTime returns how long the last successful load took
+func (c *Schema) LoadTime() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loadTime
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func TestSchema(t *testing.T) {
+	var calls int
+	fail := false
+	c := New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		calls++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &dbinfo.DBInfo{Name: "shop"}, nil
+	}, time.Minute)
+	now := time.Now()
+	c.Now = func() time.Time { return now }
+
+	get := func(refresh bool) error {
+		t.Helper()
+		_, err := c.Get(context.Background(), refresh)
+		return err
+	}
+
+	get(false)
+	get(false)
+	if calls != 1 {
+		t.Errorf("Expected a cached schema, got %d loads", calls)
+	}
+	get(true)
+	if calls != 2 {
+		t.Errorf("Expected refresh to reload the schema, got %d loads", calls)
+	}
+	now = now.Add(2 * time.Minute)
+	get(false)
+	if calls != 3 {
+		t.Errorf("Expected an expired schema to be reloaded, got %d loads", calls)
+	}
+
+	// Failures are returned and not cached
+	fail = true
+	if err := get(true); err == nil {
+		t.Error("Expected the load error to be returned")
+	}
+	fail = false
+	if err := get(false); err != nil || calls != 4 {
+		t.Errorf("Expected the cached schema after a failed refresh, got %v after %d loads", err, calls)
+	}
+}
@@ -0,0 +1,48 @@
+// Package chains finds the longest paths of a directed graph, such as the
+// chains of foreign keys between tables or of the deletes cascading from
+// one table to others. It backs the deepest chains of shape and the
+// cascade-depth rule of lint.
+package chains
+
+import "slices"
+
+// Longest finds the longest paths of a graph given by the nodes each node
+// leads to. The path of each node is computed once, so nodes reached
+// through many paths don't make the walk exponential.
+type Longest[K comparable] struct {
+	next    map[K][]K
+	longest map[K][]K
+	onPath  map[K]bool
+}
+
+// New returns the longest paths of the graph in which each node leads to
+// the nodes of next, in order. Ties go to the first of them.
+func New[K comparable](next map[K][]K) *Longest[K] {
+	return &Longest[K]{next: next, longest: make(map[K][]K), onPath: make(map[K]bool)}
+}
+
+// From returns the longest path starting at start, avoiding the nodes
+// already on the path, so cycles are cut where the walk first closes them.
+// Paths are memoized across calls: in a graph with cycles, the path of a
+// node depends on the node the first walk reached it from.
+func (l *Longest[K]) From(start K) []K {
+	if path, ok := l.longest[start]; ok {
+		return path
+	}
+	l.onPath[start] = true
+	var best []K
+	for _, next := range l.next[start] {
+		if l.onPath[next] {
+			continue
+		}
+		// A path memoized from another walk may lead back into this one
+		path := l.From(next)
+		if len(path) > len(best) && !slices.ContainsFunc(path, func(node K) bool { return l.onPath[node] }) {
+			best = path
+		}
+	}
+	l.onPath[start] = false
+	path := append([]K{start}, best...)
+	l.longest[start] = path
+	return path
+}
@@ -0,0 +1,46 @@
+package chains
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFrom(t *testing.T) {
+	next := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d", "e"},
+		"e": {"f"},
+	}
+	if got, expected := New(next).From("a"), []string{"a", "c", "e", "f"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestFromCycle(t *testing.T) {
+	next := map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}}
+	if got, expected := New(next).From("a"), []string{"a", "b", "c"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected the cycle to be cut before a, got %v", got)
+	}
+	if got, expected := New(next).From("b"), []string{"b", "c", "a"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected the cycle to be cut before b, got %v", got)
+	}
+}
+
+func TestFromWide(t *testing.T) {
+	// Every node of a layer leads to every node of the next one, so the
+	// number of paths grows exponentially with the depth
+	next := map[string][]string{}
+	for layer := 0; layer < 40; layer++ {
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 4; j++ {
+				from := fmt.Sprintf("%d_%d", layer, i)
+				next[from] = append(next[from], fmt.Sprintf("%d_%d", layer+1, j))
+			}
+		}
+	}
+	if got := New(next).From("0_0"); len(got) != 41 {
+		t.Errorf("Expected a path through the 41 layers, got %d nodes", len(got))
+	}
+}
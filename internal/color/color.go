@@ -0,0 +1,74 @@
+// Package color writes human oriented terminal output: ANSI colors and
+// columns aligned on the visible width of the text, ignoring the escape
+// sequences.
+package color
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Color is an ANSI SGR parameter list, such as "31" for red or "1;31" for
+// bold red. The zero value leaves the text unchanged.
+type Color string
+
+// Colors used by dbinfo
+const (
+	None   Color = ""
+	Bold   Color = "1"
+	Red    Color = "31"
+	Green  Color = "32"
+	Yellow Color = "33"
+	Blue   Color = "34"
+	Cyan   Color = "36"
+	Gray   Color = "90"
+)
+
+// Paint wraps s in the escape sequences of c
+func Paint(s string, c Color) string {
+	if c == None || s == "" {
+		return s
+	}
+	return "\x1b[" + string(c) + "m" + s + "\x1b[0m"
+}
+
+// Cell is a piece of text written in a color
+type Cell struct {
+	Text  string
+	Color Color
+}
+
+// WriteTable writes the rows with their columns aligned, separated by two
+// spaces. Rows can have different lengths; trailing empty cells are dropped
+// so lines don't end with spaces.
+func WriteTable(w io.Writer, rows [][]Cell) error {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell.Text))
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		b.Reset()
+		for len(row) > 0 && row[len(row)-1].Text == "" {
+			row = row[:len(row)-1]
+		}
+		for i, cell := range row {
+			b.WriteString(Paint(cell.Text, cell.Color))
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell.Text)+2))
+			}
+		}
+		b.WriteByte('\n')
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
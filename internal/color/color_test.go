@@ -0,0 +1,33 @@
+package color
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaint(t *testing.T) {
+	if got := Paint("error", Red); got != "\x1b[31merror\x1b[0m" {
+		t.Errorf("Unexpected painted text %q", got)
+	}
+	if got := Paint("plain", None); got != "plain" {
+		t.Errorf("Expected None to leave the text unchanged, got %q", got)
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteTable(&buf, [][]Cell{
+		{{"+", Green}, {"table", None}, {"public.orders", Bold}},
+		{{"~", Yellow}, {"column", None}, {"public.orders.total", Bold}, {"type integer -> bigint", None}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Colored cells are padded on their visible width
+	expected := "\x1b[32m+\x1b[0m  table   \x1b[1mpublic.orders\x1b[0m\n" +
+		"\x1b[33m~\x1b[0m  column  \x1b[1mpublic.orders.total\x1b[0m  type integer -> bigint\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected table:\n%q\nexpected:\n%q", buf.String(), expected)
+	}
+}
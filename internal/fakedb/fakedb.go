@@ -0,0 +1,164 @@
+// Package fakedb serves canned result sets through the querier interface of
+// dbinfo, so the code parsing catalog queries can be tested without a
+// database.
+package fakedb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Result is the canned answer to the queries it matches
+type Result struct {
+	SQL  string  // Matches queries with this exact text
+	Args []any   // When set, matches queries whose first arguments are equal
+	Rows [][]any // One value per column; nil for NULL
+	Err  error   // Returned by Query instead of the rows
+}
+
+// Query is a query run against the fake database
+type Query struct {
+	SQL  string
+	Args []any
+}
+
+// DB answers queries with the first matching result, and records them
+type DB struct {
+	Results []*Result
+	Queries []*Query
+}
+
+// New returns a database answering with results
+func New(results ...*Result) *DB {
+	return &DB{Results: results}
+}
+
+// Add appends results, matched after the existing ones
+func (db *DB) Add(results ...*Result) {
+	db.Results = append(db.Results, results...)
+}
+
+// Query returns the rows of the first result matching sql and args. Queries
+// nothing matches fail, so a test can't miss a query it didn't expect.
+func (db *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	db.Queries = append(db.Queries, &Query{SQL: sql, Args: args})
+	result := db.match(sql, args)
+	if result == nil {
+		return nil, fmt.Errorf("fakedb: unexpected query %s with %v", strings.Join(strings.Fields(sql), " "), args)
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &rows{values: result.Rows}, nil
+}
+
+// QueryRow is Query returning the first row only
+func (db *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	r, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return &row{err: err}
+	}
+	return &row{rows: r.(*rows)}
+}
+
+func (db *DB) match(sql string, args []any) *Result {
+	for _, result := range db.Results {
+		if result.SQL != sql || len(result.Args) > len(args) {
+			continue
+		}
+		if len(result.Args) == 0 || reflect.DeepEqual(result.Args, args[:len(result.Args)]) {
+			return result
+		}
+	}
+	return nil
+}
+
+// rows iterates over the rows of a result
+type rows struct {
+	pgx.Rows
+	values  [][]any
+	current []any
+	err     error
+}
+
+func (r *rows) Next() bool {
+	if len(r.values) == 0 || r.err != nil {
+		return false
+	}
+	r.current, r.values = r.values[0], r.values[1:]
+	return true
+}
+
+// Scan assigns the values of the current row to dest. A value is assigned
+// to a pointer of its own type, or to a pointer to such a pointer, which is
+// left nil for NULL as pgx does.
+func (r *rows) Scan(dest ...any) error {
+	if len(dest) != len(r.current) {
+		r.err = fmt.Errorf("fakedb: %d scan destinations for %d values", len(dest), len(r.current))
+		return r.err
+	}
+	for i, value := range r.current {
+		if err := assign(dest[i], value); err != nil {
+			r.err = fmt.Errorf("fakedb: column %d: %w", i, err)
+			return r.err
+		}
+	}
+	return nil
+}
+
+func (r *rows) Close() {}
+
+func (r *rows) Err() error { return r.err }
+
+func (r *rows) CommandTag() pgconn.CommandTag { return pgconn.CommandTag{} }
+
+// assign stores value in the variable dest points to
+func assign(dest, value any) error {
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("destination %T is not a pointer", dest)
+	}
+	target = target.Elem()
+
+	if value == nil {
+		if target.Kind() != reflect.Pointer && target.Kind() != reflect.Slice {
+			return fmt.Errorf("can't scan NULL into %s", target.Type())
+		}
+		target.SetZero()
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(target.Type()):
+		target.Set(v)
+	case target.Kind() == reflect.Pointer && v.Type().AssignableTo(target.Type().Elem()):
+		p := reflect.New(target.Type().Elem())
+		p.Elem().Set(v)
+		target.Set(p)
+	default:
+		return fmt.Errorf("can't scan %T into %s", value, target.Type())
+	}
+	return nil
+}
+
+// row is the first row of a result, for QueryRow
+type row struct {
+	rows *rows
+	err  error
+}
+
+func (r *row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if !r.rows.Next() {
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
@@ -0,0 +1,74 @@
+package fakedb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestQuery(t *testing.T) {
+	db := New(
+		&Result{SQL: "SELECT name, comment FROM t WHERE id = $1", Args: []any{2}, Rows: [][]any{{"b", nil}}},
+		&Result{SQL: "SELECT name, comment FROM t WHERE id = $1", Rows: [][]any{{"a", "first"}}},
+	)
+
+	rows, err := db.Query(context.Background(), "SELECT name, comment FROM t WHERE id = $1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	comment := new(string)
+	if !rows.Next() {
+		t.Fatal("Expected a row")
+	}
+	if err := rows.Scan(&name, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if name != "b" || comment != nil {
+		t.Errorf("Expected b with a NULL comment, got %s and %v", name, comment)
+	}
+	if rows.Next() {
+		t.Error("Expected a single row")
+	}
+
+	// Results without arguments match any
+	if err := db.QueryRow(context.Background(), "SELECT name, comment FROM t WHERE id = $1", 1).Scan(&name, &comment); err != nil {
+		t.Fatal(err)
+	}
+	if name != "a" || comment == nil || *comment != "first" {
+		t.Errorf("Expected a with its comment, got %s and %v", name, comment)
+	}
+	if len(db.Queries) != 2 {
+		t.Errorf("Expected 2 recorded queries, got %d", len(db.Queries))
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	cause := errors.New("permission denied")
+	db := New(
+		&Result{SQL: "SELECT 1", Err: cause},
+		&Result{SQL: "SELECT 2", Rows: [][]any{{"text"}}},
+		&Result{SQL: "SELECT 3"},
+	)
+	ctx := context.Background()
+
+	if _, err := db.Query(ctx, "SELECT 1"); !errors.Is(err, cause) {
+		t.Errorf("Expected the canned error, got %v", err)
+	}
+	if _, err := db.Query(ctx, "SELECT\n\t4"); err == nil || !strings.Contains(err.Error(), "unexpected query SELECT 4") {
+		t.Errorf("Expected unexpected queries to fail, got %v", err)
+	}
+
+	var n int
+	rows, _ := db.Query(ctx, "SELECT 2")
+	rows.Next()
+	if err := rows.Scan(&n); err == nil || rows.Err() == nil {
+		t.Error("Expected scanning text into an int to fail")
+	}
+	if err := db.QueryRow(ctx, "SELECT 3").Scan(&n); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("Expected no rows, got %v", err)
+	}
+}
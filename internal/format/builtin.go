@@ -0,0 +1,115 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/lineage"
+	"github.com/guillermo/dbinfo/schemaspy"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+func init() {
+	Register("yaml", "YAML document", "yaml", func(Options) Encoder {
+		return snapshotEncoder{encode: snapshot.WriteYAML, encodeAll: snapshot.WriteYAMLAll}
+	})
+	Register("json", "indented JSON document", "json", func(Options) Encoder {
+		return snapshotEncoder{encode: snapshot.WriteJSON, encodeAll: snapshot.WriteJSONAll}
+	})
+	Register("jsonl", "one JSON object per table, streamed as tables are introspected", "jsonl", func(Options) Encoder { return jsonLines{} })
+	Register("flat", "one tab-separated line per column, for grep and awk", "txt", func(Options) Encoder { return flat{} })
+	Register("schemaspy", "SchemaSpy XML metadata", "xml", func(opts Options) Encoder {
+		return EncoderFunc(func(w io.Writer, info *dbinfo.DBInfo) error {
+			return schemaspy.Encode(w, info, opts.DefaultSchema)
+		})
+	})
+	Register("openlineage", "OpenLineage dataset events with column lineage, one per table and line", "jsonl", func(opts Options) Encoder {
+		return EncoderFunc(func(w io.Writer, info *dbinfo.DBInfo) error {
+			eventTime := opts.EventTime
+			if eventTime.IsZero() {
+				eventTime = time.Now()
+			}
+			return lineage.WriteOpenLineage(w, info, opts.Namespace, eventTime)
+		})
+	})
+	Register("amundsen", "Amundsen column lineage records", "json", func(Options) Encoder {
+		return EncoderFunc(lineage.WriteAmundsen)
+	})
+}
+
+// snapshotEncoder writes snapshots that can also hold several databases
+type snapshotEncoder struct {
+	encode    func(io.Writer, *dbinfo.DBInfo) error
+	encodeAll func(io.Writer, []*dbinfo.DBInfo) error
+}
+
+func (e snapshotEncoder) Encode(w io.Writer, info *dbinfo.DBInfo) error {
+	return e.encode(w, info)
+}
+
+func (e snapshotEncoder) EncodeAll(w io.Writer, infos []*dbinfo.DBInfo) error {
+	return e.encodeAll(w, infos)
+}
+
+// jsonLines writes one JSON object per table and line
+type jsonLines struct{}
+
+func (jsonLines) Encode(w io.Writer, info *dbinfo.DBInfo) error {
+	for _, table := range info.Tables {
+		if err := (jsonLines{}).EncodeTable(w, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jsonLines) EncodeTable(w io.Writer, table *dbinfo.Table) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(table)
+}
+
+// flat writes one line per column: schema.table.column, type, nullability,
+// default and comment separated by tabs. Empty fields are written as "-" so
+// every line has the same number of fields.
+type flat struct{}
+
+func (flat) Encode(w io.Writer, info *dbinfo.DBInfo) error {
+	for _, table := range info.Tables {
+		if err := (flat{}).EncodeTable(w, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (flat) EncodeTable(w io.Writer, table *dbinfo.Table) error {
+	for _, column := range table.Columns {
+		typ := column.FullType
+		if typ == "" {
+			typ = column.Type
+		}
+		nullable := "not null"
+		if column.IsNullable {
+			nullable = "null"
+		}
+		_, err := fmt.Fprintf(w, "%s.%s.%s\t%s\t%s\t%s\t%s\n", table.Schema, table.Name, column.Name,
+			flatField(typ), nullable, flatField(column.DefaultValue), flatField(column.Comment))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flatField keeps a value on a single field of a single line
+func flatField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
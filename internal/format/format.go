@@ -0,0 +1,105 @@
+// Package format holds the registry of output formats supported by the
+// dbinfo command. Each format is an Encoder registered under a name, so new
+// formats become available to every command accepting a -format flag.
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Options configures encoders. Encoders ignore the options they don't use.
+type Options struct {
+	DefaultSchema string    // Schema analyzed by tools that handle one schema at a time
+	Namespace     string    // OpenLineage namespace of the server, such as postgres://db.internal:5432
+	EventTime     time.Time // Time of OpenLineage events, the current time when zero
+}
+
+// Encoder writes a database schema to w
+type Encoder interface {
+	Encode(w io.Writer, info *dbinfo.DBInfo) error
+}
+
+// TableEncoder is implemented by encoders able to write tables one at a
+// time, as soon as they are introspected. Such encoders don't have access to
+// relationships, which need the full table list.
+type TableEncoder interface {
+	Encoder
+	EncodeTable(w io.Writer, table *dbinfo.Table) error
+}
+
+// MultiEncoder is implemented by encoders able to combine several databases
+// in a single document
+type MultiEncoder interface {
+	Encoder
+	EncodeAll(w io.Writer, infos []*dbinfo.DBInfo) error
+}
+
+// EncoderFunc adapts a function to the Encoder interface
+type EncoderFunc func(w io.Writer, info *dbinfo.DBInfo) error
+
+// Encode calls f(w, info)
+func (f EncoderFunc) Encode(w io.Writer, info *dbinfo.DBInfo) error {
+	return f(w, info)
+}
+
+// Factory creates an encoder for the given options
+type Factory func(opts Options) Encoder
+
+type registration struct {
+	description string
+	extension   string
+	factory     Factory
+}
+
+var registry = make(map[string]*registration)
+
+// Register makes a format available under name. The extension, without the
+// leading dot, is used to name the files written in that format. It panics if
+// the name is already registered.
+func Register(name, description, extension string, factory Factory) {
+	if _, ok := registry[name]; ok {
+		panic("format: Register called twice for " + name)
+	}
+	registry[name] = &registration{description: description, extension: extension, factory: factory}
+}
+
+// New returns the encoder registered under name
+func New(name string, opts Options) (Encoder, error) {
+	r, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, available formats: %s", name, strings.Join(Names(), ", "))
+	}
+	return r.factory(opts), nil
+}
+
+// Names returns the registered format names in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Description returns the description a format was registered with
+func Description(name string) string {
+	if r, ok := registry[name]; ok {
+		return r.description
+	}
+	return ""
+}
+
+// Extension returns the file extension a format was registered with
+func Extension(name string) string {
+	if r, ok := registry[name]; ok {
+		return r.extension
+	}
+	return ""
+}
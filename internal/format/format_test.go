@@ -0,0 +1,163 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:    "categories",
+				Schema:  "public",
+				Comment: "Product categories",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+				},
+				HasMany: []*dbinfo.Relationship{
+					{Table: "products", Schema: "public", ForeignKey: "products_category_id_fkey", Columns: []string{"id"}, References: []string{"category_id"}},
+				},
+				BelongsTo: []*dbinfo.Relationship{},
+			},
+			{
+				Name:   "products",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "category_id", Type: "integer"},
+				},
+			},
+		},
+	}
+}
+
+func TestNames(t *testing.T) {
+	expected := []string{"amundsen", "flat", "json", "jsonl", "openlineage", "schemaspy", "yaml"}
+	for _, name := range expected {
+		if Description(name) == "" || Extension(name) == "" {
+			t.Errorf("Expected format %q to be registered with a description and an extension", name)
+		}
+	}
+	if _, err := New("nope", Options{}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test", "test format", "txt", func(Options) Encoder {
+		return EncoderFunc(func(w io.Writer, info *dbinfo.DBInfo) error {
+			_, err := io.WriteString(w, info.Name)
+			return err
+		})
+	})
+	defer delete(registry, "test")
+
+	enc, err := New("test", Options{})
+	if err != nil {
+		t.Fatalf("Failed to create registered encoder: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, testInfo()); err != nil || buf.String() != "shop" {
+		t.Errorf("Unexpected output %q (err %v)", buf.String(), err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a duplicate name to panic")
+		}
+	}()
+	Register("test", "duplicate", "txt", nil)
+}
+
+func TestYAMLAndJSONRoundTrip(t *testing.T) {
+	for _, name := range []string{"yaml", "json"} {
+		t.Run(name, func(t *testing.T) {
+			enc, err := New(name, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := enc.Encode(&buf, testInfo()); err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			decoded := &dbinfo.DBInfo{}
+			if name == "yaml" {
+				err = yaml.Unmarshal(buf.Bytes(), decoded)
+			} else {
+				err = json.Unmarshal(buf.Bytes(), decoded)
+			}
+			if err != nil {
+				t.Fatalf("Failed to decode: %v", err)
+			}
+
+			// Empty relationship slices are omitted from the output
+			expected := testInfo()
+			expected.Tables[0].BelongsTo = nil
+			if diff := cmp.Diff(expected, decoded); diff != "" {
+				t.Errorf("Round trip mismatch (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSONLines(t *testing.T) {
+	enc, err := New("jsonl", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := enc.(TableEncoder); !ok {
+		t.Fatal("Expected jsonl to support streaming tables")
+	}
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, testInfo()); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per table, got %d", len(lines))
+	}
+	var table dbinfo.Table
+	if err := json.Unmarshal([]byte(lines[1]), &table); err != nil || table.Name != "products" {
+		t.Errorf("Unexpected second line %q (err %v)", lines[1], err)
+	}
+}
+
+func TestFlat(t *testing.T) {
+	enc, err := New("flat", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := enc.(TableEncoder); !ok {
+		t.Fatal("Expected flat to support streaming tables")
+	}
+
+	info := testInfo()
+	info.Tables[1].Columns[1].IsNullable = true
+	info.Tables[1].Columns[1].Comment = "Category of the\nproduct"
+	info.Tables[1].Columns = append(info.Tables[1].Columns, &dbinfo.Column{
+		Name: "name", Type: "character varying", FullType: "character varying(100)", DefaultValue: "''::character varying",
+	})
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, info); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	expected := "public.categories.id\tinteger\tnot null\t-\t-\n" +
+		"public.products.id\tinteger\tnot null\t-\t-\n" +
+		"public.products.category_id\tinteger\tnull\t-\tCategory of the product\n" +
+		"public.products.name\tcharacter varying(100)\tnot null\t''::character varying\t-\n"
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected flat output (-expected +actual):\n%s", diff)
+	}
+}
@@ -0,0 +1,123 @@
+// Package indexes compares the indexes of a table to find the ones serving
+// lookups on a list of columns and the ones another index already serves.
+// It backs the index rules of lint and the recommendations of advisor.
+package indexes
+
+import (
+	"slices"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// PrimaryKey returns the names of the primary key columns of a table, in
+// key order. Snapshots taken before the key was recorded only flag its
+// columns, which are returned in table order.
+func PrimaryKey(table *dbinfo.Table) []string {
+	if table.PrimaryKey != nil && len(table.PrimaryKey.Columns) > 0 {
+		return table.PrimaryKey.Columns
+	}
+	var names []string
+	for _, column := range table.Columns {
+		if column.IsPrimaryKey {
+			names = append(names, column.Name)
+		}
+	}
+	return names
+}
+
+// Keys returns the column lists usable for lookups on a table: the primary
+// key and every plain (non expression) index
+func Keys(table *dbinfo.Table) [][]string {
+	var keys [][]string
+	if pk := PrimaryKey(table); len(pk) > 0 {
+		keys = append(keys, pk)
+	}
+	for _, index := range table.Indexes {
+		if index.Expression == "" {
+			keys = append(keys, index.Columns)
+		}
+	}
+	return keys
+}
+
+// Serves reports whether the leading columns of key are exactly columns, in
+// any order. Such a key serves equality lookups on all of columns, as a
+// foreign key does when joining or deleting.
+func Serves(key, columns []string) bool {
+	if len(columns) == 0 || len(key) < len(columns) {
+		return false
+	}
+	leading := slices.Clone(key[:len(columns)])
+	wanted := slices.Clone(columns)
+	slices.Sort(leading)
+	slices.Sort(wanted)
+	return slices.Equal(leading, wanted)
+}
+
+// IsPrefix reports whether columns are the leading columns of key, in the
+// same order. An index on (a, b) serves every query an index on (a) does,
+// but not one on (b).
+func IsPrefix(key, columns []string) bool {
+	return len(columns) > 0 && len(columns) <= len(key) && slices.Equal(key[:len(columns)], columns)
+}
+
+// Equal reports whether two indexes have the same keys in the same order
+func Equal(a, b *dbinfo.Index) bool {
+	return a.Expression == b.Expression && slices.Equal(a.Columns, b.Columns)
+}
+
+// Redundancy is an index that the primary key or another index already
+// serves
+type Redundancy struct {
+	Index *dbinfo.Index
+	// Of is the index serving it, nil when it duplicates the primary key
+	Of     *dbinfo.Index
+	Prefix bool // Index is a prefix of Of rather than a duplicate
+}
+
+// Reason describes the redundancy, such as "duplicates the primary key" or
+// "is a prefix of orders_customer_id_created_at_idx"
+func (r Redundancy) Reason() string {
+	switch {
+	case r.Of == nil:
+		return "duplicates the primary key"
+	case r.Prefix:
+		return "is a prefix of " + r.Of.Name
+	}
+	return "duplicates " + r.Of.Name
+}
+
+// Redundant returns the indexes of a table duplicating the primary key or
+// another index, and the non unique indexes that are a prefix of a wider
+// index, in index order. Of a pair of duplicates only one is returned: the
+// non unique one, or the one sorting last when both are alike.
+func Redundant(table *dbinfo.Table) []Redundancy {
+	pk := PrimaryKey(table)
+	var found []Redundancy
+	for i, index := range table.Indexes {
+		if index.Expression == "" && len(pk) > 0 && slices.Equal(index.Columns, pk) {
+			found = append(found, Redundancy{Index: index})
+			continue
+		}
+		for j, other := range table.Indexes {
+			if i == j {
+				continue
+			}
+			if Equal(index, other) {
+				if (!index.Unique && other.Unique) || (index.Unique == other.Unique && index.Name > other.Name) {
+					found = append(found, Redundancy{Index: index, Of: other})
+					break
+				}
+				continue
+			}
+			// A unique index enforces a constraint, so it is never
+			// redundant even when a wider index covers it
+			if !index.Unique && index.Expression == "" && other.Expression == "" &&
+				len(index.Columns) < len(other.Columns) && IsPrefix(other.Columns, index.Columns) {
+				found = append(found, Redundancy{Index: index, Of: other, Prefix: true})
+				break
+			}
+		}
+	}
+	return found
+}
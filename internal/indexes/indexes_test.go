@@ -0,0 +1,93 @@
+package indexes
+
+import (
+	"testing"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func TestServes(t *testing.T) {
+	tests := []struct {
+		key, columns []string
+		expected     bool
+	}{
+		{[]string{"a", "b"}, []string{"a"}, true},
+		{[]string{"a", "b"}, []string{"b", "a"}, true},
+		{[]string{"a", "b"}, []string{"b"}, false},
+		{[]string{"a"}, []string{"a", "b"}, false},
+		{[]string{"a"}, nil, false},
+	}
+	for _, test := range tests {
+		if got := Serves(test.key, test.columns); got != test.expected {
+			t.Errorf("Serves(%v, %v) = %v, expected %v", test.key, test.columns, got, test.expected)
+		}
+	}
+}
+
+func TestRedundant(t *testing.T) {
+	table := &dbinfo.Table{
+		Schema: "public",
+		Name:   "orders",
+		Columns: []*dbinfo.Column{
+			{Name: "id", IsPrimaryKey: true},
+			{Name: "customer_id"},
+			{Name: "created_at"},
+		},
+		Indexes: []*dbinfo.Index{
+			{Name: "orders_id_idx", Columns: []string{"id"}},
+			{Name: "orders_customer_idx", Columns: []string{"customer_id"}},
+			{Name: "orders_customer_created_idx", Columns: []string{"customer_id", "created_at"}},
+			{Name: "orders_created_customer_idx", Columns: []string{"created_at", "customer_id"}},
+			{Name: "orders_created_customer_idx2", Columns: []string{"created_at", "customer_id"}},
+			{Name: "orders_lower_idx", Expression: "lower(notes)"},
+		},
+	}
+
+	var got []string
+	for _, r := range Redundant(table) {
+		got = append(got, r.Index.Name+" "+r.Reason())
+	}
+	// The two indexes with the same columns in another order serve
+	// different queries, so neither is redundant with the other
+	expected := []string{
+		"orders_id_idx duplicates the primary key",
+		"orders_customer_idx is a prefix of orders_customer_created_idx",
+		"orders_created_customer_idx2 duplicates orders_created_customer_idx",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d redundant indexes, got %q", len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %q, got %q", expected[i], got[i])
+		}
+	}
+}
+
+func TestRedundantKeyOrder(t *testing.T) {
+	// The key order differs from the column order
+	table := &dbinfo.Table{
+		Schema: "public",
+		Name:   "order_items",
+		Columns: []*dbinfo.Column{
+			{Name: "order_id", IsPrimaryKey: true},
+			{Name: "product_id", IsPrimaryKey: true},
+		},
+		PrimaryKey: &dbinfo.PrimaryKey{Name: "order_items_pkey", Columns: []string{"product_id", "order_id"}},
+		Indexes: []*dbinfo.Index{
+			{Name: "order_items_order_product_idx", Columns: []string{"order_id", "product_id"}},
+			{Name: "order_items_product_order_idx", Columns: []string{"product_id", "order_id"}},
+		},
+	}
+
+	found := Redundant(table)
+	if len(found) != 1 || found[0].Index.Name != "order_items_product_order_idx" || found[0].Of != nil {
+		t.Errorf("Expected only the index in key order to duplicate the primary key, got %+v", found)
+	}
+
+	// Snapshots without the key fall back to the column flags
+	table.PrimaryKey = nil
+	if got := PrimaryKey(table); len(got) != 2 || got[0] != "order_id" {
+		t.Errorf("Expected the flagged columns in table order, got %q", got)
+	}
+}
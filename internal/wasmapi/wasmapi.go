@@ -0,0 +1,110 @@
+// Package wasmapi holds the offline functions of dbinfo exported to
+// JavaScript by cmd/dbinfo-wasm: loading snapshots, comparing them and
+// rendering diagrams. Functions take and return strings, so the JavaScript
+// side only deals with text and JSON.
+package wasmapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/erd"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// Load parses a YAML or JSON snapshot and returns it as JSON, the form the
+// other functions and JavaScript code read most easily
+func Load(text string) (string, error) {
+	info, err := snapshot.Parse([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := snapshot.WriteJSON(&buf, info); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Diff compares two YAML or JSON snapshots and returns the changes from the
+// first to the second as a JSON array
+func Diff(from, to string) (string, error) {
+	fromInfo, err := snapshot.Parse([]byte(from))
+	if err != nil {
+		return "", fmt.Errorf("from: %w", err)
+	}
+	toInfo, err := snapshot.Parse([]byte(to))
+	if err != nil {
+		return "", fmt.Errorf("to: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := diff.WriteJSON(&buf, diff.Compare(fromInfo, toInfo)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderOptions are the diagram options of Render, as a JSON object with the
+// names of the 'dbinfo erd' flags
+type RenderOptions struct {
+	Cluster         string `json:"cluster"`   // none (default), schema or component
+	Direction       string `json:"direction"` // LR (default), RL, TB or BT
+	CollapseLookups bool   `json:"collapseLookups"`
+}
+
+// Render draws the diagram of a YAML or JSON snapshot as mermaid, dot or svg.
+// options is a JSON RenderOptions object, and may be empty.
+func Render(text, format, options string) (string, error) {
+	var render func(io.Writer, *dbinfo.DBInfo, ...erd.Option) error
+	switch format {
+	case "mermaid", "":
+		render = erd.Mermaid
+	case "dot":
+		render = erd.DOT
+	case "svg":
+		render = erd.SVG
+	default:
+		return "", fmt.Errorf("unknown diagram format %q, expected mermaid, dot or svg", format)
+	}
+
+	var o RenderOptions
+	if options != "" {
+		if err := json.Unmarshal([]byte(options), &o); err != nil {
+			return "", fmt.Errorf("invalid options: %w", err)
+		}
+	}
+	var opts []erd.Option
+	switch o.Direction {
+	case "":
+	case "LR", "RL", "TB", "BT":
+		opts = append(opts, erd.WithDirection(o.Direction))
+	default:
+		return "", fmt.Errorf("unknown direction %q, expected LR, RL, TB or BT", o.Direction)
+	}
+	switch o.Cluster {
+	case "", "none":
+	case "schema":
+		opts = append(opts, erd.WithClusters(erd.BySchema))
+	case "component":
+		opts = append(opts, erd.WithClusters(erd.ByComponent))
+	default:
+		return "", fmt.Errorf("unknown clustering %q, expected none, schema or component", o.Cluster)
+	}
+	if o.CollapseLookups {
+		opts = append(opts, erd.WithCollapsedLookups())
+	}
+
+	info, err := snapshot.Parse([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := render(&buf, info, opts...); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
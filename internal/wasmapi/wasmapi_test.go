@@ -0,0 +1,102 @@
+package wasmapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const before = `
+name: shop
+tables:
+  - schema: public
+    name: customers
+    columns:
+      - {name: id, type: integer, isprimarykey: true}
+  - schema: public
+    name: orders
+    columns:
+      - {name: id, type: integer, isprimarykey: true}
+      - {name: customer_id, type: integer}
+    foreignkeys:
+      - name: orders_customer_id_fkey
+        columnnames: [customer_id]
+        reftableschema: public
+        reftablename: customers
+        refcolumnnames: [id]
+`
+
+func TestLoad(t *testing.T) {
+	got, err := Load(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		Name   string `json:"name"`
+		Tables []any  `json:"tables"`
+	}
+	if err := json.Unmarshal([]byte(got), &info); err != nil {
+		t.Fatalf("Load returned invalid JSON: %v", err)
+	}
+	if info.Name != "shop" || len(info.Tables) != 2 {
+		t.Errorf("Load = %s, want the shop snapshot with 2 tables", got)
+	}
+
+	if _, err := Load("tables: ["); err == nil {
+		t.Error("Expected an error for an invalid snapshot")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	after := strings.Replace(before, "      - {name: customer_id, type: integer}\n", "      - {name: customer_id, type: integer}\n      - {name: total, type: numeric}\n", 1)
+	got, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []map[string]any
+	if err := json.Unmarshal([]byte(got), &changes); err != nil {
+		t.Fatalf("Diff returned invalid JSON: %v", err)
+	}
+	if len(changes) != 1 || !strings.Contains(got, "total") {
+		t.Errorf("Diff = %s, want the added total column", got)
+	}
+
+	if got, err := Diff(before, before); err != nil || strings.TrimSpace(got) != "[]" {
+		t.Errorf("Diff of equal snapshots = %q, %v, want []", got, err)
+	}
+	if _, err := Diff(before, "tables: ["); err == nil || !strings.HasPrefix(err.Error(), "to: ") {
+		t.Errorf("Diff error = %v, want an error about the second snapshot", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format, options, contains string
+	}{
+		{"", "", "customers ||--o{ orders"},
+		{"mermaid", "{}", "erDiagram"},
+		{"dot", `{"direction": "TB"}`, "rankdir=TB"},
+		{"dot", `{"cluster": "schema"}`, "subgraph cluster_"},
+		{"svg", `{"collapseLookups": true}`, "<svg"},
+	}
+	for _, tt := range tests {
+		got, err := Render(before, tt.format, tt.options)
+		if err != nil {
+			t.Errorf("Render(%q, %q) error: %v", tt.format, tt.options, err)
+			continue
+		}
+		if !strings.Contains(got, tt.contains) {
+			t.Errorf("Render(%q, %q) doesn't contain %q:\n%s", tt.format, tt.options, tt.contains, got)
+		}
+	}
+
+	for _, tt := range []struct{ format, options, err string }{
+		{"png", "", `unknown diagram format "png", expected mermaid, dot or svg`},
+		{"dot", `{"direction": "up"}`, `unknown direction "up", expected LR, RL, TB or BT`},
+		{"dot", `{"cluster": "tag"}`, `unknown clustering "tag", expected none, schema or component`},
+	} {
+		if _, err := Render(before, tt.format, tt.options); err == nil || err.Error() != tt.err {
+			t.Errorf("Render(%q, %q) error = %v, want %q", tt.format, tt.options, err, tt.err)
+		}
+	}
+}
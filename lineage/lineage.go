@@ -0,0 +1,272 @@
+// Package lineage exports the column lineage found in dbinfo schemas, so
+// data catalogs can ingest the relationships dbinfo discovers. Each foreign
+// key column is an edge from the referenced column to the referencing one,
+// and each view column is an edge from the column of the same name in each
+// relation the view reads.
+//
+// Two formats are written:
+//
+//   - OpenLineage (https://openlineage.io): one DatasetEvent per table and
+//     line, with the schema and columnLineage dataset facets, ready to post
+//     to an OpenLineage endpoint such as Marquez.
+//   - Amundsen (https://www.amundsen.io): a JSON array of column lineage
+//     records, as the databuilder ColumnLineage model takes them.
+package lineage
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Producer identifies dbinfo as the producer of OpenLineage events
+const Producer = "https://github.com/guillermo/dbinfo"
+
+// OpenLineage schema URLs of the events and facets written
+const (
+	datasetEventSchemaURL = "https://openlineage.io/spec/2-0-2/OpenLineage.json#/$defs/DatasetEvent"
+	schemaFacetURL        = "https://openlineage.io/spec/facets/1-1-1/SchemaDatasetFacet.json#/$defs/SchemaDatasetFacet"
+	columnLineageFacetURL = "https://openlineage.io/spec/facets/1-2-0/ColumnLineageDatasetFacet.json#/$defs/ColumnLineageDatasetFacet"
+)
+
+// DefaultNamespace is the OpenLineage namespace used when none is given
+const DefaultNamespace = "postgres://localhost:5432"
+
+// Column identifies a table column
+type Column struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// Edge is a lineage edge: the values of Target come from Source
+type Edge struct {
+	Source     Column `json:"source"`
+	Target     Column `json:"target"`
+	ForeignKey string `json:"foreignKey,omitempty"` // Constraint the edge comes from
+	View       string `json:"view,omitempty"`       // Or view or materialized view, whose Target is a column
+}
+
+// Edges returns the lineage edges of a schema: those of the foreign keys,
+// in table and foreign key order, then those of the views and materialized
+// views. Composite foreign keys produce one edge per column pair. The
+// catalog records which columns a view reads but not which output column
+// each one feeds, so a view column is only linked to the columns of the
+// same name it reads; computed columns, such as count(*) AS total, have no
+// edge.
+func Edges(info *dbinfo.DBInfo) []*Edge {
+	var edges []*Edge
+	for _, table := range info.Tables {
+		for _, fk := range table.ForeignKeys {
+			for i, name := range fk.ColumnNames {
+				if i >= len(fk.RefColumnNames) {
+					break
+				}
+				edges = append(edges, &Edge{
+					Source:     Column{Schema: fk.RefTableSchema, Table: fk.RefTableName, Column: fk.RefColumnNames[i]},
+					Target:     Column{Schema: table.Schema, Table: table.Name, Column: name},
+					ForeignKey: fk.Name,
+				})
+			}
+		}
+	}
+	viewEdges := func(schema, name string, columns []*dbinfo.Column, sources []*dbinfo.ViewSource) {
+		for _, column := range columns {
+			for _, source := range sources {
+				if !slices.Contains(source.Columns, column.Name) {
+					continue
+				}
+				edges = append(edges, &Edge{
+					Source: Column{Schema: source.Schema, Table: source.Name, Column: column.Name},
+					Target: Column{Schema: schema, Table: name, Column: column.Name},
+					View:   name,
+				})
+			}
+		}
+	}
+	for _, view := range info.Views {
+		viewEdges(view.Schema, view.Name, view.Columns, view.Sources)
+	}
+	for _, view := range info.MaterializedViews {
+		viewEdges(view.Schema, view.Name, view.Columns, view.Sources)
+	}
+	return edges
+}
+
+// datasetEvent is an OpenLineage DatasetEvent
+type datasetEvent struct {
+	EventTime string  `json:"eventTime"`
+	Producer  string  `json:"producer"`
+	SchemaURL string  `json:"schemaURL"`
+	Dataset   dataset `json:"dataset"`
+}
+
+type dataset struct {
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Facets    datasetFacets `json:"facets"`
+}
+
+type datasetFacets struct {
+	Schema        *schemaFacet        `json:"schema,omitempty"`
+	ColumnLineage *columnLineageFacet `json:"columnLineage,omitempty"`
+}
+
+type facet struct {
+	Producer  string `json:"_producer"`
+	SchemaURL string `json:"_schemaURL"`
+}
+
+type schemaFacet struct {
+	facet
+	Fields []schemaField `json:"fields"`
+}
+
+type schemaField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type columnLineageFacet struct {
+	facet
+	Fields map[string]*columnLineage `json:"fields"`
+}
+
+type columnLineage struct {
+	InputFields []inputField `json:"inputFields"`
+}
+
+type inputField struct {
+	Namespace       string           `json:"namespace"`
+	Name            string           `json:"name"`
+	Field           string           `json:"field"`
+	Transformations []transformation `json:"transformations"`
+}
+
+type transformation struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	Description string `json:"description"`
+}
+
+// WriteOpenLineage writes one OpenLineage DatasetEvent per table, view and
+// materialized view, and line.
+// Datasets are named database.schema.table in namespace, which follows the
+// OpenLineage naming of PostgreSQL servers: postgres://host:port. An empty
+// namespace is written as DefaultNamespace.
+func WriteOpenLineage(w io.Writer, info *dbinfo.DBInfo, namespace string, eventTime time.Time) error {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	datasetName := func(schema, table string) string {
+		return info.Name + "." + schema + "." + table
+	}
+	lineage := make(map[string]map[string]*columnLineage)
+	for _, edge := range Edges(info) {
+		key := datasetName(edge.Target.Schema, edge.Target.Table)
+		if lineage[key] == nil {
+			lineage[key] = make(map[string]*columnLineage)
+		}
+		fields := lineage[key]
+		if fields[edge.Target.Column] == nil {
+			fields[edge.Target.Column] = &columnLineage{}
+		}
+		description := "foreign key " + edge.ForeignKey
+		if edge.View != "" {
+			description = "view " + edge.View
+		}
+		fields[edge.Target.Column].InputFields = append(fields[edge.Target.Column].InputFields, inputField{
+			Namespace: namespace,
+			Name:      datasetName(edge.Source.Schema, edge.Source.Table),
+			Field:     edge.Source.Column,
+			Transformations: []transformation{{
+				Type:        "DIRECT",
+				Subtype:     "IDENTITY",
+				Description: description,
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	write := func(schema, table string, columns []*dbinfo.Column) error {
+		name := datasetName(schema, table)
+		event := datasetEvent{
+			EventTime: eventTime.UTC().Format(time.RFC3339Nano),
+			Producer:  Producer,
+			SchemaURL: datasetEventSchemaURL,
+			Dataset: dataset{
+				Namespace: namespace,
+				Name:      name,
+				Facets: datasetFacets{
+					Schema: &schemaFacet{facet: facet{Producer, schemaFacetURL}, Fields: []schemaField{}},
+				},
+			},
+		}
+		for _, column := range columns {
+			typ := column.FullType
+			if typ == "" {
+				typ = column.Type
+			}
+			event.Dataset.Facets.Schema.Fields = append(event.Dataset.Facets.Schema.Fields, schemaField{Name: column.Name, Type: typ, Description: column.Comment})
+		}
+		if fields := lineage[name]; fields != nil {
+			event.Dataset.Facets.ColumnLineage = &columnLineageFacet{facet: facet{Producer, columnLineageFacetURL}, Fields: fields}
+		}
+		return enc.Encode(event)
+	}
+	for _, table := range info.Tables {
+		if err := write(table.Schema, table.Name, table.Columns); err != nil {
+			return err
+		}
+	}
+	for _, view := range info.Views {
+		if err := write(view.Schema, view.Name, view.Columns); err != nil {
+			return err
+		}
+	}
+	for _, view := range info.MaterializedViews {
+		if err := write(view.Schema, view.Name, view.Columns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// amundsenLineage is a column lineage record of Amundsen's databuilder
+type amundsenLineage struct {
+	ColumnKey      string   `json:"column_key"`
+	DownstreamDeps []string `json:"downstream_deps"`
+}
+
+// WriteAmundsen writes the column lineage as a JSON array of Amundsen
+// records. Columns are keyed as postgres://database.schema/table/column,
+// the keys of the databuilder PostgreSQL extractor when it uses the
+// database as the cluster name. Each record lists the columns referencing a
+// column.
+func WriteAmundsen(w io.Writer, info *dbinfo.DBInfo) error {
+	key := func(c Column) string {
+		return "postgres://" + info.Name + "." + c.Schema + "/" + c.Table + "/" + c.Column
+	}
+	records := []*amundsenLineage{}
+	bySource := make(map[string]*amundsenLineage)
+	for _, edge := range Edges(info) {
+		source := key(edge.Source)
+		record := bySource[source]
+		if record == nil {
+			record = &amundsenLineage{ColumnKey: source}
+			bySource[source] = record
+			records = append(records, record)
+		}
+		record.DownstreamDeps = append(record.DownstreamDeps, key(edge.Target))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
@@ -0,0 +1,157 @@
+package lineage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema:  "public",
+				Name:    "customers",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer"}, {Name: "region", Type: "text"}},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "customer_id", Type: "integer", Comment: "Buyer"},
+					{Name: "region", Type: "character varying", FullType: "character varying(10)"},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{{
+					Name:           "orders_customer_fkey",
+					ColumnNames:    []string{"customer_id", "region"},
+					RefTableSchema: "public",
+					RefTableName:   "customers",
+					RefColumnNames: []string{"id", "region"},
+				}},
+			},
+			{
+				Schema:      "billing",
+				Name:        "invoices",
+				Columns:     []*dbinfo.Column{{Name: "customer_id", Type: "integer"}},
+				ForeignKeys: []*dbinfo.ForeignKey{{Name: "invoices_customer_id_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: "public", RefTableName: "customers", RefColumnNames: []string{"id"}}},
+			},
+		},
+	}
+}
+
+func TestEdges(t *testing.T) {
+	expected := []*Edge{
+		{Source: Column{"public", "customers", "id"}, Target: Column{"public", "orders", "customer_id"}, ForeignKey: "orders_customer_fkey"},
+		{Source: Column{"public", "customers", "region"}, Target: Column{"public", "orders", "region"}, ForeignKey: "orders_customer_fkey"},
+		{Source: Column{"public", "customers", "id"}, Target: Column{"billing", "invoices", "customer_id"}, ForeignKey: "invoices_customer_id_fkey"},
+	}
+	if diff := cmp.Diff(expected, Edges(testInfo())); diff != "" {
+		t.Errorf("Unexpected edges (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestViewEdges(t *testing.T) {
+	info := testInfo()
+	info.Views = []*dbinfo.View{{
+		Schema:  "public",
+		Name:    "regional_orders",
+		Columns: []*dbinfo.Column{{Name: "region"}, {Name: "id"}, {Name: "total"}},
+		Sources: []*dbinfo.ViewSource{
+			{Schema: "public", Name: "customers", Columns: []string{"id", "region"}},
+			{Schema: "public", Name: "orders", Columns: []string{"customer_id", "id"}},
+		},
+	}}
+	info.MaterializedViews = []*dbinfo.MaterializedView{{
+		Schema:  "reports",
+		Name:    "regions",
+		Columns: []*dbinfo.Column{{Name: "region"}},
+		Sources: []*dbinfo.ViewSource{{Schema: "public", Name: "regional_orders", Columns: []string{"region"}}},
+	}}
+	expected := []*Edge{
+		{Source: Column{"public", "customers", "region"}, Target: Column{"public", "regional_orders", "region"}, View: "regional_orders"},
+		{Source: Column{"public", "customers", "id"}, Target: Column{"public", "regional_orders", "id"}, View: "regional_orders"},
+		{Source: Column{"public", "orders", "id"}, Target: Column{"public", "regional_orders", "id"}, View: "regional_orders"},
+		{Source: Column{"public", "regional_orders", "region"}, Target: Column{"reports", "regions", "region"}, View: "regions"},
+	}
+	if diff := cmp.Diff(expected, Edges(info)[3:]); diff != "" {
+		t.Errorf("Unexpected view edges (-expected +actual):\n%s", diff)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteOpenLineage(&buf, info, "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 5 {
+		t.Errorf("Expected an event per table and view, got %d", lines)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"description":"view regional_orders"`)) {
+		t.Errorf("Expected the view lineage in the events:\n%s", buf.String())
+	}
+}
+
+func TestWriteOpenLineage(t *testing.T) {
+	var buf bytes.Buffer
+	info := testInfo()
+	info.Tables = info.Tables[1:2]
+	if err := WriteOpenLineage(&buf, info, "postgres://db.internal:5432", time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"eventTime":"2025-03-01T12:00:00Z","producer":"https://github.com/guillermo/dbinfo","schemaURL":"https://openlineage.io/spec/2-0-2/OpenLineage.json#/$defs/DatasetEvent",` +
+		`"dataset":{"namespace":"postgres://db.internal:5432","name":"shop.public.orders","facets":{` +
+		`"schema":{"_producer":"https://github.com/guillermo/dbinfo","_schemaURL":"https://openlineage.io/spec/facets/1-1-1/SchemaDatasetFacet.json#/$defs/SchemaDatasetFacet",` +
+		`"fields":[{"name":"id","type":"integer"},{"name":"customer_id","type":"integer","description":"Buyer"},{"name":"region","type":"character varying(10)"}]},` +
+		`"columnLineage":{"_producer":"https://github.com/guillermo/dbinfo","_schemaURL":"https://openlineage.io/spec/facets/1-2-0/ColumnLineageDatasetFacet.json#/$defs/ColumnLineageDatasetFacet",` +
+		`"fields":{"customer_id":{"inputFields":[{"namespace":"postgres://db.internal:5432","name":"shop.public.customers","field":"id","transformations":[{"type":"DIRECT","subtype":"IDENTITY","description":"foreign key orders_customer_fkey"}]}]},` +
+		`"region":{"inputFields":[{"namespace":"postgres://db.internal:5432","name":"shop.public.customers","field":"region","transformations":[{"type":"DIRECT","subtype":"IDENTITY","description":"foreign key orders_customer_fkey"}]}]}}}}}}` + "\n"
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected events (-expected +actual):\n%s", diff)
+	}
+
+	// Tables without foreign keys only have a schema facet, one event per line
+	buf.Reset()
+	if err := WriteOpenLineage(&buf, testInfo(), "", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 3 {
+		t.Errorf("Expected an event per table, got %d", lines)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"namespace":"postgres://localhost:5432","name":"shop.public.customers","facets":{"schema"`)) {
+		t.Errorf("Unexpected events:\n%s", buf.String())
+	}
+}
+
+func TestWriteAmundsen(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAmundsen(&buf, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[
+  {
+    "column_key": "postgres://shop.public/customers/id",
+    "downstream_deps": [
+      "postgres://shop.public/orders/customer_id",
+      "postgres://shop.billing/invoices/customer_id"
+    ]
+  },
+  {
+    "column_key": "postgres://shop.public/customers/region",
+    "downstream_deps": [
+      "postgres://shop.public/orders/region"
+    ]
+  }
+]
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected records (-expected +actual):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteAmundsen(&buf, &dbinfo.DBInfo{Name: "empty"}); err != nil || buf.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,161 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/chains"
+	"github.com/guillermo/dbinfo/internal/indexes"
+)
+
+func init() {
+	Register(&Rule{
+		Name:        "table-without-primary-key",
+		Description: "Tables should have a primary key, so rows can be identified, replicated and referenced.",
+		Severity:    Error,
+		Check:       checkPrimaryKeys,
+	})
+	Register(&Rule{
+		Name:        "nullable-foreign-key",
+		Description: "Foreign key columns should be NOT NULL unless the relationship is optional.",
+		Severity:    Info,
+		Check:       checkNullableForeignKeys,
+	})
+	Register(&Rule{
+		Name:        "fk-type-mismatch",
+		Description: "Foreign key columns should have the same type as the columns they reference.",
+		Severity:    Error,
+		Check:       checkForeignKeyTypes,
+	})
+	Register(&Rule{
+		Name:        "cascade-depth",
+		Description: "ON DELETE CASCADE chains should not be deeper than the max-depth option (3 by default).",
+		Severity:    Warning,
+		Check:       checkCascadeDepth,
+	})
+	Register(&Rule{
+		Name:        "sequence-exhaustion",
+		Description: "Sequences should not have handed out more than the max-used option (75 by default) percent of their values.",
+		Severity:    Warning,
+		Check:       checkSequenceExhaustion,
+	})
+}
+
+func checkPrimaryKeys(c *Context) {
+	for _, table := range c.Info.Tables {
+		if len(indexes.PrimaryKey(table)) == 0 {
+			c.Report(TableLocation(table), "table has no primary key")
+		}
+	}
+}
+
+// checkSequenceExhaustion reports the sequences close to their last value,
+// at the column they number when they have one. Cycling sequences wrap
+// around instead of failing.
+func checkSequenceExhaustion(c *Context) {
+	max := float64(c.Int("max-used", 75)) / 100
+	for _, s := range c.Info.Sequences {
+		if s.Cycle || s.Used() <= max {
+			continue
+		}
+		loc := Location{Schema: s.Schema, Table: s.Name}
+		if s.OwnedBy != nil {
+			loc = Location{Schema: s.OwnedBy.Schema, Table: s.OwnedBy.Table, Column: s.OwnedBy.Column}
+		}
+		c.Report(loc, "sequence %s has used %.0f%% of its values, %d of %d", s.Name, s.Used()*100, *s.LastValue, s.Max)
+	}
+}
+
+func checkNullableForeignKeys(c *Context) {
+	for _, table := range c.Info.Tables {
+		for _, fk := range table.ForeignKeys {
+			for _, name := range fk.ColumnNames {
+				if column := findColumn(table, name); column != nil && column.IsNullable {
+					c.Report(Location{Schema: table.Schema, Table: table.Name, Column: name},
+						"column references %s.%s but is nullable", fk.RefTableSchema, fk.RefTableName)
+				}
+			}
+		}
+	}
+}
+
+func checkForeignKeyTypes(c *Context) {
+	tables := tablesByKey(c.Info)
+	for _, table := range c.Info.Tables {
+		for _, fk := range table.ForeignKeys {
+			// The referenced table may have been left out of the snapshot
+			ref := tables[dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}]
+			if ref == nil {
+				continue
+			}
+			for i, name := range fk.ColumnNames {
+				if i >= len(fk.RefColumnNames) {
+					break
+				}
+				column, refColumn := findColumn(table, name), findColumn(ref, fk.RefColumnNames[i])
+				if column == nil || refColumn == nil {
+					continue
+				}
+				if from, to := columnType(column, refColumn), columnType(refColumn, column); from != to {
+					c.Report(Location{Schema: table.Schema, Table: table.Name, Column: name},
+						"column is %s but references %s.%s.%s of type %s", from, ref.Schema, ref.Name, refColumn.Name, to)
+				}
+			}
+		}
+	}
+}
+
+func checkCascadeDepth(c *Context) {
+	max := c.Int("max-depth", 3)
+
+	// children maps each table to the tables whose rows are deleted with it
+	children := make(map[dbinfo.ObjectKey][]dbinfo.ObjectKey)
+	for _, table := range c.Info.Tables {
+		for _, fk := range table.ForeignKeys {
+			if fk.OnDelete == "CASCADE" {
+				parent := dbinfo.ObjectKey{Schema: fk.RefTableSchema, Name: fk.RefTableName}
+				children[parent] = append(children[parent], dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name})
+			}
+		}
+	}
+
+	for _, table := range c.Info.Tables {
+		// Each table starts a walk of its own, so cycles are cut where they
+		// close back on it
+		chain := chains.New(children).From(dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name})
+		if depth := len(chain) - 1; depth > max {
+			names := make([]string, len(chain))
+			for i, key := range chain {
+				names[i] = key.String()
+			}
+			c.Report(TableLocation(table), "deleting a row cascades %d levels deep, more than %d: %s",
+				depth, max, strings.Join(names, " -> "))
+		}
+	}
+}
+
+// findColumn returns the column of table named name, or nil
+func findColumn(table *dbinfo.Table, name string) *dbinfo.Column {
+	for _, column := range table.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// columnType returns the most precise type available on both columns
+func columnType(column, other *dbinfo.Column) string {
+	if column.FullType != "" && other.FullType != "" {
+		return column.FullType
+	}
+	return column.Type
+}
+
+func tablesByKey(info *dbinfo.DBInfo) map[dbinfo.ObjectKey]*dbinfo.Table {
+	tables := make(map[dbinfo.ObjectKey]*dbinfo.Table, len(info.Tables))
+	for _, table := range info.Tables {
+		tables[dbinfo.ObjectKey{Schema: table.Schema, Name: table.Name}] = table
+	}
+	return tables
+}
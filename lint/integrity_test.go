@@ -0,0 +1,146 @@
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+// cascadeTable returns a table with an id primary key and, when parent is
+// set, a parent_id column deleted in cascade with the parent
+func cascadeTable(name, parent string) *dbinfo.Table {
+	table := &dbinfo.Table{
+		Name:    name,
+		Schema:  "public",
+		Columns: []*dbinfo.Column{{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true}},
+	}
+	if parent != "" {
+		table.Columns = append(table.Columns, &dbinfo.Column{Name: "parent_id", Type: "integer", FullType: "integer"})
+		table.ForeignKeys = []*dbinfo.ForeignKey{{
+			Name:           name + "_parent_id_fkey",
+			ColumnNames:    []string{"parent_id"},
+			RefTableSchema: "public",
+			RefTableName:   parent,
+			RefColumnNames: []string{"id"},
+			OnDelete:       "CASCADE",
+		}}
+	}
+	return table
+}
+
+func integrityInfo() *dbinfo.DBInfo {
+	orders := &dbinfo.Table{
+		Name:   "orders",
+		Schema: "public",
+		Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true},
+			{Name: "customer_id", Type: "bigint", FullType: "bigint", IsNullable: true},
+			{Name: "region_code", Type: "character varying", FullType: "character varying(2)"},
+		},
+		ForeignKeys: []*dbinfo.ForeignKey{
+			{Name: "orders_customer_id_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: "public", RefTableName: "customers", RefColumnNames: []string{"id"}},
+			{Name: "orders_region_code_fkey", ColumnNames: []string{"region_code"}, RefTableSchema: "public", RefTableName: "regions", RefColumnNames: []string{"code"}},
+			{Name: "orders_missing_fkey", ColumnNames: []string{"id"}, RefTableSchema: "other", RefTableName: "missing", RefColumnNames: []string{"id"}},
+		},
+	}
+	regions := &dbinfo.Table{
+		Name:   "regions",
+		Schema: "public",
+		Columns: []*dbinfo.Column{
+			{Name: "code", Type: "character varying"},
+		},
+	}
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			cascadeTable("customers", ""),
+			orders,
+			regions,
+			cascadeTable("level1", "customers"),
+			cascadeTable("level2", "level1"),
+			cascadeTable("level3", "level2"),
+			cascadeTable("level4", "level3"),
+		},
+		Sequences: []*dbinfo.Sequence{
+			{Schema: "public", Name: "orders_id_seq", Increment: 1, Min: 1, Max: 2147483647, LastValue: ptr(int64(2000000000)), OwnedBy: &dbinfo.SequenceOwner{Schema: "public", Table: "orders", Column: "id"}},
+			{Schema: "public", Name: "tickets", Increment: 1, Min: 1, Max: 100, Cycle: true, LastValue: ptr(int64(99))},
+			{Schema: "public", Name: "invoice_numbers", Increment: 1, Min: 1, Max: 100, LastValue: ptr(int64(80))},
+			{Schema: "public", Name: "customers_id_seq", Increment: 1, Min: 1, Max: 100, LastValue: ptr(int64(75))},
+		},
+	}
+}
+
+// ptr returns a pointer to value
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func TestIntegrityRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected []string
+	}{
+		{"table-without-primary-key", []string{"public.regions"}},
+		{"nullable-foreign-key", []string{"public.orders.customer_id"}},
+		// regions.code has no formatted type, so only the base types are compared
+		{"fk-type-mismatch", []string{"public.orders.customer_id"}},
+		{"cascade-depth", []string{"public.customers"}},
+		{"sequence-exhaustion", []string{"public.invoice_numbers", "public.orders.id"}},
+	}
+	for _, test := range tests {
+		t.Run(test.rule, func(t *testing.T) {
+			actual := locations(runRule(t, test.rule, integrityInfo()))
+			if diff := cmp.Diff(test.expected, actual); diff != "" {
+				t.Errorf("Unexpected findings (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSequenceExhaustionMessage(t *testing.T) {
+	findings := runRule(t, "sequence-exhaustion", integrityInfo())
+	if expected := "sequence orders_id_seq has used 93% of its values, 2000000000 of 2147483647"; len(findings) != 2 || findings[1].Message != expected {
+		t.Errorf("Unexpected findings %v", findings)
+	}
+}
+
+func TestCascadeDepthCycle(t *testing.T) {
+	info := &dbinfo.DBInfo{Tables: []*dbinfo.Table{cascadeTable("a", "b"), cascadeTable("b", "a")}}
+	cfg := &Config{Rules: map[string]*RuleConfig{"cascade-depth": {Options: map[string]any{"max-depth": 0}}}}
+
+	findings := Run(info, []*Rule{registry["cascade-depth"]}, cfg)
+	if len(findings) != 2 {
+		t.Fatalf("Expected both tables of the cycle to be reported, got %v", locations(findings))
+	}
+	if expected := "deleting a row cascades 1 levels deep, more than 0: public.a -> public.b"; findings[0].Message != expected {
+		t.Errorf("Unexpected message %q", findings[0].Message)
+	}
+}
+
+func TestCascadeDepthWide(t *testing.T) {
+	// Every table of a layer cascades from every table of the previous one,
+	// so the number of paths grows exponentially with the depth
+	var tables []*dbinfo.Table
+	var previous []string
+	for layer := 0; layer < 30; layer++ {
+		var names []string
+		for i := 0; i < 4; i++ {
+			name := fmt.Sprintf("t%d_%d", layer, i)
+			table := cascadeTable(name, "")
+			for _, parent := range previous {
+				table.ForeignKeys = append(table.ForeignKeys, cascadeTable(name, parent).ForeignKeys...)
+			}
+			tables = append(tables, table)
+			names = append(names, name)
+		}
+		previous = names
+	}
+	cfg := &Config{Rules: map[string]*RuleConfig{"cascade-depth": {Options: map[string]any{"max-depth": 28}}}}
+
+	findings := Run(&dbinfo.DBInfo{Tables: tables}, []*Rule{registry["cascade-depth"]}, cfg)
+	if expected := []string{"public.t0_0", "public.t0_1", "public.t0_2", "public.t0_3"}; !cmp.Equal(expected, locations(findings)) {
+		t.Errorf("Expected the first layer to be reported, got %v", locations(findings))
+	}
+}
@@ -0,0 +1,283 @@
+// Package lint runs rules over a dbinfo schema and reports findings.
+//
+// Rules are registered with Register and configured with a Config, which can
+// disable them, change their severity or pass them options. Findings carry
+// the location of the offending object as schema.table[.member] and can be
+// written as text, JSON or SARIF.
+package lint
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is the importance of a finding
+type Severity string
+
+// Severities, from most to least important
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+)
+
+// rank orders severities so they can be compared
+func (s Severity) rank() int {
+	switch s {
+	case Error:
+		return 3
+	case Warning:
+		return 2
+	case Info:
+		return 1
+	}
+	return 0
+}
+
+// AtLeast reports whether s is as important as other
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// ParseSeverity validates a severity name
+func ParseSeverity(name string) (Severity, error) {
+	s := Severity(strings.ToLower(name))
+	if s.rank() == 0 {
+		return "", fmt.Errorf("unknown severity %q, expected error, warning or info", name)
+	}
+	return s, nil
+}
+
+// Location identifies the object a finding refers to
+type Location struct {
+	Schema     string `json:"schema"`
+	Table      string `json:"table,omitempty"`
+	Column     string `json:"column,omitempty"`
+	Index      string `json:"index,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+// member returns the name of the column, index or constraint of the table
+// the location points at, if any
+func (l Location) member() string {
+	return cmp.Or(l.Column, l.Index, l.Constraint)
+}
+
+// String returns the location as schema.table[.member], where member is a
+// column, index or constraint name
+func (l Location) String() string {
+	parts := []string{l.Schema}
+	if l.Table != "" {
+		parts = append(parts, l.Table)
+	}
+	if member := l.member(); member != "" {
+		parts = append(parts, member)
+	}
+	return strings.Join(parts, ".")
+}
+
+// TableLocation returns the location of a table
+func TableLocation(table *dbinfo.Table) Location {
+	return Location{Schema: table.Schema, Table: table.Name}
+}
+
+// Finding is a problem reported by a rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
+}
+
+// Rule checks a schema and reports findings through the Context
+type Rule struct {
+	Name        string   // Unique identifier, used in configuration files
+	Description string   // One sentence describing what the rule checks
+	Severity    Severity // Default severity of the findings
+	Optional    bool     // Only runs when enabled in the configuration
+	Check       func(c *Context)
+}
+
+// Context gives a rule access to the schema, its options and the report
+type Context struct {
+	Info     *dbinfo.DBInfo
+	Options  map[string]any
+	rule     *Rule
+	severity Severity
+	findings []*Finding
+}
+
+// Report records a finding at loc
+func (c *Context) Report(loc Location, format string, args ...any) {
+	c.findings = append(c.findings, &Finding{
+		Rule:     c.rule.Name,
+		Severity: c.severity,
+		Location: loc,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Fail records that the rule couldn't check the schema, as an error
+// finding without location, so the failure isn't mistaken for a clean run
+func (c *Context) Fail(err error) {
+	c.findings = append(c.findings, &Finding{
+		Rule:     c.rule.Name,
+		Severity: Error,
+		Message:  "rule failed: " + err.Error(),
+	})
+}
+
+// Int returns an integer option, or def when it is not set
+func (c *Context) Int(name string, def int) int {
+	switch v := c.Options[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// String returns a string option, or def when it is not set
+func (c *Context) String(name, def string) string {
+	if v, ok := c.Options[name].(string); ok {
+		return v
+	}
+	return def
+}
+
+// Bool returns a boolean option, or def when it is not set
+func (c *Context) Bool(name string, def bool) bool {
+	if v, ok := c.Options[name].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// Strings returns a list of strings option, or def when it is not set
+func (c *Context) Strings(name string, def []string) []string {
+	values, ok := c.Options[name].([]any)
+	if !ok {
+		return def
+	}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+var registry = make(map[string]*Rule)
+
+// Register makes a rule available to Run. It panics if a rule with the same
+// name is already registered.
+func Register(rule *Rule) {
+	if _, ok := registry[rule.Name]; ok {
+		panic("lint: Register called twice for " + rule.Name)
+	}
+	registry[rule.Name] = rule
+}
+
+// Rules returns the registered rules sorted by name
+func Rules() []*Rule {
+	rules := make([]*Rule, 0, len(registry))
+	for _, rule := range registry {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// RuleConfig configures a single rule. Listing an optional rule in the
+// configuration enables it.
+type RuleConfig struct {
+	Disabled bool           `yaml:"disabled" json:"disabled"`
+	Severity Severity       `yaml:"severity" json:"severity"` // Overrides the default severity
+	Options  map[string]any `yaml:"options" json:"options"`
+}
+
+// Config configures a lint run
+type Config struct {
+	Rules map[string]*RuleConfig `yaml:"rules" json:"rules"`
+}
+
+// LoadConfig reads a YAML (or JSON) lint configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lint config: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse lint config: %w", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate reports unknown rules and severities in the configuration
+func (cfg *Config) Validate() error {
+	for name, rc := range cfg.Rules {
+		if _, ok := registry[name]; !ok {
+			return fmt.Errorf("unknown lint rule %q", name)
+		}
+		if rc != nil && rc.Severity != "" {
+			if _, err := ParseSeverity(string(rc.Severity)); err != nil {
+				return fmt.Errorf("rule %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Run checks info with the given rules. A nil cfg runs every rule that is not
+// optional with its defaults. Findings are sorted by location, then by rule.
+func Run(info *dbinfo.DBInfo, rules []*Rule, cfg *Config) []*Finding {
+	var findings []*Finding
+	for _, rule := range rules {
+		var rc *RuleConfig
+		listed := false
+		if cfg != nil {
+			rc, listed = cfg.Rules[rule.Name]
+		}
+		if (rc != nil && rc.Disabled) || (!listed && rule.Optional) {
+			continue
+		}
+
+		c := &Context{Info: info, rule: rule, severity: rule.Severity}
+		if rc != nil {
+			c.Options = rc.Options
+			if rc.Severity != "" {
+				c.severity = Severity(strings.ToLower(string(rc.Severity)))
+			}
+		}
+		rule.Check(c)
+		findings = append(findings, c.findings...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Location.String() != b.Location.String() {
+			return a.Location.String() < b.Location.String()
+		}
+		return a.Rule < b.Rule
+	})
+	return findings
+}
+
+// HasSeverity reports whether any finding is at least as important as s
+func HasSeverity(findings []*Finding, s Severity) bool {
+	for _, finding := range findings {
+		if finding.Severity.AtLeast(s) {
+			return true
+		}
+	}
+	return false
+}
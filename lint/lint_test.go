@@ -0,0 +1,161 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+// wideTables reports tables with more columns than the max option
+var wideTables = &Rule{
+	Name:        "wide-table",
+	Description: "Tables should not have too many columns.",
+	Severity:    Warning,
+	Check: func(c *Context) {
+		max := c.Int("max", 2)
+		for _, table := range c.Info.Tables {
+			if len(table.Columns) > max {
+				c.Report(TableLocation(table), "table has %d columns, more than %d", len(table.Columns), max)
+			}
+		}
+	},
+}
+
+// nullableColumns reports every nullable column
+var nullableColumns = &Rule{
+	Name:        "nullable-column",
+	Description: "Columns should be NOT NULL.",
+	Severity:    Info,
+	Check: func(c *Context) {
+		for _, table := range c.Info.Tables {
+			for _, column := range table.Columns {
+				if column.IsNullable {
+					c.Report(Location{Schema: table.Schema, Table: table.Name, Column: column.Name}, "column is nullable")
+				}
+			}
+		}
+	},
+}
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:   "orders",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer"},
+					{Name: "notes", Type: "text", IsNullable: true},
+				},
+			},
+			{
+				Name:   "customers",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+				},
+			},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	rules := []*Rule{wideTables, nullableColumns}
+
+	expected := []*Finding{
+		{Rule: "wide-table", Severity: Warning, Location: Location{Schema: "public", Table: "orders"}, Message: "table has 3 columns, more than 2"},
+		{Rule: "nullable-column", Severity: Info, Location: Location{Schema: "public", Table: "orders", Column: "notes"}, Message: "column is nullable"},
+	}
+	if diff := cmp.Diff(expected, Run(testInfo(), rules, nil)); diff != "" {
+		t.Errorf("Unexpected findings (-expected +actual):\n%s", diff)
+	}
+
+	cfg := &Config{Rules: map[string]*RuleConfig{
+		"wide-table":      {Severity: "ERROR", Options: map[string]any{"max": 0}},
+		"nullable-column": {Disabled: true},
+	}}
+	expected = []*Finding{
+		{Rule: "wide-table", Severity: Error, Location: Location{Schema: "public", Table: "customers"}, Message: "table has 1 columns, more than 0"},
+		{Rule: "wide-table", Severity: Error, Location: Location{Schema: "public", Table: "orders"}, Message: "table has 3 columns, more than 0"},
+	}
+	findings := Run(testInfo(), rules, cfg)
+	if diff := cmp.Diff(expected, findings); diff != "" {
+		t.Errorf("Unexpected configured findings (-expected +actual):\n%s", diff)
+	}
+	if !HasSeverity(findings, Warning) || HasSeverity(Run(testInfo(), []*Rule{nullableColumns}, nil), Warning) {
+		t.Error("HasSeverity should compare findings with the threshold")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lint.yaml")
+	if err := os.WriteFile(path, []byte("rules:\n  missing-rule:\n    disabled: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "missing-rule") {
+		t.Errorf("Expected an unknown rule error, got %v", err)
+	}
+}
+
+func TestWriters(t *testing.T) {
+	rules := []*Rule{wideTables, nullableColumns}
+	findings := Run(testInfo(), rules, nil)
+
+	var text bytes.Buffer
+	if err := WriteText(&text, findings); err != nil {
+		t.Fatal(err)
+	}
+	expected := "warning  public.orders        wide-table       table has 3 columns, more than 2\n" +
+		"info     public.orders.notes  nullable-column  column is nullable\n"
+	if text.String() != expected {
+		t.Errorf("Unexpected text output:\n%s", text.String())
+	}
+
+	text.Reset()
+	if err := WriteColorText(&text, findings); err != nil {
+		t.Fatal(err)
+	}
+	expected = "\x1b[33mwarning\x1b[0m  \x1b[1mpublic.orders\x1b[0m        \x1b[90mwide-table\x1b[0m       table has 3 columns, more than 2\n" +
+		"\x1b[34minfo\x1b[0m     \x1b[1mpublic.orders.notes\x1b[0m  \x1b[90mnullable-column\x1b[0m  column is nullable\n"
+	if text.String() != expected {
+		t.Errorf("Unexpected colored output:\n%q", text.String())
+	}
+
+	var out bytes.Buffer
+	if err := WriteJSON(&out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("Expected an empty JSON array without findings, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := WriteSARIF(&out, rules, findings); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("Failed to decode SARIF output: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF log: %s", out.String())
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 2 {
+		t.Fatalf("Expected 2 rules and 2 results, got %s", out.String())
+	}
+	result := run.Results[1]
+	if result.RuleID != "nullable-column" || result.Level != "note" ||
+		result.Locations[0].LogicalLocations[0].FullyQualifiedName != "public.orders.notes" {
+		t.Errorf("Unexpected SARIF result: %+v", result)
+	}
+}
@@ -0,0 +1,130 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+)
+
+// snakeCase is the default pattern of table and column names
+const snakeCase = `^[a-z][a-z0-9]*(_[a-z0-9]+)*$`
+
+func init() {
+	Register(&Rule{
+		Name:        "table-name",
+		Description: "Table names should match the pattern option (snake_case by default).",
+		Severity:    Warning,
+		Check: func(c *Context) {
+			pattern, ok := compilePattern(c, snakeCase)
+			if !ok {
+				return
+			}
+			for _, table := range c.Info.Tables {
+				if !pattern.MatchString(table.Name) {
+					c.Report(TableLocation(table), "table name does not match %s", pattern)
+				}
+			}
+		},
+	})
+	Register(&Rule{
+		Name:        "column-name",
+		Description: "Column names should match the pattern option (snake_case by default).",
+		Severity:    Warning,
+		Check: func(c *Context) {
+			pattern, ok := compilePattern(c, snakeCase)
+			if !ok {
+				return
+			}
+			for _, table := range c.Info.Tables {
+				for _, column := range table.Columns {
+					if !pattern.MatchString(column.Name) {
+						c.Report(Location{Schema: table.Schema, Table: table.Name, Column: column.Name},
+							"column name does not match %s", pattern)
+					}
+				}
+			}
+		},
+	})
+	Register(&Rule{
+		Name:        "fk-column-suffix",
+		Description: "Single column foreign keys should end with the suffix option (_id by default).",
+		Severity:    Warning,
+		Optional:    true,
+		Check: func(c *Context) {
+			suffix := c.String("suffix", "_id")
+			for _, table := range c.Info.Tables {
+				for _, fk := range table.ForeignKeys {
+					if len(fk.ColumnNames) == 1 && !strings.HasSuffix(fk.ColumnNames[0], suffix) {
+						c.Report(Location{Schema: table.Schema, Table: table.Name, Column: fk.ColumnNames[0]},
+							"foreign key column does not end with %s", suffix)
+					}
+				}
+			}
+		},
+	})
+	Register(&Rule{
+		Name:        "index-name",
+		Description: "Index names should follow the template option, where {table} and {columns} are replaced (idx_{table}_{columns} by default).",
+		Severity:    Info,
+		Optional:    true,
+		Check: func(c *Context) {
+			template := c.String("template", "idx_{table}_{columns}")
+			for _, table := range c.Info.Tables {
+				for _, index := range table.Indexes {
+					// Expression indexes have no column list to build a name from
+					if index.Expression != "" {
+						continue
+					}
+					expected := strings.NewReplacer("{table}", table.Name, "{columns}", strings.Join(index.Columns, "_")).Replace(template)
+					if index.Name != expected {
+						c.Report(Location{Schema: table.Schema, Table: table.Name, Index: index.Name},
+							"index should be named %s", expected)
+					}
+				}
+			}
+		},
+	})
+	Register(&Rule{
+		Name:        "table-plurality",
+		Description: "Table names should be plural, or singular with the form option set to singular.",
+		Severity:    Info,
+		Optional:    true,
+		Check: func(c *Context) {
+			form := c.String("form", "plural")
+			if form != "plural" && form != "singular" {
+				c.Report(Location{}, "unknown form %q, expected plural or singular", form)
+				return
+			}
+			for _, table := range c.Info.Tables {
+				if isPlural(table.Name) != (form == "plural") {
+					c.Report(TableLocation(table), "table name should be %s", form)
+				}
+			}
+		},
+	})
+}
+
+// compilePattern compiles the pattern option, reporting invalid expressions
+func compilePattern(c *Context, def string) (*regexp.Regexp, bool) {
+	pattern, err := regexp.Compile(c.String("pattern", def))
+	if err != nil {
+		c.Report(Location{}, "invalid pattern: %v", err)
+		return nil, false
+	}
+	return pattern, true
+}
+
+// isPlural guesses whether the last word of a snake_case name is an English
+// plural. It handles regular plurals only.
+func isPlural(name string) bool {
+	words := strings.Split(strings.ToLower(name), "_")
+	word := words[len(words)-1]
+	switch {
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return false
+	case strings.HasSuffix(word, "s"):
+		return true
+	case word == "people", word == "children", word == "data", word == "media":
+		return true
+	}
+	return false
+}
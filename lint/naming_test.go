@@ -0,0 +1,90 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func namingInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:   "orders",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", IsPrimaryKey: true},
+					{Name: "customer_id"},
+					{Name: "shippingAddress"},
+					{Name: "warehouse"},
+				},
+				Indexes: []*dbinfo.Index{
+					{Name: "idx_orders_customer_id", Columns: []string{"customer_id"}},
+					{Name: "orders_warehouse", Columns: []string{"warehouse"}},
+					{Name: "orders_lower_notes", Expression: "lower(notes)"},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{Name: "orders_customer_id_fkey", ColumnNames: []string{"customer_id"}},
+					{Name: "orders_warehouse_fkey", ColumnNames: []string{"warehouse"}},
+				},
+			},
+			{Name: "OrderStatus", Schema: "public"},
+			{Name: "address", Schema: "public"},
+		},
+	}
+}
+
+func TestNamingRules(t *testing.T) {
+	cfg := &Config{Rules: map[string]*RuleConfig{
+		"fk-column-suffix": nil,
+		"index-name":       {},
+		"table-plurality":  {},
+	}}
+	tests := []struct {
+		rule     string
+		expected []string
+	}{
+		{"table-name", []string{"public.OrderStatus"}},
+		{"column-name", []string{"public.orders.shippingAddress"}},
+		{"fk-column-suffix", []string{"public.orders.warehouse"}},
+		{"index-name", []string{"public.orders.orders_warehouse"}},
+		{"table-plurality", []string{"public.OrderStatus", "public.address"}},
+	}
+	for _, test := range tests {
+		t.Run(test.rule, func(t *testing.T) {
+			actual := locations(Run(namingInfo(), []*Rule{registry[test.rule]}, cfg))
+			if diff := cmp.Diff(test.expected, actual); diff != "" {
+				t.Errorf("Unexpected findings (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNamingOptions(t *testing.T) {
+	cfg := &Config{Rules: map[string]*RuleConfig{
+		"table-name":      {Options: map[string]any{"pattern": "^[A-Za-z]+$"}},
+		"index-name":      {Options: map[string]any{"template": "{table}_{columns}"}},
+		"table-plurality": {Options: map[string]any{"form": "singular"}},
+	}}
+	rules := []*Rule{registry["table-name"], registry["index-name"], registry["table-plurality"]}
+
+	var actual []string
+	for _, finding := range Run(namingInfo(), rules, cfg) {
+		actual = append(actual, finding.Rule+" "+finding.Location.String())
+	}
+	expected := []string{
+		"table-plurality public.orders",
+		"index-name public.orders.idx_orders_customer_id",
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("Unexpected findings (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestOptionalRules(t *testing.T) {
+	if findings := Run(namingInfo(), []*Rule{registry["fk-column-suffix"]}, nil); len(findings) != 0 {
+		t.Errorf("Expected optional rules not to run unless configured, got %v", locations(findings))
+	}
+}
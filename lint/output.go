@@ -0,0 +1,159 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/guillermo/dbinfo/internal/color"
+)
+
+// WriteText writes one aligned line per finding to w
+func WriteText(w io.Writer, findings []*Finding) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Severity, f.Location, f.Rule, f.Message)
+	}
+	return tw.Flush()
+}
+
+// severityColors are the colors of the severities in WriteColorText
+var severityColors = map[Severity]color.Color{
+	Error:   color.Red,
+	Warning: color.Yellow,
+	Info:    color.Blue,
+}
+
+// WriteColorText writes the findings to w like WriteText, with the
+// severities colored for a terminal
+func WriteColorText(w io.Writer, findings []*Finding) error {
+	rows := make([][]color.Cell, 0, len(findings))
+	for _, f := range findings {
+		rows = append(rows, []color.Cell{
+			{Text: string(f.Severity), Color: severityColors[f.Severity]},
+			{Text: f.Location.String(), Color: color.Bold},
+			{Text: f.Rule, Color: color.Gray},
+			{Text: f.Message},
+		})
+	}
+	return color.WriteTable(w, rows)
+}
+
+// WriteJSON writes the findings to w as a JSON array
+func WriteJSON(w io.Writer, findings []*Finding) error {
+	if findings == nil {
+		findings = []*Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+// SARIF 2.1.0 document, limited to the properties dbinfo fills
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps severities to SARIF result levels
+func sarifLevel(s Severity) string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+	return "note"
+}
+
+// WriteSARIF writes the findings to w as a SARIF 2.1.0 log, the format code
+// scanning tools use to annotate pull requests. rules describes the rules
+// that ran.
+func WriteSARIF(w io.Writer, rules []*Rule, findings []*Finding) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "dbinfo",
+			InformationURI: "https://github.com/guillermo/dbinfo",
+			Rules:          make([]sarifRule, 0, len(rules)),
+		}},
+		Results: make([]sarifResult, 0, len(findings)),
+	}
+	for _, rule := range rules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   rule.Name,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(rule.Severity)},
+		})
+	}
+	for _, f := range findings {
+		kind := "table"
+		if f.Location.member() != "" {
+			kind = "member"
+		} else if f.Location.Table == "" {
+			kind = "namespace"
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  f.Rule,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: f.Location.String(), Kind: kind}},
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,75 @@
+package lint
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo/internal/indexes"
+)
+
+func init() {
+	Register(&Rule{
+		Name:        "fk-without-index",
+		Description: "Foreign key columns should be covered by an index, so joins and deletes on the referenced table do not scan the whole table.",
+		Severity:    Warning,
+		Check:       checkForeignKeyIndexes,
+	})
+	Register(&Rule{
+		Name:        "redundant-index",
+		Description: "Indexes should not duplicate another index or be a prefix of one, as they slow down writes without speeding up reads.",
+		Severity:    Warning,
+		Check:       checkRedundantIndexes,
+	})
+	Register(&Rule{
+		Name:        "wide-index",
+		Description: "Indexes should not span more columns than the max option (4 by default).",
+		Severity:    Info,
+		Check:       checkWideIndexes,
+	})
+	Register(&Rule{
+		Name:        "table-without-index",
+		Description: "Tables should have at least one index or primary key.",
+		Severity:    Warning,
+		Check:       checkTablesWithoutIndexes,
+	})
+}
+
+func checkForeignKeyIndexes(c *Context) {
+	for _, table := range c.Info.Tables {
+		keys := indexes.Keys(table)
+		for _, fk := range table.ForeignKeys {
+			if !slices.ContainsFunc(keys, func(key []string) bool { return indexes.Serves(key, fk.ColumnNames) }) {
+				c.Report(Location{Schema: table.Schema, Table: table.Name, Constraint: fk.Name},
+					"foreign key on (%s) has no index starting with its columns", strings.Join(fk.ColumnNames, ", "))
+			}
+		}
+	}
+}
+
+func checkRedundantIndexes(c *Context) {
+	for _, table := range c.Info.Tables {
+		for _, r := range indexes.Redundant(table) {
+			c.Report(Location{Schema: table.Schema, Table: table.Name, Index: r.Index.Name}, "index %s", r.Reason())
+		}
+	}
+}
+
+func checkWideIndexes(c *Context) {
+	max := c.Int("max", 4)
+	for _, table := range c.Info.Tables {
+		for _, index := range table.Indexes {
+			if len(index.Columns) > max {
+				c.Report(Location{Schema: table.Schema, Table: table.Name, Index: index.Name},
+					"index spans %d columns, more than %d", len(index.Columns), max)
+			}
+		}
+	}
+}
+
+func checkTablesWithoutIndexes(c *Context) {
+	for _, table := range c.Info.Tables {
+		if len(table.Indexes) == 0 && len(indexes.PrimaryKey(table)) == 0 {
+			c.Report(TableLocation(table), "table has no indexes or primary key")
+		}
+	}
+}
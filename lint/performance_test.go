@@ -0,0 +1,101 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+// runRule runs a single registered rule with its defaults
+func runRule(t *testing.T, name string, info *dbinfo.DBInfo) []*Finding {
+	t.Helper()
+	rule, ok := registry[name]
+	if !ok {
+		t.Fatalf("Rule %s is not registered", name)
+	}
+	return Run(info, []*Rule{rule}, nil)
+}
+
+// locations returns the locations of the findings as strings
+func locations(findings []*Finding) []string {
+	var result []string
+	for _, finding := range findings {
+		result = append(result, finding.Location.String())
+	}
+	return result
+}
+
+func performanceInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:   "order_items",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "order_id", Type: "integer", IsPrimaryKey: true},
+					{Name: "product_id", Type: "integer", IsPrimaryKey: true},
+					{Name: "warehouse_id", Type: "integer"},
+					{Name: "coupon_id", Type: "integer"},
+				},
+				Indexes: []*dbinfo.Index{
+					{Name: "idx_order_items_pk", Unique: true, Columns: []string{"order_id", "product_id"}},
+					{Name: "idx_order_items_product", Columns: []string{"product_id"}},
+					{Name: "idx_order_items_product_warehouse", Columns: []string{"product_id", "warehouse_id"}},
+					{Name: "idx_order_items_product_warehouse_2", Columns: []string{"warehouse_id", "product_id"}},
+					{Name: "idx_order_items_wide", Columns: []string{"order_id", "product_id", "warehouse_id", "coupon_id", "ctid"}},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{Name: "order_items_order_id_fkey", ColumnNames: []string{"order_id"}},
+					{Name: "order_items_product_id_fkey", ColumnNames: []string{"product_id"}},
+					{Name: "order_items_coupon_id_fkey", ColumnNames: []string{"coupon_id"}},
+				},
+			},
+			{
+				Name:   "events",
+				Schema: "audit",
+				Columns: []*dbinfo.Column{
+					{Name: "payload", Type: "jsonb"},
+				},
+			},
+		},
+	}
+}
+
+func TestPerformanceRules(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected []string
+	}{
+		{"fk-without-index", []string{"public.order_items.order_items_coupon_id_fkey"}},
+		// idx_order_items_product_warehouse_2 has the columns of
+		// idx_order_items_product_warehouse in another order, so it serves
+		// other queries and is not redundant
+		{"redundant-index", []string{
+			"public.order_items.idx_order_items_pk",
+			"public.order_items.idx_order_items_product",
+		}},
+		{"wide-index", []string{"public.order_items.idx_order_items_wide"}},
+		{"table-without-index", []string{"audit.events"}},
+	}
+	for _, test := range tests {
+		t.Run(test.rule, func(t *testing.T) {
+			actual := locations(runRule(t, test.rule, performanceInfo()))
+			if diff := cmp.Diff(test.expected, actual); diff != "" {
+				t.Errorf("Unexpected findings (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPerformanceLocations(t *testing.T) {
+	fk := runRule(t, "fk-without-index", performanceInfo())[0].Location
+	if fk.Constraint != "order_items_coupon_id_fkey" || fk.Column != "" {
+		t.Errorf("Expected the foreign key as the constraint of the location, got %+v", fk)
+	}
+	index := runRule(t, "wide-index", performanceInfo())[0].Location
+	if index.Index != "idx_order_items_wide" || index.Column != "" {
+		t.Errorf("Expected the index of the location to be set, got %+v", index)
+	}
+}
@@ -0,0 +1,128 @@
+// Package loadorder orders the tables of a schema by their foreign keys, so
+// that data can be loaded, deleted or truncated without breaking referential
+// integrity, and writes script skeletons in that order for test data tooling:
+// TRUNCATE and DELETE scripts, psql \copy scripts dumping and loading CSV
+// files, and INSERT statements to fill in with seed data.
+//
+// Tables referencing each other, directly or through other tables, form a
+// cycle no order satisfies. They are loaded together, in one transaction with
+// the foreign keys between them deferred until it commits. Foreign keys of a
+// table to itself need no deferral: PostgreSQL checks them at the end of each
+// statement.
+package loadorder
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Group is a table, or the tables of a cycle of foreign keys, loaded
+// together
+type Group struct {
+	Tables []*dbinfo.Table
+	// Deferred are the foreign keys between the tables of a cycle, which must
+	// be deferred while loading them. Empty for a single table.
+	Deferred []*Deferral
+}
+
+// Deferral is a foreign key of a table deferred while loading its group
+type Deferral struct {
+	Table      *dbinfo.Table
+	ForeignKey *dbinfo.ForeignKey
+}
+
+// Cycle reports whether the tables of the group reference each other
+func (g *Group) Cycle() bool {
+	return len(g.Tables) > 1
+}
+
+// Order returns the tables of info in groups, each group only referencing
+// the groups before it: referenced tables come first. Foreign keys to
+// tables outside info are ignored. Tables are otherwise kept in the order of
+// info, which is sorted by schema and name.
+func Order(info *dbinfo.DBInfo) []*Group {
+	byKey := make(map[[2]string]*dbinfo.Table, len(info.Tables))
+	for _, table := range info.Tables {
+		byKey[[2]string{table.Schema, table.Name}] = table
+	}
+	references := func(table *dbinfo.Table) []*dbinfo.Table {
+		var tables []*dbinfo.Table
+		for _, fk := range table.ForeignKeys {
+			ref := byKey[[2]string{fk.RefTableSchema, fk.RefTableName}]
+			if ref != nil && ref != table && !slices.Contains(tables, ref) {
+				tables = append(tables, ref)
+			}
+		}
+		return tables
+	}
+
+	// Tarjan's algorithm finds the strongly connected components after
+	// every component they reach, so referenced tables come out first
+	var groups []*Group
+	index := make(map[*dbinfo.Table]int, len(info.Tables))
+	low := make(map[*dbinfo.Table]int, len(info.Tables))
+	onStack := make(map[*dbinfo.Table]bool)
+	var stack []*dbinfo.Table
+	var visit func(table *dbinfo.Table)
+	visit = func(table *dbinfo.Table) {
+		index[table] = len(index) + 1
+		low[table] = index[table]
+		stack = append(stack, table)
+		onStack[table] = true
+		for _, ref := range references(table) {
+			if index[ref] == 0 {
+				visit(ref)
+				low[table] = min(low[table], low[ref])
+			} else if onStack[ref] {
+				low[table] = min(low[table], index[ref])
+			}
+		}
+		if low[table] != index[table] {
+			return
+		}
+		group := &Group{}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			group.Tables = append(group.Tables, top)
+			if top == table {
+				break
+			}
+		}
+		groups = append(groups, group.sorted(info))
+	}
+	for _, table := range info.Tables {
+		if index[table] == 0 {
+			visit(table)
+		}
+	}
+	return groups
+}
+
+// sorted puts the tables of the group in the order of info and lists the
+// foreign keys to defer between them
+func (g *Group) sorted(info *dbinfo.DBInfo) *Group {
+	position := func(table *dbinfo.Table) int {
+		return slices.Index(info.Tables, table)
+	}
+	slices.SortFunc(g.Tables, func(a, b *dbinfo.Table) int {
+		return cmp.Compare(position(a), position(b))
+	})
+	if !g.Cycle() {
+		return g
+	}
+	for _, table := range g.Tables {
+		for _, fk := range table.ForeignKeys {
+			inGroup := slices.ContainsFunc(g.Tables, func(ref *dbinfo.Table) bool {
+				return ref != table && ref.Schema == fk.RefTableSchema && ref.Name == fk.RefTableName
+			})
+			if inGroup {
+				g.Deferred = append(g.Deferred, &Deferral{Table: table, ForeignKey: fk})
+			}
+		}
+	}
+	return g
+}
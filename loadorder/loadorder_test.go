@@ -0,0 +1,125 @@
+package loadorder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	fk := func(name, column, table string) *dbinfo.ForeignKey {
+		return &dbinfo.ForeignKey{Name: name, ColumnNames: []string{column}, RefTableSchema: "public", RefTableName: table, RefColumnNames: []string{"id"}}
+	}
+	id := &dbinfo.Column{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true, Kind: dbinfo.ColumnIdentity, Identity: "ALWAYS"}
+	column := func(name string) *dbinfo.Column {
+		return &dbinfo.Column{Name: name, Type: "integer", FullType: "integer", Kind: dbinfo.ColumnPlain}
+	}
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			// Customers and their default address reference each other
+			{Schema: "public", Name: "addresses", Columns: []*dbinfo.Column{id, column("customer_id")},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("addresses_customer_fkey", "customer_id", "customers")}},
+			{Schema: "public", Name: "customers", Columns: []*dbinfo.Column{id, column("address_id"), column("referrer_id")},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("customers_address_fkey", "address_id", "addresses"), fk("customers_referrer_fkey", "referrer_id", "customers")}},
+			{Schema: "public", Name: "order_lines", Columns: []*dbinfo.Column{column("order_id"), column("product_id")},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("lines_order_fkey", "order_id", "orders"), fk("lines_product_fkey", "product_id", "products")}},
+			{Schema: "public", Name: "orders", Columns: []*dbinfo.Column{id, column("customer_id")},
+				ForeignKeys: []*dbinfo.ForeignKey{fk("orders_customer_fkey", "customer_id", "customers")}},
+			{Schema: "public", Name: "products", Columns: []*dbinfo.Column{{Name: "sku", Type: "text", FullType: "text"}}},
+		},
+	}
+}
+
+func TestOrder(t *testing.T) {
+	var got []string
+	for _, g := range Order(testInfo()) {
+		var names []string
+		for _, table := range g.Tables {
+			names = append(names, table.Name)
+		}
+		for _, d := range g.Deferred {
+			names = append(names, "defer "+d.ForeignKey.Name)
+		}
+		got = append(got, strings.Join(names, ", "))
+	}
+	want := []string{
+		"addresses, customers, defer addresses_customer_fkey, defer customers_address_fkey",
+		"orders",
+		"products",
+		"order_lines",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("groups (-want +got):\n%s", diff)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	info := testInfo()
+
+	var buf bytes.Buffer
+	if err := WriteTruncate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	want := "TRUNCATE TABLE\n    public.order_lines,\n    public.products,\n    public.orders,\n    public.addresses,\n    public.customers;\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("truncate (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteDelete(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	want = `-- The foreign keys between tables referencing each other are deferred until COMMIT
+ALTER TABLE public.addresses ALTER CONSTRAINT addresses_customer_fkey DEFERRABLE;
+ALTER TABLE public.customers ALTER CONSTRAINT customers_address_fkey DEFERRABLE;
+BEGIN;
+SET CONSTRAINTS public.addresses_customer_fkey DEFERRED;
+SET CONSTRAINTS public.customers_address_fkey DEFERRED;
+DELETE FROM public.order_lines;
+DELETE FROM public.products;
+DELETE FROM public.orders;
+DELETE FROM public.customers;
+DELETE FROM public.addresses;
+COMMIT;
+ALTER TABLE public.addresses ALTER CONSTRAINT addresses_customer_fkey NOT DEFERRABLE;
+ALTER TABLE public.customers ALTER CONSTRAINT customers_address_fkey NOT DEFERRABLE;
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("delete (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteLoad(&buf, info, "data"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"SET CONSTRAINTS public.customers_address_fkey DEFERRED;\n\\copy public.addresses (id, customer_id) FROM 'data/public.addresses.csv' WITH (FORMAT csv, HEADER)\n",
+		"\\copy public.order_lines (order_id, product_id) FROM 'data/public.order_lines.csv' WITH (FORMAT csv, HEADER)\nCOMMIT;\n",
+		"SELECT setval(pg_get_serial_sequence('public.orders', 'id'), max(id)) FROM public.orders HAVING max(id) IS NOT NULL;\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the load script:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteDump(&buf, &dbinfo.DBInfo{Tables: info.Tables[4:]}, "."); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\\copy public.products (sku) TO 'public.products.csv' WITH (FORMAT csv, HEADER)\n"; buf.String() != want {
+		t.Errorf("Expected %q as the dump script, got %q", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteSeed(&buf, &dbinfo.DBInfo{Tables: info.Tables[3:4]}); err != nil {
+		t.Fatal(err)
+	}
+	want = "BEGIN;\n\nINSERT INTO public.orders (id, customer_id) OVERRIDING SYSTEM VALUE VALUES\n    (/* id integer */, /* customer_id integer */);\nCOMMIT;\n"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("Expected the seed script to start with %q, got:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,193 @@
+package loadorder
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/ddl"
+)
+
+// tableList returns the quoted names of the tables of the groups
+func tableList(groups []*Group) []string {
+	var names []string
+	for _, g := range groups {
+		for _, table := range g.Tables {
+			names = append(names, table.QuotedName())
+		}
+	}
+	return names
+}
+
+// reversed returns the groups from the last to the first, referencing
+// tables first, for deleting
+func reversed(groups []*Group) []*Group {
+	groups = slices.Clone(groups)
+	slices.Reverse(groups)
+	return groups
+}
+
+// WriteTruncate writes to w a TRUNCATE statement emptying the tables of info,
+// referencing tables first. PostgreSQL only truncates a referenced table
+// along with every table referencing it, so they share one statement.
+func WriteTruncate(w io.Writer, info *dbinfo.DBInfo) error {
+	names := tableList(reversed(Order(info)))
+	if len(names) == 0 {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "TRUNCATE TABLE\n    %s;\n", strings.Join(names, ",\n    "))
+	return err
+}
+
+// WriteDelete writes to w DELETE statements emptying the tables of info one
+// at a time, referencing tables first, in a transaction deferring the foreign
+// keys of cycles. Unlike TRUNCATE it fires triggers and works on a subset of
+// the tables of a database.
+func WriteDelete(w io.Writer, info *dbinfo.DBInfo) error {
+	groups := Order(info)
+	var b strings.Builder
+	writeDeferrals(&b, groups, func(b *strings.Builder) {
+		for _, g := range reversed(groups) {
+			for _, table := range slices.Backward(g.Tables) {
+				fmt.Fprintf(b, "DELETE FROM %s;\n", table.QuotedName())
+			}
+		}
+	})
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// csvFile returns the quoted path of the CSV file of a table in dir
+func csvFile(dir string, table *dbinfo.Table) string {
+	return ddl.QuoteLiteral(path.Join(dir, table.Schema+"."+table.Name+".csv"))
+}
+
+// copyColumns returns the quoted list of the columns of table
+func copyColumns(table *dbinfo.Table) string {
+	names := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		names[i] = column.QuotedName()
+	}
+	return strings.Join(names, ", ")
+}
+
+// WriteDump writes to w a psql script dumping each table of info to a CSV
+// file with a header, named schema.table.csv in dir, in load order
+func WriteDump(w io.Writer, info *dbinfo.DBInfo, dir string) error {
+	var b strings.Builder
+	for _, g := range Order(info) {
+		for _, table := range g.Tables {
+			fmt.Fprintf(&b, "\\copy %s (%s) TO %s WITH (FORMAT csv, HEADER)\n", table.QuotedName(), copyColumns(table), csvFile(dir, table))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteLoad writes to w a psql script loading the CSV files written by the
+// script of WriteDump into the tables of info, referenced tables first, in a
+// transaction deferring the foreign keys of cycles. The sequences of serial
+// and identity columns are then moved past the loaded values.
+func WriteLoad(w io.Writer, info *dbinfo.DBInfo, dir string) error {
+	groups := Order(info)
+	var b strings.Builder
+	writeDeferrals(&b, groups, func(b *strings.Builder) {
+		for _, g := range groups {
+			for _, table := range g.Tables {
+				fmt.Fprintf(b, "\\copy %s (%s) FROM %s WITH (FORMAT csv, HEADER)\n", table.QuotedName(), copyColumns(table), csvFile(dir, table))
+			}
+		}
+	})
+	writeSequenceResets(&b, groups)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteSeed writes to w an INSERT statement per table of info, referenced
+// tables first, with a row of placeholders naming the type of each column,
+// to replace with seed data. Identity columns generated always are
+// overridden, so seed rows can set the keys other rows reference.
+func WriteSeed(w io.Writer, info *dbinfo.DBInfo) error {
+	groups := Order(info)
+	var b strings.Builder
+	writeDeferrals(&b, groups, func(b *strings.Builder) {
+		for _, g := range groups {
+			for _, table := range g.Tables {
+				writeSeedInsert(b, table)
+			}
+		}
+	})
+	writeSequenceResets(&b, groups)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeSeedInsert writes the INSERT statement of a table with a row of
+// placeholders
+func writeSeedInsert(b *strings.Builder, table *dbinfo.Table) {
+	placeholders := make([]string, len(table.Columns))
+	overriding := ""
+	for i, column := range table.Columns {
+		placeholders[i] = fmt.Sprintf("/* %s %s */", column.Name, cmp.Or(column.FullType, column.Type))
+		if column.Kind == dbinfo.ColumnIdentity && column.Identity == "ALWAYS" {
+			overriding = " OVERRIDING SYSTEM VALUE"
+		}
+	}
+	fmt.Fprintf(b, "\nINSERT INTO %s (%s)%s VALUES\n    (%s);\n", table.QuotedName(), copyColumns(table), overriding, strings.Join(placeholders, ", "))
+}
+
+// writeDeferrals writes the statements of body in a transaction. Foreign
+// keys between the tables of a cycle are made deferrable before it and
+// deferred in it, then made not deferrable again after it commits. The
+// model doesn't record whether a foreign key was deferrable, so they are
+// assumed not to be, the default.
+func writeDeferrals(b *strings.Builder, groups []*Group, body func(*strings.Builder)) {
+	var deferred []*Deferral
+	for _, g := range groups {
+		deferred = append(deferred, g.Deferred...)
+	}
+	alter := func(d *Deferral, mode string) {
+		fmt.Fprintf(b, "ALTER TABLE %s ALTER CONSTRAINT %s %s;\n", d.Table.QuotedName(), d.ForeignKey.QuotedName(), mode)
+	}
+
+	if len(deferred) > 0 {
+		b.WriteString("-- The foreign keys between tables referencing each other are deferred until COMMIT\n")
+		for _, d := range deferred {
+			alter(d, "DEFERRABLE")
+		}
+	}
+	b.WriteString("BEGIN;\n")
+	for _, d := range deferred {
+		fmt.Fprintf(b, "SET CONSTRAINTS %s DEFERRED;\n", dbinfo.QualifiedName(d.Table.Schema, d.ForeignKey.Name))
+	}
+	body(b)
+	b.WriteString("COMMIT;\n")
+	for _, d := range deferred {
+		alter(d, "NOT DEFERRABLE")
+	}
+}
+
+// writeSequenceResets moves the sequences of the serial and identity
+// columns of the groups past the largest value of the column
+func writeSequenceResets(b *strings.Builder, groups []*Group) {
+	first := true
+	for _, g := range groups {
+		for _, table := range g.Tables {
+			for _, column := range table.Columns {
+				if column.Kind != dbinfo.ColumnSerial && column.Kind != dbinfo.ColumnIdentity {
+					continue
+				}
+				if first {
+					b.WriteString("\n-- Continue the sequences after the loaded keys\n")
+					first = false
+				}
+				fmt.Fprintf(b, "SELECT setval(pg_get_serial_sequence(%s, %s), max(%s)) FROM %s HAVING max(%s) IS NOT NULL;\n",
+					ddl.QuoteLiteral(table.QuotedName()), ddl.QuoteLiteral(column.Name), column.QuotedName(), table.QuotedName(), column.QuotedName())
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package dbinfo
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// MaterializedView is a materialized view: a query whose result is stored
+// like a table, indexed like one, and recomputed by REFRESH MATERIALIZED VIEW
+type MaterializedView struct {
+	Name   string `json:"name" yaml:"name"`
+	Schema string `json:"schema" yaml:"schema"`
+	// Definition is the SELECT statement of the view, as pg_get_viewdef
+	// prints it
+	Definition string    `json:"definition" yaml:"definition"`
+	Columns    []*Column `json:"columns" yaml:"columns"` // Output columns, always nullable
+	Indexes    []*Index  `json:"indexes" yaml:"indexes"`
+	// Populated is false for a view created WITH NO DATA and not refreshed
+	// since, which can't be queried
+	Populated bool          `json:"populated" yaml:"populated"`
+	Comment   string        `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Sources   []*ViewSource `json:"sources,omitempty" yaml:"sources,omitempty"` // As in View
+	Owner     string        `json:"owner,omitempty" yaml:"owner,omitempty"`     // As in Table
+	Grants    []*Grant      `json:"grants,omitempty" yaml:"grants,omitempty"`   // As in Table
+}
+
+// QualifiedName returns the quoted schema.name of the materialized view
+func (v *MaterializedView) QualifiedName() string {
+	return QualifiedName(v.Schema, v.Name)
+}
+
+// Reads reports whether the materialized view reads the table or view
+// schema.name directly
+func (v *MaterializedView) Reads(schema, name string) bool {
+	return slices.ContainsFunc(v.Sources, func(s *ViewSource) bool { return s.Schema == schema && s.Name == name })
+}
+
+// getMaterializedViewIndexes reads the indexes of the materialized views,
+// with the version of indexesQuery the server runs. Unreadable views are
+// left without indexes and a warning, as tables are.
+func getMaterializedViewIndexes(ctx context.Context, db DBQuerier, o *options, views []*MaterializedView) error {
+	query := o.capabilities.queries().indexes
+	for _, view := range views {
+		start := time.Now()
+		object := view.Schema + "." + view.Name
+		indexes, err := getIndexes(ctx, db, query, view.Schema, view.Name)
+		if err != nil && skippable(err) {
+			o.warn(ctx, PhaseIndexes, object, err)
+			continue
+		}
+		if err != nil {
+			return queryError(PhaseIndexes, object, err)
+		}
+		view.Indexes = indexes
+		sortIndexes(view.Indexes)
+		o.info(ctx, "introspected materialized view", "schema", view.Schema, "view", view.Name, "duration", time.Since(start))
+	}
+	return nil
+}
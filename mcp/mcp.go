@@ -0,0 +1,231 @@
+// Package mcp serves dbinfo schemas to coding assistants over the Model
+// Context Protocol, on the stdio transport: JSON-RPC messages, one per
+// line, read from stdin and answered on stdout.
+//
+// Tools:
+//
+//	list_tables         schema, name and comment of the tables, optionally of one schema
+//	describe_table      columns, keys, indexes, comments and relationships of a table
+//	find_relationships  tables a table references and tables referencing it
+//
+// The tools only describe the schema: they never read table data, and the
+// server has no tool changing anything.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/cache"
+)
+
+// protocolVersions are the protocol revisions the server implements, the
+// latest first
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// maxMessageSize limits the size of the messages read by Serve
+const maxMessageSize = 16 << 20
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithCacheTTL sets how long introspection results are reused. Zero loads
+// the schema on every tool call. Defaults to one minute.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.ttl = ttl
+	}
+}
+
+// Server answers Model Context Protocol requests about a schema
+type Server struct {
+	cache *cache.Schema
+	ttl   time.Duration
+}
+
+// New returns a server introspecting the schema with load
+func New(load dbinfo.Loader, opts ...Option) *Server {
+	s := &Server{ttl: time.Minute}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cache = cache.New(load, s.ttl)
+	return s
+}
+
+// Serve answers the messages read from r on w, until r ends or ctx is done
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxMessageSize)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		resp := s.Handle(ctx, line)
+		if resp == nil {
+			continue
+		}
+		resp = append(resp, '\n')
+		if _, err := w.Write(resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// request is a JSON-RPC request, or a notification when it has no ID
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// response is a JSON-RPC response
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Handle answers a single message, returning the response to write back,
+// or nil for notifications
+func (s *Server) Handle(ctx context.Context, message []byte) []byte {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return encode(response{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "invalid JSON-RPC message: " + err.Error()}})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		if len(req.ID) == 0 {
+			return nil
+		}
+		return encode(response{ID: req.ID, Error: &rpcError{codeInvalidRequest, "expected a JSON-RPC 2.0 request"}})
+	}
+
+	result, err := s.dispatch(ctx, req.Method, req.Params)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	if err != nil {
+		return encode(response{ID: req.ID, Error: err})
+	}
+	return encode(response{ID: req.ID, Result: result})
+}
+
+func encode(resp response) []byte {
+	resp.JSONRPC = "2.0"
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(resp)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func (s *Server) dispatch(ctx context.Context, method string, params json.RawMessage) (any, *rpcError) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(params, &p)
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "dbinfo", "version": dbinfo.Version()},
+			"instructions": "These tools describe the structure of a PostgreSQL database: tables, columns, keys, indexes, comments and relationships. " +
+				"They never read or change table data. Table names can be given as schema.table, or as table when the name is unique.",
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{codeInvalidParams, "invalid tool call: " + err.Error()}
+		}
+		tool, ok := toolFuncs[p.Name]
+		if !ok {
+			return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", p.Name)}
+		}
+		var args toolArgs
+		if len(p.Arguments) > 0 {
+			if err := json.Unmarshal(p.Arguments, &args); err != nil {
+				return nil, &rpcError{codeInvalidParams, "invalid tool arguments: " + err.Error()}
+			}
+		}
+		return s.callTool(ctx, tool, &args), nil
+	}
+	return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("unknown method %q", method)}
+}
+
+// schema returns the cached schema, loading it when it is missing or
+// expired
+func (s *Server) schema(ctx context.Context) (*dbinfo.DBInfo, error) {
+	info, err := s.cache.Get(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("getting database info: %w", err)
+	}
+	return info, nil
+}
+
+// callTool runs a tool and returns its result. Failures, such as an unknown
+// table, are tool results flagged as errors, so the assistant sees them.
+func (s *Server) callTool(ctx context.Context, tool toolFunc, args *toolArgs) any {
+	text := func(text string, isError bool) any {
+		return map[string]any{
+			"content": []map[string]string{{"type": "text", "text": text}},
+			"isError": isError,
+		}
+	}
+
+	info, err := s.schema(ctx)
+	if err != nil {
+		return text(err.Error(), true)
+	}
+	v, err := tool(info, args)
+	if err != nil {
+		return text(err.Error(), true)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return text(err.Error(), true)
+	}
+	return text(strings.TrimSuffix(buf.String(), "\n"), false)
+}
@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	orders := &dbinfo.Relationship{Name: "orders", Table: "orders", Schema: "public", ForeignKey: "orders_customer_id_fkey", Columns: []string{"customer_id"}, References: []string{"id"}}
+	customer := &dbinfo.Relationship{Name: "customer", Table: "customers", Schema: "public", ForeignKey: "orders_customer_id_fkey", Columns: []string{"customer_id"}, References: []string{"id"}}
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{Schema: "audit", Name: "orders", Comment: "Order history"},
+			{Schema: "public", Name: "customers", Comment: "People <buying>", HasMany: []*dbinfo.Relationship{orders}},
+			{Schema: "public", Name: "orders", BelongsTo: []*dbinfo.Relationship{customer}},
+		},
+	}
+}
+
+// call sends a request to s and decodes the response
+func call(t *testing.T, s *Server, method string, params any) map[string]any {
+	t.Helper()
+	message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 7, "method": method, "params": params})
+	var resp map[string]any
+	if err := json.Unmarshal(s.Handle(context.Background(), message), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["id"] != 7.0 || resp["jsonrpc"] != "2.0" {
+		t.Errorf("Unexpected response envelope %v", resp)
+	}
+	return resp
+}
+
+// callTool calls a tool and returns its text and whether it failed
+func callTool(t *testing.T, s *Server, name string, args map[string]string) (string, bool) {
+	t.Helper()
+	resp := call(t, s, "tools/call", map[string]any{"name": name, "arguments": args})
+	result, ok := resp["result"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected a result, got %v", resp)
+	}
+	content := result["content"].([]any)[0].(map[string]any)
+	return content["text"].(string), result["isError"].(bool)
+}
+
+func newServer() *Server {
+	return New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return testInfo(), nil
+	})
+}
+
+func TestInitialize(t *testing.T) {
+	s := newServer()
+	resp := call(t, s, "initialize", map[string]any{"protocolVersion": "2024-11-05", "capabilities": map[string]any{}})
+	result := resp["result"].(map[string]any)
+	if result["protocolVersion"] != "2024-11-05" {
+		t.Errorf("Expected the client protocol version, got %v", result["protocolVersion"])
+	}
+	if _, ok := result["capabilities"].(map[string]any)["tools"]; !ok {
+		t.Errorf("Expected the tools capability, got %v", result["capabilities"])
+	}
+
+	resp = call(t, s, "initialize", map[string]any{"protocolVersion": "1999-01-01"})
+	if version := resp["result"].(map[string]any)["protocolVersion"]; version != protocolVersions[0] {
+		t.Errorf("Expected the latest protocol version, got %v", version)
+	}
+}
+
+func TestToolsList(t *testing.T) {
+	resp := call(t, newServer(), "tools/list", nil)
+	var names []string
+	for _, tool := range resp["result"].(map[string]any)["tools"].([]any) {
+		tool := tool.(map[string]any)
+		names = append(names, tool["name"].(string))
+		if tool["annotations"].(map[string]any)["readOnlyHint"] != true {
+			t.Errorf("Expected %s to be read-only", tool["name"])
+		}
+	}
+	if diff := cmp.Diff([]string{"list_tables", "describe_table", "find_relationships"}, names); diff != "" {
+		t.Errorf("Unexpected tools (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestTools(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     map[string]string
+		expected string
+		isError  bool
+	}{
+		{"list_tables", nil, `[{"schema":"audit","name":"orders","comment":"Order history"},{"schema":"public","name":"customers","comment":"People <buying>"},{"schema":"public","name":"orders"}]`, false},
+		{"list_tables", map[string]string{"schema": "audit"}, `[{"schema":"audit","name":"orders","comment":"Order history"}]`, false},
+		{"list_tables", map[string]string{"schema": "none"}, `[]`, false},
+		{"describe_table", map[string]string{"table": "audit.orders"}, `{"name":"orders","schema":"audit","comment":"Order history"}`, false},
+		{"describe_table", map[string]string{"table": "orders"}, "table orders is ambiguous, use one of: audit.orders, public.orders", true},
+		{"describe_table", map[string]string{"table": "invoices"}, "table invoices not found, use list_tables to see the available tables", true},
+		{"describe_table", nil, "missing table name", true},
+		{"find_relationships", map[string]string{"table": "customers"}, `{"schema":"public","name":"customers","belongsto":null,"hasmany":[{"name":"orders","table":"orders","schema":"public","foreignkey":"orders_customer_id_fkey","columns":["customer_id"],"references":["id"]}]}`, false},
+	}
+	s := newServer()
+	for _, test := range tests {
+		text, isError := callTool(t, s, test.name, test.args)
+		if text != test.expected || isError != test.isError {
+			t.Errorf("%s(%v) = %s (error %v), expected %s (error %v)", test.name, test.args, text, isError, test.expected, test.isError)
+		}
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	s := New(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return nil, errors.New("connection refused")
+	})
+	text, isError := callTool(t, s, "list_tables", nil)
+	if !isError || text != "getting database info: connection refused" {
+		t.Errorf("Expected the load error as a tool error, got %q (error %v)", text, isError)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	s := newServer()
+	tests := []struct {
+		message  string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"unknown method \"resources/list\""}}`},
+		{`{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"drop_table"}}`, `{"jsonrpc":"2.0","id":"a","error":{"code":-32602,"message":"unknown tool \"drop_table\""}}`},
+		{`{"jsonrpc":"1.0","id":2,"method":"ping"}`, `{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"expected a JSON-RPC 2.0 request"}}`},
+		{`{"jsonrpc":"2.0","id":3,"method":"ping"}`, `{"jsonrpc":"2.0","id":3,"result":{}}`},
+		{`{"jsonrpc":"2.0","method":"notifications/initialized"}`, ``},
+	}
+	for _, test := range tests {
+		if actual := string(s.Handle(context.Background(), []byte(test.message))); actual != test.expected {
+			t.Errorf("Handle(%s) = %s, expected %s", test.message, actual, test.expected)
+		}
+	}
+	if actual := string(s.Handle(context.Background(), []byte("{"))); !strings.HasPrefix(actual, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,`) {
+		t.Errorf("Expected a parse error, got %s", actual)
+	}
+}
+
+func TestServe(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}
+
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+{"jsonrpc":"2.0","id":2,"method":"ping"}
+`)
+	var out bytes.Buffer
+	if err := newServer().Serve(context.Background(), in, &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"jsonrpc":"2.0","id":1,"result":{}}
+{"jsonrpc":"2.0","id":2,"result":{}}
+`
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// tool describes a tool in the tools/list response
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations map[string]any `json:"annotations"`
+}
+
+// toolArgs are the arguments of every tool, each using a subset
+type toolArgs struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+}
+
+// toolFunc runs a tool on a schema, returning the value sent as its result
+type toolFunc func(info *dbinfo.DBInfo, args *toolArgs) (any, error)
+
+// readOnly are the annotations of every tool
+var readOnly = map[string]any{"readOnlyHint": true, "destructiveHint": false, "idempotentHint": true, "openWorldHint": false}
+
+// tableSchema is the input schema of the tools taking a table
+var tableSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"table": map[string]any{"type": "string", "description": "Table name, as schema.table or as table when the name is unique"},
+	},
+	"required": []string{"table"},
+}
+
+var tools = []tool{
+	{
+		Name:        "list_tables",
+		Description: "List the tables of the database, with their schema and comment",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"schema": map[string]any{"type": "string", "description": "Only list the tables of this schema"},
+			},
+		},
+		Annotations: readOnly,
+	},
+	{
+		Name:        "describe_table",
+		Description: "Describe a table: its columns and their types, primary key, indexes, foreign keys, comments and relationships",
+		InputSchema: tableSchema,
+		Annotations: readOnly,
+	},
+	{
+		Name:        "find_relationships",
+		Description: "Find the tables a table references through its foreign keys (belongsto) and the tables referencing it (hasmany)",
+		InputSchema: tableSchema,
+		Annotations: readOnly,
+	},
+}
+
+var toolFuncs = map[string]toolFunc{
+	"list_tables":        listTables,
+	"describe_table":     describeTable,
+	"find_relationships": findRelationships,
+}
+
+// tableSummary describes a table in the list_tables result
+type tableSummary struct {
+	Schema  string `json:"schema"`
+	Name    string `json:"name"`
+	Comment string `json:"comment,omitempty"`
+}
+
+func listTables(info *dbinfo.DBInfo, args *toolArgs) (any, error) {
+	tables := make([]tableSummary, 0, len(info.Tables))
+	for _, table := range info.Tables {
+		if args.Schema == "" || table.Schema == args.Schema {
+			tables = append(tables, tableSummary{Schema: table.Schema, Name: table.Name, Comment: table.Comment})
+		}
+	}
+	return tables, nil
+}
+
+func describeTable(info *dbinfo.DBInfo, args *toolArgs) (any, error) {
+	return findTable(info, args.Table)
+}
+
+func findRelationships(info *dbinfo.DBInfo, args *toolArgs) (any, error) {
+	table, err := findTable(info, args.Table)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		Schema    string                 `json:"schema"`
+		Name      string                 `json:"name"`
+		BelongsTo []*dbinfo.Relationship `json:"belongsto"`
+		HasMany   []*dbinfo.Relationship `json:"hasmany"`
+	}{table.Schema, table.Name, table.BelongsTo, table.HasMany}, nil
+}
+
+// findTable returns the table named schema.table, or table when a single
+// schema has a table with that name
+func findTable(info *dbinfo.DBInfo, name string) (*dbinfo.Table, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing table name")
+	}
+	for _, table := range info.Tables {
+		if table.Schema+"."+table.Name == name {
+			return table, nil
+		}
+	}
+
+	var matches []*dbinfo.Table
+	for _, table := range info.Tables {
+		if table.Name == name {
+			matches = append(matches, table)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("table %s not found, use list_tables to see the available tables", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, table := range matches {
+		names[i] = table.Schema + "." + table.Name
+	}
+	return nil, fmt.Errorf("table %s is ambiguous, use one of: %s", name, strings.Join(names, ", "))
+}
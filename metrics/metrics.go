@@ -0,0 +1,226 @@
+// Package metrics exports schema metrics in the Prometheus text exposition
+// format, so schema growth and drift from a reference snapshot show up on
+// existing dashboards and alerts.
+//
+// Metrics, all gauges:
+//
+//	dbinfo_up{database}                                 1 when the last introspection succeeded
+//	dbinfo_introspection_duration_seconds{database}     time the last introspection took
+//	dbinfo_tables{database,schema}                      tables per schema
+//	dbinfo_columns{database,schema}                     columns per schema
+//	dbinfo_indexes{database,schema}                     indexes per schema
+//	dbinfo_foreign_keys{database,schema}                foreign keys per schema
+//	dbinfo_warnings{database}                           objects skipped for lack of privileges
+//	dbinfo_schema_info{database,fingerprint}            always 1, the fingerprint changes with the schema
+//	dbinfo_drift_changes{database,reference}            changes from the reference snapshot
+//	dbinfo_drift_breaking_changes{database,reference}   breaking changes from the reference snapshot
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Fingerprint returns a short hash of the schema that changes whenever its
+// tables or the other objects diff compares do. The database name and the
+// details of the introspection, such as the dbinfo build or the server
+// version, are left out, so copies of a schema in different databases share
+// their fingerprint. So are the last values of sequences, which change with
+// every insert.
+func Fingerprint(info *dbinfo.DBInfo) string {
+	data, _ := json.Marshal(info.Tables)
+	sequences := make([]dbinfo.Sequence, len(info.Sequences))
+	for i, s := range info.Sequences {
+		sequences[i] = *s
+		sequences[i].LastValue = nil
+	}
+	// Schemas with tables only keep the fingerprint they had before the
+	// other objects were hashed
+	others, _ := json.Marshal(struct {
+		Views             []*dbinfo.View             `json:"views,omitempty"`
+		MaterializedViews []*dbinfo.MaterializedView `json:"materializedviews,omitempty"`
+		Sequences         []dbinfo.Sequence          `json:"sequences,omitempty"`
+		Domains           []*dbinfo.Domain           `json:"domains,omitempty"`
+		ForeignTables     []*dbinfo.ForeignTable     `json:"foreigntables,omitempty"`
+		EventTriggers     []*dbinfo.EventTrigger     `json:"eventtriggers,omitempty"`
+	}{info.Views, info.MaterializedViews, sequences, info.Domains, info.ForeignTables, info.EventTriggers})
+	if string(others) != "{}" {
+		data = append(data, others...)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Write writes the metrics of info, and its drift from each of the
+// references, named by their key. Duration is the time the introspection
+// took, left out when zero.
+func Write(w io.Writer, info *dbinfo.DBInfo, duration time.Duration, references map[string]*dbinfo.DBInfo) error {
+	bw := bufio.NewWriter(w)
+	m := &writer{w: bw}
+	database := label{"database", info.Name}
+
+	m.family("dbinfo_up", "Whether the last introspection succeeded")
+	m.sample("dbinfo_up", 1, database)
+	if duration > 0 {
+		m.family("dbinfo_introspection_duration_seconds", "Time the last introspection took")
+		m.sample("dbinfo_introspection_duration_seconds", duration.Seconds(), database)
+	}
+
+	counts := countBySchema(info)
+	schemas := make([]string, 0, len(counts))
+	for schema := range counts {
+		schemas = append(schemas, schema)
+	}
+	slices.Sort(schemas)
+	for _, family := range []struct {
+		name, help string
+		count      func(*schemaCounts) int
+	}{
+		{"dbinfo_tables", "Tables per schema", func(c *schemaCounts) int { return c.tables }},
+		{"dbinfo_columns", "Columns per schema", func(c *schemaCounts) int { return c.columns }},
+		{"dbinfo_indexes", "Indexes per schema", func(c *schemaCounts) int { return c.indexes }},
+		{"dbinfo_foreign_keys", "Foreign keys per schema", func(c *schemaCounts) int { return c.foreignKeys }},
+	} {
+		m.family(family.name, family.help)
+		for _, schema := range schemas {
+			m.sample(family.name, float64(family.count(counts[schema])), database, label{"schema", schema})
+		}
+	}
+
+	m.family("dbinfo_warnings", "Objects skipped because they could not be read")
+	m.sample("dbinfo_warnings", float64(len(info.Warnings)), database)
+	m.family("dbinfo_schema_info", "Fingerprint of the schema, changing whenever it does")
+	m.sample("dbinfo_schema_info", 1, database, label{"fingerprint", Fingerprint(info)})
+
+	if len(references) > 0 {
+		names := make([]string, 0, len(references))
+		for name := range references {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		drift := make(map[string][]*diff.Change, len(names))
+		for _, name := range names {
+			drift[name] = diff.Compare(references[name], info)
+		}
+
+		m.family("dbinfo_drift_changes", "Changes from the reference snapshot to the schema")
+		for _, name := range names {
+			m.sample("dbinfo_drift_changes", float64(len(drift[name])), database, label{"reference", name})
+		}
+		m.family("dbinfo_drift_breaking_changes", "Breaking changes from the reference snapshot to the schema")
+		for _, name := range names {
+			breaking := 0
+			for _, change := range drift[name] {
+				if change.Breaking {
+					breaking++
+				}
+			}
+			m.sample("dbinfo_drift_breaking_changes", float64(breaking), database, label{"reference", name})
+		}
+	}
+
+	return cmp.Or(m.err, bw.Flush())
+}
+
+// WriteDown writes the metrics of a failed introspection: dbinfo_up is 0
+func WriteDown(w io.Writer) error {
+	m := &writer{w: w}
+	m.family("dbinfo_up", "Whether the last introspection succeeded")
+	m.sample("dbinfo_up", 0)
+	return m.err
+}
+
+// Handler returns a handler introspecting the schema with load on every
+// request and writing its metrics. Prometheus scrapes set the pace, so
+// the scrape interval should leave time for the introspection. Failed
+// introspections are reported with dbinfo_up set to 0.
+func Handler(load dbinfo.Loader, references map[string]*dbinfo.DBInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info, err := load(r.Context())
+		w.Header().Set("Content-Type", ContentType)
+		if err != nil {
+			WriteDown(w)
+			return
+		}
+		Write(w, info, time.Since(start), references)
+	})
+}
+
+// schemaCounts are the objects of a schema
+type schemaCounts struct {
+	tables, columns, indexes, foreignKeys int
+}
+
+func countBySchema(info *dbinfo.DBInfo) map[string]*schemaCounts {
+	counts := make(map[string]*schemaCounts)
+	for _, table := range info.Tables {
+		c := counts[table.Schema]
+		if c == nil {
+			c = &schemaCounts{}
+			counts[table.Schema] = c
+		}
+		c.tables++
+		c.columns += len(table.Columns)
+		c.indexes += len(table.Indexes)
+		c.foreignKeys += len(table.ForeignKeys)
+	}
+	return counts
+}
+
+// label is a metric label
+type label struct {
+	name, value string
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writer writes metric families, keeping the first error
+type writer struct {
+	w   io.Writer
+	err error
+}
+
+func (m *writer) family(name, help string) {
+	m.printf("# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
+
+func (m *writer) sample(name string, value float64, labels ...label) {
+	var b strings.Builder
+	b.WriteString(name)
+	for i, l := range labels {
+		if i == 0 {
+			b.WriteByte('{')
+		} else {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.name + `="` + labelEscaper.Replace(l.value) + `"`)
+	}
+	if len(labels) > 0 {
+		b.WriteByte('}')
+	}
+	m.printf("%s %s\n", b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func (m *writer) printf(format string, args ...any) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema: "billing",
+				Name:   "invoices",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer"},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{{Name: "invoices_customer_id_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: "public", RefTableName: "customers", RefColumnNames: []string{"id"}}},
+			},
+			{
+				Schema:  "public",
+				Name:    "customers",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}, {Name: "email", Type: "text", IsNullable: true}},
+				Indexes: []*dbinfo.Index{{Name: "customers_email_key", Unique: true, Columns: []string{"email"}}},
+			},
+		},
+		Warnings: []*dbinfo.Warning{{Phase: dbinfo.PhaseColumns, Object: "public.secrets"}},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	reference := testInfo()
+	reference.Tables[1].Columns = reference.Tables[1].Columns[:1] // email was added since
+	reference.Tables[0].Columns[1].Type = "bigint"                // and customer_id narrowed
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testInfo(), 1500*time.Millisecond, map[string]*dbinfo.DBInfo{"release": reference, "main": testInfo()}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP dbinfo_up Whether the last introspection succeeded
+# TYPE dbinfo_up gauge
+dbinfo_up{database="shop"} 1
+# HELP dbinfo_introspection_duration_seconds Time the last introspection took
+# TYPE dbinfo_introspection_duration_seconds gauge
+dbinfo_introspection_duration_seconds{database="shop"} 1.5
+# HELP dbinfo_tables Tables per schema
+# TYPE dbinfo_tables gauge
+dbinfo_tables{database="shop",schema="billing"} 1
+dbinfo_tables{database="shop",schema="public"} 1
+# HELP dbinfo_columns Columns per schema
+# TYPE dbinfo_columns gauge
+dbinfo_columns{database="shop",schema="billing"} 2
+dbinfo_columns{database="shop",schema="public"} 2
+# HELP dbinfo_indexes Indexes per schema
+# TYPE dbinfo_indexes gauge
+dbinfo_indexes{database="shop",schema="billing"} 0
+dbinfo_indexes{database="shop",schema="public"} 1
+# HELP dbinfo_foreign_keys Foreign keys per schema
+# TYPE dbinfo_foreign_keys gauge
+dbinfo_foreign_keys{database="shop",schema="billing"} 1
+dbinfo_foreign_keys{database="shop",schema="public"} 0
+# HELP dbinfo_warnings Objects skipped because they could not be read
+# TYPE dbinfo_warnings gauge
+dbinfo_warnings{database="shop"} 1
+# HELP dbinfo_schema_info Fingerprint of the schema, changing whenever it does
+# TYPE dbinfo_schema_info gauge
+dbinfo_schema_info{database="shop",fingerprint="` + Fingerprint(testInfo()) + `"} 1
+# HELP dbinfo_drift_changes Changes from the reference snapshot to the schema
+# TYPE dbinfo_drift_changes gauge
+dbinfo_drift_changes{database="shop",reference="main"} 0
+dbinfo_drift_changes{database="shop",reference="release"} 2
+# HELP dbinfo_drift_breaking_changes Breaking changes from the reference snapshot to the schema
+# TYPE dbinfo_drift_breaking_changes gauge
+dbinfo_drift_breaking_changes{database="shop",reference="main"} 0
+dbinfo_drift_breaking_changes{database="shop",reference="release"} 1
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected metrics (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	info := testInfo()
+	fingerprint := Fingerprint(info)
+	if len(fingerprint) != 16 {
+		t.Errorf("Expected a 16 character fingerprint, got %q", fingerprint)
+	}
+
+	info.Name, info.Generator, info.Warnings = "copy", "dbinfo v2", nil
+	if Fingerprint(info) != fingerprint {
+		t.Error("Expected the fingerprint to only depend on the tables")
+	}
+	info.Tables[0].Comment = "Issued invoices"
+	if Fingerprint(info) == fingerprint {
+		t.Error("Expected the fingerprint to change with the tables")
+	}
+
+	fingerprint = Fingerprint(info)
+	lastValue := int64(10)
+	info.Sequences = []*dbinfo.Sequence{{Schema: "public", Name: "tickets", Type: "bigint", LastValue: &lastValue}}
+	if Fingerprint(info) == fingerprint {
+		t.Error("Expected the fingerprint to change with the sequences")
+	}
+	fingerprint = Fingerprint(info)
+	lastValue = 20
+	if Fingerprint(info) != fingerprint {
+		t.Error("Expected the fingerprint not to depend on the last values of sequences")
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	info := &dbinfo.DBInfo{Name: "a \"quoted\"\\name\n"}
+	var buf bytes.Buffer
+	if err := Write(&buf, info, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `dbinfo_up{database="a \"quoted\"\\name\n"} 1`) {
+		t.Errorf("Expected escaped label values, got\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "duration") {
+		t.Error("Expected no duration without one")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var fail bool
+	h := Handler(func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return testInfo(), nil
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Header().Get("Content-Type") != ContentType || !strings.Contains(rec.Body.String(), `dbinfo_up{database="shop"} 1`) {
+		t.Errorf("Unexpected metrics response %v\n%s", rec.Header(), rec.Body.String())
+	}
+
+	fail = true
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 || rec.Body.String() != "# HELP dbinfo_up Whether the last introspection succeeded\n# TYPE dbinfo_up gauge\ndbinfo_up 0\n" {
+		t.Errorf("Expected a failed introspection to be reported as down, got %d\n%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,281 @@
+package dbinfo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+)
+
+// Option configures how GetDBInfo and StreamTables introspect a database
+type Option func(*options)
+
+// options holds the settings collected from the Option values
+type options struct {
+	schemas          []string
+	excludeSchemas   []string
+	tables           []string
+	excludeTables    []string
+	statementTimeout time.Duration
+	logger           *slog.Logger
+	rollUpPartitions bool
+	workload         bool
+	statistics       bool
+	privileges       bool
+
+	// Projection, the parts of each table left out
+	skipIndexes       bool
+	skipForeignKeys   bool
+	skipRelationships bool
+	skipComments      bool
+	skipViews         bool
+	skipSequences     bool
+	skipDomains       bool
+	skipForeignTables bool
+	skipEventTriggers bool
+	skipRules         bool
+
+	// Objects skipped while introspecting, see warn
+	warnings []*Warning
+	// What the server provides, read before the tables
+	capabilities *Capabilities
+}
+
+// newOptions applies opts over the defaults
+func newOptions(opts []Option) *options {
+	o := &options{
+		schemas:        []string{},
+		excludeSchemas: []string{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSchemas restricts introspection to the given schemas.
+// It can be used several times; by default every user schema is included.
+func WithSchemas(schemas ...string) Option {
+	return func(o *options) {
+		o.schemas = append(o.schemas, schemas...)
+	}
+}
+
+// WithExcludedSchemas skips the given schemas. Exclusions win over WithSchemas.
+func WithExcludedSchemas(schemas ...string) Option {
+	return func(o *options) {
+		o.excludeSchemas = append(o.excludeSchemas, schemas...)
+	}
+}
+
+// WithTables restricts introspection to the tables matching any of the glob
+// patterns. Patterns containing a dot are matched against schema.table and
+// the others against the table name alone, so "billing.*" selects a whole
+// schema and "user*" matches tables in every schema. The syntax is the one
+// of path.Match.
+func WithTables(patterns ...string) Option {
+	return func(o *options) {
+		o.tables = append(o.tables, patterns...)
+	}
+}
+
+// WithExcludedTables skips the tables matching any of the glob patterns,
+// using the same syntax as WithTables. Exclusions win over WithTables.
+func WithExcludedTables(patterns ...string) Option {
+	return func(o *options) {
+		o.excludeTables = append(o.excludeTables, patterns...)
+	}
+}
+
+// WithStatementTimeout cancels any catalog query running longer than d, so
+// a locked catalog can't make introspection hang. Zero disables the limit.
+// Use a context deadline to bound the whole introspection.
+func WithStatementTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.statementTimeout = d
+	}
+}
+
+// WithLogger reports progress to logger: every table introspected at info
+// level and every catalog query, with its arguments and duration, at debug
+// level. Nothing is logged by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithoutIndexes skips the index queries, leaving Table.Indexes empty
+func WithoutIndexes() Option {
+	return func(o *options) {
+		o.skipIndexes = true
+	}
+}
+
+// WithoutRelationships leaves HasMany and BelongsTo empty. Foreign keys are
+// still introspected.
+func WithoutRelationships() Option {
+	return func(o *options) {
+		o.skipRelationships = true
+	}
+}
+
+// WithoutComments skips reading table and column comments
+func WithoutComments() Option {
+	return func(o *options) {
+		o.skipComments = true
+	}
+}
+
+// WithoutViews skips the view queries, leaving DBInfo.Views and
+// DBInfo.MaterializedViews empty
+func WithoutViews() Option {
+	return func(o *options) {
+		o.skipViews = true
+	}
+}
+
+// WithoutSequences skips the sequence query, leaving DBInfo.Sequences
+// empty
+func WithoutSequences() Option {
+	return func(o *options) {
+		o.skipSequences = true
+	}
+}
+
+// WithoutDomains skips the domain query, leaving DBInfo.Domains empty.
+// Columns still name their domain.
+func WithoutDomains() Option {
+	return func(o *options) {
+		o.skipDomains = true
+	}
+}
+
+// WithoutForeignTables skips the foreign table queries, leaving
+// DBInfo.ForeignTables empty
+func WithoutForeignTables() Option {
+	return func(o *options) {
+		o.skipForeignTables = true
+	}
+}
+
+// WithoutEventTriggers skips the event trigger query, leaving
+// DBInfo.EventTriggers empty
+func WithoutEventTriggers() Option {
+	return func(o *options) {
+		o.skipEventTriggers = true
+	}
+}
+
+// WithoutRules skips the rewrite rule query, leaving Table.Rules and
+// View.Rules empty
+func WithoutRules() Option {
+	return func(o *options) {
+		o.skipRules = true
+	}
+}
+
+// WithColumnsOnly introspects tables and their columns only, skipping
+// indexes, foreign keys, relationships, views, sequences, domains, foreign
+// tables, rules and event triggers. It is the cheapest introspection.
+func WithColumnsOnly() Option {
+	return func(o *options) {
+		o.skipIndexes = true
+		o.skipForeignKeys = true
+		o.skipRelationships = true
+		o.skipViews = true
+		o.skipSequences = true
+		o.skipDomains = true
+		o.skipForeignTables = true
+		o.skipEventTriggers = true
+		o.skipRules = true
+	}
+}
+
+// WithRolledUpPartitions lists partitions under their parent table, in
+// Table.Partitions, instead of as tables of their own. Only the partitioned
+// tables are introspected, which keeps a table with hundreds of partitions
+// from showing up as hundreds of near identical tables. Partitions whose
+// parent is filtered out are still listed as tables. The workload of
+// partitions, and with GetTableStats and GetIndexStats their sizes, add up
+// in their table.
+func WithRolledUpPartitions() Option {
+	return func(o *options) {
+		o.rollUpPartitions = true
+	}
+}
+
+// WithWorkload makes GetDBInfo read how busy each table is from the
+// statistics collector, and from pg_stat_statements when it is installed,
+// into Table.Workload. See Workload for what the counters measure.
+func WithWorkload() Option {
+	return func(o *options) {
+		o.workload = true
+	}
+}
+
+// WithStatistics makes GetDBInfo read the estimated row count and the
+// sizes of each table, its indexes and its TOAST data into
+// Table.Statistics, for a capacity overview along with the structure. The
+// sizes of rolled up partitions add up in their table.
+func WithStatistics() Option {
+	return func(o *options) {
+		o.statistics = true
+	}
+}
+
+// WithPrivileges makes GetDBInfo read the owner of each table, view and
+// foreign table and the privileges granted on them and on their columns,
+// into their Owner and Grants. Privileges inherited through role
+// membership aren't expanded, as in GetTablePrivileges.
+func WithPrivileges() Option {
+	return func(o *options) {
+		o.privileges = true
+	}
+}
+
+// info logs a progress message when a logger is set
+func (o *options) info(ctx context.Context, msg string, args ...any) {
+	if o.logger != nil {
+		o.logger.InfoContext(ctx, msg, args...)
+	}
+}
+
+// validate reports malformed options before any query is run
+func (o *options) validate() error {
+	for _, patterns := range [][]string{o.tables, o.excludeTables} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid table pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	if o.statementTimeout < 0 {
+		return fmt.Errorf("invalid statement timeout %s", o.statementTimeout)
+	}
+	return nil
+}
+
+// includeTable reports whether the table passes the table filters
+func (o *options) includeTable(schema, name string) bool {
+	if len(o.tables) > 0 && !matchTable(o.tables, schema, name) {
+		return false
+	}
+	return !matchTable(o.excludeTables, schema, name)
+}
+
+// matchTable reports whether any of the patterns matches the table
+func matchTable(patterns []string, schema, name string) bool {
+	for _, pattern := range patterns {
+		subject := name
+		if strings.Contains(pattern, ".") {
+			subject = schema + "." + name
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package dbinfo
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Every slice of the model has a stable order, so snapshots are
+// reproducible and diffs between them are free of noise: tables by schema
+// and name, columns by position, and indexes, foreign keys and relationships
+// by name. Names are compared byte by byte rather than with the collation of
+// the database, so the order doesn't depend on the server locale.
+
+// sortTables orders tables by schema and name
+func sortTables(tables []*Table) {
+	slices.SortStableFunc(tables, func(a, b *Table) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+}
+
+// sortTable orders the indexes and foreign keys of table by name. Columns
+// keep the order of their position in the table.
+func sortTable(table *Table) {
+	sortIndexes(table.Indexes)
+	slices.SortStableFunc(table.ForeignKeys, func(a, b *ForeignKey) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// sortIndexes orders indexes by name
+func sortIndexes(indexes []*Index) {
+	slices.SortStableFunc(indexes, func(a, b *Index) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// sortRelationships orders relationships by foreign key name, then by the
+// database, schema and name of the related table, as foreign key names are
+// only unique within a table
+func sortRelationships(relationships []*Relationship) {
+	slices.SortStableFunc(relationships, func(a, b *Relationship) int {
+		return cmp.Or(cmp.Compare(a.ForeignKey, b.ForeignKey), cmp.Compare(a.Database, b.Database), cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Table, b.Table))
+	})
+}
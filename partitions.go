@@ -0,0 +1,52 @@
+package dbinfo
+
+// Partition is a partition of a table, listed under its parent when
+// partitions are rolled up with WithRolledUpPartitions
+type Partition struct {
+	Schema     string       `json:"schema" yaml:"schema"`
+	Name       string       `json:"name" yaml:"name"`
+	Bound      string       `json:"bound" yaml:"bound"`                               // e.g. FOR VALUES FROM ('2024-01-01') TO ('2024-02-01')
+	Partitions []*Partition `json:"partitions,omitempty" yaml:"partitions,omitempty"` // Partitions of a partitioned partition
+}
+
+// partitionOf is the parent and bound of a table that is a partition
+type partitionOf struct {
+	parent ObjectKey
+	bound  string
+}
+
+// rollUpPartitions removes the partitions whose parent is in tables from the
+// list and attaches them to their parent, keeping their order. Partitions
+// of a partition are attached to it in turn. Partitions whose parent was
+// filtered out stay in the list as tables.
+func rollUpPartitions(tables []*Table, partitions map[*Table]partitionOf) []*Table {
+	byKey := make(map[ObjectKey]*Table, len(tables))
+	for _, table := range tables {
+		byKey[ObjectKey{table.Schema, table.Name}] = table
+	}
+
+	// Nodes of the partitions that are rolled up, to attach their own
+	// partitions to them
+	nodes := make(map[ObjectKey]*Partition)
+	for table, of := range partitions {
+		if byKey[of.parent] != nil {
+			nodes[ObjectKey{table.Schema, table.Name}] = &Partition{Schema: table.Schema, Name: table.Name, Bound: of.bound}
+		}
+	}
+
+	var result []*Table
+	for _, table := range tables {
+		node := nodes[ObjectKey{table.Schema, table.Name}]
+		if node == nil {
+			result = append(result, table)
+			continue
+		}
+		parent := partitions[table].parent
+		if parentNode := nodes[parent]; parentNode != nil {
+			parentNode.Partitions = append(parentNode.Partitions, node)
+		} else {
+			byKey[parent].Partitions = append(byKey[parent].Partitions, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package pii
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteText writes one aligned line per finding to w
+func WriteText(w io.Writer, findings []*Finding) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s matches %s\n", f.Location, f.Type, f.Category, f.Reason, f.Match)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the findings to w as a JSON array
+func WriteJSON(w io.Writer, findings []*Finding) error {
+	if findings == nil {
+		findings = []*Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+// WriteMarkdown writes a privacy report to w: the flagged columns grouped by
+// category, for a review of where personal data lives
+func WriteMarkdown(w io.Writer, name string, findings []*Finding) error {
+	fmt.Fprintf(w, "# Personal data in %s\n\n", name)
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No column looks like personal data.")
+		return err
+	}
+
+	var categories []string
+	byCategory := map[string][]*Finding{}
+	tables := map[string]bool{}
+	for _, f := range findings {
+		if _, ok := byCategory[f.Category]; !ok {
+			categories = append(categories, f.Category)
+		}
+		byCategory[f.Category] = append(byCategory[f.Category], f)
+		tables[f.Location.Schema+"."+f.Location.Table] = true
+	}
+	fmt.Fprintf(w, "%d columns in %d tables look like personal data.\n\n", len(findings), len(tables))
+	fmt.Fprintln(w, "| Category | Columns |")
+	fmt.Fprintln(w, "|---|---|")
+	for _, category := range categories {
+		fmt.Fprintf(w, "| %s | %d |\n", category, len(byCategory[category]))
+	}
+
+	for _, category := range categories {
+		fmt.Fprintf(w, "\n## %s\n\n", category)
+		fmt.Fprintln(w, "| Column | Type | Reason |")
+		fmt.Fprintln(w, "|---|---|---|")
+		for _, f := range byCategory[category] {
+			fmt.Fprintf(w, "| %s | %s | %s matches `%s` |\n", f.Location, f.Type, f.Reason, f.Match)
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
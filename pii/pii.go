@@ -0,0 +1,273 @@
+// Package pii flags columns that likely hold personal data, such as email
+// addresses, phone numbers or dates of birth.
+//
+// Detection is heuristic: rules match column names, column types and
+// comments, so findings are candidates for a privacy review, not proof.
+// Findings can be written as a report or recorded in the model as column
+// tags, pii:<category>, which snapshots and the JSON and YAML formats keep.
+package pii
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// TagPrefix starts the column tags added by Tag
+const TagPrefix = "pii:"
+
+// Rule flags the columns of a category. A column matches when its name
+// matches one of Names, its type is one of Types or its comment matches one
+// of Comments. Patterns are regular expressions matched case-insensitively
+// anywhere in the text, so anchor them to match whole names.
+type Rule struct {
+	Category string   `yaml:"category" json:"category"`
+	Names    []string `yaml:"names" json:"names"`
+	Types    []string `yaml:"types" json:"types"`
+	Comments []string `yaml:"comments" json:"comments"`
+}
+
+// DefaultRules are the rules used unless a Config sets NoDefaults. The
+// first matching rule gives the category, so ip_address is an ip and not
+// an address.
+var DefaultRules = []*Rule{
+	{Category: "email", Names: []string{`e_?mail`}},
+	{Category: "ip", Names: []string{`ip_?addr`, `(^|_)ip$`, `mac_?addr`}, Types: []string{"inet", "cidr", "macaddr", "macaddr8"}},
+	{Category: "national-id", Names: []string{`(^|_)ssn($|_)`, `social_?security`, `national_?id`, `tax_?id`, `passport`, `driver_?licen[cs]e`}},
+	{Category: "dob", Names: []string{`(^|_)dob($|_)`, `birth_?date`, `date_of_birth`, `birthday`}},
+	{Category: "phone", Names: []string{`phone`, `mobile`, `(^|_)fax($|_)`, `msisdn`}},
+	{Category: "financial", Names: []string{`card_?number`, `(^|_)cc_?num`, `(^|_)cvv($|_)`, `iban`, `account_?number`}},
+	{Category: "name", Names: []string{`^(first|last|middle|full|given|family|sur)_?name$`}},
+	{Category: "address", Names: []string{`address`, `street`, `(^|_)zip($|_)`, `zip_?code`, `postal_?code`, `post_?code`}},
+	{Category: "personal", Comments: []string{`\bpii\b`, `personal(ly identifiable)? (data|information)`, `\bgdpr\b`}},
+}
+
+// Config configures detection
+type Config struct {
+	// NoDefaults leaves out DefaultRules, keeping only Rules
+	NoDefaults bool `yaml:"no-defaults" json:"no-defaults"`
+	// Rules are checked before the default rules
+	Rules []*Rule `yaml:"rules" json:"rules"`
+	// Ignore lists schema.table.column patterns, in the syntax of
+	// path.Match, of columns known not to hold personal data
+	Ignore []string `yaml:"ignore" json:"ignore"`
+}
+
+// LoadConfig reads a YAML (or JSON) detection configuration file
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pii config: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse pii config: %w", err)
+	}
+	if _, err := New(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Location identifies a column
+type Location struct {
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// String returns the location as schema.table.column
+func (l Location) String() string {
+	return l.Schema + "." + l.Table + "." + l.Column
+}
+
+// Reasons a column was flagged
+const (
+	ByName    = "name"
+	ByType    = "type"
+	ByComment = "comment"
+)
+
+// Finding is a column that likely holds personal data
+type Finding struct {
+	Location Location `json:"location"`
+	Type     string   `json:"type"`
+	Category string   `json:"category"`
+	Reason   string   `json:"reason"` // ByName, ByType or ByComment
+	Match    string   `json:"match"`  // The pattern or type that matched
+}
+
+// rule is a Rule with its patterns compiled
+type rule struct {
+	category string
+	names    []pattern
+	types    []string
+	comments []pattern
+}
+
+// pattern is a compiled pattern of a rule
+type pattern struct {
+	source string
+	re     *regexp.Regexp
+}
+
+// Detector flags the columns matching a set of rules
+type Detector struct {
+	rules  []*rule
+	ignore []string
+}
+
+// New returns a detector for cfg. A nil cfg uses the default rules.
+func New(cfg *Config) (*Detector, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	rules := cfg.Rules
+	if !cfg.NoDefaults {
+		rules = append(slices.Clip(rules), DefaultRules...)
+	}
+
+	d := &Detector{ignore: cfg.Ignore}
+	for _, glob := range cfg.Ignore {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", glob, err)
+		}
+	}
+	for i, r := range rules {
+		if r == nil || r.Category == "" {
+			return nil, fmt.Errorf("pii rule %d has no category", i+1)
+		}
+		compiled := &rule{category: r.Category}
+		for _, t := range r.Types {
+			compiled.types = append(compiled.types, strings.ToLower(t))
+		}
+		var err error
+		if compiled.names, err = compile(r.Names); err != nil {
+			return nil, fmt.Errorf("pii rule %s: %w", r.Category, err)
+		}
+		if compiled.comments, err = compile(r.Comments); err != nil {
+			return nil, fmt.Errorf("pii rule %s: %w", r.Category, err)
+		}
+		d.rules = append(d.rules, compiled)
+	}
+	return d, nil
+}
+
+// compile compiles case-insensitive patterns
+func compile(sources []string) ([]pattern, error) {
+	var patterns []pattern
+	for _, source := range sources {
+		re, err := regexp.Compile("(?i)" + source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", source, err)
+		}
+		patterns = append(patterns, pattern{source, re})
+	}
+	return patterns, nil
+}
+
+// Detect returns the columns of info likely holding personal data, in the
+// order of the tables and columns
+func (d *Detector) Detect(info *dbinfo.DBInfo) []*Finding {
+	var findings []*Finding
+	for _, table := range info.Tables {
+		findings = append(findings, d.Table(table)...)
+	}
+	return findings
+}
+
+// Table returns the columns of table likely holding personal data
+func (d *Detector) Table(table *dbinfo.Table) []*Finding {
+	// Key columns carry the identifier of another row, not the data itself:
+	// address_id points at an address
+	keys := map[string]bool{}
+	for _, fk := range table.ForeignKeys {
+		for _, name := range fk.ColumnNames {
+			keys[name] = true
+		}
+	}
+
+	var findings []*Finding
+	for _, column := range table.Columns {
+		loc := Location{Schema: table.Schema, Table: table.Name, Column: column.Name}
+		if d.ignored(loc) {
+			continue
+		}
+		if f := d.match(column, keys[column.Name]); f != nil {
+			f.Location, f.Type = loc, column.Type
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+func (d *Detector) ignored(loc Location) bool {
+	for _, glob := range d.ignore {
+		if ok, _ := path.Match(glob, loc.String()); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the finding of the first rule matching column, or nil
+func (d *Detector) match(column *dbinfo.Column, foreignKey bool) *Finding {
+	// Flags and timestamps named after personal data, such as
+	// email_verified or phone_confirmed_at, don't hold it
+	byName := !foreignKey && !flagType(column.Type)
+	for _, r := range d.rules {
+		if byName {
+			for _, p := range r.names {
+				if p.re.MatchString(column.Name) {
+					return &Finding{Category: r.category, Reason: ByName, Match: p.source}
+				}
+			}
+		}
+		if slices.Contains(r.types, strings.ToLower(column.Type)) {
+			return &Finding{Category: r.category, Reason: ByType, Match: column.Type}
+		}
+		for _, p := range r.comments {
+			if column.Comment != "" && p.re.MatchString(column.Comment) {
+				return &Finding{Category: r.category, Reason: ByComment, Match: p.source}
+			}
+		}
+	}
+	return nil
+}
+
+// flagType reports whether columns of type typ record an event or a flag
+func flagType(typ string) bool {
+	switch strings.ToLower(typ) {
+	case "boolean", "bool", "timestamp with time zone", "timestamp without time zone", "timestamptz", "timestamp":
+		return true
+	}
+	return false
+}
+
+// Tag adds a pii:<category> tag to the columns of info with findings
+func Tag(info *dbinfo.DBInfo, findings []*Finding) {
+	for _, table := range info.Tables {
+		TagTable(table, findings)
+	}
+}
+
+// TagTable adds a pii:<category> tag to the columns of table with findings
+func TagTable(table *dbinfo.Table, findings []*Finding) {
+	for _, f := range findings {
+		if f.Location.Schema != table.Schema || f.Location.Table != table.Name {
+			continue
+		}
+		for _, column := range table.Columns {
+			tag := TagPrefix + f.Category
+			if column.Name == f.Location.Column && !slices.Contains(column.Tags, tag) {
+				column.Tags = append(column.Tags, tag)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package pii
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema: "public",
+				Name:   "customers",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "Email", Type: "text"},
+					{Name: "email_verified", Type: "boolean"},
+					{Name: "phone_confirmed_at", Type: "timestamp with time zone"},
+					{Name: "first_name", Type: "text"},
+					{Name: "username", Type: "text"},
+					{Name: "birth_date", Type: "date"},
+					{Name: "last_ip_address", Type: "text"},
+					{Name: "signup_from", Type: "inet"},
+					{Name: "notes", Type: "text", Comment: "Free text, may contain PII"},
+					{Name: "address_id", Type: "integer"},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{{
+					Name:           "customers_address_fkey",
+					ColumnNames:    []string{"address_id"},
+					RefTableSchema: "public",
+					RefTableName:   "addresses",
+					RefColumnNames: []string{"id"},
+				}},
+			},
+			{
+				Schema: "public",
+				Name:   "addresses",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer"},
+					{Name: "street", Type: "text"},
+					{Name: "zip", Type: "text"},
+				},
+			},
+		},
+	}
+}
+
+func TestDetect(t *testing.T) {
+	d, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range d.Detect(testInfo()) {
+		got = append(got, f.Location.String()+" "+f.Category+" "+f.Reason)
+	}
+	want := []string{
+		"public.customers.Email email name",
+		"public.customers.first_name name name",
+		"public.customers.birth_date dob name",
+		"public.customers.last_ip_address ip name",
+		"public.customers.signup_from ip type",
+		"public.customers.notes personal comment",
+		"public.addresses.street address name",
+		"public.addresses.zip address name",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findings mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	cfg := &Config{
+		NoDefaults: true,
+		Rules:      []*Rule{{Category: "handle", Names: []string{`^user_?name$`}}, {Category: "address", Names: []string{`street|zip`}}},
+		Ignore:     []string{"public.addresses.z*"},
+	}
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range d.Detect(testInfo()) {
+		got = append(got, f.Location.String()+" "+f.Category)
+	}
+	want := []string{"public.customers.username handle", "public.addresses.street address"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findings mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tc := range []struct {
+		cfg  *Config
+		want string
+	}{
+		{&Config{Rules: []*Rule{{Names: []string{"x"}}}}, "pii rule 1 has no category"},
+		{&Config{Rules: []*Rule{{Category: "x", Names: []string{"("}}}}, `pii rule x: invalid pattern "("`},
+		{&Config{Ignore: []string{"["}}, `invalid ignore pattern "["`},
+	} {
+		if _, err := New(tc.cfg); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("New() error = %v, want %q", err, tc.want)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii.yaml")
+	data := "no-defaults: true\nrules:\n  - category: handle\n    names: ['^username$']\nignore: ['*.audit.*']\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{NoDefaults: true, Rules: []*Rule{{Category: "handle", Names: []string{"^username$"}}}, Ignore: []string{"*.audit.*"}}
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Errorf("config mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestTag(t *testing.T) {
+	info := testInfo()
+	info.Tables[0].Columns[1].Tags = []string{"pii:email"}
+	d, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := d.Detect(info)
+	Tag(info, findings)
+	Tag(info, findings)
+
+	got := map[string][]string{}
+	for _, table := range info.Tables {
+		for _, column := range table.Columns {
+			if len(column.Tags) > 0 {
+				got[table.Name+"."+column.Name] = column.Tags
+			}
+		}
+	}
+	want := map[string][]string{
+		"customers.Email":           {"pii:email"},
+		"customers.first_name":      {"pii:name"},
+		"customers.birth_date":      {"pii:dob"},
+		"customers.last_ip_address": {"pii:ip"},
+		"customers.signup_from":     {"pii:ip"},
+		"customers.notes":           {"pii:personal"},
+		"addresses.street":          {"pii:address"},
+		"addresses.zip":             {"pii:address"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("tags mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	d, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings := d.Detect(testInfo())[:2]
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, findings); err != nil {
+		t.Fatal(err)
+	}
+	want := "public.customers.Email       text  email  name matches e_?mail\n" +
+		"public.customers.first_name  text  name   name matches ^(first|last|middle|full|given|family|sur)_?name$\n"
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("text mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "[]\n" {
+		t.Errorf("WriteJSON(nil) = %q, want an empty array", got)
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, "shop", findings); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Personal data in shop\n",
+		"2 columns in 1 tables look like personal data.",
+		"| email | 1 |\n",
+		"## name\n",
+		"| public.customers.Email | text | name matches `e_?mail` |\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report misses %q:\n%s", want, buf.String())
+		}
+	}
+}
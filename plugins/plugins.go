@@ -0,0 +1,182 @@
+// Package plugins runs external programs that extend dbinfo, so
+// organizations can add output formats and lint rules without forking it.
+//
+// Plugins are executables found on PATH by name. dbinfo-export-<format>
+// adds an output format: it reads the schema as a JSON snapshot on stdin
+// and writes the output to stdout. dbinfo-lint-<rule> adds a lint rule: it
+// reads the snapshot the same way, with the options of the rule as a JSON
+// object in DBINFO_LINT_OPTIONS, and writes its findings to stdout as a JSON
+// array of {"location": {"schema", "table", "column"}, "message"} objects.
+// Plugins exit with a non-zero status on failure, explaining it on stderr.
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/format"
+	"github.com/guillermo/dbinfo/lint"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// Prefixes of the plugin executable names
+const (
+	ExporterPrefix = "dbinfo-export-"
+	LintPrefix     = "dbinfo-lint-"
+)
+
+// LintOptionsEnv holds the options of a lint rule plugin, as a JSON object
+const LintOptionsEnv = "DBINFO_LINT_OPTIONS"
+
+// Plugin is an executable extending dbinfo
+type Plugin struct {
+	Name string // Format or rule name, the executable name without prefix
+	Path string
+}
+
+// Find returns the plugins with the given prefix in the directories of
+// pathList, a list such as the PATH environment variable. Like the shell,
+// the first directory holding a name wins. Plugins are sorted by name.
+func Find(pathList, prefix string) []*Plugin {
+	var plugins []*Plugin
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), prefix)
+			if !ok {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if name, ok = strings.CutSuffix(strings.ToLower(name), ".exe"); !ok {
+					continue
+				}
+			}
+			path := filepath.Join(dir, entry.Name())
+			if name == "" || seen[name] || !executable(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, &Plugin{Name: name, Path: path})
+		}
+	}
+	slices.SortFunc(plugins, func(a, b *Plugin) int { return strings.Compare(a.Name, b.Name) })
+	return plugins
+}
+
+// executable reports whether path is a regular file that can be run
+func executable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || fi.Mode().Perm()&0o111 != 0
+}
+
+// Register makes the plugins on pathList available: exporters as formats
+// and lint rule plugins as optional rules, enabled in the lint
+// configuration. Plugins named like a built-in format or rule are skipped.
+func Register(pathList string) {
+	formats := format.Names()
+	for _, p := range Find(pathList, ExporterPrefix) {
+		if slices.Contains(formats, p.Name) {
+			continue
+		}
+		format.Register(p.Name, "plugin "+p.Path, p.Name, func(opts format.Options) format.Encoder {
+			return Exporter(p, opts)
+		})
+	}
+
+	rules := map[string]bool{}
+	for _, rule := range lint.Rules() {
+		rules[rule.Name] = true
+	}
+	for _, p := range Find(pathList, LintPrefix) {
+		if !rules[p.Name] {
+			lint.Register(Rule(p))
+		}
+	}
+}
+
+// Exporter returns an encoder running an exporter plugin. The options are
+// passed in the DBINFO_DEFAULT_SCHEMA and DBINFO_LINEAGE_NAMESPACE
+// environment variables.
+func Exporter(p *Plugin, opts format.Options) format.Encoder {
+	return format.EncoderFunc(func(w io.Writer, info *dbinfo.DBInfo) error {
+		env := []string{"DBINFO_DEFAULT_SCHEMA=" + opts.DefaultSchema, "DBINFO_LINEAGE_NAMESPACE=" + opts.Namespace}
+		return p.run(info, env, w)
+	})
+}
+
+// Rule returns an optional lint rule running a rule plugin, reporting
+// warnings unless configured otherwise
+func Rule(p *Plugin) *lint.Rule {
+	return &lint.Rule{
+		Name:        p.Name,
+		Description: "Plugin " + p.Path + ".",
+		Severity:    lint.Warning,
+		Optional:    true,
+		Check: func(c *lint.Context) {
+			options, err := json.Marshal(c.Options)
+			if err != nil {
+				c.Fail(fmt.Errorf("encoding options: %w", err))
+				return
+			}
+			var out bytes.Buffer
+			if err := p.run(c.Info, []string{LintOptionsEnv + "=" + string(options)}, &out); err != nil {
+				c.Fail(err)
+				return
+			}
+			var findings []struct {
+				Location lint.Location `json:"location"`
+				Message  string        `json:"message"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &findings); err != nil {
+				c.Fail(fmt.Errorf("plugin %s printed invalid findings: %w", p.Path, err))
+				return
+			}
+			for _, f := range findings {
+				c.Report(f.Location, "%s", f.Message)
+			}
+		},
+	}
+}
+
+// run runs the plugin with info as a JSON snapshot on stdin and the given
+// environment variables added, writing its output to w
+func (p *Plugin) run(info *dbinfo.DBInfo, env []string, w io.Writer) error {
+	var in bytes.Buffer
+	if err := snapshot.WriteJSON(&in, info); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(p.Path)
+	cmd.Stdin = &in
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), env...)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return fmt.Errorf("plugin %s: %s", p.Path, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("plugin %s: %w", p.Path, err)
+	}
+	return nil
+}
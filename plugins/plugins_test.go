@@ -0,0 +1,114 @@
+package plugins
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/format"
+	"github.com/guillermo/dbinfo/lint"
+)
+
+// writeScript creates an executable shell script in dir
+func writeScript(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func skipWithoutShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test: plugins are shell scripts")
+	}
+}
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name:   "shop",
+		Tables: []*dbinfo.Table{{Schema: "public", Name: "orders", Columns: []*dbinfo.Column{{Name: "id", Type: "integer"}}}},
+	}
+}
+
+func TestFind(t *testing.T) {
+	skipWithoutShell(t)
+	first, second := t.TempDir(), t.TempDir()
+	writeScript(t, first, "dbinfo-export-csv", "")
+	writeScript(t, second, "dbinfo-export-csv", "")
+	writeScript(t, second, "dbinfo-export-avro", "")
+	writeScript(t, second, "dbinfo-lint-owner", "")
+	if err := os.WriteFile(filepath.Join(second, "dbinfo-export-notes"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := Find(strings.Join([]string{first, filepath.Join(first, "missing"), second}, string(os.PathListSeparator)), ExporterPrefix)
+	want := []*Plugin{
+		{Name: "avro", Path: filepath.Join(second, "dbinfo-export-avro")},
+		{Name: "csv", Path: filepath.Join(first, "dbinfo-export-csv")},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("plugins mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	// Prints the table names of the snapshot and the default schema
+	writeScript(t, dir, "dbinfo-export-names", `grep -o '"name": "orders"' && echo "$DBINFO_DEFAULT_SCHEMA"`)
+	writeScript(t, dir, "dbinfo-export-broken", `echo "no license" >&2; exit 3`)
+
+	var buf bytes.Buffer
+	encoder := Exporter(&Plugin{Name: "names", Path: filepath.Join(dir, "dbinfo-export-names")}, format.Options{DefaultSchema: "public"})
+	if err := encoder.Encode(&buf, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "\"name\": \"orders\"\npublic\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	path := filepath.Join(dir, "dbinfo-export-broken")
+	err := Exporter(&Plugin{Name: "broken", Path: path}, format.Options{}).Encode(&buf, testInfo())
+	if err == nil || err.Error() != "plugin "+path+": no license" {
+		t.Errorf("Encode error = %v, want the plugin's stderr", err)
+	}
+}
+
+func TestRule(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	writeScript(t, dir, "dbinfo-lint-owner", `cat > /dev/null
+[ "$DBINFO_LINT_OPTIONS" = '{"tag":"team"}' ] || { echo "unexpected options $DBINFO_LINT_OPTIONS" >&2; exit 1; }
+echo '[{"location": {"schema": "public", "table": "orders"}, "message": "no owner tag"}]'`)
+	writeScript(t, dir, "dbinfo-lint-garbage", `echo 'not json'`)
+
+	rules := []*lint.Rule{
+		Rule(&Plugin{Name: "owner", Path: filepath.Join(dir, "dbinfo-lint-owner")}),
+		Rule(&Plugin{Name: "garbage", Path: filepath.Join(dir, "dbinfo-lint-garbage")}),
+	}
+	cfg := &lint.Config{Rules: map[string]*lint.RuleConfig{
+		"owner":   {Options: map[string]any{"tag": "team"}},
+		"garbage": {},
+	}}
+	var got []string
+	for _, f := range lint.Run(testInfo(), rules, cfg) {
+		got = append(got, string(f.Severity)+" "+f.Location.String()+" "+f.Rule+": "+f.Message)
+	}
+	want := []string{
+		"error  garbage: rule failed: plugin " + filepath.Join(dir, "dbinfo-lint-garbage") + " printed invalid findings: invalid character 'o' in literal null (expecting 'u')",
+		"warning public.orders owner: no owner tag",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("findings mismatch (-want +got):\n%s", diff)
+	}
+
+	// Optional rules only run when configured
+	if findings := lint.Run(testInfo(), rules, nil); len(findings) != 0 {
+		t.Errorf("Expected no findings without configuration, got %d", len(findings))
+	}
+}
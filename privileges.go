@@ -0,0 +1,250 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// PublicRole is the grantee of the privileges granted to every role
+const PublicRole = "PUBLIC"
+
+// Grant is a privilege a role holds on a table, view or column, granted or
+// held as the owner of the table
+type Grant struct {
+	Role      string `json:"role" yaml:"role"`           // PublicRole for the grants to PUBLIC
+	Privilege string `json:"privilege" yaml:"privilege"` // As in TablePrivilege
+	Grantable bool   `json:"grantable" yaml:"grantable"` // Held WITH GRANT OPTION
+}
+
+// TablePrivilege is a privilege a role holds on a table or view, granted or
+// held as its owner
+type TablePrivilege struct {
+	Schema    string `json:"schema" yaml:"schema"`
+	Table     string `json:"table" yaml:"table"`
+	Role      string `json:"role" yaml:"role"`           // PublicRole for the grants to PUBLIC
+	Privilege string `json:"privilege" yaml:"privilege"` // SELECT, INSERT, UPDATE, DELETE, TRUNCATE, REFERENCES, TRIGGER or MAINTAIN
+	Grantable bool   `json:"grantable" yaml:"grantable"` // Held WITH GRANT OPTION
+}
+
+// privilegesFilter restricts the privilege queries to the tables, views and
+// foreign tables of the user schemas included by $1 and not excluded by $2
+const privilegesFilter = `
+	WHERE c.relkind IN ('r', 'p', 'v', 'm', 'f')
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])`
+
+// privilegesQuery lists the privileges on the tables and views of the
+// selected schemas. Tables without an ACL have the default privileges of
+// their owner. Unlike information_schema.table_privileges, the ACLs list
+// the grants to every role, not only those involving the current one.
+const privilegesQuery = `
+	SELECT n.nspname, c.relname, COALESCE(r.rolname, 'PUBLIC'), a.privilege_type, a.is_grantable
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	CROSS JOIN LATERAL aclexplode(COALESCE(c.relacl, acldefault('r', c.relowner))) a
+	LEFT JOIN pg_roles r ON r.oid = a.grantee` + privilegesFilter
+
+// ownersQuery lists the owner of the tables and views of the selected
+// schemas, with the privileges of privilegesQuery. Tables whose ACL is
+// empty, every privilege having been revoked, have a single row with a
+// NULL grantee.
+const ownersQuery = `
+	SELECT n.nspname, c.relname, pg_catalog.pg_get_userbyid(c.relowner),
+	       CASE WHEN a.grantee = 0 THEN 'PUBLIC' ELSE r.rolname END, a.privilege_type, a.is_grantable
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN LATERAL pg_catalog.aclexplode(COALESCE(c.relacl, pg_catalog.acldefault('r', c.relowner))) a ON TRUE
+	LEFT JOIN pg_catalog.pg_roles r ON r.oid = a.grantee` + privilegesFilter
+
+// columnPrivilegesQuery lists the privileges granted on single columns of
+// the tables and views of the selected schemas, which column ACLs hold
+// apart from those of the tables
+const columnPrivilegesQuery = `
+	SELECT n.nspname, c.relname, att.attname,
+	       CASE WHEN a.grantee = 0 THEN 'PUBLIC' ELSE r.rolname END, a.privilege_type, a.is_grantable
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_catalog.pg_attribute att ON att.attrelid = c.oid AND att.attnum > 0 AND NOT att.attisdropped AND att.attacl IS NOT NULL
+	CROSS JOIN LATERAL pg_catalog.aclexplode(att.attacl) a
+	LEFT JOIN pg_catalog.pg_roles r ON r.oid = a.grantee` + privilegesFilter
+
+// GetTablePrivileges returns the privileges every role holds on the tables,
+// views and foreign tables selected by opts, views being filtered as tables,
+// sorted by schema, table, role and privilege. Privileges inherited through
+// role membership aren't expanded: a role member of another one is only
+// listed with the privileges granted to it directly. WithPrivileges records
+// the same privileges in the model.
+func GetTablePrivileges(ctx context.Context, db DBQuerier, opts ...Option) ([]*TablePrivilege, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := o.querier(db).Query(ctx, privilegesQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, queryError(PhasePrivileges, "", fmt.Errorf("failed to query table privileges: %w", err))
+	}
+	defer rows.Close()
+
+	var privileges []*TablePrivilege
+	for rows.Next() {
+		p := &TablePrivilege{}
+		if err := rows.Scan(&p.Schema, &p.Table, &p.Role, &p.Privilege, &p.Grantable); err != nil {
+			return nil, queryError(PhasePrivileges, "", fmt.Errorf("failed to scan table privilege row: %w", err))
+		}
+		if o.includeTable(p.Schema, p.Table) {
+			privileges = append(privileges, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(PhasePrivileges, "", fmt.Errorf("error iterating table privilege rows: %w", err))
+	}
+	slices.SortStableFunc(privileges, func(a, b *TablePrivilege) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Table, b.Table),
+			cmp.Compare(a.Role, b.Role), cmp.Compare(a.Privilege, b.Privilege))
+	})
+	return privileges, nil
+}
+
+// aclTarget is where the owner and grants of a table or view go in the
+// model
+type aclTarget struct {
+	owner   *string
+	grants  *[]*Grant
+	columns []*Column
+}
+
+// getPrivileges reads the owner and the grants of the tables, views and
+// foreign tables of info, and the grants on their columns. Ownership and
+// privileges the role can't read are recorded as a warning.
+func getPrivileges(ctx context.Context, db DBQuerier, o *options, info *DBInfo) error {
+	targets := make(map[ObjectKey]*aclTarget)
+	for _, t := range info.Tables {
+		targets[ObjectKey{t.Schema, t.Name}] = &aclTarget{&t.Owner, &t.Grants, t.Columns}
+	}
+	for _, v := range info.Views {
+		targets[ObjectKey{v.Schema, v.Name}] = &aclTarget{&v.Owner, &v.Grants, v.Columns}
+	}
+	for _, v := range info.MaterializedViews {
+		targets[ObjectKey{v.Schema, v.Name}] = &aclTarget{&v.Owner, &v.Grants, v.Columns}
+	}
+	for _, t := range info.ForeignTables {
+		targets[ObjectKey{t.Schema, t.Name}] = &aclTarget{&t.Owner, &t.Grants, t.Columns}
+	}
+
+	err := readPrivileges(ctx, db, o, targets)
+	if err != nil && skippable(err) {
+		o.warn(ctx, PhasePrivileges, "", err)
+		return nil
+	}
+	if err != nil {
+		return queryError(PhasePrivileges, "", err)
+	}
+	return nil
+}
+
+// readPrivileges fills targets from ownersQuery and columnPrivilegesQuery
+func readPrivileges(ctx context.Context, db DBQuerier, o *options, targets map[ObjectKey]*aclTarget) error {
+	rows, err := db.Query(ctx, ownersQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return fmt.Errorf("failed to query table owners: %w", err)
+	}
+	for rows.Next() {
+		var key ObjectKey
+		var owner string
+		var role, privilege *string // NULL for an empty ACL
+		var grantable *bool
+		if err := rows.Scan(&key.Schema, &key.Name, &owner, &role, &privilege, &grantable); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan table owner row: %w", err)
+		}
+		target := targets[key]
+		if target == nil {
+			continue
+		}
+		*target.owner = owner
+		if role != nil && privilege != nil {
+			*target.grants = append(*target.grants, &Grant{Role: *role, Privilege: *privilege, Grantable: grantable != nil && *grantable})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating table owner rows: %w", err)
+	}
+
+	rows, err = db.Query(ctx, columnPrivilegesQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return fmt.Errorf("failed to query column privileges: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key ObjectKey
+		var column string
+		var role *string // NULL for a dropped grantee
+		grant := &Grant{}
+		if err := rows.Scan(&key.Schema, &key.Name, &column, &role, &grant.Privilege, &grant.Grantable); err != nil {
+			return fmt.Errorf("failed to scan column privilege row: %w", err)
+		}
+		target := targets[key]
+		if target == nil || role == nil {
+			continue
+		}
+		grant.Role = *role
+		for _, c := range target.columns {
+			if c.Name == column {
+				c.Grants = append(c.Grants, grant)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating column privilege rows: %w", err)
+	}
+
+	for _, target := range targets {
+		sortGrants(*target.grants)
+		for _, c := range target.columns {
+			sortGrants(c.Grants)
+		}
+	}
+	return nil
+}
+
+// sortGrants orders grants by role and privilege
+func sortGrants(grants []*Grant) {
+	slices.SortStableFunc(grants, func(a, b *Grant) int {
+		return cmp.Or(cmp.Compare(a.Role, b.Role), cmp.Compare(a.Privilege, b.Privilege))
+	})
+}
+
+// TablePrivileges returns the privileges recorded in the model, taken
+// WithPrivileges, as GetTablePrivileges returns them: on tables, views,
+// materialized views and foreign tables, sorted by schema, table, role and
+// privilege. Grants on single columns aren't included.
+func (d *DBInfo) TablePrivileges() []*TablePrivilege {
+	var privileges []*TablePrivilege
+	add := func(schema, table string, grants []*Grant) {
+		for _, g := range grants {
+			privileges = append(privileges, &TablePrivilege{Schema: schema, Table: table, Role: g.Role, Privilege: g.Privilege, Grantable: g.Grantable})
+		}
+	}
+	for _, t := range d.Tables {
+		add(t.Schema, t.Name, t.Grants)
+	}
+	for _, v := range d.Views {
+		add(v.Schema, v.Name, v.Grants)
+	}
+	for _, v := range d.MaterializedViews {
+		add(v.Schema, v.Name, v.Grants)
+	}
+	for _, t := range d.ForeignTables {
+		add(t.Schema, t.Name, t.Grants)
+	}
+	slices.SortStableFunc(privileges, func(a, b *TablePrivilege) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Table, b.Table),
+			cmp.Compare(a.Role, b.Role), cmp.Compare(a.Privilege, b.Privilege))
+	})
+	return privileges
+}
@@ -0,0 +1,130 @@
+package dbinfo
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// querier returns db, logging queries when a logger is set and limited by
+// the statement timeout when one is set
+func (o *options) querier(db DBQuerier) DBQuerier {
+	if o.logger != nil {
+		db = &loggingQuerier{db: db, logger: o.logger}
+	}
+	if o.statementTimeout > 0 {
+		db = &timeoutQuerier{db: db, timeout: o.statementTimeout}
+	}
+	return db
+}
+
+// timeoutQuerier gives every query its own deadline. The deadline is
+// released once the rows are closed or the row is scanned.
+type timeoutQuerier struct {
+	db      DBQuerier
+	timeout time.Duration
+}
+
+func (q *timeoutQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	rows, err := q.db.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (q *timeoutQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	return &timeoutRow{row: q.db.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+type timeoutRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+type timeoutRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return r.row.Scan(dest...)
+}
+
+// loggingQuerier logs every query at debug level, with its duration and
+// error. Queries are logged once their rows are closed or scanned.
+type loggingQuerier struct {
+	db     DBQuerier
+	logger *slog.Logger
+}
+
+func (q *loggingQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := q.db.Query(ctx, sql, args...)
+	if err != nil {
+		q.log(ctx, sql, args, start, err)
+		return nil, err
+	}
+	return &loggingRows{Rows: rows, ctx: ctx, q: q, sql: sql, args: args, start: start}, nil
+}
+
+func (q *loggingQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &loggingRow{row: q.db.QueryRow(ctx, sql, args...), ctx: ctx, q: q, sql: sql, args: args, start: time.Now()}
+}
+
+func (q *loggingQuerier) log(ctx context.Context, sql string, args []any, start time.Time, err error) {
+	attrs := []any{
+		slog.String("sql", strings.Join(strings.Fields(sql), " ")),
+		slog.Any("args", args),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	q.logger.DebugContext(ctx, "query", attrs...)
+}
+
+type loggingRows struct {
+	pgx.Rows
+	ctx    context.Context
+	q      *loggingQuerier
+	sql    string
+	args   []any
+	start  time.Time
+	closed bool
+}
+
+func (r *loggingRows) Close() {
+	r.Rows.Close()
+	if !r.closed {
+		r.closed = true
+		r.q.log(r.ctx, r.sql, r.args, r.start, r.Rows.Err())
+	}
+}
+
+type loggingRow struct {
+	row   pgx.Row
+	ctx   context.Context
+	q     *loggingQuerier
+	sql   string
+	args  []any
+	start time.Time
+}
+
+func (r *loggingRow) Scan(dest ...any) error {
+	err := r.row.Scan(dest...)
+	r.q.log(r.ctx, r.sql, r.args, r.start, err)
+	return err
+}
@@ -0,0 +1,88 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Rule is a rewrite rule of a table or view, which turns the statements
+// on it into other ones, such as an INSERT on a view into one on its table
+type Rule struct {
+	Name  string `json:"name" yaml:"name"`
+	Event string `json:"event" yaml:"event"` // SELECT, INSERT, UPDATE or DELETE
+	// Instead tells whether the rule replaces the statement rather than
+	// running along with it
+	Instead bool `json:"instead" yaml:"instead"`
+	// Enabled tells when the rule applies, as in EventTrigger
+	Enabled string `json:"enabled" yaml:"enabled"`
+	// Definition is the CREATE RULE statement, as pg_get_ruledef prints it
+	// without its final semicolon
+	Definition string `json:"definition" yaml:"definition"`
+	Comment    string `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// rulesQuery lists the rules of the tables and views of the user schemas
+// included by $1 and not excluded by $2, with their comments when $3 is
+// set. The _RETURN rule every view has is its SELECT, which View holds.
+const rulesQuery = `
+	SELECT n.nspname, c.relname, r.rulename,
+	       CASE r.ev_type WHEN '1' THEN 'SELECT' WHEN '2' THEN 'UPDATE' WHEN '3' THEN 'INSERT' WHEN '4' THEN 'DELETE' END,
+	       r.is_instead, r.ev_enabled::text, pg_catalog.pg_get_ruledef(r.oid),
+	       CASE WHEN $3::boolean THEN pg_catalog.obj_description(r.oid, 'pg_rewrite') END
+	FROM pg_catalog.pg_rewrite r
+	JOIN pg_catalog.pg_class c ON c.oid = r.ev_class
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE r.rulename <> '_RETURN' AND c.relkind IN ('r', 'p', 'v')
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	ORDER BY n.nspname, c.relname, r.rulename`
+
+// getRules reads the rules of the tables and views of info, sorted by name
+func getRules(ctx context.Context, db DBQuerier, o *options, info *DBInfo) error {
+	targets := make(map[ObjectKey]*[]*Rule)
+	for _, t := range info.Tables {
+		targets[ObjectKey{t.Schema, t.Name}] = &t.Rules
+	}
+	for _, v := range info.Views {
+		targets[ObjectKey{v.Schema, v.Name}] = &v.Rules
+	}
+
+	rows, err := db.Query(ctx, rulesQuery, o.schemas, o.excludeSchemas, !o.skipComments)
+	if err != nil {
+		return fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key ObjectKey
+		r := &Rule{}
+		var enabled string
+		var comment *string // NULL without a comment
+		if err := rows.Scan(&key.Schema, &key.Name, &r.Name, &r.Event, &r.Instead, &enabled, &r.Definition, &comment); err != nil {
+			return fmt.Errorf("failed to scan rule row: %w", err)
+		}
+		target := targets[key]
+		if target == nil {
+			continue
+		}
+		r.Enabled = cmp.Or(triggerStates[enabled], enabled)
+		r.Definition = strings.TrimSuffix(r.Definition, ";")
+		if comment != nil {
+			r.Comment = *comment
+		}
+		*target = append(*target, r)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rule rows: %w", err)
+	}
+
+	for _, rules := range targets {
+		slices.SortStableFunc(*rules, func(a, b *Rule) int {
+			return cmp.Compare(a.Name, b.Name)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Package sample installs the e-commerce sample schema used by the dbinfo
+// tests: categories, products, customers, orders and order_items, with
+// comments, indexes, foreign keys and a few rows. It gives new users a
+// database to try dbinfo on.
+package sample
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Schema creates the sample tables and fills them
+//
+//go:embed schema.sql
+var Schema string
+
+// DropSchema removes the sample tables, ignoring the missing ones
+//
+//go:embed drop.sql
+var DropSchema string
+
+// Tables are the sample tables, in creation order
+var Tables = []string{"categories", "products", "customers", "orders", "order_items"}
+
+// Execer runs SQL statements. *pgxpool.Pool, *pgx.Conn and pgx.Tx
+// implement it.
+type Execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Create installs the sample schema in the current schema of db. The
+// statements run in a single transaction, and it fails without changing
+// anything if one of the tables already exists.
+func Create(ctx context.Context, db Execer) error {
+	if _, err := db.Exec(ctx, Schema); err != nil {
+		return fmt.Errorf("failed to create the sample schema: %w", err)
+	}
+	return nil
+}
+
+// Drop removes the sample tables and their data from db
+func Drop(ctx context.Context, db Execer) error {
+	if _, err := db.Exec(ctx, DropSchema); err != nil {
+		return fmt.Errorf("failed to drop the sample schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package sample
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func TestScripts(t *testing.T) {
+	for _, table := range Tables {
+		if !strings.Contains(Schema, "CREATE TABLE "+table+" (") {
+			t.Errorf("Expected the schema to create %s", table)
+		}
+		if !strings.Contains(DropSchema, "DROP TABLE IF EXISTS "+table+";") {
+			t.Errorf("Expected the drop script to remove %s", table)
+		}
+	}
+	if strings.Contains(Schema, "DROP ") {
+		t.Error("Expected the schema not to drop existing tables")
+	}
+}
+
+func TestCreateAndDrop(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("Skipping test: TEST_POSTGRES_DSN environment variable not set")
+	}
+
+	ctx := context.Background()
+	pool, err := dbinfo.FromString(ctx, dsn)
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer pool.Close()
+
+	// Work on a copy in a schema of its own, leaving the fixture of the
+	// other tests alone
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "CREATE SCHEMA sample_test; SET search_path TO sample_test"); err != nil {
+		t.Fatalf("Failed to create test schema: %v", err)
+	}
+	defer conn.Exec(ctx, "RESET search_path; DROP SCHEMA sample_test CASCADE")
+
+	if err := Create(ctx, conn); err != nil {
+		t.Fatalf("Failed to create the sample schema: %v", err)
+	}
+	if err := Create(ctx, conn); err == nil {
+		t.Error("Expected creating the sample schema twice to fail")
+	}
+
+	info, err := dbinfo.GetDBInfo(ctx, pool, dbinfo.WithSchemas("sample_test"))
+	if err != nil {
+		t.Fatalf("Failed to get database info: %v", err)
+	}
+	if len(info.Tables) != len(Tables) {
+		t.Errorf("Expected %d sample tables, got %d", len(Tables), len(info.Tables))
+	}
+
+	if err := Drop(ctx, conn); err != nil {
+		t.Fatalf("Failed to drop the sample schema: %v", err)
+	}
+	if err := Drop(ctx, conn); err != nil {
+		t.Errorf("Expected dropping a missing sample schema to succeed, got %v", err)
+	}
+}
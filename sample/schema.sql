@@ -1,9 +1,5 @@
--- Clean up if tables already exist
-DROP TABLE IF EXISTS order_items;
-DROP TABLE IF EXISTS orders;
-DROP TABLE IF EXISTS products;
-DROP TABLE IF EXISTS categories;
-DROP TABLE IF EXISTS customers;
+-- E-commerce sample schema, used by the tests and by 'dbinfo demo -create'.
+-- sample/drop.sql removes it.
 
 -- Create tables with various features to test
 
@@ -0,0 +1,143 @@
+// Package schemaspy writes dbinfo schemas in the XML metadata format consumed
+// by SchemaSpy (https://schemaspy.org) through its -meta option.
+package schemaspy
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// SchemaLocation is the XSD that SchemaSpy validates metadata files against
+const SchemaLocation = "http://schemaspy.org/xsd/6/schemameta.xsd"
+
+// SchemaMeta is the root element of a SchemaSpy metadata file
+type SchemaMeta struct {
+	XMLName        xml.Name `xml:"schemaMeta"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:noNamespaceSchemaLocation,attr"`
+	Comments       string   `xml:"comments,omitempty"`
+	Tables         []*Table `xml:"tables>table"`
+}
+
+// Table describes a table in a SchemaSpy metadata file
+type Table struct {
+	Name         string    `xml:"name,attr"`
+	RemoteSchema string    `xml:"remoteSchema,attr,omitempty"`
+	Comments     string    `xml:"comments,attr,omitempty"`
+	Columns      []*Column `xml:"column"`
+}
+
+// Column describes a table column in a SchemaSpy metadata file
+type Column struct {
+	Name         string        `xml:"name,attr"`
+	Type         string        `xml:"type,attr"`
+	Nullable     bool          `xml:"nullable,attr"`
+	AutoUpdated  bool          `xml:"autoUpdated,attr"`
+	PrimaryKey   bool          `xml:"primaryKey,attr"`
+	DefaultValue string        `xml:"defaultValue,attr,omitempty"`
+	Comments     string        `xml:"comments,attr,omitempty"`
+	ForeignKeys  []*ForeignKey `xml:"foreignKey"`
+}
+
+// ForeignKey describes a column reference in a SchemaSpy metadata file
+type ForeignKey struct {
+	Table        string `xml:"table,attr"`
+	Column       string `xml:"column,attr"`
+	RemoteSchema string `xml:"remoteSchema,attr,omitempty"`
+}
+
+// Convert builds the SchemaSpy metadata document for a database.
+// SchemaSpy analyzes one schema at a time, so tables living outside of
+// defaultSchema are emitted as remote tables. Foreign keys pointing to a
+// table outside of defaultSchema are marked the same way, also between two
+// tables of the same remote schema. Columns declared with a domain
+// are typed with the domain, and their comments list its constraints.
+func Convert(info *dbinfo.DBInfo, defaultSchema string) *SchemaMeta {
+	meta := &SchemaMeta{
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: SchemaLocation,
+		Tables:         make([]*Table, 0, len(info.Tables)),
+	}
+
+	for _, table := range info.Tables {
+		metaTable := &Table{
+			Name:     table.Name,
+			Comments: table.Comment,
+			Columns:  make([]*Column, 0, len(table.Columns)),
+		}
+		if table.Schema != defaultSchema {
+			metaTable.RemoteSchema = table.Schema
+		}
+
+		// Index foreign keys by local column so they can be nested below it
+		references := make(map[string][]*ForeignKey)
+		for _, fk := range table.ForeignKeys {
+			for i, column := range fk.ColumnNames {
+				if i >= len(fk.RefColumnNames) {
+					break
+				}
+				ref := &ForeignKey{
+					Table:  fk.RefTableName,
+					Column: fk.RefColumnNames[i],
+				}
+				if fk.RefTableSchema != defaultSchema {
+					ref.RemoteSchema = fk.RefTableSchema
+				}
+				references[column] = append(references[column], ref)
+			}
+		}
+
+		for _, column := range table.Columns {
+			columnType, comments := column.Type, column.Comment
+			if domain := info.ColumnDomain(column); domain != nil {
+				columnType = domain.Name
+				comments = domainComment(comments, domain)
+			}
+			metaTable.Columns = append(metaTable.Columns, &Column{
+				Name:         column.Name,
+				Type:         columnType,
+				Nullable:     column.IsNullable,
+				AutoUpdated:  column.AutoIncrement() || strings.HasPrefix(column.DefaultValue, "nextval("),
+				PrimaryKey:   column.IsPrimaryKey,
+				DefaultValue: column.DefaultValue,
+				Comments:     comments,
+				ForeignKeys:  references[column.Name],
+			})
+		}
+
+		meta.Tables = append(meta.Tables, metaTable)
+	}
+
+	return meta
+}
+
+// domainComment appends the base type and constraints of domain to the
+// comment of a column declared with it
+func domainComment(comment string, domain *dbinfo.Domain) string {
+	description := fmt.Sprintf("Domain %s over %s", domain.Name, domain.Type)
+	if constraints := domain.Constraints(); len(constraints) > 0 {
+		description += ": " + strings.Join(constraints, ", ")
+	}
+	if comment == "" {
+		return description
+	}
+	return comment + " (" + description + ")"
+}
+
+// Encode writes the SchemaSpy metadata document for a database to w
+func Encode(w io.Writer, info *dbinfo.DBInfo, defaultSchema string) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(Convert(info, defaultSchema)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,157 @@
+package schemaspy
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:    "categories",
+				Schema:  "public",
+				Comment: "Product categories",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true, DefaultValue: "nextval('categories_id_seq'::regclass)"},
+					{Name: "name", Type: "character varying", Comment: "Category name"},
+				},
+			},
+			{
+				Name:   "products",
+				Schema: "public",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "category_id", Type: "integer"},
+					{Name: "invoice_id", Type: "integer", IsNullable: true},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{
+						Name:           "products_category_id_fkey",
+						ColumnNames:    []string{"category_id"},
+						RefTableSchema: "public",
+						RefTableName:   "categories",
+						RefColumnNames: []string{"id"},
+					},
+					{
+						Name:           "products_invoice_id_fkey",
+						ColumnNames:    []string{"invoice_id"},
+						RefTableSchema: "billing",
+						RefTableName:   "invoices",
+						RefColumnNames: []string{"id"},
+					},
+				},
+			},
+			{
+				Name:   "invoices",
+				Schema: "billing",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+				},
+			},
+		},
+	}
+}
+
+func TestConvert(t *testing.T) {
+	meta := Convert(testInfo(), "public")
+
+	if len(meta.Tables) != 3 {
+		t.Fatalf("Expected 3 tables, got %d", len(meta.Tables))
+	}
+
+	categories := meta.Tables[0]
+	if categories.Comments != "Product categories" {
+		t.Errorf("Expected table comment 'Product categories', got %q", categories.Comments)
+	}
+	if !categories.Columns[0].AutoUpdated {
+		t.Error("Expected serial column categories.id to be autoUpdated")
+	}
+	if categories.Columns[1].AutoUpdated {
+		t.Error("Expected categories.name not to be autoUpdated")
+	}
+
+	products := meta.Tables[1]
+	if fks := products.Columns[1].ForeignKeys; len(fks) != 1 || fks[0].Table != "categories" || fks[0].Column != "id" || fks[0].RemoteSchema != "" {
+		t.Errorf("Unexpected foreign keys for products.category_id: %+v", fks)
+	}
+	if fks := products.Columns[2].ForeignKeys; len(fks) != 1 || fks[0].RemoteSchema != "billing" {
+		t.Errorf("Expected products.invoice_id to reference the remote billing schema, got %+v", fks)
+	}
+
+	if meta.Tables[2].RemoteSchema != "billing" {
+		t.Errorf("Expected invoices to be a remote table in billing, got %q", meta.Tables[2].RemoteSchema)
+	}
+}
+
+func TestConvertRemoteForeignKeys(t *testing.T) {
+	info := testInfo()
+	info.Tables = append(info.Tables, &dbinfo.Table{
+		Name:    "payments",
+		Schema:  "billing",
+		Columns: []*dbinfo.Column{{Name: "invoice_id", Type: "integer"}},
+		ForeignKeys: []*dbinfo.ForeignKey{
+			{Name: "payments_invoice_id_fkey", ColumnNames: []string{"invoice_id"}, RefTableSchema: "billing", RefTableName: "invoices", RefColumnNames: []string{"id"}},
+		},
+	})
+
+	// The referenced table is remote even though both are in billing
+	payments := Convert(info, "public").Tables[3]
+	if fks := payments.Columns[0].ForeignKeys; len(fks) != 1 || fks[0].RemoteSchema != "billing" {
+		t.Errorf("Expected payments.invoice_id to reference the remote billing schema, got %+v", fks)
+	}
+
+	// From the billing schema, the invoices are local
+	payments = Convert(info, "billing").Tables[3]
+	if fks := payments.Columns[0].ForeignKeys; len(fks) != 1 || fks[0].RemoteSchema != "" {
+		t.Errorf("Expected payments.invoice_id to reference a local table, got %+v", fks)
+	}
+}
+
+func TestConvertDomains(t *testing.T) {
+	info := testInfo()
+	info.Domains = []*dbinfo.Domain{{Schema: "public", Name: "label", Type: "character varying(100)", NotNull: true,
+		Checks: []*dbinfo.DomainCheck{{Name: "label_check", Definition: "CHECK ((VALUE <> ''::text))"}}}}
+	info.Tables[0].Columns[1].Domain = &dbinfo.DomainRef{Schema: "public", Name: "label"}
+
+	name := Convert(info, "public").Tables[0].Columns[1]
+	if name.Type != "label" {
+		t.Errorf("Expected categories.name to be typed with its domain, got %q", name.Type)
+	}
+	expected := "Category name (Domain label over character varying(100): NOT NULL, CHECK ((VALUE <> ''::text)))"
+	if name.Comments != expected {
+		t.Errorf("Unexpected comments %q, expected %q", name.Comments, expected)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testInfo(), "public"); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, xml.Header) {
+		t.Error("Expected output to start with the XML header")
+	}
+	if !strings.Contains(out, `xsi:noNamespaceSchemaLocation="`+SchemaLocation+`"`) {
+		t.Error("Expected output to reference the SchemaSpy XSD")
+	}
+	if !strings.Contains(out, `<foreignKey table="categories" column="id"></foreignKey>`) {
+		t.Errorf("Expected nested foreign key element, got:\n%s", out)
+	}
+
+	// The output must be well formed XML
+	var decoded SchemaMeta
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode output: %v", err)
+	}
+	if len(decoded.Tables) != 3 {
+		t.Errorf("Expected 3 decoded tables, got %d", len(decoded.Tables))
+	}
+}
@@ -0,0 +1,129 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Sequence is a sequence, standalone or owned by a serial or identity
+// column
+type Sequence struct {
+	Name      string `json:"name" yaml:"name"`
+	Schema    string `json:"schema" yaml:"schema"`
+	Type      string `json:"type" yaml:"type"` // smallint, integer or bigint
+	Start     int64  `json:"start" yaml:"start"`
+	Increment int64  `json:"increment" yaml:"increment"`
+	Min       int64  `json:"min" yaml:"min"`
+	Max       int64  `json:"max" yaml:"max"`
+	Cache     int64  `json:"cache" yaml:"cache"`
+	Cycle     bool   `json:"cycle" yaml:"cycle"`
+	// LastValue is the last value handed out, nil when none was yet or the
+	// sequence can't be read without the SELECT or USAGE privilege
+	LastValue *int64 `json:"lastvalue,omitempty" yaml:"lastvalue,omitempty"`
+	// OwnedBy is the column the sequence is dropped with, nil for
+	// standalone sequences
+	OwnedBy *SequenceOwner `json:"ownedby,omitempty" yaml:"ownedby,omitempty"`
+}
+
+// SequenceOwner is the column owning a sequence
+type SequenceOwner struct {
+	Schema string `json:"schema" yaml:"schema"`
+	Table  string `json:"table" yaml:"table"`
+	Column string `json:"column" yaml:"column"`
+	// Identity is set for the sequence of an identity column, which the
+	// column creates, rather than of a serial or OWNED BY one
+	Identity bool `json:"identity,omitempty" yaml:"identity,omitempty"`
+}
+
+// QualifiedName returns the quoted schema.name of the sequence
+func (s *Sequence) QualifiedName() string {
+	return QualifiedName(s.Schema, s.Name)
+}
+
+// Used returns the share of the values of the sequence already handed out,
+// from 0 to 1, measuring how close it is to running out. It is 0 when
+// LastValue is unknown. Cycling sequences never run out but wrap around.
+func (s *Sequence) Used() float64 {
+	if s.LastValue == nil {
+		return 0
+	}
+	// Float arithmetic, as Max - Min overflows int64 for bigint sequences
+	span := float64(s.Max) - float64(s.Min)
+	if span <= 0 {
+		return 1
+	}
+	used := float64(*s.LastValue) - float64(s.Min)
+	if s.Increment < 0 {
+		used = float64(s.Max) - float64(*s.LastValue)
+	}
+	return math.Min(math.Max(used/span, 0), 1)
+}
+
+// sequencesQuery lists the sequences of the user schemas included by $1
+// and not excluded by $2, leaving out those of extensions, with the column
+// owning each one: an auto dependency for serial and OWNED BY columns, an
+// internal one for identity columns. pg_sequences hides the last value of
+// sequences the role can't read.
+const sequencesQuery = `
+	SELECT n.nspname, c.relname, pg_catalog.format_type(s.seqtypid, NULL),
+	       s.seqstart, s.seqincrement, s.seqmin, s.seqmax, s.seqcache, s.seqcycle, ps.last_value,
+	       tn.nspname, t.relname, a.attname, d.deptype = 'i'
+	FROM pg_catalog.pg_sequence s
+	JOIN pg_catalog.pg_class c ON c.oid = s.seqrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_catalog.pg_sequences ps ON ps.schemaname = n.nspname AND ps.sequencename = c.relname
+	LEFT JOIN pg_catalog.pg_depend d ON d.classid = 'pg_catalog.pg_class'::regclass AND d.objid = c.oid
+	     AND d.refclassid = 'pg_catalog.pg_class'::regclass AND d.refobjsubid > 0 AND d.deptype IN ('a', 'i')
+	LEFT JOIN pg_catalog.pg_class t ON t.oid = d.refobjid
+	LEFT JOIN pg_catalog.pg_namespace tn ON tn.oid = t.relnamespace
+	LEFT JOIN pg_catalog.pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+	WHERE n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	AND NOT EXISTS (
+	    SELECT 1 FROM pg_catalog.pg_depend e
+	    WHERE e.classid = 'pg_catalog.pg_class'::regclass AND e.objid = c.oid AND e.deptype = 'e'
+	)
+	ORDER BY n.nspname, c.relname`
+
+// getSequences reads the sequences of the schemas the options select,
+// sorted by schema and name. The table filters apply to the table owning a
+// sequence, or to the name of standalone sequences.
+func getSequences(ctx context.Context, db DBQuerier, o *options) ([]*Sequence, error) {
+	rows, err := db.Query(ctx, sequencesQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sequences: %w", err)
+	}
+	defer rows.Close()
+
+	var sequences []*Sequence
+	for rows.Next() {
+		s := &Sequence{}
+		var ownerSchema, ownerTable, ownerColumn *string // NULL for standalone sequences
+		var identity *bool
+		err := rows.Scan(&s.Schema, &s.Name, &s.Type, &s.Start, &s.Increment, &s.Min, &s.Max, &s.Cache, &s.Cycle, &s.LastValue,
+			&ownerSchema, &ownerTable, &ownerColumn, &identity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sequence row: %w", err)
+		}
+		if ownerSchema != nil && ownerTable != nil && ownerColumn != nil {
+			s.OwnedBy = &SequenceOwner{Schema: *ownerSchema, Table: *ownerTable, Column: *ownerColumn, Identity: identity != nil && *identity}
+			if !o.includeTable(s.OwnedBy.Schema, s.OwnedBy.Table) {
+				continue
+			}
+		} else if !o.includeTable(s.Schema, s.Name) {
+			continue
+		}
+		sequences = append(sequences, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sequence rows: %w", err)
+	}
+	slices.SortStableFunc(sequences, func(a, b *Sequence) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	return sequences, nil
+}
@@ -0,0 +1,329 @@
+// Package server exposes dbinfo schemas over an HTTP JSON API, so tools can
+// query schema metadata without direct database access.
+//
+// Endpoints:
+//
+//	GET  /schema                  the whole schema
+//	GET  /tables                  schema, name and comment of every table
+//	GET  /tables/{schema}/{name}  a single table
+//	GET  /diff?against=<name>     changes from a named snapshot to the schema
+//	POST /diff                    changes from the snapshot in the body to the schema
+//	GET  /metrics                 Prometheus metrics, with WithMetrics
+//	GET  /graphql?query=<query>   a GraphQL query over the schema, with WithGraphQL
+//	POST /graphql                 a GraphQL request in the body, with WithGraphQL
+//	GET  /graphql/schema          the GraphQL schema definition, with WithGraphQL
+//	GET  /healthz                 liveness probe, never requires a token
+//	GET  /                        web UI with a searchable table list and an
+//	                              interactive relationship diagram
+//
+// Introspection results are cached for a configurable time; ?refresh=true
+// reloads them immediately.
+package server
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/graphql"
+	"github.com/guillermo/dbinfo/internal/cache"
+	"github.com/guillermo/dbinfo/metrics"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+//go:embed ui
+var ui embed.FS
+
+// maxSnapshotSize limits the size of snapshots posted to /diff
+const maxSnapshotSize = 32 << 20
+
+// maxGraphQLRequestSize limits the size of requests posted to /graphql
+const maxGraphQLRequestSize = 1 << 20
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithCacheTTL sets how long introspection results are reused. Zero loads
+// the schema on every request. Defaults to one minute.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(s *Server) {
+		s.ttl = ttl
+	}
+}
+
+// WithToken requires requests to send "Authorization: Bearer <token>"
+func WithToken(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// WithSnapshot makes a snapshot available to /diff?against=<name>
+func WithSnapshot(name string, info *dbinfo.DBInfo) Option {
+	return func(s *Server) {
+		s.snapshots[name] = info
+	}
+}
+
+// WithUI enables or disables the web UI. It is enabled by default.
+func WithUI(enabled bool) Option {
+	return func(s *Server) {
+		s.ui = enabled
+	}
+}
+
+// WithMetrics enables /metrics, serving the metrics of the metrics package
+// for the cached schema, with its drift from every snapshot. It is disabled
+// by default.
+func WithMetrics(enabled bool) Option {
+	return func(s *Server) {
+		s.metrics = enabled
+	}
+}
+
+// WithGraphQL enables /graphql, answering read-only GraphQL queries over
+// the cached schema, as described by the graphql package. It is disabled by
+// default.
+func WithGraphQL(enabled bool) Option {
+	return func(s *Server) {
+		s.graphql = enabled
+	}
+}
+
+// Server is an http.Handler serving a schema
+type Server struct {
+	cache     *cache.Schema
+	ttl       time.Duration
+	token     string
+	ui        bool
+	metrics   bool
+	graphql   bool
+	snapshots map[string]*dbinfo.DBInfo
+	mux       *http.ServeMux
+}
+
+// New returns a server introspecting the schema with load
+func New(load dbinfo.Loader, opts ...Option) *Server {
+	s := &Server{
+		ttl:       time.Minute,
+		ui:        true,
+		snapshots: make(map[string]*dbinfo.DBInfo),
+		mux:       http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cache = cache.New(load, s.ttl)
+
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	s.mux.Handle("GET /schema", s.authorized(s.handleSchema))
+	s.mux.Handle("GET /tables", s.authorized(s.handleTables))
+	s.mux.Handle("GET /tables/{schema}/{name}", s.authorized(s.handleTable))
+	s.mux.Handle("GET /diff", s.authorized(s.handleDiff))
+	s.mux.Handle("POST /diff", s.authorized(s.handleDiff))
+	if s.metrics {
+		s.mux.Handle("GET /metrics", s.authorized(s.handleMetrics))
+	}
+	if s.graphql {
+		s.mux.Handle("GET /graphql", s.authorized(s.handleGraphQL))
+		s.mux.Handle("POST /graphql", s.authorized(s.handleGraphQL))
+		s.mux.Handle("GET /graphql/schema", s.authorized(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, graphql.SDL())
+		}))
+	}
+	if s.ui {
+		// The UI only holds static files, the data it shows comes from the
+		// endpoints above and is subject to the token
+		static, _ := fs.Sub(ui, "ui")
+		s.mux.Handle("GET /", http.FileServerFS(static))
+	}
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized rejects requests without the configured token
+func (s *Server) authorized(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="dbinfo"`)
+				writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+				return
+			}
+		}
+		next(w, r)
+	})
+}
+
+// schema returns the cached schema, loading it when it is missing, expired
+// or a refresh is requested
+func (s *Server) schema(r *http.Request) (*dbinfo.DBInfo, error) {
+	info, err := s.cache.Get(r.Context(), r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		return nil, fmt.Errorf("getting database info: %w", err)
+	}
+	return info, nil
+}
+
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	info, err := s.schema(r)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// TableSummary describes a table in the /tables listing
+type TableSummary struct {
+	Schema  string `json:"schema"`
+	Name    string `json:"name"`
+	Comment string `json:"comment,omitempty"`
+}
+
+func (s *Server) handleTables(w http.ResponseWriter, r *http.Request) {
+	info, err := s.schema(r)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	tables := make([]TableSummary, 0, len(info.Tables))
+	for _, table := range info.Tables {
+		tables = append(tables, TableSummary{Schema: table.Schema, Name: table.Name, Comment: table.Comment})
+	}
+	writeJSON(w, http.StatusOK, tables)
+}
+
+func (s *Server) handleTable(w http.ResponseWriter, r *http.Request) {
+	info, err := s.schema(r)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	schema, name := r.PathValue("schema"), r.PathValue("name")
+	for _, table := range info.Tables {
+		if table.Schema == schema && table.Name == name {
+			writeJSON(w, http.StatusOK, table)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("table %s.%s not found", schema, name))
+}
+
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	var from *dbinfo.DBInfo
+	if r.Method == http.MethodPost {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSnapshotSize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("reading snapshot: %w", err))
+			return
+		}
+		if from, err = snapshot.Parse(data); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		name := r.URL.Query().Get("against")
+		if from = s.snapshots[name]; from == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("unknown snapshot %q, expected one of: %s", name, strings.Join(s.snapshotNames(), ", ")))
+			return
+		}
+	}
+
+	to, err := s.schema(r)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	changes := diff.Compare(from, to)
+	if changes == nil {
+		changes = []*diff.Change{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	info, err := s.schema(r)
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err != nil {
+		metrics.WriteDown(w)
+		return
+	}
+	metrics.Write(w, info, s.cache.LoadTime(), s.snapshots)
+}
+
+// handleGraphQL runs a query given in the URL, as query, operationName and
+// variables parameters, or posted as a JSON request
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	fail := func(status int, err error) {
+		writeJSON(w, status, &graphql.Response{Errors: []*graphql.Error{{Message: err.Error()}}})
+	}
+
+	var req graphql.Request
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLRequestSize)).Decode(&req); err != nil {
+			fail(http.StatusBadRequest, fmt.Errorf("reading GraphQL request: %w", err))
+			return
+		}
+	} else {
+		query := r.URL.Query()
+		req.Query, req.OperationName = query.Get("query"), query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				fail(http.StatusBadRequest, fmt.Errorf("reading variables: %w", err))
+				return
+			}
+		}
+	}
+	if req.Query == "" {
+		fail(http.StatusBadRequest, errors.New("missing GraphQL query"))
+		return
+	}
+
+	info, err := s.schema(r)
+	if err != nil {
+		fail(http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, graphql.Execute(info, &req))
+}
+
+// snapshotNames returns the sorted names of the configured snapshots
+func (s *Server) snapshotNames() []string {
+	names := make([]string, 0, len(s.snapshots))
+	for name := range s.snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
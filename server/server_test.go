@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:    "customers",
+				Schema:  "public",
+				Comment: "People who buy things",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}},
+			},
+			{
+				Name:    "invoices",
+				Schema:  "billing",
+				Columns: []*dbinfo.Column{{Name: "id", Type: "integer", IsPrimaryKey: true}},
+			},
+		},
+	}
+}
+
+// countingLoader returns testInfo and counts how many times it was called
+func countingLoader(calls *int) dbinfo.Loader {
+	return func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		*calls++
+		return testInfo(), nil
+	}
+}
+
+func request(t *testing.T, h http.Handler, method, target, body, token string, v any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("Failed to decode %s: %v", target, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestEndpoints(t *testing.T) {
+	var calls int
+	s := New(countingLoader(&calls))
+
+	var info dbinfo.DBInfo
+	if code := request(t, s, "GET", "/schema", "", "", &info); code != http.StatusOK || len(info.Tables) != 2 {
+		t.Fatalf("Unexpected /schema response %d: %+v", code, info)
+	}
+
+	var tables []TableSummary
+	request(t, s, "GET", "/tables", "", "", &tables)
+	expected := []TableSummary{
+		{Schema: "public", Name: "customers", Comment: "People who buy things"},
+		{Schema: "billing", Name: "invoices"},
+	}
+	if diff := cmp.Diff(expected, tables); diff != "" {
+		t.Errorf("Unexpected /tables (-expected +actual):\n%s", diff)
+	}
+
+	var table dbinfo.Table
+	if code := request(t, s, "GET", "/tables/billing/invoices", "", "", &table); code != http.StatusOK || table.Name != "invoices" {
+		t.Errorf("Unexpected table response %d: %+v", code, table)
+	}
+	if code := request(t, s, "GET", "/tables/billing/missing", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing table, got %d", code)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected the schema to be loaded once, got %d loads", calls)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var calls int
+	now := time.Now()
+	s := New(countingLoader(&calls), WithCacheTTL(time.Minute))
+	s.cache.Now = func() time.Time { return now }
+
+	request(t, s, "GET", "/schema", "", "", nil)
+	request(t, s, "GET", "/schema", "", "", nil)
+	if calls != 1 {
+		t.Errorf("Expected a cached schema, got %d loads", calls)
+	}
+
+	request(t, s, "GET", "/schema?refresh=true", "", "", nil)
+	if calls != 2 {
+		t.Errorf("Expected refresh to reload the schema, got %d loads", calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	request(t, s, "GET", "/tables", "", "", nil)
+	if calls != 3 {
+		t.Errorf("Expected an expired cache to reload the schema, got %d loads", calls)
+	}
+}
+
+func TestToken(t *testing.T) {
+	var calls int
+	s := New(countingLoader(&calls), WithToken("secret"))
+
+	if code := request(t, s, "GET", "/schema", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", code)
+	}
+	if code := request(t, s, "GET", "/schema", "", "wrong", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", code)
+	}
+	if code := request(t, s, "GET", "/schema", "", "secret", nil); code != http.StatusOK {
+		t.Errorf("Expected 200 with the token, got %d", code)
+	}
+	if code := request(t, s, "GET", "/healthz", "", "", nil); code != http.StatusOK {
+		t.Errorf("Expected /healthz not to require a token, got %d", code)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var calls int
+	old := testInfo()
+	old.Tables = old.Tables[:1]
+	old.Views = []*dbinfo.View{{Schema: "public", Name: "order_summary", Definition: "SELECT id FROM orders"}}
+	s := New(countingLoader(&calls), WithSnapshot("release", old))
+
+	var changes []*diff.Change
+	if code := request(t, s, "GET", "/diff?against=release", "", "", &changes); code != http.StatusOK {
+		t.Fatalf("Unexpected /diff status %d", code)
+	}
+	expected := []*diff.Change{
+		{Type: diff.Added, Kind: diff.KindTable, Schema: "billing", Table: "invoices"},
+		{Type: diff.Removed, Kind: diff.KindView, Schema: "public", Table: "order_summary", Breaking: true},
+	}
+	if diff := cmp.Diff(expected, changes); diff != "" {
+		t.Errorf("Unexpected changes (-expected +actual):\n%s", diff)
+	}
+
+	if code := request(t, s, "GET", "/diff?against=missing", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown snapshot, got %d", code)
+	}
+
+	changes = nil
+	body := `{"name": "shop", "tables": []}`
+	if code := request(t, s, "POST", "/diff", body, "", &changes); code != http.StatusOK || len(changes) != 2 {
+		t.Errorf("Unexpected POST /diff response %d: %v", code, changes)
+	}
+}
+
+func TestUI(t *testing.T) {
+	var calls int
+	s := New(countingLoader(&calls), WithToken("secret"))
+
+	for _, path := range []string{"/", "/app.js", "/style.css"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("Expected %s to be served without a token, got %d", path, rec.Code)
+		}
+	}
+
+	s = New(countingLoader(&calls), WithUI(false))
+	if code := request(t, s, "GET", "/", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected no UI when disabled, got %d", code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	var calls int
+	if code := request(t, New(countingLoader(&calls)), "GET", "/metrics", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected /metrics to be disabled by default, got %d", code)
+	}
+
+	s := New(countingLoader(&calls), WithMetrics(true), WithToken("secret"), WithSnapshot("release", testInfo()))
+	if code := request(t, s, "GET", "/metrics", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected /metrics to require the token, got %d", code)
+	}
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	for _, expected := range []string{`dbinfo_tables{database="shop",schema="billing"} 1`, `dbinfo_drift_changes{database="shop",reference="release"} 0`} {
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("Expected %s in the metrics, got\n%s", expected, rec.Body.String())
+		}
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	var calls int
+	if code := request(t, New(countingLoader(&calls)), "GET", "/graphql?query={database{name}}", "", "", nil); code != http.StatusNotFound {
+		t.Errorf("Expected /graphql to be disabled by default, got %d", code)
+	}
+
+	s := New(countingLoader(&calls), WithGraphQL(true), WithToken("secret"))
+	if code := request(t, s, "POST", "/graphql", `{"query":"{ database { name } }"}`, "", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected /graphql to require the token, got %d", code)
+	}
+
+	var resp struct {
+		Data struct {
+			Tables []struct{ Name string }
+		}
+		Errors []struct{ Message string }
+	}
+	body := `{"query":"query ($schema: String) { tables(schema: $schema) { name } }","variables":{"schema":"billing"}}`
+	if code := request(t, s, "POST", "/graphql", body, "secret", &resp); code != http.StatusOK {
+		t.Fatalf("Unexpected status %d", code)
+	}
+	if len(resp.Errors) > 0 || len(resp.Data.Tables) != 1 || resp.Data.Tables[0].Name != "invoices" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	resp.Data.Tables = nil
+	target := "/graphql?query=" + url.QueryEscape("{ tables { name } }")
+	if code := request(t, s, "GET", target, "", "secret", &resp); code != http.StatusOK || len(resp.Data.Tables) != 2 {
+		t.Errorf("Unexpected response %d %+v", code, resp)
+	}
+
+	if code := request(t, s, "POST", "/graphql", `{"query": 1}`, "secret", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected invalid requests to fail, got %d", code)
+	}
+	if code := request(t, s, "GET", "/graphql/schema", "", "secret", nil); code != http.StatusOK {
+		t.Errorf("Expected the schema definition, got %d", code)
+	}
+}
@@ -0,0 +1,287 @@
+// dbinfo web UI: a searchable table list and a relationship diagram drawn
+// from the serve API. No dependencies, so it works offline.
+(function () {
+  "use strict";
+
+  const SVG = "http://www.w3.org/2000/svg";
+  const WIDTH = 220;
+  const HEADER = 24;
+  const LINE = 18;
+  const GAP = 60;
+
+  const state = { info: null, positions: new Map(), selected: null, view: { x: 20, y: 50, k: 1 } };
+
+  const $ = (id) => document.getElementById(id);
+  const key = (schema, name) => schema + "." + name;
+
+  // api fetches a path, asking for the bearer token when the server requires one
+  async function api(path) {
+    const headers = {};
+    const token = localStorage.getItem("dbinfo-token");
+    if (token) headers.Authorization = "Bearer " + token;
+    const res = await fetch(path, { headers });
+    if (res.status === 401) {
+      const entered = prompt("This server requires a token");
+      if (entered === null) throw new Error("missing token");
+      localStorage.setItem("dbinfo-token", entered);
+      return api(path);
+    }
+    const body = await res.json();
+    if (!res.ok) throw new Error(body.error || res.statusText);
+    return body;
+  }
+
+  async function load(refresh) {
+    $("status").textContent = "Loading…";
+    try {
+      state.info = await api("schema" + (refresh ? "?refresh=true" : ""));
+    } catch (err) {
+      $("status").textContent = err.message;
+      return;
+    }
+    $("status").textContent = state.info.tables.length + " tables";
+    $("database").textContent = state.info.name || "dbinfo";
+    fillSchemas();
+    render();
+    fit();
+  }
+
+  function fillSchemas() {
+    const select = $("schema");
+    const current = select.value;
+    const schemas = [...new Set(state.info.tables.map((t) => t.schema))].sort();
+    select.replaceChildren(new Option("All schemas", ""), ...schemas.map((s) => new Option(s, s)));
+    if (schemas.includes(current)) select.value = current;
+  }
+
+  // visibleTables returns the tables of the selected schema
+  function visibleTables() {
+    const schema = $("schema").value;
+    return state.info.tables.filter((t) => !schema || t.schema === schema);
+  }
+
+  // matches tells whether a table or one of its columns contains the search text
+  function matches(table) {
+    const query = $("search").value.trim().toLowerCase();
+    if (!query) return true;
+    if (key(table.schema, table.name).toLowerCase().includes(query)) return true;
+    return (table.columns || []).some((c) => c.name.toLowerCase().includes(query));
+  }
+
+  function render() {
+    const tables = visibleTables();
+    renderList(tables);
+    layout(tables);
+    renderDiagram(tables);
+  }
+
+  function renderList(tables) {
+    const items = tables.filter(matches).map((table) => {
+      const li = document.createElement("li");
+      li.textContent = table.name + " ";
+      const small = document.createElement("small");
+      small.textContent = table.schema;
+      li.append(small);
+      if (state.selected === key(table.schema, table.name)) li.className = "selected";
+      li.onclick = () => select(table, true);
+      return li;
+    });
+    $("tables").replaceChildren(...items);
+  }
+
+  function height(table) {
+    return HEADER + Math.max(1, (table.columns || []).length) * LINE + 6;
+  }
+
+  // layout places tables in columns, always filling the shortest one
+  function layout(tables) {
+    state.positions.clear();
+    const count = Math.max(1, Math.ceil(Math.sqrt(tables.length)));
+    const bottoms = new Array(count).fill(0);
+    for (const table of tables) {
+      const col = bottoms.indexOf(Math.min(...bottoms));
+      state.positions.set(key(table.schema, table.name), { x: col * (WIDTH + GAP), y: bottoms[col], h: height(table) });
+      bottoms[col] += height(table) + GAP / 2;
+    }
+  }
+
+  function el(name, attrs, text) {
+    const node = document.createElementNS(SVG, name);
+    for (const [k, v] of Object.entries(attrs || {})) node.setAttribute(k, v);
+    if (text !== undefined) node.textContent = text;
+    return node;
+  }
+
+  function renderDiagram(tables) {
+    const viewport = $("viewport");
+    const defs = el("defs");
+    const marker = el("marker", { id: "arrow", viewBox: "0 0 10 10", refX: 10, refY: 5, markerWidth: 8, markerHeight: 8, orient: "auto" });
+    marker.append(el("path", { d: "M0,0 L10,5 L0,10 z", fill: "#9fb3c8" }));
+    defs.append(marker);
+    const nodes = [defs];
+
+    for (const table of tables) {
+      const from = state.positions.get(key(table.schema, table.name));
+      for (const fk of table.foreignkeys || []) {
+        const target = key(fk.reftableschema, fk.reftablename);
+        const to = state.positions.get(target);
+        if (!to) continue;
+        const x1 = from.x + WIDTH, y1 = from.y + HEADER / 2;
+        const x2 = to.x, y2 = to.y + HEADER / 2;
+        const bend = Math.max(40, Math.abs(x2 - x1) / 2);
+        const edge = el("path", { class: "edge", d: `M${x1},${y1} C${x1 + bend},${y1} ${x2 - bend},${y2} ${x2},${y2}` });
+        edge.dataset.from = key(table.schema, table.name);
+        edge.dataset.to = target;
+        edge.append(el("title", {}, fk.name));
+        nodes.push(edge);
+      }
+    }
+
+    for (const table of tables) {
+      const k = key(table.schema, table.name);
+      const pos = state.positions.get(k);
+      const g = el("g", { class: "table", transform: `translate(${pos.x},${pos.y})` });
+      g.dataset.key = k;
+      g.append(el("rect", { width: WIDTH, height: pos.h, rx: 4 }));
+      g.append(el("rect", { class: "title", width: WIDTH, height: HEADER, rx: 4 }));
+      g.append(el("text", { class: "title-text", x: 8, y: 16 }, table.schema === "public" ? table.name : k));
+      (table.columns || []).forEach((column, i) => {
+        const y = HEADER + (i + 1) * LINE - 4;
+        g.append(el("text", { x: 8, y, class: column.isprimarykey ? "pk" : "" }, column.name));
+        g.append(el("text", { x: WIDTH - 8, y, "text-anchor": "end" }, column.type));
+      });
+      g.onclick = (event) => {
+        event.stopPropagation();
+        select(table, false);
+      };
+      nodes.push(g);
+    }
+
+    viewport.replaceChildren(...nodes);
+    highlight();
+    applyView();
+  }
+
+  // highlight marks the selected table and its relationships and dims the
+  // tables not matching the search
+  function highlight() {
+    const byKey = new Map(state.info.tables.map((t) => [key(t.schema, t.name), t]));
+    for (const g of document.querySelectorAll(".table")) {
+      g.classList.toggle("selected", g.dataset.key === state.selected);
+      g.classList.toggle("dimmed", !matches(byKey.get(g.dataset.key)));
+    }
+    for (const edge of document.querySelectorAll(".edge")) {
+      edge.classList.toggle("highlighted", edge.dataset.from === state.selected || edge.dataset.to === state.selected);
+    }
+  }
+
+  function select(table, center) {
+    state.selected = key(table.schema, table.name);
+    renderList(visibleTables());
+    highlight();
+    showDetails(table);
+    const pos = state.positions.get(state.selected);
+    if (center && pos) {
+      const box = $("diagram").getBoundingClientRect();
+      state.view.x = box.width / 2 - (pos.x + WIDTH / 2) * state.view.k;
+      state.view.y = box.height / 2 - (pos.y + pos.h / 2) * state.view.k;
+      applyView();
+    }
+  }
+
+  function rows(headers, values) {
+    const table = document.createElement("table");
+    const head = table.insertRow();
+    for (const h of headers) head.append(Object.assign(document.createElement("th"), { textContent: h }));
+    for (const value of values) {
+      const row = table.insertRow();
+      for (const cell of value) row.insertCell().textContent = cell;
+    }
+    return table;
+  }
+
+  function showDetails(table) {
+    const details = $("details");
+    const title = document.createElement("h2");
+    title.textContent = key(table.schema, table.name);
+    const close = Object.assign(document.createElement("button"), { textContent: "Close" });
+    close.onclick = () => { details.hidden = true; };
+    const parts = [close, title];
+    if (table.comment) parts.push(Object.assign(document.createElement("p"), { textContent: table.comment }));
+    parts.push(rows(["Column", "Type", "Null", "Default"], (table.columns || []).map((c) =>
+      [(c.isprimarykey ? "🔑 " : "") + c.name, c.fulltype || c.type, c.isnullable ? "yes" : "no", c.defaultvalue])));
+    if ((table.indexes || []).length) {
+      parts.push(rows(["Index", "Columns"], table.indexes.map((i) =>
+        [i.name + (i.unique ? (i.nullsnotdistinct ? " (unique, nulls not distinct)" : " (unique)") : ""), i.keys ? i.keys.map((k) => k.column || k.expression).join(", ") : i.expression || i.columns.join(", ")])));
+    }
+    if ((table.foreignkeys || []).length) {
+      parts.push(rows(["Foreign key", "References"], table.foreignkeys.map((fk) =>
+        [fk.columnnames.join(", "), key(fk.reftableschema, fk.reftablename) + " (" + fk.refcolumnnames.join(", ") + ")"])));
+    }
+    details.replaceChildren(...parts);
+    details.hidden = false;
+  }
+
+  function applyView() {
+    const { x, y, k } = state.view;
+    $("viewport").setAttribute("transform", `translate(${x},${y}) scale(${k})`);
+  }
+
+  // fit zooms the diagram so every visible table is on screen
+  function fit() {
+    const box = $("diagram").getBoundingClientRect();
+    let maxX = 0, maxY = 0;
+    for (const pos of state.positions.values()) {
+      maxX = Math.max(maxX, pos.x + WIDTH);
+      maxY = Math.max(maxY, pos.y + pos.h);
+    }
+    if (!maxX || !maxY) return;
+    const k = Math.min(1.5, (box.width - 40) / maxX, (box.height - 70) / maxY);
+    state.view = { x: 20, y: 50, k };
+    applyView();
+  }
+
+  function setupPanZoom() {
+    const svg = $("diagram");
+    let drag = null;
+    svg.addEventListener("mousedown", (event) => {
+      drag = { x: event.clientX - state.view.x, y: event.clientY - state.view.y };
+      svg.classList.add("panning");
+    });
+    window.addEventListener("mousemove", (event) => {
+      if (!drag) return;
+      state.view.x = event.clientX - drag.x;
+      state.view.y = event.clientY - drag.y;
+      applyView();
+    });
+    window.addEventListener("mouseup", () => {
+      drag = null;
+      svg.classList.remove("panning");
+    });
+    svg.addEventListener("wheel", (event) => {
+      event.preventDefault();
+      const box = svg.getBoundingClientRect();
+      const px = event.clientX - box.left, py = event.clientY - box.top;
+      const k = Math.min(4, Math.max(0.1, state.view.k * Math.exp(-event.deltaY / 500)));
+      // Keep the point under the cursor in place
+      state.view.x = px - ((px - state.view.x) * k) / state.view.k;
+      state.view.y = py - ((py - state.view.y) * k) / state.view.k;
+      state.view.k = k;
+      applyView();
+    }, { passive: false });
+  }
+
+  $("search").addEventListener("input", () => {
+    renderList(visibleTables());
+    highlight();
+  });
+  $("schema").addEventListener("change", () => {
+    render();
+    fit();
+  });
+  $("fit").onclick = fit;
+  $("refresh").onclick = () => load(true);
+
+  setupPanZoom();
+  load(false);
+})();
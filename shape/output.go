@@ -0,0 +1,93 @@
+package shape
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// summary returns the sizes of the schema in one line
+func (r *Report) summary() string {
+	return fmt.Sprintf("%d tables, %d columns, %d foreign keys", r.Tables, r.Columns, r.ForeignKeys)
+}
+
+// path returns the tables of the chain joined by arrows
+func (c *Chain) path() string {
+	return strings.Join(c.Tables, " → ")
+}
+
+// WriteText writes the report to w as aligned sections
+func WriteText(w io.Writer, r *Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\n", r.summary())
+
+	fmt.Fprintf(tw, "\nTYPE\tCOLUMNS\tSHARE\tTABLES\n")
+	for _, usage := range r.Types {
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%d\n", usage.Type, usage.Columns, usage.Share*100, usage.Tables)
+	}
+	fmt.Fprintf(tw, "\nWIDEST TABLE\tCOLUMNS\n")
+	for _, width := range r.Widest {
+		fmt.Fprintf(tw, "%s\t%d\n", width.Table, width.Columns)
+	}
+	fmt.Fprintf(tw, "\nMOST REFERENCED TABLE\tFOREIGN KEYS\tTABLES\n")
+	for _, ref := range r.MostReferenced {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", ref.Table, ref.ForeignKeys, ref.Tables)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Chains are too long to align with the other sections
+	fmt.Fprintf(w, "\nDEEPEST FOREIGN KEY CHAINS\n")
+	for _, chain := range r.Chains {
+		fmt.Fprintf(w, "%d  %s\n", chain.Depth(), chain.path())
+	}
+	return nil
+}
+
+// WriteJSON writes the report to w as JSON
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the report to w as a Markdown document about the
+// database name
+func WriteMarkdown(w io.Writer, name string, r *Report) error {
+	fmt.Fprintf(w, "# Shape of %s\n\n", name)
+	fmt.Fprintf(w, "%s.\n", r.summary())
+
+	fmt.Fprintf(w, "\n## Column types\n\n")
+	fmt.Fprintln(w, "| Type | Columns | Share | Tables |")
+	fmt.Fprintln(w, "|---|---:|---:|---:|")
+	for _, usage := range r.Types {
+		fmt.Fprintf(w, "| %s | %d | %.0f%% | %d |\n", usage.Type, usage.Columns, usage.Share*100, usage.Tables)
+	}
+
+	fmt.Fprintf(w, "\n## Widest tables\n\n")
+	fmt.Fprintln(w, "| Table | Columns |")
+	fmt.Fprintln(w, "|---|---:|")
+	for _, width := range r.Widest {
+		fmt.Fprintf(w, "| %s | %d |\n", width.Table, width.Columns)
+	}
+
+	fmt.Fprintf(w, "\n## Deepest foreign key chains\n\n")
+	if len(r.Chains) == 0 {
+		fmt.Fprintln(w, "No table references another.")
+	}
+	for _, chain := range r.Chains {
+		fmt.Fprintf(w, "- %d: %s\n", chain.Depth(), chain.path())
+	}
+
+	fmt.Fprintf(w, "\n## Most referenced tables\n\n")
+	fmt.Fprintln(w, "| Table | Foreign keys | Tables |")
+	fmt.Fprintln(w, "|---|---:|---:|")
+	for _, ref := range r.MostReferenced {
+		fmt.Fprintf(w, "| %s | %d | %d |\n", ref.Table, ref.ForeignKeys, ref.Tables)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
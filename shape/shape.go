@@ -0,0 +1,199 @@
+// Package shape summarizes the structure of a schema: which column types it
+// uses, its widest tables, its longest chains of foreign keys and the tables
+// most referenced by others. It gives a quick overview of a database one has
+// just inherited.
+package shape
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/internal/chains"
+)
+
+// DefaultTop is the number of tables listed in each ranking unless Options
+// sets another
+const DefaultTop = 10
+
+// typeModifiers matches the modifiers of a type, such as (255) or (10,2)
+var typeModifiers = regexp.MustCompile(`\([^)]*\)`)
+
+// TypeUsage is how many columns have a type
+type TypeUsage struct {
+	Type    string  `json:"type"` // Without modifiers: numeric, not numeric(10,2)
+	Columns int     `json:"columns"`
+	Share   float64 `json:"share"` // Of all the columns, from 0 to 1
+	Tables  int     `json:"tables"`
+}
+
+// TableWidth is the number of columns of a table
+type TableWidth struct {
+	Table   string `json:"table"`
+	Columns int    `json:"columns"`
+}
+
+// Chain is a path of foreign keys, each table referencing the next
+type Chain struct {
+	Tables []string `json:"tables"`
+}
+
+// Depth returns the number of foreign keys of the chain
+func (c *Chain) Depth() int {
+	return len(c.Tables) - 1
+}
+
+// Referenced is a table other tables reference
+type Referenced struct {
+	Table       string `json:"table"`
+	ForeignKeys int    `json:"foreignkeys"` // Foreign keys referencing the table, self references excluded
+	Tables      int    `json:"tables"`      // Tables holding them
+}
+
+// Report is the shape of a schema
+type Report struct {
+	Tables         int           `json:"tables"`
+	Columns        int           `json:"columns"`
+	ForeignKeys    int           `json:"foreignkeys"`
+	Types          []*TypeUsage  `json:"types"`          // Every type, the most used first
+	Widest         []*TableWidth `json:"widest"`         // The widest tables first
+	Chains         []*Chain      `json:"chains"`         // The deepest chains first
+	MostReferenced []*Referenced `json:"mostreferenced"` // The most referenced tables first
+}
+
+// Options sets the length of the rankings of a report
+type Options struct {
+	Top int // Tables listed in each ranking, DefaultTop when 0
+}
+
+// Analyze returns the shape of info
+func Analyze(info *dbinfo.DBInfo, opts Options) *Report {
+	top := cmp.Or(opts.Top, DefaultTop)
+	r := &Report{Tables: len(info.Tables), Types: []*TypeUsage{}, Widest: []*TableWidth{}}
+
+	types := map[string]*TypeUsage{}
+	for _, table := range info.Tables {
+		r.Columns += len(table.Columns)
+		r.ForeignKeys += len(table.ForeignKeys)
+		r.Widest = append(r.Widest, &TableWidth{Table: tableName(table.Schema, table.Name), Columns: len(table.Columns)})
+
+		seen := map[string]bool{}
+		for _, column := range table.Columns {
+			name := baseType(column)
+			usage := types[name]
+			if usage == nil {
+				usage = &TypeUsage{Type: name}
+				types[name] = usage
+				r.Types = append(r.Types, usage)
+			}
+			usage.Columns++
+			if !seen[name] {
+				seen[name] = true
+				usage.Tables++
+			}
+		}
+	}
+	for _, usage := range r.Types {
+		usage.Share = float64(usage.Columns) / float64(r.Columns)
+	}
+	slices.SortFunc(r.Types, func(a, b *TypeUsage) int {
+		return cmp.Or(cmp.Compare(b.Columns, a.Columns), cmp.Compare(a.Type, b.Type))
+	})
+	slices.SortStableFunc(r.Widest, func(a, b *TableWidth) int {
+		return cmp.Compare(b.Columns, a.Columns)
+	})
+	r.Widest = r.Widest[:min(top, len(r.Widest))]
+
+	r.MostReferenced = mostReferenced(info)
+	r.MostReferenced = r.MostReferenced[:min(top, len(r.MostReferenced))]
+	r.Chains = deepestChains(info)
+	r.Chains = r.Chains[:min(top, len(r.Chains))]
+	return r
+}
+
+// baseType returns the type of a column without its modifiers, from the full
+// type when the snapshot has it
+func baseType(column *dbinfo.Column) string {
+	if column.FullType == "" {
+		return column.Type
+	}
+	return typeModifiers.ReplaceAllString(column.FullType, "")
+}
+
+// tableName returns the schema qualified name of a table
+func tableName(schema, name string) string {
+	return dbinfo.QualifiedName(schema, name)
+}
+
+// mostReferenced returns the tables other tables reference, the most
+// referenced first
+func mostReferenced(info *dbinfo.DBInfo) []*Referenced {
+	referenced := []*Referenced{}
+	byName := map[string]*Referenced{}
+	holders := map[string]map[string]bool{}
+	for _, table := range info.Tables {
+		from := tableName(table.Schema, table.Name)
+		for _, fk := range table.ForeignKeys {
+			to := tableName(fk.RefTableSchema, fk.RefTableName)
+			if to == from {
+				continue
+			}
+			ref := byName[to]
+			if ref == nil {
+				ref = &Referenced{Table: to}
+				byName[to] = ref
+				holders[to] = map[string]bool{}
+				referenced = append(referenced, ref)
+			}
+			ref.ForeignKeys++
+			if !holders[to][from] {
+				holders[to][from] = true
+				ref.Tables++
+			}
+		}
+	}
+	slices.SortFunc(referenced, func(a, b *Referenced) int {
+		return cmp.Or(cmp.Compare(b.ForeignKeys, a.ForeignKeys), cmp.Compare(b.Tables, a.Tables), cmp.Compare(a.Table, b.Table))
+	})
+	return referenced
+}
+
+// deepestChains returns the longest chain of foreign keys starting at each
+// table no other table references, the deepest first. Chains end at a table
+// referencing nothing, or before closing a cycle.
+func deepestChains(info *dbinfo.DBInfo) []*Chain {
+	references := map[string][]string{}
+	referenced := map[string]bool{}
+	var names []string
+	for _, table := range info.Tables {
+		from := tableName(table.Schema, table.Name)
+		names = append(names, from)
+		for _, fk := range table.ForeignKeys {
+			to := tableName(fk.RefTableSchema, fk.RefTableName)
+			if to != from && !slices.Contains(references[from], to) {
+				references[from] = append(references[from], to)
+				referenced[to] = true
+			}
+		}
+	}
+	for _, to := range references {
+		slices.Sort(to)
+	}
+	slices.Sort(names)
+
+	longest := chains.New(references)
+	deepest := []*Chain{}
+	for _, name := range names {
+		if referenced[name] {
+			continue
+		}
+		if path := longest.From(name); len(path) > 1 {
+			deepest = append(deepest, &Chain{Tables: path})
+		}
+	}
+	slices.SortStableFunc(deepest, func(a, b *Chain) int {
+		return cmp.Compare(b.Depth(), a.Depth())
+	})
+	return deepest
+}
@@ -0,0 +1,124 @@
+package shape
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	fk := func(schema, table string) *dbinfo.ForeignKey {
+		return &dbinfo.ForeignKey{Name: table + "_fkey", ColumnNames: []string{table + "_id"}, RefTableSchema: schema, RefTableName: table, RefColumnNames: []string{"id"}}
+	}
+	id := &dbinfo.Column{Name: "id", Type: "integer", FullType: "integer"}
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{Schema: "public", Name: "customers", Columns: []*dbinfo.Column{id,
+				{Name: "email", Type: "character varying", FullType: "character varying(255)"},
+				{Name: "name", Type: "character varying", FullType: "character varying(100)"},
+				{Name: "referrer_id", Type: "integer", FullType: "integer"},
+			}, ForeignKeys: []*dbinfo.ForeignKey{fk("public", "customers")}},
+			{Schema: "public", Name: "orders", Columns: []*dbinfo.Column{id,
+				{Name: "customers_id", Type: "integer", FullType: "integer"},
+				{Name: "total", Type: "numeric", FullType: "numeric(10,2)"},
+			}, ForeignKeys: []*dbinfo.ForeignKey{fk("public", "customers")}},
+			{Schema: "billing", Name: "invoices", Columns: []*dbinfo.Column{id,
+				{Name: "orders_id", Type: "integer", FullType: "integer"},
+				{Name: "issued", Type: "timestamp without time zone"},
+			}, ForeignKeys: []*dbinfo.ForeignKey{fk("public", "orders")}},
+			{Schema: "public", Name: "refunds", Columns: []*dbinfo.Column{id,
+				{Name: "orders_id", Type: "integer", FullType: "integer"},
+				{Name: "customers_id", Type: "integer", FullType: "integer"},
+			}, ForeignKeys: []*dbinfo.ForeignKey{fk("public", "orders"), fk("public", "customers")}},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	r := Analyze(testInfo(), Options{Top: 2})
+
+	want := &Report{
+		Tables:      4,
+		Columns:     13,
+		ForeignKeys: 5,
+		Types: []*TypeUsage{
+			{Type: "integer", Columns: 9, Share: 9.0 / 13, Tables: 4},
+			{Type: "character varying", Columns: 2, Share: 2.0 / 13, Tables: 1},
+			{Type: "numeric", Columns: 1, Share: 1.0 / 13, Tables: 1},
+			{Type: "timestamp without time zone", Columns: 1, Share: 1.0 / 13, Tables: 1},
+		},
+		Widest: []*TableWidth{{Table: "public.customers", Columns: 4}, {Table: "public.orders", Columns: 3}},
+		Chains: []*Chain{
+			{Tables: []string{"billing.invoices", "public.orders", "public.customers"}},
+			{Tables: []string{"public.refunds", "public.orders", "public.customers"}},
+		},
+		MostReferenced: []*Referenced{
+			{Table: "public.customers", ForeignKeys: 2, Tables: 2},
+			{Table: "public.orders", ForeignKeys: 2, Tables: 2},
+		},
+	}
+	if diff := cmp.Diff(want, r); diff != "" {
+		t.Errorf("report (-want +got):\n%s", diff)
+	}
+
+	// Cycles end the chains before closing
+	info := testInfo()
+	info.Tables[0].ForeignKeys = append(info.Tables[0].ForeignKeys, &dbinfo.ForeignKey{Name: "last_order_fkey", RefTableSchema: "billing", RefTableName: "invoices"})
+	info.Tables = append(info.Tables, &dbinfo.Table{Schema: "public", Name: "notes", ForeignKeys: []*dbinfo.ForeignKey{{RefTableSchema: "public", RefTableName: "customers"}}})
+	var got []string
+	for _, chain := range Analyze(info, Options{}).Chains {
+		got = append(got, chain.path())
+	}
+	wantChains := []string{
+		"public.notes → public.customers → billing.invoices → public.orders",
+		"public.refunds → public.customers → billing.invoices → public.orders",
+	}
+	if diff := cmp.Diff(wantChains, got); diff != "" {
+		t.Errorf("chains with a cycle (-want +got):\n%s", diff)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := Analyze(testInfo(), Options{})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"4 tables, 13 columns, 5 foreign keys\n",
+		"TYPE                         COLUMNS  SHARE  TABLES\ninteger                      9        69%    4\n",
+		"MOST REFERENCED TABLE  FOREIGN KEYS  TABLES\npublic.customers       2             2\n",
+		"DEEPEST FOREIGN KEY CHAINS\n2  billing.invoices → public.orders → public.customers\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the text output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, "shop", r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Shape of shop\n\n4 tables, 13 columns, 5 foreign keys.\n",
+		"| numeric | 1 | 8% | 1 |\n",
+		"- 2: public.refunds → public.orders → public.customers\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the Markdown output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteJSON(&buf, Analyze(&dbinfo.DBInfo{}, Options{})); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"chains": []`) {
+		t.Errorf("Expected an empty chains array, got %s", buf.String())
+	}
+}
@@ -0,0 +1,213 @@
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// IndexFile is the name of the index of a snapshot directory
+const IndexFile = "dbinfo.yaml"
+
+// dirIndex is the index of a snapshot directory: the details of the
+// database, the files of its tables, in order, and the other objects
+type dirIndex struct {
+	Name              string                     `yaml:"name"`
+	Generator         string                     `yaml:"generator,omitempty"`
+	Encoding          string                     `yaml:"encoding,omitempty"`
+	Collation         string                     `yaml:"collation,omitempty"`
+	CType             string                     `yaml:"ctype,omitempty"`
+	Tables            []string                   `yaml:"tables"`
+	Views             []*dbinfo.View             `yaml:"views,omitempty"`
+	MaterializedViews []*dbinfo.MaterializedView `yaml:"materializedviews,omitempty"`
+	Sequences         []*dbinfo.Sequence         `yaml:"sequences,omitempty"`
+	Domains           []*dbinfo.Domain           `yaml:"domains,omitempty"`
+	ForeignTables     []*dbinfo.ForeignTable     `yaml:"foreigntables,omitempty"`
+	EventTriggers     []*dbinfo.EventTrigger     `yaml:"eventtriggers,omitempty"`
+	Warnings          []*dbinfo.Warning          `yaml:"warnings,omitempty"`
+	Capabilities      *dbinfo.Capabilities       `yaml:"capabilities,omitempty"`
+}
+
+// WriteDir writes info as a snapshot directory: an index file listing the
+// tables and holding the other objects, such as views and sequences, and a
+// YAML file per table named <schema>/<table>.yaml. A schema change only
+// touches the files of the tables involved, so it shows as a
+// small diff when the directory is kept in git. Table files of a previous
+// snapshot in dir that are no longer used are removed.
+func WriteDir(dir string, info *dbinfo.DBInfo) error {
+	previous, err := readIndex(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	index := dirIndex{
+		Name:              info.Name,
+		Generator:         info.Generator,
+		Encoding:          info.Encoding,
+		Collation:         info.Collation,
+		CType:             info.CType,
+		Tables:            make([]string, 0, len(info.Tables)),
+		Views:             cloneAll(info.Views, (*dbinfo.View).Clone),
+		MaterializedViews: cloneAll(info.MaterializedViews, (*dbinfo.MaterializedView).Clone),
+		Sequences:         info.Sequences,
+		Domains:           info.Domains,
+		ForeignTables:     info.ForeignTables,
+		EventTriggers:     info.EventTriggers,
+		Warnings:          info.Warnings,
+		Capabilities:      info.Capabilities,
+	}
+	taken := make(map[string]bool, len(info.Tables))
+	for _, table := range info.Tables {
+		// Names differing only in case get a suffix, since they would
+		// share a file on case-insensitive file systems
+		base := path.Join(fileName(table.Schema), fileName(table.Name))
+		name := base
+		for i := 2; taken[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken[strings.ToLower(name)] = true
+		name += ".yaml"
+		index.Tables = append(index.Tables, name)
+
+		if err := writeYAMLFile(filepath.Join(dir, filepath.FromSlash(name)), table.Clone()); err != nil {
+			return err
+		}
+	}
+	if err := writeYAMLFile(filepath.Join(dir, IndexFile), &index); err != nil {
+		return err
+	}
+
+	if previous != nil {
+		written := make(map[string]bool, len(index.Tables))
+		for _, name := range index.Tables {
+			written[name] = true
+		}
+		for _, name := range previous.Tables {
+			if written[name] || !filepath.IsLocal(filepath.FromSlash(name)) {
+				continue
+			}
+			file := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", name, err)
+			}
+			// Schemas without tables left lose their directory
+			os.Remove(filepath.Dir(file))
+		}
+	}
+	return nil
+}
+
+// LoadDir reads a snapshot directory written by WriteDir
+func LoadDir(dir string) (*dbinfo.DBInfo, error) {
+	return LoadDirFS(os.DirFS(dir), ".")
+}
+
+// LoadDirFS reads a snapshot directory written by WriteDir from fsys, such
+// as an embed.FS holding the snapshot of the schema an application expects
+func LoadDirFS(fsys fs.FS, dir string) (*dbinfo.DBInfo, error) {
+	index, err := readIndexFS(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &dbinfo.DBInfo{
+		Name:              index.Name,
+		Generator:         index.Generator,
+		Encoding:          index.Encoding,
+		Collation:         index.Collation,
+		CType:             index.CType,
+		Tables:            make([]*dbinfo.Table, 0, len(index.Tables)),
+		Views:             index.Views,
+		MaterializedViews: index.MaterializedViews,
+		Sequences:         index.Sequences,
+		Domains:           index.Domains,
+		ForeignTables:     index.ForeignTables,
+		EventTriggers:     index.EventTriggers,
+		Warnings:          index.Warnings,
+		Capabilities:      index.Capabilities,
+	}
+	for _, name := range index.Tables {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("failed to read snapshot: table file %q is outside the snapshot directory", name)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		table := &dbinfo.Table{}
+		if err := yaml.Unmarshal(data, table); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		info.Tables = append(info.Tables, table)
+	}
+	info.EnsureSlices()
+	return info, nil
+}
+
+// cloneAll copies the views of a model, which writeYAMLFile modifies
+func cloneAll[T any](values []*T, clone func(*T) *T) []*T {
+	var clones []*T
+	for _, value := range values {
+		clones = append(clones, clone(value))
+	}
+	return clones
+}
+
+// readIndex reads the index of the snapshot directory dir
+func readIndex(dir string) (*dirIndex, error) {
+	return readIndexFS(os.DirFS(dir), ".")
+}
+
+// readIndexFS reads the index of the snapshot directory dir in fsys
+func readIndexFS(fsys fs.FS, dir string) (*dirIndex, error) {
+	data, err := fs.ReadFile(fsys, path.Join(dir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index: %w", err)
+	}
+	index := &dirIndex{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", IndexFile, err)
+	}
+	return index, nil
+}
+
+// writeYAMLFile writes v, which it modifies, to a YAML file, creating its
+// directory if needed
+func writeYAMLFile(file string, v any) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(4)
+	if err := encodeYAML(enc, v); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// fileName escapes a schema or table name for use as a file name: path
+// separators, percent signs and other unsafe characters are percent
+// encoded, as is a leading dot so names can't be . or .. or hidden
+func fileName(name string) string {
+	name = url.PathEscape(name)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return name
+}
@@ -0,0 +1,120 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+// dirFiles lists the files in dir, relative to it
+func dirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestDirRoundTrip(t *testing.T) {
+	info := testInfo()
+	info.Generator = "dbinfo v1.2.0"
+	info.Encoding, info.Collation, info.CType = "UTF8", "en_US.UTF-8", "en_US.UTF-8"
+	info.Tables = append(info.Tables,
+		&dbinfo.Table{Schema: "billing", Name: "invoices/2024", Comment: "\n  Indented"},
+		&dbinfo.Table{Schema: "..", Name: "Orders"},
+		&dbinfo.Table{Schema: "..", Name: "orders"},
+	)
+	info.Views = []*dbinfo.View{{
+		Schema:     "public",
+		Name:       "recent_orders",
+		Definition: "SELECT orders.id\n   FROM orders\n  WHERE orders.created_at > (now() - '7 days'::interval);",
+		Columns:    []*dbinfo.Column{{Name: "id", Type: "integer", FullType: "integer", IsNullable: true}},
+		Updatable:  true,
+		Sources:    []*dbinfo.ViewSource{{Schema: "public", Name: "orders", Columns: []string{"created_at", "id"}}},
+	}}
+	info.MaterializedViews = []*dbinfo.MaterializedView{{
+		Schema:     "reports",
+		Name:       "daily_orders",
+		Definition: "SELECT count(*) AS orders\n   FROM orders;",
+		Columns:    []*dbinfo.Column{{Name: "orders", Type: "bigint", FullType: "bigint", IsNullable: true}},
+		Indexes:    []*dbinfo.Index{{Name: "daily_orders_idx", Expression: "(orders > 0)", Keys: []*dbinfo.IndexKey{{Expression: "(orders > 0)"}}}},
+		Populated:  true,
+	}}
+	lastValue := int64(42)
+	info.Sequences = []*dbinfo.Sequence{{
+		Schema: "public", Name: "orders_id_seq", Type: "integer", Start: 1, Increment: 1, Min: 1, Max: 2147483647, Cache: 1,
+		LastValue: &lastValue, OwnedBy: &dbinfo.SequenceOwner{Schema: "public", Table: "orders", Column: "id"},
+	}}
+	info.Warnings = []*dbinfo.Warning{{Phase: dbinfo.PhaseColumns, Object: "public.secrets", Message: "permission denied"}}
+
+	dir := t.TempDir()
+	if err := WriteDir(dir, info); err != nil {
+		t.Fatal(err)
+	}
+	expectedFiles := []string{"%2E./Orders.yaml", "%2E./orders_2.yaml", "billing/invoices%2F2024.yaml", "dbinfo.yaml", "public/orders.yaml"}
+	if diff := cmp.Diff(expectedFiles, dirFiles(t, dir)); diff != "" {
+		t.Errorf("Unexpected files (-expected +actual):\n%s", diff)
+	}
+
+	loaded, err := LoadFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info.EnsureSlices()
+	if diff := cmp.Diff(info, loaded); diff != "" {
+		t.Errorf("Round trip mismatch (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestWriteDirRemovesTables(t *testing.T) {
+	dir := t.TempDir()
+	info := testInfo()
+	info.Tables = append(info.Tables, &dbinfo.Table{Schema: "audit", Name: "events"})
+	if err := WriteDir(dir, info); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("Schema of the shop"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dropping a table removes its file and its empty schema directory,
+	// and leaves the files dbinfo didn't write alone
+	info.Tables = info.Tables[:1]
+	if err := WriteDir(dir, info); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"README.md", "dbinfo.yaml", "public/orders.yaml"}, dirFiles(t, dir)); diff != "" {
+		t.Errorf("Unexpected files (-expected +actual):\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit")); !os.IsNotExist(err) {
+		t.Errorf("Expected the audit directory to be removed, got %v", err)
+	}
+}
+
+func TestLoadDirErrors(t *testing.T) {
+	if _, err := LoadDir(t.TempDir()); err == nil || !strings.Contains(err.Error(), "index") {
+		t.Errorf("Expected a missing index error, got %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, IndexFile), []byte("name: shop\ntables:\n    - ../secrets.yaml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDir(dir); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Expected files outside the directory to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package snapshot
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// KeyEnv holds the secret key of embedded signatures
+const KeyEnv = "DBINFO_SNAPSHOT_KEY"
+
+// HMACAlgorithm is the algorithm of embedded signatures
+const HMACAlgorithm = "hmac-sha256"
+
+// Errors returned when verifying a snapshot
+var (
+	ErrUnsigned         = errors.New("the snapshot is not signed")
+	ErrInvalidSignature = errors.New("the signature doesn't match the snapshot")
+)
+
+// Canonical returns the canonical form of info that signatures cover: its
+// compact JSON encoding, without its embedded signature and with empty
+// lists rather than nil ones. A model and the YAML or JSON snapshot it was
+// written to have the same canonical form.
+func Canonical(info *dbinfo.DBInfo) ([]byte, error) {
+	clone := info.Clone()
+	clone.Signature = nil
+	clone.EnsureSlices()
+	data, err := json.Marshal(clone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// hmacSum returns the hex HMAC-SHA256 of the canonical form of info
+func hmacSum(info *dbinfo.DBInfo, key []byte) (string, error) {
+	data, err := Canonical(info)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SignHMAC embeds in info the HMAC of its canonical form keyed with key,
+// replacing any previous signature. Changing info afterwards invalidates it.
+func SignHMAC(info *dbinfo.DBInfo, key []byte) error {
+	if len(key) == 0 {
+		return errors.New("the signing key is empty")
+	}
+	sum, err := hmacSum(info, key)
+	if err != nil {
+		return err
+	}
+	info.Signature = &dbinfo.Signature{Algorithm: HMACAlgorithm, Value: sum}
+	return nil
+}
+
+// VerifyHMAC checks the signature embedded in info with key. It returns
+// ErrUnsigned when info has no signature and ErrInvalidSignature when the
+// signature doesn't match.
+func VerifyHMAC(info *dbinfo.DBInfo, key []byte) error {
+	if info.Signature == nil {
+		return ErrUnsigned
+	}
+	if info.Signature.Algorithm != HMACAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm %q", info.Signature.Algorithm)
+	}
+	sum, err := hmacSum(info, key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sum), []byte(strings.ToLower(info.Signature.Value))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignDetached returns the Ed25519 signature of the canonical form of info,
+// base64 encoded on a line, to be written next to the snapshot. The
+// embedded signature of info, if any, isn't covered.
+func SignDetached(info *dbinfo.DBInfo, key ed25519.PrivateKey) ([]byte, error) {
+	data, err := Canonical(info)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n"), nil
+}
+
+// VerifyDetached checks signature, as written by SignDetached, against info
+// with key. It returns ErrInvalidSignature when the signature doesn't match.
+func VerifyDetached(info *dbinfo.DBInfo, key ed25519.PublicKey, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	data, err := Canonical(info)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// readPEM reads the PEM block of type blockType in the file at path
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s: expected a PEM %s block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+// LoadPrivateKey reads an Ed25519 private key from a PKCS #8 PEM file, as
+// written by openssl genpkey -algorithm ed25519
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads an Ed25519 public key from a PKIX PEM file, as
+// written by openssl pkey -pubout
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return public, nil
+}
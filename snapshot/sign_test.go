@@ -0,0 +1,122 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func TestSignHMAC(t *testing.T) {
+	key := []byte("s3cret")
+	info := testInfo()
+	// A multi-line comment starting with white space is written quoted
+	info.Tables[0].Columns[1].Comment = "\n  Who placed the order"
+	if err := SignHMAC(info, key); err != nil {
+		t.Fatal(err)
+	}
+	if info.Signature.Algorithm != HMACAlgorithm || len(info.Signature.Value) != 64 {
+		t.Fatalf("Unexpected signature %+v", info.Signature)
+	}
+
+	writers := map[string]func(*bytes.Buffer, *dbinfo.DBInfo) error{
+		"yaml": func(b *bytes.Buffer, info *dbinfo.DBInfo) error { return WriteYAML(b, info) },
+		"json": func(b *bytes.Buffer, info *dbinfo.DBInfo) error { return WriteJSON(b, info) },
+	}
+	for name, write := range writers {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(&buf, info); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Parse(buf.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyHMAC(loaded, key); err != nil {
+				t.Errorf("Expected the signature to verify, got %v", err)
+			}
+			if err := VerifyHMAC(loaded, []byte("other")); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Expected an invalid signature with another key, got %v", err)
+			}
+			loaded.Tables[0].Columns[1].IsNullable = true
+			if err := VerifyHMAC(loaded, key); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Expected an invalid signature after tampering, got %v", err)
+			}
+		})
+	}
+
+	if err := VerifyHMAC(testInfo(), key); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("Expected ErrUnsigned, got %v", err)
+	}
+	if err := SignHMAC(testInfo(), nil); err == nil {
+		t.Error("Expected an error for an empty key")
+	}
+}
+
+func TestSignDetached(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeKey := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePath := writeKey("key.pem", "PRIVATE KEY", privateDER)
+	publicPath := writeKey("pub.pem", "PUBLIC KEY", publicDER)
+
+	signingKey, err := LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyingKey, err := LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPublicKey(privatePath); err == nil {
+		t.Error("Expected an error reading a private key as a public one")
+	}
+
+	signature, err := SignDetached(testInfo(), signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyDetached(loaded, verifyingKey, signature); err != nil {
+		t.Errorf("Expected the signature to verify, got %v", err)
+	}
+	loaded.Tables[0].Comment = "Orders"
+	if err := VerifyDetached(loaded, verifyingKey, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected an invalid signature after tampering, got %v", err)
+	}
+	if err := VerifyDetached(testInfo(), verifyingKey, []byte("not base64!")); err == nil || errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a decoding error, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package snapshot reads and writes dbinfo schemas stored as YAML or JSON
+// documents, the formats produced by 'dbinfo dump'.
+//
+// Snapshots kept as compliance evidence can be made tamper-evident by
+// signing their canonical form, either with an HMAC embedded in the
+// snapshot (SignHMAC) or with an Ed25519 signature kept in a file of its
+// own (SignDetached), which anyone with the public key can verify without
+// being able to sign.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads a snapshot in YAML or JSON format. The format is detected from
+// the content: documents starting with '{' are parsed as JSON.
+func Load(r io.Reader) (*dbinfo.DBInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes a snapshot in YAML or JSON format. Lists left out of the
+// snapshot are empty, not nil, as in models read from a database.
+func Parse(data []byte) (*dbinfo.DBInfo, error) {
+	info := &dbinfo.DBInfo{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, info); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON snapshot: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML snapshot: %w", err)
+	}
+	info.EnsureSlices()
+	return info, nil
+}
+
+// LoadFile reads a snapshot from a file, or from a snapshot directory
+// written by WriteDir
+func LoadFile(path string) (*dbinfo.DBInfo, error) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return LoadDir(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// LoadFS reads a snapshot from the file name in fsys, or from a snapshot
+// directory written by WriteDir. Applications can embed the snapshot of the
+// schema they expect with go:embed and compare it with the database they
+// connect to at startup.
+func LoadFS(fsys fs.FS, name string) (*dbinfo.DBInfo, error) {
+	if fi, err := fs.Stat(fsys, name); err == nil && fi.IsDir() {
+		return LoadDirFS(fsys, name)
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// WriteYAML writes info as a YAML snapshot
+func WriteYAML(w io.Writer, info *dbinfo.DBInfo) error {
+	return WriteYAMLAll(w, []*dbinfo.DBInfo{info})
+}
+
+// WriteJSON writes info as an indented JSON snapshot
+func WriteJSON(w io.Writer, info *dbinfo.DBInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(info)
+}
+
+// WriteYAMLAll writes several snapshots as a multi-document YAML stream, one
+// document per database
+func WriteYAMLAll(w io.Writer, infos []*dbinfo.DBInfo) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(4)
+	for _, info := range infos {
+		if err := encodeYAML(enc, info.Clone()); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// encodeYAML writes v, which it modifies, as a YAML document. yaml.v3
+// doesn't write multi-line strings starting with white space as block
+// scalars it reads back the same: it loses leading line breaks and
+// indentation, and fails on a leading tab. Those strings are marked with a
+// NUL, which PostgreSQL text can't hold, to be double quoted instead.
+func encodeYAML(enc *yaml.Encoder, v any) error {
+	markStrings(reflect.ValueOf(v))
+	var doc yaml.Node
+	if err := doc.Encode(v); err != nil {
+		return err
+	}
+	setStringStyles(&doc)
+	return enc.Encode(&doc)
+}
+
+// unsafeBlockMark prefixes the strings markStrings marks
+const unsafeBlockMark = "\x00"
+
+// markStrings marks the multi-line strings in v starting with white space
+func markStrings(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			markStrings(v.Elem())
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				markStrings(v.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			markStrings(v.Index(i))
+		}
+	case reflect.String:
+		s := v.String()
+		if strings.Contains(s, "\n") && strings.ContainsAny(s[:1], " \t\n") {
+			v.SetString(unsafeBlockMark + s)
+		}
+	}
+}
+
+// setStringStyles writes multi-line strings, such as comments, as literal
+// block scalars so they read as they were written, and the strings marked
+// by markStrings double quoted
+func setStringStyles(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" {
+		switch {
+		case strings.HasPrefix(node.Value, unsafeBlockMark):
+			node.Value = strings.TrimPrefix(node.Value, unsafeBlockMark)
+			node.Style = yaml.DoubleQuotedStyle
+		case strings.Contains(node.Value, "\n"):
+			node.Style = yaml.LiteralStyle
+		}
+	}
+	for _, child := range node.Content {
+		setStringStyles(child)
+	}
+}
+
+// WriteJSONAll writes several snapshots as an indented JSON array
+func WriteJSONAll(w io.Writer, infos []*dbinfo.DBInfo) error {
+	if infos == nil {
+		infos = []*dbinfo.DBInfo{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(infos)
+}
@@ -0,0 +1,207 @@
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Name:    "orders",
+				Schema:  "public",
+				Comment: "Customer orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer", FullType: "integer"},
+				},
+				Indexes: []*dbinfo.Index{
+					{Name: "idx_orders_customer_id", Columns: []string{"customer_id"}},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{
+					{Name: "orders_customer_id_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: "public", RefTableName: "customers", RefColumnNames: []string{"id"}, OnUpdate: "NO ACTION", OnDelete: "CASCADE"},
+				},
+				BelongsTo: []*dbinfo.Relationship{
+					{Table: "customers", Schema: "public", ForeignKey: "orders_customer_id_fkey", Columns: []string{"customer_id"}, References: []string{"id"}, OnDelete: "CASCADE"},
+				},
+			},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	writers := map[string]func(*bytes.Buffer, *dbinfo.DBInfo) error{
+		"yaml": func(b *bytes.Buffer, info *dbinfo.DBInfo) error { return WriteYAML(b, info) },
+		"json": func(b *bytes.Buffer, info *dbinfo.DBInfo) error { return WriteJSON(b, info) },
+	}
+	for name, write := range writers {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(&buf, testInfo()); err != nil {
+				t.Fatalf("Failed to write snapshot: %v", err)
+			}
+			loaded, err := Load(&buf)
+			if err != nil {
+				t.Fatalf("Failed to load snapshot: %v", err)
+			}
+			expected := testInfo()
+			expected.EnsureSlices()
+			if diff := cmp.Diff(expected, loaded); diff != "" {
+				t.Errorf("Round trip mismatch (-expected +actual):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteAll(t *testing.T) {
+	other := testInfo()
+	other.Name = "billing"
+	infos := []*dbinfo.DBInfo{testInfo(), other}
+
+	var buf bytes.Buffer
+	if err := WriteYAMLAll(&buf, infos); err != nil {
+		t.Fatal(err)
+	}
+	if documents := strings.Split(buf.String(), "---\n"); len(documents) != 2 {
+		t.Fatalf("Expected 2 YAML documents, got %d:\n%s", len(documents), buf.String())
+	} else if loaded, err := Parse([]byte(documents[1])); err != nil || loaded.Name != "billing" {
+		t.Errorf("Expected the second document to be the billing snapshot, got %v, %v", loaded, err)
+	}
+
+	buf.Reset()
+	if err := WriteJSONAll(&buf, infos); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "[") || !strings.Contains(buf.String(), `"name": "billing"`) {
+		t.Errorf("Expected a JSON array of snapshots, got:\n%s", buf.String())
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.yaml")
+	var buf bytes.Buffer
+	if err := WriteYAML(&buf, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot file: %v", err)
+	}
+	if loaded.Name != "shop" || len(loaded.Tables) != 1 {
+		t.Errorf("Unexpected snapshot %+v", loaded)
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestLoadFS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := WriteDir(dir, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"schema/shop.json": {Data: buf.Bytes()}}
+	for _, name := range dirFiles(t, dir) {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fsys["schema/dir/"+name] = &fstest.MapFile{Data: data}
+	}
+
+	for _, name := range []string{"schema/shop.json", "schema/dir"} {
+		loaded, err := LoadFS(fsys, name)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+		if diff := cmp.Diff(testInfo().Tables[0].Columns, loaded.Tables[0].Columns); diff != "" {
+			t.Errorf("Unexpected columns from %s (-want +got):\n%s", name, diff)
+		}
+	}
+
+	if _, err := LoadFS(fsys, "schema/missing.yaml"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]byte("{not json")); err == nil || !strings.Contains(err.Error(), "JSON") {
+		t.Errorf("Expected a JSON parse error, got %v", err)
+	}
+	if _, err := Parse([]byte("tables: [")); err == nil || !strings.Contains(err.Error(), "YAML") {
+		t.Errorf("Expected a YAML parse error, got %v", err)
+	}
+}
+
+func TestCommentsRoundTrip(t *testing.T) {
+	comments := []string{
+		"First line\nsecond line",
+		"Ends with a line break\n",
+		"\nStarts with a line break",
+		"\tIndented with a tab\nand more",
+		"  Indented\n    nested",
+		"Trailing space \nhere",
+		"Windows\r\nline breaks",
+		"key: value # not a comment",
+		"- not a list",
+		`Quotes ' and " and \backslash`,
+		"yes",
+		"null",
+		"a < b && c > d",
+		"Ünïcödé 表 🎉",
+		"Line\u2028separator",
+	}
+	info := &dbinfo.DBInfo{Name: "shop"}
+	for i, comment := range comments {
+		info.Tables = append(info.Tables, &dbinfo.Table{
+			Schema:  "public",
+			Name:    fmt.Sprintf("t%d", i),
+			Comment: comment,
+			Columns: []*dbinfo.Column{{Name: "c", Type: "text", Comment: comment}},
+		})
+	}
+
+	var yamlBuf, jsonBuf bytes.Buffer
+	if err := WriteYAML(&yamlBuf, info); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	if err := WriteJSON(&jsonBuf, info); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	info.EnsureSlices()
+	for name, buf := range map[string]*bytes.Buffer{"yaml": &yamlBuf, "json": &jsonBuf} {
+		loaded, err := Parse(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		if diff := cmp.Diff(info, loaded); diff != "" {
+			t.Errorf("Comments changed in %s (-expected +actual):\n%s", name, diff)
+		}
+	}
+
+	if !strings.Contains(yamlBuf.String(), "comment: |-\n") || strings.Contains(yamlBuf.String(), `"First line\nsecond line"`) {
+		t.Errorf("Expected multi-line comments as block scalars, got\n%s", yamlBuf.String())
+	}
+	if !strings.Contains(jsonBuf.String(), `"a < b && c > d"`) || !strings.Contains(jsonBuf.String(), "表") {
+		t.Errorf("Expected comments in JSON without needless escapes, got\n%s", jsonBuf.String())
+	}
+}
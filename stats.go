@@ -0,0 +1,393 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// TableStats holds size and row statistics of a table. Row counts are the
+// planner estimates, accurate as of the last ANALYZE.
+type TableStats struct {
+	Schema      string  `json:"schema" yaml:"schema"`
+	Name        string  `json:"name" yaml:"name"`
+	RowEstimate int64   `json:"rowestimate" yaml:"rowestimate"`
+	TableBytes  int64   `json:"tablebytes" yaml:"tablebytes"` // Main data, without indexes and TOAST
+	IndexBytes  int64   `json:"indexbytes" yaml:"indexbytes"`
+	ToastBytes  int64   `json:"toastbytes" yaml:"toastbytes"`
+	TotalBytes  int64   `json:"totalbytes" yaml:"totalbytes"`
+	LiveTuples  int64   `json:"livetuples" yaml:"livetuples"`
+	DeadTuples  int64   `json:"deadtuples" yaml:"deadtuples"`
+	BloatRatio  float64 `json:"bloatratio" yaml:"bloatratio"` // Share of dead tuples, from 0 to 1
+	BloatBytes  int64   `json:"bloatbytes" yaml:"bloatbytes"` // Estimated space taken by dead tuples
+	// Partitions is the number of partitions rolled up into the table, at
+	// any depth, with WithRolledUpPartitions
+	Partitions int `json:"partitions,omitempty" yaml:"partitions,omitempty"`
+}
+
+// Statistics is the estimated row count and the size of a table, as
+// GetDBInfo records them with WithStatistics. Row counts are the planner
+// estimates, accurate as of the last ANALYZE.
+type Statistics struct {
+	RowEstimate int64 `json:"rowestimate" yaml:"rowestimate"`
+	TotalBytes  int64 `json:"totalbytes" yaml:"totalbytes"`
+	TableBytes  int64 `json:"tablebytes" yaml:"tablebytes"` // Main data, without indexes and TOAST
+	IndexBytes  int64 `json:"indexbytes" yaml:"indexbytes"`
+	ToastBytes  int64 `json:"toastbytes" yaml:"toastbytes"`
+}
+
+// SchemaStats sums the statistics of the tables of a schema
+type SchemaStats struct {
+	Schema      string `json:"schema" yaml:"schema"`
+	Tables      int    `json:"tables" yaml:"tables"`
+	RowEstimate int64  `json:"rowestimate" yaml:"rowestimate"`
+	TableBytes  int64  `json:"tablebytes" yaml:"tablebytes"`
+	IndexBytes  int64  `json:"indexbytes" yaml:"indexbytes"`
+	ToastBytes  int64  `json:"toastbytes" yaml:"toastbytes"`
+	TotalBytes  int64  `json:"totalbytes" yaml:"totalbytes"`
+	BloatBytes  int64  `json:"bloatbytes" yaml:"bloatbytes"`
+}
+
+// tableStatsQuery reads the sizes and tuple counts of the tables of the
+// schemas $1, excluding the schemas $2, with the parent of partitions.
+// reltuples is -1 for tables never analyzed since PostgreSQL 14.
+const tableStatsQuery = `
+	SELECT n.nspname, c.relname,
+	       GREATEST(c.reltuples, 0)::bigint AS row_estimate,
+	       pg_relation_size(c.oid) AS table_bytes,
+	       pg_indexes_size(c.oid) AS index_bytes,
+	       COALESCE(pg_total_relation_size(NULLIF(c.reltoastrelid, 0)), 0) AS toast_bytes,
+	       pg_total_relation_size(c.oid) AS total_bytes,
+	       COALESCE(s.n_live_tup, 0) AS live_tuples,
+	       COALESCE(s.n_dead_tup, 0) AS dead_tuples,
+	       pn.nspname AS parent_schema, p.relname AS parent_name
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_stat_user_tables s ON s.relid = c.oid
+	LEFT JOIN pg_inherits inh ON inh.inhrelid = c.oid AND c.relispartition
+	LEFT JOIN pg_class p ON p.oid = inh.inhparent
+	LEFT JOIN pg_namespace pn ON pn.oid = p.relnamespace
+	WHERE c.relkind IN ('r', 'p')
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	ORDER BY n.nspname, c.relname`
+
+// GetTableStats returns the size and row statistics of the tables selected
+// by opts, sorted by schema and name. Bloat is estimated from the dead
+// tuples counted by the statistics collector, which is cheap but less
+// precise than pgstattuple. With WithRolledUpPartitions, the statistics of
+// partitions are added to their partitioned table, so it reports the whole
+// logical table, including partitions the table filters leave out.
+func GetTableStats(ctx context.Context, db DBQuerier, opts ...Option) ([]*TableStats, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := o.querier(db).Query(ctx, tableStatsQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("failed to query table statistics: %w", err))
+	}
+	defer rows.Close()
+
+	var all []*TableStats
+	parents := make(map[ObjectKey]ObjectKey)
+	for rows.Next() {
+		s := &TableStats{}
+		var parentSchema, parentName *string // NULL unless the table is a partition
+		err := rows.Scan(&s.Schema, &s.Name, &s.RowEstimate, &s.TableBytes, &s.IndexBytes,
+			&s.ToastBytes, &s.TotalBytes, &s.LiveTuples, &s.DeadTuples, &parentSchema, &parentName)
+		if err != nil {
+			return nil, queryError(PhaseStats, "", fmt.Errorf("failed to scan table statistics row: %w", err))
+		}
+		s.estimateBloat()
+		if parentSchema != nil && parentName != nil {
+			parents[ObjectKey{s.Schema, s.Name}] = ObjectKey{*parentSchema, *parentName}
+		}
+		all = append(all, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("error iterating table statistics rows: %w", err))
+	}
+
+	byKey := make(map[ObjectKey]*TableStats, len(all))
+	for _, s := range all {
+		byKey[ObjectKey{s.Schema, s.Name}] = s
+	}
+	var stats []*TableStats
+	for _, s := range all {
+		key := ObjectKey{s.Schema, s.Name}
+		if o.rollUpPartitions {
+			if root, ok := partitionRoot(o, key, parents, byKey); ok {
+				byKey[root].add(s)
+				continue
+			}
+		}
+		if o.includeTable(s.Schema, s.Name) {
+			stats = append(stats, s)
+		}
+	}
+	slices.SortStableFunc(stats, func(a, b *TableStats) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	return stats, nil
+}
+
+// getStatistics reads the row estimates and sizes of tables with
+// tableStatsQuery. Rolled up partitions add up in their table.
+func getStatistics(ctx context.Context, db DBQuerier, o *options, tables []*Table) error {
+	statistics := make(map[ObjectKey]*Statistics, len(tables))
+	for _, table := range tables {
+		s := &Statistics{}
+		table.Statistics = s
+		statistics[ObjectKey{table.Schema, table.Name}] = s
+		var walk func([]*Partition)
+		walk = func(partitions []*Partition) {
+			for _, p := range partitions {
+				statistics[ObjectKey{p.Schema, p.Name}] = s
+				walk(p.Partitions)
+			}
+		}
+		walk(table.Partitions)
+	}
+
+	rows, err := db.Query(ctx, tableStatsQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return queryError(PhaseStats, "", fmt.Errorf("failed to query table statistics: %w", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key ObjectKey
+		var row TableStats                   // Tuple counts are left to GetTableStats
+		var parentSchema, parentName *string // Partitions are already rolled up
+		err := rows.Scan(&key.Schema, &key.Name, &row.RowEstimate, &row.TableBytes, &row.IndexBytes,
+			&row.ToastBytes, &row.TotalBytes, &row.LiveTuples, &row.DeadTuples, &parentSchema, &parentName)
+		if err != nil {
+			return queryError(PhaseStats, "", fmt.Errorf("failed to scan table statistics row: %w", err))
+		}
+		if s := statistics[key]; s != nil {
+			s.RowEstimate += row.RowEstimate
+			s.TotalBytes += row.TotalBytes
+			s.TableBytes += row.TableBytes
+			s.IndexBytes += row.IndexBytes
+			s.ToastBytes += row.ToastBytes
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return queryError(PhaseStats, "", fmt.Errorf("error iterating table statistics rows: %w", err))
+	}
+	return nil
+}
+
+// TableStats returns the statistics recorded in the tables of the model
+// with WithStatistics, as GetTableStats returns them without the tuple
+// counts and bloat, sorted by schema and name. Tables without statistics
+// are left out.
+func (d *DBInfo) TableStats() []*TableStats {
+	var stats []*TableStats
+	for _, t := range d.Tables {
+		if s := t.Statistics; s != nil {
+			stats = append(stats, &TableStats{Schema: t.Schema, Name: t.Name, RowEstimate: s.RowEstimate, TableBytes: s.TableBytes,
+				IndexBytes: s.IndexBytes, ToastBytes: s.ToastBytes, TotalBytes: s.TotalBytes, Partitions: countPartitions(t.Partitions)})
+		}
+	}
+	slices.SortStableFunc(stats, func(a, b *TableStats) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	return stats
+}
+
+// countPartitions counts partitions at any depth
+func countPartitions(partitions []*Partition) int {
+	n := len(partitions)
+	for _, p := range partitions {
+		n += countPartitions(p.Partitions)
+	}
+	return n
+}
+
+// partitionRoot returns the topmost ancestor of the partition key selected
+// by the table filters of o, among the tables of present, to roll it up into.
+// It returns false for tables that aren't partitions, or whose ancestors
+// are all filtered out.
+func partitionRoot[V any](o *options, key ObjectKey, parents map[ObjectKey]ObjectKey, present map[ObjectKey]V) (ObjectKey, bool) {
+	var root ObjectKey
+	found := false
+	for {
+		parent, ok := parents[key]
+		if _, listed := present[parent]; !ok || !listed {
+			return root, found
+		}
+		if o.includeTable(parent.Schema, parent.Name) {
+			root, found = parent, true
+		}
+		key = parent
+	}
+}
+
+// add adds the statistics of a partition to the statistics of its
+// partitioned table
+func (s *TableStats) add(partition *TableStats) {
+	s.Partitions++
+	s.RowEstimate += partition.RowEstimate
+	s.TableBytes += partition.TableBytes
+	s.IndexBytes += partition.IndexBytes
+	s.ToastBytes += partition.ToastBytes
+	s.TotalBytes += partition.TotalBytes
+	s.LiveTuples += partition.LiveTuples
+	s.DeadTuples += partition.DeadTuples
+	s.BloatBytes += partition.BloatBytes
+	if tuples := s.LiveTuples + s.DeadTuples; tuples > 0 {
+		s.BloatRatio = float64(s.DeadTuples) / float64(tuples)
+	}
+}
+
+// IndexStats holds the size and usage of an index
+type IndexStats struct {
+	Schema string `json:"schema" yaml:"schema"`
+	Table  string `json:"table" yaml:"table"`
+	Name   string `json:"name" yaml:"name"`
+	Bytes  int64  `json:"bytes" yaml:"bytes"`
+	Scans  int64  `json:"scans" yaml:"scans"` // Index scans since the statistics were last reset
+}
+
+// indexStatsQuery reads the size and scans of the indexes of the tables of
+// the schemas $1, excluding the schemas $2, with the parent of partitions and
+// the partitioned index the indexes of partitions are attached to
+const indexStatsQuery = `
+	SELECT n.nspname, t.relname, i.relname,
+	       pg_relation_size(i.oid) AS bytes,
+	       COALESCE(s.idx_scan, 0) AS scans,
+	       pn.nspname AS parent_schema, p.relname AS parent_name, pi.relname AS parent_index
+	FROM pg_index x
+	JOIN pg_class i ON i.oid = x.indexrelid
+	JOIN pg_class t ON t.oid = x.indrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	LEFT JOIN pg_stat_user_indexes s ON s.indexrelid = x.indexrelid
+	LEFT JOIN pg_inherits inh ON inh.inhrelid = t.oid AND t.relispartition
+	LEFT JOIN pg_class p ON p.oid = inh.inhparent
+	LEFT JOIN pg_namespace pn ON pn.oid = p.relnamespace
+	LEFT JOIN pg_inherits iinh ON iinh.inhrelid = i.oid
+	LEFT JOIN pg_class pi ON pi.oid = iinh.inhparent
+	WHERE t.relkind IN ('r', 'p')
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	ORDER BY n.nspname, t.relname, i.relname`
+
+// GetIndexStats returns the size and scan count of the indexes of the tables
+// selected by opts, sorted by schema, table and name. With
+// WithRolledUpPartitions, the indexes of partitions are added to the
+// partitioned index of their partitioned table they are attached to, or
+// listed under the partitioned table when they aren't attached to one.
+func GetIndexStats(ctx context.Context, db DBQuerier, opts ...Option) ([]*IndexStats, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	rows, err := o.querier(db).Query(ctx, indexStatsQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("failed to query index statistics: %w", err))
+	}
+	defer rows.Close()
+
+	// Index names are unique in a schema, and the indexes of a partition
+	// are attached to an index of its parent
+	type indexKey struct{ schema, table, name string }
+	var all []*IndexStats
+	tables := make(map[ObjectKey]bool)
+	parents := make(map[ObjectKey]ObjectKey)
+	parentIndexes := make(map[indexKey]string)
+	for rows.Next() {
+		s := &IndexStats{}
+		var parentSchema, parentName, parentIndex *string // NULL unless the table is a partition
+		if err := rows.Scan(&s.Schema, &s.Table, &s.Name, &s.Bytes, &s.Scans, &parentSchema, &parentName, &parentIndex); err != nil {
+			return nil, queryError(PhaseStats, "", fmt.Errorf("failed to scan index statistics row: %w", err))
+		}
+		tables[ObjectKey{s.Schema, s.Table}] = true
+		if parentSchema != nil && parentName != nil {
+			parents[ObjectKey{s.Schema, s.Table}] = ObjectKey{*parentSchema, *parentName}
+			if parentIndex != nil {
+				parentIndexes[indexKey{s.Schema, s.Table, s.Name}] = *parentIndex
+			}
+		}
+		all = append(all, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(PhaseStats, "", fmt.Errorf("error iterating index statistics rows: %w", err))
+	}
+
+	byKey := make(map[indexKey]*IndexStats, len(all))
+	for _, s := range all {
+		byKey[indexKey{s.Schema, s.Table, s.Name}] = s
+	}
+	var stats []*IndexStats
+	for _, s := range all {
+		if o.rollUpPartitions {
+			if root, ok := partitionRoot(o, ObjectKey{s.Schema, s.Table}, parents, tables); ok {
+				// Follow the attached indexes up to the partitioned table
+				key := indexKey{s.Schema, s.Table, s.Name}
+				for key.schema != root.Schema || key.table != root.Name {
+					name, attached := parentIndexes[key]
+					if !attached {
+						break
+					}
+					parent := parents[ObjectKey{key.schema, key.table}]
+					key = indexKey{parent.Schema, parent.Name, name}
+				}
+				if target := byKey[key]; target != nil && target != s && key.schema == root.Schema && key.table == root.Name {
+					target.Bytes += s.Bytes
+					target.Scans += s.Scans
+					continue
+				}
+				s.Schema, s.Table = root.Schema, root.Name
+			}
+		}
+		if o.includeTable(s.Schema, s.Table) {
+			stats = append(stats, s)
+		}
+	}
+	slices.SortStableFunc(stats, func(a, b *IndexStats) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Table, b.Table), cmp.Compare(a.Name, b.Name))
+	})
+	return stats, nil
+}
+
+// estimateBloat derives the bloat from the share of dead tuples
+func (s *TableStats) estimateBloat() {
+	if tuples := s.LiveTuples + s.DeadTuples; tuples > 0 {
+		s.BloatRatio = float64(s.DeadTuples) / float64(tuples)
+		s.BloatBytes = int64(float64(s.TableBytes) * s.BloatRatio)
+	}
+}
+
+// SummarizeStats sums the statistics of the tables per schema, sorted by
+// schema name
+func SummarizeStats(tables []*TableStats) []*SchemaStats {
+	bySchema := make(map[string]*SchemaStats)
+	for _, t := range tables {
+		s, ok := bySchema[t.Schema]
+		if !ok {
+			s = &SchemaStats{Schema: t.Schema}
+			bySchema[t.Schema] = s
+		}
+		s.Tables++
+		s.RowEstimate += t.RowEstimate
+		s.TableBytes += t.TableBytes
+		s.IndexBytes += t.IndexBytes
+		s.ToastBytes += t.ToastBytes
+		s.TotalBytes += t.TotalBytes
+		s.BloatBytes += t.BloatBytes
+	}
+
+	schemas := make([]*SchemaStats, 0, len(bySchema))
+	for _, s := range bySchema {
+		schemas = append(schemas, s)
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Schema < schemas[j].Schema })
+	return schemas
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gcsBackend stores documents as objects of a Google Cloud Storage bucket,
+// through the JSON API
+type gcsBackend struct {
+	client   *http.Client
+	bucket   string
+	endpoint string // Base URL of the API, or of an emulator
+	// token returns the OAuth access token of a request, empty without
+	// authentication
+	token func(ctx context.Context) (string, error)
+}
+
+// metadataTokenURL serves the access token of the service account of
+// Google Cloud virtual machines and containers
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// openGCS authenticates gs:// locations with the access token in
+// GOOGLE_OAUTH_ACCESS_TOKEN, or with the service account of the metadata
+// server. STORAGE_EMULATOR_HOST selects an emulator, without authentication.
+func openGCS(u *url.URL) (Backend, string, error) {
+	object := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || object == "" {
+		return nil, "", fmt.Errorf("invalid Cloud Storage location %q, expected gs://bucket/object", u.String())
+	}
+	b := &gcsBackend{client: http.DefaultClient, bucket: u.Host, endpoint: "https://storage.googleapis.com"}
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		b.endpoint = strings.TrimSuffix(host, "/")
+		b.token = func(context.Context) (string, error) { return "", nil }
+	} else if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		b.token = func(context.Context) (string, error) { return token, nil }
+	} else {
+		b.token = b.metadataToken
+	}
+	return b, object, nil
+}
+
+// metadataToken asks the metadata server for an access token
+func (b *gcsBackend) metadataToken(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := do(b.client, req)
+	if err != nil {
+		return "", fmt.Errorf("no Cloud Storage credentials, set GOOGLE_OAUTH_ACCESS_TOKEN (metadata server: %w)", err)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse metadata server token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+func (b *gcsBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	u := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", b.endpoint, url.PathEscape(b.bucket), url.PathEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.do(req)
+}
+
+func (b *gcsBackend) Put(ctx context.Context, key string, data []byte) error {
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", b.endpoint, url.PathEscape(b.bucket), url.QueryEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(key))
+	_, err = b.do(req)
+	return err
+}
+
+func (b *gcsBackend) do(req *http.Request) ([]byte, error) {
+	token, err := b.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return do(b.client, req)
+}
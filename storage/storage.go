@@ -0,0 +1,226 @@
+// Package storage reads and writes snapshots in local files and remote
+// object stores, so CI jobs can share them without extra scripting.
+//
+// Locations are URLs: s3://bucket/key for Amazon S3 and S3 compatible
+// stores, gs://bucket/object for Google Cloud Storage, http:// and https://
+// for servers accepting GET and PUT, and file:// or plain paths for local
+// files. Other schemes can be added with Register.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Backend stores documents by key. Keys are the part of a location a backend
+// resolves, such as the object key in a bucket.
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// Opener returns the backend serving a location and the key of the location
+// in it
+type Opener func(u *url.URL) (Backend, string, error)
+
+// ErrNotFound is returned by Get when the location holds no document
+var ErrNotFound = errors.New("snapshot not found")
+
+var (
+	mu      sync.RWMutex
+	openers = map[string]Opener{
+		"file":  openFile,
+		"http":  openHTTP,
+		"https": openHTTP,
+		"s3":    openS3,
+		"gs":    openGCS,
+	}
+)
+
+// Register makes the backend returned by open serve the locations with the
+// given URL scheme, replacing any backend registered for it
+func Register(scheme string, open Opener) {
+	mu.Lock()
+	defer mu.Unlock()
+	openers[scheme] = open
+}
+
+// IsURL reports whether location is a URL rather than a local path
+func IsURL(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	return ok && scheme != "" && !strings.ContainsAny(scheme, `/\`)
+}
+
+// Open returns the backend serving location and the key of the location in
+// it. Locations without a scheme are local paths.
+func Open(location string) (Backend, string, error) {
+	if !IsURL(location) {
+		return fileBackend{}, location, nil
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid snapshot location: %w", err)
+	}
+	mu.RLock()
+	open, ok := openers[u.Scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported snapshot location %q, expected a path or an s3://, gs://, http(s):// or file:// URL", location)
+	}
+	return open(u)
+}
+
+// Get reads the document at location
+func Get(ctx context.Context, location string) ([]byte, error) {
+	backend, key, err := Open(location)
+	if err != nil {
+		return nil, err
+	}
+	data, err := backend.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", redact(location), err)
+	}
+	return data, nil
+}
+
+// Put writes data to location, replacing any document there
+func Put(ctx context.Context, location string, data []byte) error {
+	backend, key, err := Open(location)
+	if err != nil {
+		return err
+	}
+	if err := backend.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", redact(location), err)
+	}
+	return nil
+}
+
+// redact hides the password of a location in error messages
+func redact(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.User == nil {
+		return location
+	}
+	return u.Redacted()
+}
+
+// fileBackend stores documents in local files named by their key
+type fileBackend struct{}
+
+func openFile(u *url.URL) (Backend, string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, "", fmt.Errorf("file URL %q names a remote host", u.String())
+	}
+	return fileBackend{}, filepath.FromSlash(u.Path), nil
+}
+
+func (fileBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (fileBackend) Put(ctx context.Context, key string, data []byte) error {
+	if dir := filepath.Dir(key); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(key, data, 0o644)
+}
+
+// TokenEnv is the environment variable holding a bearer token sent to
+// http:// and https:// locations. Credentials in the URL are sent with
+// basic authentication instead.
+const TokenEnv = "DBINFO_STORAGE_TOKEN"
+
+// httpBackend gets documents with GET and writes them with PUT, the key
+// being the URL
+type httpBackend struct {
+	client *http.Client
+	token  string
+}
+
+func openHTTP(u *url.URL) (Backend, string, error) {
+	return &httpBackend{client: http.DefaultClient, token: os.Getenv(TokenEnv)}, u.String(), nil
+}
+
+func (b *httpBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.do(req)
+}
+
+func (b *httpBackend) Put(ctx context.Context, key string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType(key))
+	_, err = b.do(req)
+	return err
+}
+
+func (b *httpBackend) do(req *http.Request) ([]byte, error) {
+	if b.token != "" && req.URL.User == nil {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	return do(b.client, req)
+}
+
+// do sends req and returns the body of a successful response
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound && req.Method == http.MethodGet:
+		return nil, ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("%s: %s", resp.Status, summary(body))
+	}
+	return body, nil
+}
+
+// summary returns the start of an error response body
+func summary(body []byte) string {
+	const limit = 200
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) > limit {
+		s = s[:limit] + "..."
+	}
+	if s == "" {
+		return "empty response"
+	}
+	return s
+}
+
+// contentType returns the media type of a snapshot from its extension
+func contentType(key string) string {
+	switch strings.ToLower(filepath.Ext(key)) {
+	case ".json":
+		return "application/json"
+	case ".yaml", ".yml":
+		return "application/yaml"
+	}
+	return "application/octet-stream"
+}
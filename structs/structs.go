@@ -0,0 +1,401 @@
+// Package structs checks Go structs mapped to tables, such as the models of
+// an ORM, against a schema, so applications can verify their models against
+// the database when they start or in tests: fields with no column, Go types
+// that can't hold the values of their column, nullable columns read into
+// fields that can't hold NULL and sizes larger than their column.
+//
+// A struct maps to the table its TableName method returns, as with gorm,
+// or to the table named like the struct, in singular or plural form:
+// OrderItem matches order_items. Exported fields map to columns by their
+// tags, or by their name in snake_case:
+//
+//	ID    int64  `db:"id"`                        // sqlx, scany and pgx
+//	Email string `gorm:"column:email;size:255"`  // gorm
+//	Cache []byte `db:"-"`                         // not a column
+//
+// Embedded structs contribute their fields, prefixed with gorm's
+// embeddedPrefix. Fields holding other structs, or slices of them, are
+// relationships and are skipped.
+package structs
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+)
+
+// Kind classifies mismatches
+type Kind string
+
+// Mismatch kinds
+const (
+	MissingTable  Kind = "missing-table"  // No table matches the struct
+	MissingColumn Kind = "missing-column" // A field has no column
+	TypeConflict  Kind = "type"           // The field can't hold the values of its column
+	Nullable      Kind = "nullable"       // A nullable column is read into a field that can't hold NULL
+	Length        Kind = "length"         // The size tag of the field allows longer strings than the column
+	Unmapped      Kind = "unmapped"       // A NOT NULL column without a default no field sets, reported with Options.Strict
+)
+
+// Mismatch is a divergence between a struct and its table
+type Mismatch struct {
+	Model   string `json:"model"`           // Go type of the struct, such as app.Order
+	Field   string `json:"field,omitempty"` // Empty for mismatches of the whole struct, Address.City for fields of embedded structs
+	Table   string `json:"table,omitempty"` // schema.table
+	Column  string `json:"column,omitempty"`
+	Kind    Kind   `json:"kind"`
+	Message string `json:"message"`
+}
+
+// String formats the mismatch as Model.Field: message
+func (m *Mismatch) String() string {
+	location := m.Model
+	if m.Field != "" {
+		location += "." + m.Field
+	}
+	return location + ": " + m.Message
+}
+
+// Options configure Check
+type Options struct {
+	// Schema picks the table of a struct when tables of several schemas
+	// match it, public by default. TableName methods returning
+	// schema.table pick it themselves.
+	Schema string
+	// Strict also reports the NOT NULL columns without a default that no
+	// field maps, which inserting the struct fails on. Structs that only
+	// read a few columns of their table don't need them.
+	Strict bool
+}
+
+// tabler is implemented by structs naming their table, as gorm models do
+type tabler interface {
+	TableName() string
+}
+
+// Check compares structs, or pointers to them, with the tables of info and
+// returns the mismatches, in the order of the structs and their fields
+func Check(models []any, info *dbinfo.DBInfo, opts Options) []*Mismatch {
+	var mismatches []*Mismatch
+	for _, model := range models {
+		t := reflect.TypeOf(model)
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			mismatches = append(mismatches, &Mismatch{Model: fmt.Sprintf("%T", model), Kind: TypeConflict, Message: "not a struct"})
+			continue
+		}
+		table, mismatch := findTable(info, t, opts.Schema)
+		if mismatch != nil {
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		mismatches = append(mismatches, checkTable(t, table, opts)...)
+	}
+	return mismatches
+}
+
+// Error is returned by Verify when structs don't match their tables
+type Error struct {
+	Mismatches []*Mismatch
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, len(e.Mismatches))
+	for i, m := range e.Mismatches {
+		lines[i] = m.String()
+	}
+	return fmt.Sprintf("%d mismatches between the structs and the schema:\n%s", len(lines), strings.Join(lines, "\n"))
+}
+
+// Verify checks structs against the tables of info with the default
+// options and returns an *Error listing the mismatches, if any. It suits
+// checks at startup and in tests:
+//
+//	if err := structs.Verify(info, &Order{}, &Customer{}); err != nil {
+//		log.Fatal(err)
+//	}
+func Verify(info *dbinfo.DBInfo, models ...any) error {
+	if mismatches := Check(models, info, Options{}); len(mismatches) > 0 {
+		return &Error{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// findTable returns the table of the struct t, named by its TableName
+// method or else named like it
+func findTable(info *dbinfo.DBInfo, t reflect.Type, schema string) (*dbinfo.Table, *Mismatch) {
+	model := t.String()
+	if namer, ok := reflect.New(t).Interface().(tabler); ok {
+		explicit := namer.TableName()
+		schemaName, tableName, qualified := strings.Cut(explicit, ".")
+		if !qualified {
+			tableName = explicit
+		}
+		var found []*dbinfo.Table
+		for _, table := range info.Tables {
+			if table.Name == tableName && (!qualified || table.Schema == schemaName) {
+				found = append(found, table)
+			}
+		}
+		if table := pick(found, schema, qualified); table != nil {
+			return table, nil
+		}
+		message := fmt.Sprintf("table %s does not exist", explicit)
+		if len(found) > 1 {
+			message = fmt.Sprintf("several schemas have a table %s, qualify it with its schema or set Options.Schema", explicit)
+		}
+		return nil, &Mismatch{Model: model, Table: explicit, Kind: MissingTable, Message: message}
+	}
+
+	snake := snakeCase(t.Name())
+	var found []*dbinfo.Table
+	for _, table := range info.Tables {
+		if slices.Contains(singulars(table.Name), snake) {
+			found = append(found, table)
+		}
+	}
+	if table := pick(found, schema, false); table != nil {
+		return table, nil
+	}
+	message := "no table matches the struct, name it with a TableName method"
+	if len(found) > 1 {
+		message = "several tables match the struct, name one with a TableName method"
+	}
+	return nil, &Mismatch{Model: model, Kind: MissingTable, Message: message}
+}
+
+// pick returns the only table found, or the one in schema when several
+// schemas have a matching table
+func pick(found []*dbinfo.Table, schema string, qualified bool) *dbinfo.Table {
+	if len(found) == 1 {
+		return found[0]
+	}
+	if qualified {
+		return nil
+	}
+	if schema == "" {
+		schema = "public"
+	}
+	var inSchema []*dbinfo.Table
+	for _, table := range found {
+		if table.Schema == schema {
+			inSchema = append(inSchema, table)
+		}
+	}
+	if len(inSchema) == 1 {
+		return inSchema[0]
+	}
+	return nil
+}
+
+// field is an exported field of a struct mapped to a column
+type field struct {
+	path    string       // Go path of the field, Address.City in embedded structs
+	columns []string     // Candidate column names, the first one matching wins
+	typ     reflect.Type // Go type of the field
+	size    int          // gorm size tag, 0 when unset
+}
+
+// checkTable compares the fields of the struct t with the columns of table
+func checkTable(t reflect.Type, table *dbinfo.Table, opts Options) []*Mismatch {
+	model := t.String()
+	tableName := dbinfo.QualifiedName(table.Schema, table.Name)
+	var mismatches []*Mismatch
+	mapped := map[string]bool{}
+	for _, f := range fields(t, "", "", 0) {
+		report := func(column string, kind Kind, format string, args ...any) {
+			mismatches = append(mismatches, &Mismatch{
+				Model:   model,
+				Field:   f.path,
+				Table:   tableName,
+				Column:  column,
+				Kind:    kind,
+				Message: fmt.Sprintf(format, args...),
+			})
+		}
+
+		column := findColumn(table, f.columns)
+		if column == nil {
+			report(f.columns[0], MissingColumn, "column %s.%s does not exist", tableName, f.columns[0])
+			continue
+		}
+		mapped[column.Name] = true
+
+		value := describe(f.typ)
+		if problem := typeConflict(value, column.Type, column.FullType); problem != "" {
+			report(column.Name, TypeConflict, "%s", problem)
+		}
+		if column.IsNullable && !value.nullable {
+			report(column.Name, Nullable, "column %s is nullable but %s can't hold NULL", column.Name, f.typ)
+		}
+		if limit, ok := characterLength(column.FullType); ok && f.size > limit {
+			report(column.Name, Length, "size %d exceeds the %d characters of column %s", f.size, limit, column.Name)
+		}
+	}
+
+	if opts.Strict {
+		for _, column := range table.Columns {
+			if mapped[column.Name] || column.IsNullable || column.DefaultValue != "" || column.AutoIncrement() {
+				continue
+			}
+			mismatches = append(mismatches, &Mismatch{
+				Model:   model,
+				Table:   tableName,
+				Column:  column.Name,
+				Kind:    Unmapped,
+				Message: fmt.Sprintf("column %s is NOT NULL without a default and no field sets it", column.Name),
+			})
+		}
+	}
+	return mismatches
+}
+
+// findColumn returns the first column of table named like a candidate
+func findColumn(table *dbinfo.Table, candidates []string) *dbinfo.Column {
+	for _, candidate := range candidates {
+		for _, column := range table.Columns {
+			if column.Name == candidate {
+				return column
+			}
+		}
+	}
+	return nil
+}
+
+// fields collects the fields of the struct t mapped to columns. path and
+// prefix are the Go path and gorm column prefix of the fields of an
+// embedded struct.
+func fields(t reflect.Type, path, prefix string, depth int) []*field {
+	var collected []*field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		dbName, _, _ := strings.Cut(f.Tag.Get("db"), ",")
+		settings := gormSettings(f.Tag.Get("gorm"))
+		// gorm:"-:migration" only leaves the field out of migrations
+		if ignore, ok := settings["-"]; dbName == "-" || ok && (ignore == "" || ignore == "all") {
+			continue
+		}
+		name := dbName
+		if name == "" {
+			name = settings["column"]
+		}
+
+		fieldType := f.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		embedded := fieldType.Kind() == reflect.Struct && !isValue(fieldType) && name == "" &&
+			(f.Anonymous || hasSetting(settings, "embedded"))
+		if embedded {
+			if depth < 10 {
+				collected = append(collected, fields(fieldType, path+f.Name+".", prefix+settings["embeddedprefix"], depth+1)...)
+			}
+			continue
+		}
+		if !f.IsExported() || name == "" && isRelationship(f.Type) {
+			continue
+		}
+
+		candidates := []string{prefix + name}
+		if name == "" {
+			candidates = []string{prefix + snakeCase(f.Name), prefix + strings.ToLower(f.Name)}
+		}
+		size, _ := strconv.Atoi(settings["size"])
+		collected = append(collected, &field{path: path + f.Name, columns: candidates, typ: f.Type, size: size})
+	}
+	return collected
+}
+
+// gormSettings parses a gorm tag, such as column:email;size:255;not null,
+// into its settings by lowercase name
+func gormSettings(tag string) map[string]string {
+	settings := map[string]string{}
+	for _, part := range strings.Split(tag, ";") {
+		key, value, _ := strings.Cut(part, ":")
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" {
+			settings[key] = strings.TrimSpace(value)
+		}
+	}
+	return settings
+}
+
+// hasSetting reports whether a gorm tag includes the setting key
+func hasSetting(settings map[string]string, key string) bool {
+	_, ok := settings[key]
+	return ok
+}
+
+var (
+	scannerType = reflect.TypeFor[sql.Scanner]()
+	valuerType  = reflect.TypeFor[driver.Valuer]()
+	timeType    = reflect.TypeFor[time.Time]()
+)
+
+// isValue reports whether values of type t are stored in a single column:
+// times and types implementing sql.Scanner or driver.Valuer, such as
+// sql.NullString or uuid.UUID
+func isValue(t reflect.Type) bool {
+	return t == timeType || t.Implements(valuerType) || reflect.PointerTo(t).Implements(scannerType)
+}
+
+// isRelationship reports whether a field of type t holds other structs
+// rather than the value of a column
+func isRelationship(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		if t.Kind() != reflect.Pointer && isValue(t) {
+			return false
+		}
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isValue(t)
+}
+
+// snakeCase converts a camelCase or PascalCase name to snake_case, keeping
+// acronyms together: UserID becomes user_id and HTTPRequest http_request
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 && runes[i-1] != '_' {
+			previousLower := runes[i-1] >= 'a' && runes[i-1] <= 'z' || runes[i-1] >= '0' && runes[i-1] <= '9'
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			if previousLower || nextLower {
+				b.WriteByte('_')
+			}
+		}
+		if upper {
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// singulars returns the forms a table name can take as a struct name: the
+// name itself and the singulars it could be the regular plural of
+func singulars(table string) []string {
+	forms := []string{table}
+	if strings.HasSuffix(table, "ies") {
+		forms = append(forms, strings.TrimSuffix(table, "ies")+"y")
+	}
+	if strings.HasSuffix(table, "es") {
+		forms = append(forms, strings.TrimSuffix(table, "es"))
+	}
+	if strings.HasSuffix(table, "s") && !strings.HasSuffix(table, "ss") {
+		forms = append(forms, strings.TrimSuffix(table, "s"))
+	}
+	return forms
+}
@@ -0,0 +1,202 @@
+package structs
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema: "public",
+				Name:   "customers",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", FullType: "integer", Kind: dbinfo.ColumnSerial, DefaultValue: "nextval('customers_id_seq'::regclass)"},
+					{Name: "email", Type: "character varying", FullType: "character varying(100)"},
+					{Name: "nickname", Type: "text", FullType: "text", IsNullable: true},
+					{Name: "created_at", Type: "timestamp with time zone", FullType: "timestamp with time zone"},
+					{Name: "born_on", Type: "date", FullType: "date", IsNullable: true},
+					{Name: "address_city", Type: "text", FullType: "text", IsNullable: true},
+					{Name: "tier", Type: "smallint", FullType: "smallint"},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "order_items",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", FullType: "bigint"},
+					{Name: "order_id", Type: "bigint", FullType: "bigint"},
+					{Name: "quantity", Type: "integer", FullType: "integer", IsNullable: true},
+					{Name: "price", Type: "numeric", FullType: "numeric(10,2)"},
+					{Name: "tags", Type: "ARRAY", FullType: "character varying(20)[]"},
+					{Name: "sizes", Type: "ARRAY", FullType: "integer[]", IsNullable: true},
+					{Name: "metadata", Type: "jsonb", FullType: "jsonb", IsNullable: true},
+					{Name: "status", Type: "USER-DEFINED", FullType: "item_status"},
+					{Name: "sku", Type: "text", FullType: "text"},
+				},
+			},
+			{
+				Schema: "billing",
+				Name:   "invoices",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "uuid", FullType: "uuid"},
+				},
+			},
+			{
+				Schema: "public",
+				Name:   "invoices",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "bigint", FullType: "bigint"},
+				},
+			},
+		},
+	}
+}
+
+type Timestamps struct {
+	CreatedAt time.Time
+}
+
+type Address struct {
+	City   *string
+	Street string
+}
+
+type Customer struct {
+	Timestamps
+	ID       int32          `db:"id"`
+	Email    string         `gorm:"size:255"`
+	Nickname sql.NullString // Nullable
+	BornOn   time.Time
+	Address  Address     `gorm:"embedded;embeddedPrefix:address_"`
+	Tier     uint8       // Too small for smallint
+	Items    []OrderItem // A relationship
+	Phone    string      // No column
+	secret   string
+}
+
+type OrderItem struct {
+	ID       int64
+	OrderID  int64
+	Quantity *int
+	Price    float64
+	Tags     []int
+	Sizes    []int16
+	Metadata map[string]any
+	Status   string
+	Sku      bool
+	Ignored  string `gorm:"-"`
+	Skipped  string `db:"-"`
+}
+
+type Invoice struct {
+	ID string
+}
+
+type Payment struct {
+	ID int64
+}
+
+type Refund struct {
+	ID int64
+}
+
+func (Refund) TableName() string { return "billing.refunds" }
+
+type BillingInvoice struct {
+	ID    [16]byte
+	Total *float64
+}
+
+func (*BillingInvoice) TableName() string { return "billing.invoices" }
+
+func TestCheck(t *testing.T) {
+	models := []any{&Customer{}, OrderItem{}, (*Invoice)(nil), &BillingInvoice{}, Payment{}, Refund{}, "orders"}
+	got := Check(models, testInfo(), Options{})
+	var lines []string
+	for _, m := range got {
+		lines = append(lines, string(m.Kind)+" "+m.String())
+	}
+	want := []string{
+		"length structs.Customer.Email: size 255 exceeds the 100 characters of column email",
+		"nullable structs.Customer.BornOn: column born_on is nullable but time.Time can't hold NULL",
+		"missing-column structs.Customer.Address.Street: column public.customers.address_street does not exist",
+		"type structs.Customer.Tier: 8-bit integers can't hold every value of column type smallint",
+		"missing-column structs.Customer.Phone: column public.customers.phone does not exist",
+		"type structs.OrderItem.Tags: elements: an integer can't hold values of column type character varying(20)",
+		"type structs.OrderItem.Sizes: elements: 16-bit integers can't hold every value of column type integer",
+		"type structs.OrderItem.Sku: a bool can't hold values of column type text",
+		"missing-column structs.BillingInvoice.Total: column billing.invoices.total does not exist",
+		"missing-table structs.Payment: no table matches the struct, name it with a TableName method",
+		"missing-table structs.Refund: table billing.refunds does not exist",
+		"type string: not a struct",
+	}
+	if diff := cmp.Diff(want, lines); diff != "" {
+		t.Errorf("Unexpected mismatches (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckStrict(t *testing.T) {
+	type Customer struct {
+		ID        int64
+		CreatedAt time.Time
+	}
+	got := Check([]any{Customer{}}, testInfo(), Options{Strict: true})
+	var columns []string
+	for _, m := range got {
+		if m.Kind != Unmapped {
+			t.Errorf("Unexpected mismatch %s", m)
+		}
+		columns = append(columns, m.Column)
+	}
+	// id has a default and nickname is nullable
+	if diff := cmp.Diff([]string{"email", "tier"}, columns); diff != "" {
+		t.Errorf("Unexpected unmapped columns (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	got := Check([]any{Invoice{}}, testInfo(), Options{Schema: "billing"})
+	if len(got) != 0 {
+		t.Errorf("Expected the billing invoices to match, got %v", got)
+	}
+	got = Check([]any{Invoice{}}, testInfo(), Options{Schema: "sales"})
+	if len(got) != 1 || !strings.Contains(got[0].Message, "several tables match") {
+		t.Errorf("Expected invoices to be ambiguous, got %v", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	type Customers struct {
+		ID    int64
+		Email string
+	}
+	if err := Verify(testInfo(), &Customers{}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	err := Verify(testInfo(), &Customer{})
+	if err == nil || !strings.HasPrefix(err.Error(), "5 mismatches between the structs and the schema:\nstructs.Customer.Email: ") {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"ID":          "id",
+		"UserID":      "user_id",
+		"HTTPRequest": "http_request",
+		"OrderItem":   "order_item",
+		"Address2":    "address2",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
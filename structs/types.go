@@ -0,0 +1,237 @@
+package structs
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Column classes, grouping the column types Go values are read from alike
+const (
+	classInteger = "integer"
+	classNumber  = "number"
+	classBoolean = "boolean"
+	classString  = "string"
+	classBytes   = "bytes"
+	classTime    = "time" // Dates and timestamps
+	classClock   = "clock"
+	classUUID    = "uuid"
+	classJSON    = "json"
+	classArray   = "array"
+)
+
+// columnClasses maps column types, as information_schema names them, to
+// their class. Types missing from it, such as enums and domains, aren't
+// checked.
+var columnClasses = map[string]string{
+	"smallint":                    classInteger,
+	"integer":                     classInteger,
+	"bigint":                      classInteger,
+	"numeric":                     classNumber,
+	"real":                        classNumber,
+	"double precision":            classNumber,
+	"boolean":                     classBoolean,
+	"text":                        classString,
+	"character varying":           classString,
+	"character":                   classString,
+	"name":                        classString,
+	"bytea":                       classBytes,
+	"date":                        classTime,
+	"timestamp without time zone": classTime,
+	"timestamp with time zone":    classTime,
+	"time without time zone":      classClock,
+	"time with time zone":         classClock,
+	"uuid":                        classUUID,
+	"json":                        classJSON,
+	"jsonb":                       classJSON,
+	"ARRAY":                       classArray,
+}
+
+// integerBits are the sizes of the integer column types
+var integerBits = map[string]int{"smallint": 16, "integer": 32, "bigint": 64}
+
+// value describes what a Go type holds
+type value struct {
+	kind     reflect.Kind // Bool, Int, Float64, String, Slice, Map, Struct; Invalid when not checked
+	bits     int          // Size of integers
+	time     bool         // A time.Time
+	bytes    bool         // A []byte, read from any column
+	elem     reflect.Type // Element of slices
+	nullable bool         // Holds NULL, as pointers, slices and sql.Null types do
+}
+
+// describe returns what a field of type t holds. Pointers and structs with
+// a Valid field, such as sql.NullInt64 or pgtype.Text, hold NULL and the
+// value of their first field. Other types implementing sql.Scanner aren't
+// checked.
+func describe(t reflect.Type) value {
+	if t.Kind() == reflect.Pointer {
+		v := describe(t.Elem())
+		v.nullable = true
+		return v
+	}
+	if inner, ok := nullWrapper(t); ok {
+		v := describe(inner)
+		v.nullable = true
+		return v
+	}
+	switch {
+	case t == timeType:
+		return value{kind: reflect.Struct, time: true}
+	case (t.Kind() == reflect.Slice) && t.Elem().Kind() == reflect.Uint8:
+		return value{kind: reflect.Slice, bytes: true, nullable: true}
+	case isValue(t):
+		return value{nullable: true}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64:
+		return value{kind: t.Kind()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value{kind: reflect.Int, bits: t.Bits()}
+	case reflect.Slice:
+		return value{kind: reflect.Slice, elem: t.Elem(), nullable: true}
+	case reflect.Map:
+		return value{kind: reflect.Map, nullable: true}
+	case reflect.Struct:
+		return value{kind: reflect.Struct}
+	}
+	return value{nullable: true}
+}
+
+// nullWrapper returns the type of the value held by nullable structs such
+// as sql.NullString, sql.Null[T] or pgtype.Int8: a first field holding the
+// value and a Valid boolean
+func nullWrapper(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Struct || t.NumField() == 0 {
+		return nil, false
+	}
+	valid, ok := t.FieldByName("Valid")
+	if !ok || valid.Type.Kind() != reflect.Bool {
+		return nil, false
+	}
+	switch {
+	case len(valid.Index) == 1 && valid.Index[0] > 0:
+		return t.Field(0).Type, true
+	case len(valid.Index) == 2 && valid.Index[0] == 0:
+		// Types embedding a sql.Null type, such as null.String, promote
+		// its Valid field
+		return nullWrapper(t.Field(0).Type)
+	}
+	return nil, false
+}
+
+// typeConflict explains why a field holding v can't hold the values of a
+// column, or returns "" when it can. typ is the information_schema type of
+// the column and fullType its formatted type, used for arrays and numerics.
+func typeConflict(v value, typ, fullType string) string {
+	class, known := columnClasses[typ]
+	if !known && strings.HasSuffix(fullType, "[]") {
+		class, known = classArray, true
+	}
+	// Strings and byte slices read the text of any value
+	if !known || v.kind == reflect.Invalid || v.kind == reflect.String || v.bytes {
+		return ""
+	}
+
+	ok := true
+	switch v.kind {
+	case reflect.Int:
+		switch {
+		case class == classNumber && typ == "numeric" && integralNumeric(fullType):
+		case class != classInteger:
+			ok = false
+		case integerBits[typ] > v.bits:
+			return fmt.Sprintf("%d-bit integers can't hold every value of column type %s", v.bits, typ)
+		}
+	case reflect.Float32, reflect.Float64:
+		ok = class == classInteger || class == classNumber
+	case reflect.Bool:
+		ok = class == classBoolean
+	case reflect.Struct:
+		ok = v.time && class == classTime || !v.time && class == classJSON
+	case reflect.Map:
+		ok = class == classJSON
+	case reflect.Slice:
+		switch class {
+		case classJSON:
+		case classArray:
+			if fullType != "" && v.elem != nil {
+				element := strings.TrimSuffix(fullType, "[]")
+				if problem := typeConflict(describe(v.elem), elementType(element), element); problem != "" {
+					return "elements: " + problem
+				}
+			}
+		default:
+			ok = false
+		}
+	}
+	if ok {
+		return ""
+	}
+
+	columnType := fullType
+	if columnType == "" {
+		columnType = typ
+	}
+	return fmt.Sprintf("%s can't hold values of column type %s", v.describe(), columnType)
+}
+
+// describe names the Go values v holds in messages
+func (v value) describe() string {
+	switch {
+	case v.time:
+		return "time.Time"
+	case v.kind == reflect.Int:
+		return "an integer"
+	case v.kind == reflect.Float32 || v.kind == reflect.Float64:
+		return "a float"
+	case v.kind == reflect.Bool:
+		return "a bool"
+	case v.kind == reflect.Slice:
+		return "a slice"
+	case v.kind == reflect.Map:
+		return "a map"
+	}
+	return "a struct"
+}
+
+// integralNumeric reports whether a numeric column, such as numeric(10,0),
+// has no decimals
+func integralNumeric(fullType string) bool {
+	modifiers, ok := strings.CutPrefix(fullType, "numeric(")
+	if !ok {
+		return false
+	}
+	_, scale, found := strings.Cut(strings.TrimSuffix(modifiers, ")"), ",")
+	return !found || scale == "0"
+}
+
+// elementType returns the type of array elements as information_schema
+// would name it, from their formatted type: character varying(20) becomes
+// character varying
+func elementType(fullType string) string {
+	if i := strings.IndexByte(fullType, '('); i >= 0 {
+		rest := fullType[i:]
+		fullType = fullType[:i]
+		// Keep words following the modifiers, as in timestamp(3) with time zone
+		if j := strings.IndexByte(rest, ')'); j >= 0 {
+			fullType += rest[j+1:]
+		}
+	}
+	return strings.TrimSpace(fullType)
+}
+
+// characterLength returns the maximum length of a character varying(n) or
+// character(n) column
+func characterLength(fullType string) (int, bool) {
+	for _, prefix := range []string{"character varying(", "character("} {
+		if rest, ok := strings.CutPrefix(fullType, prefix); ok {
+			n, err := strconv.Atoi(strings.TrimSuffix(rest, ")"))
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
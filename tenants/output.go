@@ -0,0 +1,101 @@
+package tenants
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/guillermo/dbinfo/diff"
+)
+
+// listedTenants is the number of tenants named for a deviation, the others
+// are counted
+const listedTenants = 5
+
+// summary returns the result of the comparison in one line
+func (r *Report) summary() string {
+	return fmt.Sprintf("%d tenants compared with %s (%d tables): %d conform, %d drifted",
+		len(r.Tenants), r.Reference, r.Tables, len(r.Tenants)-len(r.Drifted()), len(r.Drifted()))
+}
+
+// changeSummary counts the changes of a tenant and the breaking ones
+func (t *Tenant) changeSummary() string {
+	breaking := 0
+	for _, change := range t.Changes {
+		if change.Breaking {
+			breaking++
+		}
+	}
+	if len(t.Changes) == 1 {
+		return fmt.Sprintf("1 change, %d breaking", breaking)
+	}
+	return fmt.Sprintf("%d changes, %d breaking", len(t.Changes), breaking)
+}
+
+// tenantList names the tenants of a deviation, counting those past
+// listedTenants
+func (d *Deviation) tenantList() string {
+	if len(d.Tenants) <= listedTenants {
+		return strings.Join(d.Tenants, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(d.Tenants[:listedTenants], ", "), len(d.Tenants)-listedTenants)
+}
+
+// WriteText writes the changes of each drifted tenant to w, followed by the
+// deviations shared by several tenants
+func WriteText(w io.Writer, r *Report) error {
+	fmt.Fprintln(w, r.summary())
+	for _, t := range r.Drifted() {
+		fmt.Fprintf(w, "\n%s: %s\n", t.Schema, t.changeSummary())
+		for _, change := range t.Changes {
+			fmt.Fprintf(w, "  %s\n", change)
+		}
+	}
+	if len(r.Deviations) > 0 {
+		fmt.Fprintf(w, "\nDEVIATIONS\n")
+		for _, d := range r.Deviations {
+			fmt.Fprintf(w, "%s\n  in %d: %s\n", d.Change, len(d.Tenants), d.tenantList())
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report to w as JSON
+func WriteJSON(w io.Writer, r *Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the report to w as a Markdown document about the
+// database name
+func WriteMarkdown(w io.Writer, name string, r *Report) error {
+	fmt.Fprintf(w, "# Tenant conformance of %s\n\n", name)
+	fmt.Fprintf(w, "%s.\n", r.summary())
+
+	if len(r.Deviations) > 0 {
+		fmt.Fprintf(w, "\n## Deviations\n\n")
+		fmt.Fprintln(w, "| Change | Breaking | Tenants |")
+		fmt.Fprintln(w, "|---|---|---|")
+		for _, d := range r.Deviations {
+			fmt.Fprintf(w, "| `%s` | %s | %d: %s |\n", d.Change, yesNo(d.Change), len(d.Tenants), d.tenantList())
+		}
+	}
+
+	for _, t := range r.Drifted() {
+		fmt.Fprintf(w, "\n## %s\n\n%s.\n\n", t.Schema, t.changeSummary())
+		for _, change := range t.Changes {
+			fmt.Fprintf(w, "- `%s`\n", change)
+		}
+	}
+	return nil
+}
+
+// yesNo tells whether a change is breaking
+func yesNo(change *diff.Change) string {
+	if change.Breaking {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,170 @@
+// Package tenants checks that the schemas of a schema-per-tenant database
+// conform to a reference schema, and reports the deviations of each tenant:
+// the tables, columns, indexes and foreign keys a tenant lacks, has in
+// excess or defines differently.
+//
+// Each tenant is compared with the reference as if it had the tenant's
+// name, so foreign keys between the tables of a schema compare equal across
+// tenants, and foreign keys to shared schemas must point to the same table.
+package tenants
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+)
+
+// Options select the schemas to compare
+type Options struct {
+	// Reference is the schema the tenants should look like, such as a
+	// template schema new tenants are copied from
+	Reference string
+	// Tenants are path.Match patterns of the tenant schemas, such as
+	// tenant_*. Every schema but the reference when empty.
+	Tenants []string
+}
+
+// Tenant is the comparison of a tenant schema with the reference
+type Tenant struct {
+	Schema string `json:"schema"`
+	// Changes go from the reference to the tenant: an added column is a
+	// column only the tenant has
+	Changes []*diff.Change `json:"changes"`
+}
+
+// Conforms reports whether the tenant matches the reference
+func (t *Tenant) Conforms() bool {
+	return len(t.Changes) == 0
+}
+
+// Deviation is a change found in several tenants
+type Deviation struct {
+	Change  *diff.Change `json:"change"` // With the schema of the reference
+	Tenants []string     `json:"tenants"`
+}
+
+// Report is the conformance of the tenants with the reference
+type Report struct {
+	Reference string    `json:"reference"`
+	Tables    int       `json:"tables"` // Tables of the reference
+	Tenants   []*Tenant `json:"tenants"`
+	// Deviations group the changes of the tenants, most widespread first
+	Deviations []*Deviation `json:"deviations"`
+}
+
+// Drifted returns the tenants that don't conform to the reference
+func (r *Report) Drifted() []*Tenant {
+	var drifted []*Tenant
+	for _, t := range r.Tenants {
+		if !t.Conforms() {
+			drifted = append(drifted, t)
+		}
+	}
+	return drifted
+}
+
+// Validate checks that the reference is set and the tenant patterns are
+// well formed
+func (o Options) Validate() error {
+	if o.Reference == "" {
+		return fmt.Errorf("missing reference schema")
+	}
+	for _, pattern := range o.Tenants {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tenant pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// tenant reports whether schema is a tenant to compare
+func (o Options) tenant(schema string) bool {
+	if schema == o.Reference {
+		return false
+	}
+	if len(o.Tenants) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(o.Tenants, func(pattern string) bool {
+		match, _ := path.Match(pattern, schema)
+		return match
+	})
+}
+
+// Compare compares the tenant schemas of info with the reference schema,
+// sorted by schema name. It fails when the reference has no tables.
+func Compare(info *dbinfo.DBInfo, opts Options) (*Report, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var schemas []string
+	tables := 0
+	for _, table := range info.Tables {
+		if table.Schema == opts.Reference {
+			tables++
+		} else if opts.tenant(table.Schema) && !slices.Contains(schemas, table.Schema) {
+			schemas = append(schemas, table.Schema)
+		}
+	}
+	if tables == 0 {
+		return nil, fmt.Errorf("reference schema %s has no tables", opts.Reference)
+	}
+	slices.Sort(schemas)
+
+	r := &Report{Reference: opts.Reference, Tables: tables, Tenants: []*Tenant{}, Deviations: []*Deviation{}}
+	for _, schema := range schemas {
+		changes := diff.Compare(schemaAs(info, opts.Reference, schema), schemaAs(info, schema, schema))
+		if changes == nil {
+			changes = []*diff.Change{}
+		}
+		r.Tenants = append(r.Tenants, &Tenant{Schema: schema, Changes: changes})
+	}
+	r.group()
+	return r, nil
+}
+
+// schemaAs returns the tables of schema renamed to the schema name, with
+// the foreign keys between them following the rename
+func schemaAs(info *dbinfo.DBInfo, schema, name string) *dbinfo.DBInfo {
+	result := &dbinfo.DBInfo{Name: info.Name}
+	for _, table := range info.Tables {
+		if table.Schema != schema {
+			continue
+		}
+		table = table.Clone()
+		table.Schema = name
+		for _, fk := range table.ForeignKeys {
+			if fk.RefTableSchema == schema {
+				fk.RefTableSchema = name
+			}
+		}
+		result.Tables = append(result.Tables, table)
+	}
+	return result
+}
+
+// group lists the changes of the tenants as deviations from the reference,
+// the ones shared by the most tenants first
+func (r *Report) group() {
+	byChange := make(map[diff.Change]*Deviation)
+	for _, t := range r.Tenants {
+		for _, change := range t.Changes {
+			key := *change
+			key.Schema = r.Reference
+			d := byChange[key]
+			if d == nil {
+				d = &Deviation{Change: &key}
+				byChange[key] = d
+				r.Deviations = append(r.Deviations, d)
+			}
+			d.Tenants = append(d.Tenants, t.Schema)
+		}
+	}
+	slices.SortStableFunc(r.Deviations, func(a, b *Deviation) int {
+		return cmp.Compare(len(b.Tenants), len(a.Tenants))
+	})
+}
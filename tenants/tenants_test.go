@@ -0,0 +1,126 @@
+package tenants
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+)
+
+// tenantTables returns the tables of a tenant schema, orders referencing
+// customers in the same schema and currencies in the shared schema
+func tenantTables(schema string) []*dbinfo.Table {
+	return []*dbinfo.Table{
+		{Schema: schema, Name: "customers", Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true},
+			{Name: "email", Type: "text", FullType: "text"},
+		}},
+		{Schema: schema, Name: "orders", Columns: []*dbinfo.Column{
+			{Name: "id", Type: "integer", FullType: "integer", IsPrimaryKey: true},
+			{Name: "customer_id", Type: "integer", FullType: "integer"},
+		}, ForeignKeys: []*dbinfo.ForeignKey{
+			{Name: "orders_customer_id_fkey", ColumnNames: []string{"customer_id"}, RefTableSchema: schema, RefTableName: "customers", RefColumnNames: []string{"id"}},
+			{Name: "orders_currency_fkey", ColumnNames: []string{"currency"}, RefTableSchema: "shared", RefTableName: "currencies", RefColumnNames: []string{"code"}},
+		}},
+	}
+}
+
+func testInfo() *dbinfo.DBInfo {
+	info := &dbinfo.DBInfo{Name: "saas"}
+	for _, schema := range []string{"template", "tenant_1", "tenant_2", "tenant_3"} {
+		info.Tables = append(info.Tables, tenantTables(schema)...)
+	}
+	info.Tables = append(info.Tables, &dbinfo.Table{Schema: "shared", Name: "currencies"})
+
+	// tenant_2 lacks an index the others have, tenant_3 also added a column
+	for _, table := range info.Tables {
+		if table.Name == "customers" && table.Schema != "tenant_2" {
+			table.Indexes = []*dbinfo.Index{{Name: "customers_email_key", Unique: true, Columns: []string{"email"}}}
+		}
+		if table.Name == "orders" && table.Schema == "tenant_3" {
+			table.Columns = append(table.Columns, &dbinfo.Column{Name: "notes", Type: "text", FullType: "text", IsNullable: true})
+		}
+	}
+	return info
+}
+
+func TestCompare(t *testing.T) {
+	r, err := Compare(testInfo(), Options{Reference: "template", Tenants: []string{"tenant_*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conforming []string
+	for _, tenant := range r.Tenants {
+		if tenant.Conforms() {
+			conforming = append(conforming, tenant.Schema)
+		}
+	}
+	if diff := cmp.Diff([]string{"tenant_1"}, conforming); diff != "" {
+		t.Errorf("conforming tenants (-want +got):\n%s", diff)
+	}
+
+	want := []*Deviation{
+		{Change: &diff.Change{Type: diff.Removed, Kind: diff.KindIndex, Schema: "template", Table: "customers", Name: "customers_email_key"}, Tenants: []string{"tenant_2"}},
+		{Change: &diff.Change{Type: diff.Added, Kind: diff.KindColumn, Schema: "template", Table: "orders", Name: "notes"}, Tenants: []string{"tenant_3"}},
+	}
+	if diff := cmp.Diff(want, r.Deviations); diff != "" {
+		t.Errorf("deviations (-want +got):\n%s", diff)
+	}
+	if got := r.Tenants[2].Changes[0].Path(); got != "tenant_3.orders.notes" {
+		t.Errorf("Expected the changes of a tenant to name its schema, got %s", got)
+	}
+
+	// Without patterns every other schema is a tenant
+	if r, err = Compare(testInfo(), Options{Reference: "template"}); err != nil || len(r.Tenants) != 4 {
+		t.Errorf("Expected 4 tenants including shared, got %v, %v", r, err)
+	}
+	if _, err := Compare(testInfo(), Options{Reference: "missing"}); err == nil {
+		t.Error("Expected an error for a reference without tables")
+	}
+	if _, err := Compare(testInfo(), Options{Reference: "template", Tenants: []string{"["}}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r, err := Compare(testInfo(), Options{Reference: "template", Tenants: []string{"tenant_*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"3 tenants compared with template (2 tables): 1 conform, 2 drifted\n",
+		"\ntenant_2: 1 change, 0 breaking\n  - index tenant_2.customers.customers_email_key\n",
+		"DEVIATIONS\n- index template.customers.customers_email_key\n  in 1: tenant_2\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the text output:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := WriteMarkdown(&buf, "saas", r); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Tenant conformance of saas\n",
+		"| `+ column template.orders.notes` | no | 1: tenant_3 |\n",
+		"## tenant_3\n\n1 change, 0 breaking.\n\n- `+ column tenant_3.orders.notes`\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the Markdown output:\n%s", want, buf.String())
+		}
+	}
+
+	d := &Deviation{Tenants: []string{"a", "b", "c", "d", "e", "f", "g"}}
+	if got := d.tenantList(); got != "a, b, c, d, e and 2 more" {
+		t.Errorf("Unexpected tenant list %q", got)
+	}
+}
@@ -0,0 +1,236 @@
+// Package terraform generates Terraform configuration for the PostgreSQL
+// provider (cyrilgdn/postgresql) from a dbinfo schema, so existing databases
+// can be brought under infrastructure as code.
+//
+// Schemas become postgresql_schema resources, with import blocks adopting
+// the existing schemas on the first apply. The provider has no table
+// resource, so each table becomes a terraform_data resource holding the DDL
+// that creates it, with the foreign keys in a last resource depending on
+// every table. Applying them only records the DDL in the state, where plans
+// show changes to it; a local-exec provisioner can be added to run it
+// against new databases. The grants of snapshots taken with privileges
+// become postgresql_grant resources.
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/ddl"
+)
+
+// Generate writes the Terraform configuration of the schemas and tables in
+// info to w, with the grants on the tables and their columns. The
+// privileges owners hold on their tables need no grant and are left out.
+func Generate(w io.Writer, info *dbinfo.DBInfo) error {
+	var b strings.Builder
+	b.WriteString(`terraform {
+  required_providers {
+    postgresql = {
+      source = "cyrilgdn/postgresql"
+    }
+  }
+}
+`)
+
+	schemas := make(map[string]string)
+	schemaNames := namer{}
+	for _, table := range info.Tables {
+		if _, ok := schemas[table.Schema]; ok {
+			continue
+		}
+		name := schemaNames.unique(table.Schema)
+		schemas[table.Schema] = name
+		fmt.Fprintf(&b, "\nresource \"postgresql_schema\" %s {\n", quote(name))
+		if info.Name != "" {
+			fmt.Fprintf(&b, "  database = %s\n", quote(info.Name))
+			fmt.Fprintf(&b, "  name     = %s\n", quote(table.Schema))
+		} else {
+			fmt.Fprintf(&b, "  name = %s\n", quote(table.Schema))
+		}
+		b.WriteString("}\n")
+
+		// The provider imports schemas by database and name
+		id := table.Schema
+		if info.Name != "" {
+			id = info.Name + "." + table.Schema
+		}
+		fmt.Fprintf(&b, "\nimport {\n  to = postgresql_schema.%s\n  id = %s\n}\n", name, quote(id))
+	}
+
+	tableNames := namer{}
+	grantNames := namer{}
+	var tables []string
+	var foreignKeys strings.Builder
+	for _, table := range info.Tables {
+		name := tableNames.unique(table.Schema + "_" + table.Name)
+		tables = append(tables, "terraform_data."+name)
+		fmt.Fprintf(&b, "\nresource \"terraform_data\" %s {\n", quote(name))
+		fmt.Fprintf(&b, "  depends_on = [postgresql_schema.%s]\n\n", schemas[table.Schema])
+		fmt.Fprintf(&b, "  input = %s\n}\n", heredoc(ddl.Table(table)))
+		writeGrants(&b, info.Name, table, "terraform_data."+name, grantNames)
+
+		for _, fk := range table.ForeignKeys {
+			foreignKeys.WriteString(ddl.ForeignKey(table, fk))
+			foreignKeys.WriteString(";\n")
+		}
+	}
+
+	if foreignKeys.Len() > 0 {
+		name := tableNames.unique("foreign_keys")
+		fmt.Fprintf(&b, "\nresource \"terraform_data\" %s {\n  depends_on = [\n", quote(name))
+		for _, table := range tables {
+			fmt.Fprintf(&b, "    %s,\n", table)
+		}
+		fmt.Fprintf(&b, "  ]\n\n  input = %s\n}\n", heredoc(foreignKeys.String()))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// grant is a postgresql_grant resource: privileges a role holds on a table,
+// or on some of its columns, with or without the grant option
+type grant struct {
+	role       string
+	grantable  bool
+	columns    []string // Only for column grants
+	privileges []string
+}
+
+// writeGrants writes the grants on table, other than those of its owner, as
+// postgresql_grant resources depending on the resource creating the table.
+// The grants of a role on a table share a resource, as long as they agree
+// on the grant option; column grants share one per privilege.
+func writeGrants(b *strings.Builder, database string, table *dbinfo.Table, dependsOn string, names namer) {
+	var grants, columnGrants []*grant
+	for _, g := range table.Grants {
+		if g.Role == table.Owner {
+			continue
+		}
+		i := slices.IndexFunc(grants, func(t *grant) bool { return t.role == g.Role && t.grantable == g.Grantable })
+		if i < 0 {
+			i = len(grants)
+			grants = append(grants, &grant{role: g.Role, grantable: g.Grantable})
+		}
+		grants[i].privileges = append(grants[i].privileges, g.Privilege)
+	}
+	for _, column := range table.Columns {
+		for _, g := range column.Grants {
+			if g.Role == table.Owner {
+				continue
+			}
+			i := slices.IndexFunc(columnGrants, func(c *grant) bool {
+				return c.role == g.Role && c.grantable == g.Grantable && c.privileges[0] == g.Privilege
+			})
+			if i < 0 {
+				i = len(columnGrants)
+				columnGrants = append(columnGrants, &grant{role: g.Role, grantable: g.Grantable, privileges: []string{g.Privilege}})
+			}
+			columnGrants[i].columns = append(columnGrants[i].columns, column.Name)
+		}
+	}
+	grants = append(grants, columnGrants...)
+
+	for _, g := range grants {
+		role := g.role
+		if role == dbinfo.PublicRole {
+			role = "public"
+		}
+		objectType := "table"
+		if len(g.columns) > 0 {
+			objectType = "column"
+		}
+		var attributes [][2]string
+		if database != "" {
+			attributes = append(attributes, [2]string{"database", quote(database)})
+		}
+		attributes = append(attributes,
+			[2]string{"role", quote(role)},
+			[2]string{"schema", quote(table.Schema)},
+			[2]string{"object_type", quote(objectType)},
+			[2]string{"objects", list([]string{table.Name})},
+		)
+		if len(g.columns) > 0 {
+			attributes = append(attributes, [2]string{"columns", list(g.columns)})
+		}
+		attributes = append(attributes, [2]string{"privileges", list(g.privileges)})
+		if g.grantable {
+			attributes = append(attributes, [2]string{"with_grant_option", "true"})
+		}
+
+		name := names.unique(table.Schema + "_" + table.Name + "_" + role)
+		fmt.Fprintf(b, "\nresource \"postgresql_grant\" %s {\n", quote(name))
+		fmt.Fprintf(b, "  depends_on = [%s]\n\n", dependsOn)
+		width := 0
+		for _, attribute := range attributes {
+			width = max(width, len(attribute[0]))
+		}
+		for _, attribute := range attributes {
+			fmt.Fprintf(b, "  %-*s = %s\n", width, attribute[0], attribute[1])
+		}
+		b.WriteString("}\n")
+	}
+}
+
+// list returns values as a list of quoted strings
+func list(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// unsafeChars matches the characters Terraform doesn't accept in names
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// namer hands out resource names, unique among those of a resource type
+type namer map[string]bool
+
+// unique returns base as a valid resource name, with a numeric suffix when
+// it is taken
+func (n namer) unique(base string) string {
+	base = unsafeChars.ReplaceAllString(base, "_")
+	if base == "" || base[0] == '-' || (base[0] >= '0' && base[0] <= '9') {
+		base = "_" + base
+	}
+	name := base
+	for i := 2; n[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	n[name] = true
+	return name
+}
+
+// templateEscaper escapes the template sequences that strings and heredocs
+// would otherwise interpolate
+var templateEscaper = strings.NewReplacer("${", "$${", "%{", "%%{")
+
+// stringEscaper escapes quoted strings
+var stringEscaper = strings.NewReplacer(
+	`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{",
+)
+
+// quote returns value as a quoted string
+func quote(value string) string {
+	return `"` + stringEscaper.Replace(value) + `"`
+}
+
+// heredoc returns text, ending with a newline, as a heredoc string whose
+// delimiter doesn't appear as a line of text
+func heredoc(text string) string {
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+	delimiter := "SQL"
+	for i := 2; lines[delimiter]; i++ {
+		delimiter = fmt.Sprintf("SQL%d", i)
+	}
+	return "<<" + delimiter + "\n" + templateEscaper.Replace(text) + delimiter
+}
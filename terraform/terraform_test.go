@@ -0,0 +1,220 @@
+package terraform
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/guillermo/dbinfo"
+)
+
+func testInfo() *dbinfo.DBInfo {
+	return &dbinfo.DBInfo{
+		Name: "shop",
+		Tables: []*dbinfo.Table{
+			{
+				Schema:  "billing",
+				Name:    "customers",
+				Comment: "Billed ${customer}",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "email", Type: "text", IsNullable: true},
+				},
+				Indexes: []*dbinfo.Index{{Name: "customers_email_key", Unique: true, Columns: []string{"email"}}},
+			},
+			{
+				Schema: "public",
+				Name:   "orders",
+				Columns: []*dbinfo.Column{
+					{Name: "id", Type: "integer", IsPrimaryKey: true},
+					{Name: "customer_id", Type: "integer", IsNullable: true},
+				},
+				ForeignKeys: []*dbinfo.ForeignKey{{
+					Name:           "orders_customer_id_fkey",
+					ColumnNames:    []string{"customer_id"},
+					RefTableSchema: "billing",
+					RefTableName:   "customers",
+					RefColumnNames: []string{"id"},
+				}},
+			},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Generate(&buf, testInfo()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `terraform {
+  required_providers {
+    postgresql = {
+      source = "cyrilgdn/postgresql"
+    }
+  }
+}
+
+resource "postgresql_schema" "billing" {
+  database = "shop"
+  name     = "billing"
+}
+
+import {
+  to = postgresql_schema.billing
+  id = "shop.billing"
+}
+
+resource "postgresql_schema" "public" {
+  database = "shop"
+  name     = "public"
+}
+
+import {
+  to = postgresql_schema.public
+  id = "shop.public"
+}
+
+resource "terraform_data" "billing_customers" {
+  depends_on = [postgresql_schema.billing]
+
+  input = <<SQL
+CREATE TABLE billing.customers (
+    id integer NOT NULL,
+    email text,
+    PRIMARY KEY (id)
+);
+COMMENT ON TABLE billing.customers IS 'Billed $${customer}';
+CREATE UNIQUE INDEX customers_email_key ON billing.customers (email);
+SQL
+}
+
+resource "terraform_data" "public_orders" {
+  depends_on = [postgresql_schema.public]
+
+  input = <<SQL
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    customer_id integer,
+    PRIMARY KEY (id)
+);
+SQL
+}
+
+resource "terraform_data" "foreign_keys" {
+  depends_on = [
+    terraform_data.billing_customers,
+    terraform_data.public_orders,
+  ]
+
+  input = <<SQL
+ALTER TABLE public.orders ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES billing.customers (id);
+SQL
+}
+`
+	if diff := cmp.Diff(expected, buf.String()); diff != "" {
+		t.Errorf("Unexpected configuration (-expected +actual):\n%s", diff)
+	}
+}
+
+func TestNames(t *testing.T) {
+	info := &dbinfo.DBInfo{Tables: []*dbinfo.Table{
+		{Schema: "2024 data", Name: "a b"},
+		{Schema: "2024 data", Name: "a_b"},
+	}}
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`resource "postgresql_schema" "_2024_data" {` + "\n" + `  name = "2024 data"`,
+		`id = "2024 data"`,
+		`resource "terraform_data" "_2024_data_a_b" {`,
+		`resource "terraform_data" "_2024_data_a_b_2" {`,
+		"depends_on = [postgresql_schema._2024_data]",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in\n%s", expected, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "foreign_keys") {
+		t.Error("Expected no foreign keys resource without foreign keys")
+	}
+}
+
+func TestGrants(t *testing.T) {
+	info := testInfo()
+	customers := info.Tables[0]
+	customers.Owner = "billing"
+	customers.Grants = []*dbinfo.Grant{
+		{Role: "PUBLIC", Privilege: "SELECT"},
+		{Role: "billing", Privilege: "SELECT", Grantable: true},
+		{Role: "clerk", Privilege: "INSERT"},
+		{Role: "clerk", Privilege: "SELECT"},
+		{Role: "manager", Privilege: "DELETE", Grantable: true},
+	}
+	customers.Columns[0].Grants = []*dbinfo.Grant{{Role: "support", Privilege: "SELECT"}}
+	customers.Columns[1].Grants = []*dbinfo.Grant{{Role: "support", Privilege: "SELECT"}, {Role: "support", Privilege: "UPDATE"}}
+
+	var buf bytes.Buffer
+	if err := Generate(&buf, info); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`resource "postgresql_grant" "billing_customers_public" {
+  depends_on = [terraform_data.billing_customers]
+
+  database    = "shop"
+  role        = "public"
+  schema      = "billing"
+  object_type = "table"
+  objects     = ["customers"]
+  privileges  = ["SELECT"]
+}`,
+		`  role        = "clerk"
+  schema      = "billing"
+  object_type = "table"
+  objects     = ["customers"]
+  privileges  = ["INSERT", "SELECT"]
+}`,
+		`  privileges        = ["DELETE"]
+  with_grant_option = true
+}`,
+		`resource "postgresql_grant" "billing_customers_support" {
+  depends_on = [terraform_data.billing_customers]
+
+  database    = "shop"
+  role        = "support"
+  schema      = "billing"
+  object_type = "column"
+  objects     = ["customers"]
+  columns     = ["id", "email"]
+  privileges  = ["SELECT"]
+}`,
+		`  columns     = ["email"]
+  privileges  = ["UPDATE"]`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in\n%s", expected, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), `role        = "billing"`) {
+		t.Errorf("Expected no grant to the owner, got\n%s", buf.String())
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	tests := []struct {
+		text, expected string
+	}{
+		{"SELECT 1;\n", "<<SQL\nSELECT 1;\nSQL"},
+		{"SELECT '\nSQL\n';\n", "<<SQL2\nSELECT '\nSQL\n';\nSQL2"},
+		{"SELECT '%{if}';\n", "<<SQL\nSELECT '%%{if}';\nSQL"},
+	}
+	for _, test := range tests {
+		if actual := heredoc(test.text); actual != test.expected {
+			t.Errorf("heredoc(%q) = %q, expected %q", test.text, actual, test.expected)
+		}
+	}
+}
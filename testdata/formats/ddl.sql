@@ -0,0 +1,75 @@
+CREATE TABLE public.categories (
+    id integer NOT NULL DEFAULT nextval('categories_id_seq'::regclass),
+    name character varying(100) NOT NULL,
+    description text,
+    created_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT categories_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE public.categories IS 'Product categories';
+COMMENT ON COLUMN public.categories.name IS 'Category name';
+
+CREATE TABLE public.customers (
+    id integer NOT NULL DEFAULT nextval('customers_id_seq'::regclass),
+    email character varying(255) NOT NULL,
+    first_name character varying(100) NOT NULL,
+    last_name character varying(100) NOT NULL,
+    phone character varying(20),
+    address text,
+    created_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT customers_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE public.customers IS 'Customer information';
+CREATE UNIQUE INDEX customers_email_key ON public.customers (email);
+
+CREATE TABLE public.order_items (
+    id integer NOT NULL DEFAULT nextval('order_items_id_seq'::regclass),
+    order_id integer NOT NULL,
+    product_id integer NOT NULL,
+    quantity integer NOT NULL,
+    unit_price numeric(10,2) NOT NULL,
+    subtotal numeric(10,2) NOT NULL,
+    CONSTRAINT order_items_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE public.order_items IS 'Individual items within an order';
+CREATE INDEX idx_order_items_order_id ON public.order_items (order_id);
+CREATE INDEX idx_order_items_product_id ON public.order_items (product_id);
+CREATE UNIQUE INDEX order_items_order_id_product_id_key ON public.order_items (order_id, product_id);
+
+CREATE TABLE public.orders (
+    id integer NOT NULL DEFAULT nextval('orders_id_seq'::regclass),
+    customer_id integer NOT NULL,
+    order_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status character varying(20) NOT NULL DEFAULT 'pending'::character varying,
+    total_amount numeric(10,2) NOT NULL,
+    shipping_address text,
+    tracking_number character varying(100),
+    notes text,
+    CONSTRAINT orders_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE public.orders IS 'Customer orders';
+CREATE INDEX idx_orders_customer_id ON public.orders (customer_id);
+CREATE INDEX idx_orders_date ON public.orders (order_date);
+
+CREATE TABLE public.products (
+    id integer NOT NULL DEFAULT nextval('products_id_seq'::regclass),
+    category_id integer NOT NULL,
+    name character varying(255) NOT NULL,
+    price numeric(10,2) NOT NULL,
+    description text,
+    sku character varying(50),
+    stock_quantity integer NOT NULL DEFAULT 0,
+    is_active boolean NOT NULL DEFAULT true,
+    created_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp without time zone,
+    CONSTRAINT products_pkey PRIMARY KEY (id)
+);
+COMMENT ON TABLE public.products IS 'Available products';
+CREATE INDEX idx_products_category ON public.products (category_id);
+CREATE INDEX idx_products_name ON public.products (name);
+CREATE UNIQUE INDEX idx_products_sku ON public.products (sku);
+CREATE UNIQUE INDEX products_sku_key ON public.products (sku);
+
+ALTER TABLE public.order_items ADD CONSTRAINT order_items_order_id_fkey FOREIGN KEY (order_id) REFERENCES public.orders (id) ON DELETE CASCADE;
+ALTER TABLE public.order_items ADD CONSTRAINT order_items_product_id_fkey FOREIGN KEY (product_id) REFERENCES public.products (id) ON DELETE RESTRICT;
+ALTER TABLE public.orders ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES public.customers (id);
+ALTER TABLE public.products ADD CONSTRAINT products_category_id_fkey FOREIGN KEY (category_id) REFERENCES public.categories (id) ON DELETE CASCADE;
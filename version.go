@@ -0,0 +1,78 @@
+package dbinfo
+
+import (
+	"runtime/debug"
+)
+
+// Range of PostgreSQL major versions dbinfo is tested against
+const (
+	MinPostgresVersion = 12
+	MaxPostgresVersion = 17
+)
+
+const modulePath = "github.com/guillermo/dbinfo"
+
+// version and commit can be set at build time with
+// -ldflags "-X github.com/guillermo/dbinfo.version=v1.2.3 -X github.com/guillermo/dbinfo.commit=abc123".
+// Otherwise they are read from the build information embedded by the Go
+// toolchain.
+var (
+	version string
+	commit  string
+)
+
+// Version returns the version of the dbinfo module, or "(devel)" when it is
+// built from a source checkout
+func Version() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath && info.Main.Version != "" {
+			return info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				return dep.Version
+			}
+		}
+	}
+	return "(devel)"
+}
+
+// Commit returns the VCS revision dbinfo was built from, or an empty string
+// when it is unknown. A "+dirty" suffix marks builds with local changes.
+func Commit() string {
+	if commit != "" {
+		return commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified {
+		revision += "+dirty"
+	}
+	return revision
+}
+
+// Generator returns the string identifying this dbinfo build in snapshots
+func Generator() string {
+	if c := Commit(); c != "" {
+		return "dbinfo " + Version() + " (" + c + ")"
+	}
+	return "dbinfo " + Version()
+}
@@ -0,0 +1,233 @@
+package dbinfo
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// View is a view. Materialized views are a MaterializedView.
+type View struct {
+	Name   string `json:"name" yaml:"name"`
+	Schema string `json:"schema" yaml:"schema"`
+	// Definition is the SELECT statement of the view, as pg_get_viewdef
+	// prints it
+	Definition string    `json:"definition" yaml:"definition"`
+	Columns    []*Column `json:"columns" yaml:"columns"` // Output columns, always nullable
+	Comment    string    `json:"comment,omitempty" yaml:"comment,omitempty"`
+	// Updatable tells whether UPDATE and DELETE work on the view, as
+	// information_schema.views.is_updatable reports it
+	Updatable bool `json:"updatable" yaml:"updatable"`
+	// Sources are the tables and views the view reads, with the columns it
+	// reads from each, as recorded in the dependencies of the view
+	Sources []*ViewSource `json:"sources,omitempty" yaml:"sources,omitempty"`
+	Owner   string        `json:"owner,omitempty" yaml:"owner,omitempty"`   // As in Table
+	Grants  []*Grant      `json:"grants,omitempty" yaml:"grants,omitempty"` // As in Table
+	Rules   []*Rule       `json:"rules,omitempty" yaml:"rules,omitempty"`   // As in Table, such as the INSERT of an updatable view
+}
+
+// ViewSource is a table, view or materialized view read by a view
+type ViewSource struct {
+	Schema  string   `json:"schema" yaml:"schema"`
+	Name    string   `json:"name" yaml:"name"`
+	Columns []string `json:"columns" yaml:"columns"` // Sorted by name, empty when the view only reads whole rows
+}
+
+// QualifiedName returns the quoted schema.name of the view
+func (v *View) QualifiedName() string {
+	return QualifiedName(v.Schema, v.Name)
+}
+
+// Reads reports whether the view reads the table or view schema.name
+// directly
+func (v *View) Reads(schema, name string) bool {
+	return slices.ContainsFunc(v.Sources, func(s *ViewSource) bool { return s.Schema == schema && s.Name == name })
+}
+
+// viewFilter restricts the views queries to the user schemas included by
+// $1 and not excluded by $2, and leaves out the views of extensions
+const viewFilter = `
+	AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	AND (cardinality($1::text[]) = 0 OR n.nspname = ANY($1::text[]))
+	AND NOT n.nspname = ANY($2::text[])
+	AND NOT EXISTS (
+	    SELECT 1 FROM pg_catalog.pg_depend e
+	    WHERE e.classid = 'pg_catalog.pg_class'::regclass AND e.objid = v.oid AND e.deptype = 'e'
+	)`
+
+// viewsQuery lists the views and materialized views with their definition,
+// and their comments when $3 is set. A view is updatable as
+// information_schema.views computes it.
+const viewsQuery = `
+	SELECT n.nspname, v.relname, v.relkind = 'm', v.relispopulated, pg_catalog.pg_get_viewdef(v.oid),
+	       CASE WHEN $3::boolean THEN pg_catalog.obj_description(v.oid, 'pg_class') END,
+	       v.relkind = 'v' AND (pg_catalog.pg_relation_is_updatable(v.oid, false) & 20) = 20
+	FROM pg_catalog.pg_class v
+	JOIN pg_catalog.pg_namespace n ON n.oid = v.relnamespace
+	WHERE v.relkind IN ('v', 'm')` + viewFilter + `
+	ORDER BY n.nspname, v.relname`
+
+// viewColumnsQuery lists the columns of the views, with their comments when
+// $3 is set. information_schema.columns leaves materialized views out, so
+// the columns are read from pg_attribute, with data_type computed as
+// information_schema does.
+const viewColumnsQuery = `
+	SELECT n.nspname, v.relname, a.attname,
+	       CASE WHEN t.typtype = 'd' THEN
+	                CASE WHEN bt.typelem <> 0 AND bt.typlen = -1 THEN 'ARRAY'
+	                     WHEN nbt.nspname = 'pg_catalog' THEN pg_catalog.format_type(t.typbasetype, NULL)
+	                     ELSE 'USER-DEFINED' END
+	            ELSE
+	                CASE WHEN t.typelem <> 0 AND t.typlen = -1 THEN 'ARRAY'
+	                     WHEN nt.nspname = 'pg_catalog' THEN pg_catalog.format_type(a.atttypid, NULL)
+	                     ELSE 'USER-DEFINED' END
+	       END,
+	       pg_catalog.format_type(a.atttypid, a.atttypmod),
+	       CASE WHEN $3::boolean THEN pg_catalog.col_description(v.oid, a.attnum) END
+	FROM pg_catalog.pg_class v
+	JOIN pg_catalog.pg_namespace n ON n.oid = v.relnamespace
+	JOIN pg_catalog.pg_attribute a ON a.attrelid = v.oid AND a.attnum > 0 AND NOT a.attisdropped
+	JOIN pg_catalog.pg_type t ON t.oid = a.atttypid
+	JOIN pg_catalog.pg_namespace nt ON nt.oid = t.typnamespace
+	LEFT JOIN pg_catalog.pg_type bt ON t.typtype = 'd' AND bt.oid = t.typbasetype
+	LEFT JOIN pg_catalog.pg_namespace nbt ON nbt.oid = bt.typnamespace
+	WHERE v.relkind IN ('v', 'm')` + viewFilter + `
+	ORDER BY n.nspname, v.relname, a.attnum`
+
+// viewSourcesQuery lists the relations each view reads, with the columns
+// it reads, from the dependencies of the rewrite rule of the view. Whole
+// row references depend on the relation with no column.
+const viewSourcesQuery = `
+	SELECT n.nspname, v.relname, sn.nspname, s.relname,
+	       COALESCE(array_agg(DISTINCT a.attname::text) FILTER (WHERE a.attname IS NOT NULL), '{}')
+	FROM pg_catalog.pg_class v
+	JOIN pg_catalog.pg_namespace n ON n.oid = v.relnamespace
+	JOIN pg_catalog.pg_rewrite r ON r.ev_class = v.oid
+	JOIN pg_catalog.pg_depend d ON d.classid = 'pg_catalog.pg_rewrite'::regclass AND d.objid = r.oid
+	     AND d.refclassid = 'pg_catalog.pg_class'::regclass AND d.refobjid <> v.oid
+	JOIN pg_catalog.pg_class s ON s.oid = d.refobjid AND s.relkind IN ('r', 'p', 'v', 'm', 'f')
+	JOIN pg_catalog.pg_namespace sn ON sn.oid = s.relnamespace
+	LEFT JOIN pg_catalog.pg_attribute a ON a.attrelid = s.oid AND a.attnum = d.refobjsubid AND d.refobjsubid > 0
+	WHERE v.relkind IN ('v', 'm')` + viewFilter + `
+	GROUP BY n.nspname, v.relname, sn.nspname, s.relname
+	ORDER BY n.nspname, v.relname, sn.nspname, s.relname`
+
+// getViews reads the views and materialized views of the schemas and
+// tables the options select, sorted by schema and name. The indexes of the
+// materialized views are read by getMaterializedViewIndexes.
+func getViews(ctx context.Context, db DBQuerier, o *options) ([]*View, []*MaterializedView, error) {
+	rows, err := db.Query(ctx, viewsQuery, o.schemas, o.excludeSchemas, !o.skipComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query views: %w", err)
+	}
+	var views []*View
+	var materialized []*MaterializedView
+	// Where the columns and sources of each view go, whatever its type
+	columns := make(map[ObjectKey]*[]*Column)
+	sources := make(map[ObjectKey]*[]*ViewSource)
+	for rows.Next() {
+		var schema, name, definition string
+		var isMaterialized, populated, updatable bool
+		var comment *string // NULL without a comment
+		if err := rows.Scan(&schema, &name, &isMaterialized, &populated, &definition, &comment, &updatable); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan view row: %w", err)
+		}
+		if !o.includeTable(schema, name) {
+			continue
+		}
+		key := ObjectKey{schema, name}
+		definition = strings.TrimSpace(definition)
+		if isMaterialized {
+			view := &MaterializedView{Name: name, Schema: schema, Definition: definition, Populated: populated}
+			if comment != nil {
+				view.Comment = *comment
+			}
+			materialized = append(materialized, view)
+			columns[key], sources[key] = &view.Columns, &view.Sources
+			continue
+		}
+		view := &View{Name: name, Schema: schema, Definition: definition, Updatable: updatable}
+		if comment != nil {
+			view.Comment = *comment
+		}
+		views = append(views, view)
+		columns[key], sources[key] = &view.Columns, &view.Sources
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating view rows: %w", err)
+	}
+
+	rows, err = db.Query(ctx, viewColumnsQuery, o.schemas, o.excludeSchemas, !o.skipComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query view columns: %w", err)
+	}
+	for rows.Next() {
+		var key ObjectKey
+		column := &Column{IsNullable: true, Kind: ColumnPlain}
+		var comment *string
+		if err := rows.Scan(&key.Schema, &key.Name, &column.Name, &column.Type, &column.FullType, &comment); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan view column row: %w", err)
+		}
+		if comment != nil {
+			column.Comment = *comment
+		}
+		if list := columns[key]; list != nil {
+			*list = append(*list, column)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating view column rows: %w", err)
+	}
+
+	rows, err = db.Query(ctx, viewSourcesQuery, o.schemas, o.excludeSchemas)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query view sources: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key ObjectKey
+		source := &ViewSource{}
+		if err := rows.Scan(&key.Schema, &key.Name, &source.Schema, &source.Name, &source.Columns); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan view source row: %w", err)
+		}
+		if list := sources[key]; list != nil {
+			*list = append(*list, source)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating view source rows: %w", err)
+	}
+
+	sortViews(views, materialized)
+	return views, materialized, nil
+}
+
+// sortViews orders views and materialized views by schema and name, and
+// their sources likewise
+func sortViews(views []*View, materialized []*MaterializedView) {
+	slices.SortStableFunc(views, func(a, b *View) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	slices.SortStableFunc(materialized, func(a, b *MaterializedView) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+	for _, view := range views {
+		sortSources(view.Sources)
+	}
+	for _, view := range materialized {
+		sortSources(view.Sources)
+	}
+}
+
+// sortSources orders the sources of a view by schema and name
+func sortSources(sources []*ViewSource) {
+	slices.SortStableFunc(sources, func(a, b *ViewSource) int {
+		return cmp.Or(cmp.Compare(a.Schema, b.Schema), cmp.Compare(a.Name, b.Name))
+	})
+}
@@ -0,0 +1,43 @@
+package dbinfo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Warning records a part of the schema left out because it could not be
+// read, typically a table the role has no privileges on
+type Warning struct {
+	Phase   Phase  `json:"phase" yaml:"phase"`
+	Object  string `json:"object" yaml:"object"` // schema.table that was skipped, in whole or in part
+	Message string `json:"message" yaml:"message"`
+}
+
+// skippable reports whether err only concerns the object being read, so the
+// introspection can go on without it. Connection failures, timeouts and
+// cancellations are never skipped.
+func skippable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "42501", // insufficient_privilege
+		"42P01", // undefined_table, such as a table dropped during the scan
+		"42602", // invalid_name, such as a name a regclass cast can't parse
+		"42704": // undefined_object
+		return true
+	}
+	return false
+}
+
+// warn records that the phase of object was skipped because of err, and
+// logs it when a logger is set
+func (o *options) warn(ctx context.Context, phase Phase, object string, err error) {
+	o.warnings = append(o.warnings, &Warning{Phase: phase, Object: object, Message: err.Error()})
+	if o.logger != nil {
+		o.logger.WarnContext(ctx, "skipped unreadable object", "phase", string(phase), "object", object, "error", err)
+	}
+}
@@ -0,0 +1,143 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/snapshot"
+)
+
+// Source is a database watched by a Monitor
+type Source struct {
+	Name   string // Names the database in events and its file in the state
+	Server string // host:port of the database server in events, optional
+	Load   dbinfo.Loader
+}
+
+// State stores the last known schema of each source in a directory, as a
+// JSON snapshot named after the source, so changes made while the monitor
+// was stopped are reported when it starts again
+type State struct {
+	dir string
+}
+
+// NewState returns the state stored in dir, creating the directory if needed
+func NewState(dir string) (*State, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return &State{dir: dir}, nil
+}
+
+func (s *State) path(name string) string {
+	return filepath.Join(s.dir, url.PathEscape(name)+".json")
+}
+
+// Load returns the last schema saved for the source name, or nil when there
+// is none
+func (s *State) Load(name string) (*dbinfo.DBInfo, error) {
+	info, err := snapshot.LoadFile(s.path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return info, err
+}
+
+// Save replaces the schema saved for the source name. The snapshot is
+// written to a temporary file first, so a crash never leaves it truncated.
+func (s *State) Save(name string, info *dbinfo.DBInfo) error {
+	f, err := os.CreateTemp(s.dir, ".state-*")
+	if err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if err := snapshot.WriteJSON(f, info); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path(name)); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// Monitor polls several databases, compares each schema with the one saved
+// in the state and notifies the changes. Unlike Watch it keeps running when
+// a database can't be loaded or a notification fails: the failure is
+// reported to OnError and the source is retried on the next poll. The state
+// of a source only advances once its changes have been delivered to every
+// notifier, so no change is lost, but notifiers that succeeded get them
+// again.
+type Monitor struct {
+	Sources   []Source
+	State     *State
+	Notifiers []Notifier
+	OnError   func(source string, err error) // Optional
+}
+
+// Run polls the sources right away and then every interval, until ctx is
+// done. It returns ctx.Err().
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks every source once. The first poll of a source without state
+// only saves its schema.
+func (m *Monitor) Poll(ctx context.Context) {
+	for _, source := range m.Sources {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := m.check(ctx, source); err != nil && m.OnError != nil {
+			m.OnError(source.Name, err)
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context, source Source) error {
+	previous, err := m.State.Load(source.Name)
+	if err != nil {
+		return err
+	}
+	current, err := source.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if previous != nil {
+		changes := diff.Compare(previous, current)
+		if len(changes) == 0 {
+			return nil
+		}
+		event := newEvent(previous, current, changes)
+		event.Database, event.Server = source.Name, source.Server
+		var errs []error
+		for _, notifier := range m.Notifiers {
+			errs = append(errs, notifier.Notify(ctx, event))
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+	}
+	return m.State.Save(source.Name, current)
+}
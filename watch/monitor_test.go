@@ -0,0 +1,95 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+)
+
+func TestMonitor(t *testing.T) {
+	state, err := NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tables := []*dbinfo.Table{{Name: "orders", Schema: "public"}}
+	var loadErr error
+	orders := Source{Name: "orders/eu", Load: func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		return &dbinfo.DBInfo{Name: "orders", Tables: tables}, loadErr
+	}}
+
+	var events []*Event
+	var notifyErr error
+	var failures []string
+	m := &Monitor{
+		Sources: []Source{orders},
+		State:   state,
+		Notifiers: []Notifier{NotifierFunc(func(ctx context.Context, event *Event) error {
+			events = append(events, event)
+			return notifyErr
+		})},
+		OnError: func(source string, err error) {
+			failures = append(failures, source+": "+err.Error())
+		},
+	}
+
+	// The first poll only saves the schema
+	m.Poll(context.Background())
+	if saved, err := state.Load("orders/eu"); err != nil || saved == nil || len(saved.Tables) != 1 {
+		t.Fatalf("Expected the schema to be saved, got %v, %v", saved, err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Expected no event on the first poll, got %d", len(events))
+	}
+
+	// A failed notification is retried on the next poll
+	tables = append(tables, &dbinfo.Table{Name: "invoices", Schema: "public"})
+	notifyErr = errors.New("webhook down")
+	m.Poll(context.Background())
+	notifyErr = nil
+	m.Poll(context.Background())
+	m.Poll(context.Background())
+	if len(events) != 2 || events[1].Database != "orders/eu" || events[1].Changes[0].Table != "invoices" {
+		t.Fatalf("Expected the change to be notified twice, got %v", events)
+	}
+
+	// Load failures are reported too
+	loadErr = errors.New("connection refused")
+	m.Poll(context.Background())
+	if len(failures) != 2 || failures[0] != "orders/eu: webhook down" || failures[1] != "orders/eu: connection refused" {
+		t.Errorf("Unexpected failures %q", failures)
+	}
+
+	// A monitor started again compares with the saved state
+	loadErr = nil
+	tables = tables[:1]
+	restarted := &Monitor{Sources: m.Sources, State: state, Notifiers: m.Notifiers}
+	restarted.Poll(context.Background())
+	if len(events) != 3 || events[2].Changes[0].Table != "invoices" || !events[2].Changes[0].Breaking {
+		t.Errorf("Expected the removed table to be notified after a restart, got %v", events)
+	}
+}
+
+func TestMonitorRun(t *testing.T) {
+	state, err := NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var loads int
+	m := &Monitor{Sources: []Source{{Name: "shop", Load: func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		if loads++; loads == 2 {
+			cancel()
+		}
+		return &dbinfo.DBInfo{}, nil
+	}}}, State: state}
+	if err := m.Run(ctx, time.Millisecond); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if loads != 2 {
+		t.Errorf("Expected 2 loads, got %d", loads)
+	}
+}
@@ -0,0 +1,142 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notifier delivers the events of a Monitor
+type Notifier interface {
+	Notify(ctx context.Context, event *Event) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(ctx context.Context, event *Event) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// httpClient sends the webhook requests, bounding how long a slow endpoint
+// holds up a poll
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SecretEnv holds the secret signing webhook payloads
+const SecretEnv = "DBINFO_WEBHOOK_SECRET"
+
+// SignatureHeader carries the signature of a webhook payload: "sha256="
+// followed by the hex HMAC-SHA256 of the request body keyed with the secret
+const SignatureHeader = "X-Dbinfo-Signature-256"
+
+// Webhook returns a notifier posting each event as JSON to endpoint. With a
+// secret, requests are signed in the SignatureHeader header, so receivers
+// can check they come from dbinfo.
+func Webhook(endpoint string, secret []byte) Notifier {
+	return NotifierFunc(func(ctx context.Context, event *Event) error {
+		return post(ctx, endpoint, event, secret)
+	})
+}
+
+// Sign returns the SignatureHeader value of body
+func Sign(body, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature, a SignatureHeader value, signs body with
+// secret. Receivers written in Go can use it to authenticate webhooks.
+func Verify(body, secret []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(body, secret)))
+}
+
+// Slack returns a notifier posting each event as a message to a Slack
+// incoming webhook endpoint
+func Slack(endpoint string) Notifier {
+	return NotifierFunc(func(ctx context.Context, event *Event) error {
+		return post(ctx, endpoint, map[string]string{"text": SlackMessage(event)}, nil)
+	})
+}
+
+// slackMaxChanges is the number of changes listed in a Slack message,
+// keeping it under the length Slack accepts
+const slackMaxChanges = 50
+
+// slackEscaper escapes the characters Slack reserves for its markup
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackMessage returns the text of the Slack message for event: a summary
+// line followed by the changes in a code block
+func SlackMessage(event *Event) string {
+	var b strings.Builder
+	breaking := 0
+	for _, change := range event.Changes {
+		if change.Breaking {
+			breaking++
+		}
+	}
+	noun := "changes"
+	if len(event.Changes) == 1 {
+		noun = "change"
+	}
+	fmt.Fprintf(&b, "%d schema %s in *%s*", len(event.Changes), noun, slackEscaper.Replace(event.Database))
+	if breaking > 0 {
+		fmt.Fprintf(&b, ", %d breaking", breaking)
+	}
+	b.WriteString(":\n```\n")
+	for i, change := range event.Changes {
+		if i == slackMaxChanges {
+			fmt.Fprintf(&b, "… and %d more\n", len(event.Changes)-i)
+			break
+		}
+		b.WriteString(slackEscaper.Replace(change.String()))
+		if change.Breaking {
+			b.WriteString(" (breaking)")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// post sends v as JSON to endpoint, signed when secret isn't empty, failing
+// unless the response is a success. Errors only name the host, since
+// webhook URLs embed secrets.
+func post(ctx context.Context, endpoint string, v any, secret []byte) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(body, secret))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to notify %s: %w", req.URL.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to notify %s: responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guillermo/dbinfo/diff"
+)
+
+func testEvent() *Event {
+	return &Event{
+		Time:     time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Database: "shop",
+		Changes: []*diff.Change{
+			{Type: diff.Added, Kind: diff.KindTable, Schema: "public", Table: "invoices"},
+			{Type: diff.Removed, Kind: diff.KindColumn, Schema: "public", Table: "orders", Name: "total", Breaking: true},
+		},
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected content type %q", r.Header.Get("Content-Type"))
+		}
+	}))
+	defer srv.Close()
+
+	if err := Webhook(srv.URL, nil).Notify(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if signature != "" {
+		t.Errorf("Expected no signature without a secret, got %q", signature)
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Database != "shop" || len(event.Changes) != 2 || !event.Time.Equal(testEvent().Time) {
+		t.Errorf("Unexpected event %s", body)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var body []byte
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+
+	secret := []byte("s3cret")
+	if err := Webhook(srv.URL, secret).Notify(context.Background(), testEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signature, "sha256=") || !Verify(body, secret, signature) {
+		t.Errorf("Signature %q doesn't verify", signature)
+	}
+	if Verify(body, []byte("other"), signature) || Verify(append(body, ' '), secret, signature) {
+		t.Error("Expected the signature to fail with another secret or body")
+	}
+
+	// Computed with: printf %s '{"a":1}' | openssl dgst -sha256 -hmac s3cret
+	if got, want := Sign([]byte(`{"a":1}`), secret), "sha256=5910e62016ef5034272c926c27071992a465c2335cecf41851bda071577f4f6d"; got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	err := Slack(srv.URL+"/services/T000/B000/secret").Notify(context.Background(), testEvent())
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Fatalf("Expected the status in the error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expected the error to leave the URL path out, got %v", err)
+	}
+}
+
+func TestSlackMessage(t *testing.T) {
+	expected := "2 schema changes in *shop*, 1 breaking:\n```\n+ table public.invoices\n- column public.orders.total (breaking)\n```"
+	if actual := SlackMessage(testEvent()); actual != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, actual)
+	}
+
+	event := testEvent()
+	event.Database = "<a&b>"
+	event.Changes = event.Changes[:1]
+	if actual := SlackMessage(event); !strings.HasPrefix(actual, "1 schema change in *&lt;a&amp;b&gt;*:\n") {
+		t.Errorf("Unexpected message\n%s", actual)
+	}
+
+	for range 60 {
+		event.Changes = append(event.Changes, event.Changes[0])
+	}
+	if actual := SlackMessage(event); !strings.Contains(actual, "… and 11 more\n```") {
+		t.Errorf("Expected the changes to be truncated, got\n%s", actual)
+	}
+}
@@ -0,0 +1,87 @@
+// Package watch polls a database schema and reports its changes as events
+package watch
+
+import (
+	"context"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+	"github.com/guillermo/dbinfo/metrics"
+)
+
+// Event describes the changes found between two polls
+type Event struct {
+	Time                time.Time      `json:"time"`
+	Database            string         `json:"database,omitempty"`   // Names the database
+	Server              string         `json:"server,omitempty"`     // host:port of the database server, when known
+	Fingerprint         string         `json:"fingerprint"`          // Of the schema after the changes, see metrics.Fingerprint
+	PreviousFingerprint string         `json:"previous_fingerprint"` // Of the schema before the changes
+	Summary             Summary        `json:"summary"`
+	Changes             []*diff.Change `json:"changes"`
+}
+
+// Summary counts the changes of an event by type
+type Summary struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+	Breaking int `json:"breaking"`
+}
+
+// newEvent returns the event for the changes from previous to current
+func newEvent(previous, current *dbinfo.DBInfo, changes []*diff.Change) *Event {
+	event := &Event{
+		Time:                time.Now(),
+		Fingerprint:         metrics.Fingerprint(current),
+		PreviousFingerprint: metrics.Fingerprint(previous),
+		Changes:             changes,
+	}
+	for _, change := range changes {
+		switch change.Type {
+		case diff.Added:
+			event.Summary.Added++
+		case diff.Removed:
+			event.Summary.Removed++
+		case diff.Modified:
+			event.Summary.Modified++
+		}
+		if change.Breaking {
+			event.Summary.Breaking++
+		}
+	}
+	return event
+}
+
+// Watch loads the schema every interval and calls fn with the changes since
+// the previous load. Polls without changes do not produce events, and
+// events leave naming the database to fn. It runs
+// until ctx is done, load fails or fn returns an error, and returns
+// ctx.Err() when the context ends it.
+func Watch(ctx context.Context, load dbinfo.Loader, interval time.Duration, fn func(*Event) error) error {
+	previous, err := load(ctx)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := load(ctx)
+		if err != nil {
+			return err
+		}
+		if changes := diff.Compare(previous, current); len(changes) > 0 {
+			if err := fn(newEvent(previous, current, changes)); err != nil {
+				return err
+			}
+		}
+		previous = current
+	}
+}
@@ -0,0 +1,88 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/guillermo/dbinfo"
+	"github.com/guillermo/dbinfo/diff"
+)
+
+func TestWatch(t *testing.T) {
+	// The schema gains a table on the third load
+	var loads int
+	load := func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		loads++
+		info := &dbinfo.DBInfo{Name: "shop", Tables: []*dbinfo.Table{{Name: "orders", Schema: "public"}}}
+		if loads >= 3 {
+			info.Tables = append(info.Tables, &dbinfo.Table{Name: "invoices", Schema: "public"})
+		}
+		return info, nil
+	}
+
+	done := errors.New("done")
+	var events []*Event
+	err := Watch(context.Background(), load, time.Millisecond, func(event *Event) error {
+		events = append(events, event)
+		return done
+	})
+	if !errors.Is(err, done) {
+		t.Fatalf("Expected the callback error, got %v", err)
+	}
+	if loads != 3 || len(events) != 1 {
+		t.Fatalf("Expected one event after 3 loads, got %d events after %d loads", len(events), loads)
+	}
+	change := events[0].Changes[0]
+	if change.Type != diff.Added || change.Table != "invoices" {
+		t.Errorf("Unexpected change %v", change)
+	}
+	if summary := events[0].Summary; summary != (Summary{Added: 1}) {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if fp := events[0].Fingerprint; fp == "" || fp == events[0].PreviousFingerprint {
+		t.Errorf("Expected the fingerprint to change, got %q before and after", fp)
+	}
+}
+
+func TestWatchViews(t *testing.T) {
+	// A view is dropped on the second load
+	var loads int
+	load := func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		loads++
+		info := &dbinfo.DBInfo{Name: "shop", Tables: []*dbinfo.Table{{Name: "orders", Schema: "public"}}}
+		if loads == 1 {
+			info.Views = []*dbinfo.View{{Name: "recent_orders", Schema: "public", Definition: "SELECT * FROM orders"}}
+		}
+		return info, nil
+	}
+
+	done := errors.New("done")
+	var events []*Event
+	Watch(context.Background(), load, time.Millisecond, func(event *Event) error {
+		events = append(events, event)
+		return done
+	})
+	if len(events) != 1 || events[0].Changes[0].Kind != diff.KindView {
+		t.Fatalf("Expected the removed view to be notified, got %v", events)
+	}
+	if summary := events[0].Summary; summary != (Summary{Removed: 1, Breaking: 1}) {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if fp := events[0].Fingerprint; fp == events[0].PreviousFingerprint {
+		t.Errorf("Expected the fingerprint to change, got %q before and after", fp)
+	}
+}
+
+func TestWatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	load := func(ctx context.Context) (*dbinfo.DBInfo, error) {
+		cancel()
+		return &dbinfo.DBInfo{}, nil
+	}
+	err := Watch(ctx, load, time.Hour, func(*Event) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
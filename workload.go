@@ -0,0 +1,252 @@
+package dbinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Thresholds of the workload annotations
+const (
+	// hotShare is the share of the activity of the busiest table a table
+	// needs to be hot
+	hotShare = 0.1
+	// missingIndexRows is the number of rows a sequential scan reads on
+	// average above which scanning is likely slower than an index lookup
+	missingIndexRows = 10000
+	// missingIndexRatio is the share of sequential scans above which a large
+	// table likely misses an index
+	missingIndexRatio = 0.5
+)
+
+// Workload is how busy a table is, read from pg_stat_user_tables and
+// pg_stat_statements. Counters are cumulative since the statistics were last
+// reset, so they compare tables with each other rather than measure a rate.
+type Workload struct {
+	SeqScans     int64   `json:"seqscans" yaml:"seqscans"`
+	SeqRowsRead  int64   `json:"seqrowsread" yaml:"seqrowsread"` // Rows read by the sequential scans
+	IndexScans   int64   `json:"indexscans" yaml:"indexscans"`
+	RowsInserted int64   `json:"rowsinserted" yaml:"rowsinserted"`
+	RowsUpdated  int64   `json:"rowsupdated" yaml:"rowsupdated"`
+	RowsDeleted  int64   `json:"rowsdeleted" yaml:"rowsdeleted"`
+	SeqScanRatio float64 `json:"seqscanratio" yaml:"seqscanratio"` // Share of the scans that were sequential, from 0 to 1
+	// Calls is the number of executions of the statements naming the table,
+	// 0 without pg_stat_statements
+	Calls int64 `json:"calls" yaml:"calls"`
+	// Hot tables have at least a tenth of the reads and writes of the
+	// busiest table
+	Hot bool `json:"hot" yaml:"hot"`
+	// MissingIndex is set when the table is mostly read by sequential scans
+	// of more than 10000 rows each, which an index would likely avoid
+	MissingIndex bool `json:"missingindex" yaml:"missingindex"`
+}
+
+// Reads returns the number of scans of the table, sequential or by index
+func (w *Workload) Reads() int64 {
+	return w.SeqScans + w.IndexScans
+}
+
+// Writes returns the number of rows inserted, updated and deleted
+func (w *Workload) Writes() int64 {
+	return w.RowsInserted + w.RowsUpdated + w.RowsDeleted
+}
+
+// add adds the counters of other, a partition of the table
+func (w *Workload) add(other *Workload) {
+	w.SeqScans += other.SeqScans
+	w.SeqRowsRead += other.SeqRowsRead
+	w.IndexScans += other.IndexScans
+	w.RowsInserted += other.RowsInserted
+	w.RowsUpdated += other.RowsUpdated
+	w.RowsDeleted += other.RowsDeleted
+}
+
+// tableWorkloadQuery reads the statistics collector counters of every user
+// table. Tables never scanned have NULL index scans when they have no index.
+const tableWorkloadQuery = `
+	SELECT schemaname, relname, seq_scan, seq_tup_read, COALESCE(idx_scan, 0),
+	       n_tup_ins, n_tup_upd, n_tup_del
+	FROM pg_stat_user_tables`
+
+// statementsExtensionQuery tells whether pg_stat_statements is installed
+const statementsExtensionQuery = `SELECT EXISTS (SELECT FROM pg_extension WHERE extname = 'pg_stat_statements')`
+
+// statementsQuery reads the statements run on the current database with
+// their number of executions. The view must be on the search path.
+const statementsQuery = `
+	SELECT query, calls
+	FROM pg_stat_statements
+	WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())`
+
+// getWorkload reads the workload of tables and annotates them. Statistics
+// the role can't read are recorded as warnings.
+func getWorkload(ctx context.Context, db DBQuerier, o *options, tables []*Table) error {
+	workloads := make(map[ObjectKey]*Workload, len(tables))
+	// The counters of rolled up partitions add up in their table
+	partitionsOf := make(map[*Table][]*Partition)
+	for _, table := range tables {
+		w := &Workload{}
+		workloads[ObjectKey{table.Schema, table.Name}] = w
+		var walk func([]*Partition)
+		walk = func(partitions []*Partition) {
+			for _, p := range partitions {
+				workloads[ObjectKey{p.Schema, p.Name}] = w
+				partitionsOf[table] = append(partitionsOf[table], p)
+				walk(p.Partitions)
+			}
+		}
+		walk(table.Partitions)
+	}
+
+	rows, err := db.Query(ctx, tableWorkloadQuery)
+	if err != nil {
+		return queryError(PhaseWorkload, "", fmt.Errorf("failed to query table workload: %w", err))
+	}
+	for rows.Next() {
+		var key ObjectKey
+		w := &Workload{}
+		if err := rows.Scan(&key.Schema, &key.Name, &w.SeqScans, &w.SeqRowsRead, &w.IndexScans, &w.RowsInserted, &w.RowsUpdated, &w.RowsDeleted); err != nil {
+			rows.Close()
+			return queryError(PhaseWorkload, "", fmt.Errorf("failed to scan table workload row: %w", err))
+		}
+		if total, ok := workloads[key]; ok {
+			total.add(w)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return queryError(PhaseWorkload, "", fmt.Errorf("error iterating table workload rows: %w", err))
+	}
+
+	calls, err := getStatementCalls(ctx, db)
+	if err != nil && statementsUnavailable(err) {
+		o.warn(ctx, PhaseWorkload, "", err)
+	} else if err != nil {
+		return queryError(PhaseWorkload, "", err)
+	}
+	for _, table := range tables {
+		w := workloads[ObjectKey{table.Schema, table.Name}]
+		w.Calls = calls[table.Name]
+		for _, p := range partitionsOf[table] {
+			w.Calls += calls[p.Name]
+		}
+	}
+
+	annotateWorkload(tables, workloads)
+	return nil
+}
+
+// getStatementCalls returns the executions of the statements of
+// pg_stat_statements by table name they mention, or nil when the extension
+// isn't installed
+func getStatementCalls(ctx context.Context, db DBQuerier) (map[string]int64, error) {
+	var installed bool
+	if err := db.QueryRow(ctx, statementsExtensionQuery).Scan(&installed); err != nil {
+		return nil, fmt.Errorf("failed to check for pg_stat_statements: %w", err)
+	}
+	if !installed {
+		return nil, nil
+	}
+
+	rows, err := db.Query(ctx, statementsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pg_stat_statements: %w", err)
+	}
+	defer rows.Close()
+
+	calls := make(map[string]int64)
+	for rows.Next() {
+		var query string
+		var n int64
+		if err := rows.Scan(&query, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan pg_stat_statements row: %w", err)
+		}
+		for name := range statementNames(query) {
+			calls[name] += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pg_stat_statements rows: %w", err)
+	}
+	return calls, nil
+}
+
+// statementsUnavailable reports whether err means pg_stat_statements can't
+// be read, because the role lacks privileges, the view isn't on the search
+// path or the library isn't preloaded
+func statementsUnavailable(err error) bool {
+	var pgErr *pgconn.PgError
+	return skippable(err) || errors.As(err, &pgErr) && pgErr.Code == "55000" // object_not_in_prerequisite_state
+}
+
+// statementNames returns the identifiers of a statement, the names of the
+// tables it reads or writes among them. Unquoted identifiers are folded to
+// lower case, and string literals and comments are skipped. Qualified names
+// count as their last part, so tables of the same name in different schemas
+// share their calls.
+func statementNames(query string) map[string]bool {
+	names := make(map[string]bool)
+	r := []rune(query)
+	for i := 0; i < len(r); i++ {
+		switch c := r[i]; {
+		case c == '\'':
+			for i++; i < len(r) && r[i] != '\''; i++ {
+			}
+		case c == '-' && i+1 < len(r) && r[i+1] == '-':
+			for ; i < len(r) && r[i] != '\n'; i++ {
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			for i += 2; i+1 < len(r) && (r[i] != '*' || r[i+1] != '/'); i++ {
+			}
+			i++
+		case c == '"':
+			var b strings.Builder
+			for i++; i < len(r); i++ {
+				if r[i] == '"' {
+					if i+1 < len(r) && r[i+1] == '"' {
+						b.WriteRune('"')
+						i++
+						continue
+					}
+					break
+				}
+				b.WriteRune(r[i])
+			}
+			names[b.String()] = true
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i+1 < len(r) && (r[i+1] == '_' || r[i+1] == '$' || unicode.IsLetter(r[i+1]) || unicode.IsDigit(r[i+1])) {
+				i++
+			}
+			names[strings.ToLower(string(r[start:i+1]))] = true
+		case c == '$' || unicode.IsDigit(c):
+			// Parameters and numbers, skipped with the digits following them
+			for i+1 < len(r) && unicode.IsDigit(r[i+1]) {
+				i++
+			}
+		}
+	}
+	return names
+}
+
+// annotateWorkload sets the workload of the tables, computing the ratios
+// and flags from the counters
+func annotateWorkload(tables []*Table, workloads map[ObjectKey]*Workload) {
+	var busiest int64
+	for _, w := range workloads {
+		busiest = max(busiest, w.Reads()+w.Writes())
+	}
+	for _, table := range tables {
+		w := workloads[ObjectKey{table.Schema, table.Name}]
+		if reads := w.Reads(); reads > 0 {
+			w.SeqScanRatio = float64(w.SeqScans) / float64(reads)
+		}
+		w.Hot = busiest > 0 && float64(w.Reads()+w.Writes()) >= hotShare*float64(busiest)
+		w.MissingIndex = w.SeqScans > 0 && w.SeqRowsRead/w.SeqScans > missingIndexRows && w.SeqScanRatio > missingIndexRatio
+		table.Workload = w
+	}
+}